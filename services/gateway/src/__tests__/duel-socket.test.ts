@@ -0,0 +1,22 @@
+/**
+ * Tests for relaying duel sockets to the NGS service
+ */
+import { ngsDuelSocketPath } from '../middleware/duel-socket';
+
+const DUEL_ID = '0b6c3e52-8f5e-4d59-a0a4-7f3d2f1f4c21';
+
+describe('Duel Socket Paths', () => {
+  it('should relay a duel socket to its NGS path', () => {
+    expect(ngsDuelSocketPath(`/ws/duels/${DUEL_ID}`)).toBe(`/ngs/duels/${DUEL_ID}/ws`);
+  });
+
+  it('should not pass the token query on', () => {
+    expect(ngsDuelSocketPath(`/ws/duels/${DUEL_ID}?token=abc.def.ghi`)).toBe(`/ngs/duels/${DUEL_ID}/ws`);
+  });
+
+  it('should not relay other paths', () => {
+    expect(ngsDuelSocketPath('/ws/chat')).toBeUndefined();
+    expect(ngsDuelSocketPath('/ws/duels/history')).toBeUndefined();
+    expect(ngsDuelSocketPath(`/ws/duels/${DUEL_ID}/submit`)).toBeUndefined();
+  });
+});
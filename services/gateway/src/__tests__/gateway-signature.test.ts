@@ -0,0 +1,85 @@
+/**
+ * Tests for signing identity headers forwarded to the NGS service
+ */
+import { forwardIdentity, signGatewayRequest, signProxyRequest } from '../middleware/gateway-signature';
+
+// The same request and signature are checked by the NGS service's
+// TestGatewaySignature, so both sides agree on the format
+const SECRET = 'gateway-test-secret';
+const USER_ID = '9f0c1f9e-2f55-4a8e-9d0a-1f7c7c1f0a11';
+const EXPECTED = '596b95621ff72878325352e5375169eeb5cc1a4e38129b1c01babd0c9ab22571';
+
+describe('Gateway Request Signing', () => {
+  it('should sign the timestamp, method, URI and identity headers', () => {
+    const signature = signGatewayRequest(SECRET, '1767225600', 'get', '/ngs/progress?limit=5', [
+      USER_ID,
+      'student',
+      'ada@example.com',
+      '',
+      'acme',
+    ]);
+
+    expect(signature).toBe(EXPECTED);
+  });
+
+  it('should sign the identity headers set on a proxy request', () => {
+    const headers: Record<string, string> = {
+      'x-user-id': USER_ID,
+      'x-user-role': 'student',
+      'x-user-email': 'ada@example.com',
+      'x-org-id': 'acme',
+    };
+    const proxyReq = {
+      method: 'GET',
+      path: '/ngs/progress?limit=5',
+      getHeader: (name: string) => headers[name.toLowerCase()],
+      setHeader: (name: string, value: string) => {
+        headers[name.toLowerCase()] = value;
+      },
+    };
+
+    signProxyRequest(proxyReq, SECRET, () => 1767225600 * 1000 + 999);
+
+    expect(headers['x-gateway-timestamp']).toBe('1767225600');
+    expect(headers['x-gateway-signature']).toBe(EXPECTED);
+  });
+
+  it('should replace client identity headers with the authenticated user', () => {
+    const headers: Record<string, string> = {
+      'x-user-id': 'spoofed',
+      'x-user-role': 'admin',
+      'x-user-tier': 'enterprise',
+    };
+    const proxyReq = {
+      method: 'GET',
+      path: '/ngs/progress?limit=5',
+      getHeader: (name: string) => headers[name.toLowerCase()],
+      setHeader: (name: string, value: string) => {
+        headers[name.toLowerCase()] = value;
+      },
+      removeHeader: (name: string) => {
+        delete headers[name.toLowerCase()];
+      },
+    };
+
+    forwardIdentity(proxyReq, { userId: USER_ID, email: 'ada@example.com', role: 'student', org_id: 'acme' }, SECRET);
+
+    expect(headers['x-user-id']).toBe(USER_ID);
+    expect(headers['x-user-role']).toBe('student');
+    expect(headers['x-user-tier']).toBeUndefined();
+    expect(headers['x-org-id']).toBe('acme');
+    expect(headers['x-gateway-signature']).toBeDefined();
+  });
+
+  it('should change the signature when an identity header changes', () => {
+    const signature = signGatewayRequest(SECRET, '1767225600', 'GET', '/ngs/progress?limit=5', [
+      USER_ID,
+      'admin',
+      'ada@example.com',
+      '',
+      'acme',
+    ]);
+
+    expect(signature).not.toBe(EXPECTED);
+  });
+});
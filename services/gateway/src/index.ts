@@ -10,6 +10,8 @@ import { createProxyMiddleware } from 'http-proxy-middleware';
 import rateLimit from 'express-rate-limit';
 import Redis from 'ioredis';
 import { generateServiceToken, serviceAuthMiddleware } from './middleware/service-auth';
+import { forwardIdentity } from './middleware/gateway-signature';
+import { ngsDuelSocketPath } from './middleware/duel-socket';
 import { metricsMiddleware } from './middleware/metrics-middleware';
 import { correlationIdMiddleware } from './middleware/correlation-id';
 import { register, websocketConnectionsActive, websocketConnectionsTotal, websocketMessagesTotal, rateLimitExceededTotal, authValidationTotal } from './metrics';
@@ -49,6 +51,12 @@ try {
   console.error('Failed to generate gateway service token:', error);
 }
 
+// Secret the NGS service checks forwarded identity headers with (GATEWAY_SIGNING_SECRET there)
+const GATEWAY_SIGNING_SECRET = process.env.GATEWAY_SIGNING_SECRET || '';
+if (!GATEWAY_SIGNING_SECRET) {
+  console.warn('WARNING: GATEWAY_SIGNING_SECRET not set. Identity headers sent to NGS are unsigned.');
+}
+
 // Service URLs
 const AUTH_SERVICE_URL = process.env.AUTH_SERVICE_URL || 'http://localhost:3001';
 const INTELLIGENCE_SERVICE_URL = process.env.INTELLIGENCE_SERVICE_URL || 'http://localhost:8000';
@@ -449,6 +457,7 @@ interface AuthRequest extends Request {
     email: string;
     role: string;
     subscription_tier?: string;
+    org_id?: string;
   };
 }
 
@@ -473,6 +482,7 @@ const authenticateToken = (req: AuthRequest, res: Response, next: NextFunction)
       email: decoded.email,
       role: decoded.role,
       ...(decoded.subscription_tier && { subscription_tier: decoded.subscription_tier }),
+      ...(decoded.org_id && { org_id: decoded.org_id }),
     };
     next();
   });
@@ -814,10 +824,15 @@ app.use(
         proxyReq.setHeader('X-Service-Token', gatewayServiceToken);
       }
       // Forward user context
-      if (req.user) {
-        proxyReq.setHeader('X-User-Id', req.user.userId);
-        proxyReq.setHeader('X-User-Email', req.user.email);
-        proxyReq.setHeader('X-User-Role', req.user.role);
+      forwardIdentity(proxyReq, req.user, GATEWAY_SIGNING_SECRET);
+      // Forward the rate-limit window so /ngs/me/usage can report it
+      const rateLimitInfo = (req as any).rateLimit;
+      if (rateLimitInfo) {
+        proxyReq.setHeader('X-RateLimit-Limit', String(rateLimitInfo.limit));
+        proxyReq.setHeader('X-RateLimit-Remaining', String(rateLimitInfo.remaining));
+        if (rateLimitInfo.resetTime) {
+          proxyReq.setHeader('X-RateLimit-Reset', String(Math.ceil(rateLimitInfo.resetTime.getTime() / 1000)));
+        }
       }
       if (['POST', 'PUT', 'PATCH', 'DELETE'].includes(req.method)) {
         forwardJsonBody(proxyReq, req as Request);
@@ -833,6 +848,24 @@ app.use(
   })
 );
 
+// NGS duel sockets (/ws/duels/:id?token=...) are relayed to the NGS service once the upgrade
+// handler below has authenticated them
+const ngsDuelSocketProxy = createProxyMiddleware({
+  target: NGS_SERVICE_URL,
+  changeOrigin: true,
+  ws: true,
+  pathRewrite: (path) => ngsDuelSocketPath(path) || path,
+  onProxyReqWs: (proxyReq, req: AuthRequest) => {
+    if (gatewayServiceToken) {
+      proxyReq.setHeader('X-Service-Token', gatewayServiceToken);
+    }
+    forwardIdentity(proxyReq, req.user, GATEWAY_SIGNING_SECRET);
+  },
+  onError: (err) => {
+    console.error('NGS duel socket proxy error:', err.message);
+  },
+});
+
 // MCP Server proxy (requires authentication)
 const MCP_SERVICE_URL = process.env.MCP_SERVICE_URL || 'http://localhost:7000';
 app.use(
@@ -978,7 +1011,44 @@ app.use((req: Request, res: Response) => {
 const server = createServer(app);
 
 // WebSocket server setup
-const wss = new WebSocketServer({ server, path: '/ws/chat' });
+const wss = new WebSocketServer({ noServer: true });
+
+// Route socket upgrades: tutor chat stays on the gateway, duels are relayed to NGS
+server.on('upgrade', (req, socket, head) => {
+  const url = new URL(req.url || '', `http://${req.headers.host}`);
+  if (url.pathname === '/ws/chat') {
+    wss.handleUpgrade(req, socket, head, (ws) => wss.emit('connection', ws, req));
+    return;
+  }
+
+  if (ngsDuelSocketPath(url.pathname)) {
+    const token = url.searchParams.get('token');
+    let decoded: any;
+    try {
+      decoded = token ? jwt.verify(token, JWT_SECRET) : undefined;
+    } catch (err) {
+      decoded = undefined;
+    }
+    if (!decoded) {
+      websocketConnectionsTotal.labels({ status: 'rejected' }).inc();
+      logger.warn('Duel socket rejected: Invalid or missing token');
+      socket.write('HTTP/1.1 401 Unauthorized\r\n\r\n');
+      socket.destroy();
+      return;
+    }
+    (req as AuthRequest).user = {
+      userId: decoded.sub,
+      email: decoded.email,
+      role: decoded.role,
+      ...(decoded.subscription_tier && { subscription_tier: decoded.subscription_tier }),
+      ...(decoded.org_id && { org_id: decoded.org_id }),
+    };
+    ngsDuelSocketProxy.upgrade!(req as any, socket as any, head);
+    return;
+  }
+
+  socket.destroy();
+});
 
 interface AuthenticatedWebSocket extends WebSocket {
   userId?: string;
@@ -1117,6 +1187,7 @@ server.listen(PORT, () => {
   logger.info('🚀 Noble Gateway (Phase 3 - Complete)');
   logger.info(`📡 HTTP API: http://0.0.0.0:${PORT}`);
   logger.info(`🔌 WebSocket: ws://0.0.0.0:${PORT}/ws/chat`);
+  logger.info(`🔌 Duel sockets: ws://0.0.0.0:${PORT}/ws/duels/:id → ${NGS_SERVICE_URL}`);
   logger.info(`✅ Health check: http://0.0.0.0:${PORT}/health`);
   logger.info(`📊 Metrics: http://0.0.0.0:${PORT}/metrics`);
   logger.info(`🔐 JWT authentication: enabled`);
@@ -0,0 +1,11 @@
+const DUEL_SOCKET_PATH = /^\/ws\/duels\/([0-9a-fA-F-]{36})$/;
+
+/**
+ * Returns the NGS path a gateway duel socket URL is relayed to, or undefined
+ * when the URL is not a duel socket. The query, which carries the caller's
+ * token, is not passed on.
+ */
+export const ngsDuelSocketPath = (url: string): string | undefined => {
+  const match = DUEL_SOCKET_PATH.exec(url.split('?')[0]);
+  return match ? `/ngs/duels/${match[1]}/ws` : undefined;
+};
@@ -0,0 +1,92 @@
+import { createHmac } from 'crypto';
+
+/**
+ * Identity headers the gateway forwards to the NGS service, in signing order.
+ * Must match GatewayIdentityHeaders in services/ngs-curriculum/internal/services.
+ */
+export const GATEWAY_IDENTITY_HEADERS = ['X-User-Id', 'X-User-Role', 'X-User-Email', 'X-User-Tier', 'X-Org-Id'];
+
+/**
+ * Returns the hex HMAC-SHA256 sent in X-Gateway-Signature. It covers the
+ * X-Gateway-Timestamp value, the upper-case method, the request URI with its
+ * query, and the identity header values in order, each on its own line; absent
+ * headers are empty lines. The body is not covered.
+ */
+export const signGatewayRequest = (
+  secret: string,
+  timestamp: string,
+  method: string,
+  requestUri: string,
+  identity: string[]
+): string => {
+  const mac = createHmac('sha256', secret);
+  mac.update(`${timestamp}\n${method.toUpperCase()}\n${requestUri}`);
+  for (const value of identity) {
+    mac.update(`\n${value}`);
+  }
+  return mac.digest('hex');
+};
+
+/**
+ * The parts of an outgoing proxy request that signing reads and writes
+ */
+interface SignableRequest {
+  method: string;
+  path: string;
+  getHeader(name: string): number | string | string[] | undefined;
+  setHeader(name: string, value: string): void;
+}
+
+/**
+ * Signs the identity headers already set on an outgoing proxy request, so the
+ * service can trust them. The path is the rewritten one the service sees.
+ */
+export const signProxyRequest = (proxyReq: SignableRequest, secret: string, now: () => number = Date.now) => {
+  const timestamp = String(Math.floor(now() / 1000));
+  const identity = GATEWAY_IDENTITY_HEADERS.map((header) => {
+    const value = proxyReq.getHeader(header);
+    return value === undefined ? '' : String(value);
+  });
+  proxyReq.setHeader('X-Gateway-Timestamp', timestamp);
+  proxyReq.setHeader('X-Gateway-Signature', signGatewayRequest(secret, timestamp, proxyReq.method, proxyReq.path, identity));
+};
+
+/**
+ * The caller the gateway authenticated, as forwarded to the NGS service
+ */
+export interface GatewayIdentity {
+  userId: string;
+  email: string;
+  role: string;
+  subscription_tier?: string;
+  org_id?: string;
+}
+
+interface ForwardableRequest extends SignableRequest {
+  removeHeader(name: string): void;
+}
+
+/**
+ * Replaces any identity headers on an outgoing proxy request with the
+ * authenticated caller's, and signs them when a secret is set. Identity
+ * headers sent by the client are never passed on.
+ */
+export const forwardIdentity = (proxyReq: ForwardableRequest, user: GatewayIdentity | undefined, secret: string) => {
+  for (const header of GATEWAY_IDENTITY_HEADERS) {
+    proxyReq.removeHeader(header);
+  }
+  if (user) {
+    proxyReq.setHeader('X-User-Id', user.userId);
+    proxyReq.setHeader('X-User-Email', user.email);
+    proxyReq.setHeader('X-User-Role', user.role);
+    if (user.subscription_tier) {
+      proxyReq.setHeader('X-User-Tier', user.subscription_tier);
+    }
+    if (user.org_id) {
+      proxyReq.setHeader('X-Org-Id', user.org_id);
+    }
+  }
+  if (secret) {
+    signProxyRequest(proxyReq, secret);
+  }
+};
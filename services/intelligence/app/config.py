@@ -115,6 +115,11 @@ class Settings(BaseSettings):
     openai_base_url: str = os.getenv("OPENAI_BASE_URL", "https://api.openai.com/v1")
     openai_organization: Optional[str] = os.getenv("OPENAI_ORGANIZATION")
     openai_project: Optional[str] = os.getenv("OPENAI_PROJECT")
+
+    # Media transcription (OpenAI-compatible audio API)
+    transcription_model: str = os.getenv("TRANSCRIPTION_MODEL", "whisper-1")
+    transcription_max_bytes: int = int(os.getenv("TRANSCRIPTION_MAX_BYTES", str(25 * 1024 * 1024)))
+    transcription_timeout_sec: float = float(os.getenv("TRANSCRIPTION_TIMEOUT_SEC", "300"))
     
     # Token Limits
     free_tier_tokens_day: int = int(os.getenv("FREE_TIER_TOKENS_DAY", "1000"))
@@ -169,6 +169,19 @@ class LessonArtifacts(BaseModel):
     glossary: List[GlossaryTerm] = Field(default_factory=list, description="Glossary terms")
 
 
+class VisualAltText(BaseModel):
+    """Alt-text for one visual aid."""
+    visual: str = Field(..., description="Visual aid description as listed in teach.visuals")
+    alt_text: str = Field(..., description="Concise screen-reader alternative text")
+
+
+class AccessibilityInfo(BaseModel):
+    """Accessibility metadata and alternative formats."""
+    plain_language_summary: str = Field(..., description="Summary in plain language (short sentences, common words)")
+    alt_text: List[VisualAltText] = Field(default_factory=list, description="Alt-text for each visual aid")
+    reading_level: Optional[float] = Field(None, ge=0, le=20, description="Estimated US grade reading level")
+
+
 class StructuredLesson(BaseModel):
     """Complete structured lesson with all sections."""
     metadata: LessonMetadata
@@ -177,6 +190,7 @@ class StructuredLesson(BaseModel):
     assessment: Assessment
     summary: str = Field(..., description="Lesson summary and key takeaways")
     artifacts: LessonArtifacts = Field(default_factory=LessonArtifacts)
+    accessibility: Optional[AccessibilityInfo] = Field(None, description="Accessibility metadata")
 
 
 class LearnerProfile(BaseModel):
@@ -193,6 +207,7 @@ class GenerationConstraints(BaseModel):
     target_minutes: int = Field(default=30, ge=5, le=120, description="Target lesson duration")
     prereqs: List[str] = Field(default_factory=list, description="Required prerequisites")
     require_ethics_guardrails: bool = Field(default=True, description="Apply ethics guardrails")
+    include_accessibility: bool = Field(default=True, description="Generate accessibility metadata")
 
 
 class GenerateLessonRequest(BaseModel):
@@ -227,3 +242,61 @@ class EducatorChatResponse(BaseModel):
     lesson_id: UUID
     tokens_used: int
     latency_ms: int
+
+
+class TranscribeMediaRequest(BaseModel):
+    """Request to transcribe and summarize a lesson video."""
+    media_url: str = Field(..., min_length=1, max_length=2048, description="HTTPS URL of the media file")
+    lesson_title: str = Field(..., min_length=1, max_length=255, description="Lesson title for summary context")
+    language: Optional[str] = Field(None, max_length=10, description="ISO-639-1 language hint")
+
+
+class TranscribeMediaResponse(BaseModel):
+    """Transcript and AI summary for a lesson video."""
+    transcript: str
+    summary: str
+    language: Optional[str] = None
+    model: str = Field(..., description="Transcription model used")
+    provider: str = Field(..., description="LLM provider used for the summary")
+    tokens_used: int = Field(..., ge=0, description="Tokens consumed by the summary")
+    latency_ms: int = Field(..., ge=0, description="Total processing latency")
+
+
+class RubricCriterion(BaseModel):
+    """One criterion of a design challenge rubric."""
+    name: str = Field(..., min_length=1, max_length=255)
+    description: Optional[str] = Field(None, max_length=1000)
+    max_points: int = Field(..., gt=0, le=1000)
+
+
+class DesignAsset(BaseModel):
+    """A diagram or document uploaded with a design submission."""
+    file_name: str = Field(..., max_length=255)
+    content_type: str = Field(..., max_length=100)
+    data: Optional[str] = Field(None, description="Base64 file contents")
+
+
+class EvaluateDesignRequest(BaseModel):
+    """Request to score a design challenge submission against its rubric."""
+    challenge_title: str = Field(..., min_length=1, max_length=255)
+    challenge_description: str = Field("", max_length=20000)
+    rubric: List[RubricCriterion] = Field(..., min_length=1, max_length=20)
+    writeup: str = Field(..., min_length=1, max_length=100000, description="Markdown write-up")
+    assets: Optional[List[DesignAsset]] = None
+
+
+class CriterionScore(BaseModel):
+    """Points awarded for one rubric criterion."""
+    criterion: str
+    points: int = Field(..., ge=0)
+    comment: str = ""
+
+
+class EvaluateDesignResponse(BaseModel):
+    """Rubric scores for a design submission."""
+    scores: List[CriterionScore]
+    comments: str
+    model: str
+    provider: str
+    tokens_used: int = Field(..., ge=0)
+    latency_ms: int = Field(..., ge=0)
@@ -11,12 +11,17 @@ from app.database import get_db
 from app.models.schemas import (
     GenerateLessonRequest, GenerateLessonResponse,
     EducatorChatMessage, EducatorChatResponse,
-    SessionInfo, StructuredLesson
+    SessionInfo, StructuredLesson,
+    TranscribeMediaRequest, TranscribeMediaResponse,
+    EvaluateDesignRequest, EvaluateDesignResponse, CriterionScore
 )
 from app.services.llm_router import llm_orchestrator, ProviderExhaustedError
 from app.services.session_service import SessionService
 from app.services.integration_service import integration_service
 from app.services.usage_service import usage_service
+from app.services.transcription_service import (
+    transcription_service, TranscriptionError, TranscriptionUnavailableError
+)
 from app.utils.token_counter import token_counter
 from app.utils.service_auth import verify_service_token_dependency, ServiceTokenPayload
 from app.utils.sanitize import sanitize_message
@@ -27,6 +32,10 @@ router = APIRouter(prefix="/educator", tags=["educator"])
 
 # Security constants
 MAX_MESSAGE_LENGTH = 10000
+# Transcript characters passed to the summarizer
+MAX_SUMMARY_INPUT_CHARS = 24000
+# Write-up characters passed to the design evaluator
+MAX_DESIGN_INPUT_CHARS = 24000
 
 
 def get_user_id(x_user_id: Optional[str] = Header(None)) -> UUID:
@@ -67,9 +76,14 @@ Learner Profile:
 Constraints:
 - Target Duration: {request.constraints.target_minutes} minutes
 - Prerequisites: {', '.join(request.constraints.prereqs) if request.constraints.prereqs else 'None'}
-- Ethics Guardrails: {'Required' if request.constraints.require_ethics_guardrails else 'Optional'}
+- Ethics Guardrails: {'Required: include a key concept named "Ethics & Responsible Use" on the ethical risks and responsible practice of the topic' if request.constraints.require_ethics_guardrails else 'Optional'}
+- Never include real personal data (names with contact details, emails, phone numbers, ID or card numbers); use example.com addresses and clearly fictional details
 """
 
+    accessibility_text = ""
+    if request.constraints.include_accessibility:
+        accessibility_text = "7. Accessibility: a plain-language summary, alt-text for every visual aid, and an estimated US grade reading level\n"
+
     user_prompt = f"""Generate a comprehensive lesson for Level {request.level_number} of the Noble Growth School curriculum.
 
 Lesson Summary: {request.lesson_summary}
@@ -84,7 +98,7 @@ Create a structured lesson with:
 4. Assessment checks (MCQ or short answer) with explanations
 5. Summary of key takeaways
 6. Artifacts for integration (quiz items, notes outline, glossary)
-
+{accessibility_text}
 Respond ONLY with valid JSON matching the StructuredLesson schema. No additional text."""
 
     return system_prompt, user_prompt
@@ -245,6 +259,198 @@ async def generate_lesson(
     )
 
 
+@router.post("/transcribe", response_model=TranscribeMediaResponse)
+async def transcribe_media(
+    request: TranscribeMediaRequest,
+    user_id: UUID = Depends(get_user_id),
+    db: Session = Depends(get_db),
+    service: ServiceTokenPayload = Depends(verify_service_token_dependency)
+):
+    """Transcribe a lesson video and summarize the transcript."""
+    start_time = time.time()
+    
+    if not await llm_orchestrator.ensure_ready():
+        raise HTTPException(status_code=503, detail="LLM service not ready")
+    
+    user_tier = await integration_service.get_user_tier(user_id)
+    has_quota, quota_msg = usage_service.check_quota(
+        db, user_id, user_tier, "llm_tokens", 2000
+    )
+    if not has_quota:
+        raise HTTPException(status_code=429, detail=quota_msg)
+    
+    try:
+        transcription = await transcription_service.transcribe(request.media_url, request.language)
+    except TranscriptionUnavailableError as exc:
+        raise HTTPException(status_code=503, detail=str(exc))
+    except TranscriptionError as exc:
+        logger.warning(f"Transcription failed for {request.media_url}: {exc}")
+        raise HTTPException(status_code=422, detail=str(exc))
+    
+    system_prompt = (
+        "You summarize lesson videos for the Noble Growth School. Write a concise summary "
+        "of 3-6 sentences covering the key ideas a learner should remember, followed by a "
+        "short bulleted list of key terms. Use plain language and do not invent content "
+        "that is not in the transcript."
+    )
+    user_prompt = (
+        f"Lesson: {request.lesson_title}\n\n"
+        f"Transcript:\n{transcription.text[:MAX_SUMMARY_INPUT_CHARS]}"
+    )
+    
+    try:
+        provider_result = await llm_orchestrator.generate_response(
+            prompt=user_prompt,
+            system_prompt=system_prompt,
+            temperature=0.3,
+            max_tokens=800,
+        )
+    except ProviderExhaustedError as exc:
+        logger.error(f"All LLM providers exhausted: {exc}")
+        raise HTTPException(status_code=503, detail="LLM providers unavailable")
+    
+    summary = provider_result.content.strip()
+    if not summary:
+        raise HTTPException(status_code=500, detail="LLM returned empty summary")
+    
+    tokens_used = token_counter.count_tokens(system_prompt + user_prompt + summary)
+    latency_ms = int((time.time() - start_time) * 1000)
+    
+    usage_service.record_usage(
+        db, user_id, "llm_tokens", tokens_used,
+        metadata={
+            "media_transcription": True,
+            "transcription_model": transcription.model,
+            "provider": provider_result.provider,
+            "latency_ms": latency_ms,
+        }
+    )
+    
+    logger.info(
+        "Lesson media transcribed",
+        extra={
+            "user_id": str(user_id),
+            "transcript_chars": len(transcription.text),
+            "tokens_used": tokens_used,
+            "latency_ms": latency_ms,
+        }
+    )
+    
+    return TranscribeMediaResponse(
+        transcript=transcription.text,
+        summary=summary,
+        language=transcription.language,
+        model=transcription.model,
+        provider=provider_result.provider,
+        tokens_used=tokens_used,
+        latency_ms=latency_ms,
+    )
+
+
+@router.post("/evaluate-design", response_model=EvaluateDesignResponse)
+async def evaluate_design(
+    request: EvaluateDesignRequest,
+    user_id: UUID = Depends(get_user_id),
+    db: Session = Depends(get_db),
+    service: ServiceTokenPayload = Depends(verify_service_token_dependency)
+):
+    """Score a design challenge write-up against its rubric.
+    
+    Providers take text only, so uploaded assets are described by name and type; the
+    write-up is expected to explain its diagrams.
+    """
+    start_time = time.time()
+    
+    if not await llm_orchestrator.ensure_ready():
+        raise HTTPException(status_code=503, detail="LLM service not ready")
+    
+    rubric_lines = "\n".join(
+        f"- {c.name} (0-{c.max_points} points)" + (f": {c.description}" if c.description else "")
+        for c in request.rubric
+    )
+    asset_lines = "\n".join(
+        f"- {a.file_name} ({a.content_type})" for a in (request.assets or [])
+    ) or "- none"
+    system_prompt = (
+        "You are an educator grading design challenge submissions for the Noble Growth School. "
+        "Score the submission on every rubric criterion, using whole points within each "
+        "criterion's range, with a one-sentence comment per criterion. Then give two or three "
+        "sentences of overall feedback. Respond with JSON only, in the form "
+        '{"scores": [{"criterion": "...", "points": 0, "comment": "..."}], "comments": "..."}, '
+        "using the criterion names exactly as given."
+    )
+    user_prompt = (
+        f"Challenge: {request.challenge_title}\n{request.challenge_description}\n\n"
+        f"Rubric:\n{rubric_lines}\n\n"
+        f"Attached diagrams (not shown):\n{asset_lines}\n\n"
+        f"Write-up:\n{request.writeup[:MAX_DESIGN_INPUT_CHARS]}"
+    )
+    
+    user_tier = await integration_service.get_user_tier(user_id)
+    has_quota, quota_msg = usage_service.check_quota(
+        db, user_id, user_tier, "llm_tokens", token_counter.count_tokens(system_prompt + user_prompt) + 800
+    )
+    if not has_quota:
+        raise HTTPException(status_code=429, detail=quota_msg)
+    
+    try:
+        provider_result = await llm_orchestrator.generate_response(
+            prompt=user_prompt,
+            system_prompt=system_prompt,
+            temperature=0.2,
+            max_tokens=800,
+        )
+    except ProviderExhaustedError as exc:
+        logger.error(f"All LLM providers exhausted: {exc}")
+        raise HTTPException(status_code=503, detail="LLM providers unavailable")
+    
+    try:
+        json_text = provider_result.content.strip()
+        if json_text.startswith("```json"):
+            json_text = json_text.split("```json")[1].split("```")[0].strip()
+        elif json_text.startswith("```"):
+            json_text = json_text.split("```")[1].split("```")[0].strip()
+        
+        evaluation = json.loads(json_text)
+        returned = {
+            str(s.get("criterion", "")).strip().lower(): s for s in evaluation.get("scores", [])
+        }
+        scores = []
+        for criterion in request.rubric:
+            score = returned[criterion.name.lower()]
+            points = min(max(int(score.get("points", 0)), 0), criterion.max_points)
+            scores.append(CriterionScore(
+                criterion=criterion.name,
+                points=points,
+                comment=str(score.get("comment", "")),
+            ))
+        comments = str(evaluation.get("comments", ""))
+    except (json.JSONDecodeError, ValueError, TypeError, KeyError, AttributeError) as e:
+        logger.error(f"Failed to parse design evaluation: {e}\nResponse: {provider_result.content[:500]}")
+        raise HTTPException(status_code=502, detail="LLM returned an unreadable evaluation")
+    
+    tokens_used = token_counter.count_tokens(system_prompt + user_prompt + provider_result.content)
+    latency_ms = int((time.time() - start_time) * 1000)
+    
+    usage_service.record_usage(
+        db, user_id, "llm_tokens", tokens_used,
+        metadata={
+            "design_evaluation": True,
+            "provider": provider_result.provider,
+            "latency_ms": latency_ms,
+        }
+    )
+    
+    return EvaluateDesignResponse(
+        scores=scores,
+        comments=comments,
+        model=provider_result.model,
+        provider=provider_result.provider,
+        tokens_used=tokens_used,
+        latency_ms=latency_ms,
+    )
+
+
 @router.post("/chat/message", response_model=EducatorChatResponse)
 async def educator_chat(
     message: EducatorChatMessage,
@@ -0,0 +1,116 @@
+"""
+Transcription Service for lesson media.
+Downloads a video or audio file and transcribes it through an OpenAI-compatible audio API.
+"""
+
+import logging
+from dataclasses import dataclass
+from typing import Optional
+from urllib.parse import urlparse
+
+import httpx
+
+from app.config import settings
+
+logger = logging.getLogger(__name__)
+
+
+class TranscriptionError(Exception):
+    """Raised when media cannot be downloaded or transcribed."""
+
+
+class TranscriptionUnavailableError(TranscriptionError):
+    """Raised when no transcription backend is configured."""
+
+
+@dataclass
+class TranscriptionResult:
+    text: str
+    language: Optional[str]
+    model: str
+
+
+class TranscriptionService:
+    """Service for turning lesson media into text."""
+
+    def is_configured(self) -> bool:
+        return bool(settings.openai_api_key and settings.openai_base_url and settings.transcription_model)
+
+    async def download_media(self, media_url: str) -> tuple[bytes, str]:
+        """Fetch media over HTTPS, refusing files larger than the configured cap."""
+        parsed = urlparse(media_url)
+        if parsed.scheme != "https" or not parsed.netloc:
+            raise TranscriptionError("media_url must be an https URL")
+
+        max_bytes = settings.transcription_max_bytes
+        filename = parsed.path.rsplit("/", 1)[-1] or "media"
+        chunks = []
+        size = 0
+
+        try:
+            async with httpx.AsyncClient(timeout=settings.transcription_timeout_sec, follow_redirects=True) as client:
+                async with client.stream("GET", media_url) as response:
+                    if response.status_code != 200:
+                        raise TranscriptionError(f"media download returned {response.status_code}")
+                    declared = response.headers.get("content-length")
+                    if declared and declared.isdigit() and int(declared) > max_bytes:
+                        raise TranscriptionError(f"media exceeds {max_bytes} bytes")
+                    async for chunk in response.aiter_bytes():
+                        size += len(chunk)
+                        if size > max_bytes:
+                            raise TranscriptionError(f"media exceeds {max_bytes} bytes")
+                        chunks.append(chunk)
+        except httpx.HTTPError as exc:
+            raise TranscriptionError(f"media download failed: {exc}") from exc
+
+        if size == 0:
+            raise TranscriptionError("media file is empty")
+        return b"".join(chunks), filename
+
+    async def transcribe(self, media_url: str, language: Optional[str] = None) -> TranscriptionResult:
+        """Download media and return its transcript."""
+        if not self.is_configured():
+            raise TranscriptionUnavailableError("transcription is not configured")
+
+        content, filename = await self.download_media(media_url)
+
+        headers = {"Authorization": f"Bearer {settings.openai_api_key}"}
+        if settings.openai_organization:
+            headers["OpenAI-Organization"] = settings.openai_organization
+        if settings.openai_project:
+            headers["OpenAI-Project"] = settings.openai_project
+
+        data = {"model": settings.transcription_model, "response_format": "verbose_json"}
+        if language:
+            data["language"] = language
+
+        endpoint = f"{settings.openai_base_url.rstrip('/')}/audio/transcriptions"
+        try:
+            async with httpx.AsyncClient(timeout=settings.transcription_timeout_sec) as client:
+                response = await client.post(
+                    endpoint,
+                    headers=headers,
+                    data=data,
+                    files={"file": (filename, content)},
+                )
+        except httpx.HTTPError as exc:
+            raise TranscriptionError(f"transcription request failed: {exc}") from exc
+
+        if response.status_code != 200:
+            logger.error(f"Transcription API error {response.status_code}: {response.text[:500]}")
+            raise TranscriptionError(f"transcription API returned {response.status_code}")
+
+        payload = response.json()
+        text = (payload.get("text") or "").strip()
+        if not text:
+            raise TranscriptionError("transcription returned no text")
+
+        return TranscriptionResult(
+            text=text,
+            language=payload.get("language") or language,
+            model=settings.transcription_model,
+        )
+
+
+# Create a singleton instance
+transcription_service = TranscriptionService()
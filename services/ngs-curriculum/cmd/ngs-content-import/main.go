@@ -0,0 +1,90 @@
+// Command ngs-content-import imports lessons from an external content source into lesson drafts.
+//
+//	go run ./cmd/ngs-content-import -source git -path ../content-repo -subdir lessons
+//	go run ./cmd/ngs-content-import -source notion -path ~/Downloads/Export.zip
+//	go run ./cmd/ngs-content-import -source gdocs -docs 1AbC...,1DeF...
+//
+// Drafts are published through POST /ngs/admin/lesson-drafts/:id/publish. Unlike the admin
+// API, local paths are not restricted to CONTENT_IMPORT_ROOT.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"noble-ngs-curriculum/internal/clients/connectors"
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/egress"
+	"noble-ngs-curriculum/internal/services"
+)
+
+func main() {
+	source := flag.String("source", "", "content source: git, notion or gdocs")
+	path := flag.String("path", "", "repository directory or Notion export (.zip or directory)")
+	archiveURL := flag.String("archive-url", "", "HTTPS .tar.gz repository archive (git source)")
+	subdir := flag.String("subdir", "", "only import files under this directory")
+	docs := flag.String("docs", "", "comma-separated Google Docs document IDs (gdocs source)")
+	fieldMap := flag.String("map", "", "extra front-matter mappings, e.g. \"Module=level_id,Position=lesson_order\"")
+	flag.Parse()
+
+	cfg := config.Load()
+
+	egressPolicy, err := egress.ParsePolicy(cfg.EgressProxyURL, cfg.EgressNoProxy, cfg.EgressAllowlist)
+	if err != nil {
+		log.Fatalf("Invalid egress configuration: %v", err)
+	}
+	egress.Configure(egressPolicy)
+
+	opts := connectors.Options{
+		Path:        *path,
+		ArchiveURL:  *archiveURL,
+		Subdir:      *subdir,
+		AccessToken: cfg.GoogleDocsAccessToken,
+	}
+	if *docs != "" {
+		opts.DocumentIDs = strings.Split(*docs, ",")
+	}
+
+	conn, err := connectors.New(*source, opts)
+	if err != nil {
+		log.Fatalf("Invalid content source: %v", err)
+	}
+
+	mappings := map[string]string{}
+	for _, pair := range strings.Split(*fieldMap, ",") {
+		if pair == "" {
+			continue
+		}
+		key, field, ok := strings.Cut(pair, "=")
+		if !ok {
+			log.Fatalf("Invalid mapping %q, expected key=field", pair)
+		}
+		mappings[strings.TrimSpace(key)] = strings.TrimSpace(field)
+	}
+
+	db, err := database.Connect(cfg.DatabaseURL, time.Duration(cfg.DBSlowQueryMs)*time.Millisecond)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	report, err := services.NewContentImportService(db, cfg).ImportContent(ctx, conn, mappings)
+	if err != nil {
+		log.Fatalf("Content import failed: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+}
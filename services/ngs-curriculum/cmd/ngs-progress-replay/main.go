@@ -0,0 +1,61 @@
+// Command ngs-progress-replay rebuilds user_progress from the XP ledger (xp_events and
+// xp_event_rollups) when PROGRESS_MODE=projection.
+//
+//	go run ./cmd/ngs-progress-replay -dry-run
+//	go run ./cmd/ngs-progress-replay -user 7f1c...
+//
+// Dry runs report what would change and work in either progress mode. A replay fails rather
+// than waits while XP reconciliation or another replay is running.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/google/uuid"
+)
+
+func main() {
+	user := flag.String("user", "", "only replay this user ID")
+	dryRun := flag.Bool("dry-run", false, "report changes without writing them")
+	flag.Parse()
+
+	var userID *uuid.UUID
+	if *user != "" {
+		id, err := uuid.Parse(*user)
+		if err != nil {
+			log.Fatalf("Invalid user ID %q: %v", *user, err)
+		}
+		userID = &id
+	}
+
+	cfg := config.Load()
+
+	db, err := database.Connect(cfg.DatabaseURL, time.Duration(cfg.DBSlowQueryMs)*time.Millisecond)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+	defer cancel()
+
+	replay, err := services.NewProgressProjection(db, cfg, services.SystemClock{}).Replay(ctx, userID, *dryRun)
+	if err != nil {
+		log.Fatalf("Progress replay failed: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(replay); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+// Package api holds what list endpoints share: cursor pagination and the date range filter.
+//
+// Lists ordered newest first sort by a time, then by ID, and a cursor names the last item of a
+// page by both, so the next page starts right after it however many rows are added meanwhile.
+// Lists ordered by position leave the cursor's time zero.
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Page sizes when a list request gives no limit, and the most it may ask for
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// ErrInvalidPage is returned for a malformed cursor, limit or filter
+var ErrInvalidPage = errors.New("invalid page")
+
+// Cursor names the last item of a page
+type Cursor struct {
+	At time.Time
+	ID uuid.UUID
+}
+
+// String encodes the cursor for the next_cursor field
+func (c Cursor) String() string {
+	var at string
+	if !c.At.IsZero() {
+		at = c.At.UTC().Format(time.RFC3339Nano)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(at + "|" + c.ID.String()))
+}
+
+// ParseCursor decodes a cursor given as ?cursor=
+func ParseCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: malformed cursor", ErrInvalidPage)
+	}
+	at, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return Cursor{}, fmt.Errorf("%w: malformed cursor", ErrInvalidPage)
+	}
+	var cursor Cursor
+	if cursor.ID, err = uuid.Parse(id); err != nil {
+		return Cursor{}, fmt.Errorf("%w: malformed cursor", ErrInvalidPage)
+	}
+	if at != "" {
+		if cursor.At, err = time.Parse(time.RFC3339Nano, at); err != nil {
+			return Cursor{}, fmt.Errorf("%w: malformed cursor", ErrInvalidPage)
+		}
+	}
+	return cursor, nil
+}
+
+// Page is one page of a list: at most Limit items after the After cursor, dated from From up
+// to but not including To. Nil bounds and a zero Limit leave the list unbounded.
+type Page struct {
+	Limit int
+	After *Cursor
+	From  *time.Time
+	To    *time.Time
+}
+
+// Includes reports whether an item of a newest-first list, dated at, falls in the page's date
+// range and after its cursor
+func (p Page) Includes(at time.Time, id uuid.UUID) bool {
+	if p.From != nil && at.Before(*p.From) {
+		return false
+	}
+	if p.To != nil && !at.Before(*p.To) {
+		return false
+	}
+	if p.After != nil {
+		if at.After(p.After.At) {
+			return false
+		}
+		if at.Equal(p.After.At) && bytes.Compare(id[:], p.After.ID[:]) <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Fetch is the page with one item more than its limit, for the rows a list reads so it can
+// tell whether there are more
+func (p Page) Fetch() Page {
+	if p.Limit > 0 {
+		p.Limit++
+	}
+	return p
+}
+
+// ParsePage reads ?cursor=&limit=&from=&to=. The limit defaults to DefaultLimit and is capped
+// at MaxLimit. from and to are RFC 3339 times or YYYY-MM-DD dates; a date to includes the day.
+func ParsePage(c *fiber.Ctx) (Page, error) {
+	page := Page{Limit: c.QueryInt("limit", DefaultLimit)}
+	if page.Limit < 1 {
+		page.Limit = DefaultLimit
+	}
+	if page.Limit > MaxLimit {
+		page.Limit = MaxLimit
+	}
+	if s := c.Query("cursor"); s != "" {
+		cursor, err := ParseCursor(s)
+		if err != nil {
+			return Page{}, err
+		}
+		page.After = &cursor
+	}
+	var err error
+	if page.From, err = parseBound(c.Query("from"), false); err != nil {
+		return Page{}, err
+	}
+	if page.To, err = parseBound(c.Query("to"), true); err != nil {
+		return Page{}, err
+	}
+	if page.From != nil && page.To != nil && !page.From.Before(*page.To) {
+		return Page{}, fmt.Errorf("%w: from must be before to", ErrInvalidPage)
+	}
+	return page, nil
+}
+
+// parseBound reads a from or to time; a date to moves to the start of the next day
+func parseBound(s string, end bool) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q is not an RFC 3339 time or YYYY-MM-DD date", ErrInvalidPage, s)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// QueryBool reads an optional true/false query value, nil when it is not given
+func QueryBool(c *fiber.Ctx, key string) (*bool, error) {
+	switch c.Query(key) {
+	case "":
+		return nil, nil
+	case "true":
+		v := true
+		return &v, nil
+	case "false":
+		v := false
+		return &v, nil
+	}
+	return nil, fmt.Errorf("%w: %s must be true or false", ErrInvalidPage, key)
+}
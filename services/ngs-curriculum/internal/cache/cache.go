@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Cache holds short-lived shared state: cached reads such as the leaderboard, and counters such
+// as rate limit windows. Values are bytes; Load stores JSON.
+type Cache interface {
+	Name() string
+	// Get returns the value under key, reporting false when it is missing or expired
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key for ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	// Incr adds one to the counter under key and returns the new count. A new counter
+	// expires after ttl, so counters form fixed windows.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+}
+
+var (
+	cacheLookups = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_cache_lookups_total",
+			Help: "Cache reads by key prefix and result (hit or miss).",
+		},
+		[]string{"prefix", "result"},
+	)
+
+	cacheErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_cache_errors_total",
+			Help: "Failed calls to the shared cache, which were served from memory instead.",
+		},
+		[]string{"op"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(cacheLookups, cacheErrors)
+}
+
+// New returns the cache for redisURL: Redis backed by memory while Redis is unreachable, or
+// memory alone when redisURL is empty
+func New(redisURL string) (Cache, error) {
+	if redisURL == "" {
+		return NewMemory(), nil
+	}
+	redis, err := NewRedis(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewFallback(redis, NewMemory()), nil
+}
+
+// Load returns the JSON value cached under key, or calls load and caches its result for ttl.
+// A nil cache always calls load, and cache errors are logged rather than returned, so the
+// caller only sees load's errors.
+func Load[T any](ctx context.Context, c Cache, key string, ttl time.Duration, load func() (T, error)) (T, error) {
+	if c == nil {
+		return load()
+	}
+
+	prefix := keyPrefix(key)
+	if data, ok, err := c.Get(ctx, key); err != nil {
+		log.Printf("Cache read %s failed: %v", key, err)
+	} else if ok {
+		var value T
+		if err := json.Unmarshal(data, &value); err == nil {
+			cacheLookups.WithLabelValues(prefix, "hit").Inc()
+			return value, nil
+		}
+	}
+	cacheLookups.WithLabelValues(prefix, "miss").Inc()
+
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value, fmt.Errorf("failed to encode %s for cache: %w", key, err)
+	}
+	if err := c.Set(ctx, key, data, ttl); err != nil {
+		log.Printf("Cache write %s failed: %v", key, err)
+	}
+	return value, nil
+}
+
+// keyPrefix returns the part of key before its first colon, which labels the metrics
+func keyPrefix(key string) string {
+	prefix, _, _ := strings.Cut(key, ":")
+	return prefix
+}
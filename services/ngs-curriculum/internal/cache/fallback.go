@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// FallbackRetryInterval is how long Fallback serves from memory after the shared cache fails
+// before trying it again
+var FallbackRetryInterval = 5 * time.Second
+
+// Fallback uses a shared cache while it works and a local one while it does not. Reads and
+// counters are then per replica, so cached data may differ between replicas and rate limits
+// apply per replica, but requests keep being served.
+type Fallback struct {
+	shared Cache
+	local  Cache
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+func NewFallback(shared, local Cache) *Fallback {
+	return &Fallback{shared: shared, local: local}
+}
+
+func (f *Fallback) Name() string {
+	return f.shared.Name()
+}
+
+// Degraded reports whether the shared cache is currently being skipped
+func (f *Fallback) Degraded() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return time.Now().Before(f.downUntil)
+}
+
+// failed records a shared cache error, logging only the first of an outage
+func (f *Fallback) failed(op string, err error) {
+	cacheErrors.WithLabelValues(op).Inc()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !time.Now().Before(f.downUntil) {
+		log.Printf("Cache %s unavailable, using memory for %s: %v", f.shared.Name(), FallbackRetryInterval, err)
+	}
+	f.downUntil = time.Now().Add(FallbackRetryInterval)
+}
+
+func (f *Fallback) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if !f.Degraded() {
+		value, ok, err := f.shared.Get(ctx, key)
+		if err == nil {
+			return value, ok, nil
+		}
+		f.failed("get", err)
+	}
+	return f.local.Get(ctx, key)
+}
+
+func (f *Fallback) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if !f.Degraded() {
+		err := f.shared.Set(ctx, key, value, ttl)
+		if err == nil {
+			return nil
+		}
+		f.failed("set", err)
+	}
+	return f.local.Set(ctx, key, value, ttl)
+}
+
+// Delete removes keys from both caches, so nothing stale survives an outage in either
+func (f *Fallback) Delete(ctx context.Context, keys ...string) error {
+	if !f.Degraded() {
+		if err := f.shared.Delete(ctx, keys...); err != nil {
+			f.failed("delete", err)
+		}
+	}
+	return f.local.Delete(ctx, keys...)
+}
+
+func (f *Fallback) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	if !f.Degraded() {
+		count, err := f.shared.Incr(ctx, key, ttl)
+		if err == nil {
+			return count, nil
+		}
+		f.failed("incr", err)
+	}
+	return f.local.Incr(ctx, key, ttl)
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memorySweepEvery is how many writes pass between sweeps of expired entries
+const memorySweepEvery = 1024
+
+// Memory is a Cache local to this replica
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	writes  int
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	value   []byte
+	count   int64
+	expires time.Time
+}
+
+func NewMemory() *Memory {
+	return NewMemoryWithClock(time.Now)
+}
+
+// NewMemoryWithClock creates a Memory cache that reads the time from now, e.g. a fake clock in tests
+func NewMemoryWithClock(now func() time.Time) *Memory {
+	return &Memory{
+		entries: make(map[string]memoryEntry),
+		now:     now,
+	}
+}
+
+func (m *Memory) Name() string {
+	return "memory"
+}
+
+// live returns the unexpired entry under key. The caller holds m.mu.
+func (m *Memory) live(key string) (memoryEntry, bool) {
+	entry, ok := m.entries[key]
+	if ok && !m.now().Before(entry.expires) {
+		delete(m.entries, key)
+		return memoryEntry{}, false
+	}
+	return entry, ok
+}
+
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.live(key)
+	if !ok || entry.value == nil {
+		return nil, false, nil
+	}
+	return append([]byte(nil), entry.value...), true, nil
+}
+
+func (m *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store(key, memoryEntry{value: append([]byte{}, value...), expires: m.now().Add(ttl)})
+	return nil
+}
+
+func (m *Memory) Delete(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+func (m *Memory) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.live(key)
+	if !ok {
+		entry = memoryEntry{expires: m.now().Add(ttl)}
+	}
+	entry.count++
+	m.store(key, entry)
+	return entry.count, nil
+}
+
+// store saves an entry, sweeping expired ones now and then so unread keys do not pile up. The
+// caller holds m.mu.
+func (m *Memory) store(key string, entry memoryEntry) {
+	m.entries[key] = entry
+	m.writes++
+	if m.writes%memorySweepEvery != 0 {
+		return
+	}
+	now := m.now()
+	for k, e := range m.entries {
+		if !now.Before(e.expires) {
+			delete(m.entries, k)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"noble-ngs-curriculum/internal/egress"
+)
+
+// ErrNotFound is returned by Get for a key that holds no object
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps objects too large to hold in Postgres rows. Keys are slash-separated paths such
+// as submissions/<user>/<submission>/code.txt.
+type Store interface {
+	Name() string
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewStore returns the store selected by name. An empty name or "none" disables offloading
+// and returns a nil store. For "fs" the URL is a directory; for "http" it is the base URL
+// objects are put under.
+func NewStore(name, url, token string) (Store, error) {
+	switch name {
+	case "", "none":
+		return nil, nil
+	case "fs":
+		if url == "" {
+			return nil, fmt.Errorf("blob store %q requires a directory", name)
+		}
+		return NewFileStore(url), nil
+	case "http":
+		if url == "" {
+			return nil, fmt.Errorf("blob store %q requires a URL", name)
+		}
+		return NewHTTPStore(url, token), nil
+	default:
+		return nil, fmt.Errorf("unknown blob store %q", name)
+	}
+}
+
+// validKey rejects keys that could escape the store's root
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "..") || strings.Contains(key, `\`) {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	return nil
+}
+
+// FileStore keeps objects as files under a directory, e.g. a mounted volume
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+func (s *FileStore) Name() string {
+	return "fs"
+}
+
+func (s *FileStore) path(key string) (string, error) {
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes to a temporary file and renames it, so readers never see a partial object
+func (s *FileStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+	return nil
+}
+
+func (s *FileStore) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob: %w", err)
+	}
+	return data, nil
+}
+
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	return nil
+}
+
+// HTTPStore keeps objects with PUT, GET and DELETE on <url>/<key>, as object storage buckets
+// and their gateways accept. The token is sent as a bearer token when set.
+type HTTPStore struct {
+	url        string
+	token      string
+	httpClient *http.Client
+}
+
+func NewHTTPStore(url, token string) *HTTPStore {
+	return &HTTPStore{
+		url:        strings.TrimSuffix(url, "/"),
+		token:      token,
+		httpClient: egress.NewClient(egress.DestinationBlobStore, 30*time.Second),
+	}
+}
+
+func (s *HTTPStore) Name() string {
+	return "http"
+}
+
+func (s *HTTPStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	resp, err := s.do(ctx, "PUT", key, data, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkStatus(resp, http.StatusOK, http.StatusCreated, http.StatusNoContent)
+}
+
+func (s *HTTPStore) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, "GET", key, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if err := checkStatus(resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return data, nil
+}
+
+func (s *HTTPStore) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, "DELETE", key, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return checkStatus(resp, http.StatusOK, http.StatusAccepted, http.StatusNoContent)
+}
+
+func (s *HTTPStore) do(ctx context.Context, method, key string, data []byte, contentType string) (*http.Response, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, s.url+"/"+key, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType != "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	if s.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	return resp, nil
+}
+
+// checkStatus returns an error for a status outside ok
+func checkStatus(resp *http.Response, ok ...int) error {
+	for _, status := range ok {
+		if resp.StatusCode == status {
+			return nil
+		}
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("blob store returned status %d: %s", resp.StatusCode, string(data))
+}
@@ -0,0 +1,83 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Document is one markdown document fetched from an external content source
+type Document struct {
+	Ref     string // Stable identifier within the source, e.g. a file path or document ID
+	Title   string // Fallback title when front matter has none
+	Content string // Markdown, optionally starting with a front-matter block
+}
+
+// Connector fetches markdown documents from an external content source
+type Connector interface {
+	Name() string
+	Fetch(ctx context.Context) ([]Document, error)
+}
+
+// Options selects and configures a connector
+type Options struct {
+	Path        string   // Local directory or Notion export (.zip or unzipped directory)
+	ArchiveURL  string   // HTTPS URL of a .tar.gz repository archive
+	Subdir      string   // Only read files under this directory of the repository or export
+	DocumentIDs []string // Google Docs document IDs
+	AccessToken string   // OAuth access token for Google Docs
+}
+
+// New returns the connector for a source: "git" (a checked-out repository or repository
+// archive of markdown files), "notion" (a Notion markdown export) or "gdocs" (Google Docs)
+func New(source string, opts Options) (Connector, error) {
+	switch source {
+	case "git":
+		if opts.ArchiveURL != "" {
+			if !strings.HasPrefix(opts.ArchiveURL, "https://") {
+				return nil, fmt.Errorf("archive_url must use https")
+			}
+			return NewArchiveConnector(opts.ArchiveURL, opts.Subdir), nil
+		}
+		if opts.Path == "" {
+			return nil, fmt.Errorf("git source requires a path or archive_url")
+		}
+		return NewDirConnector(opts.Path, opts.Subdir), nil
+	case "notion":
+		if opts.Path == "" {
+			return nil, fmt.Errorf("notion source requires the export path")
+		}
+		return NewNotionConnector(opts.Path, opts.Subdir), nil
+	case "gdocs":
+		if len(opts.DocumentIDs) == 0 {
+			return nil, fmt.Errorf("gdocs source requires document_ids")
+		}
+		if opts.AccessToken == "" {
+			return nil, fmt.Errorf("gdocs source requires an access token")
+		}
+		return NewGoogleDocsConnector(opts.DocumentIDs, opts.AccessToken), nil
+	default:
+		return nil, fmt.Errorf("unknown content source %q", source)
+	}
+}
+
+// isMarkdown reports whether a file name looks like a markdown document
+func isMarkdown(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(lower, ".md") || strings.HasSuffix(lower, ".markdown")
+}
+
+// underSubdir reports whether a slash-separated path is inside subdir ("" matches everything)
+func underSubdir(path, subdir string) bool {
+	subdir = strings.Trim(subdir, "/")
+	return subdir == "" || path == subdir || strings.HasPrefix(path, subdir+"/")
+}
+
+// titleFromPath turns "lessons/intro-to-ml.md" into "intro to ml"
+func titleFromPath(path string) string {
+	name := path[strings.LastIndex(path, "/")+1:]
+	if dot := strings.LastIndex(name, "."); dot > 0 {
+		name = name[:dot]
+	}
+	return strings.NewReplacer("-", " ", "_", " ").Replace(name)
+}
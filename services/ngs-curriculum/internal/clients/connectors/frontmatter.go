@@ -0,0 +1,48 @@
+package connectors
+
+import (
+	"strings"
+)
+
+// ParseFrontMatter splits a leading "---" delimited block of "key: value" lines from the
+// markdown body. Keys are lowercased with spaces turned into underscores, and surrounding
+// quotes are removed from values. Nested YAML is not supported; such lines are ignored.
+func ParseFrontMatter(content string) (map[string]string, string) {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	fields := map[string]string{}
+	if !strings.HasPrefix(content, "---\n") {
+		return fields, content
+	}
+
+	rest := content[len("---\n"):]
+	end := strings.Index(rest, "\n---")
+	if end < 0 {
+		return fields, content
+	}
+	block := rest[:end]
+	body := rest[end+len("\n---"):]
+	if nl := strings.Index(body, "\n"); nl >= 0 {
+		body = body[nl+1:]
+	} else {
+		body = ""
+	}
+
+	for _, line := range strings.Split(block, "\n") {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		colon := strings.Index(line, ":")
+		if colon <= 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:colon]))
+		key = strings.Join(strings.Fields(key), "_")
+		value := strings.TrimSpace(line[colon+1:])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		fields[key] = value
+	}
+
+	return fields, body
+}
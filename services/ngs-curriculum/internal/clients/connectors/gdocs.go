@@ -0,0 +1,84 @@
+package connectors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"noble-ngs-curriculum/internal/egress"
+)
+
+const googleDriveAPI = "https://www.googleapis.com/drive/v3"
+
+// GoogleDocsConnector exports Google Docs as markdown through the Drive API. Front matter can
+// be written at the top of the document between "---" lines.
+type GoogleDocsConnector struct {
+	documentIDs []string
+	accessToken string
+	baseURL     string
+	httpClient  *http.Client
+}
+
+func NewGoogleDocsConnector(documentIDs []string, accessToken string) *GoogleDocsConnector {
+	return &GoogleDocsConnector{
+		documentIDs: documentIDs,
+		accessToken: accessToken,
+		baseURL:     googleDriveAPI,
+		httpClient:  egress.NewClient(egress.DestinationContent, 60*time.Second),
+	}
+}
+
+func (c *GoogleDocsConnector) Name() string {
+	return "gdocs"
+}
+
+func (c *GoogleDocsConnector) Fetch(ctx context.Context) ([]Document, error) {
+	docs := make([]Document, 0, len(c.documentIDs))
+	for _, id := range c.documentIDs {
+		var meta struct {
+			Name string `json:"name"`
+		}
+		metaBody, err := c.get(ctx, "/files/"+url.PathEscape(id)+"?fields=name")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get document %s: %w", id, err)
+		}
+		if err := json.Unmarshal(metaBody, &meta); err != nil {
+			return nil, fmt.Errorf("failed to parse document %s metadata: %w", id, err)
+		}
+
+		content, err := c.get(ctx, "/files/"+url.PathEscape(id)+"/export?mimeType="+url.QueryEscape("text/markdown"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to export document %s: %w", id, err)
+		}
+
+		docs = append(docs, Document{Ref: id, Title: meta.Name, Content: string(content)})
+	}
+	return docs, nil
+}
+
+func (c *GoogleDocsConnector) get(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("drive API returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return body, nil
+}
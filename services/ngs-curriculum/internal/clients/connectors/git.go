@@ -0,0 +1,151 @@
+package connectors
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"noble-ngs-curriculum/internal/egress"
+)
+
+// maxDocumentBytes skips files too large to be a lesson
+const maxDocumentBytes = 2 << 20
+
+// maxArchiveBytes caps a downloaded repository archive
+const maxArchiveBytes = 100 << 20
+
+// DirConnector reads markdown files from a checked-out Git repository
+type DirConnector struct {
+	root   string
+	subdir string
+}
+
+func NewDirConnector(root, subdir string) *DirConnector {
+	return &DirConnector{root: root, subdir: subdir}
+}
+
+func (c *DirConnector) Name() string {
+	return "git"
+}
+
+func (c *DirConnector) Fetch(ctx context.Context) ([]Document, error) {
+	var docs []Document
+	err := filepath.WalkDir(c.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(c.root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !isMarkdown(rel) || !underSubdir(rel, c.subdir) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil || info.Size() > maxDocumentBytes {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", rel, err)
+		}
+		docs = append(docs, Document{Ref: rel, Title: titleFromPath(rel), Content: string(content)})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repository: %w", err)
+	}
+	return docs, nil
+}
+
+// ArchiveConnector reads markdown files from a .tar.gz repository archive, such as the
+// archive download of a GitHub or GitLab branch, so no git binary is needed
+type ArchiveConnector struct {
+	url        string
+	subdir     string
+	httpClient *http.Client
+}
+
+func NewArchiveConnector(url, subdir string) *ArchiveConnector {
+	return &ArchiveConnector{
+		url:        url,
+		subdir:     subdir,
+		httpClient: egress.NewClient(egress.DestinationContent, 2*time.Minute),
+	}
+}
+
+func (c *ArchiveConnector) Name() string {
+	return "git"
+}
+
+func (c *ArchiveConnector) Fetch(ctx context.Context) ([]Document, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download archive: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("archive download returned status %d", resp.StatusCode)
+	}
+
+	gz, err := gzip.NewReader(io.LimitReader(resp.Body, maxArchiveBytes))
+	if err != nil {
+		return nil, fmt.Errorf("archive is not gzip: %w", err)
+	}
+	defer gz.Close()
+
+	var docs []Document
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg || hdr.Size > maxDocumentBytes {
+			continue
+		}
+
+		// Archives wrap the repository in a single top-level directory
+		path := hdr.Name
+		if slash := strings.Index(path, "/"); slash >= 0 {
+			path = path[slash+1:]
+		}
+		if !isMarkdown(path) || !underSubdir(path, c.subdir) {
+			continue
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		docs = append(docs, Document{Ref: path, Title: titleFromPath(path), Content: string(content)})
+	}
+	return docs, nil
+}
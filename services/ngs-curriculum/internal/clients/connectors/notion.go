@@ -0,0 +1,129 @@
+package connectors
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// notionIDSuffix matches the " <32 hex>" ID Notion appends to exported file and folder names
+var notionIDSuffix = regexp.MustCompile(` [0-9a-f]{32}`)
+
+// notionProperty matches a "Property: value" line in a Notion page export
+var notionProperty = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9 _-]{0,40}):\s*(.*)$`)
+
+// NotionConnector reads a Notion "Markdown & CSV" export, either the .zip or its unzipped
+// directory. Page properties exported under the title become front matter.
+type NotionConnector struct {
+	path   string
+	subdir string
+}
+
+func NewNotionConnector(path, subdir string) *NotionConnector {
+	return &NotionConnector{path: path, subdir: subdir}
+}
+
+func (c *NotionConnector) Name() string {
+	return "notion"
+}
+
+func (c *NotionConnector) Fetch(ctx context.Context) ([]Document, error) {
+	var raw []Document
+	var err error
+	if strings.HasSuffix(strings.ToLower(c.path), ".zip") {
+		raw, err = c.readZip(ctx)
+	} else {
+		raw, err = NewDirConnector(c.path, "").Fetch(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]Document, 0, len(raw))
+	for _, doc := range raw {
+		// Refs drop Notion's IDs so they read like the page hierarchy
+		ref := notionIDSuffix.ReplaceAllString(doc.Ref, "")
+		if !underSubdir(ref, c.subdir) {
+			continue
+		}
+		title, content := NotionToFrontMatter(doc.Content)
+		if title == "" {
+			title = titleFromPath(ref)
+		}
+		docs = append(docs, Document{Ref: ref, Title: title, Content: content})
+	}
+	return docs, nil
+}
+
+func (c *NotionConnector) readZip(ctx context.Context) ([]Document, error) {
+	zr, err := zip.OpenReader(c.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Notion export: %w", err)
+	}
+	defer zr.Close()
+
+	var docs []Document
+	for _, f := range zr.File {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if f.FileInfo().IsDir() || !isMarkdown(f.Name) || f.UncompressedSize64 > maxDocumentBytes {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+		}
+		content, err := io.ReadAll(io.LimitReader(rc, maxDocumentBytes))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+		}
+		docs = append(docs, Document{Ref: f.Name, Content: string(content)})
+	}
+	return docs, nil
+}
+
+// NotionToFrontMatter converts a Notion page export, "# Title" followed by a block of
+// "Property: value" lines, into markdown with a front-matter block. Pages that already
+// start with front matter are returned unchanged. Returns the page title, if any.
+func NotionToFrontMatter(content string) (string, string) {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if strings.HasPrefix(content, "---\n") {
+		return "", content
+	}
+
+	lines := strings.Split(content, "\n")
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "# ") {
+		return "", content
+	}
+	title := strings.TrimSpace(strings.TrimPrefix(lines[0], "# "))
+
+	i := 1
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+	var properties []string
+	for ; i < len(lines); i++ {
+		m := notionProperty.FindStringSubmatch(lines[i])
+		if m == nil {
+			break
+		}
+		properties = append(properties, m[1]+": "+m[2])
+	}
+	if len(properties) == 0 {
+		return title, content
+	}
+
+	var b strings.Builder
+	b.WriteString("---\n")
+	for _, p := range properties {
+		b.WriteString(p + "\n")
+	}
+	b.WriteString("---\n# " + title + "\n")
+	b.WriteString(strings.Join(lines[i:], "\n"))
+	return title, b.String()
+}
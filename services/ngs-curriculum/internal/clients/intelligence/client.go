@@ -9,21 +9,29 @@ import (
 	"net/http"
 	"time"
 
+	"noble-ngs-curriculum/internal/egress"
+
 	"github.com/google/uuid"
 )
 
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
-	getToken   func() string
+	baseURL     string
+	httpClient  *http.Client
+	mediaClient *http.Client
+	// streamClient carries streamed answers, which last as long as the model keeps generating
+	streamClient *http.Client
+	getToken    func() string
+	// recordUsage is given the tokens each answered request used; see SetUsageRecorder
+	recordUsage func(Usage)
 }
 
 func NewClient(baseURL string, tokenProvider func() string) *Client {
 	return &Client{
 		baseURL: baseURL,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		httpClient: egress.NewClient(egress.DestinationIntelligence, 30*time.Second),
+		// Transcription downloads and processes whole videos
+		mediaClient: egress.NewClient(egress.DestinationIntelligence, 10*time.Minute),
+		streamClient: egress.NewClient(egress.DestinationIntelligence, 5*time.Minute),
 		getToken: tokenProvider,
 	}
 }
@@ -47,6 +55,7 @@ type GenerationConstraints struct {
 	TargetMinutes           int      `json:"target_minutes"`
 	Prereqs                 []string `json:"prereqs"`
 	RequireEthicsGuardrails bool     `json:"require_ethics_guardrails"`
+	IncludeAccessibility    bool     `json:"include_accessibility"`
 }
 
 type StructuredLesson struct {
@@ -56,6 +65,7 @@ type StructuredLesson struct {
 	Assessment      Assessment           `json:"assessment"`
 	Summary         string               `json:"summary"`
 	Artifacts       LessonArtifacts      `json:"artifacts"`
+	Accessibility   *AccessibilityInfo   `json:"accessibility,omitempty"`
 }
 
 type LessonMetadata struct {
@@ -106,6 +116,17 @@ type LessonArtifacts struct {
 	Glossary      []GlossaryTerm           `json:"glossary"`
 }
 
+type AccessibilityInfo struct {
+	PlainLanguageSummary string          `json:"plain_language_summary"`
+	AltText              []VisualAltText `json:"alt_text"`
+	ReadingLevel         *float64        `json:"reading_level,omitempty"`
+}
+
+type VisualAltText struct {
+	Visual  string `json:"visual"`
+	AltText string `json:"alt_text"`
+}
+
 type GlossaryTerm struct {
 	Term       string `json:"term"`
 	Definition string `json:"definition"`
@@ -120,10 +141,43 @@ type GenerateLessonResponse struct {
 	Version          int              `json:"version"`
 }
 
+type TranscribeMediaRequest struct {
+	MediaURL    string  `json:"media_url"`
+	LessonTitle string  `json:"lesson_title"`
+	Language    *string `json:"language,omitempty"`
+}
+
+type TranscribeMediaResponse struct {
+	Transcript string  `json:"transcript"`
+	Summary    string  `json:"summary"`
+	Language   *string `json:"language"`
+	Model      string  `json:"model"`
+	Provider   string  `json:"provider"`
+	TokensUsed int     `json:"tokens_used"`
+	LatencyMs  int     `json:"latency_ms"`
+}
+
 type EducatorChatRequest struct {
 	Message   string     `json:"message"`
 	LessonID  uuid.UUID  `json:"lesson_id"`
 	SessionID *uuid.UUID `json:"session_id,omitempty"`
+	// Preferences are the learner's, as in LearnerProfile
+	Preferences map[string]interface{} `json:"preferences,omitempty"`
+	// WeakTopics are the learner's, as in LearnerProfile
+	WeakTopics []string `json:"weak_topics,omitempty"`
+	// LessonContext holds the lesson passages that best match the message, best first
+	LessonContext []LessonContextChunk `json:"lesson_context,omitempty"`
+	// LearnerMemory is what the tutor remembers about the learner from earlier sessions; it
+	// seeds a new session, so it is only sent without a SessionID
+	LearnerMemory string `json:"learner_memory,omitempty"`
+}
+
+// LessonContextChunk is a passage of the lesson or of its media transcripts and summaries
+type LessonContextChunk struct {
+	Source  string  `json:"source"`
+	Heading string  `json:"heading,omitempty"`
+	Content string  `json:"content"`
+	Rank    float64 `json:"rank"`
 }
 
 type EducatorChatResponse struct {
@@ -132,6 +186,7 @@ type EducatorChatResponse struct {
 	LessonID   uuid.UUID `json:"lesson_id"`
 	TokensUsed int       `json:"tokens_used"`
 	LatencyMs  int       `json:"latency_ms"`
+	Provider   string    `json:"provider"`
 }
 
 func (c *Client) GenerateLesson(ctx context.Context, req GenerateLessonRequest, userID, userEmail, userRole string) (*GenerateLessonResponse, error) {
@@ -176,6 +231,8 @@ func (c *Client) GenerateLesson(ctx context.Context, req GenerateLessonRequest,
 	if err := json.Unmarshal(respBody, &result); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
+	result.Provider = servedBy(result.Provider, resp)
+	c.reportUsage(ctx, resp, result.Provider, result.TokensUsed)
 	
 	return &result, nil
 }
@@ -222,6 +279,75 @@ func (c *Client) SendEducatorChatMessage(ctx context.Context, req EducatorChatRe
 	if err := json.Unmarshal(respBody, &result); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
+	result.Provider = servedBy(result.Provider, resp)
+	c.reportUsage(ctx, resp, result.Provider, result.TokensUsed)
+	
+	return &result, nil
+}
+
+// Ping checks that the intelligence service answers its health endpoint
+func (c *Client) Ping(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/health", c.baseURL), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("intelligence service returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (c *Client) TranscribeMedia(ctx context.Context, req TranscribeMediaRequest, userID, userEmail, userRole string) (*TranscribeMediaResponse, error) {
+	url := fmt.Sprintf("%s/educator/transcribe", c.baseURL)
+	
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Service-Token", c.getToken())
+	httpReq.Header.Set("X-User-Id", userID)
+	httpReq.Header.Set("X-User-Email", userEmail)
+	httpReq.Header.Set("X-User-Role", userRole)
+	
+	if correlationID := ctx.Value("correlation_id"); correlationID != nil {
+		httpReq.Header.Set("X-Correlation-ID", correlationID.(string))
+	}
+	
+	resp, err := c.mediaClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("intelligence service returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	
+	var result TranscribeMediaResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	result.Provider = servedBy(result.Provider, resp)
+	c.reportUsage(WithOperation(ctx, OperationTranscription), resp, result.Provider, result.TokensUsed)
 	
 	return &result, nil
 }
@@ -0,0 +1,11 @@
+package intelligence
+
+import "noble-ngs-curriculum/internal/faults"
+
+// SetFaultInjector has requests fail, time out or slow down by the injector's intelligence
+// rules, matched on the request path. Call it before the client is used.
+func (c *Client) SetFaultInjector(injector *faults.Injector) {
+	c.httpClient.Transport = injector.Transport(faults.TargetIntelligence, c.httpClient.Transport)
+	c.mediaClient.Transport = injector.Transport(faults.TargetIntelligence, c.mediaClient.Transport)
+	c.streamClient.Transport = injector.Transport(faults.TargetIntelligence, c.streamClient.Transport)
+}
@@ -0,0 +1,90 @@
+package intelligence
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ChatTurn is one exchange of a tutor chat session
+type ChatTurn struct {
+	Message  string `json:"message"`
+	Response string `json:"response"`
+}
+
+type SummarizeChatSessionRequest struct {
+	LessonTitle string `json:"lesson_title"`
+	LevelNumber int    `json:"level_number"`
+	// Turns are the exchanges not yet summarized, oldest first
+	Turns []ChatTurn `json:"turns"`
+	// PreviousSummary covers the session's earlier exchanges when it was resumed after being
+	// summarized; they are already part of LearnerMemory
+	PreviousSummary string `json:"previous_summary,omitempty"`
+	// LearnerMemory is the learner's memory before this session; empty for their first
+	LearnerMemory string `json:"learner_memory,omitempty"`
+	// MaxMemoryChars bounds the updated memory
+	MaxMemoryChars int `json:"max_memory_chars"`
+}
+
+type SummarizeChatSessionResponse struct {
+	// SessionSummary is what this session covered and where the learner got stuck
+	SessionSummary string `json:"session_summary"`
+	// LearnerMemory is the learner's memory with this session folded in
+	LearnerMemory string `json:"learner_memory"`
+	Model         string `json:"model"`
+	Provider      string `json:"provider"`
+	TokensUsed    int    `json:"tokens_used"`
+	LatencyMs     int    `json:"latency_ms"`
+}
+
+// SummarizeChatSession summarizes a finished tutor chat session and folds it into the learner's memory
+func (c *Client) SummarizeChatSession(ctx context.Context, req SummarizeChatSessionRequest, userID, userEmail, userRole string) (*SummarizeChatSessionResponse, error) {
+	url := fmt.Sprintf("%s/educator/chat/summarize", c.baseURL)
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Service-Token", c.getToken())
+	httpReq.Header.Set("X-User-Id", userID)
+	httpReq.Header.Set("X-User-Email", userEmail)
+	httpReq.Header.Set("X-User-Role", userRole)
+
+	if correlationID := ctx.Value("correlation_id"); correlationID != nil {
+		httpReq.Header.Set("X-Correlation-ID", correlationID.(string))
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("intelligence service returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var result SummarizeChatSessionResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	result.Provider = servedBy(result.Provider, resp)
+	c.reportUsage(ctx, resp, result.Provider, result.TokensUsed)
+
+	return &result, nil
+}
@@ -0,0 +1,197 @@
+package intelligence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Operations routed by a RoutingPolicy
+const (
+	// OperationLesson is full lesson generation
+	OperationLesson = "lesson"
+	// OperationRemediation is a short remediation lesson on a topic the learner keeps missing
+	OperationRemediation = "remediation"
+	// OperationChat is a tutor chat answer, whole or streamed
+	OperationChat = "chat"
+	// OperationSummary is a chat session summary for the learner's memory
+	OperationSummary = "summary"
+	// OperationEvaluation is a design submission graded against its rubric
+	OperationEvaluation = "evaluation"
+)
+
+// ProviderHeader names the LLM provider a request asks the intelligence service to use
+const ProviderHeader = "X-LLM-Provider"
+
+// operationPaths are the operations of requests not given one with WithOperation
+var operationPaths = map[string]string{
+	"/educator/generate":        OperationLesson,
+	"/educator/chat/message":    OperationChat,
+	"/educator/chat/stream":     OperationChat,
+	"/educator/chat/summarize":  OperationSummary,
+	"/educator/evaluate-design": OperationEvaluation,
+}
+
+var providerAttempts = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ngs_intelligence_provider_attempts_total",
+		Help: "Intelligence service requests by operation, requested LLM provider and outcome (ok, or failed and passed to the next provider).",
+	},
+	[]string{"operation", "provider", "outcome"},
+)
+
+func init() {
+	prometheus.MustRegister(providerAttempts)
+}
+
+type operationKey struct{}
+
+// WithOperation routes the intelligence service requests made with ctx as operation, for
+// callers whose requests share an endpoint with another operation
+func WithOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// RoutingPolicy gives, per operation, the LLM providers to ask the intelligence service for
+// in order. Operations without providers are left to the intelligence service's own order.
+type RoutingPolicy map[string][]string
+
+// ParseRoutingPolicy reads a policy such as "lesson=openai,gemini;chat=ollama,gemini". "none"
+// turns routing off.
+func ParseRoutingPolicy(spec string) (RoutingPolicy, error) {
+	policy := make(RoutingPolicy)
+	if strings.TrimSpace(spec) == "none" {
+		return policy, nil
+	}
+	for _, field := range strings.Split(spec, ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		operation, list, ok := strings.Cut(field, "=")
+		operation = strings.TrimSpace(operation)
+		if !ok || operation == "" {
+			return nil, fmt.Errorf("%q is not operation=provider,provider,...", field)
+		}
+		if !validOperation(operation) {
+			return nil, fmt.Errorf("unknown operation %q", operation)
+		}
+		if _, dup := policy[operation]; dup {
+			return nil, fmt.Errorf("providers for %s listed twice", operation)
+		}
+		seen := make(map[string]bool)
+		var providers []string
+		for _, provider := range strings.Split(list, ",") {
+			provider = strings.TrimSpace(provider)
+			if provider == "" {
+				return nil, fmt.Errorf("empty provider for %s", operation)
+			}
+			if seen[provider] {
+				return nil, fmt.Errorf("provider %s listed twice for %s", provider, operation)
+			}
+			seen[provider] = true
+			providers = append(providers, provider)
+		}
+		policy[operation] = providers
+	}
+	return policy, nil
+}
+
+func validOperation(operation string) bool {
+	switch operation {
+	case OperationLesson, OperationRemediation, OperationChat, OperationSummary, OperationEvaluation:
+		return true
+	}
+	return false
+}
+
+// String lists the policy's operations and providers in a stable order, for logs
+func (p RoutingPolicy) String() string {
+	var fields []string
+	for _, operation := range []string{OperationLesson, OperationRemediation, OperationChat, OperationSummary, OperationEvaluation} {
+		if providers := p[operation]; len(providers) > 0 {
+			fields = append(fields, operation+"="+strings.Join(providers, ","))
+		}
+	}
+	if len(fields) == 0 {
+		return "none"
+	}
+	return strings.Join(fields, "; ")
+}
+
+// SetRoutingPolicy has each routed request ask for the operation's providers in turn, moving
+// to the next when one fails to connect, answers 429 or a 5xx, or fails by an injected fault.
+// A streamed answer fails over only until it starts. Call it after SetFaultInjector.
+func (c *Client) SetRoutingPolicy(policy RoutingPolicy) {
+	c.httpClient.Transport = &routingTransport{policy: policy, base: c.httpClient.Transport}
+	c.streamClient.Transport = &routingTransport{policy: policy, base: c.streamClient.Transport}
+}
+
+type routingTransport struct {
+	policy RoutingPolicy
+	base   http.RoundTripper
+}
+
+func (t *routingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	operation, _ := req.Context().Value(operationKey{}).(string)
+	if operation == "" {
+		operation = operationPaths[req.URL.Path]
+	}
+	providers := t.policy[operation]
+	if len(providers) == 0 {
+		return base.RoundTrip(req)
+	}
+
+	for i, provider := range providers {
+		attempt := req.Clone(req.Context())
+		if i > 0 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attempt.Body = body
+		}
+		attempt.Header.Set(ProviderHeader, provider)
+
+		resp, err := base.RoundTrip(attempt)
+		if err == nil && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			providerAttempts.WithLabelValues(operation, provider, "ok").Inc()
+			// The provider that served the request, for services that do not report it
+			if resp.Header.Get(ProviderHeader) == "" {
+				resp.Header.Set(ProviderHeader, provider)
+			}
+			return resp, nil
+		}
+		providerAttempts.WithLabelValues(operation, provider, "failed").Inc()
+
+		// The last provider's failure, or one that cannot be retried, is the request's
+		last := i == len(providers)-1
+		if last || req.Context().Err() != nil || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+		if err == nil {
+			resp.Body.Close()
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+		log.Printf("Intelligence %s request failed with provider %s, trying %s: %v", operation, provider, providers[i+1], err)
+	}
+	return nil, errors.New("no LLM provider to route to")
+}
+
+// servedBy is the provider the intelligence service reported in a response, or else the one
+// a routed request was served by
+func servedBy(reported string, resp *http.Response) string {
+	if reported != "" {
+		return reported
+	}
+	return resp.Header.Get(ProviderHeader)
+}
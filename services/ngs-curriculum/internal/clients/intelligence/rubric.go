@@ -0,0 +1,95 @@
+package intelligence
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+type RubricCriterion struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MaxPoints   int    `json:"max_points"`
+}
+
+// DesignAsset is an uploaded diagram or document, sent inline as base64 (encoding/json's []byte form)
+type DesignAsset struct {
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+type EvaluateDesignRequest struct {
+	ChallengeTitle       string            `json:"challenge_title"`
+	ChallengeDescription string            `json:"challenge_description"`
+	Rubric               []RubricCriterion `json:"rubric"`
+	Writeup              string            `json:"writeup"`
+	Assets               []DesignAsset     `json:"assets"`
+}
+
+type CriterionScore struct {
+	Criterion string `json:"criterion"`
+	Points    int    `json:"points"`
+	Comment   string `json:"comment"`
+}
+
+type EvaluateDesignResponse struct {
+	Scores     []CriterionScore `json:"scores"`
+	Comments   string           `json:"comments"`
+	Model      string           `json:"model"`
+	Provider   string           `json:"provider"`
+	TokensUsed int              `json:"tokens_used"`
+	LatencyMs  int              `json:"latency_ms"`
+}
+
+// EvaluateDesign scores a design challenge write-up and its assets against a rubric
+func (c *Client) EvaluateDesign(ctx context.Context, req EvaluateDesignRequest, userID, userEmail, userRole string) (*EvaluateDesignResponse, error) {
+	url := fmt.Sprintf("%s/educator/evaluate-design", c.baseURL)
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Service-Token", c.getToken())
+	httpReq.Header.Set("X-User-Id", userID)
+	httpReq.Header.Set("X-User-Email", userEmail)
+	httpReq.Header.Set("X-User-Role", userRole)
+
+	if correlationID := ctx.Value("correlation_id"); correlationID != nil {
+		httpReq.Header.Set("X-Correlation-ID", correlationID.(string))
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("intelligence service returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var result EvaluateDesignResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	result.Provider = servedBy(result.Provider, resp)
+	c.reportUsage(ctx, resp, result.Provider, result.TokensUsed)
+
+	return &result, nil
+}
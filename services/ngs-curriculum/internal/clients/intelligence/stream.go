@@ -0,0 +1,115 @@
+package intelligence
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// maxStreamEventBytes bounds one server-sent event line of a streamed answer
+const maxStreamEventBytes = 1 << 20
+
+// educatorChatEvent is one server-sent event of a streamed tutor chat answer
+type educatorChatEvent struct {
+	Content    string    `json:"content"`
+	Done       bool      `json:"done"`
+	SessionID  uuid.UUID `json:"session_id"`
+	TokensUsed int       `json:"tokens_used"`
+	LatencyMs  int       `json:"latency_ms"`
+	Provider   string    `json:"provider"`
+	Error      string    `json:"error"`
+}
+
+// StreamEducatorChatMessage sends a tutor chat message and passes each piece of the answer to
+// onChunk as the model writes it. It returns the whole answer once the stream is done. When
+// onChunk fails, e.g. because the learner has gone, or ctx is cancelled, the request is
+// abandoned and the connection closed, so the intelligence service stops generating; the
+// error is returned.
+func (c *Client) StreamEducatorChatMessage(ctx context.Context, req EducatorChatRequest, userID, userEmail, userRole string, onChunk func(content string) error) (*EducatorChatResponse, error) {
+	url := fmt.Sprintf("%s/educator/chat/stream", c.baseURL)
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	httpReq.Header.Set("X-Service-Token", c.getToken())
+	httpReq.Header.Set("X-User-Id", userID)
+	httpReq.Header.Set("X-User-Email", userEmail)
+	httpReq.Header.Set("X-User-Role", userRole)
+
+	if correlationID := ctx.Value("correlation_id"); correlationID != nil {
+		httpReq.Header.Set("X-Correlation-ID", correlationID.(string))
+	}
+
+	resp, err := c.streamClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxStreamEventBytes))
+		return nil, fmt.Errorf("intelligence service returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var answer strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxStreamEventBytes)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			// Blank separators, comments and fields other than data
+			continue
+		}
+		var event educatorChatEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return nil, fmt.Errorf("failed to parse stream event: %w", err)
+		}
+		if event.Error != "" {
+			return nil, fmt.Errorf("intelligence service stream failed: %s", event.Error)
+		}
+		if event.Content != "" {
+			answer.WriteString(event.Content)
+			if err := onChunk(event.Content); err != nil {
+				return nil, err
+			}
+		}
+		if event.Done {
+			result := &EducatorChatResponse{
+				Response:   answer.String(),
+				SessionID:  event.SessionID,
+				LessonID:   req.LessonID,
+				TokensUsed: event.TokensUsed,
+				LatencyMs:  event.LatencyMs,
+				Provider:   servedBy(event.Provider, resp),
+			}
+			c.reportUsage(ctx, resp, result.Provider, result.TokensUsed)
+			return result, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+	return nil, errors.New("intelligence service stream ended before the answer was done")
+}
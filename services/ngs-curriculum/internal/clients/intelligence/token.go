@@ -0,0 +1,28 @@
+package intelligence
+
+import (
+	"log"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// serviceTokenTTL is how long each signed service token stays valid
+const serviceTokenTTL = 24 * time.Hour
+
+// ServiceTokenProvider returns a token provider for NewClient that signs a fresh HS256 service JWT
+// with secret on each call. It returns "" when signing fails, so the request goes out unauthenticated.
+func ServiceTokenProvider(secret string) func() string {
+	return func() string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"service": "ngs-curriculum",
+			"exp":     time.Now().Add(serviceTokenTTL).Unix(),
+		})
+		tokenString, err := token.SignedString([]byte(secret))
+		if err != nil {
+			log.Printf("Failed to generate service token: %v", err)
+			return ""
+		}
+		return tokenString
+	}
+}
@@ -0,0 +1,44 @@
+package intelligence
+
+import (
+	"context"
+	"net/http"
+)
+
+// OperationTranscription is media transcription. It is reported in Usage but not routed.
+const OperationTranscription = "transcription"
+
+// Usage is the tokens one answered intelligence service request used
+type Usage struct {
+	// OrgID is the organization the request was made for, empty when made for none
+	OrgID     string
+	Operation string
+	Provider  string
+	Tokens    int
+}
+
+type orgKey struct{}
+
+// WithOrg attributes the intelligence service requests made with ctx to the organization
+func WithOrg(ctx context.Context, orgID string) context.Context {
+	return context.WithValue(ctx, orgKey{}, orgID)
+}
+
+// SetUsageRecorder has record called with the usage of each answered request. It is called on
+// the request's goroutine, so it must be quick.
+func (c *Client) SetUsageRecorder(record func(Usage)) {
+	c.recordUsage = record
+}
+
+// reportUsage passes the tokens a request used to the usage recorder, if there is one
+func (c *Client) reportUsage(ctx context.Context, resp *http.Response, provider string, tokens int) {
+	if c.recordUsage == nil || tokens <= 0 {
+		return
+	}
+	orgID, _ := ctx.Value(orgKey{}).(string)
+	operation, _ := ctx.Value(operationKey{}).(string)
+	if operation == "" && resp.Request != nil {
+		operation = operationPaths[resp.Request.URL.Path]
+	}
+	c.recordUsage(Usage{OrgID: orgID, Operation: operation, Provider: provider, Tokens: tokens})
+}
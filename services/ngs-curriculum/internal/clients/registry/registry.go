@@ -0,0 +1,170 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"noble-ngs-curriculum/internal/egress"
+)
+
+// Instance is one replica of a service as announced to the registry
+type Instance struct {
+	ID      string
+	Name    string
+	Address string
+	Port    int
+	// HealthPath is polled by the registry on Address:Port, e.g. /health
+	HealthPath string
+	Tags       []string
+}
+
+// Registry announces service replicas so the gateway can discover and route to them
+type Registry interface {
+	Name() string
+	// Register announces the instance. It is marked unhealthy unless Heartbeat is called
+	// at least once per ttl.
+	Register(ctx context.Context, instance Instance, ttl time.Duration) error
+	Heartbeat(ctx context.Context, instanceID string) error
+	Deregister(ctx context.Context, instanceID string) error
+}
+
+// NewRegistry returns the registry selected by name. An empty name or "none" disables
+// registration and returns a nil registry.
+func NewRegistry(name, url, token string) (Registry, error) {
+	switch name {
+	case "", "none":
+		return nil, nil
+	case "consul":
+		if url == "" {
+			return nil, fmt.Errorf("service registry %q requires a URL", name)
+		}
+		return NewConsulRegistry(url, token), nil
+	default:
+		return nil, fmt.Errorf("unknown service registry %q", name)
+	}
+}
+
+// ConsulRegistry registers with a Consul agent's HTTP API. Each instance gets a TTL check fed by
+// Heartbeat and an HTTP check on its health endpoint; Consul removes instances whose checks
+// stay critical.
+type ConsulRegistry struct {
+	url        string
+	token      string
+	httpClient *http.Client
+}
+
+func NewConsulRegistry(url, token string) *ConsulRegistry {
+	return &ConsulRegistry{
+		url:        url,
+		token:      token,
+		httpClient: egress.NewClient(egress.DestinationRegistry, 10*time.Second),
+	}
+}
+
+func (r *ConsulRegistry) Name() string {
+	return "consul"
+}
+
+// ttlCheckID is the ID of the check Heartbeat passes
+func ttlCheckID(instanceID string) string {
+	return "service:" + instanceID + ":ttl"
+}
+
+type consulCheck struct {
+	CheckID                        string `json:"CheckID"`
+	Name                           string `json:"Name"`
+	TTL                            string `json:"TTL,omitempty"`
+	HTTP                           string `json:"HTTP,omitempty"`
+	Interval                       string `json:"Interval,omitempty"`
+	DeregisterCriticalServiceAfter string `json:"DeregisterCriticalServiceAfter"`
+}
+
+type consulRegistration struct {
+	ID      string            `json:"ID"`
+	Name    string            `json:"Name"`
+	Address string            `json:"Address"`
+	Port    int               `json:"Port"`
+	Tags    []string          `json:"Tags,omitempty"`
+	Meta    map[string]string `json:"Meta,omitempty"`
+	Checks  []consulCheck     `json:"Checks"`
+}
+
+func (r *ConsulRegistry) Register(ctx context.Context, instance Instance, ttl time.Duration) error {
+	healthURL := "http://" + instance.Address + ":" + strconv.Itoa(instance.Port) + instance.HealthPath
+	// Replicas that stop heartbeating for long are assumed gone rather than slow
+	deregisterAfter := (10 * ttl).String()
+
+	return r.put(ctx, "/v1/agent/service/register", consulRegistration{
+		ID:      instance.ID,
+		Name:    instance.Name,
+		Address: instance.Address,
+		Port:    instance.Port,
+		Tags:    instance.Tags,
+		Meta:    map[string]string{"health": healthURL},
+		Checks: []consulCheck{
+			{
+				CheckID:                        ttlCheckID(instance.ID),
+				Name:                           "Heartbeat",
+				TTL:                            ttl.String(),
+				DeregisterCriticalServiceAfter: deregisterAfter,
+			},
+			{
+				CheckID:                        "service:" + instance.ID + ":http",
+				Name:                           "Health endpoint",
+				HTTP:                           healthURL,
+				Interval:                       ttl.String(),
+				DeregisterCriticalServiceAfter: deregisterAfter,
+			},
+		},
+	})
+}
+
+func (r *ConsulRegistry) Heartbeat(ctx context.Context, instanceID string) error {
+	return r.put(ctx, "/v1/agent/check/pass/"+url.PathEscape(ttlCheckID(instanceID)), nil)
+}
+
+func (r *ConsulRegistry) Deregister(ctx context.Context, instanceID string) error {
+	return r.put(ctx, "/v1/agent/service/deregister/"+url.PathEscape(instanceID), nil)
+}
+
+// put sends a PUT to the agent with body as JSON, if any
+func (r *ConsulRegistry) put(ctx context.Context, path string, body interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "PUT", r.url+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if r.token != "" {
+		httpReq.Header.Set("X-Consul-Token", r.token)
+	}
+
+	resp, err := r.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("consul returned status %d: %s", resp.StatusCode, string(data))
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"noble-ngs-curriculum/internal/egress"
+)
+
+// Provider turns plain text into speech audio
+type Provider interface {
+	Name() string
+	Synthesize(ctx context.Context, text string, voice string) (*Audio, error)
+}
+
+// Audio is a synthesized audio clip
+type Audio struct {
+	Data        []byte
+	ContentType string
+}
+
+// NewProvider returns the provider selected by name. An empty name or "none"
+// disables audio generation and returns a nil provider.
+func NewProvider(name, url, apiKey string) (Provider, error) {
+	switch name {
+	case "", "none":
+		return nil, nil
+	case "http":
+		if url == "" {
+			return nil, fmt.Errorf("tts provider %q requires a URL", name)
+		}
+		return NewHTTPProvider(url, apiKey), nil
+	default:
+		return nil, fmt.Errorf("unknown tts provider %q", name)
+	}
+}
+
+// HTTPProvider calls a TTS endpoint that accepts {"text", "voice", "format"} and
+// responds with the audio bytes
+type HTTPProvider struct {
+	url        string
+	apiKey     string
+	httpClient *http.Client
+}
+
+func NewHTTPProvider(url, apiKey string) *HTTPProvider {
+	return &HTTPProvider{
+		url:        url,
+		apiKey:     apiKey,
+		httpClient: egress.NewClient(egress.DestinationTTS, 90*time.Second),
+	}
+}
+
+func (p *HTTPProvider) Name() string {
+	return "http"
+}
+
+func (p *HTTPProvider) Synthesize(ctx context.Context, text string, voice string) (*Audio, error) {
+	body, err := json.Marshal(map[string]string{
+		"text":   text,
+		"voice":  voice,
+		"format": "mp3",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "audio/mpeg")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tts provider returned status %d: %s", resp.StatusCode, string(data))
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "audio/mpeg"
+	}
+
+	return &Audio{Data: data, ContentType: contentType}, nil
+}
@@ -0,0 +1,166 @@
+// Package users resolves user IDs to how the users are shown to others, such as on the
+// leaderboard, from the user service.
+package users
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"noble-ngs-curriculum/internal/cache"
+	"noble-ngs-curriculum/internal/egress"
+
+	"github.com/google/uuid"
+)
+
+// maxBatch is the most IDs asked for in one request
+const maxBatch = 100
+
+// Profile is a user's public display name and avatar. Users the user service does not know have
+// an empty profile.
+type Profile struct {
+	ID          uuid.UUID `json:"id"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   string    `json:"avatar_url,omitempty"`
+}
+
+// Client looks up profiles in batches with POST {url}/users/batch, {"ids": [...]}, which answers
+// {"users": [profile, ...]}. Each profile is cached on its own for ttl, so a leaderboard only
+// asks for the users it has not seen lately.
+type Client struct {
+	baseURL       string
+	tokenProvider func() string
+	httpClient    *http.Client
+	cache         cache.Cache
+	ttl           time.Duration
+}
+
+// NewClient returns a client for the user service at baseURL. tokenProvider signs each request,
+// as for the intelligence service; a nil cache or zero ttl caches nothing.
+func NewClient(baseURL string, tokenProvider func() string, c cache.Cache, ttl time.Duration) *Client {
+	return &Client{
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		tokenProvider: tokenProvider,
+		httpClient:    egress.NewClient(egress.DestinationUsers, 5*time.Second),
+		cache:         c,
+		ttl:           ttl,
+	}
+}
+
+func profileKey(id uuid.UUID) string {
+	return "user_profile:" + id.String()
+}
+
+// Profiles returns the profile of each of ids, from the cache where it can
+func (c *Client) Profiles(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]Profile, error) {
+	profiles := make(map[uuid.UUID]Profile, len(ids))
+	var missing []uuid.UUID
+	for _, id := range ids {
+		if _, ok := profiles[id]; ok {
+			continue
+		}
+		if profile, ok := c.cached(ctx, id); ok {
+			profiles[id] = profile
+			continue
+		}
+		profiles[id] = Profile{ID: id}
+		missing = append(missing, id)
+	}
+
+	for start := 0; start < len(missing); start += maxBatch {
+		end := start + maxBatch
+		if end > len(missing) {
+			end = len(missing)
+		}
+		batch := missing[start:end]
+		found, err := c.fetch(ctx, batch)
+		if err != nil {
+			return profiles, err
+		}
+		for _, profile := range found {
+			if _, ok := profiles[profile.ID]; ok {
+				profiles[profile.ID] = profile
+			}
+		}
+		// Unknown users are cached too, so they are not asked for on every read
+		for _, id := range batch {
+			c.store(ctx, profiles[id])
+		}
+	}
+	return profiles, nil
+}
+
+func (c *Client) cached(ctx context.Context, id uuid.UUID) (Profile, bool) {
+	if c.cache == nil || c.ttl <= 0 {
+		return Profile{}, false
+	}
+	data, ok, err := c.cache.Get(ctx, profileKey(id))
+	if err != nil {
+		log.Printf("Cache read %s failed: %v", profileKey(id), err)
+		return Profile{}, false
+	}
+	if !ok {
+		return Profile{}, false
+	}
+	var profile Profile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return Profile{}, false
+	}
+	return profile, true
+}
+
+func (c *Client) store(ctx context.Context, profile Profile) {
+	if c.cache == nil || c.ttl <= 0 {
+		return
+	}
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return
+	}
+	if err := c.cache.Set(ctx, profileKey(profile.ID), data, c.ttl); err != nil {
+		log.Printf("Cache write %s failed: %v", profileKey(profile.ID), err)
+	}
+}
+
+func (c *Client) fetch(ctx context.Context, ids []uuid.UUID) ([]Profile, error) {
+	body, err := json.Marshal(map[string][]uuid.UUID{"ids": ids})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/users/batch", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.tokenProvider != nil {
+		if token := c.tokenProvider(); token != "" {
+			httpReq.Header.Set("X-Service-Token", token)
+		}
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("user service returned status %d: %s", resp.StatusCode, string(data))
+	}
+
+	var result struct {
+		Users []Profile `json:"users"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return result.Users, nil
+}
@@ -4,23 +4,36 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
-	_ "github.com/lib/pq"
+	"noble-ngs-curriculum/internal/faults"
+
+	"github.com/lib/pq"
 )
 
 type DB struct {
 	*sql.DB
+
+	// stmts caches prepared statements by query text
+	stmts sync.Map
+
+	connector *instrumentedConnector
 }
 
-// Connect establishes a connection to the PostgreSQL database
-func Connect(databaseURL string) (*DB, error) {
-	db, err := sql.Open("postgres", databaseURL)
+// Connect establishes a connection to the PostgreSQL database. Queries slower than
+// slowQueryThreshold are logged; zero disables the slow-query log.
+func Connect(databaseURL string, slowQueryThreshold time.Duration) (*DB, error) {
+	connector, err := pq.NewConnector(databaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	instrumented := &instrumentedConnector{connector: connector, slowThreshold: slowQueryThreshold}
+	db := sql.OpenDB(instrumented)
 
 	// Test the connection
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
@@ -30,10 +43,43 @@ func Connect(databaseURL string) (*DB, error) {
 
 	log.Println("✅ Connected to PostgreSQL database")
 
-	return &DB{db}, nil
+	return &DB{DB: db, connector: instrumented}, nil
+}
+
+// SetFaultInjector has queries fail or slow down by the injector's db rules, matched on the
+// calling function and the query text
+func (db *DB) SetFaultInjector(injector *faults.Injector) {
+	if db.connector != nil {
+		db.connector.faults.Store(injector)
+	}
+}
+
+// Stmt returns the prepared statement for query, preparing it on first use. database/sql
+// re-prepares it on each pooled connection as needed; use Tx.Stmt to run it in a transaction.
+// Only pass constant query text, since statements are kept until the DB is closed.
+func (db *DB) Stmt(query string) (*sql.Stmt, error) {
+	if stmt, ok := db.stmts.Load(query); ok {
+		return stmt.(*sql.Stmt), nil
+	}
+
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	if existing, loaded := db.stmts.LoadOrStore(query, stmt); loaded {
+		// Another goroutine prepared it first
+		stmt.Close()
+		return existing.(*sql.Stmt), nil
+	}
+	return stmt, nil
 }
 
-// Close closes the database connection
+// Close closes the prepared statements and the database connection
 func (db *DB) Close() error {
+	db.stmts.Range(func(query, stmt interface{}) bool {
+		stmt.(*sql.Stmt).Close()
+		db.stmts.Delete(query)
+		return true
+	})
 	return db.DB.Close()
 }
@@ -0,0 +1,341 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"log"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"noble-ngs-curriculum/internal/faults"
+
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Every query issued through a DB, inside or outside a transaction, is timed by wrapping the
+// driver connection. The caller label is the first function outside this package and
+// database/sql, e.g. "LessonService.GetLessonsByLevel".
+
+const (
+	opQuery = "query"
+	opExec  = "exec"
+
+	modulePrefix    = "noble-ngs-curriculum/"
+	databasePackage = modulePrefix + "internal/database."
+
+	// maxLoggedQueryLength keeps slow-query log lines readable
+	maxLoggedQueryLength = 300
+)
+
+var (
+	queryDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ngs_db_query_duration_seconds",
+			Help:    "Database query latency by caller, including reading the result rows.",
+			Buckets: []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		},
+		[]string{"caller", "op"},
+	)
+
+	queryRows = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ngs_db_query_rows",
+			Help:    "Rows returned by queries or affected by statements, by caller.",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 9),
+		},
+		[]string{"caller", "op"},
+	)
+
+	queryErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_db_query_errors_total",
+			Help: "Failed database queries by caller.",
+		},
+		[]string{"caller", "op"},
+	)
+
+	slowQueries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_db_slow_queries_total",
+			Help: "Queries slower than the slow-query threshold, by caller.",
+		},
+		[]string{"caller"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(queryDuration, queryRows, queryErrors, slowQueries)
+}
+
+// CallerLabel shortens a fully qualified function name to a metric label,
+// e.g. "noble-ngs-curriculum/internal/services.(*LessonService).GetLesson" to "LessonService.GetLesson"
+func CallerLabel(function string) string {
+	if i := strings.LastIndex(function, "/"); i >= 0 {
+		function = function[i+1:]
+	}
+	if i := strings.Index(function, "."); i >= 0 {
+		function = function[i+1:]
+	}
+	function = strings.NewReplacer("(*", "", ")", "").Replace(function)
+	// Closures report as Method.func1; attribute them to the enclosing function
+	if i := strings.Index(function, ".func"); i >= 0 {
+		function = function[:i]
+	}
+	return function
+}
+
+// queryCaller finds the service-level function that issued the query
+func queryCaller() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if strings.HasPrefix(frame.Function, modulePrefix) && !strings.HasPrefix(frame.Function, databasePackage) {
+			return CallerLabel(frame.Function)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// compactQuery collapses whitespace and truncates a query for logging
+func compactQuery(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) > maxLoggedQueryLength {
+		query = query[:maxLoggedQueryLength] + "..."
+	}
+	return query
+}
+
+// instrumentedConnector opens pq connections wrapped for instrumentation
+type instrumentedConnector struct {
+	connector     *pq.Connector
+	slowThreshold time.Duration
+	faults        atomic.Pointer[faults.Injector]
+}
+
+func (c *instrumentedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{Conn: conn, slowThreshold: c.slowThreshold, faults: &c.faults}, nil
+}
+
+func (c *instrumentedConnector) Driver() driver.Driver {
+	return c.connector.Driver()
+}
+
+type instrumentedConn struct {
+	driver.Conn
+	slowThreshold time.Duration
+	faults        *atomic.Pointer[faults.Injector]
+}
+
+// inject runs any injected fault for the query before it is sent
+func (c *instrumentedConn) inject(ctx context.Context, caller, query string) error {
+	return c.faults.Load().Inject(ctx, faults.TargetDB, caller, query)
+}
+
+// observe records one finished query. Query parameters are never logged.
+func (c *instrumentedConn) observe(caller, op, query string, start time.Time, rows int64, err error) {
+	elapsed := time.Since(start)
+	queryDuration.WithLabelValues(caller, op).Observe(elapsed.Seconds())
+	if err != nil && err != io.EOF {
+		queryErrors.WithLabelValues(caller, op).Inc()
+		return
+	}
+	queryRows.WithLabelValues(caller, op).Observe(float64(rows))
+
+	if c.slowThreshold > 0 && elapsed >= c.slowThreshold {
+		slowQueries.WithLabelValues(caller).Inc()
+		log.Printf("Slow query (%s, %d rows) in %s: %s", elapsed.Round(time.Millisecond), rows, caller, compactQuery(query))
+	}
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	caller, start := queryCaller(), time.Now()
+	if err := c.inject(ctx, caller, query); err != nil {
+		c.observe(caller, opQuery, query, start, 0, err)
+		return nil, err
+	}
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		if err != driver.ErrSkip {
+			c.observe(caller, opQuery, query, start, 0, err)
+		}
+		return nil, err
+	}
+	return &instrumentedRows{Rows: rows, conn: c, caller: caller, query: query, start: start}, nil
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	caller, start := queryCaller(), time.Now()
+	if err := c.inject(ctx, caller, query); err != nil {
+		c.observe(caller, opExec, query, start, 0, err)
+		return nil, err
+	}
+	result, err := execer.ExecContext(ctx, query, args)
+	if err == driver.ErrSkip {
+		return nil, err
+	}
+	var affected int64
+	if err == nil {
+		affected, _ = result.RowsAffected()
+	}
+	c.observe(caller, opExec, query, start, affected, err)
+	return result, err
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedStmt{Stmt: stmt, conn: c, query: query}, nil
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *instrumentedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *instrumentedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// instrumentedStmt records each execution of a prepared statement like a plain query
+type instrumentedStmt struct {
+	driver.Stmt
+	conn  *instrumentedConn
+	query string
+}
+
+// namedValues drops argument names for drivers without context-aware statements
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
+
+func (s *instrumentedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	caller, start := queryCaller(), time.Now()
+	if err := s.conn.inject(ctx, caller, s.query); err != nil {
+		s.conn.observe(caller, opQuery, s.query, start, 0, err)
+		return nil, err
+	}
+	var rows driver.Rows
+	var err error
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(namedValues(args))
+	}
+	if err != nil {
+		s.conn.observe(caller, opQuery, s.query, start, 0, err)
+		return nil, err
+	}
+	return &instrumentedRows{Rows: rows, conn: s.conn, caller: caller, query: s.query, start: start}, nil
+}
+
+func (s *instrumentedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	caller, start := queryCaller(), time.Now()
+	if err := s.conn.inject(ctx, caller, s.query); err != nil {
+		s.conn.observe(caller, opExec, s.query, start, 0, err)
+		return nil, err
+	}
+	var result driver.Result
+	var err error
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		result, err = s.Stmt.Exec(namedValues(args))
+	}
+	var affected int64
+	if err == nil {
+		affected, _ = result.RowsAffected()
+	}
+	s.conn.observe(caller, opExec, s.query, start, affected, err)
+	return result, err
+}
+
+// instrumentedRows counts rows as they are read and records the query when the rows are closed
+type instrumentedRows struct {
+	driver.Rows
+	conn   *instrumentedConn
+	caller string
+	query  string
+	start  time.Time
+	count  int64
+	err    error
+	closed bool
+}
+
+func (r *instrumentedRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err == nil {
+		r.count++
+	} else if err != io.EOF {
+		r.err = err
+	}
+	return err
+}
+
+func (r *instrumentedRows) Close() error {
+	err := r.Rows.Close()
+	if !r.closed {
+		r.closed = true
+		r.conn.observe(r.caller, opQuery, r.query, r.start, r.count, r.err)
+	}
+	return err
+}
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Replicas coordinate through Postgres advisory locks, keyed by hashtext of a lock name such as
+// "ngs_retention:xp_metadata". The part before the colon labels the lock metrics.
+//
+// TryXactLock suits work that fits in one transaction. SessionLock covers longer work, such as
+// startup seeding, on a dedicated connection that it checks every LockRenewInterval; the lock
+// dies with that connection, so a failed check cancels the lock's context.
+
+// LockRenewInterval is how often a held SessionLock checks its connection
+var LockRenewInterval = 15 * time.Second
+
+var (
+	lockAttempts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_lock_attempts_total",
+			Help: "Advisory lock attempts by lock and result (acquired, busy or error).",
+		},
+		[]string{"lock", "result"},
+	)
+
+	lockHeld = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ngs_lock_held_seconds",
+			Help:    "How long session advisory locks were held.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 10),
+		},
+		[]string{"lock"},
+	)
+
+	locksLost = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_locks_lost_total",
+			Help: "Session advisory locks lost because their connection failed.",
+		},
+		[]string{"lock"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(lockAttempts, lockHeld, locksLost)
+}
+
+// lockLabel returns the metric label for a lock name
+func lockLabel(name string) string {
+	label, _, _ := strings.Cut(name, ":")
+	return label
+}
+
+// recordLockAttempt counts an attempt and passes its result through
+func recordLockAttempt(name string, locked bool, err error) (bool, error) {
+	result := "acquired"
+	if err != nil {
+		result = "error"
+	} else if !locked {
+		result = "busy"
+	}
+	lockAttempts.WithLabelValues(lockLabel(name), result).Inc()
+	return locked, err
+}
+
+// TryXactLock takes the named advisory lock for the rest of tx, reporting false when another
+// replica holds it. Commit or rollback releases it.
+func TryXactLock(tx *sql.Tx, name string) (bool, error) {
+	var locked bool
+	err := tx.QueryRow(`SELECT pg_try_advisory_xact_lock(hashtext($1))`, name).Scan(&locked)
+	if err != nil {
+		err = fmt.Errorf("failed to take lock %s: %w", name, err)
+	}
+	return recordLockAttempt(name, locked, err)
+}
+
+// XactLock waits for the named advisory lock and holds it for the rest of tx, serializing work
+// on one key across replicas. Commit or rollback releases it.
+func XactLock(tx *sql.Tx, name string) error {
+	_, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, name)
+	if err != nil {
+		err = fmt.Errorf("failed to take lock %s: %w", name, err)
+	}
+	_, err = recordLockAttempt(name, err == nil, err)
+	return err
+}
+
+// SessionLock is a held advisory lock. Release it when done.
+type SessionLock struct {
+	name     string
+	conn     *sql.Conn
+	ctx      context.Context
+	cancel   context.CancelFunc
+	acquired time.Time
+	stopped  chan struct{}
+	once     sync.Once
+}
+
+// AcquireLock waits until it holds the named lock or ctx ends
+func (db *DB) AcquireLock(ctx context.Context, name string) (*SessionLock, error) {
+	return db.sessionLock(ctx, name, `SELECT true FROM pg_advisory_lock(hashtext($1))`)
+}
+
+// TryLock takes the named lock if it is free, returning nil when another replica holds it
+func (db *DB) TryLock(ctx context.Context, name string) (*SessionLock, error) {
+	return db.sessionLock(ctx, name, `SELECT pg_try_advisory_lock(hashtext($1))`)
+}
+
+func (db *DB) sessionLock(ctx context.Context, name, query string) (*SessionLock, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		_, err = recordLockAttempt(name, false, fmt.Errorf("failed to get connection for lock %s: %w", name, err))
+		return nil, err
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, query, name).Scan(&locked); err != nil {
+		conn.Close()
+		_, err = recordLockAttempt(name, false, fmt.Errorf("failed to take lock %s: %w", name, err))
+		return nil, err
+	}
+	recordLockAttempt(name, locked, nil)
+	if !locked {
+		conn.Close()
+		return nil, nil
+	}
+
+	lockCtx, cancel := context.WithCancel(context.Background())
+	l := &SessionLock{
+		name:     name,
+		conn:     conn,
+		ctx:      lockCtx,
+		cancel:   cancel,
+		acquired: time.Now(),
+		stopped:  make(chan struct{}),
+	}
+	go l.renew()
+	return l, nil
+}
+
+// renew checks the lock's connection until the lock is released, cancelling the lock's context
+// when the connection, and with it the lock, is gone
+func (l *SessionLock) renew() {
+	ticker := time.NewTicker(LockRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stopped:
+			return
+		case <-ticker.C:
+		}
+		checkCtx, cancel := context.WithTimeout(l.ctx, LockRenewInterval)
+		err := l.conn.PingContext(checkCtx)
+		cancel()
+		if err != nil {
+			select {
+			case <-l.stopped:
+				// Released while checking
+				return
+			default:
+			}
+			locksLost.WithLabelValues(lockLabel(l.name)).Inc()
+			log.Printf("Lost lock %s: %v", l.name, err)
+			l.cancel()
+			return
+		}
+	}
+}
+
+// Context is cancelled when the lock is released or lost; long work under the lock should stop
+// when it is done
+func (l *SessionLock) Context() context.Context {
+	return l.ctx
+}
+
+// Release unlocks and discards the lock's connection, so a lock that failed to unlock cannot
+// return to the pool. Calling it again does nothing.
+func (l *SessionLock) Release() error {
+	var err error
+	l.once.Do(func() {
+		close(l.stopped)
+		lockHeld.WithLabelValues(lockLabel(l.name)).Observe(time.Since(l.acquired).Seconds())
+		lost := l.ctx.Err() != nil
+		l.cancel()
+
+		_, err = l.conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext($1))`, l.name)
+		if lost {
+			err = nil
+		}
+		l.conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		if err != nil {
+			err = fmt.Errorf("failed to release lock %s: %w", l.name, err)
+		}
+	})
+	return err
+}
@@ -0,0 +1,26 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// RequiredSchemaVersion is the newest NGS migration (shared/schemas/NN_ngs_*.sql) this build
+// needs. Bump it with every migration that records itself in ngs_schema_version.
+const RequiredSchemaVersion = 59
+
+// CheckSchemaVersion reports an error unless the database is reachable and has at least
+// RequiredSchemaVersion applied
+func (db *DB) CheckSchemaVersion(ctx context.Context) error {
+	var version int
+	err := db.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(version), 0) FROM ngs_schema_version
+	`).Scan(&version)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version < RequiredSchemaVersion {
+		return fmt.Errorf("schema version %d is older than required version %d", version, RequiredSchemaVersion)
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+// Package guardrails checks generated lessons before they are published: no personal data,
+// a reading level within bounds, and the ethics guardrail sections the generation asked for.
+package guardrails
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Checks, as named in a Report
+const (
+	CheckPII          = "no_pii"
+	CheckReadingLevel = "reading_level"
+	CheckEthics       = "ethics_guardrails"
+)
+
+// Section is a section a lesson must have, found by a heading containing any of its keywords
+type Section struct {
+	Name     string   `json:"name"`
+	Keywords []string `json:"keywords"`
+}
+
+// Policy is what generated lessons are checked against. A zero ReadingLevelMax leaves the
+// reading level unbounded above.
+type Policy struct {
+	ReadingLevelMin float64
+	ReadingLevelMax float64
+	EthicsSections  []Section
+}
+
+// ParseSections reads sections such as "ethics=ethic,responsible use;safety=safety,risk".
+// Keywords are matched case-insensitively. "none" requires no sections.
+func ParseSections(spec string) ([]Section, error) {
+	var sections []Section
+	if strings.TrimSpace(spec) == "none" {
+		return sections, nil
+	}
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(spec, ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		name, list, ok := strings.Cut(field, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%q is not section=keyword,keyword,...", field)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("section %s listed twice", name)
+		}
+		seen[name] = true
+		section := Section{Name: name}
+		for _, keyword := range strings.Split(list, ",") {
+			keyword = strings.ToLower(strings.TrimSpace(keyword))
+			if keyword == "" {
+				return nil, fmt.Errorf("empty keyword for section %s", name)
+			}
+			section.Keywords = append(section.Keywords, keyword)
+		}
+		sections = append(sections, section)
+	}
+	return sections, nil
+}
+
+// Lesson is the generated content to check
+type Lesson struct {
+	Markdown string
+	// Text is the structured lesson's other text, such as practice tasks and quiz items
+	Text []string
+	// ReadingLevel is the content's estimated grade level
+	ReadingLevel float64
+	// RequireEthics is set when generation asked for ethics guardrails
+	RequireEthics bool
+}
+
+// Result is one check's outcome. Details never repeat personal data found, only what kind.
+type Result struct {
+	Check   string   `json:"check"`
+	Passed  bool     `json:"passed"`
+	Details []string `json:"details,omitempty"`
+}
+
+// Report is the outcome of every check on a lesson
+type Report struct {
+	Passed  bool     `json:"passed"`
+	Results []Result `json:"results"`
+}
+
+// Failed lists the checks that failed
+func (r Report) Failed() []string {
+	var failed []string
+	for _, result := range r.Results {
+		if !result.Passed {
+			failed = append(failed, result.Check)
+		}
+	}
+	return failed
+}
+
+// String summarizes the failed checks, for logs and stored errors
+func (r Report) String() string {
+	if r.Passed {
+		return "all guardrail checks passed"
+	}
+	var parts []string
+	for _, result := range r.Results {
+		if !result.Passed {
+			parts = append(parts, result.Check+": "+strings.Join(result.Details, "; "))
+		}
+	}
+	return strings.Join(parts, " | ")
+}
+
+// Check runs every check on lesson. The ethics check only runs when the lesson requires it.
+func (p Policy) Check(lesson Lesson) Report {
+	results := []Result{
+		checkPII(append([]string{lesson.Markdown}, lesson.Text...)),
+		p.checkReadingLevel(lesson.ReadingLevel),
+	}
+	if lesson.RequireEthics {
+		results = append(results, p.checkEthics(lesson.Markdown))
+	}
+	report := Report{Passed: true, Results: results}
+	for _, result := range results {
+		report.Passed = report.Passed && result.Passed
+	}
+	return report
+}
+
+var (
+	emailPattern = regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@([a-z0-9-]+\.)+[a-z]{2,}\b`)
+	phonePattern = regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?\(?\b\d{3}\)?[\s.-]\d{3}[\s.-]\d{4}\b`)
+	ssnPattern   = regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)
+	cardPattern  = regexp.MustCompile(`\b(?:\d[ -]?){13,19}\b`)
+)
+
+// Domains reserved for documentation (RFC 2606), so addresses in them are examples
+var (
+	exampleDomains = []string{"example.com", "example.org", "example.net"}
+	exampleTLDs    = []string{"example", "test", "invalid", "localhost"}
+)
+
+func checkPII(texts []string) Result {
+	found := make(map[string]int)
+	for _, text := range texts {
+		for _, email := range emailPattern.FindAllString(text, -1) {
+			if !exampleAddress(email) {
+				found["email address"]++
+			}
+		}
+		found["phone number"] += len(phonePattern.FindAllString(text, -1))
+		found["national ID number"] += len(ssnPattern.FindAllString(text, -1))
+		for _, card := range cardPattern.FindAllString(text, -1) {
+			if luhn(card) {
+				found["payment card number"]++
+			}
+		}
+	}
+
+	result := Result{Check: CheckPII, Passed: true}
+	for kind, count := range found {
+		if count > 0 {
+			result.Passed = false
+			result.Details = append(result.Details, fmt.Sprintf("%d %s(s) found", count, kind))
+		}
+	}
+	sort.Strings(result.Details)
+	return result
+}
+
+func exampleAddress(email string) bool {
+	domain := strings.ToLower(email[strings.LastIndex(email, "@")+1:])
+	for _, example := range exampleDomains {
+		if domain == example || strings.HasSuffix(domain, "."+example) {
+			return true
+		}
+	}
+	for _, tld := range exampleTLDs {
+		if strings.HasSuffix(domain, "."+tld) {
+			return true
+		}
+	}
+	return false
+}
+
+// luhn reports whether the digits in s pass the Luhn checksum payment cards carry
+func luhn(s string) bool {
+	sum, digits := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			continue
+		}
+		d := int(s[i] - '0')
+		if digits%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
+
+func (p Policy) checkReadingLevel(level float64) Result {
+	result := Result{Check: CheckReadingLevel, Passed: true}
+	if level < p.ReadingLevelMin {
+		result.Passed = false
+		result.Details = []string{fmt.Sprintf("grade %.1f is below the minimum of %.1f", level, p.ReadingLevelMin)}
+	}
+	if p.ReadingLevelMax > 0 && level > p.ReadingLevelMax {
+		result.Passed = false
+		result.Details = []string{fmt.Sprintf("grade %.1f is above the maximum of %.1f", level, p.ReadingLevelMax)}
+	}
+	return result
+}
+
+var headingPattern = regexp.MustCompile(`(?m)^[ \t]{0,3}#{1,6}[ \t]+(.+?)[ \t]*#*[ \t]*$`)
+
+func (p Policy) checkEthics(markdown string) Result {
+	var headings []string
+	for _, match := range headingPattern.FindAllStringSubmatch(markdown, -1) {
+		headings = append(headings, strings.ToLower(match[1]))
+	}
+
+	result := Result{Check: CheckEthics, Passed: true}
+	for _, section := range p.EthicsSections {
+		if !hasSection(headings, section) {
+			result.Passed = false
+			result.Details = append(result.Details, fmt.Sprintf("no %s section", section.Name))
+		}
+	}
+	return result
+}
+
+func hasSection(headings []string, section Section) bool {
+	for _, heading := range headings {
+		for _, keyword := range section.Keywords {
+			if strings.Contains(heading, keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,298 @@
+// Package lessondiff compares two versions of a lesson: a line diff of the markdown in unified
+// hunks, and which sections of the structured lesson were added, removed or changed.
+package lessondiff
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Line operations in a hunk, as in a unified diff
+const (
+	OpContext = " "
+	OpAdd     = "+"
+	OpRemove  = "-"
+)
+
+// Section changes
+const (
+	SectionAdded     = "added"
+	SectionRemoved   = "removed"
+	SectionChanged   = "changed"
+	SectionUnchanged = "unchanged"
+)
+
+// ContextLines is how many unchanged lines surround each change in a hunk
+const ContextLines = 3
+
+// maxCells bounds the line comparison table. Past it the differing middle of the two texts is
+// shown as removed and re-added whole rather than compared line by line.
+const maxCells = 4_000_000
+
+// Line is one line of a hunk
+type Line struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Hunk is a run of changed lines with their context. Starts are 1-based line numbers, as in a
+// unified diff's @@ header.
+type Hunk struct {
+	FromStart int    `json:"from_start"`
+	FromLines int    `json:"from_lines"`
+	ToStart   int    `json:"to_start"`
+	ToLines   int    `json:"to_lines"`
+	Lines     []Line `json:"lines"`
+}
+
+// MarkdownDiff is the line diff of two markdown documents
+type MarkdownDiff struct {
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+	Hunks   []Hunk `json:"hunks"`
+}
+
+// Section is how one top-level section of the structured lesson changed. Before and after are
+// only given for sections that changed.
+type Section struct {
+	Name   string          `json:"name"`
+	Change string          `json:"change"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// sectionOrder lists the structured lesson's sections in the order lessons present them; other
+// keys follow alphabetically
+var sectionOrder = []string{"metadata", "teach", "guided_practice", "assessment", "summary", "artifacts", "accessibility"}
+
+// Markdown diffs from against to line by line
+func Markdown(from, to string) MarkdownDiff {
+	a, b := splitLines(from), splitLines(to)
+	ops := diffLines(a, b)
+
+	diff := MarkdownDiff{Hunks: []Hunk{}}
+	for _, op := range ops {
+		switch op.Op {
+		case OpAdd:
+			diff.Added++
+		case OpRemove:
+			diff.Removed++
+		}
+	}
+	diff.Hunks = hunks(ops)
+	return diff
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines returns the edit script from a to b: the longest common subsequence of lines kept
+// as context, and the rest removed from a or added from b
+func diffLines(a, b []string) []Line {
+	// Common prefix and suffix need no table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []Line
+	for _, line := range a[:prefix] {
+		ops = append(ops, Line{OpContext, line})
+	}
+	ops = append(ops, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, Line{OpContext, line})
+	}
+	return ops
+}
+
+func diffMiddle(a, b []string) []Line {
+	var ops []Line
+	if len(a)*len(b) > maxCells {
+		for _, line := range a {
+			ops = append(ops, Line{OpRemove, line})
+		}
+		for _, line := range b {
+			ops = append(ops, Line{OpAdd, line})
+		}
+		return ops
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, Line{OpContext, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, Line{OpRemove, a[i]})
+			i++
+		default:
+			ops = append(ops, Line{OpAdd, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, Line{OpRemove, a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, Line{OpAdd, b[j]})
+	}
+	return ops
+}
+
+// hunks groups the changes in ops with up to ContextLines of context either side, merging
+// changes whose context would overlap
+func hunks(ops []Line) []Hunk {
+	result := []Hunk{}
+	// fromLine and toLine are the 1-based line numbers ops[i] is at in each text
+	fromLine, toLine := make([]int, len(ops)), make([]int, len(ops))
+	f, t := 1, 1
+	for i, op := range ops {
+		fromLine[i], toLine[i] = f, t
+		if op.Op != OpAdd {
+			f++
+		}
+		if op.Op != OpRemove {
+			t++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].Op == OpContext {
+			i++
+			continue
+		}
+		start := i - ContextLines
+		if start < 0 {
+			start = 0
+		}
+		// Extend past each change while the next change is within two contexts' reach
+		end, unchanged := i, 0
+		for end < len(ops) && unchanged <= 2*ContextLines {
+			if ops[end].Op == OpContext {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+			end++
+		}
+		// end is past the last change plus its trailing context
+		end -= unchanged
+		if trailing := end + ContextLines; trailing < len(ops) {
+			end = trailing
+		} else {
+			end = len(ops)
+		}
+
+		hunk := Hunk{FromStart: fromLine[start], ToStart: toLine[start], Lines: ops[start:end]}
+		for _, op := range hunk.Lines {
+			if op.Op != OpAdd {
+				hunk.FromLines++
+			}
+			if op.Op != OpRemove {
+				hunk.ToLines++
+			}
+		}
+		result = append(result, hunk)
+		i = end
+	}
+	return result
+}
+
+// Sections compares the top-level sections of two structured lessons, given as JSON objects.
+// Anything that is not an object counts as having no sections.
+func Sections(from, to json.RawMessage) []Section {
+	a, b := objectOf(from), objectOf(to)
+
+	names := make(map[string]bool)
+	for name := range a {
+		names[name] = true
+	}
+	for name := range b {
+		names[name] = true
+	}
+	var ordered []string
+	for _, name := range sectionOrder {
+		if names[name] {
+			ordered = append(ordered, name)
+			delete(names, name)
+		}
+	}
+	var rest []string
+	for name := range names {
+		rest = append(rest, name)
+	}
+	sort.Strings(rest)
+	ordered = append(ordered, rest...)
+
+	sections := []Section{}
+	for _, name := range ordered {
+		before, inA := a[name]
+		after, inB := b[name]
+		section := Section{Name: name}
+		switch {
+		case !inA:
+			section.Change, section.After = SectionAdded, after
+		case !inB:
+			section.Change, section.Before = SectionRemoved, before
+		case sameJSON(before, after):
+			section.Change = SectionUnchanged
+		default:
+			section.Change, section.Before, section.After = SectionChanged, before, after
+		}
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+func objectOf(data json.RawMessage) map[string]json.RawMessage {
+	var object map[string]json.RawMessage
+	if len(data) == 0 || json.Unmarshal(data, &object) != nil {
+		return map[string]json.RawMessage{}
+	}
+	// JSON null sections are absent
+	for name, value := range object {
+		if string(value) == "null" {
+			delete(object, name)
+		}
+	}
+	return object
+}
+
+// sameJSON compares values rather than bytes, so key order and spacing do not count as changes
+func sameJSON(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return string(a) == string(b)
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,121 @@
+// Package lessonexport renders a lesson for printing or for offline classrooms: as markdown with
+// a metadata header and a glossary appendix, or as a PDF laid out from that markdown.
+package lessonexport
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Export formats
+const (
+	FormatMarkdown = "md"
+	FormatPDF      = "pdf"
+)
+
+// Term is one glossary entry
+type Term struct {
+	Term       string
+	Definition string
+}
+
+// Document is a lesson ready to export
+type Document struct {
+	Title            string
+	Description      string
+	Level            int
+	Order            int
+	LessonType       string
+	Difficulty       string
+	EstimatedMinutes int
+	Outcomes         []string
+	UpdatedAt        time.Time
+	// Body is the lesson content as markdown
+	Body     string
+	Glossary []Term
+}
+
+// Markdown renders the lesson as one markdown document: the title, a metadata header, the
+// content and the glossary, alphabetically, as an appendix
+func Markdown(doc Document) []byte {
+	var b strings.Builder
+	b.WriteString("# " + doc.Title + "\n\n")
+	if description := strings.TrimSpace(doc.Description); description != "" {
+		b.WriteString(description + "\n\n")
+	}
+	for _, field := range header(doc) {
+		fmt.Fprintf(&b, "- **%s:** %s\n", field[0], field[1])
+	}
+	if len(doc.Outcomes) > 0 {
+		b.WriteString("\n**By the end of this lesson you will be able to:**\n\n")
+		for _, outcome := range doc.Outcomes {
+			b.WriteString("- " + strings.TrimSpace(outcome) + "\n")
+		}
+	}
+	b.WriteString("\n---\n\n")
+
+	if body := strings.TrimSpace(withoutTitle(doc.Body, doc.Title)); body != "" {
+		b.WriteString(body + "\n")
+	}
+
+	if glossary := sortedGlossary(doc.Glossary); len(glossary) > 0 {
+		b.WriteString("\n---\n\n## Glossary\n\n")
+		for _, term := range glossary {
+			fmt.Fprintf(&b, "**%s:** %s\n\n", term.Term, term.Definition)
+		}
+	}
+	return []byte(strings.TrimRight(b.String(), "\n") + "\n")
+}
+
+// header lists the metadata fields shown under the title, as name and value
+func header(doc Document) [][2]string {
+	var fields [][2]string
+	if doc.Level > 0 {
+		place := fmt.Sprintf("%d", doc.Level)
+		if doc.Order > 0 {
+			place += fmt.Sprintf(", lesson %d", doc.Order)
+		}
+		fields = append(fields, [2]string{"Level", place})
+	}
+	if doc.LessonType != "" {
+		fields = append(fields, [2]string{"Type", doc.LessonType})
+	}
+	if doc.Difficulty != "" {
+		fields = append(fields, [2]string{"Difficulty", doc.Difficulty})
+	}
+	if doc.EstimatedMinutes > 0 {
+		fields = append(fields, [2]string{"Estimated time", fmt.Sprintf("%d minutes", doc.EstimatedMinutes)})
+	}
+	if !doc.UpdatedAt.IsZero() {
+		fields = append(fields, [2]string{"Updated", doc.UpdatedAt.UTC().Format("2006-01-02")})
+	}
+	return fields
+}
+
+// withoutTitle drops a level-one heading repeating the title at the top of body, since the
+// export starts with the title already
+func withoutTitle(body, title string) string {
+	trimmed := strings.TrimLeft(body, " \t\r\n")
+	line, rest, _ := strings.Cut(trimmed, "\n")
+	heading, ok := strings.CutPrefix(strings.TrimSpace(line), "# ")
+	if ok && strings.EqualFold(strings.TrimSpace(heading), strings.TrimSpace(title)) {
+		return rest
+	}
+	return body
+}
+
+func sortedGlossary(glossary []Term) []Term {
+	terms := make([]Term, 0, len(glossary))
+	for _, term := range glossary {
+		term.Term, term.Definition = strings.TrimSpace(term.Term), strings.TrimSpace(term.Definition)
+		if term.Term != "" {
+			terms = append(terms, term)
+		}
+	}
+	sort.SliceStable(terms, func(i, j int) bool {
+		return strings.ToLower(terms[i].Term) < strings.ToLower(terms[j].Term)
+	})
+	return terms
+}
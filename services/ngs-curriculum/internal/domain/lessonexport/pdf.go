@@ -0,0 +1,455 @@
+package lessonexport
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A4, in points
+const (
+	pageWidth    = 595.28
+	pageHeight   = 841.89
+	pageMargin   = 56.0
+	footerHeight = 24.0
+)
+
+// font is one of the PDF standard fonts, which readers provide, so nothing is embedded
+type font struct {
+	resource string
+	baseFont string
+	widths   *[95]int
+}
+
+var (
+	fontRegular = font{"F1", "Helvetica", &helveticaWidths}
+	fontBold    = font{"F2", "Helvetica-Bold", &helveticaBoldWidths}
+	fontItalic  = font{"F3", "Helvetica-Oblique", &helveticaWidths}
+	fontMono    = font{"F4", "Courier", nil}
+	fonts       = []font{fontRegular, fontBold, fontItalic, fontMono}
+)
+
+// style is how one kind of block is set
+type style struct {
+	font    font
+	size    float64
+	leading float64
+	// before is extra space above the block
+	before float64
+}
+
+var (
+	styleBody    = style{fontRegular, 11, 15, 5}
+	styleQuote   = style{fontItalic, 11, 15, 5}
+	styleCode    = style{fontMono, 9.5, 12, 0}
+	styleFooter  = style{fontRegular, 9, 12, 0}
+	styleHeading = []style{
+		{fontBold, 20, 25, 12},
+		{fontBold, 16, 21, 12},
+		{fontBold, 13, 18, 10},
+		{fontBold, 11, 15, 8},
+	}
+)
+
+// width is the width of s, already in WinAnsi, set in f at size
+func (f font) width(s []byte, size float64) float64 {
+	total := 0
+	for _, c := range s {
+		switch {
+		case f.widths == nil:
+			total += 600
+		case c >= 32 && c <= 126:
+			total += f.widths[c-32]
+		default:
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// Block kinds
+const (
+	blockParagraph = iota
+	blockHeading
+	blockBullet
+	blockQuote
+	blockCode
+	blockRule
+)
+
+type block struct {
+	kind int
+	// level is the heading level, or how deeply a bullet is nested
+	level  int
+	marker string
+	text   string
+}
+
+var (
+	headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	bulletPattern  = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+	rulePattern    = regexp.MustCompile(`^\s*([-*_])(\s*([-*_]))+\s*$`)
+	imagePattern   = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	linkPattern    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)[^)]*\)`)
+	strongPattern  = regexp.MustCompile(`(\*\*|__)(.+?)(\*\*|__)`)
+	emPattern      = regexp.MustCompile(`(^|[^\w*])\*([^*\s][^*]*?)\*`)
+)
+
+// parseMarkdown splits markdown into the blocks the PDF sets. It reads the common subset
+// lessons use; anything else is set as paragraph text.
+func parseMarkdown(markdown string) []block {
+	var blocks []block
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			blocks = append(blocks, block{kind: blockParagraph, text: inline(strings.Join(paragraph, " "))})
+			paragraph = nil
+		}
+	}
+
+	fence := ""
+	for _, line := range strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n") {
+		line = strings.ReplaceAll(line, "\t", "    ")
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+				continue
+			}
+			blocks = append(blocks, block{kind: blockCode, text: line})
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			flush()
+			fence = trimmed[:3]
+		case trimmed == "":
+			flush()
+		case headingPattern.MatchString(trimmed):
+			flush()
+			m := headingPattern.FindStringSubmatch(trimmed)
+			blocks = append(blocks, block{kind: blockHeading, level: len(m[1]), text: inline(m[2])})
+		case rulePattern.MatchString(trimmed) && strings.Count(trimmed, string(trimmed[0])) >= 3:
+			flush()
+			blocks = append(blocks, block{kind: blockRule})
+		case bulletPattern.MatchString(line):
+			flush()
+			m := bulletPattern.FindStringSubmatch(line)
+			marker := m[2]
+			if strings.ContainsAny(marker, "-*+") {
+				marker = "•"
+			}
+			blocks = append(blocks, block{kind: blockBullet, level: len(m[1]) / 2, marker: marker, text: inline(m[3])})
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+			blocks = append(blocks, block{kind: blockQuote, text: inline(strings.TrimSpace(strings.TrimLeft(trimmed, ">")))})
+		case strings.HasPrefix(trimmed, "|"):
+			// Tables keep their columns in a fixed-width font
+			flush()
+			blocks = append(blocks, block{kind: blockCode, text: trimmed})
+		default:
+			paragraph = append(paragraph, trimmed)
+		}
+	}
+	flush()
+	return blocks
+}
+
+// inline strips inline markdown: emphasis and code markers go, links keep their URL in
+// brackets for print and images become their alt text
+func inline(text string) string {
+	text = imagePattern.ReplaceAllString(text, "[Image: $1]")
+	text = linkPattern.ReplaceAllString(text, "$1 ($2)")
+	text = strongPattern.ReplaceAllString(text, "$2")
+	text = emPattern.ReplaceAllString(text, "$1$2")
+	return strings.ReplaceAll(text, "`", "")
+}
+
+// textLine is one line set on a page
+type textLine struct {
+	font font
+	size float64
+	x, y float64
+	text []byte
+}
+
+// rule is a horizontal line across a page
+type rule struct {
+	x1, x2, y float64
+}
+
+type page struct {
+	lines []textLine
+	rules []rule
+}
+
+// layout sets blocks on pages, top to bottom, starting a page when one is full
+type layout struct {
+	pages []*page
+	y     float64
+}
+
+const (
+	pageTop    = pageHeight - pageMargin
+	pageBottom = pageMargin + footerHeight
+)
+
+func (l *layout) current() *page {
+	if len(l.pages) == 0 {
+		l.newPage()
+	}
+	return l.pages[len(l.pages)-1]
+}
+
+func (l *layout) newPage() {
+	l.pages = append(l.pages, &page{})
+	l.y = pageTop
+}
+
+// keep starts a new page unless height fits on this one
+func (l *layout) keep(height float64) {
+	if len(l.pages) == 0 || l.y-height < pageBottom {
+		l.newPage()
+	}
+}
+
+// gap leaves height of space between blocks, except at the top of a page
+func (l *layout) gap(height float64) {
+	if len(l.pages) > 0 && l.y < pageTop {
+		l.keep(height)
+		if l.y < pageTop {
+			l.y -= height
+		}
+	}
+}
+
+// line sets one line of text below the last, returning its baseline
+func (l *layout) line(s style, x float64, text []byte) float64 {
+	l.keep(s.leading)
+	l.y -= s.leading
+	// The baseline sits above the bottom of the line by the font's descent, about a fifth of it
+	baseline := l.y + 0.22*s.size
+	p := l.current()
+	p.lines = append(p.lines, textLine{font: s.font, size: s.size, x: x, y: baseline, text: text})
+	return baseline
+}
+
+// paragraph wraps text to the width right of x and sets it
+func (l *layout) paragraph(s style, x float64, text string) {
+	for _, line := range wrap(s, encode(text), pageWidth-pageMargin-x) {
+		l.line(s, x, line)
+	}
+}
+
+// wrap breaks text into lines no wider than width, breaking long words where they must
+func wrap(s style, text []byte, width float64) [][]byte {
+	var lines [][]byte
+	var current []byte
+	for _, word := range bytes.Fields(text) {
+		candidate := word
+		if len(current) > 0 {
+			candidate = append(append(append([]byte{}, current...), ' '), word...)
+		}
+		if s.font.width(candidate, s.size) <= width {
+			current = candidate
+			continue
+		}
+		if len(current) > 0 {
+			lines = append(lines, current)
+		}
+		current = word
+		for len(current) > 1 && s.font.width(current, s.size) > width {
+			cut := len(current) - 1
+			for cut > 1 && s.font.width(current[:cut], s.size) > width {
+				cut--
+			}
+			lines = append(lines, current[:cut])
+			current = current[cut:]
+		}
+	}
+	if len(current) > 0 {
+		lines = append(lines, current)
+	}
+	if len(lines) == 0 {
+		lines = [][]byte{{}}
+	}
+	return lines
+}
+
+// PDF renders the lesson as a printable A4 PDF, laid out from its markdown export, with the
+// title and page number in each page's footer
+func PDF(doc Document) []byte {
+	var l layout
+	for _, b := range parseMarkdown(string(Markdown(doc))) {
+		switch b.kind {
+		case blockHeading:
+			s := styleHeading[min(b.level, len(styleHeading))-1]
+			l.gap(s.before)
+			// A heading is not left at the foot of a page without two lines of what follows it
+			l.keep(s.leading + 2*styleBody.leading)
+			l.paragraph(s, pageMargin, b.text)
+		case blockParagraph:
+			l.gap(styleBody.before)
+			l.paragraph(styleBody, pageMargin, b.text)
+		case blockBullet:
+			indent := pageMargin + 14*float64(b.level)
+			marker := encode(b.marker)
+			markerWidth := styleBody.font.width(marker, styleBody.size) + 6
+			lines := wrap(styleBody, encode(b.text), pageWidth-pageMargin-indent-markerWidth)
+			for j, line := range lines {
+				baseline := l.line(styleBody, indent+markerWidth, line)
+				if j == 0 {
+					p := l.current()
+					p.lines = append(p.lines, textLine{font: styleBody.font, size: styleBody.size, x: indent, y: baseline, text: marker})
+				}
+			}
+		case blockQuote:
+			l.gap(styleQuote.before)
+			l.paragraph(styleQuote, pageMargin+16, b.text)
+		case blockCode:
+			// Code keeps its spacing, so it is broken at the width rather than between words
+			perLine := int((pageWidth - 2*pageMargin - 8) / styleCode.font.width([]byte{' '}, styleCode.size))
+			code := encode(b.text)
+			for len(code) > perLine {
+				l.line(styleCode, pageMargin+8, code[:perLine])
+				code = code[perLine:]
+			}
+			l.line(styleCode, pageMargin+8, code)
+		case blockRule:
+			l.keep(12)
+			l.y -= 12
+			p := l.current()
+			p.rules = append(p.rules, rule{x1: pageMargin, x2: pageWidth - pageMargin, y: l.y + 6})
+		}
+	}
+	if len(l.pages) == 0 {
+		l.newPage()
+	}
+	return writePDF(doc, l.pages)
+}
+
+// writePDF writes the pages as a PDF 1.4 file. Objects 1 to 6 are the catalog, page tree and
+// fonts, 7 the document info, then each page and its content stream.
+func writePDF(doc Document, pages []*page) []byte {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	const firstPage = 8
+	var kids []string
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", firstPage+2*i))
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	var fontRefs []string
+	for i, f := range fonts {
+		object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", f.baseFont))
+		fontRefs = append(fontRefs, fmt.Sprintf("/%s %d 0 R", f.resource, 3+i))
+	}
+	info := "<< /Title " + pdfString(encode(doc.Title)) + " /Producer (NGS Curriculum)"
+	if !doc.UpdatedAt.IsZero() {
+		info += " /ModDate (D:" + doc.UpdatedAt.UTC().Format("20060102150405") + "Z)"
+	}
+	object(info + " >>")
+
+	for i, p := range pages {
+		var content bytes.Buffer
+		for _, r := range p.rules {
+			fmt.Fprintf(&content, "0.5 w %s %s m %s %s l S\n", num(r.x1), num(r.y), num(r.x2), num(r.y))
+		}
+		for _, line := range p.lines {
+			fmt.Fprintf(&content, "BT /%s %s Tf %s %s Td %s Tj ET\n", line.font.resource, num(line.size), num(line.x), num(line.y), pdfString(line.text))
+		}
+		// Footer: the title on the left, the page number on the right
+		footerY := pageMargin - styleFooter.size
+		pageNumber := []byte(fmt.Sprintf("%d / %d", i+1, len(pages)))
+		numberX := pageWidth - pageMargin - styleFooter.font.width(pageNumber, styleFooter.size)
+		footerTitle := wrap(styleFooter, encode(doc.Title), numberX-pageMargin-12)[0]
+		fmt.Fprintf(&content, "BT /%s %s Tf %s %s Td %s Tj ET\n", styleFooter.font.resource, num(styleFooter.size), num(pageMargin), num(footerY), pdfString(footerTitle))
+		fmt.Fprintf(&content, "BT /%s %s Tf %s %s Td %s Tj ET\n", styleFooter.font.resource, num(styleFooter.size), num(numberX), num(footerY), pdfString(pageNumber))
+
+		var compressed bytes.Buffer
+		w := zlib.NewWriter(&compressed)
+		w.Write(content.Bytes())
+		w.Close()
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			num(pageWidth), num(pageHeight), strings.Join(fontRefs, " "), firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), compressed.Bytes()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 7 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// num writes a number to two decimal places, which is finer than a printer resolves
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// pdfString writes text as a PDF literal string, escaping delimiters and writing bytes outside
+// ASCII in octal
+func pdfString(text []byte) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, c := range text {
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 32 || c > 126:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// winAnsi maps the characters WinAnsiEncoding places in 0x80-0x9F
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88,
+	'‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B,
+	'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// encode converts text to WinAnsiEncoding, the encoding of the standard fonts. Characters it
+// lacks are printed as '?'.
+func encode(text string) []byte {
+	out := make([]byte, 0, len(text))
+	for len(text) > 0 {
+		r, size := utf8.DecodeRuneInString(text)
+		text = text[size:]
+		switch {
+		case r >= 32 && r <= 126, r >= 0xA0 && r <= 0xFF:
+			out = append(out, byte(r))
+		case r == '\n' || r == '\t':
+			out = append(out, ' ')
+		default:
+			if c, ok := winAnsi[r]; ok {
+				out = append(out, c)
+			} else if r >= 32 {
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,130 @@
+package xp
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Formulas, selected per deployment with XP_FORMULA
+const (
+	FormulaLinear      = "linear"
+	FormulaLogarithmic = "logarithmic"
+	FormulaDifficulty  = "difficulty"
+)
+
+// DefaultDifficultyMultipliers scale awards under the difficulty formula. Difficulties not
+// listed, and awards without one, are not scaled.
+const DefaultDifficultyMultipliers = "easy=1,medium=1.25,hard=1.5,expert=2"
+
+// Award is an XP award before the formula is applied
+type Award struct {
+	Source string
+	// Base is the amount from AwardAmount
+	Base int
+	// Difficulty is the "difficulty" the award was made with, if any
+	Difficulty string
+	// Repeats is how many earlier awards from Source fall in the repeat window. It is only
+	// counted for calculators that report CountsRepeats.
+	Repeats int
+}
+
+// XPCalculator computes the XP given for an award. Awards with a base of 0 or less are given
+// unchanged by every formula.
+type XPCalculator interface {
+	// Name is the formula's XP_FORMULA value
+	Name() string
+	Amount(award Award) int
+	// CountsRepeats reports whether Amount reads Award.Repeats, which costs a ledger query
+	CountsRepeats() bool
+}
+
+// NewCalculator returns the calculator for formula; empty is linear. multipliers are the
+// difficulty formula's "difficulty=multiplier" pairs, empty for the defaults.
+func NewCalculator(formula, multipliers string) (XPCalculator, error) {
+	switch formula {
+	case "", FormulaLinear:
+		return Linear{}, nil
+	case FormulaLogarithmic:
+		return Logarithmic{}, nil
+	case FormulaDifficulty:
+		if multipliers == "" {
+			multipliers = DefaultDifficultyMultipliers
+		}
+		parsed, err := ParseMultipliers(multipliers)
+		if err != nil {
+			return nil, err
+		}
+		return DifficultyScaled{Multipliers: parsed}, nil
+	}
+	return nil, fmt.Errorf("unknown XP formula %q: use linear, logarithmic or difficulty", formula)
+}
+
+// ParseMultipliers reads difficulty multipliers such as "easy=1,hard=1.5"
+func ParseMultipliers(spec string) (map[string]float64, error) {
+	multipliers := make(map[string]float64)
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		difficulty, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not difficulty=multiplier", field)
+		}
+		difficulty = strings.TrimSpace(difficulty)
+		multiplier, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || difficulty == "" || multiplier <= 0 || math.IsInf(multiplier, 0) {
+			return nil, fmt.Errorf("multiplier for %q must be a positive number", difficulty)
+		}
+		multipliers[difficulty] = multiplier
+	}
+	return multipliers, nil
+}
+
+// Linear gives the base amount every time. It is the default.
+type Linear struct{}
+
+func (Linear) Name() string        { return FormulaLinear }
+func (Linear) CountsRepeats() bool { return false }
+func (Linear) Amount(a Award) int  { return a.Base }
+
+// Logarithmic gives diminishing returns for a source repeated within the window: the base
+// divided by 1 + ln(1 + repeats), so the first award is in full, the second 59%, the fifth 38%.
+// A positive base never drops below 1.
+type Logarithmic struct{}
+
+func (Logarithmic) Name() string        { return FormulaLogarithmic }
+func (Logarithmic) CountsRepeats() bool { return true }
+
+func (Logarithmic) Amount(a Award) int {
+	if a.Base <= 0 || a.Repeats <= 0 {
+		return a.Base
+	}
+	return atLeastOne(float64(a.Base) / (1 + math.Log1p(float64(a.Repeats))))
+}
+
+// DifficultyScaled multiplies the base by the award's difficulty multiplier
+type DifficultyScaled struct {
+	Multipliers map[string]float64
+}
+
+func (DifficultyScaled) Name() string        { return FormulaDifficulty }
+func (DifficultyScaled) CountsRepeats() bool { return false }
+
+func (d DifficultyScaled) Amount(a Award) int {
+	multiplier, ok := d.Multipliers[a.Difficulty]
+	if a.Base <= 0 || !ok {
+		return a.Base
+	}
+	return atLeastOne(float64(a.Base) * multiplier)
+}
+
+// atLeastOne rounds a scaled positive award, keeping it at 1 or more
+func atLeastOne(amount float64) int {
+	if rounded := int(math.Round(amount)); rounded > 1 {
+		return rounded
+	}
+	return 1
+}
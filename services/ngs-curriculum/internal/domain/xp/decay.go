@@ -0,0 +1,76 @@
+package xp
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DecayCurves give, per source, the share of an award given for the user's 1st, 2nd, 3rd...
+// award from that source in a day. Awards past the end of a curve get its last share. Sources
+// without a curve do not decay.
+type DecayCurves map[string][]float64
+
+// ParseDecayCurves reads curves such as "reflection_quality=1,0.5,0.25;lesson_completion=1,1,0.5".
+// Shares must be between 0 and 1. "none" turns decay off.
+func ParseDecayCurves(spec string) (DecayCurves, error) {
+	curves := make(DecayCurves)
+	if strings.TrimSpace(spec) == "none" {
+		return curves, nil
+	}
+	for _, field := range strings.Split(spec, ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		source, list, ok := strings.Cut(field, "=")
+		source = strings.TrimSpace(source)
+		if !ok || source == "" {
+			return nil, fmt.Errorf("%q is not source=share,share,...", field)
+		}
+		if _, dup := curves[source]; dup {
+			return nil, fmt.Errorf("decay curve for %s listed twice", source)
+		}
+		var curve []float64
+		for _, value := range strings.Split(list, ",") {
+			share, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || share < 0 || share > 1 {
+				return nil, fmt.Errorf("decay shares for %s must be numbers between 0 and 1", source)
+			}
+			curve = append(curve, share)
+		}
+		curves[source] = curve
+	}
+	return curves, nil
+}
+
+// Share returns the share of an award from source given when earlier awards from it already
+// fall in the day
+func (c DecayCurves) Share(source string, earlier int) float64 {
+	curve := c[source]
+	if len(curve) == 0 {
+		return 1
+	}
+	if earlier < 0 {
+		earlier = 0
+	}
+	if earlier >= len(curve) {
+		return curve[len(curve)-1]
+	}
+	return curve[earlier]
+}
+
+// Decayed returns amount after the decay for earlier awards from source in the day. Awards of 0
+// or less are not decayed.
+func (c DecayCurves) Decayed(source string, amount, earlier int) int {
+	if amount <= 0 {
+		return amount
+	}
+	return int(math.Round(float64(amount) * c.Share(source, earlier)))
+}
+
+// Decays reports whether awards from source can decay
+func (c DecayCurves) Decays(source string) bool {
+	return len(c[source]) > 0
+}
@@ -0,0 +1,92 @@
+package xp
+
+import "fmt"
+
+// Level-down policies, selected with LEVEL_DOWN_POLICY
+const (
+	// LevelDownNever only ever raises levels. It is the default.
+	LevelDownNever = "never"
+	// LevelDownStrict has the level follow total XP down as well as up, within the grace
+	LevelDownStrict = "strict"
+)
+
+// Agent unlock policies, selected with AGENT_UNLOCK_POLICY
+const (
+	// AgentUnlockPermanent keeps agent creation once unlocked. It is the default.
+	AgentUnlockPermanent = "permanent"
+	// AgentUnlockLevel locks agent creation again whenever the level is below the unlock level
+	AgentUnlockLevel = "level"
+)
+
+// LevelPolicy decides what happens to a user's level and agent unlock when their total XP falls
+// below their level's threshold: after XP is revoked or a negative reconciliation, or after
+// thresholds are raised. The zero value is the default: levels and unlocks are never lost.
+type LevelPolicy struct {
+	LevelDown string
+	// GraceXP is how far, under LevelDownStrict, total XP may fall below the current level's
+	// threshold before the level is lost. It keeps small corrections from bouncing users
+	// between levels.
+	GraceXP     int
+	AgentUnlock string
+}
+
+// NewLevelPolicy returns the policy for the LEVEL_DOWN_POLICY, LEVEL_DOWN_GRACE_XP and
+// AGENT_UNLOCK_POLICY values; empty values are the defaults
+func NewLevelPolicy(levelDown string, graceXP int, agentUnlock string) (LevelPolicy, error) {
+	switch levelDown {
+	case "":
+		levelDown = LevelDownNever
+	case LevelDownNever, LevelDownStrict:
+	default:
+		return LevelPolicy{}, fmt.Errorf("unknown level-down policy %q: use never or strict", levelDown)
+	}
+	switch agentUnlock {
+	case "":
+		agentUnlock = AgentUnlockPermanent
+	case AgentUnlockPermanent, AgentUnlockLevel:
+	default:
+		return LevelPolicy{}, fmt.Errorf("unknown agent unlock policy %q: use permanent or level", agentUnlock)
+	}
+	if graceXP < 0 {
+		return LevelPolicy{}, fmt.Errorf("level-down grace must not be negative")
+	}
+	return LevelPolicy{LevelDown: levelDown, GraceXP: graceXP, AgentUnlock: agentUnlock}, nil
+}
+
+// Level returns the level of a user at currentLevel once their total is totalXP. Levels reached
+// with totalXP are always given. Under LevelDownStrict the user drops to the highest level whose
+// threshold is within GraceXP of totalXP; otherwise they keep currentLevel.
+func (p LevelPolicy) Level(thresholds []int, currentLevel, totalXP int) int {
+	reached := LevelForXP(thresholds, totalXP)
+	if reached >= currentLevel {
+		return reached
+	}
+	if p.LevelDown != LevelDownStrict {
+		return currentLevel
+	}
+	if kept := LevelForXP(thresholds, totalXP+p.GraceXP); kept < currentLevel {
+		return kept
+	}
+	return currentLevel
+}
+
+// AgentUnlocked reports whether agent creation is available at level for a user who had it
+// (alreadyUnlocked) before
+func (p LevelPolicy) AgentUnlocked(alreadyUnlocked bool, level, unlockLevel int) bool {
+	if p.AgentUnlock == AgentUnlockLevel {
+		return level >= unlockLevel
+	}
+	return AgentUnlocked(alreadyUnlocked, level, unlockLevel)
+}
+
+// Replay applies awards in order from a new user's level 1 under the policy and returns the
+// resulting total, level and agent unlock
+func (p LevelPolicy) Replay(thresholds []int, unlockLevel int, awards []int) (totalXP, level int, agentUnlocked bool) {
+	level = 1
+	for _, amount := range awards {
+		totalXP += amount
+		level = p.Level(thresholds, level, totalXP)
+		agentUnlocked = p.AgentUnlocked(agentUnlocked, level, unlockLevel)
+	}
+	return totalXP, level, agentUnlocked
+}
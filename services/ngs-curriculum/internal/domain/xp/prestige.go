@@ -0,0 +1,46 @@
+package xp
+
+// Prestige is progression past the top of the XP curve: a milestone every MilestoneXP beyond
+// the last threshold. The zero value has no milestones.
+type Prestige struct {
+	// StartXP is the last threshold, where milestones start counting
+	StartXP     int
+	MilestoneXP int
+}
+
+// NewPrestige returns milestones every milestoneXP past the top of thresholds; milestoneXP of 0
+// or less turns them off
+func NewPrestige(thresholds []int, milestoneXP int) Prestige {
+	if milestoneXP <= 0 {
+		return Prestige{}
+	}
+	start := 0
+	if len(thresholds) > 0 {
+		start = thresholds[len(thresholds)-1]
+	}
+	return Prestige{StartXP: start, MilestoneXP: milestoneXP}
+}
+
+// Enabled reports whether milestones are counted
+func (p Prestige) Enabled() bool {
+	return p.MilestoneXP > 0
+}
+
+// Milestones returns how many milestones totalXP has passed
+func (p Prestige) Milestones(totalXP int) int {
+	if !p.Enabled() || totalXP < p.StartXP {
+		return 0
+	}
+	// In int64 so a total near the int limit cannot overflow the subtraction
+	return int((int64(totalXP) - int64(p.StartXP)) / int64(p.MilestoneXP))
+}
+
+// XPToNextMilestone returns the XP still needed for the next milestone, counting the rest of the
+// curve for users below its top. It is 0 when milestones are off.
+func (p Prestige) XPToNextMilestone(totalXP int) int {
+	if !p.Enabled() {
+		return 0
+	}
+	next := int64(p.StartXP) + int64(p.Milestones(totalXP)+1)*int64(p.MilestoneXP)
+	return int(next - int64(totalXP))
+}
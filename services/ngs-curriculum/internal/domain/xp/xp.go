@@ -0,0 +1,96 @@
+// Package xp holds the XP and level math shared by the services. Thresholds are the minimum
+// total XP for each level, starting with level 1, as configured in LEVEL_UP_XP_THRESHOLDS. The
+// functions are pure and never touch the database.
+package xp
+
+// DefaultAward is the XP given for a source without a configured amount
+const DefaultAward = 10
+
+// MaxLevel returns the highest level on the curve; an empty curve only has level 1
+func MaxLevel(thresholds []int) int {
+	if len(thresholds) == 0 {
+		return 1
+	}
+	return len(thresholds)
+}
+
+// LevelForXP returns the level reached with totalXP, starting at level 1
+func LevelForXP(thresholds []int, totalXP int) int {
+	level := 1
+	for i, threshold := range thresholds {
+		if totalXP >= threshold {
+			level = i + 1
+		} else {
+			break
+		}
+	}
+	return level
+}
+
+// LevelAfterAward returns the level after an award brings the total to totalXP under the default
+// LevelPolicy. Levels are never lost, so a user above the curve (after thresholds were raised)
+// keeps their current level.
+func LevelAfterAward(thresholds []int, currentLevel, totalXP int) int {
+	return LevelPolicy{}.Level(thresholds, currentLevel, totalXP)
+}
+
+// Replay applies awards in order from a new user's level 1 under the default LevelPolicy and
+// returns the resulting total and level. It is how event-sourced progress is rebuilt from the XP
+// ledger.
+func Replay(thresholds []int, awards []int) (totalXP, level int) {
+	totalXP, level, _ = LevelPolicy{}.Replay(thresholds, 0, awards)
+	return totalXP, level
+}
+
+// AwardAmount returns amount, or the configured award for source when amount is not positive
+func AwardAmount(sources map[string]int, source string, amount int) int {
+	if amount > 0 {
+		return amount
+	}
+	if configured, ok := sources[source]; ok {
+		return configured
+	}
+	return DefaultAward
+}
+
+// AgentUnlocked reports whether agent creation is available at level. Once unlocked it stays
+// unlocked.
+func AgentUnlocked(alreadyUnlocked bool, level, unlockLevel int) bool {
+	return alreadyUnlocked || level >= unlockLevel
+}
+
+// LevelProgress is how far a user is through their current level
+type LevelProgress struct {
+	Level int
+	// XPToNextLevel is 0 at the max level
+	XPToNextLevel int
+	// Percent is between 0 and 100; it is 100 at the max level
+	Percent  float64
+	MaxLevel bool
+}
+
+// Progress returns the progress through level with totalXP. Levels outside the curve are clamped
+// to it, and XP outside the level's range is clamped to its ends. It does not panic on any
+// input, even a curve that is not increasing.
+func Progress(thresholds []int, level, totalXP int) LevelProgress {
+	maxLevel := MaxLevel(thresholds)
+	if level < 1 {
+		level = 1
+	}
+	if level >= maxLevel {
+		return LevelProgress{Level: maxLevel, Percent: 100, MaxLevel: true}
+	}
+
+	current, next := thresholds[level-1], thresholds[level]
+	p := LevelProgress{Level: level, XPToNextLevel: next - totalXP}
+	switch {
+	case totalXP <= current:
+		p.Percent = 0
+	case totalXP >= next:
+		p.Percent, p.XPToNextLevel = 100, 0
+	default:
+		// In floating point so extreme curves cannot overflow
+		p.Percent = (float64(totalXP) - float64(current)) / (float64(next) - float64(current)) * 100
+	}
+	return p
+}
@@ -0,0 +1,197 @@
+// Package egress builds the HTTP clients for every outbound call: the intelligence service,
+// TTS, object storage, the service registry, content connectors, the code sandbox, alert
+// webhooks and the user service.
+// Locked-down deployments configure it once at startup:
+//
+//   - Proxy sends requests through an HTTP proxy; hosts matching NoProxy go direct. Without a
+//     proxy, HTTP_PROXY, HTTPS_PROXY and NO_PROXY apply as usual.
+//   - Allowlist limits requests, redirects included, to matching hosts. Empty allows any host.
+//
+// Host patterns are hostnames ("tts.example.com") or wildcards for subdomains ("*.example.com").
+// Requests are counted by destination in ngs_egress_requests_total.
+package egress
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Destinations, the label outbound requests are counted under
+const (
+	DestinationIntelligence = "intelligence"
+	DestinationTTS          = "tts"
+	DestinationBlobStore    = "blobstore"
+	DestinationRegistry     = "registry"
+	DestinationContent      = "content"
+	DestinationSandbox      = "sandbox"
+	DestinationAlerts       = "alerts"
+	DestinationUsers        = "users"
+)
+
+// ErrBlocked is returned for requests to hosts outside the allowlist
+var ErrBlocked = errors.New("outbound host not allowed")
+
+var (
+	egressRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_egress_requests_total",
+			Help: "Outbound HTTP requests by destination and result (status class, error or blocked).",
+		},
+		[]string{"destination", "result"},
+	)
+
+	egressDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ngs_egress_request_duration_seconds",
+			Help:    "Outbound HTTP request duration until response headers, by destination.",
+			Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+		},
+		[]string{"destination"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(egressRequests, egressDuration)
+}
+
+// Policy is the proxy and allowlist applied to outbound requests
+type Policy struct {
+	Proxy     *url.URL
+	NoProxy   []string
+	Allowlist []string
+}
+
+// ParsePolicy reads EGRESS_PROXY_URL, EGRESS_NO_PROXY and EGRESS_ALLOWLIST. The host lists
+// are comma-separated.
+func ParsePolicy(proxy, noProxy, allowlist string) (Policy, error) {
+	var policy Policy
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return Policy{}, fmt.Errorf("proxy %q is not an http or https URL", proxy)
+		}
+		policy.Proxy = u
+	}
+
+	var err error
+	if policy.NoProxy, err = parseHosts(noProxy); err != nil {
+		return Policy{}, fmt.Errorf("invalid no-proxy host: %w", err)
+	}
+	if policy.Allowlist, err = parseHosts(allowlist); err != nil {
+		return Policy{}, fmt.Errorf("invalid allowlist host: %w", err)
+	}
+	return policy, nil
+}
+
+func parseHosts(list string) ([]string, error) {
+	var hosts []string
+	for _, host := range strings.Split(list, ",") {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host == "" {
+			continue
+		}
+		name := strings.TrimPrefix(host, "*.")
+		if name == "" || strings.ContainsAny(name, "*/:@ ") {
+			return nil, fmt.Errorf("%q is not a hostname or *.domain", host)
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+// matchHost reports whether host matches any of patterns
+func matchHost(host string, patterns []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range patterns {
+		if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed reports whether requests to host may be sent
+func (p Policy) Allowed(host string) bool {
+	return len(p.Allowlist) == 0 || matchHost(host, p.Allowlist)
+}
+
+// proxyFor returns the proxy for req, or nil to connect directly
+func (p Policy) proxyFor(req *http.Request) (*url.URL, error) {
+	if p.Proxy == nil {
+		return http.ProxyFromEnvironment(req)
+	}
+	if matchHost(req.URL.Hostname(), p.NoProxy) {
+		return nil, nil
+	}
+	return p.Proxy, nil
+}
+
+var (
+	policy atomic.Pointer[Policy]
+
+	// transport is shared by every client so connections are pooled across destinations
+	transport = newTransport()
+)
+
+func newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = func(req *http.Request) (*url.URL, error) {
+		return current().proxyFor(req)
+	}
+	return t
+}
+
+func current() Policy {
+	if p := policy.Load(); p != nil {
+		return *p
+	}
+	return Policy{}
+}
+
+// Configure applies p to every client, including those already built
+func Configure(p Policy) {
+	policy.Store(&p)
+}
+
+// NewClient returns a client for calls to destination, timing out after timeout
+func NewClient(destination string, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &roundTripper{destination: destination},
+	}
+}
+
+type roundTripper struct {
+	destination string
+}
+
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !current().Allowed(req.URL.Hostname()) {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		egressRequests.WithLabelValues(t.destination, "blocked").Inc()
+		return nil, fmt.Errorf("%w: %s (%s)", ErrBlocked, req.URL.Hostname(), t.destination)
+	}
+
+	start := time.Now()
+	resp, err := transport.RoundTrip(req)
+	egressDuration.WithLabelValues(t.destination).Observe(time.Since(start).Seconds())
+	if err != nil {
+		egressRequests.WithLabelValues(t.destination, "error").Inc()
+		return nil, err
+	}
+	egressRequests.WithLabelValues(t.destination, fmt.Sprintf("%dxx", resp.StatusCode/100)).Inc()
+	return resp, nil
+}
@@ -0,0 +1,293 @@
+// Package faults injects dependency failures for exercising retries, timeouts and degraded
+// modes outside production. Rules come from FAULT_INJECTION, e.g.
+//
+//	intelligence:timeout,match=/educator/chat;db:error,match=ChallengeService.SubmitChallenge,rate=0.5;db:delay,delay=2s
+//
+// Each rule names a target (intelligence or db) and a fault:
+//   - error fails the call straight away
+//   - timeout hangs until the caller's deadline, or for delay (default 30s), then fails as a timeout
+//   - delay waits for delay, then lets the call through
+//
+// match limits a rule to calls whose subject contains it: the request path for intelligence,
+// the calling function (as in ngs_db_query_duration_seconds) or query text for db. rate is the
+// share of matching calls that fail (default 1). The first matching rule applies.
+package faults
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Targets
+const (
+	TargetIntelligence = "intelligence"
+	TargetDB           = "db"
+)
+
+// Faults
+const (
+	FaultError   = "error"
+	FaultTimeout = "timeout"
+	FaultDelay   = "delay"
+)
+
+// defaultTimeoutWait bounds a timeout fault when neither the rule nor the caller sets a deadline
+const defaultTimeoutWait = 30 * time.Second
+
+// ErrInjected is wrapped by every error a fault returns
+var ErrInjected = errors.New("injected fault")
+
+var faultsInjected = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ngs_faults_injected_total",
+		Help: "Dependency faults injected by FAULT_INJECTION, by target and fault.",
+	},
+	[]string{"target", "fault"},
+)
+
+func init() {
+	prometheus.MustRegister(faultsInjected)
+}
+
+// Allowed reports whether fault injection may run in environment (ENVIRONMENT)
+func Allowed(environment string) bool {
+	switch strings.ToLower(environment) {
+	case "development", "staging":
+		return true
+	}
+	return false
+}
+
+// Rule is one FAULT_INJECTION rule
+type Rule struct {
+	Target string
+	Fault  string
+	Match  string
+	Delay  time.Duration
+	Rate   float64
+}
+
+func (r Rule) String() string {
+	s := fmt.Sprintf("%s:%s", r.Target, r.Fault)
+	if r.Match != "" {
+		s += ",match=" + r.Match
+	}
+	if r.Fault != FaultError {
+		s += ",delay=" + r.Delay.String()
+	}
+	if r.Rate < 1 {
+		s += ",rate=" + strconv.FormatFloat(r.Rate, 'f', -1, 64)
+	}
+	return s
+}
+
+// Parse reads FAULT_INJECTION rules, separated by semicolons
+func Parse(spec string) ([]Rule, error) {
+	var rules []Rule
+	for _, text := range strings.Split(spec, ";") {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		rule, err := parseRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fault rule %q: %w", text, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseRule(text string) (Rule, error) {
+	fields := strings.Split(text, ",")
+	target, fault, ok := strings.Cut(strings.TrimSpace(fields[0]), ":")
+	if !ok {
+		return Rule{}, errors.New("start with target:fault")
+	}
+	rule := Rule{Target: strings.TrimSpace(target), Fault: strings.TrimSpace(fault), Rate: 1}
+	switch rule.Target {
+	case TargetIntelligence, TargetDB:
+	default:
+		return Rule{}, fmt.Errorf("unknown target %q: use intelligence or db", rule.Target)
+	}
+	switch rule.Fault {
+	case FaultError, FaultTimeout, FaultDelay:
+	default:
+		return Rule{}, fmt.Errorf("unknown fault %q: use error, timeout or delay", rule.Fault)
+	}
+
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return Rule{}, fmt.Errorf("option %q is not key=value", field)
+		}
+		switch key {
+		case "match":
+			rule.Match = value
+		case "delay":
+			delay, err := time.ParseDuration(value)
+			if err != nil || delay <= 0 {
+				return Rule{}, fmt.Errorf("delay %q is not a positive duration", value)
+			}
+			rule.Delay = delay
+		case "rate":
+			rate, err := strconv.ParseFloat(value, 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return Rule{}, fmt.Errorf("rate %q is not between 0 and 1", value)
+			}
+			rule.Rate = rate
+		default:
+			return Rule{}, fmt.Errorf("unknown option %q", key)
+		}
+	}
+
+	switch {
+	case rule.Fault == FaultDelay && rule.Delay == 0:
+		return Rule{}, errors.New("delay faults need delay=")
+	case rule.Fault == FaultTimeout && rule.Delay == 0:
+		rule.Delay = defaultTimeoutWait
+	}
+	return rule, nil
+}
+
+// Injector applies rules to dependency calls. A nil Injector injects nothing.
+type Injector struct {
+	rules []Rule
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func New(rules []Rule) *Injector {
+	return &Injector{rules: rules, rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// Rules returns the injector's rules
+func (i *Injector) Rules() []Rule {
+	if i == nil {
+		return nil
+	}
+	return append([]Rule(nil), i.rules...)
+}
+
+// rule returns the first rule for target matching any of subjects, or nil when the call should
+// go through, rolling each rule's rate
+func (i *Injector) rule(target string, subjects []string) *Rule {
+	for n := range i.rules {
+		rule := &i.rules[n]
+		if rule.Target != target || !matches(rule.Match, subjects) {
+			continue
+		}
+		if rule.Rate < 1 {
+			i.mu.Lock()
+			roll := i.rand.Float64()
+			i.mu.Unlock()
+			if roll >= rule.Rate {
+				return nil
+			}
+		}
+		return rule
+	}
+	return nil
+}
+
+func matches(match string, subjects []string) bool {
+	if match == "" {
+		return true
+	}
+	for _, subject := range subjects {
+		if strings.Contains(subject, match) {
+			return true
+		}
+	}
+	return false
+}
+
+// Inject runs the fault for a call to target, if any rule picks it, and returns the error the
+// call should fail with. Delays return nil once they have passed.
+func (i *Injector) Inject(ctx context.Context, target string, subjects ...string) error {
+	if i == nil {
+		return nil
+	}
+	rule := i.rule(target, subjects)
+	if rule == nil {
+		return nil
+	}
+	faultsInjected.WithLabelValues(target, rule.Fault).Inc()
+
+	switch rule.Fault {
+	case FaultError:
+		return fmt.Errorf("%w: %s error", ErrInjected, target)
+	case FaultTimeout:
+		if err := wait(ctx, rule.Delay); err != nil {
+			return &timeoutError{target: target, err: err}
+		}
+		return &timeoutError{target: target, err: context.DeadlineExceeded}
+	default:
+		return wait(ctx, rule.Delay)
+	}
+}
+
+// wait sleeps for d, returning early with the context's error if it ends first
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// timeoutError is a timeout fault. Like a net/http timeout it reports Timeout() and matches
+// context.DeadlineExceeded, as well as ErrInjected.
+type timeoutError struct {
+	target string
+	err    error
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("%s: %s timed out: %v", ErrInjected, e.target, e.err)
+}
+
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return true }
+
+func (e *timeoutError) Unwrap() []error {
+	return []error{ErrInjected, e.err}
+}
+
+// Transport wraps base (nil for http.DefaultTransport) so requests can fail or slow down as
+// target, matched on the request path
+func (i *Injector) Transport(target string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{injector: i, target: target, base: base}
+}
+
+type transport struct {
+	injector *Injector
+	target   string
+	base     http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.injector.Inject(req.Context(), t.target, req.URL.Path); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type AccountHandler struct {
+	accountService *services.AccountService
+}
+
+func NewAccountHandler(accountService *services.AccountService) *AccountHandler {
+	return &AccountHandler{
+		accountService: accountService,
+	}
+}
+
+// accountUserID reads the :id path parameter of the admin account routes
+func accountUserID(c *fiber.Ctx) (uuid.UUID, error) {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return uuid.Nil, fiber.NewError(fiber.StatusBadRequest, "Invalid user ID format")
+	}
+	return userID, nil
+}
+
+// GetAccountStatus handles GET /ngs/admin/users/:id/account (admin)
+func (h *AccountHandler) GetAccountStatus(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+	userID, err := accountUserID(c)
+	if err != nil {
+		return err
+	}
+
+	status, err := h.accountService.GetAccountStatus(userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(status)
+}
+
+// DeactivateAccount handles POST /ngs/admin/users/:id/deactivate (admin)
+func (h *AccountHandler) DeactivateAccount(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+	userID, err := accountUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.DeactivateAccountRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	status, err := h.accountService.DeactivateAccount(userID, req.Reason, services.AccountSourceAdmin, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(status)
+}
+
+// ReactivateAccount handles POST /ngs/admin/users/:id/reactivate (admin)
+func (h *AccountHandler) ReactivateAccount(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+	userID, err := accountUserID(c)
+	if err != nil {
+		return err
+	}
+
+	status, err := h.accountService.ReactivateAccount(userID, services.AccountSourceAdmin, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(status)
+}
+
+// HandleAccountEvent handles POST /ngs/internal/account-events
+// Called by the gateway with the ACCOUNT_EVENTS_TOKEN shared secret in X-Internal-Token
+func (h *AccountHandler) HandleAccountEvent(c *fiber.Ctx) error {
+	err := h.accountService.CheckAccountEventToken(c.Get("X-Internal-Token"))
+	if errors.Is(err, services.ErrAccountEventsDisabled) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var event models.AccountEvent
+	if err := c.BodyParser(&event); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	status, err := h.accountService.HandleAccountEvent(event)
+	if errors.Is(err, services.ErrInvalidAccountEvent) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(status)
+}
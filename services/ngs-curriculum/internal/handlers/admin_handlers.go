@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AdminHandler struct {
+	economyService *services.EconomyService
+	importService  *services.ImportService
+	contentService *services.ContentImportService
+}
+
+func NewAdminHandler(economyService *services.EconomyService, importService *services.ImportService, contentService *services.ContentImportService) *AdminHandler {
+	return &AdminHandler{
+		economyService: economyService,
+		importService:  importService,
+		contentService: contentService,
+	}
+}
+
+// SimulateXPCurve handles POST /ngs/admin/simulate/xp-curve
+// Read-only: projects the level distribution without changing any thresholds
+func (h *AdminHandler) SimulateXPCurve(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	var req models.XPCurveSimulationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	simulation, err := h.economyService.SimulateXPCurve(req)
+	if errors.Is(err, services.ErrInvalidSimulation) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		log.Printf("Error simulating XP curve: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to simulate XP curve",
+		})
+	}
+
+	return c.JSON(simulation)
+}
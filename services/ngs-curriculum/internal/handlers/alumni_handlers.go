@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AlumniHandler struct {
+	alumniService *services.AlumniService
+}
+
+func NewAlumniHandler(alumniService *services.AlumniService) *AlumniHandler {
+	return &AlumniHandler{
+		alumniService: alumniService,
+	}
+}
+
+// GetFeed handles GET /ngs/alumni/feed
+func (h *AlumniHandler) GetFeed(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	feed, err := h.alumniService.GetFeed(userID)
+	if errors.Is(err, services.ErrNotAlumni) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		log.Printf("Error getting alumni feed for user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get alumni feed",
+		})
+	}
+	return c.JSON(feed)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"fmt"
+
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AnalyticsHandler struct {
+	analyticsService *services.AnalyticsService
+}
+
+func NewAnalyticsHandler(analyticsService *services.AnalyticsService) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		analyticsService: analyticsService,
+	}
+}
+
+// GetCohortAnalytics handles GET /ngs/analytics/cohorts/:id (the cohort's educator)
+// Query: stuck_days a started lesson goes untouched before a member counts as stuck, weeks of
+// reflection quality trend
+func (h *AnalyticsHandler) GetCohortAnalytics(c *fiber.Ctx) error {
+	educatorID, cohortID, err := cohortParams(c)
+	if err != nil {
+		return err
+	}
+
+	stuckDays := c.QueryInt("stuck_days", services.DefaultStuckAfterDays)
+	weeks := c.QueryInt("weeks", services.DefaultTrendWeeks)
+	if stuckDays < 1 || stuckDays > services.MaxStuckAfterDays || weeks < 1 || weeks > services.MaxTrendWeeks {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("stuck_days must be 1-%d and weeks 1-%d", services.MaxStuckAfterDays, services.MaxTrendWeeks),
+		})
+	}
+
+	analytics, err := h.analyticsService.GetCohortAnalytics(cohortID, educatorID, stuckDays, weeks)
+	if err != nil {
+		return cohortError(c, err)
+	}
+	return c.JSON(analytics)
+}
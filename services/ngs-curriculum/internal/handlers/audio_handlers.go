@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type AudioHandler struct {
+	audioService *services.AudioService
+}
+
+func NewAudioHandler(audioService *services.AudioService) *AudioHandler {
+	return &AudioHandler{
+		audioService: audioService,
+	}
+}
+
+// audioError maps audio service errors to HTTP responses
+func audioError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrLessonNotFound), errors.Is(err, services.ErrAudioNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrLevelLocked):
+		return levelLocked(c)
+	case errors.Is(err, services.ErrAudioLinkInvalid):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrAudioUnavailable):
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("Audio error: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to get lesson audio",
+	})
+}
+
+// GetLessonAudio handles GET /ngs/lessons/:id/audio
+// Returns a signed, expiring URL; the first request for a content version renders the audio
+func (h *AudioHandler) GetLessonAudio(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	lessonID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid lesson ID format",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+
+	audio, err := h.audioService.GetLessonAudio(ctx, lessonID, userID)
+	if err != nil {
+		return audioError(c, err)
+	}
+
+	return c.JSON(audio)
+}
+
+// StreamAudio handles GET /ngs/audio/:id?expires=...&signature=...
+// The signature stands in for user headers so the URL works in plain audio players
+func (h *AudioHandler) StreamAudio(c *fiber.Ctx) error {
+	audioID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid audio ID format",
+		})
+	}
+
+	expires := int64(c.QueryInt("expires", 0))
+	audio, path, err := h.audioService.OpenAudio(audioID, expires, c.Query("signature"))
+	if err != nil {
+		return audioError(c, err)
+	}
+
+	c.Set(fiber.HeaderContentType, audio.ContentType)
+	c.Set(fiber.HeaderCacheControl, "private, max-age=300")
+	return c.SendFile(path)
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AuthoringHandler struct {
+	authoringService *services.LessonAuthoringService
+}
+
+func NewAuthoringHandler(authoringService *services.LessonAuthoringService) *AuthoringHandler {
+	return &AuthoringHandler{
+		authoringService: authoringService,
+	}
+}
+
+// authoringError maps lesson authoring service errors to HTTP responses
+func authoringError(c *fiber.Ctx, err error) error {
+	var prereqErr *services.PrerequisiteError
+	switch {
+	case errors.As(err, &prereqErr):
+		return prerequisiteErrorResponse(c, prereqErr)
+	case errors.Is(err, services.ErrLessonNotFound), errors.Is(err, services.ErrCohortNotFound),
+		errors.Is(err, services.ErrTemplateNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrNotCohortEducator):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrOverlayExists):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidAuthoring), errors.Is(err, services.ErrInvalidAgeBand):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("Lesson authoring error: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to author lesson",
+	})
+}
+
+// CloneLesson handles POST /ngs/admin/lessons/:id/clone (educator or admin)
+func (h *AuthoringHandler) CloneLesson(c *fiber.Ctx) error {
+	educatorID, err := getEducatorID(c)
+	if err != nil {
+		return err
+	}
+
+	lessonID, err := lessonIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.CloneLessonRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	lesson, err := h.authoringService.CloneLesson(lessonID, educatorID, req)
+	if err != nil {
+		return authoringError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(lesson)
+}
+
+// GetLessonTemplates handles GET /ngs/admin/lesson-templates (educator or admin)
+func (h *AuthoringHandler) GetLessonTemplates(c *fiber.Ctx) error {
+	if _, err := getEducatorID(c); err != nil {
+		return err
+	}
+
+	return c.JSON(listResponse("templates", services.LessonTemplates(), false, nil))
+}
+
+// CreateLessonFromTemplate handles POST /ngs/admin/lessons/from-template (educator or admin)
+func (h *AuthoringHandler) CreateLessonFromTemplate(c *fiber.Ctx) error {
+	educatorID, err := getEducatorID(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.CreateLessonFromTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	lesson, err := h.authoringService.CreateLessonFromTemplate(educatorID, req)
+	if err != nil {
+		return authoringError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(lesson)
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BodyLimit rejects request bodies larger than maxBytes with 413. The declared Content-Length is
+// checked first so an oversize body is refused before any handler parses it. Paths under an
+// exempt prefix are left to a route-level BodyLimit of their own; a "*" segment in a prefix
+// matches any one path segment, as in "/ngs/challenges/*/design".
+func BodyLimit(maxBytes int, exemptPrefixes ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		for _, prefix := range exemptPrefixes {
+			if hasPathPrefix(c.Path(), prefix) {
+				return c.Next()
+			}
+		}
+
+		if c.Request().Header.ContentLength() > maxBytes || len(c.Body()) > maxBytes {
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+				"error":     "Request body too large",
+				"max_bytes": maxBytes,
+			})
+		}
+		return c.Next()
+	}
+}
+
+// hasPathPrefix reports whether path starts with prefix. A prefix with a "*" segment matches
+// whole segments, "*" matching any one non-empty segment.
+func hasPathPrefix(path, prefix string) bool {
+	if !strings.Contains(prefix, "*") {
+		return strings.HasPrefix(path, prefix)
+	}
+
+	pathSegments := strings.Split(path, "/")
+	prefixSegments := strings.Split(prefix, "/")
+	if len(pathSegments) < len(prefixSegments) {
+		return false
+	}
+	for i, segment := range prefixSegments {
+		if segment == "*" && pathSegments[i] != "" {
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return true
+}
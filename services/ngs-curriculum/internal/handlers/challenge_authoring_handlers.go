@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type ChallengeAuthoringHandler struct {
+	authoringService *services.ChallengeAuthoringService
+}
+
+func NewChallengeAuthoringHandler(authoringService *services.ChallengeAuthoringService) *ChallengeAuthoringHandler {
+	return &ChallengeAuthoringHandler{
+		authoringService: authoringService,
+	}
+}
+
+// challengeAuthoringError maps challenge authoring service errors to HTTP responses
+func challengeAuthoringError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrChallengeNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidChallenge), errors.Is(err, services.ErrInvalidAgeBand):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("Challenge authoring error: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to author challenge",
+	})
+}
+
+// challengeIDParam parses the :id challenge path parameter
+func challengeIDParam(c *fiber.Ctx) (uuid.UUID, error) {
+	challengeID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return uuid.Nil, fiber.NewError(fiber.StatusBadRequest, "Invalid challenge ID format")
+	}
+	return challengeID, nil
+}
+
+// ListChallenges handles GET /ngs/admin/challenges (educator or admin)
+// Query: level filters by level, include_inactive=true lists deactivated challenges too
+func (h *ChallengeAuthoringHandler) ListChallenges(c *fiber.Ctx) error {
+	if _, err := getEducatorID(c); err != nil {
+		return err
+	}
+
+	level := c.QueryInt("level", 0)
+	if level < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid level",
+		})
+	}
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	challenges, err := h.authoringService.ListChallenges(level, c.QueryBool("include_inactive", false), limit+1)
+	if err != nil {
+		return challengeAuthoringError(c, err)
+	}
+	challenges, hasMore := trimPage(challenges, limit)
+	return c.JSON(listResponse("challenges", challenges, hasMore, nil))
+}
+
+// GetTestCaseSchema handles GET /ngs/admin/challenges/test-case-schema (educator or admin)
+func (h *ChallengeAuthoringHandler) GetTestCaseSchema(c *fiber.Ctx) error {
+	if _, err := getEducatorID(c); err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, "application/schema+json")
+	return c.Send(services.TestCaseSchema())
+}
+
+// GetChallenge handles GET /ngs/admin/challenges/:id (educator or admin)
+// Unlike the learner route, it returns hidden test cases, the solution and inactive challenges
+func (h *ChallengeAuthoringHandler) GetChallenge(c *fiber.Ctx) error {
+	if _, err := getEducatorID(c); err != nil {
+		return err
+	}
+
+	challengeID, err := challengeIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	challenge, err := h.authoringService.GetChallenge(challengeID)
+	if err != nil {
+		return challengeAuthoringError(c, err)
+	}
+	return c.JSON(challenge)
+}
+
+// CreateChallenge handles POST /ngs/admin/challenges (educator or admin)
+func (h *ChallengeAuthoringHandler) CreateChallenge(c *fiber.Ctx) error {
+	educatorID, err := getEducatorID(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.ChallengeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	challenge, err := h.authoringService.CreateChallenge(educatorID, req)
+	if err != nil {
+		return challengeAuthoringError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(challenge)
+}
+
+// UpdateChallenge handles PUT /ngs/admin/challenges/:id (educator or admin)
+// Fields left out of the body keep their values; test_cases, when sent, replace them all
+func (h *ChallengeAuthoringHandler) UpdateChallenge(c *fiber.Ctx) error {
+	educatorID, err := getEducatorID(c)
+	if err != nil {
+		return err
+	}
+
+	challengeID, err := challengeIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.ChallengeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	challenge, err := h.authoringService.UpdateChallenge(educatorID, challengeID, req)
+	if err != nil {
+		return challengeAuthoringError(c, err)
+	}
+	return c.JSON(challenge)
+}
+
+// DeactivateChallenge handles POST /ngs/admin/challenges/:id/deactivate (educator or admin)
+func (h *ChallengeAuthoringHandler) DeactivateChallenge(c *fiber.Ctx) error {
+	return h.setActive(c, false)
+}
+
+// ReactivateChallenge handles POST /ngs/admin/challenges/:id/reactivate (educator or admin)
+func (h *ChallengeAuthoringHandler) ReactivateChallenge(c *fiber.Ctx) error {
+	return h.setActive(c, true)
+}
+
+func (h *ChallengeAuthoringHandler) setActive(c *fiber.Ctx, active bool) error {
+	educatorID, err := getEducatorID(c)
+	if err != nil {
+		return err
+	}
+
+	challengeID, err := challengeIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	challenge, err := h.authoringService.SetChallengeActive(educatorID, challengeID, active)
+	if err != nil {
+		return challengeAuthoringError(c, err)
+	}
+	return c.JSON(challenge)
+}
@@ -1,8 +1,16 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
 	"strconv"
+	"strings"
 
+	"noble-ngs-curriculum/internal/api"
+	"noble-ngs-curriculum/internal/middleware"
 	"noble-ngs-curriculum/internal/models"
 	"noble-ngs-curriculum/internal/services"
 
@@ -38,19 +46,29 @@ func (h *ChallengeHandler) GetChallengesByLevel(c *fiber.Ctx) error {
 		})
 	}
 
+	// Anonymous requests fall back to the adult band
+	userID, _ := uuid.Parse(middleware.GetIdentity(c).UserID)
+
+	// Optional comma-separated tag filter, e.g. ?tags=loops,recursion
+	var tags []string
+	if tagsStr := c.Query("tags"); tagsStr != "" {
+		tags = strings.Split(tagsStr, ",")
+	}
+
 	// Get challenges
-	challenges, err := h.challengeService.GetChallengesByLevel(level)
+	challenges, err := h.challengeService.GetChallengesByLevel(level, userID, tags)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"level":      level,
-		"challenges": challenges,
-		"count":      len(challenges),
-	})
+	// Solutions are only revealed through GET /ngs/challenges/:id/solutions
+	for i := range challenges {
+		challenges[i].SolutionTemplate = ""
+	}
+
+	return c.JSON(listResponse("challenges", challenges, false, fiber.Map{"level": level}))
 }
 
 // GetChallenge handles GET /ngs/challenges/:id
@@ -72,13 +90,157 @@ func (h *ChallengeHandler) GetChallenge(c *fiber.Ctx) error {
 		})
 	}
 
+	// Spoiler protection: keep the solution hidden until the user has passed
+	revealSolution := false
+	if userID, err := uuid.Parse(middleware.GetIdentity(c).UserID); err == nil {
+		allowed, err := h.challengeService.ChallengeAllowedFor(challengeID, userID)
+		if err != nil {
+			log.Printf("Error checking age band for challenge %s: %v", challengeID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to get challenge",
+			})
+		}
+		if !allowed {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "challenge not found",
+			})
+		}
+		revealSolution, _ = h.challengeService.HasPassedChallenge(userID, challengeID)
+	}
+	if !revealSolution {
+		challenge.SolutionTemplate = ""
+	}
+
 	return c.JSON(challenge)
 }
 
+// GetSolutions handles GET /ngs/challenges/:id/solutions
+func (h *ChallengeHandler) GetSolutions(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	challengeID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid challenge ID format",
+		})
+	}
+
+	limit := c.QueryInt("limit", 10)
+	if limit > 50 {
+		limit = 50
+	}
+
+	solutions, err := h.challengeService.GetSolutions(challengeID, userID, limit)
+	if errors.Is(err, services.ErrSolutionLocked) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		log.Printf("Error getting solutions for challenge %s: %v", challengeID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get solutions",
+		})
+	}
+
+	return c.JSON(solutions)
+}
+
+// GetRuntimeLeaderboard handles GET /ngs/challenges/:id/leaderboard
+func (h *ChallengeHandler) GetRuntimeLeaderboard(c *fiber.Ctx) error {
+	challengeID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid challenge ID format",
+		})
+	}
+
+	limit := c.QueryInt("limit", 10)
+	if limit < 1 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	entries, err := h.challengeService.GetRuntimeLeaderboard(challengeID, limit+1)
+	if errors.Is(err, services.ErrChallengeNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if errors.Is(err, services.ErrNotOptimizationChallenge) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		log.Printf("Error getting runtime leaderboard for challenge %s: %v", challengeID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get leaderboard",
+		})
+	}
+
+	entries, hasMore := trimPage(entries, limit)
+	return c.JSON(listResponse("leaderboard", entries, hasMore, fiber.Map{
+		"challenge_id": challengeID,
+	}))
+}
+
+// ShareSubmission handles PUT /ngs/challenges/submissions/:id/share
+func (h *ChallengeHandler) ShareSubmission(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	submissionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid submission ID format",
+		})
+	}
+
+	var req struct {
+		Shared bool `json:"shared"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	err = h.challengeService.SetSubmissionShared(userID, submissionID, req.Shared)
+	if errors.Is(err, services.ErrSubmissionNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Passing submission not found",
+		})
+	}
+	if errors.Is(err, services.ErrRestrictedForMinor) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		log.Printf("Error updating sharing for submission %s: %v", submissionID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update submission sharing",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"submission_id": submissionID,
+		"is_shared":     req.Shared,
+	})
+}
+
 // SubmitChallenge handles POST /ngs/challenges/:id/submit
 func (h *ChallengeHandler) SubmitChallenge(c *fiber.Ctx) error {
-	// Get user ID from header
-	userIDStr := c.Get("X-User-Id")
+	// Get user ID from the request identity
+	userIDStr := middleware.GetIdentity(c).UserID
 	if userIDStr == "" {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Missing user ID",
@@ -112,8 +274,8 @@ func (h *ChallengeHandler) SubmitChallenge(c *fiber.Ctx) error {
 	// Set challenge ID from path
 	req.ChallengeID = challengeID
 
-	// Validate submission code
-	if req.SubmissionCode == "" {
+	// Validate submission; reflection challenges take reflection_text instead of code
+	if req.SubmissionCode == "" && req.ReflectionText == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Submission code is required",
 		})
@@ -121,22 +283,36 @@ func (h *ChallengeHandler) SubmitChallenge(c *fiber.Ctx) error {
 
 	// Submit challenge
 	submission, err := h.challengeService.SubmitChallenge(userID, req)
+	if errors.Is(err, services.ErrSubmissionTooLarge) {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if errors.Is(err, services.ErrDesignSubmissionRequired) || errors.Is(err, services.ErrCollaborationRequired) ||
+		errors.Is(err, services.ErrEmptySubmission) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
+	recordUsage(c, services.MeterSandboxMs, int64(submission.RuntimeMs))
+	recordUsage(c, services.MeterStorageBytes, int64(len(req.SubmissionCode)+len(req.ReflectionText)))
+
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"submission": submission,
 		"message":    "Challenge submission processed",
 	})
 }
 
-// GetUserSubmissions handles GET /ngs/challenges/submissions
+// GetUserSubmissions handles GET /ngs/challenges/submissions?passed=&from=&to=&cursor=&limit=
 func (h *ChallengeHandler) GetUserSubmissions(c *fiber.Ctx) error {
-	// Get user ID from header
-	userIDStr := c.Get("X-User-Id")
+	// Get user ID from the request identity
+	userIDStr := middleware.GetIdentity(c).UserID
 	if userIDStr == "" {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Missing user ID",
@@ -150,22 +326,234 @@ func (h *ChallengeHandler) GetUserSubmissions(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get limit from query parameter
+	page, err := api.ParsePage(c)
+	if err != nil {
+		return pageError(c, err)
+	}
+	passed, err := api.QueryBool(c, "passed")
+	if err != nil {
+		return pageError(c, err)
+	}
+
+	// Get submissions
+	submissions, err := h.challengeService.GetUserSubmissions(userID, page.Fetch(), passed)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	submissions, hasMore, extra := nextPage(submissions, page, func(s models.ChallengeSubmission) api.Cursor {
+		return api.Cursor{At: s.SubmittedAt, ID: s.ID}
+	})
+	return c.JSON(listResponse("submissions", submissions, hasMore, extra))
+}
+
+// SubmitDesign handles POST /ngs/challenges/:id/design, a multipart form with a markdown
+// "writeup" field and any number of "assets" files
+func (h *ChallengeHandler) SubmitDesign(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	challengeID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid challenge ID format",
+		})
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Expected a multipart/form-data body",
+		})
+	}
+
+	var writeup string
+	if values := form.Value["writeup"]; len(values) > 0 {
+		writeup = values[0]
+	}
+	var uploads []services.DesignUpload
+	for _, file := range form.File["assets"] {
+		data, err := readFormFile(file)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to read asset %q", file.Filename),
+			})
+		}
+		uploads = append(uploads, services.DesignUpload{FileName: file.Filename, Data: data})
+	}
+
+	identity := middleware.GetIdentity(c)
+	submission, err := h.challengeService.SubmitDesign(userID, identity.Email, identity.Role, challengeID, writeup, uploads)
+	switch {
+	case errors.Is(err, services.ErrChallengeNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrNotDesignChallenge), errors.Is(err, services.ErrInvalidDesignSubmission):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrSubmissionTooLarge):
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrDesignAssetsUnavailable):
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case err != nil:
+		log.Printf("Error submitting design for challenge %s: %v", challengeID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to submit design",
+		})
+	}
+
+	stored := len(writeup)
+	for _, upload := range uploads {
+		stored += len(upload.Data)
+	}
+	recordUsage(c, services.MeterStorageBytes, int64(stored))
+
+	message := "Design submitted for review"
+	if submission.Status == models.ReviewGraded {
+		message = "Design submission evaluated"
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"submission": submission,
+		"message":    message,
+	})
+}
+
+// readFormFile reads an uploaded multipart file into memory
+func readFormFile(file *multipart.FileHeader) ([]byte, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// GetReviewQueue handles GET /ngs/challenges/submissions/review-queue (educators)
+func (h *ChallengeHandler) GetReviewQueue(c *fiber.Ctx) error {
+	if _, err := getEducatorID(c); err != nil {
+		return err
+	}
+
 	limit := c.QueryInt("limit", 20)
+	if limit < 1 {
+		limit = 20
+	}
 	if limit > 100 {
 		limit = 100
 	}
 
-	// Get submissions
-	submissions, err := h.challengeService.GetUserSubmissions(userID, limit)
+	submissions, err := h.challengeService.GetReviewQueue(limit + 1)
 	if err != nil {
+		log.Printf("Error getting design review queue: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get review queue",
+		})
+	}
+
+	submissions, hasMore := trimPage(submissions, limit)
+	return c.JSON(listResponse("submissions", submissions, hasMore, nil))
+}
+
+// ReviewSubmission handles POST /ngs/challenges/submissions/:id/review (educators)
+func (h *ChallengeHandler) ReviewSubmission(c *fiber.Ctx) error {
+	reviewerID, err := getEducatorID(c)
+	if err != nil {
+		return err
+	}
+
+	submissionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid submission ID format",
+		})
+	}
+
+	var req models.ReviewDesignRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	submission, err := h.challengeService.ReviewDesignSubmission(submissionID, reviewerID, req)
+	switch {
+	case errors.Is(err, services.ErrSubmissionNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrSubmissionReviewed):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidReview):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
+	case err != nil:
+		log.Printf("Error reviewing submission %s: %v", submissionID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to review submission",
+		})
 	}
 
 	return c.JSON(fiber.Map{
-		"submissions": submissions,
-		"count":       len(submissions),
+		"submission": submission,
 	})
 }
+
+// GetDesignAsset handles GET /ngs/challenges/submissions/:id/assets/:asset_id for the submitter
+// and educators
+func (h *ChallengeHandler) GetDesignAsset(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	submissionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid submission ID format",
+		})
+	}
+	assetID, err := uuid.Parse(c.Params("asset_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid asset ID format",
+		})
+	}
+
+	role := middleware.GetIdentity(c).Role
+	asset, data, err := h.challengeService.GetDesignAsset(submissionID, assetID, userID, role == "educator" || role == "admin")
+	switch {
+	case errors.Is(err, services.ErrSubmissionNotFound), errors.Is(err, services.ErrAssetNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrDesignAssetsUnavailable):
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case err != nil:
+		log.Printf("Error getting asset %s of submission %s: %v", assetID, submissionID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get asset",
+		})
+	}
+
+	// Served as a download so an upload is never rendered in the app's origin
+	c.Attachment(asset.FileName)
+	c.Set(fiber.HeaderContentType, asset.ContentType)
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	c.Set(fiber.HeaderCacheControl, "private, max-age=300")
+	return c.Send(data)
+}
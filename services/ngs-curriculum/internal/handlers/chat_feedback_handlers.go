@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type ChatFeedbackHandler struct {
+	feedbackService *services.ChatFeedbackService
+}
+
+func NewChatFeedbackHandler(feedbackService *services.ChatFeedbackService) *ChatFeedbackHandler {
+	return &ChatFeedbackHandler{
+		feedbackService: feedbackService,
+	}
+}
+
+// chatFeedbackError maps chat feedback and ticket service errors to HTTP responses
+func chatFeedbackError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrChatMessageNotFound), errors.Is(err, services.ErrTicketNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidChatFeedback), errors.Is(err, services.ErrInvalidTicket):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrTicketClaimed), errors.Is(err, services.ErrTicketResolved):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("Chat feedback error: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to process chat feedback",
+	})
+}
+
+// SetFeedback handles PUT /ngs/chat/messages/:id/feedback
+func (h *ChatFeedbackHandler) SetFeedback(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+	messageID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid message ID format",
+		})
+	}
+
+	var req models.ChatFeedbackRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	feedback, err := h.feedbackService.SetFeedback(userID, messageID, req)
+	if err != nil {
+		return chatFeedbackError(c, err)
+	}
+	return c.JSON(feedback)
+}
+
+// Escalate handles POST /ngs/chat/messages/:id/escalate
+// Returns 201 with a new ticket, or 200 with the ticket of an answer escalated before
+func (h *ChatFeedbackHandler) Escalate(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+	messageID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid message ID format",
+		})
+	}
+
+	var req models.EscalateChatRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	ticket, created, err := h.feedbackService.Escalate(userID, messageID, req)
+	if err != nil {
+		return chatFeedbackError(c, err)
+	}
+	if created {
+		c.Status(fiber.StatusCreated)
+	}
+	return c.JSON(ticket)
+}
+
+// ListEscalations handles GET /ngs/chat/escalations
+func (h *ChatFeedbackHandler) ListEscalations(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	tickets, err := h.feedbackService.ListUserTickets(userID, limit+1)
+	if err != nil {
+		log.Printf("Error listing escalations for user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list escalations",
+		})
+	}
+	tickets, hasMore := trimPage(tickets, limit)
+	return c.JSON(listResponse("tickets", tickets, hasMore, nil))
+}
+
+// ListTickets handles GET /ngs/educator/tickets?status=open&mine=true (educators)
+func (h *ChatFeedbackHandler) ListTickets(c *fiber.Ctx) error {
+	educatorID, err := getEducatorID(c)
+	if err != nil {
+		return err
+	}
+
+	var assignedTo uuid.NullUUID
+	if c.QueryBool("mine", false) {
+		assignedTo = uuid.NullUUID{UUID: educatorID, Valid: true}
+	}
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	tickets, err := h.feedbackService.ListTickets(c.Query("status", services.TicketOpen), assignedTo, limit+1)
+	if err != nil {
+		return chatFeedbackError(c, err)
+	}
+	tickets, hasMore := trimPage(tickets, limit)
+	return c.JSON(listResponse("tickets", tickets, hasMore, nil))
+}
+
+// ClaimTicket handles POST /ngs/educator/tickets/:id/claim (educators)
+func (h *ChatFeedbackHandler) ClaimTicket(c *fiber.Ctx) error {
+	educatorID, err := getEducatorID(c)
+	if err != nil {
+		return err
+	}
+	ticketID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ticket ID format",
+		})
+	}
+
+	override := middleware.GetIdentity(c).Role == "admin"
+	ticket, err := h.feedbackService.ClaimTicket(ticketID, educatorID, override)
+	if err != nil {
+		return chatFeedbackError(c, err)
+	}
+	return c.JSON(ticket)
+}
+
+// ResolveTicket handles POST /ngs/educator/tickets/:id/resolve (educators)
+func (h *ChatFeedbackHandler) ResolveTicket(c *fiber.Ctx) error {
+	educatorID, err := getEducatorID(c)
+	if err != nil {
+		return err
+	}
+	ticketID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ticket ID format",
+		})
+	}
+
+	var req models.ResolveTicketRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	override := middleware.GetIdentity(c).Role == "admin"
+	ticket, err := h.feedbackService.ResolveTicket(ticketID, educatorID, req, override)
+	if err != nil {
+		return chatFeedbackError(c, err)
+	}
+	return c.JSON(ticket)
+}
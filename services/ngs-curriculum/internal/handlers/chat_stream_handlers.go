@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"noble-ngs-curriculum/internal/clients/intelligence"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// chatStreamTimeout bounds a streamed tutor chat answer
+const chatStreamTimeout = 2 * time.Minute
+
+// errLearnerGone is returned when a streamed event cannot be written to the learner
+var errLearnerGone = errors.New("learner disconnected")
+
+var chatStreams = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ngs_chat_streams_total",
+		Help: "Streamed tutor chat answers by outcome: completed, cancelled (the learner disconnected) or failed.",
+	},
+	[]string{"outcome"},
+)
+
+func init() {
+	prometheus.MustRegister(chatStreams)
+}
+
+// StreamEducatorChatMessage handles POST /ngs/lessons/:id/chat/stream
+// Takes the same body as the chat endpoint and answers with server-sent events as the tutor
+// writes: {"content": "...", "done": false} for each piece, then {"done": true, ...} with the
+// session_id, tokens_used and message_id, or {"error": "...", "done": true}. When the learner
+// disconnects, the request to the intelligence service is cancelled so it stops generating.
+func (h *LessonHandler) StreamEducatorChatMessage(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+	lessonID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid lesson ID format",
+		})
+	}
+
+	var req struct {
+		Message   string     `json:"message"`
+		SessionID *uuid.UUID `json:"session_id,omitempty"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if req.Message == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Message is required",
+		})
+	}
+	if refused, err := h.chatLessonRefused(c, lessonID, userID); refused {
+		return err
+	}
+
+	chatReq := h.educatorChatRequest(userID, lessonID, req.Message, req.SessionID)
+	// The stream is written after the handler returns, when the request's buffers are reused
+	identity := middleware.GetIdentity(c)
+	userEmail, userRole := strings.Clone(identity.Email), strings.Clone(identity.Role)
+	correlationID := strings.Clone(c.Get("X-Correlation-ID"))
+	orgID := strings.Clone(identity.OrgID)
+	record := usageRecorder(c)
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set("X-Accel-Buffering", "no")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithTimeout(context.Background(), chatStreamTimeout)
+		defer cancel()
+		if correlationID != "" {
+			ctx = context.WithValue(ctx, "correlation_id", correlationID)
+		}
+		ctx = intelligence.WithOrg(ctx, orgID)
+
+		chatResp, err := h.intelligenceClient.StreamEducatorChatMessage(ctx, chatReq, userID.String(), userEmail, userRole, func(content string) error {
+			return writeChatEvent(w, fiber.Map{"content": content, "done": false})
+		})
+		if errors.Is(err, errLearnerGone) {
+			chatStreams.WithLabelValues("cancelled").Inc()
+			return
+		}
+		if err != nil {
+			chatStreams.WithLabelValues("failed").Inc()
+			log.Printf("Error streaming chat message for user %s: %v", userID, err)
+			_ = writeChatEvent(w, fiber.Map{"error": "Failed to send chat message", "done": true})
+			return
+		}
+
+		record(services.MeterLLMTokens, int64(chatResp.TokensUsed))
+		done := fiber.Map{
+			"content":     "",
+			"done":        true,
+			"session_id":  chatResp.SessionID,
+			"lesson_id":   chatResp.LessonID,
+			"tokens_used": chatResp.TokensUsed,
+			"provider":    chatResp.Provider,
+			"latency_ms":  chatResp.LatencyMs,
+		}
+		if messageID, ok := h.recordChat(userID, lessonID, req.Message, chatResp); ok {
+			done["message_id"] = messageID
+		}
+		if err := writeChatEvent(w, done); err != nil {
+			chatStreams.WithLabelValues("cancelled").Inc()
+			return
+		}
+		chatStreams.WithLabelValues("completed").Inc()
+	})
+	return nil
+}
+
+// writeChatEvent writes one server-sent event and flushes it to the learner, returning
+// errLearnerGone when they have disconnected
+func writeChatEvent(w *bufio.Writer, event fiber.Map) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode chat event: %w", err)
+	}
+	if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+		return fmt.Errorf("%w: %v", errLearnerGone, err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("%w: %v", errLearnerGone, err)
+	}
+	return nil
+}
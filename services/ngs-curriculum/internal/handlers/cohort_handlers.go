@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type CohortHandler struct {
+	cohortService *services.CohortService
+}
+
+func NewCohortHandler(cohortService *services.CohortService) *CohortHandler {
+	return &CohortHandler{
+		cohortService: cohortService,
+	}
+}
+
+// cohortError maps cohort service errors to HTTP responses
+func cohortError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrCohortNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrNotCohortEducator):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCohort), errors.Is(err, services.ErrInvalidAgeBand),
+		errors.Is(err, services.ErrInvalidCurriculum):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("Cohort error: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to process cohort",
+	})
+}
+
+// cohortParams returns the calling educator and the :id cohort
+func cohortParams(c *fiber.Ctx) (uuid.UUID, uuid.UUID, error) {
+	educatorID, err := getEducatorID(c)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	cohortID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, fiber.NewError(fiber.StatusBadRequest, "Invalid cohort ID format")
+	}
+
+	return educatorID, cohortID, nil
+}
+
+// CreateCohort handles POST /ngs/cohorts
+func (h *CohortHandler) CreateCohort(c *fiber.Ctx) error {
+	educatorID, err := getEducatorID(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.CreateCohortRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	cohort, err := h.cohortService.CreateCohort(educatorID, req)
+	if err != nil {
+		return cohortError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(cohort)
+}
+
+// GetCohort handles GET /ngs/cohorts/:id
+func (h *CohortHandler) GetCohort(c *fiber.Ctx) error {
+	educatorID, cohortID, err := cohortParams(c)
+	if err != nil {
+		return err
+	}
+
+	cohort, err := h.cohortService.GetCohort(cohortID, educatorID)
+	if err != nil {
+		return cohortError(c, err)
+	}
+
+	return c.JSON(cohort)
+}
+
+// AddMember handles POST /ngs/cohorts/:id/members
+func (h *CohortHandler) AddMember(c *fiber.Ctx) error {
+	educatorID, cohortID, err := cohortParams(c)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.UserID == uuid.Nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "user_id is required",
+		})
+	}
+
+	cohort, err := h.cohortService.AddMember(cohortID, educatorID, req.UserID)
+	if err != nil {
+		return cohortError(c, err)
+	}
+
+	return c.JSON(cohort)
+}
+
+// RemoveMember handles DELETE /ngs/cohorts/:id/members/:userId
+func (h *CohortHandler) RemoveMember(c *fiber.Ctx) error {
+	educatorID, cohortID, err := cohortParams(c)
+	if err != nil {
+		return err
+	}
+
+	userID, err := uuid.Parse(c.Params("userId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID format",
+		})
+	}
+
+	cohort, err := h.cohortService.RemoveMember(cohortID, educatorID, userID)
+	if err != nil {
+		return cohortError(c, err)
+	}
+
+	return c.JSON(cohort)
+}
+
+// SetContentAgeBand handles PUT /ngs/cohorts/:id/content-age-band
+// A null content_age_band clears the override
+func (h *CohortHandler) SetContentAgeBand(c *fiber.Ctx) error {
+	educatorID, cohortID, err := cohortParams(c)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		ContentAgeBand *string `json:"content_age_band"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	cohort, err := h.cohortService.SetContentAgeBand(cohortID, educatorID, req.ContentAgeBand)
+	if err != nil {
+		return cohortError(c, err)
+	}
+
+	return c.JSON(cohort)
+}
+
+// GetCurriculum handles GET /ngs/cohorts/:id/curriculum
+func (h *CohortHandler) GetCurriculum(c *fiber.Ctx) error {
+	educatorID, cohortID, err := cohortParams(c)
+	if err != nil {
+		return err
+	}
+
+	curriculum, err := h.cohortService.GetCurriculum(cohortID, educatorID)
+	if err != nil {
+		return cohortError(c, err)
+	}
+
+	return c.JSON(curriculum)
+}
+
+// SetCurriculum handles PUT /ngs/cohorts/:id/curriculum
+// The body replaces all of the cohort's lesson overrides and hidden tracks
+func (h *CohortHandler) SetCurriculum(c *fiber.Ctx) error {
+	educatorID, cohortID, err := cohortParams(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.SetCohortCurriculumRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	curriculum, err := h.cohortService.SetCurriculum(cohortID, educatorID, req)
+	if err != nil {
+		return cohortError(c, err)
+	}
+
+	return c.JSON(curriculum)
+}
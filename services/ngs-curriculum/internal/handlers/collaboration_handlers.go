@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type CollaborationHandler struct {
+	collaborationService *services.CollaborationService
+}
+
+func NewCollaborationHandler(collaborationService *services.CollaborationService) *CollaborationHandler {
+	return &CollaborationHandler{
+		collaborationService: collaborationService,
+	}
+}
+
+// collaborationError maps collaboration service errors to HTTP responses
+func collaborationError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrCollaborationNotFound), errors.Is(err, services.ErrChallengeNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrNotCollaborator):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrCollaborationClosed), errors.Is(err, services.ErrAlreadyCollaborator),
+		errors.Is(err, services.ErrRoleTaken), errors.Is(err, services.ErrCollaborationIncomplete):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrNotCollaborationChallenge), errors.Is(err, services.ErrInvalidCollaborationRole):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrSubmissionTooLarge):
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("Collaboration error: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to process collaboration",
+	})
+}
+
+// collaborationParams returns the caller and the :id collaboration
+func collaborationParams(c *fiber.Ctx) (uuid.UUID, uuid.UUID, error) {
+	userID, err := getUserID(c)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	collaborationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, fiber.NewError(fiber.StatusBadRequest, "Invalid collaboration ID format")
+	}
+
+	return userID, collaborationID, nil
+}
+
+// ListCollaborations handles GET /ngs/challenges/:id/collaborations
+func (h *CollaborationHandler) ListCollaborations(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	challengeID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid challenge ID format",
+		})
+	}
+
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	collaborations, err := h.collaborationService.ListOpenCollaborations(challengeID, userID, limit+1)
+	if err != nil {
+		return collaborationError(c, err)
+	}
+
+	collaborations, hasMore := trimPage(collaborations, limit)
+	return c.JSON(listResponse("collaborations", collaborations, hasMore, fiber.Map{
+		"challenge_id": challengeID,
+	}))
+}
+
+// CreateCollaboration handles POST /ngs/challenges/:id/collaborations
+func (h *CollaborationHandler) CreateCollaboration(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	challengeID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid challenge ID format",
+		})
+	}
+
+	var req models.CollaborationRoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	collaboration, err := h.collaborationService.CreateCollaboration(challengeID, userID, req.Role)
+	if err != nil {
+		return collaborationError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(collaboration)
+}
+
+// GetCollaboration handles GET /ngs/collaborations/:id
+func (h *CollaborationHandler) GetCollaboration(c *fiber.Ctx) error {
+	userID, collaborationID, err := collaborationParams(c)
+	if err != nil {
+		return err
+	}
+
+	collaboration, err := h.collaborationService.GetCollaboration(collaborationID, userID)
+	if err != nil {
+		return collaborationError(c, err)
+	}
+
+	return c.JSON(collaboration)
+}
+
+// JoinCollaboration handles POST /ngs/collaborations/:id/join
+func (h *CollaborationHandler) JoinCollaboration(c *fiber.Ctx) error {
+	userID, collaborationID, err := collaborationParams(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.CollaborationRoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	collaboration, err := h.collaborationService.JoinCollaboration(collaborationID, userID, req.Role)
+	if err != nil {
+		return collaborationError(c, err)
+	}
+
+	return c.JSON(collaboration)
+}
+
+// UpdateSection handles PUT /ngs/collaborations/:id/section
+func (h *CollaborationHandler) UpdateSection(c *fiber.Ctx) error {
+	userID, collaborationID, err := collaborationParams(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.CollaborationSectionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	collaboration, err := h.collaborationService.UpdateSection(collaborationID, userID, req.Content)
+	if err != nil {
+		return collaborationError(c, err)
+	}
+
+	return c.JSON(collaboration)
+}
+
+// LeaveCollaboration handles POST /ngs/collaborations/:id/leave
+func (h *CollaborationHandler) LeaveCollaboration(c *fiber.Ctx) error {
+	userID, collaborationID, err := collaborationParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.collaborationService.LeaveCollaboration(collaborationID, userID); err != nil {
+		return collaborationError(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// SubmitCollaboration handles POST /ngs/collaborations/:id/submit
+func (h *CollaborationHandler) SubmitCollaboration(c *fiber.Ctx) error {
+	userID, collaborationID, err := collaborationParams(c)
+	if err != nil {
+		return err
+	}
+
+	collaboration, err := h.collaborationService.SubmitCollaboration(collaborationID, userID)
+	if err != nil {
+		return collaborationError(c, err)
+	}
+
+	return c.JSON(collaboration)
+}
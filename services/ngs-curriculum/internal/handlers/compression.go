@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	compressionInputBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_http_compression_input_bytes_total",
+			Help: "Response bytes before compression, for compressed responses.",
+		},
+		[]string{"route", "encoding"},
+	)
+
+	compressionSavedBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_http_compression_saved_bytes_total",
+			Help: "Response bytes saved by compression.",
+		},
+		[]string{"route", "encoding"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(compressionInputBytes, compressionSavedBytes)
+}
+
+// compressionLevels maps COMPRESSION_LEVEL to brotli and gzip/deflate levels
+var compressionLevels = map[string][2]int{
+	"speed":   {fasthttp.CompressBrotliBestSpeed, fasthttp.CompressBestSpeed},
+	"default": {fasthttp.CompressBrotliDefaultCompression, fasthttp.CompressDefaultCompression},
+	"best":    {fasthttp.CompressBrotliBestCompression, fasthttp.CompressBestCompression},
+}
+
+// CompressContent compresses a content-heavy route's response with brotli, gzip or deflate,
+// whichever the client accepts first in that order, and records the bytes saved. level is
+// speed, default, best or off. Small bodies and already encoded responses are left alone.
+func CompressContent(level string) (fiber.Handler, error) {
+	if level == "off" {
+		return func(c *fiber.Ctx) error { return c.Next() }, nil
+	}
+	levels, ok := compressionLevels[level]
+	if !ok {
+		return nil, fmt.Errorf("unknown compression level %q", level)
+	}
+	compress := fasthttp.CompressHandlerBrotliLevel(func(*fasthttp.RequestCtx) {}, levels[0], levels[1])
+
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		before := len(c.Response().Body())
+		compress(c.Context())
+
+		encoding := string(c.Response().Header.Peek(fiber.HeaderContentEncoding))
+		if encoding == "" {
+			return nil
+		}
+		route := c.Route().Path
+		compressionInputBytes.WithLabelValues(route, encoding).Add(float64(before))
+		if saved := before - len(c.Response().Body()); saved > 0 {
+			compressionSavedBytes.WithLabelValues(route, encoding).Add(float64(saved))
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ConsentHandler struct {
+	consentService *services.ConsentService
+}
+
+func NewConsentHandler(consentService *services.ConsentService) *ConsentHandler {
+	return &ConsentHandler{
+		consentService: consentService,
+	}
+}
+
+// consentError maps consent service errors to HTTP responses
+func consentError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrConsentNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrConsentClosed):
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrConsentNotRequired):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidGuardian):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("Guardian consent error: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to process guardian consent",
+	})
+}
+
+// RequestConsent handles POST /ngs/settings/guardian-consent
+func (h *ConsentHandler) RequestConsent(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		GuardianEmail string `json:"guardian_email"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	consent, err := h.consentService.RequestConsent(userID, req.GuardianEmail)
+	if err != nil {
+		return consentError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(consent)
+}
+
+// GetConsentStatus handles GET /ngs/settings/guardian-consent
+func (h *ConsentHandler) GetConsentStatus(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	status, err := h.consentService.GetConsentStatus(userID)
+	if err != nil {
+		return consentError(c, err)
+	}
+
+	return c.JSON(status)
+}
+
+// GetConsentRequest handles GET /ngs/guardian-consent/:token
+// Opened by the guardian from the consent link; the token is the only credential
+func (h *ConsentHandler) GetConsentRequest(c *fiber.Ctx) error {
+	view, err := h.consentService.GetConsentByToken(c.Params("token"))
+	if err != nil {
+		return consentError(c, err)
+	}
+
+	return c.JSON(view)
+}
+
+// RespondConsent handles POST /ngs/guardian-consent/:token
+func (h *ConsentHandler) RespondConsent(c *fiber.Ctx) error {
+	var req struct {
+		Decision string `json:"decision"` // grant or deny
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Decision != "grant" && req.Decision != "deny" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Decision must be grant or deny",
+		})
+	}
+
+	view, err := h.consentService.RespondConsent(c.Params("token"), req.Decision == "grant", c.IP())
+	if err != nil {
+		return consentError(c, err)
+	}
+
+	return c.JSON(view)
+}
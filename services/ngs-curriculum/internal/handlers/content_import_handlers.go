@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// contentImportError maps content import service errors to HTTP responses
+func contentImportError(c *fiber.Ctx, err error) error {
+	var prereqErr *services.PrerequisiteError
+	if errors.As(err, &prereqErr) {
+		return prerequisiteErrorResponse(c, prereqErr)
+	}
+
+	switch {
+	case errors.Is(err, services.ErrDraftNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidContentImport):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrDraftIncomplete):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrExternalIDTaken):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("Content import error: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to import content",
+	})
+}
+
+// ImportContent handles POST /ngs/admin/content/import
+func (h *AdminHandler) ImportContent(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	var req models.ContentImportRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	conn, err := h.contentService.Connector(req)
+	if err != nil {
+		return contentImportError(c, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	report, err := h.contentService.ImportContent(ctx, conn, req.FieldMap)
+	if err != nil {
+		return contentImportError(c, err)
+	}
+
+	return c.JSON(report)
+}
+
+// GetLessonDrafts handles GET /ngs/admin/lesson-drafts?status=draft
+func (h *AdminHandler) GetLessonDrafts(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	drafts, err := h.contentService.ListDrafts(c.Query("status"), limit+1)
+	if err != nil {
+		return contentImportError(c, err)
+	}
+
+	drafts, hasMore := trimPage(drafts, limit)
+	return c.JSON(listResponse("drafts", drafts, hasMore, nil))
+}
+
+// PublishLessonDraft handles POST /ngs/admin/lesson-drafts/:id/publish
+func (h *AdminHandler) PublishLessonDraft(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	draftID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid draft ID format",
+		})
+	}
+
+	draft, err := h.contentService.PublishDraft(draftID)
+	if err != nil {
+		return contentImportError(c, err)
+	}
+
+	return c.JSON(draft)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type CurriculumPackHandler struct {
+	packService *services.CurriculumPackService
+}
+
+func NewCurriculumPackHandler(packService *services.CurriculumPackService) *CurriculumPackHandler {
+	return &CurriculumPackHandler{
+		packService: packService,
+	}
+}
+
+// packFormat picks the pack format from the content type, or from the body when the type names
+// neither: JSON packs are objects, anything else is read as YAML
+func packFormat(contentType string, body []byte) string {
+	contentType = strings.ToLower(contentType)
+	switch {
+	case strings.Contains(contentType, "yaml"):
+		return services.PackFormatYAML
+	case strings.Contains(contentType, "json"):
+		return services.PackFormatJSON
+	case bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")):
+		return services.PackFormatJSON
+	}
+	return services.PackFormatYAML
+}
+
+// ImportPack handles POST /ngs/admin/curriculum/import?dry_run=true (admin)
+// The body is a JSON or YAML curriculum pack
+func (h *CurriculumPackHandler) ImportPack(c *fiber.Ctx) error {
+	adminID, err := getUserIDWithRole(c, "admin")
+	if err != nil {
+		return err
+	}
+
+	format := packFormat(string(c.Request().Header.ContentType()), c.Body())
+	pack, err := services.ParseCurriculumPack(c.Body(), format)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	result, err := h.packService.ImportPack(pack, uuid.NullUUID{UUID: adminID, Valid: true},
+		services.PackImportOptions{DryRun: c.QueryBool("dry_run", false)})
+	var packErr *services.CurriculumPackError
+	var prereqErr *services.PrerequisiteError
+	switch {
+	case errors.As(err, &packErr):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":    "Invalid curriculum pack",
+			"problems": packErr.Problems,
+		})
+	case errors.As(err, &prereqErr):
+		return prerequisiteErrorResponse(c, prereqErr)
+	case err != nil:
+		log.Printf("Error importing curriculum pack: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to import curriculum pack",
+		})
+	}
+
+	return c.JSON(result)
+}
+
+// ExportPack handles GET /ngs/admin/curriculum/export?format=yaml (admin)
+// JSON by default; the pack is sent as a download
+func (h *CurriculumPackHandler) ExportPack(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	format := c.Query("format", services.PackFormatJSON)
+	if format != services.PackFormatJSON && format != services.PackFormatYAML {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "format must be json or yaml",
+		})
+	}
+
+	pack, err := h.packService.ExportPack()
+	if err != nil {
+		log.Printf("Error exporting curriculum pack: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to export curriculum pack",
+		})
+	}
+	data, err := services.MarshalCurriculumPack(pack, format)
+	if err != nil {
+		log.Printf("Error encoding curriculum pack: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to export curriculum pack",
+		})
+	}
+
+	contentType := fiber.MIMEApplicationJSON
+	if format == services.PackFormatYAML {
+		contentType = "application/yaml"
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Attachment("curriculum-pack." + format)
+	return c.Send(data)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type DeadLetterHandler struct {
+	jobService *services.JobService
+}
+
+func NewDeadLetterHandler(jobService *services.JobService) *DeadLetterHandler {
+	return &DeadLetterHandler{
+		jobService: jobService,
+	}
+}
+
+// deadLetterError maps dead letter errors to responses
+func deadLetterError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrDeadLetterNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrDeadLetterState), errors.Is(err, services.ErrUnknownJobKind):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidDeadLetter):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return err
+}
+
+func deadLetterID(c *fiber.Ctx) (int64, error) {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid dead letter ID",
+		})
+	}
+	return id, nil
+}
+
+// ListDeadLetters handles GET /ngs/admin/dlq?status=dead&kind=&limit= (admin). status defaults
+// to dead; status=all lists every state.
+func (h *DeadLetterHandler) ListDeadLetters(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	status := c.Query("status", services.DeadLetterDead)
+	if status == "all" {
+		status = ""
+	}
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 {
+		limit = 50
+	}
+	if limit > 200 {
+		limit = 200
+	}
+
+	letters, err := h.jobService.ListDeadLetters(status, c.Query("kind"), limit+1)
+	if err != nil {
+		return deadLetterError(c, err)
+	}
+	letters, hasMore := trimPage(letters, limit)
+
+	return c.JSON(listResponse("dead_letters", letters, hasMore, nil))
+}
+
+// RetryDeadLetter handles POST /ngs/admin/dlq/:id/retry (admin). The job runs in the background;
+// the dead letter shows retrying until it finishes.
+func (h *DeadLetterHandler) RetryDeadLetter(c *fiber.Ctx) error {
+	adminID, err := getUserIDWithRole(c, "admin")
+	if err != nil {
+		return err
+	}
+	id, err := deadLetterID(c)
+	if err != nil {
+		return err
+	}
+
+	letter, err := h.jobService.Retry(id, adminID)
+	if err != nil {
+		return deadLetterError(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(letter)
+}
+
+// DiscardDeadLetter handles POST /ngs/admin/dlq/:id/discard (admin)
+func (h *DeadLetterHandler) DiscardDeadLetter(c *fiber.Ctx) error {
+	adminID, err := getUserIDWithRole(c, "admin")
+	if err != nil {
+		return err
+	}
+	id, err := deadLetterID(c)
+	if err != nil {
+		return err
+	}
+
+	letter, err := h.jobService.Discard(id, adminID)
+	if err != nil {
+		return deadLetterError(c, err)
+	}
+
+	return c.JSON(letter)
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+	"github.com/google/uuid"
+)
+
+type DuelHandler struct {
+	duelService *services.DuelService
+	hub         *services.DuelHub
+}
+
+func NewDuelHandler(duelService *services.DuelService, hub *services.DuelHub) *DuelHandler {
+	return &DuelHandler{
+		duelService: duelService,
+		hub:         hub,
+	}
+}
+
+// duelError maps duel service errors to HTTP responses
+func duelError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrDuelNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrNotDuelParticipant):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrDuelNotActive):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrNoDuelChallenge):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrSubmissionTooLarge):
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("Duel error: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to process duel",
+	})
+}
+
+// JoinQueue handles POST /ngs/duels/queue
+func (h *DuelHandler) JoinQueue(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	duel, err := h.duelService.JoinQueue(userID)
+	if err != nil {
+		return duelError(c, err)
+	}
+
+	return c.JSON(duel)
+}
+
+// GetDuel handles GET /ngs/duels/:id
+// Clients without a socket poll this for the shared countdown and result
+func (h *DuelHandler) GetDuel(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	duelID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid duel ID format",
+		})
+	}
+
+	duel, err := h.duelService.GetDuel(duelID, userID)
+	if err != nil {
+		return duelError(c, err)
+	}
+
+	return c.JSON(duel)
+}
+
+// SubmitDuel handles POST /ngs/duels/:id/submit
+func (h *DuelHandler) SubmitDuel(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	duelID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid duel ID format",
+		})
+	}
+
+	var req struct {
+		SubmissionCode string `json:"submission_code"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.SubmissionCode == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Submission code is required",
+		})
+	}
+
+	result, err := h.duelService.SubmitDuel(duelID, userID, req.SubmissionCode)
+	if err != nil {
+		return duelError(c, err)
+	}
+
+	return c.JSON(result)
+}
+
+// CancelDuel handles POST /ngs/duels/:id/cancel
+func (h *DuelHandler) CancelDuel(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	duelID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid duel ID format",
+		})
+	}
+
+	if err := h.duelService.CancelDuel(duelID, userID); err != nil {
+		return duelError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Duel cancelled",
+	})
+}
+
+// GetDuelHistory handles GET /ngs/duels/history
+func (h *DuelHandler) GetDuelHistory(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	duels, err := h.duelService.GetDuelHistory(userID, limit+1)
+	if err != nil {
+		return duelError(c, err)
+	}
+
+	rating, err := h.duelService.GetRating(userID)
+	if err != nil {
+		return duelError(c, err)
+	}
+
+	duels, hasMore := trimPage(duels, limit)
+	return c.JSON(listResponse("duels", duels, hasMore, fiber.Map{"rating": rating}))
+}
+
+// GetDuelRating handles GET /ngs/duels/rating
+func (h *DuelHandler) GetDuelRating(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	rating, err := h.duelService.GetRating(userID)
+	if err != nil {
+		return duelError(c, err)
+	}
+
+	return c.JSON(rating)
+}
+
+// AcceptDuelSocket handles GET /ngs/duels/:id/ws before the upgrade, so only the duel's
+// participants can watch it
+func (h *DuelHandler) AcceptDuelSocket(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	duelID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid duel ID format",
+		})
+	}
+
+	if _, err := h.duelService.GetDuel(duelID, userID); err != nil {
+		return duelError(c, err)
+	}
+
+	if !websocket.IsWebSocketUpgrade(c) {
+		return c.Status(fiber.StatusUpgradeRequired).JSON(fiber.Map{
+			"error": "WebSocket upgrade required",
+		})
+	}
+
+	c.Locals("duel_id", duelID)
+	c.Locals("duel_user_id", userID)
+	return c.Next()
+}
+
+// DuelSocket streams the duel state to a participant: once on connect, whenever either player
+// changes the duel, and when the countdown runs out. It closes once the duel is over.
+func (h *DuelHandler) DuelSocket() fiber.Handler {
+	return websocket.New(func(conn *websocket.Conn) {
+		duelID := conn.Locals("duel_id").(uuid.UUID)
+		userID := conn.Locals("duel_user_id").(uuid.UUID)
+
+		changes, unsubscribe := h.hub.Subscribe(duelID)
+		defer unsubscribe()
+
+		// The client sends nothing; reading only notices when it goes away
+		gone := make(chan struct{})
+		go func() {
+			defer close(gone)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		for {
+			state, err := h.duelService.GetDuel(duelID, userID)
+			if err != nil {
+				log.Printf("Duel socket error: %v", err)
+				conn.WriteJSON(fiber.Map{"error": "Failed to process duel"})
+				return
+			}
+			if err := conn.WriteJSON(state); err != nil {
+				return
+			}
+
+			var countdown <-chan time.Time
+			var timer *time.Timer
+			switch state.Duel.Status {
+			case "completed", "expired", "cancelled":
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "duel over"))
+				return
+			case "active":
+				// Re-read at the deadline so the expiry is settled and sent
+				timer = time.NewTimer(time.Duration(state.SecondsRemaining) * time.Second)
+				countdown = timer.C
+			}
+
+			select {
+			case <-changes:
+			case <-countdown:
+			case <-gone:
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			if isClosed(gone) {
+				return
+			}
+		}
+	})
+}
+
+// isClosed reports whether ch has been closed, without waiting
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strconv"
+
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type ExamHandler struct {
+	examService *services.ExamService
+}
+
+func NewExamHandler(examService *services.ExamService) *ExamHandler {
+	return &ExamHandler{
+		examService: examService,
+	}
+}
+
+// examError maps exam service errors to HTTP responses
+func examError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrExamNotFound), errors.Is(err, services.ErrExamUnavailable):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrExamLevelLocked):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrExamNotActive), errors.Is(err, services.ErrExamExpired):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("Exam error: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to process exam",
+	})
+}
+
+// StartExam handles POST /ngs/levels/:level/exam
+func (h *ExamHandler) StartExam(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	level, err := strconv.Atoi(c.Params("level"))
+	if err != nil || level < 1 || level > 24 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Level must be between 1 and 24",
+		})
+	}
+
+	exam, err := h.examService.StartExam(userID, level)
+	if err != nil {
+		return examError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(exam)
+}
+
+// GetExam handles GET /ngs/exams/:id
+func (h *ExamHandler) GetExam(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	examID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid exam ID format",
+		})
+	}
+
+	exam, err := h.examService.GetExam(examID, userID)
+	if err != nil {
+		return examError(c, err)
+	}
+
+	return c.JSON(exam)
+}
+
+// SubmitExam handles POST /ngs/exams/:id/submit
+func (h *ExamHandler) SubmitExam(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	examID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid exam ID format",
+		})
+	}
+
+	var req models.SubmitExamRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	result, err := h.examService.SubmitExam(examID, userID, req)
+	if err != nil {
+		return examError(c, err)
+	}
+
+	return c.JSON(result)
+}
+
+// GetCertifications handles GET /ngs/certifications
+func (h *ExamHandler) GetCertifications(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	certifications, err := h.examService.GetCertifications(userID)
+	if err != nil {
+		return examError(c, err)
+	}
+
+	return c.JSON(listResponse("certifications", certifications, false, nil))
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ExperimentHandler struct {
+	experimentService *services.ExperimentService
+}
+
+func NewExperimentHandler(experimentService *services.ExperimentService) *ExperimentHandler {
+	return &ExperimentHandler{
+		experimentService: experimentService,
+	}
+}
+
+// experimentError maps experiment service errors to HTTP responses
+func experimentError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrExperimentNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidExperiment):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("Experiment error: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to process experiment",
+	})
+}
+
+// GetAssignment handles GET /ngs/experiments/:key/assignment
+// Logs an exposure, so clients should call it when the variant is actually shown
+func (h *ExperimentHandler) GetAssignment(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	assignment, err := h.experimentService.Assign(c.Params("key"), userID)
+	if err != nil {
+		return experimentError(c, err)
+	}
+
+	return c.JSON(assignment)
+}
+
+// UpsertExperiment handles PUT /ngs/experiments/:key (admin)
+func (h *ExperimentHandler) UpsertExperiment(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	var req models.UpsertExperimentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	req.Key = c.Params("key")
+
+	experiment, err := h.experimentService.UpsertExperiment(req)
+	if err != nil {
+		return experimentError(c, err)
+	}
+
+	return c.JSON(experiment)
+}
+
+// GetResults handles GET /ngs/experiments/:key/results (admin)
+func (h *ExperimentHandler) GetResults(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	results, err := h.experimentService.GetResults(c.Params("key"))
+	if err != nil {
+		return experimentError(c, err)
+	}
+
+	return c.JSON(results)
+}
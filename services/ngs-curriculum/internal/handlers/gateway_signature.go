@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GatewaySignature refuses requests carrying identity headers without a valid gateway signature
+// in X-Gateway-Timestamp and X-Gateway-Signature. Requests without identity headers, such as
+// health checks and internal calls authenticated by token, pass through.
+func GatewaySignature(verifier *services.GatewayVerifier) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		identity := make([]string, len(services.GatewayIdentityHeaders))
+		present := false
+		for i, header := range services.GatewayIdentityHeaders {
+			identity[i] = c.Get(header)
+			present = present || identity[i] != ""
+		}
+		if !present {
+			return c.Next()
+		}
+
+		err := verifier.Verify(c.Get("X-Gateway-Timestamp"), c.Get("X-Gateway-Signature"), c.Method(), c.OriginalURL(), identity)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type GraphHandler struct {
+	graphService *services.GraphService
+}
+
+func NewGraphHandler(graphService *services.GraphService) *GraphHandler {
+	return &GraphHandler{
+		graphService: graphService,
+	}
+}
+
+// GetCurriculumGraph handles GET /ngs/curriculum/graph
+func (h *GraphHandler) GetCurriculumGraph(c *fiber.Ctx) error {
+	// Anonymous requests fall back to the adult band
+	userID, _ := uuid.Parse(middleware.GetIdentity(c).UserID)
+
+	graph, err := h.graphService.GetCurriculumGraph(userID)
+	if err != nil {
+		log.Printf("Error building curriculum graph: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to build curriculum graph",
+		})
+	}
+
+	return c.JSON(graph)
+}
+
+// GetCurriculumMap handles GET /ngs/curriculum/map
+func (h *GraphHandler) GetCurriculumMap(c *fiber.Ctx) error {
+	// Anonymous requests see every level but the first locked
+	userID, _ := uuid.Parse(middleware.GetIdentity(c).UserID)
+
+	curriculumMap, err := h.graphService.GetCurriculumMap(userID)
+	if err != nil {
+		log.Printf("Error building curriculum map: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to build curriculum map",
+		})
+	}
+
+	return c.JSON(curriculumMap)
+}
+
+// prerequisiteErrorResponse reports every prerequisite violation so authors can fix them in one pass
+func prerequisiteErrorResponse(c *fiber.Ctx, err *services.PrerequisiteError) error {
+	return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+		"error":      "Invalid lesson prerequisites",
+		"violations": err.Violations,
+	})
+}
+
+// SetLessonPrerequisites handles PUT /ngs/admin/lessons/:id/prerequisites (admin)
+func (h *GraphHandler) SetLessonPrerequisites(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	lessonID, err := lessonIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.SetPrerequisitesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	prereqs, err := h.graphService.SetLessonPrerequisites(lessonID, req)
+	var prereqErr *services.PrerequisiteError
+	switch {
+	case errors.As(err, &prereqErr):
+		return prerequisiteErrorResponse(c, prereqErr)
+	case errors.Is(err, services.ErrLessonNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		log.Printf("Error setting prerequisites for lesson %s: %v", lessonID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to set lesson prerequisites",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"lesson_id":     lessonID,
+		"prerequisites": prereqs,
+	})
+}
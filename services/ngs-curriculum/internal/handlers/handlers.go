@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"fmt"
 	"log"
 	"strconv"
 
+	"noble-ngs-curriculum/internal/api"
+	"noble-ngs-curriculum/internal/middleware"
 	"noble-ngs-curriculum/internal/models"
 	"noble-ngs-curriculum/internal/services"
 
@@ -21,11 +24,11 @@ func NewHandler(progressService *services.ProgressService) *Handler {
 	}
 }
 
-// getUserID extracts user ID from X-User-Id header
+// getUserID extracts the authenticated user's ID from the request identity
 func getUserID(c *fiber.Ctx) (uuid.UUID, error) {
-	userIDStr := c.Get("X-User-Id")
+	userIDStr := middleware.GetIdentity(c).UserID
 	if userIDStr == "" {
-		return uuid.Nil, fiber.NewError(fiber.StatusUnauthorized, "X-User-Id header required")
+		return uuid.Nil, fiber.NewError(fiber.StatusUnauthorized, "Authentication required")
 	}
 
 	userID, err := uuid.Parse(userIDStr)
@@ -36,6 +39,27 @@ func getUserID(c *fiber.Ctx) (uuid.UUID, error) {
 	return userID, nil
 }
 
+// getUserIDWithRole extracts the user ID when the caller's role is one of roles
+func getUserIDWithRole(c *fiber.Ctx, roles ...string) (uuid.UUID, error) {
+	userID, err := getUserID(c)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	role := middleware.GetIdentity(c).Role
+	for _, allowed := range roles {
+		if role == allowed {
+			return userID, nil
+		}
+	}
+	return uuid.Nil, fiber.NewError(fiber.StatusForbidden, "Insufficient role")
+}
+
+// getEducatorID extracts the user ID of an educator or admin caller
+func getEducatorID(c *fiber.Ctx) (uuid.UUID, error) {
+	return getUserIDWithRole(c, "educator", "admin")
+}
+
 // GetProgress retrieves user progress
 // GET /ngs/progress
 func (h *Handler) GetProgress(c *fiber.Ctx) error {
@@ -90,61 +114,20 @@ func (h *Handler) AwardXP(c *fiber.Ctx) error {
 	})
 }
 
-// CompleteLesson marks a lesson as complete and awards XP
-// POST /ngs/complete-lesson
-func (h *Handler) CompleteLesson(c *fiber.Ctx) error {
+// GetAchievements retrieves user achievements
+// GET /ngs/achievements?from=&to=&cursor=&limit=
+func (h *Handler) GetAchievements(c *fiber.Ctx) error {
 	userID, err := getUserID(c)
 	if err != nil {
 		return err
 	}
 
-	var req models.CompleteLessonRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
-	}
-
-	// Determine XP source based on score
-	source := "lesson_completion"
-	if req.Score >= 100 {
-		source = "quiz_perfect"
-	} else if req.Score >= 80 {
-		source = "quiz_good"
-	} else if req.Score >= 60 {
-		source = "quiz_pass"
-	}
-
-	// Add lesson_id to metadata
-	if req.Metadata == nil {
-		req.Metadata = make(map[string]interface{})
-	}
-	req.Metadata["lesson_id"] = req.LessonID.String()
-	req.Metadata["score"] = req.Score
-
-	progress, err := h.progressService.AwardXP(userID, source, 0, req.Metadata)
+	page, err := api.ParsePage(c)
 	if err != nil {
-		log.Printf("Error completing lesson for user %s: %v", userID, err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to complete lesson",
-		})
+		return pageError(c, err)
 	}
 
-	return c.JSON(fiber.Map{
-		"message":  "Lesson completed successfully",
-		"progress": progress,
-	})
-}
-
-// GetAchievements retrieves user achievements
-// GET /ngs/achievements
-func (h *Handler) GetAchievements(c *fiber.Ctx) error {
-	userID, err := getUserID(c)
-	if err != nil {
-		return err
-	}
-
-	achievements, err := h.progressService.GetAchievements(userID)
+	achievements, err := h.progressService.GetAchievements(userID, page.Fetch())
 	if err != nil {
 		log.Printf("Error getting achievements for user %s: %v", userID, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -152,14 +135,15 @@ func (h *Handler) GetAchievements(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"achievements": achievements,
-		"count":        len(achievements),
+	achievements, hasMore, extra := nextPage(achievements, page, func(a models.Achievement) api.Cursor {
+		return api.Cursor{At: a.UnlockedAt, ID: a.ID}
 	})
+	return c.JSON(listResponse("achievements", achievements, hasMore, extra))
 }
 
-// GetLeaderboard retrieves the leaderboard
-// GET /ngs/leaderboard
+// GetLeaderboard retrieves the leaderboard, by lifetime XP or by XP earned this day, week or
+// month
+// GET /ngs/leaderboard?period=daily|weekly|monthly|all
 func (h *Handler) GetLeaderboard(c *fiber.Ctx) error {
 	limit := 10
 	if limitStr := c.Query("limit"); limitStr != "" {
@@ -167,8 +151,12 @@ func (h *Handler) GetLeaderboard(c *fiber.Ctx) error {
 			limit = parsedLimit
 		}
 	}
+	period, err := h.progressService.LeaderboardPeriod(c.Query("period"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 
-	leaderboard, err := h.progressService.GetLeaderboard(limit)
+	leaderboard, err := h.progressService.GetPeriodLeaderboard(period, limit+1)
 	if err != nil {
 		log.Printf("Error getting leaderboard: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -176,10 +164,55 @@ func (h *Handler) GetLeaderboard(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"leaderboard": leaderboard,
-		"count":       len(leaderboard),
-	})
+	leaderboard, hasMore := trimPage(leaderboard, limit)
+	extra := fiber.Map{"period": period.Name}
+	if !period.AllTime() {
+		extra["period_start"] = period.Start
+		extra["resets_at"] = period.End
+	}
+	return c.JSON(listResponse("leaderboard", leaderboard, hasMore, extra))
+}
+
+// GetLeaderboardPosition retrieves the user's rank on the leaderboard with the users ranked
+// nearest above and below
+// GET /ngs/leaderboard/me?period=weekly&neighbors=3
+func (h *Handler) GetLeaderboardPosition(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+	neighbors := c.QueryInt("neighbors", 3)
+	if neighbors < 0 || neighbors > services.MaxLeaderboardNeighbors {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("neighbors must be between 0 and %d", services.MaxLeaderboardNeighbors),
+		})
+	}
+	period, err := h.progressService.LeaderboardPeriod(c.Query("period"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	position, err := h.progressService.GetLeaderboardPosition(userID, period, neighbors)
+	if err != nil {
+		log.Printf("Error getting leaderboard position: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get leaderboard position",
+		})
+	}
+
+	response := fiber.Map{
+		"period":       period.Name,
+		"ranked":       position.Ranked,
+		"me":           position.Me,
+		"above":        position.Above,
+		"below":        position.Below,
+		"total_ranked": position.TotalRanked,
+	}
+	if !period.AllTime() {
+		response["period_start"] = period.Start
+		response["resets_at"] = period.End
+	}
+	return c.JSON(response)
 }
 
 // GetLevels retrieves all curriculum levels
@@ -193,10 +226,7 @@ func (h *Handler) GetLevels(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"levels": levels,
-		"count":  len(levels),
-	})
+	return c.JSON(listResponse("levels", levels, false, nil))
 }
 
 // GetLevel retrieves a specific level
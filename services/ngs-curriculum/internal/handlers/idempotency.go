@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// IdempotencyStore records Idempotency-Key requests and their responses;
+// *services.IdempotencyService implements it
+type IdempotencyStore interface {
+	Begin(userID uuid.UUID, key, method, path string, body []byte) (*services.IdempotentResponse, error)
+	Complete(userID uuid.UUID, key string, response services.IdempotentResponse) error
+	Release(userID uuid.UUID, key string) error
+}
+
+var _ IdempotencyStore = (*services.IdempotencyService)(nil)
+
+// Idempotent makes a route safe to retry with an Idempotency-Key header. The first request with
+// a key runs; once it succeeds, requests repeating it get its status and body back, marked with
+// Idempotent-Replayed: true, without running again. A repeat that arrives while the first is still
+// running gets 409, and a key reused for another request 422. Failed requests keep no record, so
+// they can be retried with the same key. Requests without the header, or without a caller, run
+// as usual.
+func Idempotent(store IdempotencyStore) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := strings.TrimSpace(c.Get("Idempotency-Key"))
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > services.IdempotencyKeyMaxLength {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Idempotency-Key must be at most 255 characters",
+			})
+		}
+		userID, err := uuid.Parse(middleware.GetIdentity(c).UserID)
+		if err != nil {
+			return c.Next()
+		}
+
+		stored, err := store.Begin(userID, key, c.Method(), c.Path(), c.Body())
+		switch {
+		case errors.Is(err, services.ErrIdempotencyKeyReused):
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, services.ErrIdempotencyKeyInProgress):
+			c.Set(fiber.HeaderRetryAfter, "1")
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		case err != nil:
+			log.Printf("Error claiming idempotency key for user %s: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check Idempotency-Key",
+			})
+		case stored != nil:
+			c.Set("Idempotent-Replayed", "true")
+			if stored.ContentType != "" {
+				c.Set(fiber.HeaderContentType, stored.ContentType)
+			}
+			return c.Status(stored.StatusCode).Send(stored.Body)
+		}
+
+		err = c.Next()
+		status := c.Response().StatusCode()
+		if err != nil || status < 200 || status >= 300 {
+			if releaseErr := store.Release(userID, key); releaseErr != nil {
+				log.Printf("Error releasing idempotency key for user %s: %v", userID, releaseErr)
+			}
+			return err
+		}
+
+		response := services.IdempotentResponse{
+			StatusCode:  status,
+			ContentType: string(c.Response().Header.ContentType()),
+			Body:        append([]byte(nil), c.Response().Body()...),
+		}
+		if err := store.Complete(userID, key, response); err != nil {
+			// The award stands; the key stays in progress until it expires, so a retry cannot
+			// repeat it
+			log.Printf("Error storing idempotent response for user %s: %v", userID, err)
+		}
+		return nil
+	}
+}
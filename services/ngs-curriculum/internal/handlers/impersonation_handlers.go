@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ImpersonatedRole is the role impersonated requests run with, the default learner role
+const ImpersonatedRole = "student"
+
+type ImpersonationHandler struct {
+	impersonationService *services.ImpersonationService
+}
+
+func NewImpersonationHandler(impersonationService *services.ImpersonationService) *ImpersonationHandler {
+	return &ImpersonationHandler{
+		impersonationService: impersonationService,
+	}
+}
+
+// impersonationError maps impersonation service errors to responses
+func impersonationError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidImpersonation):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrImpersonationNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrImpersonationInvalid):
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+	return err
+}
+
+// Middleware runs requests carrying X-Impersonation-Token as the session's learner. Only the
+// admin who started the session may use its token. Each request is audited before it is
+// handled, and refused if it cannot be; the response carries X-Impersonating.
+func (h *ImpersonationHandler) Middleware(c *fiber.Ctx) error {
+	token := c.Get("X-Impersonation-Token")
+	if token == "" {
+		return c.Next()
+	}
+
+	adminID, err := getUserIDWithRole(c, "admin")
+	if err != nil {
+		return err
+	}
+	session, err := h.impersonationService.ResolveImpersonation(token, adminID)
+	if err != nil {
+		return impersonationError(c, err)
+	}
+	auditID, err := h.impersonationService.BeginAudit(session, c.Method(), c.Path())
+	if err != nil {
+		log.Printf("Refusing impersonated request: %v", err)
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Impersonated requests cannot be audited right now",
+		})
+	}
+
+	identity := middleware.GetIdentity(c)
+	middleware.SetIdentity(c, middleware.Identity{
+		UserID: session.UserID.String(),
+		Role:   ImpersonatedRole,
+		Tier:   identity.Tier,
+		OrgID:  identity.OrgID,
+	})
+	c.Request().Header.Set("X-Impersonated-By", adminID.String())
+	c.Set("X-Impersonating", session.UserID.String())
+
+	err = c.Next()
+
+	status := c.Response().StatusCode()
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		status = fiberErr.Code
+	} else if err != nil {
+		status = fiber.StatusInternalServerError
+	}
+	h.impersonationService.FinishAudit(auditID, status)
+	return err
+}
+
+// StartImpersonation handles POST /ngs/admin/impersonations (admin)
+func (h *ImpersonationHandler) StartImpersonation(c *fiber.Ctx) error {
+	adminID, err := getUserIDWithRole(c, "admin")
+	if err != nil {
+		return err
+	}
+
+	var req models.StartImpersonationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	session, err := h.impersonationService.StartImpersonation(adminID, req)
+	if err != nil {
+		return impersonationError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(session)
+}
+
+// ListImpersonations handles GET /ngs/admin/impersonations?user_id=&limit= (admin)
+func (h *ImpersonationHandler) ListImpersonations(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	var userID *uuid.UUID
+	if raw := c.Query("user_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid user ID format",
+			})
+		}
+		userID = &id
+	}
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	sessions, err := h.impersonationService.ListImpersonations(userID, limit+1)
+	if err != nil {
+		return err
+	}
+	sessions, hasMore := trimPage(sessions, limit)
+
+	return c.JSON(listResponse("impersonations", sessions, hasMore, nil))
+}
+
+// EndImpersonation handles DELETE /ngs/admin/impersonations/:id (admin)
+func (h *ImpersonationHandler) EndImpersonation(c *fiber.Ctx) error {
+	adminID, err := getUserIDWithRole(c, "admin")
+	if err != nil {
+		return err
+	}
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid impersonation ID format",
+		})
+	}
+
+	session, err := h.impersonationService.EndImpersonation(sessionID, adminID)
+	if err != nil {
+		return impersonationError(c, err)
+	}
+
+	return c.JSON(session)
+}
+
+// GetImpersonationAudit handles GET /ngs/admin/impersonations/:id/audit?limit= (admin)
+func (h *ImpersonationHandler) GetImpersonationAudit(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid impersonation ID format",
+		})
+	}
+	limit := c.QueryInt("limit", 100)
+	if limit < 1 {
+		limit = 100
+	}
+	if limit > 500 {
+		limit = 500
+	}
+
+	entries, err := h.impersonationService.GetImpersonationAudit(sessionID, limit+1)
+	if err != nil {
+		return impersonationError(c, err)
+	}
+	entries, hasMore := trimPage(entries, limit)
+
+	return c.JSON(listResponse("audit", entries, hasMore, nil))
+}
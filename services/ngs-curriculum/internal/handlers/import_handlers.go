@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"log"
+
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ImportUsers handles POST /ngs/admin/import/users?dry_run=true
+// Accepts text/csv with a header row or JSON {"records": [...]}
+func (h *AdminHandler) ImportUsers(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	var records []models.ImportRecord
+	var rows []int
+	var parseErrors []models.ImportError
+	if c.Is("csv") {
+		var err error
+		records, rows, parseErrors, err = services.ParseImportCSV(bytes.NewReader(c.Body()))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	} else {
+		var req models.ImportUsersRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body; send text/csv or JSON {\"records\": [...]}",
+			})
+		}
+		records = req.Records
+	}
+
+	report, err := h.importService.ImportUsers(records, rows, c.QueryBool("dry_run", false))
+	if errors.Is(err, services.ErrInvalidImport) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		log.Printf("Error importing users: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to import users",
+		})
+	}
+
+	// CSV rows that could not be parsed count towards the report too
+	report.TotalRecords += len(parseErrors)
+	report.Failed += len(parseErrors)
+	report.Errors = append(parseErrors, report.Errors...)
+
+	return c.JSON(report)
+}
+
+// SetLessonExternalID handles PUT /ngs/admin/lessons/:id/external-id
+func (h *AdminHandler) SetLessonExternalID(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	lessonID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid lesson ID format",
+		})
+	}
+
+	var req struct {
+		ExternalID string `json:"external_id"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	err = h.importService.SetLessonExternalID(lessonID, req.ExternalID)
+	switch {
+	case errors.Is(err, services.ErrLessonNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrExternalIDTaken):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		log.Printf("Error setting external ID for lesson %s: %v", lessonID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to set lesson external ID",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"lesson_id":   lessonID,
+		"external_id": req.ExternalID,
+	})
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"noble-ngs-curriculum/internal/mtls"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// InternalOnly keeps paths under prefixes to the internal listener. Requests for them that did
+// not arrive with a verified client certificate get 404, so the public listener does not reveal
+// the routes; the rest pass through. The client's certificate name is kept in the
+// "internal_client" local.
+func InternalOnly(prefixes ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		for _, prefix := range prefixes {
+			if !hasPathPrefix(c.Path(), prefix) {
+				continue
+			}
+			client := mtls.Client(c.Context().TLSConnectionState())
+			if client == "" {
+				// The same error as an unknown route
+				return fiber.NewError(fiber.StatusNotFound, "Cannot "+c.Method()+" "+c.Path())
+			}
+			c.Locals("internal_client", client)
+			break
+		}
+		return c.Next()
+	}
+}
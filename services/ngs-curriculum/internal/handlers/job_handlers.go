@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type JobQueueHandler struct {
+	jobQueueService *services.JobQueueService
+}
+
+func NewJobQueueHandler(jobQueueService *services.JobQueueService) *JobQueueHandler {
+	return &JobQueueHandler{
+		jobQueueService: jobQueueService,
+	}
+}
+
+// GetJobQueue handles GET /ngs/admin/jobs (admin)
+func (h *JobQueueHandler) GetJobQueue(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	return c.JSON(h.jobQueueService.Status())
+}
+
+// SetJobQueue handles PUT /ngs/admin/jobs (admin)
+func (h *JobQueueHandler) SetJobQueue(c *fiber.Ctx) error {
+	userID, err := getUserIDWithRole(c, "admin")
+	if err != nil {
+		return err
+	}
+
+	var req models.SetJobQueueRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	status, err := h.jobQueueService.SetSettings(userID, req)
+	if errors.Is(err, services.ErrInvalidJobSettings) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(status)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"log"
+
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type LearnerMemoryHandler struct {
+	memoryService *services.LearnerMemoryService
+}
+
+func NewLearnerMemoryHandler(memoryService *services.LearnerMemoryService) *LearnerMemoryHandler {
+	return &LearnerMemoryHandler{
+		memoryService: memoryService,
+	}
+}
+
+// GetMemory handles GET /ngs/me/memory
+// The summary is empty until the learner's first chat session has been summarized
+func (h *LearnerMemoryHandler) GetMemory(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	memory, err := h.memoryService.LearnerMemory(userID)
+	if err != nil {
+		log.Printf("Error getting learner memory for user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get learner memory",
+		})
+	}
+	return c.JSON(memory)
+}
+
+// ForgetMemory handles DELETE /ngs/me/memory
+func (h *LearnerMemoryHandler) ForgetMemory(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.memoryService.ForgetLearnerMemory(userID); err != nil {
+		log.Printf("Error forgetting learner memory for user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to forget learner memory",
+		})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
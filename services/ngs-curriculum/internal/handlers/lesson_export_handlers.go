@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"noble-ngs-curriculum/internal/domain/lessonexport"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ExportLesson handles GET /ngs/lessons/:id/export?format=pdf|md
+// The lesson is rendered for printing or offline classrooms and sent as a download; PDF by default
+func (h *LessonHandler) ExportLesson(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	lessonID, err := lessonIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	export, err := h.lessonService.ExportLesson(lessonID, userID, c.Query("format", lessonexport.FormatPDF))
+	switch {
+	case errors.Is(err, services.ErrInvalidExportFormat):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrLevelLocked):
+		return levelLocked(c)
+	case errors.Is(err, services.ErrLessonNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		log.Printf("Error exporting lesson %s: %v", lessonID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to export lesson",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, export.ContentType)
+	c.Attachment(export.FileName)
+	return c.Send(export.Content)
+}
@@ -3,10 +3,15 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"strconv"
+	"strings"
 	"time"
 
+	"noble-ngs-curriculum/internal/api"
 	"noble-ngs-curriculum/internal/clients/intelligence"
+	"noble-ngs-curriculum/internal/middleware"
 	"noble-ngs-curriculum/internal/models"
 	"noble-ngs-curriculum/internal/services"
 
@@ -14,22 +19,221 @@ import (
 	"github.com/google/uuid"
 )
 
+// IntelligenceClient is the part of the Intelligence service the lesson handlers call.
+// *intelligence.Client implements it; handler tests use testsupport.MockIntelligence.
+type IntelligenceClient interface {
+	GenerateLesson(ctx context.Context, req intelligence.GenerateLessonRequest, userID, userEmail, userRole string) (*intelligence.GenerateLessonResponse, error)
+	SendEducatorChatMessage(ctx context.Context, req intelligence.EducatorChatRequest, userID, userEmail, userRole string) (*intelligence.EducatorChatResponse, error)
+	StreamEducatorChatMessage(ctx context.Context, req intelligence.EducatorChatRequest, userID, userEmail, userRole string, onChunk func(content string) error) (*intelligence.EducatorChatResponse, error)
+}
+
+var _ IntelligenceClient = (*intelligence.Client)(nil)
+
+// LearnerPreferences gives a learner's preferences for chat;
+// *services.SettingsService implements it
+type LearnerPreferences interface {
+	LearnerPreferences(userID uuid.UUID) (map[string]interface{}, error)
+}
+
+// WeakTopics gives the topics a learner struggles with most, for chat;
+// *services.WeakTopicService implements it
+type WeakTopics interface {
+	WeakTopicNames(userID uuid.UUID) ([]string, error)
+}
+
+// LessonContext picks the lesson passages that best match a chat message;
+// *services.MediaService implements it
+type LessonContext interface {
+	LessonContext(userID, lessonID uuid.UUID, message string) ([]models.LessonContextChunk, error)
+}
+
+// ChatRecorder keeps a chat exchange so the learner can rate or escalate it;
+// *services.ChatFeedbackService implements it
+type ChatRecorder interface {
+	RecordChatMessage(userID, lessonID, sessionID uuid.UUID, message, response string) (uuid.UUID, error)
+}
+
+// LearnerMemory gives what the tutor remembers about a learner from earlier chat sessions;
+// *services.LearnerMemoryService implements it
+type LearnerMemory interface {
+	LearnerMemory(userID uuid.UUID) (*models.LearnerMemory, error)
+}
+
 type LessonHandler struct {
 	lessonService       *services.LessonService
-	intelligenceClient  *intelligence.Client
+	intelligenceClient  IntelligenceClient
+	// preferences are sent with chat requests; nil sends none
+	preferences LearnerPreferences
+	// weakTopics are sent with chat requests; nil sends none
+	weakTopics WeakTopics
+	// lessonContext is sent with chat requests; nil sends none
+	lessonContext LessonContext
+	// chatRecorder keeps chat exchanges for feedback and escalation; nil keeps none
+	chatRecorder ChatRecorder
+	// memory is sent with the first message of chat sessions; nil sends none
+	memory LearnerMemory
 }
 
-func NewLessonHandler(lessonService *services.LessonService, intelligenceClient *intelligence.Client) *LessonHandler {
+func NewLessonHandler(lessonService *services.LessonService, intelligenceClient IntelligenceClient) *LessonHandler {
 	return &LessonHandler{
 		lessonService:      lessonService,
 		intelligenceClient: intelligenceClient,
 	}
 }
 
-// GetLessonsByLevel handles GET /ngs/levels/:level/lessons
+// SetLearnerPreferences has chat requests carry the learner's preferences
+func (h *LessonHandler) SetLearnerPreferences(preferences LearnerPreferences) {
+	h.preferences = preferences
+}
+
+// SetWeakTopics has chat requests carry the learner's weak topics
+func (h *LessonHandler) SetWeakTopics(weakTopics WeakTopics) {
+	h.weakTopics = weakTopics
+}
+
+// SetLessonContext has chat requests carry the lesson passages that best match the message
+func (h *LessonHandler) SetLessonContext(lessonContext LessonContext) {
+	h.lessonContext = lessonContext
+}
+
+// SetChatRecorder has chat answers kept and returned with a message_id to rate or escalate them by
+func (h *LessonHandler) SetChatRecorder(chatRecorder ChatRecorder) {
+	h.chatRecorder = chatRecorder
+}
+
+// SetLearnerMemory has new chat sessions carry the learner's memory
+func (h *LessonHandler) SetLearnerMemory(memory LearnerMemory) {
+	h.memory = memory
+}
+
+// educatorChatRequest builds the request for a chat message with everything known about the
+// learner and the lesson
+func (h *LessonHandler) educatorChatRequest(userID, lessonID uuid.UUID, message string, sessionID *uuid.UUID) intelligence.EducatorChatRequest {
+	chatReq := intelligence.EducatorChatRequest{
+		Message:       message,
+		LessonID:      lessonID,
+		SessionID:     sessionID,
+		Preferences:   h.learnerPreferences(userID),
+		WeakTopics:    h.learnerWeakTopics(userID),
+		LessonContext: h.chatLessonContext(userID, lessonID, message),
+	}
+	if sessionID == nil {
+		chatReq.LearnerMemory = h.learnerMemory(userID)
+	}
+	return chatReq
+}
+
+// recordChat keeps a chat exchange and returns the message_id to rate it by. An answer that
+// cannot be kept is still returned, without one.
+func (h *LessonHandler) recordChat(userID, lessonID uuid.UUID, message string, chatResp *intelligence.EducatorChatResponse) (uuid.UUID, bool) {
+	if h.chatRecorder == nil {
+		return uuid.Nil, false
+	}
+	messageID, err := h.chatRecorder.RecordChatMessage(userID, lessonID, chatResp.SessionID, message, chatResp.Response)
+	if err != nil {
+		log.Printf("Error recording chat message for user %s: %v", userID, err)
+		return uuid.Nil, false
+	}
+	return messageID, true
+}
+
+// chatLessonContext returns the passages to ground a chat answer in, or none when they cannot
+// be read: the tutor still answers, from the lesson ID alone
+func (h *LessonHandler) chatLessonContext(userID, lessonID uuid.UUID, message string) []intelligence.LessonContextChunk {
+	if h.lessonContext == nil {
+		return nil
+	}
+	chunks, err := h.lessonContext.LessonContext(userID, lessonID, message)
+	if err != nil {
+		if !errors.Is(err, services.ErrLessonNotFound) {
+			log.Printf("Error getting lesson context for lesson %s: %v", lessonID, err)
+		}
+		return nil
+	}
+	passages := make([]intelligence.LessonContextChunk, 0, len(chunks))
+	for _, chunk := range chunks {
+		passages = append(passages, intelligence.LessonContextChunk{
+			Source:  chunk.Source,
+			Heading: chunk.Heading,
+			Content: chunk.Content,
+			Rank:    chunk.Rank,
+		})
+	}
+	return passages
+}
+
+// learnerWeakTopics returns the user's weak topics, or none when they cannot be read
+func (h *LessonHandler) learnerWeakTopics(userID uuid.UUID) []string {
+	if h.weakTopics == nil {
+		return []string{}
+	}
+	topics, err := h.weakTopics.WeakTopicNames(userID)
+	if err != nil {
+		log.Printf("Error getting weak topics for user %s: %v", userID, err)
+		return []string{}
+	}
+	return topics
+}
+
+// learnerMemory returns what the tutor remembers about the user, or nothing when it cannot be
+// read
+func (h *LessonHandler) learnerMemory(userID uuid.UUID) string {
+	if h.memory == nil {
+		return ""
+	}
+	memory, err := h.memory.LearnerMemory(userID)
+	if err != nil {
+		log.Printf("Error getting learner memory for user %s: %v", userID, err)
+		return ""
+	}
+	return memory.Summary
+}
+
+// learnerPreferences returns the user's preferences, or none when they cannot be read: a
+// request without them still gets an answer
+func (h *LessonHandler) learnerPreferences(userID uuid.UUID) map[string]interface{} {
+	if h.preferences == nil {
+		return map[string]interface{}{}
+	}
+	preferences, err := h.preferences.LearnerPreferences(userID)
+	if err != nil {
+		log.Printf("Error getting learner preferences for user %s: %v", userID, err)
+		return map[string]interface{}{}
+	}
+	return preferences
+}
+
+// levelLocked answers requests for lessons above the user's current level
+func levelLocked(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error":   "level_locked",
+		"message": "Reach this lesson's level to open it, or add ?preview=true to see its outline.",
+	})
+}
+
+// chatLessonRefused answers a chat about a lesson the user cannot open, before any of it is sent
+// to the tutor: 404 when it is hidden or missing, 403 level_locked when it is above their level.
+// It reports whether it answered.
+func (h *LessonHandler) chatLessonRefused(c *fiber.Ctx, lessonID, userID uuid.UUID) (bool, error) {
+	_, err := h.lessonService.GetLesson(lessonID, userID, false)
+	switch {
+	case err == nil:
+		return false, nil
+	case errors.Is(err, services.ErrLevelLocked):
+		return true, levelLocked(c)
+	case errors.Is(err, services.ErrLessonNotFound):
+		return true, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	log.Printf("Error getting lesson %s for chat: %v", lessonID, err)
+	return true, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to send chat message",
+	})
+}
+
+// GetLessonsByLevel handles GET /ngs/levels/:level/lessons?preview=&lesson_type=&cursor=&limit=
 func (h *LessonHandler) GetLessonsByLevel(c *fiber.Ctx) error {
-	// Get user ID from header
-	userIDStr := c.Get("X-User-Id")
+	// Get user ID from the request identity
+	userIDStr := middleware.GetIdentity(c).UserID
 	if userIDStr == "" {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Missing user ID",
@@ -59,25 +263,62 @@ func (h *LessonHandler) GetLessonsByLevel(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get lessons
-	lessons, err := h.lessonService.GetLessonsByLevel(level, userID)
+	page, err := api.ParsePage(c)
+	if err != nil {
+		return pageError(c, err)
+	}
+
+	// Get lessons; a locked level is only shown as a preview
+	lessons, err := h.lessonService.GetLessonsByLevel(level, userID, c.QueryBool("preview", false), c.Query("lesson_type"), page.Fetch())
+	if errors.Is(err, services.ErrLevelLocked) {
+		return levelLocked(c)
+	}
+	if errors.Is(err, api.ErrInvalidPage) {
+		return pageError(c, err)
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"level":   level,
-		"lessons": lessons,
-		"count":   len(lessons),
+	lessons, hasMore, extra := nextPage(lessons, page, func(l models.LessonWithCompletion) api.Cursor {
+		return api.Cursor{ID: l.ID}
 	})
+	extra["level"] = level
+	return c.JSON(listResponse("lessons", lessons, hasMore, extra))
 }
 
-// GetLesson handles GET /ngs/lessons/:id
+// GetCompletedLessons handles GET /ngs/lessons/completions
+func (h *LessonHandler) GetCompletedLessons(c *fiber.Ctx) error {
+	userIDStr := middleware.GetIdentity(c).UserID
+	if userIDStr == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Missing user ID",
+		})
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID format",
+		})
+	}
+
+	completions, err := h.lessonService.GetCompletedLessons(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(completions)
+}
+
+// GetLesson handles GET /ngs/lessons/:id?preview=
 func (h *LessonHandler) GetLesson(c *fiber.Ctx) error {
-	// Get user ID from header
-	userIDStr := c.Get("X-User-Id")
+	// Get user ID from the request identity
+	userIDStr := middleware.GetIdentity(c).UserID
 	if userIDStr == "" {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Missing user ID",
@@ -100,21 +341,27 @@ func (h *LessonHandler) GetLesson(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get lesson
-	lesson, err := h.lessonService.GetLesson(lessonID, userID)
+	// Get lesson; a locked lesson is only shown as a preview
+	lesson, err := h.lessonService.GetLesson(lessonID, userID, c.QueryBool("preview", false))
+	if errors.Is(err, services.ErrLevelLocked) {
+		return levelLocked(c)
+	}
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
+	if hidesQuizAnswers(c) {
+		lesson.Metadata = services.HideQuizAnswers(lesson.Metadata)
+	}
 
 	return c.JSON(lesson)
 }
 
 // CompleteLessonHandler handles POST /ngs/lessons/:id/complete
 func (h *LessonHandler) CompleteLessonHandler(c *fiber.Ctx) error {
-	// Get user ID from header
-	userIDStr := c.Get("X-User-Id")
+	// Get user ID from the request identity
+	userIDStr := middleware.GetIdentity(c).UserID
 	if userIDStr == "" {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Missing user ID",
@@ -148,8 +395,46 @@ func (h *LessonHandler) CompleteLessonHandler(c *fiber.Ctx) error {
 	// Set lesson ID from path
 	req.LessonID = lessonID
 
-	// Complete lesson
+	return h.completeLesson(c, userID, req)
+}
+
+// CompleteLegacyLesson handles POST /ngs/complete-lesson, the older form of
+// POST /ngs/lessons/:id/complete taking lesson_id in the body. It completes the lesson the same
+// way, level gate and completion criteria included.
+func (h *LessonHandler) CompleteLegacyLesson(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.CompleteLessonRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if req.LessonID == uuid.Nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "lesson_id is required",
+		})
+	}
+
+	return h.completeLesson(c, userID, req)
+}
+
+// completeLesson completes req.LessonID for the user and answers with the completion
+func (h *LessonHandler) completeLesson(c *fiber.Ctx, userID uuid.UUID, req models.CompleteLessonRequest) error {
 	completion, err := h.lessonService.CompleteLesson(userID, req)
+	var criteriaErr *services.CompletionCriteriaError
+	if errors.As(err, &criteriaErr) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":          "Lesson completion criteria not met",
+			"unmet_criteria": criteriaErr.Unmet,
+		})
+	}
+	if errors.Is(err, services.ErrLevelLocked) {
+		return levelLocked(c)
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -162,10 +447,10 @@ func (h *LessonHandler) CompleteLessonHandler(c *fiber.Ctx) error {
 	})
 }
 
-// GetReflections handles GET /ngs/reflections
+// GetReflections handles GET /ngs/reflections?from=&to=&cursor=&limit=
 func (h *LessonHandler) GetReflections(c *fiber.Ctx) error {
-	// Get user ID from header
-	userIDStr := c.Get("X-User-Id")
+	// Get user ID from the request identity
+	userIDStr := middleware.GetIdentity(c).UserID
 	if userIDStr == "" {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Missing user ID",
@@ -179,30 +464,29 @@ func (h *LessonHandler) GetReflections(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get limit from query parameter
-	limit := c.QueryInt("limit", 20)
-	if limit > 100 {
-		limit = 100
+	page, err := api.ParsePage(c)
+	if err != nil {
+		return pageError(c, err)
 	}
 
 	// Get reflections
-	reflections, err := h.lessonService.GetUserReflections(userID, limit)
+	reflections, err := h.lessonService.GetUserReflections(userID, page.Fetch())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"reflections": reflections,
-		"count":       len(reflections),
+	reflections, hasMore, extra := nextPage(reflections, page, func(r models.UserReflection) api.Cursor {
+		return api.Cursor{At: r.CreatedAt, ID: r.ID}
 	})
+	return c.JSON(listResponse("reflections", reflections, hasMore, extra))
 }
 
 // SubmitReflection handles POST /ngs/reflections
 func (h *LessonHandler) SubmitReflection(c *fiber.Ctx) error {
-	// Get user ID from header
-	userIDStr := c.Get("X-User-Id")
+	// Get user ID from the request identity
+	userIDStr := middleware.GetIdentity(c).UserID
 	if userIDStr == "" {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Missing user ID",
@@ -245,24 +529,18 @@ func (h *LessonHandler) SubmitReflection(c *fiber.Ctx) error {
 	})
 }
 
+// GenerateLesson handles POST /ngs/lessons/:id/generate. The content is published to the lesson
+// every learner reads, so only educators and admins may regenerate it, and the request carries
+// nothing about them as a learner.
 func (h *LessonHandler) GenerateLesson(c *fiber.Ctx) error {
-	// Get user info from headers
-	userIDStr := c.Get("X-User-Id")
-	userEmail := c.Get("X-User-Email")
-	userRole := c.Get("X-User-Role")
-	
-	if userIDStr == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Missing user ID",
-		})
-	}
-
-	userID, err := uuid.Parse(userIDStr)
+	userID, err := getEducatorID(c)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid user ID format",
-		})
+		return err
 	}
+	identity := middleware.GetIdentity(c)
+	userIDStr := identity.UserID
+	userEmail := identity.Email
+	userRole := identity.Role
 
 	// Get lesson ID from path parameter
 	lessonIDStr := c.Params("id")
@@ -273,20 +551,21 @@ func (h *LessonHandler) GenerateLesson(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get lesson details from database
-	lesson, err := h.lessonService.GetLesson(lessonID, userID)
+	// Get lesson details from database. Generation needs only the lesson's outline, which an
+	// educator may see at any level.
+	lesson, err := h.lessonService.GetLesson(lessonID, userID, true)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Lesson not found",
 		})
 	}
 
+	// The lesson is shared, so it is written for its level rather than for whoever asked
 	learnerProfile := intelligence.LearnerProfile{
-		XP:           0, // Will be fetched from user_progress
 		CurrentLevel: lesson.LevelID,
 		WeakTopics:   []string{},
 		PriorLessons: []string{},
-		Preferences:  make(map[string]interface{}),
+		Preferences:  map[string]interface{}{},
 	}
 
 	genReq := intelligence.GenerateLessonRequest{
@@ -297,6 +576,7 @@ func (h *LessonHandler) GenerateLesson(c *fiber.Ctx) error {
 			TargetMinutes:           lesson.EstimatedMinutes,
 			Prereqs:                 []string{},
 			RequireEthicsGuardrails: true,
+			IncludeAccessibility:    true,
 		},
 	}
 
@@ -306,6 +586,7 @@ func (h *LessonHandler) GenerateLesson(c *fiber.Ctx) error {
 	if correlationID := c.Get("X-Correlation-ID"); correlationID != "" {
 		ctx = context.WithValue(ctx, "correlation_id", correlationID)
 	}
+	ctx = intelligence.WithOrg(ctx, strings.Clone(middleware.GetIdentity(c).OrgID))
 
 	genResp, err := h.intelligenceClient.GenerateLesson(ctx, genReq, userIDStr, userEmail, userRole)
 	if err != nil {
@@ -321,7 +602,34 @@ func (h *LessonHandler) GenerateLesson(c *fiber.Ctx) error {
 		})
 	}
 
-	err = h.lessonService.UpdateLessonContent(lessonID, genResp.ContentMarkdown, metadataJSON, genResp.Version)
+	recordUsage(c, services.MeterLLMTokens, int64(genResp.TokensUsed))
+
+	// A lesson that does not fit the structured lesson schema is quarantined, not published
+	if err := services.ValidateGeneratedLesson(genResp, genReq.Constraints); err != nil {
+		log.Printf("Generated lesson %s quarantined: %v", lessonID, err)
+		h.lessonService.QuarantineGeneratedLesson(lessonID, userID, genResp, err)
+		var lessonErr *services.GeneratedLessonError
+		errors.As(err, &lessonErr)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error":    "invalid_generation",
+			"message":  "The intelligence service returned a lesson that does not match the lesson schema; it was quarantined and not published",
+			"problems": lessonErr.Problems,
+		})
+	}
+
+	// The lesson is only published once it passes the guardrail checklist
+	guardrailReport := services.CheckGeneratedLesson(genResp, genReq.Constraints)
+	if !guardrailReport.Passed {
+		log.Printf("Generated lesson %s blocked by guardrails: %s", lessonID, guardrailReport)
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":      "guardrails_failed",
+			"message":    "The generated lesson failed its safety checks and was not published",
+			"guardrails": guardrailReport,
+		})
+	}
+
+	accessibility := lessonAccessibility(genResp.StructuredLesson)
+	err = h.lessonService.UpdateLessonContent(lessonID, genResp.ContentMarkdown, metadataJSON, userID, accessibility)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to store lesson content: " + err.Error(),
@@ -336,14 +644,16 @@ func (h *LessonHandler) GenerateLesson(c *fiber.Ctx) error {
 		"provider":          genResp.Provider,
 		"latency_ms":        genResp.LatencyMs,
 		"version":           genResp.Version,
+		"accessibility":     accessibility,
+		"guardrails":        guardrailReport,
 		"message":           "Lesson generated successfully",
 	})
 }
 
 // GetLessonContent handles GET /ngs/lessons/:id/content
 func (h *LessonHandler) GetLessonContent(c *fiber.Ctx) error {
-	// Get user ID from header
-	userIDStr := c.Get("X-User-Id")
+	// Get user ID from the request identity
+	userIDStr := middleware.GetIdentity(c).UserID
 	if userIDStr == "" {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Missing user ID",
@@ -367,13 +677,27 @@ func (h *LessonHandler) GetLessonContent(c *fiber.Ctx) error {
 	}
 
 	// Get lesson with content
-	lesson, err := h.lessonService.GetLesson(lessonID, userID)
+	lesson, err := h.lessonService.GetLesson(lessonID, userID, false)
+	if errors.Is(err, services.ErrLevelLocked) {
+		return levelLocked(c)
+	}
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Lesson not found",
 		})
 	}
 
+	accessibility, err := h.lessonService.GetLessonAccessibility(lessonID)
+	if err != nil {
+		log.Printf("Error getting accessibility for lesson %s: %v", lessonID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get lesson content",
+		})
+	}
+
+	if hidesQuizAnswers(c) {
+		lesson.Metadata = services.HideQuizAnswers(lesson.Metadata)
+	}
 	var metadata map[string]interface{}
 	if lesson.Metadata != nil {
 		if err := json.Unmarshal(lesson.Metadata, &metadata); err != nil {
@@ -389,14 +713,51 @@ func (h *LessonHandler) GetLessonContent(c *fiber.Ctx) error {
 		"level_id":         lesson.LevelID,
 		"xp_reward":        lesson.XPReward,
 		"estimated_minutes": lesson.EstimatedMinutes,
+		"accessibility":    accessibility,
 	})
 }
 
+// lessonAccessibility maps generated accessibility metadata, falling back to the lesson
+// summary and the visual descriptions themselves when the generator left gaps
+func lessonAccessibility(lesson intelligence.StructuredLesson) *models.LessonAccessibility {
+	accessibility := &models.LessonAccessibility{
+		PlainLanguageSummary: lesson.Summary,
+		AltText:              []models.VisualAltText{},
+	}
+
+	generated := map[string]string{}
+	if lesson.Accessibility != nil {
+		if lesson.Accessibility.PlainLanguageSummary != "" {
+			accessibility.PlainLanguageSummary = lesson.Accessibility.PlainLanguageSummary
+		}
+		if lesson.Accessibility.ReadingLevel != nil {
+			accessibility.ReadingLevel = *lesson.Accessibility.ReadingLevel
+		}
+		for _, alt := range lesson.Accessibility.AltText {
+			generated[alt.Visual] = alt.AltText
+		}
+	}
+
+	for _, visual := range lesson.Teach.Visuals {
+		altText := generated[visual]
+		if altText == "" {
+			altText = visual
+		}
+		accessibility.AltText = append(accessibility.AltText, models.VisualAltText{
+			Visual:  visual,
+			AltText: altText,
+		})
+	}
+
+	return accessibility
+}
+
 func (h *LessonHandler) SendEducatorChatMessage(c *fiber.Ctx) error {
-	// Get user info from headers
-	userIDStr := c.Get("X-User-Id")
-	userEmail := c.Get("X-User-Email")
-	userRole := c.Get("X-User-Role")
+	// Get user info from the request identity
+	identity := middleware.GetIdentity(c)
+	userIDStr := identity.UserID
+	userEmail := identity.Email
+	userRole := identity.Role
 	
 	if userIDStr == "" {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
@@ -436,19 +797,19 @@ func (h *LessonHandler) SendEducatorChatMessage(c *fiber.Ctx) error {
 			"error": "Message is required",
 		})
 	}
-
-	chatReq := intelligence.EducatorChatRequest{
-		Message:   req.Message,
-		LessonID:  lessonID,
-		SessionID: req.SessionID,
+	if refused, err := h.chatLessonRefused(c, lessonID, userID); refused {
+		return err
 	}
 
+	chatReq := h.educatorChatRequest(userID, lessonID, req.Message, req.SessionID)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	if correlationID := c.Get("X-Correlation-ID"); correlationID != "" {
 		ctx = context.WithValue(ctx, "correlation_id", correlationID)
 	}
+	ctx = intelligence.WithOrg(ctx, strings.Clone(middleware.GetIdentity(c).OrgID))
 
 	chatResp, err := h.intelligenceClient.SendEducatorChatMessage(ctx, chatReq, userIDStr, userEmail, userRole)
 	if err != nil {
@@ -457,11 +818,18 @@ func (h *LessonHandler) SendEducatorChatMessage(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
+	recordUsage(c, services.MeterLLMTokens, int64(chatResp.TokensUsed))
+
+	result := fiber.Map{
 		"response":    chatResp.Response,
 		"session_id":  chatResp.SessionID,
 		"lesson_id":   chatResp.LessonID,
 		"tokens_used": chatResp.TokensUsed,
+		"provider":    chatResp.Provider,
 		"latency_ms":  chatResp.LatencyMs,
-	})
+	}
+	if messageID, ok := h.recordChat(userID, lessonID, req.Message, chatResp); ok {
+		result["message_id"] = messageID
+	}
+	return c.JSON(result)
 }
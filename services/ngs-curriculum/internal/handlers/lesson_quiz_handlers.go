@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SubmitLessonQuiz handles POST /ngs/lessons/:id/quiz
+// Answers are graded against the lesson's quiz; the best attempt's score counts towards completion
+func (h *LessonHandler) SubmitLessonQuiz(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	lessonID, err := lessonIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.SubmitLessonQuizRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	attempt, err := h.lessonService.SubmitLessonQuiz(lessonID, userID, req.Answers)
+	switch {
+	case errors.Is(err, services.ErrLevelLocked):
+		return levelLocked(c)
+	case errors.Is(err, services.ErrLessonNotFound), errors.Is(err, services.ErrNoLessonQuiz):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		log.Printf("Error grading quiz of lesson %s: %v", lessonID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to grade quiz",
+		})
+	}
+
+	return c.JSON(attempt)
+}
+
+// hidesQuizAnswers reports whether lessons sent to the caller leave out quiz answer keys.
+// Educators and admins see them; learners' quizzes are graded by the service.
+func hidesQuizAnswers(c *fiber.Ctx) bool {
+	role := middleware.GetIdentity(c).Role
+	return role != "educator" && role != "admin"
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strconv"
+
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type LessonVersionHandler struct {
+	versionService *services.LessonVersionService
+}
+
+func NewLessonVersionHandler(versionService *services.LessonVersionService) *LessonVersionHandler {
+	return &LessonVersionHandler{versionService: versionService}
+}
+
+// versionParam parses a content version path parameter
+func versionParam(c *fiber.Ctx, name string) (int, error) {
+	version, err := strconv.Atoi(c.Params(name))
+	if err != nil || version < 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "Invalid lesson version")
+	}
+	return version, nil
+}
+
+// ListLessonVersions handles GET /ngs/lessons/:id/versions (educator or admin)
+func (h *LessonVersionHandler) ListLessonVersions(c *fiber.Ctx) error {
+	if _, err := getEducatorID(c); err != nil {
+		return err
+	}
+	lessonID, err := lessonIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	versions, err := h.versionService.ListVersions(lessonID)
+	if err != nil {
+		log.Printf("Error listing versions of lesson %s: %v", lessonID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list lesson versions",
+		})
+	}
+	return c.JSON(listResponse("versions", versions, false, nil))
+}
+
+// DiffLessonVersions handles GET /ngs/lessons/:id/versions/:a/diff/:b (educator or admin)
+// The diff is from version a to version b
+func (h *LessonVersionHandler) DiffLessonVersions(c *fiber.Ctx) error {
+	if _, err := getEducatorID(c); err != nil {
+		return err
+	}
+	lessonID, err := lessonIDParam(c)
+	if err != nil {
+		return err
+	}
+	from, err := versionParam(c, "a")
+	if err != nil {
+		return err
+	}
+	to, err := versionParam(c, "b")
+	if err != nil {
+		return err
+	}
+
+	diff, err := h.versionService.Diff(lessonID, from, to)
+	if errors.Is(err, services.ErrLessonVersionNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		log.Printf("Error diffing versions of lesson %s: %v", lessonID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to diff lesson versions",
+		})
+	}
+	return c.JSON(diff)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"noble-ngs-curriculum/internal/api"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// List endpoints answer {"<items>": [...], "count": n, "has_more": bool}. Items are an empty array
+// rather than null when nothing matches, and come in the order the README documents for the
+// endpoint. Limited lists fetch limit+1 rows so has_more is exact. Cursor-paginated lists also
+// answer next_cursor while there are more.
+
+// listResponse returns the body of a list endpoint, with any extra fields merged in
+func listResponse[T any](key string, items []T, hasMore bool, extra fiber.Map) fiber.Map {
+	if items == nil {
+		items = []T{}
+	}
+	body := fiber.Map{
+		key:        items,
+		"count":    len(items),
+		"has_more": hasMore,
+	}
+	for k, v := range extra {
+		body[k] = v
+	}
+	return body
+}
+
+// trimPage cuts items fetched with limit+1 rows back to limit, reporting whether there were more
+func trimPage[T any](items []T, limit int) ([]T, bool) {
+	if len(items) > limit {
+		return items[:limit], true
+	}
+	return items, false
+}
+
+// nextPage cuts items fetched with the page's limit+1 rows back to the limit. While there are
+// more it returns next_cursor, naming the last item by cursor, for listResponse's extra fields.
+func nextPage[T any](items []T, page api.Page, cursor func(T) api.Cursor) ([]T, bool, fiber.Map) {
+	items, hasMore := trimPage(items, page.Limit)
+	if !hasMore {
+		return items, false, fiber.Map{}
+	}
+	return items, true, fiber.Map{"next_cursor": cursor(items[len(items)-1]).String()}
+}
+
+// pageError answers a list request whose cursor, limit or filters are invalid
+func pageError(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type LLMCostHandler struct {
+	llmCostService *services.LLMCostService
+	clock          services.Clock
+}
+
+func NewLLMCostHandler(llmCostService *services.LLMCostService, clock services.Clock) *LLMCostHandler {
+	return &LLMCostHandler{
+		llmCostService: llmCostService,
+		clock:          clock,
+	}
+}
+
+// ListLLMCosts handles GET /ngs/admin/llm-costs?from=YYYY-MM-DD&to=YYYY-MM-DD&org_id=&limit=
+// (admin): LLM spend per day and organization, newest day first, the last 30 days by default
+func (h *LLMCostHandler) ListLLMCosts(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	limit := c.QueryInt("limit", 100)
+	if limit < 1 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	orgID := c.Query("org_id")
+	if orgID != "" {
+		if err := services.ValidateOrgID(orgID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	start, end, err := services.ParseDayRange(c.Query("from"), c.Query("to"), h.clock.Now())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	costs, err := h.llmCostService.DailyCosts(start, end, orgID, limit+1)
+	if err != nil {
+		return err
+	}
+	costs, hasMore := trimPage(costs, limit)
+
+	var total float64
+	for _, cost := range costs {
+		total += cost.CostUSD
+	}
+	return c.JSON(listResponse("costs", costs, hasMore, fiber.Map{
+		"from":           start.Format("2006-01-02"),
+		"to":             end.Format("2006-01-02"),
+		"total_cost_usd": total,
+	}))
+}
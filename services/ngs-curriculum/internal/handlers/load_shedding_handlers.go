@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"strconv"
+
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// shedRetryAfterSeconds is the Retry-After sent with shed requests
+const shedRetryAfterSeconds = 5
+
+var shedRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ngs_load_shed_requests_total",
+		Help: "Low-priority requests rejected with 503 while the service was saturated, by route.",
+	},
+	[]string{"route"},
+)
+
+func init() {
+	prometheus.MustRegister(shedRequests)
+}
+
+type LoadShedHandler struct {
+	loadShedder *services.LoadShedder
+}
+
+func NewLoadShedHandler(loadShedder *services.LoadShedder) *LoadShedHandler {
+	return &LoadShedHandler{
+		loadShedder: loadShedder,
+	}
+}
+
+// LowPriority marks a route that can be turned away under load, such as leaderboards and
+// analytics. While the service is saturated it answers 503 instead of running the handler.
+func (h *LoadShedHandler) LowPriority(c *fiber.Ctx) error {
+	if !h.loadShedder.Shedding() {
+		return c.Next()
+	}
+
+	shedRequests.WithLabelValues(c.Route().Path).Inc()
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(shedRetryAfterSeconds))
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"error":               "overloaded",
+		"message":             "This is temporarily unavailable while the service is busy. Your progress is not affected; please try again shortly.",
+		"retry_after_seconds": shedRetryAfterSeconds,
+	})
+}
+
+// GetLoad handles GET /ngs/admin/load (admin)
+func (h *LoadShedHandler) GetLoad(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	return c.JSON(h.loadShedder.Status())
+}
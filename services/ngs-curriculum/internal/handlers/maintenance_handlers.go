@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type MaintenanceHandler struct {
+	maintenanceService *services.MaintenanceService
+}
+
+func NewMaintenanceHandler(maintenanceService *services.MaintenanceService) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		maintenanceService: maintenanceService,
+	}
+}
+
+// Middleware answers learner endpoints with 503 while maintenance mode is on. Exempt paths and
+// admin callers pass through so the service can be checked and switched back.
+func (h *MaintenanceHandler) Middleware(c *fiber.Ctx) error {
+	if services.MaintenanceExempt(c.Path()) || middleware.GetIdentity(c).Role == "admin" {
+		return c.Next()
+	}
+
+	status := h.maintenanceService.Status()
+	if !status.Enabled {
+		return c.Next()
+	}
+
+	if status.RetryAfterSeconds != nil {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(*status.RetryAfterSeconds))
+	}
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"error":               "maintenance",
+		"message":             status.Message,
+		"retry_after_seconds": status.RetryAfterSeconds,
+	})
+}
+
+// GetMaintenance handles GET /ngs/admin/maintenance (admin)
+func (h *MaintenanceHandler) GetMaintenance(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	return c.JSON(h.maintenanceService.Status())
+}
+
+// SetMaintenance handles PUT /ngs/admin/maintenance (admin)
+func (h *MaintenanceHandler) SetMaintenance(c *fiber.Ctx) error {
+	userID, err := getUserIDWithRole(c, "admin")
+	if err != nil {
+		return err
+	}
+
+	var req models.SetMaintenanceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	status, err := h.maintenanceService.SetStatus(userID, req)
+	if errors.Is(err, services.ErrInvalidMaintenance) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(status)
+}
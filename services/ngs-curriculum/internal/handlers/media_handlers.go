@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type MediaHandler struct {
+	mediaService *services.MediaService
+}
+
+func NewMediaHandler(mediaService *services.MediaService) *MediaHandler {
+	return &MediaHandler{
+		mediaService: mediaService,
+	}
+}
+
+// mediaError maps media service errors to HTTP responses
+func mediaError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrLessonNotFound), errors.Is(err, services.ErrMediaNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrLevelLocked):
+		return levelLocked(c)
+	case errors.Is(err, services.ErrMediaDuplicate):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidMedia), errors.Is(err, services.ErrInvalidSearch):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("Media error: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to process lesson media",
+	})
+}
+
+// lessonIDParam parses the :id lesson path parameter
+func lessonIDParam(c *fiber.Ctx) (uuid.UUID, error) {
+	lessonID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return uuid.Nil, fiber.NewError(fiber.StatusBadRequest, "Invalid lesson ID format")
+	}
+	return lessonID, nil
+}
+
+// AttachMedia handles POST /ngs/lessons/:id/media (educator)
+// Transcription runs in the background; poll GET /ngs/lessons/:id/media for its status
+func (h *MediaHandler) AttachMedia(c *fiber.Ctx) error {
+	userID, err := getEducatorID(c)
+	if err != nil {
+		return err
+	}
+
+	lessonID, err := lessonIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.AttachMediaRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	identity := middleware.GetIdentity(c)
+	media, err := h.mediaService.AttachMedia(lessonID, userID, identity.Email, identity.Role, req)
+	if err != nil {
+		return mediaError(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(media)
+}
+
+// ListLessonMedia handles GET /ngs/lessons/:id/media
+func (h *MediaHandler) ListLessonMedia(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	lessonID, err := lessonIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	media, err := h.mediaService.ListLessonMedia(lessonID, userID)
+	if err != nil {
+		return mediaError(c, err)
+	}
+
+	return c.JSON(listResponse("media", media, false, nil))
+}
+
+// GetLessonArtifacts handles GET /ngs/lessons/:id/artifacts?type=notes|snippets. Without a type
+// it returns the transcripts and summaries of the lesson's media.
+func (h *MediaHandler) GetLessonArtifacts(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	lessonID, err := lessonIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	switch artifactType := c.Query("type"); artifactType {
+	case "":
+	case services.StudyArtifactNotes:
+		notes, markdown, err := h.mediaService.GetLessonNotes(lessonID, userID)
+		if err != nil {
+			return mediaError(c, err)
+		}
+		return c.JSON(listResponse("artifacts", notes, false, fiber.Map{"type": artifactType, "markdown": markdown}))
+	case services.StudyArtifactSnippets:
+		snippets, err := h.mediaService.GetLessonCodeSnippets(lessonID, userID)
+		if err != nil {
+			return mediaError(c, err)
+		}
+		return c.JSON(listResponse("artifacts", snippets, false, fiber.Map{"type": artifactType}))
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": services.ErrInvalidArtifactType.Error()})
+	}
+
+	artifacts, err := h.mediaService.GetLessonArtifacts(lessonID, userID)
+	if err != nil {
+		return mediaError(c, err)
+	}
+
+	return c.JSON(listResponse("artifacts", artifacts, false, nil))
+}
+
+// Search handles GET /ngs/search?q=&limit=
+func (h *MediaHandler) Search(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	results, err := h.mediaService.Search(userID, c.Query("q"), c.QueryInt("limit", 0))
+	if err != nil {
+		return mediaError(c, err)
+	}
+
+	return c.JSON(results)
+}
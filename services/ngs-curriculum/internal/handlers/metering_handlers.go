@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Locals set by the metering middleware for recordUsage
+const (
+	usageOrgLocal   = "usage_org"
+	usageMeterLocal = "usage_meter"
+)
+
+type MeteringHandler struct {
+	meteringService *services.MeteringService
+	clock           services.Clock
+}
+
+func NewMeteringHandler(meteringService *services.MeteringService, clock services.Clock) *MeteringHandler {
+	return &MeteringHandler{
+		meteringService: meteringService,
+		clock:           clock,
+	}
+}
+
+// Middleware meters requests from callers in an organization as API calls, refusing them with 429 once the
+// organization's monthly quota is used up. Requests without it are not metered.
+func (h *MeteringHandler) Middleware(c *fiber.Ctx) error {
+	orgID := middleware.GetIdentity(c).OrgID
+	if orgID == "" {
+		return c.Next()
+	}
+	if err := services.ValidateOrgID(orgID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	allowed, resetsAt, err := h.meteringService.CheckQuota(orgID)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		retryAfter := int(resetsAt.Sub(h.clock.Now()).Seconds()) + 1
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error":     "quota_exceeded",
+			"message":   "Your organization has used this month's API calls.",
+			"resets_at": resetsAt,
+		})
+	}
+
+	h.meteringService.Record(orgID, services.MeterAPICalls, 1)
+	c.Locals(usageOrgLocal, orgID)
+	c.Locals(usageMeterLocal, h.meteringService)
+	return c.Next()
+}
+
+// usageRecorder returns recordUsage for the request, for work that outlives the request's
+// context, such as a streamed response
+func usageRecorder(c *fiber.Ctx) func(meter string, quantity int64) {
+	orgID, _ := c.Locals(usageOrgLocal).(string)
+	orgID = strings.Clone(orgID)
+	meteringService, _ := c.Locals(usageMeterLocal).(*services.MeteringService)
+	return func(meter string, quantity int64) {
+		if orgID == "" || meteringService == nil {
+			return
+		}
+		meteringService.Record(orgID, meter, quantity)
+	}
+}
+
+// recordUsage adds to the calling organization's meter; it does nothing for requests the
+// metering middleware did not meter
+func recordUsage(c *fiber.Ctx, meter string, quantity int64) {
+	usageRecorder(c)(meter, quantity)
+}
+
+// ListUsage handles GET /ngs/admin/usage?month=YYYY-MM&limit= (admin): every organization's
+// usage for the month, the current one by default
+func (h *MeteringHandler) ListUsage(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	limit := c.QueryInt("limit", 100)
+	if limit < 1 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	month, err := services.ParseMonth(c.Query("month"), h.clock.Now())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	usage, err := h.meteringService.MonthlyUsage(month, "", limit+1)
+	if err != nil {
+		return err
+	}
+	usage, hasMore := trimPage(usage, limit)
+
+	return c.JSON(listResponse("organizations", usage, hasMore, fiber.Map{"month": month.Format("2006-01")}))
+}
+
+// GetOrgUsage handles GET /ngs/admin/orgs/:id/usage?month=YYYY-MM (admin)
+func (h *MeteringHandler) GetOrgUsage(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+	orgID := c.Params("id")
+	if err := services.ValidateOrgID(orgID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	month, err := services.ParseMonth(c.Query("month"), h.clock.Now())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	usage, err := h.meteringService.OrgMonthlyUsage(month, orgID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(usage)
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"errors"
+
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type OrgHandler struct {
+	orgService      *services.OrganizationService
+	meteringService *services.MeteringService
+	clock           services.Clock
+}
+
+func NewOrgHandler(orgService *services.OrganizationService, meteringService *services.MeteringService, clock services.Clock) *OrgHandler {
+	return &OrgHandler{
+		orgService:      orgService,
+		meteringService: meteringService,
+		clock:           clock,
+	}
+}
+
+// orgError maps organization errors to responses
+func orgError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrEducatorNotFound), errors.Is(err, services.ErrInvitationNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrEducatorExists):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidOrgSettings), errors.Is(err, services.ErrInvalidEducator),
+		errors.Is(err, services.ErrInvalidEducatorList), errors.Is(err, services.ErrInvalidMonth):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return err
+}
+
+// orgParams returns the caller's organization and the caller, who must have one
+// of roles (any role when none are given)
+func orgParams(c *fiber.Ctx, roles ...string) (string, uuid.UUID, error) {
+	var userID uuid.UUID
+	var err error
+	if len(roles) == 0 {
+		userID, err = getUserID(c)
+	} else {
+		userID, err = getUserIDWithRole(c, roles...)
+	}
+	if err != nil {
+		return "", uuid.Nil, err
+	}
+
+	orgID := middleware.GetIdentity(c).OrgID
+	if orgID == "" {
+		return "", uuid.Nil, fiber.NewError(fiber.StatusBadRequest, "Caller is not in an organization")
+	}
+	if err := services.ValidateOrgID(orgID); err != nil {
+		return "", uuid.Nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return orgID, userID, nil
+}
+
+// getOrgAdmin returns the organization an org admin (or platform admin) is managing
+func getOrgAdmin(c *fiber.Ctx) (string, uuid.UUID, error) {
+	return orgParams(c, "org_admin", "admin")
+}
+
+// GetSettings handles GET /ngs/org/settings (any member of the organization)
+func (h *OrgHandler) GetSettings(c *fiber.Ctx) error {
+	orgID, _, err := orgParams(c)
+	if err != nil {
+		return err
+	}
+
+	settings, err := h.orgService.GetSettings(orgID)
+	if err != nil {
+		return orgError(c, err)
+	}
+
+	return c.JSON(settings)
+}
+
+// UpdateSettings handles PUT /ngs/org/settings (org admin)
+func (h *OrgHandler) UpdateSettings(c *fiber.Ctx) error {
+	orgID, adminID, err := getOrgAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.UpdateOrgSettingsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	settings, err := h.orgService.UpdateSettings(orgID, adminID, req)
+	if err != nil {
+		return orgError(c, err)
+	}
+
+	return c.JSON(settings)
+}
+
+// ListEducators handles GET /ngs/org/educators?status=&limit= (org admin)
+func (h *OrgHandler) ListEducators(c *fiber.Ctx) error {
+	orgID, _, err := getOrgAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	limit := c.QueryInt("limit", 100)
+	if limit < 1 {
+		limit = 100
+	}
+	if limit > 500 {
+		limit = 500
+	}
+
+	educators, err := h.orgService.ListEducators(orgID, c.Query("status"), limit+1)
+	if err != nil {
+		return orgError(c, err)
+	}
+	educators, hasMore := trimPage(educators, limit)
+
+	return c.JSON(listResponse("educators", educators, hasMore, nil))
+}
+
+// InviteEducator handles POST /ngs/org/educators (org admin)
+func (h *OrgHandler) InviteEducator(c *fiber.Ctx) error {
+	orgID, adminID, err := getOrgAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.InviteEducatorRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	educator, err := h.orgService.InviteEducator(orgID, adminID, req)
+	if err != nil {
+		return orgError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(educator)
+}
+
+// RemoveEducator handles DELETE /ngs/org/educators/:userId (org admin)
+func (h *OrgHandler) RemoveEducator(c *fiber.Ctx) error {
+	orgID, adminID, err := getOrgAdmin(c)
+	if err != nil {
+		return err
+	}
+	userID, err := uuid.Parse(c.Params("userId"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID format")
+	}
+
+	if err := h.orgService.RemoveEducator(orgID, adminID, userID); err != nil {
+		return orgError(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// AcceptInvitation handles POST /ngs/org/invitation/accept (the invited user)
+func (h *OrgHandler) AcceptInvitation(c *fiber.Ctx) error {
+	orgID, userID, err := orgParams(c)
+	if err != nil {
+		return err
+	}
+
+	educator, err := h.orgService.AcceptInvitation(orgID, userID)
+	if err != nil {
+		return orgError(c, err)
+	}
+
+	return c.JSON(educator)
+}
+
+// GetUsage handles GET /ngs/org/usage?month=YYYY-MM (org admin): the organization's metered usage
+func (h *OrgHandler) GetUsage(c *fiber.Ctx) error {
+	orgID, _, err := getOrgAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	month, err := services.ParseMonth(c.Query("month"), h.clock.Now())
+	if err != nil {
+		return orgError(c, err)
+	}
+	usage, err := h.meteringService.OrgMonthlyUsage(month, orgID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(usage)
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"log"
+
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PrestigeHandler struct {
+	prestigeService *services.PrestigeService
+}
+
+func NewPrestigeHandler(prestigeService *services.PrestigeService) *PrestigeHandler {
+	return &PrestigeHandler{
+		prestigeService: prestigeService,
+	}
+}
+
+// GetPrestige handles GET /ngs/prestige
+func (h *PrestigeHandler) GetPrestige(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	status, err := h.prestigeService.GetPrestige(userID)
+	if err != nil {
+		log.Printf("Error getting prestige for user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get prestige",
+		})
+	}
+	return c.JSON(status)
+}
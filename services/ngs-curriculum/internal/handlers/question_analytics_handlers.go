@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"log"
+
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type QuestionAnalyticsHandler struct {
+	analyticsService *services.QuestionAnalyticsService
+}
+
+func NewQuestionAnalyticsHandler(analyticsService *services.QuestionAnalyticsService) *QuestionAnalyticsHandler {
+	return &QuestionAnalyticsHandler{
+		analyticsService: analyticsService,
+	}
+}
+
+// GetQuestionStats handles GET /ngs/admin/analytics/questions (educator or admin)
+// Query: level, flagged=true for flagged questions only, min_attempts before flagging, limit
+func (h *QuestionAnalyticsHandler) GetQuestionStats(c *fiber.Ctx) error {
+	if _, err := getEducatorID(c); err != nil {
+		return err
+	}
+
+	level := c.QueryInt("level", 0)
+	minAttempts := c.QueryInt("min_attempts", services.DefaultQuestionMinAttempts)
+	if level < 0 || minAttempts < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "level must not be negative and min_attempts must be at least 1",
+		})
+	}
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	stats, err := h.analyticsService.GetQuestionStats(level, minAttempts, c.QueryBool("flagged", false))
+	if err != nil {
+		log.Printf("Error getting question analytics: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get question analytics",
+		})
+	}
+	stats, hasMore := trimPage(stats, limit)
+	return c.JSON(listResponse("questions", stats, hasMore, fiber.Map{"min_attempts": minAttempts}))
+}
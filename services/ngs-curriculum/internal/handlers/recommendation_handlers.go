@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"log"
+
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type RecommendationHandler struct {
+	recommendationService *services.RecommendationService
+}
+
+func NewRecommendationHandler(recommendationService *services.RecommendationService) *RecommendationHandler {
+	return &RecommendationHandler{
+		recommendationService: recommendationService,
+	}
+}
+
+// GetContinue handles GET /ngs/continue
+// Returns the one action behind the app's "continue learning" button
+func (h *RecommendationHandler) GetContinue(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	action, err := h.recommendationService.NextAction(userID)
+	if err != nil {
+		log.Printf("Error computing next action for user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to compute next action",
+		})
+	}
+
+	return c.JSON(action)
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// manualReconciliationTimeout bounds an XP reconciliation run started from the admin API
+const manualReconciliationTimeout = 10 * time.Minute
+
+type ReconciliationHandler struct {
+	reconciliationService *services.ReconciliationService
+}
+
+func NewReconciliationHandler(reconciliationService *services.ReconciliationService) *ReconciliationHandler {
+	return &ReconciliationHandler{
+		reconciliationService: reconciliationService,
+	}
+}
+
+// GetXPReconciliation handles GET /ngs/admin/xp-reconciliation (admin)
+func (h *ReconciliationHandler) GetXPReconciliation(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	return c.JSON(h.reconciliationService.Status())
+}
+
+// RunXPReconciliation handles POST /ngs/admin/xp-reconciliation/run?dry_run=true (admin)
+func (h *ReconciliationHandler) RunXPReconciliation(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), manualReconciliationTimeout)
+	defer cancel()
+
+	run := h.reconciliationService.Reconcile(ctx, c.QueryBool("dry_run", false), services.ReconciliationAdmin)
+	switch {
+	case run.Error != "":
+		return c.Status(fiber.StatusInternalServerError).JSON(run)
+	case run.Skipped:
+		return c.Status(fiber.StatusConflict).JSON(run)
+	}
+	return c.JSON(run)
+}
+
+// ReconcileUserXP handles POST /ngs/admin/reconcile-xp/:userId?dry_run=true (admin)
+func (h *ReconciliationHandler) ReconcileUserXP(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	userID, err := uuid.Parse(c.Params("userId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID format",
+		})
+	}
+
+	result, err := h.reconciliationService.ReconcileUser(userID, c.QueryBool("dry_run", false))
+	if errors.Is(err, services.ErrProgressNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User progress not found",
+		})
+	}
+	if err != nil {
+		log.Printf("Error reconciling XP for user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reconcile XP",
+		})
+	}
+	return c.JSON(result)
+}
+
+// ListXPRepairs handles GET /ngs/admin/xp-reconciliation/repairs?user_id=&limit= (admin)
+func (h *ReconciliationHandler) ListXPRepairs(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	var userID *uuid.UUID
+	if raw := c.Query("user_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid user ID format",
+			})
+		}
+		userID = &id
+	}
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 {
+		limit = 50
+	}
+	if limit > 200 {
+		limit = 200
+	}
+
+	repairs, err := h.reconciliationService.ListXPRepairs(userID, limit+1)
+	if err != nil {
+		return err
+	}
+	repairs, hasMore := trimPage(repairs, limit)
+
+	return c.JSON(listResponse("repairs", repairs, hasMore, nil))
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type RemediationHandler struct {
+	remediationService *services.RemediationService
+}
+
+func NewRemediationHandler(remediationService *services.RemediationService) *RemediationHandler {
+	return &RemediationHandler{
+		remediationService: remediationService,
+	}
+}
+
+// ListRemediation handles GET /ngs/remediation
+func (h *RemediationHandler) ListRemediation(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	lessons, err := h.remediationService.ListRemediation(userID, limit+1)
+	if err != nil {
+		log.Printf("Error listing remediation lessons for user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list remediation lessons",
+		})
+	}
+	lessons, hasMore := trimPage(lessons, limit)
+	return c.JSON(listResponse("lessons", lessons, hasMore, nil))
+}
+
+// GetRemediation handles GET /ngs/remediation/:id
+func (h *RemediationHandler) GetRemediation(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid remediation ID format",
+		})
+	}
+
+	lesson, err := h.remediationService.GetRemediation(userID, id)
+	if errors.Is(err, services.ErrRemediationNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		log.Printf("Error getting remediation lesson %s: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get remediation lesson",
+		})
+	}
+	return c.JSON(lesson)
+}
+
+// CompleteRemediation handles POST /ngs/remediation/:id/complete
+func (h *RemediationHandler) CompleteRemediation(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid remediation ID format",
+		})
+	}
+
+	lesson, err := h.remediationService.CompleteRemediation(userID, id)
+	switch {
+	case errors.Is(err, services.ErrRemediationNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrRemediationNotReady):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		log.Printf("Error completing remediation lesson %s: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to complete remediation lesson",
+		})
+	}
+	return c.JSON(lesson)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// manualRetentionTimeout bounds a retention run started from the admin API
+const manualRetentionTimeout = 5 * time.Minute
+
+type RetentionHandler struct {
+	retentionService *services.RetentionService
+}
+
+func NewRetentionHandler(retentionService *services.RetentionService) *RetentionHandler {
+	return &RetentionHandler{
+		retentionService: retentionService,
+	}
+}
+
+// GetRetentionPolicies handles GET /ngs/admin/retention (admin)
+func (h *RetentionHandler) GetRetentionPolicies(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	return c.JSON(listResponse("policies", h.retentionService.Policies(), false, nil))
+}
+
+// RunRetentionPolicy handles POST /ngs/admin/retention/:policy/run (admin)
+func (h *RetentionHandler) RunRetentionPolicy(c *fiber.Ctx) error {
+	if _, err := getUserIDWithRole(c, "admin"); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), manualRetentionTimeout)
+	defer cancel()
+
+	run, err := h.retentionService.RunPolicy(ctx, c.Params("policy"))
+	switch {
+	case errors.Is(err, services.ErrUnknownRetentionPolicy):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrRetentionPolicyDisabled):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return err
+	}
+
+	if run.Error != "" {
+		return c.Status(fiber.StatusInternalServerError).JSON(run)
+	}
+	return c.JSON(run)
+}
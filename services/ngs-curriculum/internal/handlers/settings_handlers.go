@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SettingsHandler struct {
+	settingsService *services.SettingsService
+}
+
+func NewSettingsHandler(settingsService *services.SettingsService) *SettingsHandler {
+	return &SettingsHandler{
+		settingsService: settingsService,
+	}
+}
+
+// GetSettings handles GET /ngs/settings
+func (h *SettingsHandler) GetSettings(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	settings, err := h.settingsService.GetSettings(userID)
+	if err != nil {
+		log.Printf("Error getting settings for user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get settings",
+		})
+	}
+
+	return c.JSON(settings)
+}
+
+// UpdateSettings handles PATCH /ngs/settings
+func (h *SettingsHandler) UpdateSettings(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.UpdateSettingsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	settings, err := h.settingsService.UpdateSettings(userID, req)
+	if errors.Is(err, services.ErrInvalidSettings) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if errors.Is(err, services.ErrRestrictedForMinor) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		log.Printf("Error updating settings for user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update settings",
+		})
+	}
+
+	return c.JSON(settings)
+}
+
+// GetLearningPreferences handles GET /ngs/settings/learning
+func (h *SettingsHandler) GetLearningPreferences(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	prefs, err := h.settingsService.GetLearningPreferences(userID)
+	if err != nil {
+		log.Printf("Error getting learning preferences for user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get learning preferences",
+		})
+	}
+
+	return c.JSON(prefs)
+}
+
+// UpdateLearningPreferences handles PUT /ngs/settings/learning
+func (h *SettingsHandler) UpdateLearningPreferences(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.LearningPreferencesPatch
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	prefs, err := h.settingsService.UpdateLearningPreferences(userID, req)
+	if errors.Is(err, services.ErrInvalidSettings) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		log.Printf("Error updating learning preferences for user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update learning preferences",
+		})
+	}
+
+	return c.JSON(prefs)
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// snapshotTimeout bounds taking or restoring one learner snapshot
+const snapshotTimeout = 2 * time.Minute
+
+type SnapshotHandler struct {
+	snapshotService *services.SnapshotService
+}
+
+func NewSnapshotHandler(snapshotService *services.SnapshotService) *SnapshotHandler {
+	return &SnapshotHandler{
+		snapshotService: snapshotService,
+	}
+}
+
+// snapshotError maps snapshot service errors to responses
+func snapshotError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrSnapshotsDisabled):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidSnapshot), errors.Is(err, services.ErrSnapshotSignature):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrSnapshotRestore):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+	return err
+}
+
+// SnapshotUser handles POST /ngs/admin/users/:id/snapshot (admin)
+func (h *SnapshotHandler) SnapshotUser(c *fiber.Ctx) error {
+	adminID, err := getUserIDWithRole(c, "admin")
+	if err != nil {
+		return err
+	}
+	userID, err := accountUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.SnapshotRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+	defer cancel()
+
+	snapshot, err := h.snapshotService.Snapshot(ctx, userID, adminID, req.Note)
+	if err != nil {
+		return snapshotError(c, err)
+	}
+
+	return c.JSON(snapshot)
+}
+
+// RestoreUser handles POST /ngs/admin/users/:id/restore (admin); the body is a snapshot bundle
+func (h *SnapshotHandler) RestoreUser(c *fiber.Ctx) error {
+	adminID, err := getUserIDWithRole(c, "admin")
+	if err != nil {
+		return err
+	}
+	userID, err := accountUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var snapshot models.LearnerSnapshot
+	if err := c.BodyParser(&snapshot); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid snapshot bundle",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+	defer cancel()
+
+	restore, err := h.snapshotService.Restore(ctx, userID, adminID, snapshot)
+	if err != nil {
+		return snapshotError(c, err)
+	}
+
+	return c.JSON(restore)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SummaryHandler struct {
+	summaryService *services.SummaryService
+}
+
+func NewSummaryHandler(summaryService *services.SummaryService) *SummaryHandler {
+	return &SummaryHandler{
+		summaryService: summaryService,
+	}
+}
+
+// GetWeeklySummary handles GET /ngs/summary/weekly
+func (h *SummaryHandler) GetWeeklySummary(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	summary, err := h.summaryService.GetWeeklySummary(userID)
+	if err != nil {
+		log.Printf("Error building weekly summary for user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to build weekly summary",
+		})
+	}
+
+	return c.JSON(summary)
+}
+
+// GetActivityHeatmap handles GET /ngs/activity/heatmap?year=2025
+func (h *SummaryHandler) GetActivityHeatmap(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	currentYear := time.Now().Year()
+	year := c.QueryInt("year", currentYear)
+	if year < 2000 || year > currentYear+1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid year",
+		})
+	}
+
+	heatmap, err := h.summaryService.GetActivityHeatmap(userID, year)
+	if err != nil {
+		log.Printf("Error building activity heatmap for user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get activity",
+		})
+	}
+
+	return c.JSON(heatmap)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type UsageHandler struct {
+	usageService *services.UsageService
+}
+
+func NewUsageHandler(usageService *services.UsageService) *UsageHandler {
+	return &UsageHandler{
+		usageService: usageService,
+	}
+}
+
+// GetUsage handles GET /ngs/me/usage
+// The tier is the caller's and the rate-limit window comes from headers the gateway forwards
+func (h *UsageHandler) GetUsage(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	usage, err := h.usageService.GetUsage(userID, middleware.GetIdentity(c).Tier)
+	if err != nil {
+		log.Printf("Error getting usage for user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get usage",
+		})
+	}
+	usage.RateLimit = forwardedRateLimit(c)
+
+	return c.JSON(usage)
+}
+
+// forwardedRateLimit reads the gateway's X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset (Unix seconds) request headers, or returns nil without them
+func forwardedRateLimit(c *fiber.Ctx) *models.Quota {
+	limit, err := strconv.Atoi(c.Get("X-RateLimit-Limit"))
+	if err != nil || limit < 0 {
+		return nil
+	}
+	remaining, err := strconv.Atoi(c.Get("X-RateLimit-Remaining"))
+	if err != nil || remaining < 0 || remaining > limit {
+		return nil
+	}
+
+	var resetsAt *time.Time
+	if reset, err := strconv.ParseInt(c.Get("X-RateLimit-Reset"), 10, 64); err == nil && reset > 0 {
+		t := time.Unix(reset, 0).UTC()
+		resetsAt = &t
+	}
+	quota := services.NewQuota(limit-remaining, limit, resetsAt)
+	return &quota
+}
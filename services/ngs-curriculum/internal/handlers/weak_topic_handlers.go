@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"log"
+
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type WeakTopicHandler struct {
+	weakTopicService *services.WeakTopicService
+}
+
+func NewWeakTopicHandler(weakTopicService *services.WeakTopicService) *WeakTopicHandler {
+	return &WeakTopicHandler{
+		weakTopicService: weakTopicService,
+	}
+}
+
+// GetWeakTopics handles GET /ngs/weak-topics
+func (h *WeakTopicHandler) GetWeakTopics(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	weak, err := h.weakTopicService.GetWeakTopics(userID)
+	if err != nil {
+		log.Printf("Error getting weak topics for user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get weak topics",
+		})
+	}
+	return c.JSON(weak)
+}
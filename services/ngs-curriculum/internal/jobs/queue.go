@@ -0,0 +1,363 @@
+// Package jobs runs background work on a shared pool of workers. Each job belongs to a priority
+// class: grading before generation before digests. A class can be capped below the pool size
+// so one kind of work cannot take every worker, and a job that has waited MaxWait jumps ahead
+// of higher classes so low-priority work is never starved.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Priority classes, highest first
+const (
+	ClassGrading    = "grading"
+	ClassGeneration = "generation"
+	ClassDigest     = "digest"
+)
+
+// Classes lists the priority classes from highest to lowest priority
+var Classes = []string{ClassGrading, ClassGeneration, ClassDigest}
+
+var (
+	ErrUnknownClass = errors.New("unknown job class")
+	ErrQueueClosed  = errors.New("job queue is shutting down")
+)
+
+var (
+	jobsQueued = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ngs_jobs_queued",
+			Help: "Background jobs waiting for a worker, by class.",
+		},
+		[]string{"class"},
+	)
+
+	jobsRunning = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ngs_jobs_running",
+			Help: "Background jobs running, by class.",
+		},
+		[]string{"class"},
+	)
+
+	jobWait = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ngs_job_wait_seconds",
+			Help:    "Time background jobs waited for a worker, by class.",
+			Buckets: []float64{0.01, 0.1, 0.5, 1, 5, 15, 30, 60, 120, 300, 600},
+		},
+		[]string{"class"},
+	)
+
+	jobsPromoted = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_jobs_promoted_total",
+			Help: "Jobs started ahead of higher classes because they had waited the maximum wait, by class.",
+		},
+		[]string{"class"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(jobsQueued, jobsRunning, jobWait, jobsPromoted)
+}
+
+// Settings size the pool. Classes missing from ClassLimits may use every worker; a limit of 0
+// pauses a class.
+type Settings struct {
+	Workers     int
+	MaxWait     time.Duration
+	ClassLimits map[string]int
+}
+
+// Validate checks settings before they are applied
+func (s Settings) Validate() error {
+	if s.Workers < 1 {
+		return errors.New("workers must be at least 1")
+	}
+	if s.MaxWait <= 0 {
+		return errors.New("max wait must be positive")
+	}
+	for class, limit := range s.ClassLimits {
+		if !IsClass(class) {
+			return fmt.Errorf("%w %q", ErrUnknownClass, class)
+		}
+		if limit < 0 {
+			return fmt.Errorf("%s limit must not be negative", class)
+		}
+	}
+	return nil
+}
+
+// limit returns how many jobs of class may run at once
+func (s Settings) limit(class string) int {
+	if limit, ok := s.ClassLimits[class]; ok && limit < s.Workers {
+		return limit
+	}
+	return s.Workers
+}
+
+// IsClass reports whether class is a priority class
+func IsClass(class string) bool {
+	for _, c := range Classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseClassLimits reads per-class limits such as "grading=4,generation=2,digest=1"
+func ParseClassLimits(spec string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		class, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not class=limit", field)
+		}
+		class = strings.TrimSpace(class)
+		if !IsClass(class) {
+			return nil, fmt.Errorf("%w %q", ErrUnknownClass, class)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("limit for %s must be a non-negative integer", class)
+		}
+		limits[class] = limit
+	}
+	return limits, nil
+}
+
+// ClassStats is one class's share of the queue
+type ClassStats struct {
+	Class         string
+	MaxConcurrent int
+	Running       int
+	Queued        int
+	OldestWait    time.Duration // How long the class's oldest queued job has waited
+}
+
+type job struct {
+	class    string
+	name     string
+	run      func(ctx context.Context)
+	queuedAt time.Time
+}
+
+// Queue runs jobs on a shared pool of workers in priority order
+type Queue struct {
+	now func() time.Time
+
+	mu       sync.Mutex
+	settings Settings
+	pending  map[string][]*job // FIFO per class
+	running  map[string]int
+	closing  bool
+
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func New(settings Settings) *Queue {
+	return NewWithClock(settings, time.Now)
+}
+
+// NewWithClock returns a queue that measures waits with now
+func NewWithClock(settings Settings, now func() time.Time) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Queue{
+		now:      now,
+		settings: settings,
+		pending:  make(map[string][]*job),
+		running:  make(map[string]int),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Settings returns the queue's current settings
+func (q *Queue) Settings() Settings {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.settings
+}
+
+// Configure applies new settings. Running jobs are left alone; lower limits take effect as
+// they finish, and higher ones start queued jobs straight away.
+func (q *Queue) Configure(settings Settings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.settings = settings
+	q.dispatch()
+	return nil
+}
+
+// Submit queues run under class. name identifies the job in logs. run's context is cancelled
+// when a drain runs out of time.
+func (q *Queue) Submit(class, name string, run func(ctx context.Context)) error {
+	if !IsClass(class) {
+		return fmt.Errorf("%w %q", ErrUnknownClass, class)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closing {
+		return ErrQueueClosed
+	}
+	q.pending[class] = append(q.pending[class], &job{class: class, name: name, run: run, queuedAt: q.now()})
+	jobsQueued.WithLabelValues(class).Inc()
+	q.dispatch()
+	return nil
+}
+
+// dispatch starts queued jobs while workers are free. A class whose oldest job has waited
+// MaxWait goes first, oldest first; otherwise the highest class with room does. Callers hold mu.
+func (q *Queue) dispatch() {
+	for !q.closing && q.runningTotal() < q.settings.Workers {
+		class, promoted := q.next()
+		if class == "" {
+			return
+		}
+		next := q.pending[class][0]
+		q.pending[class] = q.pending[class][1:]
+		q.running[class]++
+
+		waited := q.now().Sub(next.queuedAt)
+		jobsQueued.WithLabelValues(class).Dec()
+		jobsRunning.WithLabelValues(class).Inc()
+		jobWait.WithLabelValues(class).Observe(waited.Seconds())
+		if promoted {
+			jobsPromoted.WithLabelValues(class).Inc()
+		}
+
+		q.wg.Add(1)
+		go q.run(next)
+	}
+}
+
+// next picks the class to start a job from, reporting whether it was promoted for waiting
+func (q *Queue) next() (string, bool) {
+	now := q.now()
+	starved, starvedSince := "", time.Time{}
+	preferred := ""
+	for _, class := range Classes {
+		jobs := q.pending[class]
+		if len(jobs) == 0 || q.running[class] >= q.settings.limit(class) {
+			continue
+		}
+		if preferred == "" {
+			preferred = class
+		}
+		if now.Sub(jobs[0].queuedAt) >= q.settings.MaxWait && (starved == "" || jobs[0].queuedAt.Before(starvedSince)) {
+			starved, starvedSince = class, jobs[0].queuedAt
+		}
+	}
+	if starved != "" {
+		return starved, starved != preferred
+	}
+	return preferred, false
+}
+
+func (q *Queue) runningTotal() int {
+	total := 0
+	for _, n := range q.running {
+		total += n
+	}
+	return total
+}
+
+func (q *Queue) run(j *job) {
+	defer q.wg.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job %s (%s) panicked: %v", j.name, j.class, r)
+		}
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		q.running[j.class]--
+		jobsRunning.WithLabelValues(j.class).Dec()
+		q.dispatch()
+	}()
+	j.run(q.ctx)
+}
+
+// Depth returns how many jobs are waiting for a worker
+func (q *Queue) Depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	depth := 0
+	for _, jobs := range q.pending {
+		depth += len(jobs)
+	}
+	return depth
+}
+
+// Stats returns each class's limit, running and queued jobs, in priority order
+func (q *Queue) Stats() []ClassStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	stats := make([]ClassStats, 0, len(Classes))
+	for _, class := range Classes {
+		s := ClassStats{
+			Class:         class,
+			MaxConcurrent: q.settings.limit(class),
+			Running:       q.running[class],
+			Queued:        len(q.pending[class]),
+		}
+		if s.Queued > 0 {
+			s.OldestWait = now.Sub(q.pending[class][0].queuedAt)
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// Drain stops queued jobs from starting and refuses new ones, then waits for running jobs. When
+// ctx ends first, running jobs are cancelled and Drain returns once they have stopped. Jobs
+// that never started are dropped; their owners must be able to pick them up again.
+func (q *Queue) Drain(ctx context.Context) error {
+	q.mu.Lock()
+	q.closing = true
+	for class, jobs := range q.pending {
+		if len(jobs) > 0 {
+			log.Printf("Dropping %d queued %s jobs: shutting down", len(jobs), class)
+			jobsQueued.WithLabelValues(class).Sub(float64(len(jobs)))
+		}
+		delete(q.pending, class)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,126 @@
+// Package middleware authenticates requests. Auth reads the caller's identity once, from a
+// verified bearer JWT or, in header mode, from the gateway's X-User-* headers, and stores it in
+// the request's locals. Handlers read identity only through GetIdentity, never from headers.
+package middleware
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Authentication modes, from AUTH_MODE
+const (
+	// ModeHeaders trusts the gateway's X-User-* headers (signed when GATEWAY_SIGNING_SECRET is
+	// set). Bearer tokens are still verified when sent, so clients can move over.
+	ModeHeaders = "headers"
+	// ModeJWT only takes identity from a verified bearer token; X-User-* headers are ignored
+	ModeJWT = "jwt"
+)
+
+const identityLocal = "identity"
+
+var authFailures = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ngs_auth_failures_total",
+		Help: "Requests refused for their bearer token, by reason (malformed, signature, expired or claims).",
+	},
+	[]string{"reason"},
+)
+
+func init() {
+	prometheus.MustRegister(authFailures)
+}
+
+// Identity is the authenticated caller. UserID is empty for anonymous requests.
+type Identity struct {
+	UserID string
+	Email  string
+	Role   string
+	Tier   string
+	OrgID  string
+}
+
+// GetIdentity returns the caller's identity, empty when Auth did not run or found none
+func GetIdentity(c *fiber.Ctx) Identity {
+	identity, _ := c.Locals(identityLocal).(Identity)
+	return identity
+}
+
+// SetIdentity replaces the caller's identity for the rest of the request, e.g. while
+// impersonating
+func SetIdentity(c *fiber.Ctx, identity Identity) {
+	c.Locals(identityLocal, identity)
+}
+
+// ValidMode reports whether mode is an AUTH_MODE
+func ValidMode(mode string) bool {
+	return mode == ModeHeaders || mode == ModeJWT
+}
+
+// Auth sets each request's identity. A bearer token in Authorization is verified with verifier
+// (nil refuses every token) and answered with 401 when it does not verify.
+func Auth(mode string, verifier *JWTVerifier) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if token, ok := bearerToken(c.Get(fiber.HeaderAuthorization)); ok {
+			if verifier == nil {
+				authFailures.WithLabelValues("signature").Inc()
+				return unauthorized(c, ErrTokenSignature)
+			}
+			claims, err := verifier.Verify(token)
+			if err != nil {
+				authFailures.WithLabelValues(failureReason(err)).Inc()
+				return unauthorized(c, err)
+			}
+			SetIdentity(c, Identity{
+				UserID: claims.Subject,
+				Email:  claims.Email,
+				Role:   claims.Role,
+				Tier:   claims.Tier,
+				OrgID:  claims.OrgID,
+			})
+			return c.Next()
+		}
+
+		if mode == ModeHeaders {
+			SetIdentity(c, Identity{
+				UserID: c.Get("X-User-Id"),
+				Email:  c.Get("X-User-Email"),
+				Role:   c.Get("X-User-Role"),
+				Tier:   c.Get("X-User-Tier"),
+				OrgID:  c.Get("X-Org-Id"),
+			})
+		} else {
+			SetIdentity(c, Identity{})
+		}
+		return c.Next()
+	}
+}
+
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func failureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrTokenExpired):
+		return "expired"
+	case errors.Is(err, ErrTokenClaims):
+		return "claims"
+	case errors.Is(err, ErrTokenSignature):
+		return "signature"
+	}
+	return "malformed"
+}
+
+func unauthorized(c *fiber.Ctx, err error) error {
+	c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+}
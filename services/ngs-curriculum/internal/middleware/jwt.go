@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrTokenMalformed = errors.New("malformed token")
+	ErrTokenSignature = errors.New("invalid token signature")
+	ErrTokenExpired   = errors.New("token expired or not yet valid")
+	ErrTokenClaims    = errors.New("invalid token claims")
+)
+
+// jwtMethods are the algorithms tokens may be signed with; any other alg, including none, is
+// refused before a key is looked up
+var jwtMethods = []string{"HS256", "RS256", "ES256"}
+
+// Claims are the JWT claims identity is read from
+type Claims struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+	Tier  string `json:"tier"`
+	OrgID string `json:"org_id"`
+	jwt.RegisteredClaims
+}
+
+// JWTVerifier checks bearer tokens signed with HS256 by one of its secrets, or with RS256 or
+// ES256 by its public key. A token's alg must match the key it is checked with, so a public key
+// can never be used as an HMAC secret.
+type JWTVerifier struct {
+	secrets   jwt.VerificationKeySet
+	publicKey crypto.PublicKey
+	parser    *jwt.Parser
+}
+
+// NewJWTVerifier returns a verifier for the comma-separated HS256 secrets and the PEM public key
+// (either may be empty, not both). Non-empty issuer and audience must match the token's.
+func NewJWTVerifier(secrets string, publicKeyPEM []byte, issuer, audience string, leeway time.Duration, now func() time.Time) (*JWTVerifier, error) {
+	v := &JWTVerifier{}
+	for _, secret := range strings.Split(secrets, ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			v.secrets.Keys = append(v.secrets.Keys, []byte(secret))
+		}
+	}
+	if len(publicKeyPEM) > 0 {
+		key, err := parsePublicKey(publicKeyPEM)
+		if err != nil {
+			return nil, err
+		}
+		v.publicKey = key
+	}
+	if len(v.secrets.Keys) == 0 && v.publicKey == nil {
+		return nil, errors.New("a JWT secret or public key is required")
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(jwtMethods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+		jwt.WithTimeFunc(now),
+	}
+	if issuer != "" {
+		options = append(options, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		options = append(options, jwt.WithAudience(audience))
+	}
+	v.parser = jwt.NewParser(options...)
+	return v, nil
+}
+
+// parsePublicKey reads an RSA or P-256 public key from a PUBLIC KEY or CERTIFICATE block
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block in JWT public key")
+	}
+	var key crypto.PublicKey
+	switch block.Type {
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWT public key: %w", err)
+		}
+		key = parsed
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWT certificate: %w", err)
+		}
+		key = cert.PublicKey
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q in JWT public key", block.Type)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return k, nil
+	case *ecdsa.PublicKey:
+		if k.Curve.Params().Name != "P-256" {
+			return nil, errors.New("JWT EC keys must be P-256 (ES256)")
+		}
+		return k, nil
+	}
+	return nil, errors.New("JWT public key must be RSA or EC P-256")
+}
+
+// Verify checks a token's signature, expiry, issuer and audience and returns its claims. exp
+// and sub are required.
+func (v *JWTVerifier) Verify(token string) (*Claims, error) {
+	var claims Claims
+	if _, err := v.parser.ParseWithClaims(token, &claims, v.key); err != nil {
+		return nil, tokenError(err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: sub is required", ErrTokenClaims)
+	}
+	return &claims, nil
+}
+
+// key returns the key for the token's alg: the secrets for HS256, the public key for RS256 or
+// ES256 when it is of that type
+func (v *JWTVerifier) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if len(v.secrets.Keys) > 0 {
+			return v.secrets, nil
+		}
+	case *jwt.SigningMethodRSA:
+		if key, ok := v.publicKey.(*rsa.PublicKey); ok {
+			return key, nil
+		}
+	case *jwt.SigningMethodECDSA:
+		if key, ok := v.publicKey.(*ecdsa.PublicKey); ok {
+			return key, nil
+		}
+	}
+	return nil, ErrTokenSignature
+}
+
+// tokenError sorts a parse failure into the reasons Auth reports
+func tokenError(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired), errors.Is(err, jwt.ErrTokenNotValidYet):
+		return ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenInvalidClaims):
+		return fmt.Errorf("%w: %v", ErrTokenClaims, err)
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return ErrTokenSignature
+	}
+	return ErrTokenMalformed
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AccountDeactivation is a period an account was deactivated for
+type AccountDeactivation struct {
+	ID                uuid.UUID  `json:"id"`
+	Reason            string     `json:"reason,omitempty"`
+	Source            string     `json:"source"` // admin or event
+	DeactivatedAt     time.Time  `json:"deactivated_at"`
+	ReactivatedAt     *time.Time `json:"reactivated_at,omitempty"`
+	ReactivatedSource string     `json:"reactivated_source,omitempty"`
+	GraceDays         int        `json:"grace_days"`
+}
+
+// AccountStatus is whether an account is deactivated, with its deactivations, newest first
+type AccountStatus struct {
+	UserID        uuid.UUID             `json:"user_id"`
+	Deactivated   bool                  `json:"deactivated"`
+	Deactivations []AccountDeactivation `json:"deactivations"`
+}
+
+// DeactivateAccountRequest is an admin deactivating an account
+type DeactivateAccountRequest struct {
+	Reason string `json:"reason"`
+}
+
+// AccountEvent is an account lifecycle event from the gateway. OccurredAt defaults to when
+// the event is received.
+type AccountEvent struct {
+	Type       string     `json:"type"` // account.deactivated or account.reactivated
+	UserID     uuid.UUID  `json:"user_id"`
+	Reason     string     `json:"reason"`
+	OccurredAt *time.Time `json:"occurred_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AlumniFeed is continuing-education content for users who have finished the curriculum. It
+// rotates weekly.
+type AlumniFeed struct {
+	UserID    uuid.UUID        `json:"user_id"`
+	Week      string           `json:"week"` // ISO week, e.g. 2026-W42
+	RotatesAt time.Time        `json:"rotates_at"`
+	Items     []AlumniFeedItem `json:"items"`
+}
+
+// AlumniFeedItem is one piece of alumni content
+type AlumniFeedItem struct {
+	Type        string     `json:"type"` // challenge, mentorship
+	Title       string     `json:"title"`
+	Body        string     `json:"body"`
+	LevelNumber int        `json:"level_number,omitempty"`
+	Difficulty  string     `json:"difficulty,omitempty"`
+	XPReward    int        `json:"xp_reward,omitempty"`
+	ResourceID  *uuid.UUID `json:"resource_id,omitempty"`
+	Endpoint    string     `json:"endpoint,omitempty"` // API path the client should open
+}
@@ -0,0 +1,46 @@
+package models
+
+import "github.com/google/uuid"
+
+// LessonTemplate is a starting point for authoring a lesson of a common type
+type LessonTemplate struct {
+	Type               string             `json:"type"` // tutorial, exercise, quiz, challenge, reflection
+	Name               string             `json:"name"`
+	Description        string             `json:"description"`
+	ContentMarkdown    string             `json:"content_markdown"`
+	HumanPractice      string             `json:"human_practice"`
+	ReflectionPrompt   string             `json:"reflection_prompt"`
+	XPReward           int                `json:"xp_reward"`
+	EstimatedMinutes   int                `json:"estimated_minutes"`
+	CompletionCriteria CompletionCriteria `json:"completion_criteria"`
+}
+
+// CloneLessonRequest copies a lesson. Without CohortID the copy is a new lesson in LevelID
+// (default: the source's level). With CohortID it is an overlay that replaces the source for
+// that cohort's members only.
+type CloneLessonRequest struct {
+	LevelID     *int       `json:"level_id,omitempty"`
+	LessonOrder *int       `json:"lesson_order,omitempty"`
+	Title       string     `json:"title,omitempty"`
+	CohortID    *uuid.UUID `json:"cohort_id,omitempty"`
+}
+
+// CreateLessonFromTemplateRequest creates a lesson from a built-in template
+type CreateLessonFromTemplateRequest struct {
+	Template    string     `json:"template"`
+	LevelID     int        `json:"level_id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	LessonOrder *int       `json:"lesson_order,omitempty"`
+	MinAgeBand  string     `json:"min_age_band,omitempty"`
+	CohortID    *uuid.UUID `json:"cohort_id,omitempty"` // Restrict the lesson to one cohort
+}
+
+// AuthoredLesson is a lesson created by cloning or from a template
+type AuthoredLesson struct {
+	Lesson
+	ClonedFrom       *uuid.UUID `json:"cloned_from,omitempty"`
+	OverlayOf        *uuid.UUID `json:"overlay_of,omitempty"`
+	OverlayCohortID  *uuid.UUID `json:"overlay_cohort_id,omitempty"`
+	ChallengesCopied int        `json:"challenges_copied"`
+}
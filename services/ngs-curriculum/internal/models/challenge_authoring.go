@@ -0,0 +1,39 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChallengeRequest creates or updates a challenge. On update, omitted fields are left unchanged;
+// a 0 limit, time limit or baseline goes back to the default, and test_cases or metadata of null
+// clears them.
+type ChallengeRequest struct {
+	LessonID          *uuid.UUID      `json:"lesson_id,omitempty"`
+	LevelID           *int            `json:"level_id,omitempty"`
+	Title             *string         `json:"title,omitempty"`
+	Description       *string         `json:"description,omitempty"`
+	ChallengeType     *string         `json:"challenge_type,omitempty"`
+	Difficulty        *string         `json:"difficulty,omitempty"`
+	StarterCode       *string         `json:"starter_code,omitempty"`
+	TestCases         json.RawMessage `json:"test_cases,omitempty"`
+	SolutionTemplate  *string         `json:"solution_template,omitempty"`
+	XPReward          *int            `json:"xp_reward,omitempty"`
+	TimeLimitMinutes  *int            `json:"time_limit_minutes,omitempty"`
+	Limits            *ResourceLimits `json:"limits,omitempty"`
+	BaselineRuntimeMs *float64        `json:"baseline_runtime_ms,omitempty"`
+	BaselineMemoryKB  *int64          `json:"baseline_memory_kb,omitempty"`
+	Tags              *[]string       `json:"tags,omitempty"`
+	Metadata          json.RawMessage `json:"metadata,omitempty"`
+	MinAgeBand        *string         `json:"min_age_band,omitempty"`
+}
+
+// AuthoredChallenge is a challenge as educators see it, with who last changed it
+type AuthoredChallenge struct {
+	Challenge
+	CreatedBy *uuid.UUID `json:"created_by,omitempty"`
+	UpdatedBy *uuid.UUID `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
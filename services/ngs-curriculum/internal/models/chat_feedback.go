@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChatFeedbackRequest rates a tutor chat answer
+type ChatFeedbackRequest struct {
+	Rating  string  `json:"rating"` // up or down
+	Comment *string `json:"comment,omitempty"`
+}
+
+// ChatFeedback is a learner's rating of a tutor chat answer; rating again replaces it
+type ChatFeedback struct {
+	MessageID uuid.UUID `json:"message_id"`
+	Rating    string    `json:"rating"`
+	Comment   *string   `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// EscalateChatRequest asks a human educator to follow up on a tutor chat answer
+type EscalateChatRequest struct {
+	Note *string `json:"note,omitempty"` // What the learner still needs
+}
+
+// ResolveTicketRequest closes an educator ticket with the educator's answer to the learner
+type ResolveTicketRequest struct {
+	Resolution string `json:"resolution"`
+}
+
+// EducatorTicket is a tutor chat answer a learner escalated to human educators, with the
+// exchange and the learner's rating of it
+type EducatorTicket struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	LessonID    uuid.UUID  `json:"lesson_id"`
+	LessonTitle string     `json:"lesson_title"`
+	MessageID   uuid.UUID  `json:"message_id"`
+	SessionID   uuid.UUID  `json:"session_id"`
+	Message     string     `json:"message"`
+	Response    string     `json:"response"`
+	Rating      *string    `json:"rating,omitempty"`
+	Note        *string    `json:"note,omitempty"`
+	Status      string     `json:"status"` // open, claimed, resolved
+	AssignedTo  *uuid.UUID `json:"assigned_to,omitempty"`
+	Resolution  *string    `json:"resolution,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+}
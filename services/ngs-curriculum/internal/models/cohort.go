@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Cohort is an educator-managed group of learners
+type Cohort struct {
+	ID             uuid.UUID `json:"id"`
+	Name           string    `json:"name"`
+	EducatorID     uuid.UUID `json:"educator_id"`
+	ContentAgeBand *string   `json:"content_age_band"` // nil keeps each member's own band
+	MemberCount    int       `json:"member_count"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// CreateCohortRequest creates a cohort owned by the calling educator
+type CreateCohortRequest struct {
+	Name           string  `json:"name"`
+	ContentAgeBand *string `json:"content_age_band,omitempty"`
+}
+
+// CohortLessonOverride reorders or hides one lesson for a cohort. Only optional lessons can be
+// hidden.
+type CohortLessonOverride struct {
+	LessonID    uuid.UUID `json:"lesson_id"`
+	LessonOrder *int      `json:"lesson_order,omitempty"` // nil keeps the lesson's own order
+	Hidden      bool      `json:"hidden"`
+}
+
+// CohortCurriculum is a cohort's sequencing: per-lesson overrides plus optional tracks hidden
+// from its members
+type CohortCurriculum struct {
+	CohortID     uuid.UUID              `json:"cohort_id"`
+	Lessons      []CohortLessonOverride `json:"lessons"`
+	HiddenTracks []string               `json:"hidden_tracks"`
+	UpdatedAt    *time.Time             `json:"updated_at,omitempty"`
+}
+
+// SetCohortCurriculumRequest replaces a cohort's sequencing
+type SetCohortCurriculumRequest struct {
+	Lessons      []CohortLessonOverride `json:"lessons"`
+	HiddenTracks []string               `json:"hidden_tracks"`
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CohortAnalytics is an educator's view of how a cohort is getting on
+type CohortAnalytics struct {
+	CohortID    uuid.UUID `json:"cohort_id"`
+	MemberCount int       `json:"member_count"`
+	// AverageTimeSpentSeconds is the mean time spent on a completed lesson, over completions
+	// that recorded it; nil when none did
+	AverageTimeSpentSeconds *float64                `json:"average_time_spent_seconds"`
+	Levels                  []CohortLevelStats      `json:"levels"`
+	XPDistribution          CohortXPDistribution    `json:"xp_distribution"`
+	StuckLessons            []StuckLesson           `json:"stuck_lessons"`
+	ReflectionQuality       CohortReflectionQuality `json:"reflection_quality"`
+	StuckAfterDays          int                     `json:"stuck_after_days"`
+	GeneratedAt             time.Time               `json:"generated_at"`
+}
+
+// CohortLevelStats is how far the cohort has got through one level's lessons
+type CohortLevelStats struct {
+	LevelNumber int `json:"level_number"`
+	LessonCount int `json:"lesson_count"`
+	Completions int `json:"completions"`
+	// CompletionRate is the share of member and lesson pairs completed, 0-1
+	CompletionRate   float64 `json:"completion_rate"`
+	MembersStarted   int     `json:"members_started"`
+	MembersCompleted int     `json:"members_completed"`
+	// AverageTimeSpentSeconds is the mean time spent on a completed lesson of the level; nil
+	// when no completion recorded it
+	AverageTimeSpentSeconds *float64 `json:"average_time_spent_seconds"`
+}
+
+// CohortXPDistribution is how total XP is spread over the cohort's members
+type CohortXPDistribution struct {
+	Average float64    `json:"average"`
+	Median  float64    `json:"median"`
+	Max     int        `json:"max"`
+	Buckets []XPBucket `json:"buckets"`
+}
+
+// XPBucket counts the members with at least MinXP and less than MaxXP total XP. The last bucket
+// has no MaxXP.
+type XPBucket struct {
+	MinXP   int  `json:"min_xp"`
+	MaxXP   *int `json:"max_xp"`
+	Members int  `json:"members"`
+}
+
+// StuckLesson is a lesson members started but have neither completed nor touched for a while
+type StuckLesson struct {
+	LessonID       uuid.UUID `json:"lesson_id"`
+	LessonTitle    string    `json:"lesson_title"`
+	LevelNumber    int       `json:"level_number"`
+	MembersStarted int       `json:"members_started"`
+	MembersStuck   int       `json:"members_stuck"`
+	// StuckRate is the share of the members who started the lesson that are stuck on it, 0-1
+	StuckRate  float64   `json:"stuck_rate"`
+	StuckSince time.Time `json:"stuck_since"`
+}
+
+// CohortReflectionQuality is the weekly quality of the cohort's reflections and where it is going
+type CohortReflectionQuality struct {
+	// Trend is improving, declining, steady or insufficient_data
+	Trend string                  `json:"trend"`
+	Weeks []ReflectionQualityWeek `json:"weeks"`
+}
+
+// ReflectionQualityWeek is the reflections members wrote in the week starting WeekStart (Monday)
+type ReflectionQualityWeek struct {
+	WeekStart   time.Time `json:"week_start"`
+	Reflections int       `json:"reflections"`
+	// AverageQuality is the mean quality score, 0-1; nil in a week without reflections
+	AverageQuality *float64 `json:"average_quality"`
+	HighQuality    int      `json:"high_quality"`
+}
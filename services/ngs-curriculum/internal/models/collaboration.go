@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Collaboration statuses
+const (
+	CollaborationOpen      = "open"
+	CollaborationSubmitted = "submitted"
+)
+
+// CollaborationRole is one entry of a collaboration challenge's metadata.roles. XP is split
+// between members in proportion to their roles' weights (1 when unset).
+type CollaborationRole struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Weight      int    `json:"weight,omitempty"`
+	MinChars    int    `json:"min_chars,omitempty"` // shortest complete section
+}
+
+// CollaborationMember is a user holding one role in a collaboration, with their section
+type CollaborationMember struct {
+	UserID           uuid.UUID  `json:"user_id"`
+	Role             string     `json:"role"`
+	Section          string     `json:"section"`
+	SectionUpdatedAt *time.Time `json:"section_updated_at,omitempty"`
+	XPAwarded        int        `json:"xp_awarded,omitempty"`
+	JoinedAt         time.Time  `json:"joined_at"`
+}
+
+// Collaboration is a group attempt at a collaboration challenge
+type Collaboration struct {
+	ID          uuid.UUID             `json:"id"`
+	ChallengeID uuid.UUID             `json:"challenge_id"`
+	CreatedBy   uuid.UUID             `json:"created_by"`
+	Status      string                `json:"status"` // open or submitted
+	Roles       []CollaborationRole   `json:"roles"`
+	Members     []CollaborationMember `json:"members"`
+	// Roles without a member or with an incomplete section; submitting needs none
+	MissingRoles []string   `json:"missing_roles"`
+	SubmittedBy  *uuid.UUID `json:"submitted_by,omitempty"`
+	SubmittedAt  *time.Time `json:"submitted_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// CollaborationRoleRequest creates or joins a collaboration in a role
+type CollaborationRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// CollaborationSectionRequest replaces the caller's section
+type CollaborationSectionRequest struct {
+	Content string `json:"content"`
+}
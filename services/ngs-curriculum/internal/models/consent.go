@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GuardianConsent is a guardian consent request and its outcome
+type GuardianConsent struct {
+	ID            uuid.UUID  `json:"id"`
+	UserID        uuid.UUID  `json:"user_id"`
+	GuardianEmail string     `json:"guardian_email"`
+	Status        string     `json:"status"` // pending, granted, denied, superseded
+	AgeBand       string     `json:"age_band"`
+	RequestedAt   time.Time  `json:"requested_at"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	RespondedAt   *time.Time `json:"responded_at,omitempty"`
+	ConsentURL    string     `json:"consent_url,omitempty"` // Only returned when the request is created
+}
+
+// GuardianConsentView is what the guardian sees when opening a consent link
+type GuardianConsentView struct {
+	Status        string    `json:"status"`
+	AgeBand       string    `json:"age_band"`
+	GuardianEmail string    `json:"guardian_email"`
+	RequestedAt   time.Time `json:"requested_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	Expired       bool      `json:"expired"`
+}
+
+// ConsentStatus summarises a user's guardian consent state
+type ConsentStatus struct {
+	AgeBand  string           `json:"age_band"`
+	Required bool             `json:"required"`
+	Status   string           `json:"status"` // not_required, missing, pending, granted, denied
+	Latest   *GuardianConsent `json:"latest,omitempty"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LessonDraftFields are the lesson fields mapped from a document's front matter
+type LessonDraftFields struct {
+	Title            string   `json:"title,omitempty"`
+	Description      string   `json:"description,omitempty"`
+	LevelID          *int     `json:"level_id,omitempty"`
+	LessonOrder      *int     `json:"lesson_order,omitempty"`
+	LessonType       string   `json:"lesson_type,omitempty"`
+	XPReward         *int     `json:"xp_reward,omitempty"`
+	EstimatedMinutes *int     `json:"estimated_minutes,omitempty"`
+	IsRequired       *bool    `json:"is_required,omitempty"`
+	ReflectionPrompt string   `json:"reflection_prompt,omitempty"`
+	HumanPractice    string   `json:"human_practice,omitempty"`
+	MinAgeBand       string   `json:"min_age_band,omitempty"`
+	ExternalID       string   `json:"external_id,omitempty"`
+	Prerequisites    []string `json:"prerequisites,omitempty"` // Lesson IDs or external IDs
+}
+
+// LessonDraft is an imported document waiting to be published as a lesson
+type LessonDraft struct {
+	ID              uuid.UUID         `json:"id"`
+	Source          string            `json:"source"`
+	SourceRef       string            `json:"source_ref"`
+	Fields          LessonDraftFields `json:"fields"`
+	FrontMatter     map[string]string `json:"front_matter"`
+	ContentMarkdown string            `json:"content_markdown"`
+	Warnings        []string          `json:"warnings"`
+	Status          string            `json:"status"` // draft, published
+	LessonID        *uuid.UUID        `json:"lesson_id,omitempty"`
+	ImportedAt      time.Time         `json:"imported_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	PublishedAt     *time.Time        `json:"published_at,omitempty"`
+}
+
+// ContentImportRequest runs a content connector
+type ContentImportRequest struct {
+	Source      string            `json:"source"` // git, notion, gdocs
+	Path        string            `json:"path,omitempty"`
+	ArchiveURL  string            `json:"archive_url,omitempty"`
+	Subdir      string            `json:"subdir,omitempty"`
+	DocumentIDs []string          `json:"document_ids,omitempty"`
+	FieldMap    map[string]string `json:"field_map,omitempty"` // Front-matter key -> lesson field
+}
+
+// ContentImportError describes a document that could not be imported
+type ContentImportError struct {
+	Ref   string `json:"ref"`
+	Error string `json:"error"`
+}
+
+// ContentImportReport summarises a connector run
+type ContentImportReport struct {
+	Source    string               `json:"source"`
+	Documents int                  `json:"documents"`
+	Created   int                  `json:"created"`
+	Updated   int                  `json:"updated"`
+	Unchanged int                  `json:"unchanged"`
+	Failed    int                  `json:"failed"`
+	Errors    []ContentImportError `json:"errors"`
+	DraftIDs  []uuid.UUID          `json:"draft_ids"`
+}
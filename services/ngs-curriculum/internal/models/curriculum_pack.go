@@ -0,0 +1,81 @@
+package models
+
+import "encoding/json"
+
+// CurriculumPack is a portable curriculum: levels with their lessons and challenges. Lessons
+// and challenges carry stable external IDs, so importing a pack again updates what it created
+// instead of duplicating it. Packs are read and written as JSON or YAML with the same fields.
+type CurriculumPack struct {
+	Version int         `json:"version"` // Pack format version, currently 1
+	Name    string      `json:"name,omitempty"`
+	Levels  []PackLevel `json:"levels"`
+}
+
+// PackLevel is a curriculum level, identified by its number
+type PackLevel struct {
+	Number      int             `json:"number"`
+	Title       string          `json:"title"`
+	Description string          `json:"description,omitempty"`
+	XPRequired  *int            `json:"xp_required,omitempty"` // Defaults to the configured level-up threshold
+	Lessons     []PackLesson    `json:"lessons,omitempty"`
+	Challenges  []PackChallenge `json:"challenges,omitempty"`
+}
+
+// PackLesson is a lesson of a pack level. Prerequisites name other lessons by external ID.
+type PackLesson struct {
+	ExternalID         string              `json:"external_id"`
+	Order              int                 `json:"order"`
+	Title              string              `json:"title"`
+	Type               string              `json:"type,omitempty"` // Defaults to tutorial
+	Description        string              `json:"description,omitempty"`
+	ContentMarkdown    string              `json:"content_markdown,omitempty"`
+	CoreLesson         string              `json:"core_lesson,omitempty"`
+	HumanPractice      string              `json:"human_practice,omitempty"`
+	ReflectionPrompt   string              `json:"reflection_prompt,omitempty"`
+	AgentUnlock        string              `json:"agent_unlock,omitempty"`
+	XPReward           *int                `json:"xp_reward,omitempty"`
+	EstimatedMinutes   *int                `json:"estimated_minutes,omitempty"`
+	Required           *bool               `json:"required,omitempty"`
+	MinAgeBand         string              `json:"min_age_band,omitempty"`
+	CompletionCriteria *CompletionCriteria `json:"completion_criteria,omitempty"`
+	MinLevel           int                 `json:"min_level,omitempty"`
+	Prerequisites      []string            `json:"prerequisites,omitempty"`
+	Metadata           json.RawMessage     `json:"metadata,omitempty"`
+}
+
+// PackChallenge is a challenge of a pack level, optionally attached to a lesson by external ID
+type PackChallenge struct {
+	ExternalID        string          `json:"external_id"`
+	Lesson            string          `json:"lesson,omitempty"`
+	Title             string          `json:"title"`
+	Description       string          `json:"description"`
+	ChallengeType     string          `json:"challenge_type"`
+	Difficulty        string          `json:"difficulty,omitempty"`
+	StarterCode       string          `json:"starter_code,omitempty"`
+	SolutionTemplate  string          `json:"solution_template,omitempty"`
+	TestCases         json.RawMessage `json:"test_cases,omitempty"`
+	XPReward          *int            `json:"xp_reward,omitempty"`
+	TimeLimitMinutes  int             `json:"time_limit_minutes,omitempty"`
+	Limits            *ResourceLimits `json:"limits,omitempty"`
+	BaselineRuntimeMs float64         `json:"baseline_runtime_ms,omitempty"`
+	BaselineMemoryKB  int64           `json:"baseline_memory_kb,omitempty"`
+	Tags              []string        `json:"tags,omitempty"`
+	Metadata          json.RawMessage `json:"metadata,omitempty"`
+	MinAgeBand        string          `json:"min_age_band,omitempty"`
+	Active            *bool           `json:"active,omitempty"`
+}
+
+// PackImportCounts tallies what an import did with one kind of pack entry
+type PackImportCounts struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+}
+
+// CurriculumPackImport reports the outcome of importing a pack
+type CurriculumPackImport struct {
+	DryRun     bool             `json:"dry_run"`
+	Levels     PackImportCounts `json:"levels"`
+	Lessons    PackImportCounts `json:"lessons"`
+	Challenges PackImportCounts `json:"challenges"`
+}
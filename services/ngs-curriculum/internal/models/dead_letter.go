@@ -0,0 +1,26 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeadLetter is a failed background job kept for an admin to retry or discard
+type DeadLetter struct {
+	ID            int64           `json:"id"`
+	Kind          string          `json:"kind"`
+	Class         string          `json:"class"`
+	Payload       json.RawMessage `json:"payload"`
+	Error         string          `json:"error"`
+	Attempts      int             `json:"attempts"`
+	Status        string          `json:"status"` // dead, retrying, resolved or discarded
+	FirstFailedAt time.Time       `json:"first_failed_at"`
+	LastFailedAt  time.Time       `json:"last_failed_at"`
+	RetriedBy     *uuid.UUID      `json:"retried_by,omitempty"`
+	RetriedAt     *time.Time      `json:"retried_at,omitempty"`
+	ResolvedAt    *time.Time      `json:"resolved_at,omitempty"`
+	DiscardedBy   *uuid.UUID      `json:"discarded_by,omitempty"`
+	DiscardedAt   *time.Time      `json:"discarded_at,omitempty"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Submission review statuses
+const (
+	ReviewGraded  = "graded"
+	ReviewPending = "pending_review" // a design submission awaiting rubric evaluation
+)
+
+// Rubric evaluation methods
+const (
+	EvaluationEducator = "educator"
+	EvaluationAI       = "ai"
+	EvaluationScorer   = "scorer" // the reflection quality scorer
+)
+
+// RubricCriterion is one entry of a design challenge's metadata.rubric
+type RubricCriterion struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MaxPoints   int    `json:"max_points"`
+}
+
+// DesignAsset is a diagram or document uploaded with a design submission
+type DesignAsset struct {
+	ID          uuid.UUID `json:"id"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+}
+
+// RubricScore is the points awarded for one rubric criterion
+type RubricScore struct {
+	Criterion string `json:"criterion"`
+	Points    int    `json:"points"`
+	MaxPoints int    `json:"max_points"`
+	Comment   string `json:"comment,omitempty"`
+}
+
+// DesignEvaluation is the rubric evaluation of a design or reflection submission
+type DesignEvaluation struct {
+	Method     string        `json:"method"` // educator or ai
+	ReviewerID *uuid.UUID    `json:"reviewer_id,omitempty"`
+	Scores     []RubricScore `json:"scores"`
+	Comments   string        `json:"comments,omitempty"`
+	ReviewedAt time.Time     `json:"reviewed_at"`
+}
+
+// ReviewDesignRequest scores a pending design submission against its challenge rubric
+type ReviewDesignRequest struct {
+	Scores   []RubricScore `json:"scores"`
+	Comments string        `json:"comments"`
+}
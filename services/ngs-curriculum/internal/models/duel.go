@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Duel represents a one-vs-one timed challenge match
+type Duel struct {
+	ID               uuid.UUID  `json:"id"`
+	ChallengeID      uuid.UUID  `json:"challenge_id"`
+	LevelNumber      int        `json:"level_number"`
+	PlayerOneID      uuid.UUID  `json:"player_one_id"`
+	PlayerTwoID      *uuid.UUID `json:"player_two_id,omitempty"`
+	Status           string     `json:"status"` // waiting, active, completed, expired, cancelled
+	WinnerID         *uuid.UUID `json:"winner_id,omitempty"`
+	TimeLimitSeconds int        `json:"time_limit_seconds"`
+	StartedAt        *time.Time `json:"started_at,omitempty"`
+	EndsAt           *time.Time `json:"ends_at,omitempty"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// DuelState is a duel as seen by one participant, including the shared countdown
+type DuelState struct {
+	Duel
+	Challenge        *Challenge `json:"challenge,omitempty"`
+	ServerTime       time.Time  `json:"server_time"`
+	SecondsRemaining int        `json:"seconds_remaining"`
+}
+
+// DuelRating is a user's Elo-style duel rating
+type DuelRating struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Rating    int       `json:"rating"`
+	Wins      int       `json:"wins"`
+	Losses    int       `json:"losses"`
+	Draws     int       `json:"draws"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DuelSubmitResult is returned after a duel submission is graded
+type DuelSubmitResult struct {
+	Passed bool      `json:"passed"`
+	Score  int       `json:"score"`
+	Won    bool      `json:"won"`
+	Duel   DuelState `json:"duel"`
+}
@@ -0,0 +1,29 @@
+package models
+
+// XPCurveSimulationRequest describes a hypothetical XP economy. Omitted thresholds keep the
+// current curve; xp_sources overrides the default award for the listed sources only.
+type XPCurveSimulationRequest struct {
+	Thresholds []int          `json:"thresholds"`
+	XPSources  map[string]int `json:"xp_sources"`
+}
+
+// LevelDistributionBucket counts users at a level under the current and simulated economy
+type LevelDistributionBucket struct {
+	Level          int `json:"level"`
+	CurrentUsers   int `json:"current_users"`
+	ProjectedUsers int `json:"projected_users"`
+}
+
+// XPCurveSimulation is the projected level distribution over historical XP events
+type XPCurveSimulation struct {
+	Users              int                       `json:"users"`
+	Thresholds         []int                     `json:"thresholds"`
+	XPSources          map[string]int            `json:"xp_sources"`
+	Distribution       []LevelDistributionBucket `json:"distribution"`
+	UsersPromoted      int                       `json:"users_promoted"`
+	UsersDemoted       int                       `json:"users_demoted"`
+	UsersProtected     int                       `json:"users_protected"` // Projected below their level's threshold but kept there by the level-down policy
+	CurrentMeanLevel   float64                   `json:"current_mean_level"`
+	ProjectedMeanLevel float64                   `json:"projected_mean_level"`
+	AgentUnlockUsers   int                       `json:"agent_unlock_users"` // Projected users with agent creation unlocked
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExamQuestion is a quiz question as shown to the learner (no answer key)
+type ExamQuestion struct {
+	Index    int       `json:"index"`
+	LessonID uuid.UUID `json:"lesson_id"`
+	Type     string    `json:"type"`
+	Question string    `json:"question"`
+	Choices  []string  `json:"choices,omitempty"`
+}
+
+// LevelExam is a timed exam session for certifying a level
+type LevelExam struct {
+	ID               uuid.UUID      `json:"id"`
+	UserID           uuid.UUID      `json:"user_id"`
+	LevelNumber      int            `json:"level_number"`
+	Status           string         `json:"status"` // in_progress, submitted, expired
+	Questions        []ExamQuestion `json:"questions"`
+	Challenge        *Challenge     `json:"challenge,omitempty"`
+	TimeLimitSeconds int            `json:"time_limit_seconds"`
+	StartedAt        time.Time      `json:"started_at"`
+	EndsAt           time.Time      `json:"ends_at"`
+	SubmittedAt      *time.Time     `json:"submitted_at,omitempty"`
+	SecondsRemaining int            `json:"seconds_remaining"`
+	QuizScore        *int           `json:"quiz_score,omitempty"`
+	ChallengeScore   *int           `json:"challenge_score,omitempty"`
+	TotalScore       *int           `json:"total_score,omitempty"`
+	Passed           bool           `json:"passed"`
+}
+
+// SubmitExamRequest carries quiz answers keyed by question index plus the challenge solution
+type SubmitExamRequest struct {
+	Answers        map[string]interface{} `json:"answers"`
+	SubmissionCode string                 `json:"submission_code,omitempty"`
+}
+
+// ExamQuestionResult is the grading detail for a single exam question
+type ExamQuestionResult struct {
+	Index       int         `json:"index"`
+	Correct     bool        `json:"correct"`
+	Given       interface{} `json:"given,omitempty"`
+	Answer      interface{} `json:"answer"`
+	Explanation string      `json:"explanation,omitempty"`
+}
+
+// ExamResult is returned when an exam is submitted
+type ExamResult struct {
+	Exam          LevelExam            `json:"exam"`
+	Results       []ExamQuestionResult `json:"results"`
+	Certification *LevelCertification  `json:"certification,omitempty"`
+}
+
+// LevelCertification records that a user certified a level by exam
+type LevelCertification struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	LevelNumber int        `json:"level_number"`
+	ExamID      *uuid.UUID `json:"exam_id,omitempty"`
+	Score       int        `json:"score"`
+	CertifiedAt time.Time  `json:"certified_at"`
+}
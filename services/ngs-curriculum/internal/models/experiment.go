@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExperimentVariant is one arm of an experiment with its relative traffic weight
+type ExperimentVariant struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+// Experiment is an A/B test definition
+type Experiment struct {
+	Key         string              `json:"key"`
+	Description string              `json:"description,omitempty"`
+	Variants    []ExperimentVariant `json:"variants"`
+	Status      string              `json:"status"` // active, paused, concluded
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+// UpsertExperimentRequest creates or updates an experiment
+type UpsertExperimentRequest struct {
+	Key         string              `json:"key"`
+	Description string              `json:"description"`
+	Variants    []ExperimentVariant `json:"variants"`
+	Status      string              `json:"status"`
+}
+
+// ExperimentAssignment is the variant a user sees
+type ExperimentAssignment struct {
+	ExperimentKey string    `json:"experiment_key"`
+	UserID        uuid.UUID `json:"user_id"`
+	Variant       string    `json:"variant"`
+	Active        bool      `json:"active"` // false when paused or concluded; the first variant is served and no exposure is logged
+}
+
+// VariantMetrics rolls up outcomes for users exposed to a variant, counted from first exposure
+type VariantMetrics struct {
+	Variant          string  `json:"variant"`
+	ExposedUsers     int     `json:"exposed_users"`
+	TotalXP          int     `json:"total_xp"`
+	AvgXPPerUser     float64 `json:"avg_xp_per_user"`
+	LessonsCompleted int     `json:"lessons_completed"`
+	ChallengesPassed int     `json:"challenges_passed"`
+	RetainedUsers7d  int     `json:"retained_users_7d"` // Earned XP 7 or more days after first exposure
+}
+
+// ExperimentResults is the per-variant rollup for an experiment
+type ExperimentResults struct {
+	Experiment Experiment       `json:"experiment"`
+	Variants   []VariantMetrics `json:"variants"`
+}
@@ -0,0 +1,70 @@
+package models
+
+import "github.com/google/uuid"
+
+// LessonPrerequisites is the parsed form of lessons.prerequisites. Stored values are either an
+// object {"min_level": 3, "lessons": ["<id>", ...]} or a bare array of lesson IDs.
+type LessonPrerequisites struct {
+	MinLevel int         `json:"min_level,omitempty"`
+	Lessons  []uuid.UUID `json:"lessons,omitempty"`
+}
+
+// GraphNode is a level, lesson or capability in the curriculum map. Level is the curriculum
+// level the node belongs to, for grouping; Data carries type-specific attributes.
+type GraphNode struct {
+	ID    string                 `json:"id"`   // level:<id>, lesson:<uuid>, capability:<slug>
+	Type  string                 `json:"type"` // level, lesson, capability
+	Label string                 `json:"label"`
+	Level int                    `json:"level"`
+	Data  map[string]interface{} `json:"data,omitempty"`
+}
+
+// GraphEdge connects two graph nodes. Prerequisite edges point from the requirement to the node
+// it gates; unlock edges point from a lesson to the capability it unlocks.
+type GraphEdge struct {
+	ID     string `json:"id"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"` // prerequisite, unlock
+}
+
+// CurriculumGraph is the full curriculum map in a render-ready node/edge format
+type CurriculumGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// PrerequisiteViolation describes one problem with a lesson's prerequisites
+type PrerequisiteViolation struct {
+	Code      string     `json:"code"` // cycle, missing_lesson, unpublished_lesson, unknown_level, level_order, invalid_format
+	LessonID  *uuid.UUID `json:"lesson_id,omitempty"`
+	Reference string     `json:"reference,omitempty"` // The offending prerequisite reference
+	Path      []string   `json:"path,omitempty"`      // Node IDs around a cycle
+	Message   string     `json:"message"`
+}
+
+// SetPrerequisitesRequest replaces a lesson's prerequisites. Lessons may be lesson IDs or
+// external IDs; MinLevel defaults to the lesson's own level.
+type SetPrerequisitesRequest struct {
+	MinLevel *int     `json:"min_level,omitempty"`
+	Lessons  []string `json:"lessons"`
+}
+
+// CurriculumMapLevel is one level of the curriculum map with the user's progress through it
+type CurriculumMapLevel struct {
+	CurriculumLevel
+	LessonCount            int  `json:"lesson_count"`
+	RequiredLessonCount    int  `json:"required_lesson_count"`
+	CompletedCount         int  `json:"completed_count"`
+	CompletedRequiredCount int  `json:"completed_required_count"`
+	Locked                 bool `json:"locked"`
+	XPToUnlock             int  `json:"xp_to_unlock"`
+	Completed              bool `json:"completed"` // Every required lesson is done
+}
+
+// CurriculumMap is everything the level map screen needs in one response
+type CurriculumMap struct {
+	CurrentLevel int                  `json:"current_level"`
+	TotalXP      int                  `json:"total_xp"`
+	Levels       []CurriculumMapLevel `json:"levels"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ImpersonationSession lets an admin make requests as a learner until it expires or is ended
+type ImpersonationSession struct {
+	ID        uuid.UUID  `json:"id"`
+	AdminID   uuid.UUID  `json:"admin_id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Reason    string     `json:"reason"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	EndedBy   *uuid.UUID `json:"ended_by,omitempty"`
+	Active    bool       `json:"active"`
+	Token     string     `json:"token,omitempty"` // Only returned when the session is started
+}
+
+// StartImpersonationRequest opens an impersonation session; ttl_minutes defaults to
+// IMPERSONATION_TTL_MINUTES and is capped at IMPERSONATION_MAX_TTL_MINUTES
+type StartImpersonationRequest struct {
+	UserID     uuid.UUID `json:"user_id"`
+	Reason     string    `json:"reason"`
+	TTLMinutes int       `json:"ttl_minutes"`
+}
+
+// ImpersonationAuditEntry is a request made under an impersonation session. Status is nil
+// while the request is in flight or if it never finished.
+type ImpersonationAuditEntry struct {
+	ID        int64     `json:"id"`
+	SessionID uuid.UUID `json:"session_id"`
+	AdminID   uuid.UUID `json:"admin_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    *int      `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
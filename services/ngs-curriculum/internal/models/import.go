@@ -0,0 +1,36 @@
+package models
+
+// ImportRecord is one historical lesson completion from a legacy LMS
+type ImportRecord struct {
+	UserID           string `json:"user_id"`
+	LessonExternalID string `json:"lesson_external_id"`
+	Score            *int   `json:"score,omitempty"`
+	TimeSpentSeconds *int   `json:"time_spent_seconds,omitempty"`
+	XP               *int   `json:"xp,omitempty"`           // Defaults to the lesson's xp_reward
+	CompletedAt      string `json:"completed_at,omitempty"` // RFC 3339 or YYYY-MM-DD; defaults to import time
+}
+
+// ImportUsersRequest is the JSON form of a user import
+type ImportUsersRequest struct {
+	Records []ImportRecord `json:"records"`
+}
+
+// ImportError describes a record that could not be imported
+type ImportError struct {
+	Row              int    `json:"row"` // CSV line number, or 1-based index for JSON
+	UserID           string `json:"user_id,omitempty"`
+	LessonExternalID string `json:"lesson_external_id,omitempty"`
+	Error            string `json:"error"`
+}
+
+// ImportReport summarises an import run
+type ImportReport struct {
+	DryRun        bool          `json:"dry_run"`
+	TotalRecords  int           `json:"total_records"`
+	Imported      int           `json:"imported"`
+	Skipped       int           `json:"skipped"` // Already completed in NGS
+	Failed        int           `json:"failed"`
+	UsersAffected int           `json:"users_affected"`
+	XPAwarded     int           `json:"xp_awarded"`
+	Errors        []ImportError `json:"errors"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobClassStatus is one priority class of the background job pool
+type JobClassStatus struct {
+	Class         string `json:"class"`
+	MaxConcurrent int    `json:"max_concurrent"`
+	Running       int    `json:"running"`
+	Queued        int    `json:"queued"`
+	OldestWaitMs  int64  `json:"oldest_wait_ms"` // How long the oldest queued job has waited
+}
+
+// JobQueueStatus is this instance's background job pool, with classes in priority order
+type JobQueueStatus struct {
+	Workers        int              `json:"workers"`
+	MaxWaitSeconds int              `json:"max_wait_seconds"`
+	Classes        []JobClassStatus `json:"classes"`
+	UpdatedBy      *uuid.UUID       `json:"updated_by,omitempty"`
+	UpdatedAt      *time.Time       `json:"updated_at,omitempty"`
+}
+
+// SetJobQueueRequest overrides the job pool settings for every instance. Omitted fields and
+// classes go back to their defaults.
+type SetJobQueueRequest struct {
+	Workers        *int           `json:"workers,omitempty"`
+	MaxWaitSeconds *int           `json:"max_wait_seconds,omitempty"`
+	ClassLimits    map[string]int `json:"class_limits,omitempty"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// LearnerMemory is what the tutor remembers about a learner from their finished chat sessions.
+// It is sent with the first message of each new chat session.
+type LearnerMemory struct {
+	Summary            string     `json:"summary"`
+	SessionsSummarized int        `json:"sessions_summarized"`
+	UpdatedAt          *time.Time `json:"updated_at,omitempty"`
+}
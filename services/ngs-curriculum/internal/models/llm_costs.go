@@ -0,0 +1,17 @@
+package models
+
+// LLMDailyCost is an organization's LLM spend on one day (UTC). Requests made for no
+// organization, such as background jobs, have an empty OrgID.
+type LLMDailyCost struct {
+	Day        string                     `json:"day"` // YYYY-MM-DD
+	OrgID      string                     `json:"org_id"`
+	Tokens     int64                      `json:"tokens"`
+	CostUSD    float64                    `json:"cost_usd"`
+	ByProvider map[string]LLMProviderCost `json:"by_provider"`
+}
+
+// LLMProviderCost is the part of a day's spend served by one provider
+type LLMProviderCost struct {
+	Tokens  int64   `json:"tokens"`
+	CostUSD float64 `json:"cost_usd"`
+}
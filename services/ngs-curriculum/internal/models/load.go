@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// LoadStatus is this instance's latest load sample and whether it is shedding low-priority
+// requests because of it
+type LoadStatus struct {
+	Shedding      bool           `json:"shedding"`
+	SheddingSince *time.Time     `json:"shedding_since,omitempty"`
+	Reasons       []string       `json:"reasons"`          // Saturated signals: db_pool_wait or queue:<name>
+	DBPoolWaitMs  float64        `json:"db_pool_wait_ms"`  // Average wait for a connection since the last sample
+	DBPoolInUse   int            `json:"db_pool_in_use"`   // Connections in use
+	DBPoolMaxOpen int            `json:"db_pool_max_open"` // Pool size
+	QueueDepths   map[string]int `json:"queue_depths"`     // Jobs waiting to start, by queue
+	SampledAt     time.Time      `json:"sampled_at"`
+}
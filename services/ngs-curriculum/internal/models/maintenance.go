@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaintenanceStatus is the maintenance mode switch. Forced is set when the MAINTENANCE_MODE
+// environment variable holds it on regardless of the stored switch.
+type MaintenanceStatus struct {
+	Enabled           bool       `json:"enabled"`
+	Forced            bool       `json:"forced,omitempty"`
+	Message           string     `json:"message"`
+	RetryAfterSeconds *int       `json:"retry_after_seconds,omitempty"`
+	UpdatedBy         *uuid.UUID `json:"updated_by,omitempty"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// SetMaintenanceRequest turns maintenance mode on or off. An empty message uses the default.
+type SetMaintenanceRequest struct {
+	Enabled           bool   `json:"enabled"`
+	Message           string `json:"message"`
+	RetryAfterSeconds *int   `json:"retry_after_seconds,omitempty"`
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LessonMedia is a video asset attached to a lesson
+type LessonMedia struct {
+	ID          uuid.UUID  `json:"id"`
+	LessonID    uuid.UUID  `json:"lesson_id"`
+	MediaType   string     `json:"media_type"`
+	URL         string     `json:"url"`
+	Title       *string    `json:"title,omitempty"`
+	Language    *string    `json:"language,omitempty"`
+	Status      string     `json:"status"` // pending, processing, completed, failed
+	Error       *string    `json:"error,omitempty"`
+	AttachedBy  uuid.UUID  `json:"attached_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+}
+
+// AttachMediaRequest attaches a video to a lesson and starts transcription
+type AttachMediaRequest struct {
+	URL      string  `json:"url"`
+	Title    *string `json:"title,omitempty"`
+	Language *string `json:"language,omitempty"` // ISO-639-1 hint, e.g. "en"
+}
+
+// LessonArtifact is AI-generated text derived from lesson media
+type LessonArtifact struct {
+	ID           uuid.UUID `json:"id"`
+	LessonID     uuid.UUID `json:"lesson_id"`
+	MediaID      uuid.UUID `json:"media_id"`
+	ArtifactType string    `json:"artifact_type"` // transcript, summary
+	Content      string    `json:"content"`
+	Model        *string   `json:"model,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SearchResult is a lesson matching a full-text query. Source names where the best match
+// was found: the lesson itself or one of its artifacts.
+type SearchResult struct {
+	LessonID    uuid.UUID `json:"lesson_id"`
+	LevelID     int       `json:"level_id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Source      string    `json:"source"` // lesson, transcript, summary
+	Snippet     string    `json:"snippet"`
+	Rank        float64   `json:"rank"`
+}
+
+// SearchResponse lists full-text search results
+type SearchResponse struct {
+	Query   string         `json:"query"`
+	Results []SearchResult `json:"results"`
+	Count   int            `json:"count"`
+	HasMore bool           `json:"has_more"`
+}
+
+// LessonContextChunk is a passage of a lesson or its media sent with a tutor chat message, so
+// the answer is grounded in what the lesson actually says. Rank is its full-text rank against
+// the message; 0 when no passage matched and the lesson's opening is sent instead.
+type LessonContextChunk struct {
+	Source  string  `json:"source"` // lesson, transcript, summary
+	Heading string  `json:"heading,omitempty"`
+	Content string  `json:"content"`
+	Rank    float64 `json:"rank"`
+}
@@ -68,6 +68,63 @@ type Lesson struct {
 	IsRequired       bool            `json:"is_required"`
 	CreatedAt        time.Time       `json:"created_at"`
 	UpdatedAt        time.Time       `json:"updated_at"`
+
+	CompletionCriteria json.RawMessage `json:"completion_criteria,omitempty"`
+	MinAgeBand         string          `json:"min_age_band"` // child, teen, adult
+}
+
+// CompletionCriteria defines what a learner must provide to complete a lesson.
+// A zero value means the lesson completes on reading alone.
+type CompletionCriteria struct {
+	MinReflectionWords int `json:"min_reflection_words,omitempty"`
+	// MinQuizScore is checked against the learner's best graded attempt at the lesson quiz
+	MinQuizScore     int    `json:"min_quiz_score,omitempty"`
+	RequiredArtifact string `json:"required_artifact,omitempty"` // Completion metadata key, e.g. "artifact_url"
+}
+
+// LessonAccessibility holds accessibility metadata and alternative formats for lesson content
+type LessonAccessibility struct {
+	PlainLanguageSummary  string          `json:"plain_language_summary,omitempty"`
+	AltText               []VisualAltText `json:"alt_text"`
+	ReadingLevel          float64         `json:"reading_level"`
+	ReadingLevelEstimated bool            `json:"reading_level_estimated"` // Estimated from current content because none was stored
+}
+
+// VisualAltText is the alternative text for one visual aid
+type VisualAltText struct {
+	Visual  string `json:"visual"`
+	AltText string `json:"alt_text"`
+}
+
+// LessonNote is one entry of a generated lesson's notes outline
+type LessonNote struct {
+	Heading  string `json:"heading"`
+	Content  string `json:"content"`
+	Level    int    `json:"level"`    // Heading depth, 1 to 6
+	Markdown string `json:"markdown"` // The entry ready to paste into notes
+}
+
+// LessonCodeSnippet is a code snippet from a generated lesson
+type LessonCodeSnippet struct {
+	Language    string `json:"language,omitempty"`
+	Description string `json:"description,omitempty"`
+	Code        string `json:"code"`
+	Markdown    string `json:"markdown"` // The snippet as a fenced code block
+}
+
+// LessonAudio is a cached text-to-speech rendition of a lesson's content
+type LessonAudio struct {
+	ID             uuid.UUID `json:"id"`
+	LessonID       uuid.UUID `json:"lesson_id"`
+	ContentVersion int       `json:"content_version"`
+	Voice          string    `json:"voice"`
+	Provider       string    `json:"provider"`
+	StoragePath    string    `json:"-"`
+	ContentType    string    `json:"content_type"`
+	SizeBytes      int64     `json:"size_bytes"`
+	CreatedAt      time.Time `json:"created_at"`
+	URL            string    `json:"url"`        // Signed, expiring download URL
+	ExpiresAt      time.Time `json:"expires_at"` // When URL stops working
 }
 
 // LessonCompletion tracks user lesson completions
@@ -89,31 +146,72 @@ type Challenge struct {
 	LevelID          int             `json:"level_id"`
 	Title            string          `json:"title"`
 	Description      string          `json:"description"`
-	ChallengeType    string          `json:"challenge_type"` // coding, design, reflection, collaboration
+	ChallengeType    string          `json:"challenge_type"` // coding, optimization, design, reflection, collaboration
 	Difficulty       string          `json:"difficulty"`     // easy, medium, hard, expert
 	StarterCode      string          `json:"starter_code,omitempty"`
 	TestCases        json.RawMessage `json:"test_cases,omitempty"`
 	SolutionTemplate string          `json:"solution_template,omitempty"`
 	XPReward         int             `json:"xp_reward"`
 	TimeLimitMinutes int             `json:"time_limit_minutes,omitempty"`
-	Tags             []string        `json:"tags,omitempty"`
-	Metadata         json.RawMessage `json:"metadata,omitempty"`
-	IsActive         bool            `json:"is_active"`
-	CreatedAt        time.Time       `json:"created_at"`
+	Limits           ResourceLimits  `json:"limits"` // per test case run
+	// Optimization challenges score against the reference solution's total runtime and peak memory
+	BaselineRuntimeMs float64         `json:"baseline_runtime_ms,omitempty"`
+	BaselineMemoryKB  int64           `json:"baseline_memory_kb,omitempty"`
+	Tags              []string        `json:"tags,omitempty"`
+	Metadata          json.RawMessage `json:"metadata,omitempty"`
+	IsActive          bool            `json:"is_active"`
+	MinAgeBand        string          `json:"min_age_band"` // child, teen, adult
+	CreatedAt         time.Time       `json:"created_at"`
 }
 
 // ChallengeSubmission tracks user challenge attempts
 type ChallengeSubmission struct {
-	ID               uuid.UUID       `json:"id"`
-	UserID           uuid.UUID       `json:"user_id"`
-	ChallengeID      uuid.UUID       `json:"challenge_id"`
-	SubmissionCode   string          `json:"submission_code,omitempty"`
-	TestResults      json.RawMessage `json:"test_results,omitempty"`
-	Passed           bool            `json:"passed"`
-	Score            int             `json:"score,omitempty"`
-	Feedback         string          `json:"feedback,omitempty"`
-	TimeTakenSeconds int             `json:"time_taken_seconds,omitempty"`
-	SubmittedAt      time.Time       `json:"submitted_at"`
+	ID               uuid.UUID    `json:"id"`
+	UserID           uuid.UUID    `json:"user_id"`
+	ChallengeID      uuid.UUID    `json:"challenge_id"`
+	SubmissionCode   string       `json:"submission_code,omitempty"`
+	TestResults      *TestResults `json:"test_results,omitempty"`
+	Passed           bool         `json:"passed"`
+	Score            int          `json:"score,omitempty"`
+	Feedback         string       `json:"feedback,omitempty"`
+	TimeTakenSeconds int          `json:"time_taken_seconds,omitempty"`
+	IsShared         bool         `json:"is_shared"`
+	SubmittedAt      time.Time    `json:"submitted_at"`
+	// Measured over all test cases: total runtime and peak memory
+	RuntimeMs float64 `json:"runtime_ms,omitempty"`
+	MemoryKB  int64   `json:"memory_kb,omitempty"`
+	// Design submissions: graded or pending_review, the write-up, uploaded assets and evaluation
+	Status     string            `json:"status"`
+	Writeup    string            `json:"writeup,omitempty"`
+	Assets     []DesignAsset     `json:"assets,omitempty"`
+	Evaluation *DesignEvaluation `json:"evaluation,omitempty"`
+}
+
+// RuntimeLeaderboardEntry is a user's fastest passing submission to an optimization challenge
+type RuntimeLeaderboardEntry struct {
+	Rank         int       `json:"rank"`
+	UserID       uuid.UUID `json:"user_id"`
+	SubmissionID uuid.UUID `json:"submission_id"`
+	RuntimeMs    float64   `json:"runtime_ms"`
+	MemoryKB     int64     `json:"memory_kb"`
+	Score        int       `json:"score"`
+	SubmittedAt  time.Time `json:"submitted_at"`
+}
+
+// CommunitySolution is an opted-in passing submission shown after a learner passes
+type CommunitySolution struct {
+	SubmissionID   uuid.UUID `json:"submission_id"`
+	SubmissionCode string    `json:"submission_code"`
+	Score          int       `json:"score"`
+	CodeLength     int       `json:"code_length"`
+	SubmittedAt    time.Time `json:"submitted_at"`
+}
+
+// ChallengeSolutions is the solution reveal for a passed challenge
+type ChallengeSolutions struct {
+	ChallengeID        uuid.UUID           `json:"challenge_id"`
+	SolutionTemplate   string              `json:"solution_template"`
+	CommunitySolutions []CommunitySolution `json:"community_solutions"`
 }
 
 // UserReflection represents a user's reflection on a lesson or practice
@@ -132,15 +230,37 @@ type UserReflection struct {
 
 // Request/Response DTOs
 
-// CompleteLessonRequest is the request body for completing a lesson
+// CompleteLessonRequest is the request body for completing a lesson. The completion's score is
+// the learner's best graded quiz attempt, not anything the client sends.
 type CompleteLessonRequest struct {
 	LessonID         uuid.UUID              `json:"lesson_id"`
-	Score            int                    `json:"score,omitempty"`
 	TimeSpentSeconds int                    `json:"time_spent_seconds,omitempty"`
 	ReflectionText   string                 `json:"reflection_text,omitempty"`
 	Metadata         map[string]interface{} `json:"metadata,omitempty"`
 }
 
+// SubmitLessonQuizRequest carries lesson quiz answers keyed by question index
+type SubmitLessonQuizRequest struct {
+	Answers map[string]interface{} `json:"answers"`
+}
+
+// LessonQuizAttempt is a graded lesson quiz attempt. Answer keys are not returned, so the quiz
+// can be retaken honestly.
+type LessonQuizAttempt struct {
+	ID        uuid.UUID                  `json:"id"`
+	LessonID  uuid.UUID                  `json:"lesson_id"`
+	Score     int                        `json:"score"`
+	BestScore int                        `json:"best_score"`
+	Results   []LessonQuizQuestionResult `json:"results"`
+	CreatedAt time.Time                  `json:"created_at"`
+}
+
+// LessonQuizQuestionResult is whether one quiz question was answered correctly
+type LessonQuizQuestionResult struct {
+	Index   int  `json:"index"`
+	Correct bool `json:"correct"`
+}
+
 // AwardXPRequest is the request body for awarding XP
 type AwardXPRequest struct {
 	Source   string                 `json:"source"`
@@ -161,6 +281,7 @@ type SubmitReflectionRequest struct {
 type SubmitChallengeRequest struct {
 	ChallengeID    uuid.UUID `json:"challenge_id"`
 	SubmissionCode string    `json:"submission_code"`
+	ReflectionText string    `json:"reflection_text,omitempty"` // reflection challenges
 }
 
 // LessonWithCompletion includes lesson data and user completion status
@@ -169,6 +290,17 @@ type LessonWithCompletion struct {
 	Completed   bool      `json:"completed"`
 	CompletedAt time.Time `json:"completed_at,omitempty"`
 	UserScore   int       `json:"user_score,omitempty"`
+	// Locked marks a lesson above the user's current level. It is only returned as a preview,
+	// without its content.
+	Locked bool `json:"locked"`
+}
+
+// LessonCompletionSet lists every lesson a user has completed, for screens that show all levels at once
+type LessonCompletionSet struct {
+	LessonIDs []uuid.UUID `json:"lesson_ids"`
+	// ByLevel counts completed lessons per level number
+	ByLevel map[int]int `json:"by_level"`
+	Count   int         `json:"count"`
 }
 
 // ProgressResponse includes progress with level details
@@ -178,17 +310,32 @@ type ProgressResponse struct {
 	NextLevelInfo    *CurriculumLevel `json:"next_level_info,omitempty"`
 	XPToNextLevel    int              `json:"xp_to_next_level"`
 	ProgressPercent  float64          `json:"progress_percent"`
+	IsMaxLevel       bool             `json:"is_max_level"`
 }
 
 // LeaderboardEntry represents a user on the leaderboard
 type LeaderboardEntry struct {
 	UserID       uuid.UUID `json:"user_id"`
-	Username     string    `json:"username,omitempty"`
+	Username     string    `json:"username,omitempty"` // Display name from the user service, when configured
+	AvatarURL    string    `json:"avatar_url,omitempty"`
 	CurrentLevel int       `json:"current_level"`
 	TotalXP      int       `json:"total_xp"`
+	PeriodXP     int       `json:"period_xp,omitempty"` // XP earned in the leaderboard's period, for period boards
+	Prestige     int       `json:"prestige"`            // Milestones past the top of the XP curve
+	MasteryStars int       `json:"mastery_stars"`       // Track stages mastered
 	Rank         int       `json:"rank"`
 }
 
+// LeaderboardPosition is where a user stands on a leaderboard, with the users ranked just above
+// and below. Users who are not on the board have no position.
+type LeaderboardPosition struct {
+	Ranked      bool               `json:"ranked"`
+	Me          *LeaderboardEntry  `json:"me"`
+	Above       []LeaderboardEntry `json:"above"` // Nearest first
+	Below       []LeaderboardEntry `json:"below"` // Nearest first
+	TotalRanked int                `json:"total_ranked,omitempty"`
+}
+
 // JSONB is a custom type for PostgreSQL JSONB fields
 type JSONB map[string]interface{}
 
@@ -212,3 +359,16 @@ func (j *JSONB) Scan(value interface{}) error {
 	}
 	return json.Unmarshal(bytes, j)
 }
+
+// LessonVersion is a snapshot of a lesson's content as of one content_version
+type LessonVersion struct {
+	LessonID uuid.UUID `json:"lesson_id"`
+	Version  int       `json:"version"`
+	// Source is what wrote the version: generated, import, pack, authored or baseline (content
+	// before versions were kept)
+	Source          string          `json:"source"`
+	CreatedBy       *uuid.UUID      `json:"created_by,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	ContentMarkdown string          `json:"content_markdown,omitempty"`
+	Metadata        json.RawMessage `json:"metadata,omitempty"`
+}
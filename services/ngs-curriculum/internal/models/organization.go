@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrgSettings are the defaults an organization's members start from. Nil fields use the
+// service-wide defaults.
+type OrgSettings struct {
+	OrgID            string     `json:"org_id"`
+	AgentUnlockLevel *int       `json:"agent_unlock_level"`
+	EnabledTracks    []string   `json:"enabled_tracks"` // nil offers every track
+	Locale           *string    `json:"locale"`
+	UpdatedBy        *uuid.UUID `json:"updated_by,omitempty"`
+	UpdatedAt        *time.Time `json:"updated_at,omitempty"`
+}
+
+// UpdateOrgSettingsRequest is a partial update of an organization's defaults; omitted fields are
+// left unchanged and the clear_* flags go back to the service-wide default
+type UpdateOrgSettingsRequest struct {
+	AgentUnlockLevel      *int      `json:"agent_unlock_level"`
+	EnabledTracks         *[]string `json:"enabled_tracks"`
+	Locale                *string   `json:"locale"`
+	ClearAgentUnlockLevel bool      `json:"clear_agent_unlock_level"`
+	ClearEnabledTracks    bool      `json:"clear_enabled_tracks"`
+	ClearLocale           bool      `json:"clear_locale"`
+}
+
+// OrgEducator is an educator invited to, or active in, an organization
+type OrgEducator struct {
+	OrgID      string     `json:"org_id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Email      *string    `json:"email"`
+	Status     string     `json:"status"` // invited or active
+	InvitedBy  uuid.UUID  `json:"invited_by"`
+	InvitedAt  time.Time  `json:"invited_at"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+}
+
+// InviteEducatorRequest invites a user to be one of the organization's educators
+type InviteEducatorRequest struct {
+	UserID uuid.UUID `json:"user_id"`
+	Email  *string   `json:"email,omitempty"`
+}
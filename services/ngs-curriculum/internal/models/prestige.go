@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PrestigeStatus is a user's progression past the top level: XP milestones and mastery stars
+type PrestigeStatus struct {
+	UserID            uuid.UUID      `json:"user_id"`
+	TotalXP           int            `json:"total_xp"`
+	Milestones        int            `json:"milestones"`
+	MilestoneXP       int            `json:"milestone_xp"`         // XP per milestone; 0 when milestones are off
+	MilestonesStartXP int            `json:"milestones_start_xp"`  // The top of the XP curve
+	XPToNextMilestone int            `json:"xp_to_next_milestone"` // 0 when milestones are off
+	MasteryStars      int            `json:"mastery_stars"`
+	Tracks            []TrackMastery `json:"tracks"`
+}
+
+// TrackMastery is a user's stars in one track, one per stage whose lessons they all completed
+type TrackMastery struct {
+	Track    string        `json:"track"`
+	Stars    int           `json:"stars"`
+	MaxStars int           `json:"max_stars"`
+	Stages   []MasteryStar `json:"stages"`
+}
+
+// MasteryStar is a stage of a track a user has mastered
+type MasteryStar struct {
+	Stage    string    `json:"stage"`
+	EarnedAt time.Time `json:"earned_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// QuestionStats is how learners did on one lesson assessment question across submitted exams.
+// A question is identified by its lesson and text, so rewording a question starts it afresh.
+type QuestionStats struct {
+	LessonID    uuid.UUID       `json:"lesson_id"`
+	LessonTitle string          `json:"lesson_title"`
+	LevelNumber int             `json:"level_number"`
+	Type        string          `json:"type"`
+	Question    string          `json:"question"`
+	Choices     []string        `json:"choices,omitempty"`
+	Answer      json.RawMessage `json:"answer,omitempty"`
+	Attempts    int             `json:"attempts"`
+	Correct     int             `json:"correct"`
+	CorrectRate float64         `json:"correct_rate"`
+	// Discrimination is the correlation between answering this question correctly and the rest
+	// of the exam's score. It is nil when every attempt had the same outcome.
+	Discrimination *float64 `json:"discrimination"`
+	Flags          []string `json:"flags"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// NextAction is the single most relevant thing a learner should do next
+type NextAction struct {
+	Type        string     `json:"type"` // resume_exam, resume_duel, resume_lesson, retry_challenge, remediation_lesson, next_lesson, start_challenge, take_exam, caught_up
+	Title       string     `json:"title"`
+	Reason      string     `json:"reason"`
+	LevelNumber int        `json:"level_number"`
+	ResourceID  *uuid.UUID `json:"resource_id,omitempty"`
+	Endpoint    string     `json:"endpoint,omitempty"` // API path the client should open
+}
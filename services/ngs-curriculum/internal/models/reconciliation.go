@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// XPDrift is a user whose total_xp differs from the sum of their XP ledger, or whose
+// current_level differs from the level that sum gives under LEVEL_DOWN_POLICY
+type XPDrift struct {
+	UserID       uuid.UUID `json:"user_id"`
+	TotalXP      int       `json:"total_xp"`
+	LedgerXP     int       `json:"ledger_xp"`
+	Drift        int       `json:"drift"` // total_xp - ledger_xp
+	CurrentLevel int       `json:"current_level"`
+	LedgerLevel  int       `json:"ledger_level"`
+}
+
+// XPReconciliationRun is the outcome of one XP reconciliation run
+type XPReconciliationRun struct {
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	DryRun     bool      `json:"dry_run"`
+	Checked    int       `json:"checked"`           // Users compared with the ledger
+	Drifted    int       `json:"drifted"`           // Users whose total_xp or level differed
+	DriftXP    int64     `json:"drift_xp"`          // Sum of the absolute drift
+	Repaired   int       `json:"repaired"`          // Totals reset to the ledger
+	Drifts     []XPDrift `json:"drifts"`            // The first 50 drifted users
+	Skipped    bool      `json:"skipped,omitempty"` // Another instance was already running
+	Error      string    `json:"error,omitempty"`
+}
+
+// XPReconciliationStatus is the reconciliation schedule and the most recent run on this instance
+type XPReconciliationStatus struct {
+	Enabled   bool                 `json:"enabled"`
+	HourUTC   int                  `json:"hour_utc"`
+	Repair    bool                 `json:"repair"`
+	NextRunAt *time.Time           `json:"next_run_at,omitempty"`
+	LastRun   *XPReconciliationRun `json:"last_run,omitempty"`
+}
+
+// XPUserReconciliation is the outcome of reconciling one user. Drift is 0 and the levels match
+// when their progress agreed with the ledger.
+type XPUserReconciliation struct {
+	XPDrift
+	DryRun    bool      `json:"dry_run"`
+	Repaired  bool      `json:"repaired"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// XPRepair is an audit record of a total_xp reset to the ledger
+type XPRepair struct {
+	ID              int64     `json:"id"`
+	UserID          uuid.UUID `json:"user_id"`
+	RunStartedAt    time.Time `json:"run_started_at"`
+	PreviousTotalXP int       `json:"previous_total_xp"`
+	LedgerXP        int       `json:"ledger_xp"`
+	PreviousLevel   int       `json:"previous_level"`
+	NewLevel        int       `json:"new_level"`
+	Source          string    `json:"source"` // scheduled or admin
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// ProgressReplayChange is a user whose progress the replay changed
+type ProgressReplayChange struct {
+	UserID          uuid.UUID `json:"user_id"`
+	PreviousTotalXP int       `json:"previous_total_xp"`
+	TotalXP         int       `json:"total_xp"`
+	PreviousLevel   int       `json:"previous_level"`
+	Level           int       `json:"level"`
+}
+
+// ProgressReplay is the outcome of rebuilding user_progress from the XP ledger
+type ProgressReplay struct {
+	StartedAt  time.Time              `json:"started_at"`
+	DurationMs int64                  `json:"duration_ms"`
+	DryRun     bool                   `json:"dry_run"`
+	Users      int                    `json:"users"`   // Users replayed
+	Changed    int                    `json:"changed"` // Users whose progress differed from the replay
+	Changes    []ProgressReplayChange `json:"changes"` // The first 50 changed users
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QuizPerformance is a learner's exam answers on one topic
+type QuizPerformance struct {
+	Answered int      `json:"answered"`
+	Correct  int      `json:"correct"`
+	Accuracy *float64 `json:"accuracy,omitempty"` // Correct / Answered; unset with no answers
+}
+
+// RemediationOutcome compares a learner's exam answers on a topic before and after they
+// completed its remediation. Improved is unset until there are answers on both sides.
+type RemediationOutcome struct {
+	Before   QuizPerformance `json:"before"`
+	After    QuizPerformance `json:"after"`
+	Improved *bool           `json:"improved,omitempty"`
+}
+
+// RemediationLesson is an optional micro-lesson on one of a learner's weak topics
+type RemediationLesson struct {
+	ID              uuid.UUID           `json:"id"`
+	UserID          uuid.UUID           `json:"user_id"`
+	Topic           string              `json:"topic"`
+	LessonID        *uuid.UUID          `json:"lesson_id,omitempty"` // The lesson the topic is named after
+	Source          *string             `json:"source,omitempty"`    // generated, selected
+	Status          string              `json:"status"`              // pending, ready, failed, completed
+	Title           *string             `json:"title,omitempty"`
+	ContentMarkdown *string             `json:"content_markdown,omitempty"`
+	Metadata        json.RawMessage     `json:"metadata,omitempty"`
+	Error           *string             `json:"error,omitempty"`
+	WeakScore       int                 `json:"weak_score"`
+	Endpoint        string              `json:"endpoint"` // API path the client should open
+	CreatedAt       time.Time           `json:"created_at"`
+	ReadyAt         *time.Time          `json:"ready_at,omitempty"`
+	CompletedAt     *time.Time          `json:"completed_at,omitempty"`
+	Outcome         *RemediationOutcome `json:"outcome,omitempty"` // Set once completed
+}
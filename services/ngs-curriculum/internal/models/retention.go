@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// RetentionRun is the outcome of one run of a retention policy
+type RetentionRun struct {
+	Policy     string    `json:"policy"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Rows       int64     `json:"rows"`              // Sessions archived, drafts pruned or XP events compacted
+	Skipped    bool      `json:"skipped,omitempty"` // Another instance held the policy lock
+	Error      string    `json:"error,omitempty"`
+}
+
+// RetentionPolicy is a configured retention policy and its most recent run on this instance
+type RetentionPolicy struct {
+	Name          string        `json:"name"` // chat_session_archive, stale_draft_prune, xp_metadata_rollup
+	Description   string        `json:"description"`
+	RetentionDays int           `json:"retention_days"`
+	Enabled       bool          `json:"enabled"`
+	LastRun       *RetentionRun `json:"last_run,omitempty"`
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NotificationPreferences controls which notifications a user receives
+type NotificationPreferences struct {
+	WeeklyDigest     bool `json:"weekly_digest"`
+	StreakReminders  bool `json:"streak_reminders"`
+	ChallengeUpdates bool `json:"challenge_updates"`
+	PushEnabled      bool `json:"push_enabled"`
+}
+
+// ContentPreferences shapes which content is suggested first
+type ContentPreferences struct {
+	PreferredTrackOrder []string `json:"preferred_track_order"`
+}
+
+// LearningPreferences is how a user likes to be taught. Tutor chat receives it as the
+// request's preferences.
+type LearningPreferences struct {
+	Examples            string `json:"examples"`   // examples, balanced, theory
+	CodeStyle           string `json:"code_style"` // code_heavy, balanced, conceptual
+	ProgrammingLanguage string `json:"programming_language"`
+}
+
+// LearningPreferencesPatch changes only the learning preferences that are set
+type LearningPreferencesPatch struct {
+	Examples            *string `json:"examples,omitempty"`
+	CodeStyle           *string `json:"code_style,omitempty"`
+	ProgrammingLanguage *string `json:"programming_language,omitempty"`
+}
+
+// LearningPreferenceOptions lists the values each learning preference accepts, for editors
+type LearningPreferenceOptions struct {
+	Examples             []string `json:"examples"`
+	CodeStyle            []string `json:"code_style"`
+	ProgrammingLanguages []string `json:"programming_languages"`
+}
+
+// LearningPreferencesResponse is a user's learning preferences with the values they can choose
+type LearningPreferencesResponse struct {
+	Preferences LearningPreferences       `json:"preferences"`
+	Options     LearningPreferenceOptions `json:"options"`
+}
+
+// UserSettings holds a user's privacy, locale, notification, content and learning preferences
+type UserSettings struct {
+	UserID            uuid.UUID               `json:"user_id"`
+	ShowOnLeaderboard bool                    `json:"show_on_leaderboard"`
+	PublicProfile     bool                    `json:"public_profile"`
+	Locale            string                  `json:"locale"`
+	Timezone          string                  `json:"timezone"`
+	Notifications     NotificationPreferences `json:"notifications"`
+	Content           ContentPreferences      `json:"content"`
+	Learning          LearningPreferences     `json:"learning"`
+	AgeBand           string                  `json:"age_band"` // child, teen, adult
+	IsMinor           bool                    `json:"is_minor"`
+	DeactivatedAt     *time.Time              `json:"deactivated_at,omitempty"` // Notifications are off while set
+	UpdatedAt         *time.Time              `json:"updated_at,omitempty"`
+}
+
+// NotificationPreferencesPatch changes only the notification flags that are set
+type NotificationPreferencesPatch struct {
+	WeeklyDigest     *bool `json:"weekly_digest,omitempty"`
+	StreakReminders  *bool `json:"streak_reminders,omitempty"`
+	ChallengeUpdates *bool `json:"challenge_updates,omitempty"`
+	PushEnabled      *bool `json:"push_enabled,omitempty"`
+}
+
+// UpdateSettingsRequest is a partial settings update; omitted fields are left unchanged
+type UpdateSettingsRequest struct {
+	ShowOnLeaderboard *bool                         `json:"show_on_leaderboard"`
+	PublicProfile     *bool                         `json:"public_profile"`
+	Locale            *string                       `json:"locale"`
+	Timezone          *string                       `json:"timezone"`
+	Notifications     *NotificationPreferencesPatch `json:"notifications"`
+	Content           *ContentPreferences           `json:"content"`
+	Learning          *LearningPreferencesPatch     `json:"learning"`
+	AgeBand           *string                       `json:"age_band"`
+}
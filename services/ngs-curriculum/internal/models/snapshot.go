@@ -0,0 +1,35 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LearnerSnapshot is a signed bundle of one learner's curriculum state. Tables holds each
+// table's rows for the learner as a JSON array. The signature covers every other field, so a
+// bundle must be restored as it was downloaded.
+type LearnerSnapshot struct {
+	Version       int                        `json:"version"`
+	UserID        uuid.UUID                  `json:"user_id"`
+	SchemaVersion int                        `json:"schema_version"`
+	CreatedAt     time.Time                  `json:"created_at"`
+	CreatedBy     uuid.UUID                  `json:"created_by"`
+	Note          string                     `json:"note,omitempty"`
+	Tables        map[string]json.RawMessage `json:"tables"`
+	Signature     string                     `json:"signature,omitempty"`
+}
+
+// SnapshotRequest takes a learner snapshot; the note is kept in the bundle
+type SnapshotRequest struct {
+	Note string `json:"note"`
+}
+
+// SnapshotRestore is the outcome of restoring a snapshot. Previous is the learner's state just
+// before the restore, so the restore itself can be undone.
+type SnapshotRestore struct {
+	UserID   uuid.UUID        `json:"user_id"`
+	Rows     map[string]int64 `json:"rows"` // Rows restored per table
+	Previous *LearnerSnapshot `json:"previous"`
+}
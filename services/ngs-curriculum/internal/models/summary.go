@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+)
+
+// StreakStatus describes the user's daily activity streak
+type StreakStatus struct {
+	Days   int    `json:"days"`
+	Status string `json:"status"` // active, at_risk, frozen, none
+}
+
+// WeakTopic is the topic the user struggled with most during the period
+type WeakTopic struct {
+	Topic        string `json:"topic"`
+	FailedCount  int    `json:"failed_count"`
+	AverageScore int    `json:"average_score"`
+}
+
+// WeeklySummary aggregates the past 7 days for the digest email and recap card
+type WeeklySummary struct {
+	PeriodStart         time.Time      `json:"period_start"`
+	PeriodEnd           time.Time      `json:"period_end"`
+	TotalXP             int            `json:"total_xp"`
+	XPBySource          map[string]int `json:"xp_by_source"`
+	LessonsCompleted    int            `json:"lessons_completed"`
+	ChallengesCompleted int            `json:"challenges_completed"`
+	Streak              StreakStatus   `json:"streak"`
+	Percentile          int            `json:"percentile"`
+	PercentileChange    int            `json:"percentile_change"`
+	WeakTopic           *WeakTopic     `json:"weak_topic,omitempty"`
+}
+
+// ActivityDay is one cell of the activity heatmap
+type ActivityDay struct {
+	Date  string `json:"date"` // YYYY-MM-DD
+	Count int    `json:"count"`
+	XP    int    `json:"xp"`
+}
+
+// ActivityHeatmap holds per-day activity for one calendar year; days without activity are omitted
+type ActivityHeatmap struct {
+	Year       int           `json:"year"`
+	Days       []ActivityDay `json:"days"`
+	TotalCount int           `json:"total_count"`
+	ActiveDays int           `json:"active_days"`
+	MaxCount   int           `json:"max_count"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import "encoding/json"
+
+// Test case statuses
+const (
+	TestPassed         = "passed"
+	TestFailed         = "failed"
+	TestError          = "error"           // the code raised or failed to run
+	TestTimeout        = "timeout"         // the code ran past its time or CPU limit
+	TestMemoryExceeded = "memory_exceeded" // the code used more memory than its limit
+)
+
+// ResourceLimits caps each test case's run. Zero fields fall back to the SANDBOX_* defaults;
+// a zero default means no limit.
+type ResourceLimits struct {
+	TimeLimitMs   int `json:"time_limit_ms"` // wall clock
+	CPULimitMs    int `json:"cpu_limit_ms"`
+	MemoryLimitMB int `json:"memory_limit_mb"`
+}
+
+// TestCase is one entry of a challenge's test_cases. Hidden cases are graded but their
+// expected and actual output are never shown to learners. Points weighs the case in the score;
+// unset is 1.
+type TestCase struct {
+	Name     string          `json:"name,omitempty"`
+	Input    json.RawMessage `json:"input,omitempty"`
+	Expected json.RawMessage `json:"expected,omitempty"`
+	Hidden   bool            `json:"hidden,omitempty"`
+	Points   int             `json:"points,omitempty"`
+}
+
+// TestResults is the graded outcome of a submission, stored as challenge_submissions.test_results
+type TestResults struct {
+	TotalTests  int `json:"total_tests"`
+	PassedTests int `json:"passed_tests"`
+	FailedTests int `json:"failed_tests"`
+	// Partial credit: the points of the cases passed out of all cases' points
+	EarnedPoints int              `json:"earned_points"`
+	MaxPoints    int              `json:"max_points"`
+	Tests        []TestCaseResult `json:"tests"`
+	Error        string           `json:"error,omitempty"` // grading could not run at all
+	Note         string           `json:"note,omitempty"`
+	// Limits each case ran under
+	Limits *ResourceLimits `json:"limits,omitempty"`
+	// Performance scores optimization challenges
+	Performance *PerformanceScore `json:"performance,omitempty"`
+}
+
+// PerformanceScore compares a correct submission's total runtime and peak memory with the
+// reference solution's. Each part is 100 at or under the baseline and falls in proportion above it.
+type PerformanceScore struct {
+	RuntimeMs         float64 `json:"runtime_ms"`
+	MemoryKB          int64   `json:"memory_kb"`
+	BaselineRuntimeMs float64 `json:"baseline_runtime_ms,omitempty"`
+	BaselineMemoryKB  int64   `json:"baseline_memory_kb,omitempty"`
+	RuntimeScore      int     `json:"runtime_score"`
+	MemoryScore       int     `json:"memory_score"`
+}
+
+// TestCaseResult is the outcome of one test case
+type TestCaseResult struct {
+	Name         string          `json:"name"`
+	Status       string          `json:"status"` // passed, failed, error, timeout, memory_exceeded
+	Passed       bool            `json:"passed"`
+	Points       int             `json:"points"`
+	PointsEarned int             `json:"points_earned"`
+	Hidden       bool            `json:"hidden,omitempty"`
+	Input        json.RawMessage `json:"input,omitempty"`
+	Expected     json.RawMessage `json:"expected,omitempty"`
+	Actual       json.RawMessage `json:"actual,omitempty"`
+	Output       string          `json:"output,omitempty"` // error or console output
+	RuntimeMs    float64         `json:"runtime_ms"`
+	CPUMs        float64         `json:"cpu_ms"`
+	MemoryKB     int64           `json:"memory_kb"` // peak
+	Feedback     string          `json:"feedback"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Quota is a user's consumption of one allowance. Limit and Remaining are null when the
+// allowance is unlimited.
+type Quota struct {
+	Used      int        `json:"used"`
+	Limit     *int       `json:"limit"`
+	Remaining *int       `json:"remaining"`
+	ResetsAt  *time.Time `json:"resets_at,omitempty"`
+}
+
+// UserUsage summarises the quotas a client may hit, so it can show them before a 429
+type UserUsage struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Tier      string    `json:"tier"`
+	LLMTokens Quota     `json:"llm_tokens"`           // Today (UTC), as the intelligence service counts them
+	RateLimit *Quota    `json:"rate_limit,omitempty"` // The gateway's request window; omitted when not forwarded
+	DailyXP   Quota     `json:"daily_xp"`             // XP earned today (UTC); uncapped
+}
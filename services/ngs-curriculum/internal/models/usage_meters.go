@@ -0,0 +1,12 @@
+package models
+
+// OrgUsage is an organization's metered usage over one calendar month (UTC)
+type OrgUsage struct {
+	OrgID          string  `json:"org_id"`
+	Month          string  `json:"month"` // YYYY-MM
+	APICalls       int64   `json:"api_calls"`
+	APICallsLimit  *int64  `json:"api_calls_limit,omitempty"`
+	LLMTokens      int64   `json:"llm_tokens"`
+	SandboxMinutes float64 `json:"sandbox_minutes"`
+	StorageBytes   int64   `json:"storage_bytes"`
+}
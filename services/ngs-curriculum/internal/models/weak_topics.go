@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LearnerWeakTopic is a topic a learner keeps missing: a challenge tag or a lesson title. Score
+// is the misses less the successes since, and the counts say where the misses came from.
+type LearnerWeakTopic struct {
+	Topic             string     `json:"topic"`
+	Score             int        `json:"score"`
+	QuizMisses        int        `json:"quiz_misses"`
+	ChallengeFailures int        `json:"challenge_failures"`
+	LowReflections    int        `json:"low_reflections"`
+	Recoveries        int        `json:"recoveries"`
+	LastMissedAt      *time.Time `json:"last_missed_at,omitempty"`
+}
+
+// WeakTopicsResponse lists a learner's weak topics, weakest first
+type WeakTopicsResponse struct {
+	UserID           uuid.UUID          `json:"user_id"`
+	Topics           []LearnerWeakTopic `json:"topics"`
+	RefreshedThrough time.Time          `json:"refreshed_through"`
+}
@@ -0,0 +1,114 @@
+// Package mtls serves the internal listener: admin and service-to-service routes over TLS that
+// requires a client certificate signed by INTERNAL_TLS_CLIENT_CA. When
+// INTERNAL_TLS_ALLOWED_CLIENTS is set, the certificate's common name or one of its DNS names must
+// also be listed, so a certificate from the same CA issued to another service is refused during
+// the handshake.
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrClientNotAllowed fails the handshake of a client certificate outside the allowlist
+var ErrClientNotAllowed = errors.New("client certificate not allowed")
+
+var handshakesRefused = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "ngs_internal_tls_clients_refused_total",
+	Help: "Internal listener handshakes refused because the client certificate was not on the allowlist.",
+})
+
+func init() {
+	prometheus.MustRegister(handshakesRefused)
+}
+
+// ParseAllowlist reads comma-separated client names
+func ParseAllowlist(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ClientNames returns the names a client certificate can be allowed by: its common name, then
+// its DNS names
+func ClientNames(cert *x509.Certificate) []string {
+	var names []string
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	return append(names, cert.DNSNames...)
+}
+
+// Allowed reports whether cert is named in allowed; an empty allowlist allows any client the CA
+// signed
+func Allowed(cert *x509.Certificate, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, name := range ClientNames(cert) {
+		for _, a := range allowed {
+			if strings.EqualFold(name, a) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ServerConfig loads the listener's certificate and key and the CA client certificates must
+// chain to
+func ServerConfig(certFile, keyFile, clientCAFile string, allowed []string) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" || clientCAFile == "" {
+		return nil, errors.New("the certificate, key and client CA are all required")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	caPEM, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates in client CA %s", clientCAFile)
+	}
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			leaf := state.PeerCertificates[0]
+			if !Allowed(leaf, allowed) {
+				handshakesRefused.Inc()
+				return fmt.Errorf("%w: %s", ErrClientNotAllowed, strings.Join(ClientNames(leaf), ", "))
+			}
+			return nil
+		},
+	}, nil
+}
+
+// Client returns the name of the verified client certificate on a connection, or "" when the
+// connection did not come through the internal listener
+func Client(state *tls.ConnectionState) string {
+	if state == nil || len(state.VerifiedChains) == 0 {
+		return ""
+	}
+	names := ClientNames(state.VerifiedChains[0][0])
+	if len(names) == 0 {
+		return state.VerifiedChains[0][0].Subject.String()
+	}
+	return names[0]
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	markdownSyntax  = regexp.MustCompile("(?s)```.*?```|`[^`]*`|!?\\[([^\\]]*)\\]\\([^)]*\\)|[#*_>|~-]+")
+	sentenceEndings = regexp.MustCompile(`[.!?]+(\s|$)|\n\s*\n`)
+)
+
+// EstimateReadingLevel returns the Flesch-Kincaid grade level of markdown text, rounded to
+// one decimal and clamped to 0-20. Code blocks and markdown syntax are ignored.
+func EstimateReadingLevel(markdown string) float64 {
+	text := markdownSyntax.ReplaceAllString(markdown, " $1 ")
+
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	if len(words) == 0 {
+		return 0
+	}
+
+	sentences := len(sentenceEndings.FindAllStringIndex(strings.TrimSpace(text), -1))
+	if sentences == 0 {
+		sentences = 1
+	}
+
+	syllables := 0
+	for _, word := range words {
+		syllables += countSyllables(word)
+	}
+
+	grade := 0.39*float64(len(words))/float64(sentences) + 11.8*float64(syllables)/float64(len(words)) - 15.59
+	grade = math.Max(0, math.Min(20, grade))
+	return math.Round(grade*10) / 10
+}
+
+// countSyllables approximates syllables as vowel groups, ignoring a silent trailing "e"
+func countSyllables(word string) int {
+	word = strings.ToLower(word)
+	count := 0
+	prevVowel := false
+	for _, r := range word {
+		vowel := strings.ContainsRune("aeiouy", r)
+		if vowel && !prevVowel {
+			count++
+		}
+		prevVowel = vowel
+	}
+	if strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") && count > 1 {
+		count--
+	}
+	if count == 0 {
+		count = 1
+	}
+	return count
+}
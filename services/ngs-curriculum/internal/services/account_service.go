@@ -0,0 +1,198 @@
+package services
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Account lifecycle event types
+const (
+	AccountEventDeactivated = "account.deactivated"
+	AccountEventReactivated = "account.reactivated"
+)
+
+// Where a deactivation or reactivation came from
+const (
+	AccountSourceAdmin = "admin"
+	AccountSourceEvent = "event"
+)
+
+var (
+	ErrInvalidAccountEvent   = errors.New("invalid account event")
+	ErrAccountEventsDisabled = errors.New("account events are not enabled")
+	ErrAccountEventToken     = errors.New("invalid account events token")
+)
+
+// AccountService deactivates and reactivates accounts. Deactivation is soft and separate from
+// deleting an account: nothing is removed, the learner is only left off leaderboards, their
+// notifications are off and their streak is frozen until they come back.
+type AccountService struct {
+	db     *database.DB
+	config *config.Config
+	clock  Clock
+}
+
+func NewAccountService(db *database.DB, cfg *config.Config, clock Clock) *AccountService {
+	return &AccountService{
+		db:     db,
+		config: cfg,
+		clock:  clock,
+	}
+}
+
+// eventTime is when an event happened: at if given, but never in the future
+func (s *AccountService) eventTime(at *time.Time) time.Time {
+	now := s.clock.Now()
+	if at == nil || at.After(now) {
+		return now
+	}
+	return at.UTC()
+}
+
+// DeactivateAccount deactivates an account as of at. Deactivating an account that already is
+// changes nothing.
+func (s *AccountService) DeactivateAccount(userID uuid.UUID, reason, source string, at *time.Time) (*models.AccountStatus, error) {
+	when := s.eventTime(at)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO ngs_account_deactivations (user_id, reason, source, deactivated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) WHERE reactivated_at IS NULL DO NOTHING
+	`, userID, strings.TrimSpace(reason), source, when)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record deactivation: %w", err)
+	}
+	if inserted, _ := result.RowsAffected(); inserted > 0 {
+		_, err = tx.Exec(`
+			INSERT INTO user_settings (user_id, deactivated_at)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE SET deactivated_at = EXCLUDED.deactivated_at, updated_at = NOW()
+		`, userID, when)
+		if err != nil {
+			return nil, fmt.Errorf("failed to deactivate account: %w", err)
+		}
+		log.Printf("Account %s deactivated (%s)", userID, source)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit deactivation: %w", err)
+	}
+	return s.GetAccountStatus(userID)
+}
+
+// ReactivateAccount reactivates an account as of at, keeping its streak frozen for
+// REACTIVATION_GRACE_DAYS so the learner has time to pick it up again. Reactivating an account
+// that is not deactivated changes nothing.
+func (s *AccountService) ReactivateAccount(userID uuid.UUID, source string, at *time.Time) (*models.AccountStatus, error) {
+	when := s.eventTime(at)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE ngs_account_deactivations
+		SET reactivated_at = GREATEST($2, deactivated_at), reactivated_source = $3, grace_days = $4
+		WHERE user_id = $1 AND reactivated_at IS NULL
+	`, userID, when, source, max(s.config.ReactivationGraceDays, 0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record reactivation: %w", err)
+	}
+	if updated, _ := result.RowsAffected(); updated > 0 {
+		_, err = tx.Exec(`
+			UPDATE user_settings SET deactivated_at = NULL, updated_at = NOW() WHERE user_id = $1
+		`, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reactivate account: %w", err)
+		}
+		log.Printf("Account %s reactivated (%s)", userID, source)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit reactivation: %w", err)
+	}
+	return s.GetAccountStatus(userID)
+}
+
+// CheckAccountEventToken checks the shared secret sent with account events against
+// ACCOUNT_EVENTS_TOKEN; events are refused while it is unset
+func (s *AccountService) CheckAccountEventToken(token string) error {
+	if s.config.AccountEventsToken == "" {
+		return ErrAccountEventsDisabled
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AccountEventsToken)) != 1 {
+		return ErrAccountEventToken
+	}
+	return nil
+}
+
+// HandleAccountEvent applies an account lifecycle event from the gateway
+func (s *AccountService) HandleAccountEvent(event models.AccountEvent) (*models.AccountStatus, error) {
+	if event.UserID == uuid.Nil {
+		return nil, fmt.Errorf("%w: user_id is required", ErrInvalidAccountEvent)
+	}
+	switch event.Type {
+	case AccountEventDeactivated:
+		return s.DeactivateAccount(event.UserID, event.Reason, AccountSourceEvent, event.OccurredAt)
+	case AccountEventReactivated:
+		return s.ReactivateAccount(event.UserID, AccountSourceEvent, event.OccurredAt)
+	}
+	return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidAccountEvent, event.Type)
+}
+
+// GetAccountStatus returns whether an account is deactivated and its last 20 deactivations
+func (s *AccountService) GetAccountStatus(userID uuid.UUID) (*models.AccountStatus, error) {
+	rows, err := s.db.Query(`
+		SELECT id, reason, source, deactivated_at, reactivated_at, COALESCE(reactivated_source, ''), grace_days
+		FROM ngs_account_deactivations
+		WHERE user_id = $1
+		ORDER BY deactivated_at DESC, id
+		LIMIT 20
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deactivations: %w", err)
+	}
+	defer rows.Close()
+
+	status := &models.AccountStatus{UserID: userID, Deactivations: []models.AccountDeactivation{}}
+	for rows.Next() {
+		var deactivation models.AccountDeactivation
+		var reactivatedAt sql.NullTime
+		if err := rows.Scan(
+			&deactivation.ID, &deactivation.Reason, &deactivation.Source, &deactivation.DeactivatedAt,
+			&reactivatedAt, &deactivation.ReactivatedSource, &deactivation.GraceDays,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan deactivation: %w", err)
+		}
+		if reactivatedAt.Valid {
+			deactivation.ReactivatedAt = &reactivatedAt.Time
+		} else {
+			status.Deactivated = true
+		}
+		status.Deactivations = append(status.Deactivations, deactivation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read deactivations: %w", err)
+	}
+
+	return status, nil
+}
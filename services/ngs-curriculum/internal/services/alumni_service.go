@@ -0,0 +1,141 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/domain/xp"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var ErrNotAlumni = errors.New("the alumni feed opens once the final level is reached")
+
+// alumniChallengeDifficulties are the challenge difficulties the alumni feed draws from
+var alumniChallengeDifficulties = []string{"hard", "expert"}
+
+// mentorshipPrompts rotate through the alumni feed, a few each week
+var mentorshipPrompts = []models.AlumniFeedItem{
+	{Title: "Pair with a newer learner", Body: "Join an open collaboration on a challenge below your level and let your newer teammate drive. Review their approach together before you submit."},
+	{Title: "Explain what you once found hard", Body: "Write a reflection aimed at a level 1 learner that explains the concept you struggled with most. Teaching it back is the best test of mastery.", Endpoint: "/ngs/reflections"},
+	{Title: "Share a worked solution", Body: "Pick a challenge you have solved and share your solution with notes on the trade-offs you weighed and the approaches you discarded."},
+	{Title: "Write to your earlier self", Body: "Re-read one of your first reflections. What would you tell that learner now? Post the advice as a new reflection.", Endpoint: "/ngs/reflections"},
+	{Title: "Design a practice drill", Body: "Sketch a ten-minute drill for a stage of a track you have mastered and share it with your cohort or educator."},
+	{Title: "Duel and debrief", Body: "Queue for a duel and, win or lose, leave your opponent one concrete tip afterwards.", Endpoint: "/ngs/duels/queue"},
+	{Title: "Walk a peer through the ethics checklist", Body: "Go through the ethical AI checklist with a peer on their current project and note one risk they had not considered."},
+	{Title: "Answer the question you asked at level 12", Body: "Think back to where you were stuck halfway through the curriculum and write the guide you wish you had had."},
+}
+
+// AlumniWeek returns the ISO week key for t (in UTC) and the start of the next week, when the
+// feed rotates
+func AlumniWeek(t time.Time) (string, time.Time) {
+	t = t.UTC()
+	year, week := t.ISOWeek()
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	monday := time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, time.UTC)
+	return fmt.Sprintf("%d-W%02d", year, week), monday.AddDate(0, 0, 7)
+}
+
+// AlumniPrompts returns the count mentorship prompts for the week starting at weekStart. Every
+// prompt is shown before any repeats.
+func AlumniPrompts(weekStart time.Time, count int) []models.AlumniFeedItem {
+	if count <= 0 {
+		return nil
+	}
+	if count > len(mentorshipPrompts) {
+		count = len(mentorshipPrompts)
+	}
+	weeks := int(weekStart.Unix() / int64(7*24*time.Hour/time.Second))
+	prompts := make([]models.AlumniFeedItem, 0, count)
+	for i := 0; i < count; i++ {
+		prompt := mentorshipPrompts[(weeks*count+i)%len(mentorshipPrompts)]
+		prompt.Type = "mentorship"
+		prompts = append(prompts, prompt)
+	}
+	return prompts
+}
+
+// AlumniService serves continuing-education content to users past the final level
+type AlumniService struct {
+	db     *database.DB
+	config *config.Config
+	clock  Clock
+}
+
+func NewAlumniService(db *database.DB, cfg *config.Config, clock Clock) *AlumniService {
+	return &AlumniService{
+		db:     db,
+		config: cfg,
+		clock:  clock,
+	}
+}
+
+// GetFeed returns this week's alumni feed: advanced challenges the user has not passed, picked
+// afresh for each user every week, then the week's mentorship prompts
+func (s *AlumniService) GetFeed(userID uuid.UUID) (*models.AlumniFeed, error) {
+	level := 1
+	err := s.db.QueryRow(`SELECT current_level FROM user_progress WHERE user_id = $1`, userID).Scan(&level)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get current level: %w", err)
+	}
+	if level < xp.MaxLevel(s.config.LevelUpXPThresholds) {
+		return nil, ErrNotAlumni
+	}
+
+	week, rotatesAt := AlumniWeek(s.clock.Now())
+	feed := &models.AlumniFeed{UserID: userID, Week: week, RotatesAt: rotatesAt, Items: []models.AlumniFeedItem{}}
+
+	challenges, err := s.rotatingChallenges(userID, week)
+	if err != nil {
+		return nil, err
+	}
+	feed.Items = append(feed.Items, challenges...)
+	feed.Items = append(feed.Items, AlumniPrompts(rotatesAt.AddDate(0, 0, -7), s.config.AlumniFeedPrompts)...)
+	return feed, nil
+}
+
+// rotatingChallenges picks active advanced challenges the user may see and has not passed,
+// ordered by a hash of the challenge, user and week so each week brings a different set
+func (s *AlumniService) rotatingChallenges(userID uuid.UUID, week string) ([]models.AlumniFeedItem, error) {
+	if s.config.AlumniFeedChallenges <= 0 {
+		return nil, nil
+	}
+	rows, err := s.db.Query(`
+		SELECT c.id, c.level_id, c.title, c.description, c.difficulty, COALESCE(c.xp_reward, 0)
+		FROM challenges c
+		WHERE c.is_active = true AND c.difficulty = ANY($2)
+			AND `+contentAgeFilterSQL("c.min_age_band", "$1")+`
+			AND NOT EXISTS (
+				SELECT 1 FROM challenge_submissions cs
+				WHERE cs.challenge_id = c.id AND cs.user_id = $1 AND cs.passed = true
+			)
+		ORDER BY md5(c.id::text || $3)
+		LIMIT $4
+	`, userID, pq.Array(alumniChallengeDifficulties), userID.String()+week, s.config.AlumniFeedChallenges)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alumni challenges: %w", err)
+	}
+	defer rows.Close()
+
+	var items []models.AlumniFeedItem
+	for rows.Next() {
+		var id uuid.UUID
+		item := models.AlumniFeedItem{Type: "challenge"}
+		if err := rows.Scan(&id, &item.LevelNumber, &item.Title, &item.Body, &item.Difficulty, &item.XPReward); err != nil {
+			return nil, fmt.Errorf("failed to scan alumni challenge: %w", err)
+		}
+		item.ResourceID = &id
+		item.Endpoint = fmt.Sprintf("/ngs/challenges/%s", id)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read alumni challenges: %w", err)
+	}
+	return items, nil
+}
@@ -0,0 +1,375 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Cohort analytics defaults and bounds for the query parameters
+const (
+	DefaultStuckAfterDays = 7
+	MaxStuckAfterDays     = 90
+	DefaultTrendWeeks     = 12
+	MaxTrendWeeks         = 52
+)
+
+// Reflection quality trends
+const (
+	ReflectionTrendImproving    = "improving"
+	ReflectionTrendDeclining    = "declining"
+	ReflectionTrendSteady       = "steady"
+	ReflectionTrendInsufficient = "insufficient_data"
+)
+
+const (
+	// stuckLessonsLimit is how many stuck lessons the dashboard lists, most stuck members first
+	stuckLessonsLimit = 10
+	// highQualityReflection is the quality score reflections earn the high quality XP at
+	highQualityReflection = 0.8
+	// reflectionTrendSlope is the change in weekly average quality per week, either way, beyond
+	// which quality counts as improving or declining
+	reflectionTrendSlope = 0.01
+)
+
+// xpBucketBounds are the lower bounds of the XP distribution buckets after the first, which
+// starts at 0
+var xpBucketBounds = []int64{100, 250, 500, 1000, 2500, 5000, 10000}
+
+// AnalyticsService reports how a cohort is progressing to its educator
+type AnalyticsService struct {
+	db            *database.DB
+	cohortService *CohortService
+	clock         Clock
+}
+
+func NewAnalyticsService(db *database.DB, cohortService *CohortService, clock Clock) *AnalyticsService {
+	return &AnalyticsService{
+		db:            db,
+		cohortService: cohortService,
+		clock:         clock,
+	}
+}
+
+// cohortLessonsSQL selects the lessons members of the cohort bound to $1 work through: shared
+// lessons and the cohort's own, less those replaced by the cohort's overlays or hidden by its
+// curriculum. Age bands differ between members and are not applied.
+func cohortLessonsSQL() string {
+	return `
+		SELECT l.id, l.level_id, l.title
+		FROM lessons l
+		WHERE (l.overlay_cohort_id IS NULL OR l.overlay_cohort_id = $1)
+		  AND NOT EXISTS (
+			SELECT 1 FROM lessons ov WHERE ov.overlay_of = l.id AND ov.overlay_cohort_id = $1)
+		  AND NOT (NOT COALESCE(l.is_required, true) AND EXISTS (
+			SELECT 1 FROM cohort_curriculum_overrides cco
+			WHERE cco.cohort_id = $1 AND cco.hidden
+			  AND (cco.lesson_id IN (l.id, l.overlay_of) OR cco.track = ` + lessonTrackSQL("l.lesson_order") + `)))`
+}
+
+// GetCohortAnalytics aggregates the progress of the educator's cohort: completion per level,
+// time spent, the spread of XP, lessons members have been stuck on for stuckAfterDays and the
+// weekly quality of their reflections over the last weeks weeks
+func (s *AnalyticsService) GetCohortAnalytics(cohortID uuid.UUID, educatorID uuid.UUID, stuckAfterDays int, weeks int) (*models.CohortAnalytics, error) {
+	cohort, err := s.cohortService.GetCohort(cohortID, educatorID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	analytics := &models.CohortAnalytics{
+		CohortID:       cohortID,
+		MemberCount:    cohort.MemberCount,
+		StuckAfterDays: stuckAfterDays,
+		GeneratedAt:    now,
+	}
+	if analytics.Levels, analytics.AverageTimeSpentSeconds, err = s.levelStats(cohortID, cohort.MemberCount); err != nil {
+		return nil, err
+	}
+	if analytics.XPDistribution, err = s.xpDistribution(cohortID); err != nil {
+		return nil, err
+	}
+	if analytics.StuckLessons, err = s.stuckLessons(cohortID, now.AddDate(0, 0, -stuckAfterDays)); err != nil {
+		return nil, err
+	}
+	if analytics.ReflectionQuality, err = s.reflectionQuality(cohortID, now, weeks); err != nil {
+		return nil, err
+	}
+	return analytics, nil
+}
+
+// levelStats returns completion and time spent per level, and the mean time spent on a lesson
+// across all levels
+func (s *AnalyticsService) levelStats(cohortID uuid.UUID, members int) ([]models.CohortLevelStats, *float64, error) {
+	rows, err := s.db.Query(`
+		WITH cohort_lessons AS (`+cohortLessonsSQL()+`
+		), levels AS (
+			SELECT level_id, COUNT(*) AS lessons FROM cohort_lessons GROUP BY level_id
+		), done AS (
+			SELECT cl.level_id, lc.user_id, COUNT(*) AS completed,
+			       SUM(lc.time_spent_seconds) FILTER (WHERE lc.time_spent_seconds > 0) AS seconds,
+			       COUNT(*) FILTER (WHERE lc.time_spent_seconds > 0) AS timed
+			FROM lesson_completions lc
+			JOIN cohort_members cm ON cm.user_id = lc.user_id AND cm.cohort_id = $1
+			JOIN cohort_lessons cl ON cl.id = lc.lesson_id
+			GROUP BY cl.level_id, lc.user_id
+		)
+		SELECT lv.level_id, lv.lessons, COALESCE(SUM(d.completed), 0), COUNT(d.user_id),
+		       COUNT(d.user_id) FILTER (WHERE d.completed >= lv.lessons),
+		       COALESCE(SUM(d.seconds), 0), COALESCE(SUM(d.timed), 0)
+		FROM levels lv
+		LEFT JOIN done d ON d.level_id = lv.level_id
+		GROUP BY lv.level_id, lv.lessons
+		ORDER BY lv.level_id
+	`, cohortID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to aggregate cohort completions: %w", err)
+	}
+	defer rows.Close()
+
+	levels := []models.CohortLevelStats{}
+	var totalSeconds, totalTimed int64
+	for rows.Next() {
+		var level models.CohortLevelStats
+		var seconds, timed int64
+		if err := rows.Scan(&level.LevelNumber, &level.LessonCount, &level.Completions,
+			&level.MembersStarted, &level.MembersCompleted, &seconds, &timed); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan cohort level stats: %w", err)
+		}
+		level.CompletionRate = CompletionRate(level.Completions, level.LessonCount, members)
+		level.AverageTimeSpentSeconds = averageSeconds(seconds, timed)
+		totalSeconds += seconds
+		totalTimed += timed
+		levels = append(levels, level)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read cohort level stats: %w", err)
+	}
+	return levels, averageSeconds(totalSeconds, totalTimed), nil
+}
+
+// CompletionRate is the share of the lessons × members lesson completions made, 0 when there
+// are no lessons or no members
+func CompletionRate(completions int, lessons int, members int) float64 {
+	if lessons <= 0 || members <= 0 {
+		return 0
+	}
+	rate := float64(completions) / float64(lessons*members)
+	if rate > 1 {
+		rate = 1
+	}
+	return rate
+}
+
+func averageSeconds(seconds int64, count int64) *float64 {
+	if count == 0 {
+		return nil
+	}
+	average := float64(seconds) / float64(count)
+	return &average
+}
+
+// xpDistribution buckets the members' total XP; members without progress have none
+func (s *AnalyticsService) xpDistribution(cohortID uuid.UUID) (models.CohortXPDistribution, error) {
+	var distribution models.CohortXPDistribution
+	err := s.db.QueryRow(`
+		SELECT COALESCE(AVG(COALESCE(up.total_xp, 0)), 0)::float8,
+		       COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY COALESCE(up.total_xp, 0)), 0)::float8,
+		       COALESCE(MAX(up.total_xp), 0)
+		FROM cohort_members cm
+		LEFT JOIN user_progress up ON up.user_id = cm.user_id
+		WHERE cm.cohort_id = $1
+	`, cohortID).Scan(&distribution.Average, &distribution.Median, &distribution.Max)
+	if err != nil {
+		return distribution, fmt.Errorf("failed to aggregate cohort XP: %w", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT width_bucket(COALESCE(up.total_xp, 0), $2::int[]), COUNT(*)
+		FROM cohort_members cm
+		LEFT JOIN user_progress up ON up.user_id = cm.user_id
+		WHERE cm.cohort_id = $1
+		GROUP BY 1
+	`, cohortID, pq.Array(xpBucketBounds))
+	if err != nil {
+		return distribution, fmt.Errorf("failed to bucket cohort XP: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var bucket, members int
+		if err := rows.Scan(&bucket, &members); err != nil {
+			return distribution, fmt.Errorf("failed to scan cohort XP bucket: %w", err)
+		}
+		counts[bucket] = members
+	}
+	if err := rows.Err(); err != nil {
+		return distribution, fmt.Errorf("failed to read cohort XP buckets: %w", err)
+	}
+	distribution.Buckets = XPBuckets(counts)
+	return distribution, nil
+}
+
+// XPBuckets lays out every XP bucket, empty ones included, with the member counts of counts,
+// which is keyed by the bucket's index as width_bucket numbers it (0 for the first)
+func XPBuckets(counts map[int]int) []models.XPBucket {
+	buckets := make([]models.XPBucket, 0, len(xpBucketBounds)+1)
+	lower := 0
+	for i := 0; i <= len(xpBucketBounds); i++ {
+		bucket := models.XPBucket{MinXP: lower, Members: counts[i]}
+		if i < len(xpBucketBounds) {
+			upper := int(xpBucketBounds[i])
+			bucket.MaxXP = &upper
+			lower = upper
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets
+}
+
+// stuckLessons returns the lessons members started and have neither completed nor touched since
+// cutoff. A lesson is started once its metrics are recorded.
+func (s *AnalyticsService) stuckLessons(cohortID uuid.UUID, cutoff time.Time) ([]models.StuckLesson, error) {
+	rows, err := s.db.Query(`
+		WITH cohort_lessons AS (`+cohortLessonsSQL()+`
+		), started AS (
+			SELECT lm.user_id, lm.lesson_id, MAX(lm.updated_at) AS touched_at
+			FROM lesson_metrics lm
+			JOIN cohort_members cm ON cm.user_id = lm.user_id AND cm.cohort_id = $1
+			GROUP BY lm.user_id, lm.lesson_id
+		)
+		SELECT cl.id, cl.title, cl.level_id, COUNT(*),
+		       COUNT(*) FILTER (WHERE lc.id IS NULL AND s.touched_at < $2),
+		       MIN(s.touched_at) FILTER (WHERE lc.id IS NULL AND s.touched_at < $2)
+		FROM started s
+		JOIN cohort_lessons cl ON cl.id = s.lesson_id
+		LEFT JOIN lesson_completions lc ON lc.user_id = s.user_id AND lc.lesson_id = s.lesson_id
+		GROUP BY cl.id, cl.title, cl.level_id
+		HAVING COUNT(*) FILTER (WHERE lc.id IS NULL AND s.touched_at < $2) > 0
+		ORDER BY 5 DESC, cl.level_id, cl.title
+		LIMIT $3
+	`, cohortID, cutoff, stuckLessonsLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find stuck lessons: %w", err)
+	}
+	defer rows.Close()
+
+	lessons := []models.StuckLesson{}
+	for rows.Next() {
+		var lesson models.StuckLesson
+		if err := rows.Scan(&lesson.LessonID, &lesson.LessonTitle, &lesson.LevelNumber,
+			&lesson.MembersStarted, &lesson.MembersStuck, &lesson.StuckSince); err != nil {
+			return nil, fmt.Errorf("failed to scan stuck lesson: %w", err)
+		}
+		if lesson.MembersStarted > 0 {
+			lesson.StuckRate = float64(lesson.MembersStuck) / float64(lesson.MembersStarted)
+		}
+		lessons = append(lessons, lesson)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stuck lessons: %w", err)
+	}
+	return lessons, nil
+}
+
+// reflectionQuality returns the weekly quality of members' scored reflections over the last
+// weeks weeks, the current week included
+func (s *AnalyticsService) reflectionQuality(cohortID uuid.UUID, now time.Time, weeks int) (models.CohortReflectionQuality, error) {
+	since := WeekStart(now).AddDate(0, 0, -7*(weeks-1))
+	rows, err := s.db.Query(`
+		SELECT date_trunc('week', r.created_at), COUNT(*), AVG(r.quality_score)::float8,
+		       COUNT(*) FILTER (WHERE r.quality_score >= $3)
+		FROM user_reflections r
+		JOIN cohort_members cm ON cm.user_id = r.user_id AND cm.cohort_id = $1
+		WHERE r.created_at >= $2 AND r.quality_score IS NOT NULL
+		GROUP BY 1
+		ORDER BY 1
+	`, cohortID, since, highQualityReflection)
+	if err != nil {
+		return models.CohortReflectionQuality{}, fmt.Errorf("failed to aggregate reflection quality: %w", err)
+	}
+	defer rows.Close()
+
+	found := []models.ReflectionQualityWeek{}
+	for rows.Next() {
+		var week models.ReflectionQualityWeek
+		var average sql.NullFloat64
+		if err := rows.Scan(&week.WeekStart, &week.Reflections, &average, &week.HighQuality); err != nil {
+			return models.CohortReflectionQuality{}, fmt.Errorf("failed to scan reflection quality: %w", err)
+		}
+		if average.Valid {
+			week.AverageQuality = &average.Float64
+		}
+		found = append(found, week)
+	}
+	if err := rows.Err(); err != nil {
+		return models.CohortReflectionQuality{}, fmt.Errorf("failed to read reflection quality: %w", err)
+	}
+
+	quality := models.CohortReflectionQuality{Weeks: ReflectionWeeks(since, weeks, found)}
+	quality.Trend = ReflectionTrend(quality.Weeks)
+	return quality, nil
+}
+
+// WeekStart is midnight UTC on the Monday of t's week, as date_trunc('week') has it
+func WeekStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+// ReflectionWeeks lays out weeks consecutive weeks from since, taking each from found when it
+// has reflections and leaving it empty otherwise
+func ReflectionWeeks(since time.Time, weeks int, found []models.ReflectionQualityWeek) []models.ReflectionQualityWeek {
+	byWeek := make(map[time.Time]models.ReflectionQualityWeek, len(found))
+	for _, week := range found {
+		byWeek[WeekStart(week.WeekStart)] = week
+	}
+
+	series := make([]models.ReflectionQualityWeek, 0, weeks)
+	start := WeekStart(since)
+	for i := 0; i < weeks; i++ {
+		weekStart := start.AddDate(0, 0, 7*i)
+		week := byWeek[weekStart]
+		week.WeekStart = weekStart
+		series = append(series, week)
+	}
+	return series
+}
+
+// ReflectionTrend fits a line through the average quality of the weeks that have reflections.
+// Quality is improving or declining when it moves by more than reflectionTrendSlope a week, and
+// steady otherwise. Fewer than two weeks with reflections are insufficient_data.
+func ReflectionTrend(weeks []models.ReflectionQualityWeek) string {
+	var n, sumX, sumY, sumXY, sumXX float64
+	for i, week := range weeks {
+		if week.AverageQuality == nil || week.Reflections == 0 {
+			continue
+		}
+		x, y := float64(i), *week.AverageQuality
+		n++
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	if n < 2 {
+		return ReflectionTrendInsufficient
+	}
+
+	slope := (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+	switch {
+	case slope > reflectionTrendSlope:
+		return ReflectionTrendImproving
+	case slope < -reflectionTrendSlope:
+		return ReflectionTrendDeclining
+	}
+	return ReflectionTrendSteady
+}
@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"noble-ngs-curriculum/internal/clients/tts"
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAudioUnavailable = errors.New("audio generation is not configured")
+	ErrAudioLinkInvalid = errors.New("audio link is invalid or expired")
+	ErrAudioNotFound    = errors.New("audio not found")
+)
+
+// maxSpeechChunk keeps each provider call under common TTS request limits
+const maxSpeechChunk = 4000
+
+var extraWhitespace = regexp.MustCompile(`[ \t]+`)
+
+type AudioService struct {
+	db       *database.DB
+	config   *config.Config
+	provider tts.Provider
+	clock    Clock
+	secret   []byte
+
+	// inflight serialises synthesis per lesson/version/voice so concurrent requests share one render
+	inflight sync.Map
+}
+
+func NewAudioService(db *database.DB, cfg *config.Config, provider tts.Provider, clock Clock) *AudioService {
+	secret := []byte(cfg.AudioURLSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("Failed to generate audio URL secret: %v", err)
+		}
+		log.Printf("⚠️  AUDIO_URL_SECRET not set; audio links will not survive a restart")
+	}
+
+	return &AudioService{
+		db:       db,
+		config:   cfg,
+		provider: provider,
+		clock:    clock,
+		secret:   secret,
+	}
+}
+
+// GetLessonAudio returns a signed link to the audio for a lesson's current content version,
+// synthesizing and caching it on first request. A lesson above the user's level is
+// ErrLevelLocked.
+func (s *AudioService) GetLessonAudio(ctx context.Context, lessonID uuid.UUID, userID uuid.UUID) (*models.LessonAudio, error) {
+	var markdown sql.NullString
+	var version int
+	var locked bool
+	err := s.db.QueryRow(`
+		SELECT content_markdown, COALESCE(content_version, 0), `+lessonLockedSQL("lessons", "$2")+`
+		FROM lessons
+		WHERE id = $1 AND `+lessonVisibleSQL("lessons", "$2")+`
+	`, lessonID, userID).Scan(&markdown, &version, &locked)
+	if err == sql.ErrNoRows {
+		return nil, ErrLessonNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lesson: %w", err)
+	}
+	if locked {
+		return nil, ErrLevelLocked
+	}
+
+	voice := s.config.TTSVoice
+	audio, err := s.findAudio(lessonID, version, voice)
+	if err == sql.ErrNoRows {
+		audio, err = s.renderAudio(ctx, lessonID, version, voice, markdown.String)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.sign(audio)
+	return audio, nil
+}
+
+const lessonAudioColumns = `id, lesson_id, content_version, voice, provider, storage_path,
+	content_type, size_bytes, created_at`
+
+func scanLessonAudio(row rowScanner) (*models.LessonAudio, error) {
+	var audio models.LessonAudio
+	err := row.Scan(
+		&audio.ID, &audio.LessonID, &audio.ContentVersion, &audio.Voice, &audio.Provider,
+		&audio.StoragePath, &audio.ContentType, &audio.SizeBytes, &audio.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &audio, nil
+}
+
+func (s *AudioService) findAudio(lessonID uuid.UUID, version int, voice string) (*models.LessonAudio, error) {
+	audio, err := scanLessonAudio(s.db.QueryRow(`
+		SELECT `+lessonAudioColumns+`
+		FROM lesson_audio
+		WHERE lesson_id = $1 AND content_version = $2 AND voice = $3
+	`, lessonID, version, voice))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to query lesson audio: %w", err)
+	}
+	return audio, err
+}
+
+// renderAudio synthesizes speech chunk by chunk, writes the file and records it in the cache
+func (s *AudioService) renderAudio(ctx context.Context, lessonID uuid.UUID, version int, voice, markdown string) (*models.LessonAudio, error) {
+	if s.provider == nil {
+		return nil, ErrAudioUnavailable
+	}
+
+	key := fmt.Sprintf("%s/%d/%s", lessonID, version, voice)
+	lock, _ := s.inflight.LoadOrStore(key, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer func() {
+		lock.(*sync.Mutex).Unlock()
+		s.inflight.Delete(key)
+	}()
+
+	// Another request may have finished the render while we waited
+	if audio, err := s.findAudio(lessonID, version, voice); err != sql.ErrNoRows {
+		return audio, err
+	}
+
+	chunks := SpeechChunks(markdown, maxSpeechChunk)
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("lesson %s has no content to narrate", lessonID)
+	}
+
+	var data []byte
+	contentType := "audio/mpeg"
+	for _, chunk := range chunks {
+		clip, err := s.provider.Synthesize(ctx, chunk, voice)
+		if err != nil {
+			return nil, fmt.Errorf("failed to synthesize audio: %w", err)
+		}
+		data = append(data, clip.Data...)
+		contentType = clip.ContentType
+	}
+
+	relPath := filepath.Join(lessonID.String(), fmt.Sprintf("v%d-%s.mp3", version, sanitizeFileName(voice)))
+	if err := writeFileAtomic(filepath.Join(s.config.AudioStorageDir, relPath), data); err != nil {
+		return nil, fmt.Errorf("failed to store audio: %w", err)
+	}
+
+	audio, err := scanLessonAudio(s.db.QueryRow(`
+		INSERT INTO lesson_audio (lesson_id, content_version, voice, provider, storage_path, content_type, size_bytes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (lesson_id, content_version, voice) DO UPDATE
+		SET provider = EXCLUDED.provider, storage_path = EXCLUDED.storage_path,
+			content_type = EXCLUDED.content_type, size_bytes = EXCLUDED.size_bytes, created_at = NOW()
+		RETURNING `+lessonAudioColumns,
+		lessonID, version, voice, s.provider.Name(), relPath, contentType, len(data),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record lesson audio: %w", err)
+	}
+
+	log.Printf("Rendered audio for lesson %s (version %d, %d bytes)", lessonID, version, len(data))
+	return audio, nil
+}
+
+// OpenAudio verifies a signed link and returns the cached audio with its absolute file path
+func (s *AudioService) OpenAudio(audioID uuid.UUID, expires int64, signature string) (*models.LessonAudio, string, error) {
+	if !VerifyAudioSignature(s.secret, audioID, expires, signature, s.clock.Now()) {
+		return nil, "", ErrAudioLinkInvalid
+	}
+
+	audio, err := scanLessonAudio(s.db.QueryRow(`
+		SELECT `+lessonAudioColumns+`
+		FROM lesson_audio
+		WHERE id = $1
+	`, audioID))
+	if err == sql.ErrNoRows {
+		return nil, "", ErrAudioNotFound
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query lesson audio: %w", err)
+	}
+
+	return audio, filepath.Join(s.config.AudioStorageDir, audio.StoragePath), nil
+}
+
+func (s *AudioService) sign(audio *models.LessonAudio) {
+	audio.ExpiresAt = s.clock.Now().Add(time.Duration(s.config.AudioURLTTLSeconds) * time.Second)
+	expires := audio.ExpiresAt.Unix()
+	audio.URL = fmt.Sprintf("/ngs/audio/%s?expires=%d&signature=%s",
+		audio.ID, expires, SignAudioURL(s.secret, audio.ID, expires))
+}
+
+// SignAudioURL returns the HMAC-SHA256 signature for an audio link
+func SignAudioURL(secret []byte, audioID uuid.UUID, expires int64) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s:%d", audioID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyAudioSignature checks an audio link signature and that it has not expired
+func VerifyAudioSignature(secret []byte, audioID uuid.UUID, expires int64, signature string, now time.Time) bool {
+	if now.Unix() > expires {
+		return false
+	}
+	expected := SignAudioURL(secret, audioID, expires)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// SpeechChunks converts lesson markdown to narration text and splits it on paragraph
+// boundaries into chunks of at most maxChars (longer paragraphs are split on words)
+func SpeechChunks(markdown string, maxChars int) []string {
+	text := markdownSyntax.ReplaceAllString(markdown, " $1 ")
+
+	var chunks []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+	}
+	add := func(piece, sep string) {
+		if current.Len() > 0 && current.Len()+len(sep)+len(piece) > maxChars {
+			flush()
+		}
+		if current.Len() > 0 {
+			current.WriteString(sep)
+		}
+		current.WriteString(piece)
+	}
+
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		paragraph = strings.TrimSpace(extraWhitespace.ReplaceAllString(strings.ReplaceAll(paragraph, "\n", " "), " "))
+		if paragraph == "" {
+			continue
+		}
+		if len(paragraph) <= maxChars {
+			add(paragraph, "\n\n")
+			continue
+		}
+		flush()
+		for _, word := range strings.Fields(paragraph) {
+			add(word, " ")
+		}
+		flush()
+	}
+	flush()
+
+	return chunks
+}
+
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
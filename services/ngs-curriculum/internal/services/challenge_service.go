@@ -1,122 +1,203 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"unicode/utf8"
 
+	"noble-ngs-curriculum/internal/api"
+	"noble-ngs-curriculum/internal/clients/blobstore"
+	"noble-ngs-curriculum/internal/config"
 	"noble-ngs-curriculum/internal/database"
 	"noble-ngs-curriculum/internal/models"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrChallengeNotFound  = errors.New("challenge not found")
+	ErrSolutionLocked     = errors.New("solutions are revealed after passing this challenge")
+	ErrSubmissionNotFound = errors.New("submission not found")
+	ErrEmptySubmission    = errors.New("submission is empty")
 )
 
 type ChallengeService struct {
-	db *database.DB
+	db      *database.DB
+	config  *config.Config
+	blobs   blobstore.Store
+	sandbox Sandbox
+	// evaluator scores design submissions; nil leaves them to educators
+	evaluator DesignEvaluator
+	// projection applies XP to progress in projection mode; nil updates the total directly
+	projection *ProgressProjection
+	// leaderboards drops cached leaderboards after XP is awarded; nil leaves them to expire
+	leaderboards LeaderboardInvalidator
 }
 
-func NewChallengeService(db *database.DB) *ChallengeService {
+// NewChallengeService creates a ChallengeService. blobs holds large submissions and may be nil,
+// keeping every submission in Postgres.
+func NewChallengeService(db *database.DB, cfg *config.Config, blobs blobstore.Store) *ChallengeService {
 	return &ChallengeService{
-		db: db,
+		db:      db,
+		config:  cfg,
+		blobs:   blobs,
+		sandbox: PlaceholderSandbox{},
 	}
 }
 
-// GetChallengesByLevel retrieves all active challenges for a specific level
-func (s *ChallengeService) GetChallengesByLevel(levelID int) ([]models.Challenge, error) {
+// submissionRow is a submission as stored, with the blob store keys of any offloaded parts
+type submissionRow struct {
+	models.ChallengeSubmission
+	codeRef        sql.NullString
+	testResultsRef sql.NullString
+}
+
+// challengeColumns is the column list scanChallenge reads
+const challengeColumns = `id, lesson_id, level_id, title, description, challenge_type,
+	       difficulty, starter_code, test_cases, solution_template,
+	       xp_reward, time_limit_minutes, tags, metadata, is_active, min_age_band, created_at,
+	       run_time_limit_ms, run_cpu_limit_ms, run_memory_limit_mb, baseline_runtime_ms, baseline_memory_kb`
+
+// scanChallenge reads challengeColumns. tags is a TEXT[] scanned through pq.Array; it and the
+// JSON columns read as nil when NULL. NULL run limits read as 0, the service default.
+func scanChallenge(row rowScanner) (models.Challenge, error) {
+	var c models.Challenge
+	var lessonID sql.NullString
+	var starterCode, solutionTemplate sql.NullString
+	var timeLimitMinutes, runTimeLimit, runCPULimit, runMemoryLimit, baselineMemory sql.NullInt64
+	var baselineRuntime sql.NullFloat64
+	var testCases, metadata []byte
+
+	err := row.Scan(
+		&c.ID, &lessonID, &c.LevelID, &c.Title, &c.Description,
+		&c.ChallengeType, &c.Difficulty, &starterCode, &testCases,
+		&solutionTemplate, &c.XPReward, &timeLimitMinutes, pq.Array(&c.Tags),
+		&metadata, &c.IsActive, &c.MinAgeBand, &c.CreatedAt,
+		&runTimeLimit, &runCPULimit, &runMemoryLimit, &baselineRuntime, &baselineMemory,
+	)
+	if err != nil {
+		return c, err
+	}
+	c.TestCases, c.Metadata = testCases, metadata
+
+	if lessonID.Valid {
+		c.LessonID, _ = uuid.Parse(lessonID.String)
+	}
+	if starterCode.Valid {
+		c.StarterCode = starterCode.String
+	}
+	if solutionTemplate.Valid {
+		c.SolutionTemplate = solutionTemplate.String
+	}
+	if timeLimitMinutes.Valid {
+		c.TimeLimitMinutes = int(timeLimitMinutes.Int64)
+	}
+	c.Limits = models.ResourceLimits{
+		TimeLimitMs:   int(runTimeLimit.Int64),
+		CPULimitMs:    int(runCPULimit.Int64),
+		MemoryLimitMB: int(runMemoryLimit.Int64),
+	}
+	c.BaselineRuntimeMs, c.BaselineMemoryKB = baselineRuntime.Float64, baselineMemory.Int64
+	return c, nil
+}
+
+// NormalizeChallengeTags trims tags, dropping empty and repeated ones. Tags match case-sensitively.
+func NormalizeChallengeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// GetChallengesByLevel retrieves all active challenges for a specific level suitable for the user.
+// When tags are given, only challenges carrying every one of them are returned.
+func (s *ChallengeService) GetChallengesByLevel(levelID int, userID uuid.UUID, tags []string) ([]models.Challenge, error) {
+	// A non-nil slice binds as an empty array rather than NULL, which the tag filter treats as no filter
+	tags = append([]string{}, NormalizeChallengeTags(tags)...)
 	rows, err := s.db.Query(`
-		SELECT id, lesson_id, level_id, title, description, challenge_type,
-		       difficulty, starter_code, test_cases, solution_template,
-		       xp_reward, time_limit_minutes, tags, metadata, is_active, created_at
+		SELECT `+challengeColumns+`
 		FROM challenges
-		WHERE level_id = $1 AND is_active = true
-		ORDER BY difficulty, title
-	`, levelID)
+		WHERE level_id = $1 AND is_active = true AND `+contentAgeFilterSQL("min_age_band", "$2")+`
+		  AND (cardinality($3::TEXT[]) = 0 OR tags @> $3::TEXT[])
+		ORDER BY difficulty, title, id
+	`, levelID, userID, pq.Array(tags))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query challenges: %w", err)
 	}
 	defer rows.Close()
 
-	var challenges []models.Challenge
+	challenges := []models.Challenge{}
 	for rows.Next() {
-		var c models.Challenge
-		var lessonID sql.NullString
-		var starterCode, solutionTemplate sql.NullString
-		var timeLimitMinutes sql.NullInt64
-
-		err := rows.Scan(
-			&c.ID, &lessonID, &c.LevelID, &c.Title, &c.Description,
-			&c.ChallengeType, &c.Difficulty, &starterCode, &c.TestCases,
-			&solutionTemplate, &c.XPReward, &timeLimitMinutes, &c.Tags,
-			&c.Metadata, &c.IsActive, &c.CreatedAt,
-		)
+		c, err := scanChallenge(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan challenge: %w", err)
 		}
-
-		if lessonID.Valid {
-			c.LessonID, _ = uuid.Parse(lessonID.String)
-		}
-		if starterCode.Valid {
-			c.StarterCode = starterCode.String
-		}
-		if solutionTemplate.Valid {
-			c.SolutionTemplate = solutionTemplate.String
-		}
-		if timeLimitMinutes.Valid {
-			c.TimeLimitMinutes = int(timeLimitMinutes.Int64)
-		}
-
+		c.Limits = s.resolveLimits(c.Limits)
 		challenges = append(challenges, c)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read challenges: %w", err)
+	}
 
 	return challenges, nil
 }
 
 // GetChallenge retrieves a specific challenge by ID
 func (s *ChallengeService) GetChallenge(challengeID uuid.UUID) (*models.Challenge, error) {
-	var c models.Challenge
-	var lessonID sql.NullString
-	var starterCode, solutionTemplate sql.NullString
-	var timeLimitMinutes sql.NullInt64
-
-	err := s.db.QueryRow(`
-		SELECT id, lesson_id, level_id, title, description, challenge_type,
-		       difficulty, starter_code, test_cases, solution_template,
-		       xp_reward, time_limit_minutes, tags, metadata, is_active, created_at
+	c, err := scanChallenge(s.db.QueryRow(`
+		SELECT `+challengeColumns+`
 		FROM challenges
 		WHERE id = $1
-	`, challengeID).Scan(
-		&c.ID, &lessonID, &c.LevelID, &c.Title, &c.Description,
-		&c.ChallengeType, &c.Difficulty, &starterCode, &c.TestCases,
-		&solutionTemplate, &c.XPReward, &timeLimitMinutes, &c.Tags,
-		&c.Metadata, &c.IsActive, &c.CreatedAt,
-	)
+	`, challengeID))
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("challenge not found")
+		return nil, ErrChallengeNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to query challenge: %w", err)
 	}
+	c.Limits = s.resolveLimits(c.Limits)
 
-	if lessonID.Valid {
-		c.LessonID, _ = uuid.Parse(lessonID.String)
-	}
-	if starterCode.Valid {
-		c.StarterCode = starterCode.String
-	}
-	if solutionTemplate.Valid {
-		c.SolutionTemplate = solutionTemplate.String
+	return &c, nil
+}
+
+// ChallengeAllowedFor reports whether a challenge's min_age_band is suitable for the user
+func (s *ChallengeService) ChallengeAllowedFor(challengeID uuid.UUID, userID uuid.UUID) (bool, error) {
+	var allowed bool
+	err := s.db.QueryRow(`
+		SELECT `+contentAgeFilterSQL("min_age_band", "$2")+`
+		FROM challenges
+		WHERE id = $1
+	`, challengeID, userID).Scan(&allowed)
+	if err == sql.ErrNoRows {
+		return false, nil
 	}
-	if timeLimitMinutes.Valid {
-		c.TimeLimitMinutes = int(timeLimitMinutes.Int64)
+	if err != nil {
+		return false, fmt.Errorf("failed to check challenge age band: %w", err)
 	}
-
-	return &c, nil
+	return allowed, nil
 }
 
-// SubmitChallenge processes a challenge submission and awards XP if successful
+// SubmitChallenge processes a challenge submission and awards XP if successful. Code over
+// SUBMISSION_MAX_CODE_BYTES is rejected with ErrSubmissionTooLarge; code and test results over
+// SUBMISSION_OFFLOAD_BYTES are written to the blob store and referenced from the row.
 func (s *ChallengeService) SubmitChallenge(userID uuid.UUID, req models.SubmitChallengeRequest) (*models.ChallengeSubmission, error) {
+	if max := s.config.SubmissionMaxCodeBytes; max > 0 && len(req.SubmissionCode) > max {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrSubmissionTooLarge, len(req.SubmissionCode), max)
+	}
+
 	// Start transaction
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -125,185 +206,394 @@ func (s *ChallengeService) SubmitChallenge(userID uuid.UUID, req models.SubmitCh
 	defer tx.Rollback()
 
 	// Get challenge details
-	var challenge models.Challenge
-	err = tx.QueryRow(`
-		SELECT id, title, xp_reward, test_cases, challenge_type
+	challenge, err := scanChallenge(tx.QueryRow(`
+		SELECT `+challengeColumns+`
 		FROM challenges
-		WHERE id = $1 AND is_active = true
-	`, req.ChallengeID).Scan(
-		&challenge.ID, &challenge.Title, &challenge.XPReward,
-		&challenge.TestCases, &challenge.ChallengeType,
-	)
+		WHERE id = $1 AND is_active = true AND `+contentAgeFilterSQL("min_age_band", "$2")+`
+	`, req.ChallengeID, userID))
 	if err != nil {
 		return nil, fmt.Errorf("challenge not found: %w", err)
 	}
+	challenge.Limits = s.resolveLimits(challenge.Limits)
+	switch challenge.ChallengeType {
+	case ChallengeTypeDesign:
+		return nil, ErrDesignSubmissionRequired
+	case ChallengeTypeCollaboration:
+		return nil, ErrCollaborationRequired
+	case ChallengeTypeReflection:
+		text := req.ReflectionText
+		if text == "" {
+			text = req.SubmissionCode
+		}
+		return s.submitReflection(tx, userID, &challenge, text)
+	}
+	if req.SubmissionCode == "" {
+		return nil, fmt.Errorf("%w: submission_code is required", ErrEmptySubmission)
+	}
 
-	// Validate submission (simplified - in production would execute code in sandbox)
-	testResults, passed, score := s.validateSubmission(req.SubmissionCode, challenge.TestCases)
+	// Run the submission against the challenge's test cases
+	testResults, passed, score := s.validateSubmission(req.SubmissionCode, &challenge)
 
 	// Generate feedback
-	feedback := s.generateFeedback(passed, score, challenge.ChallengeType)
+	feedback := s.generateFeedback(passed, score, challenge.ChallengeType, testResults)
 
-	// Create submission record
+	// Create submission record, offloading large parts first so the row can reference them
 	testResultsJSON, _ := json.Marshal(testResults)
-	var submission models.ChallengeSubmission
+	submissionID := uuid.New()
+	ctx := context.Background()
+	codeRef := s.offloadSubmissionBlob(ctx, "code", submissionBlobKey(userID, submissionID, "code.txt"),
+		[]byte(req.SubmissionCode), "text/plain; charset=utf-8")
+	testResultsRef := s.offloadSubmissionBlob(ctx, "test_results", submissionBlobKey(userID, submissionID, "test_results.json"),
+		testResultsJSON, "application/json")
+	committed := false
+	defer func() {
+		if !committed {
+			s.deleteSubmissionBlobs(ctx, codeRef, testResultsRef)
+		}
+	}()
+
+	inlineCode, inlineResults := req.SubmissionCode, testResultsJSON
+	if codeRef != "" {
+		inlineCode = ""
+	}
+	if testResultsRef != "" {
+		inlineResults = nil
+	}
 
+	submission := models.ChallengeSubmission{
+		SubmissionCode: req.SubmissionCode,
+		TestResults:    RedactHiddenTests(testResults),
+		Status:         models.ReviewGraded,
+	}
+	// Usage is only kept for runs that executed, so failed runs stay off the runtime leaderboard
+	var runtimeMs sql.NullFloat64
+	var memoryKB sql.NullInt64
+	if testResults.Error == "" && testResults.TotalTests > 0 {
+		submission.RuntimeMs, submission.MemoryKB = measureUsage(testResults)
+		runtimeMs = sql.NullFloat64{Float64: submission.RuntimeMs, Valid: true}
+		memoryKB = sql.NullInt64{Int64: submission.MemoryKB, Valid: true}
+	}
+	var timeTaken sql.NullInt64
 	err = tx.QueryRow(`
-		INSERT INTO challenge_submissions (user_id, challenge_id, submission_code, test_results, passed, score, feedback)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, user_id, challenge_id, submission_code, test_results, passed, score, feedback, time_taken_seconds, submitted_at
-	`, userID, req.ChallengeID, req.SubmissionCode, testResultsJSON, passed, score, feedback).Scan(
+		INSERT INTO challenge_submissions (
+			id, user_id, challenge_id, submission_code, test_results, passed, score, feedback,
+			code_ref, test_results_ref, code_length, runtime_ms, memory_kb
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), NULLIF($10, ''), $11, $12, $13)
+		RETURNING id, user_id, challenge_id, passed, score, feedback, time_taken_seconds, COALESCE(is_shared, false), submitted_at
+	`, submissionID, userID, req.ChallengeID, inlineCode, inlineResults, passed, score, feedback,
+		codeRef, testResultsRef, utf8.RuneCountInString(req.SubmissionCode), runtimeMs, memoryKB).Scan(
 		&submission.ID, &submission.UserID, &submission.ChallengeID,
-		&submission.SubmissionCode, &submission.TestResults, &submission.Passed,
-		&submission.Score, &submission.Feedback, &submission.TimeTakenSeconds,
-		&submission.SubmittedAt,
+		&submission.Passed, &submission.Score, &submission.Feedback, &timeTaken,
+		&submission.IsShared, &submission.SubmittedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create submission: %w", err)
 	}
+	submission.TimeTakenSeconds = int(timeTaken.Int64)
 
 	// Award XP if passed
 	if passed {
-		xpToAward := challenge.XPReward
-		if score >= 100 {
-			xpToAward = challenge.XPReward // Full XP for perfect solution
-		} else if score >= 80 {
-			xpToAward = int(float64(challenge.XPReward) * 0.8) // 80% XP
-		} else if score >= 60 {
-			xpToAward = int(float64(challenge.XPReward) * 0.6) // 60% XP
+		if err := s.awardChallengeXP(tx, userID, &challenge, score); err != nil {
+			return nil, err
 		}
+	}
 
-		metadata := map[string]interface{}{
-			"challenge_id":    challenge.ID.String(),
-			"challenge_title": challenge.Title,
-			"score":           score,
-			"passed":          passed,
-		}
-		metadataJSON, _ := json.Marshal(metadata)
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	committed = true
+	if passed {
+		invalidateLeaderboards(s.leaderboards)
+	}
 
-		_, err = tx.Exec(`
-			INSERT INTO xp_events (user_id, source, xp_awarded, metadata)
-			VALUES ($1, $2, $3, $4)
-		`, userID, "challenge_solved", xpToAward, metadataJSON)
-		if err != nil {
-			return nil, fmt.Errorf("failed to award XP: %w", err)
-		}
+	return &submission, nil
+}
+
+// awardChallengeXP awards the XP for a passing submission, scaled down for lower scores
+func (s *ChallengeService) awardChallengeXP(tx *sql.Tx, userID uuid.UUID, challenge *models.Challenge, score int) error {
+	xpToAward := challenge.XPReward
+	if score >= 100 {
+		xpToAward = challenge.XPReward // Full XP for perfect solution
+	} else if score >= 80 {
+		xpToAward = int(float64(challenge.XPReward) * 0.8) // 80% XP
+	} else if score >= 60 {
+		xpToAward = int(float64(challenge.XPReward) * 0.6) // 60% XP
+	} else {
+		xpToAward = int(float64(challenge.XPReward) * 0.4) // Correct but far off an optimization baseline
+	}
+
+	return s.recordChallengeXP(tx, userID, challenge, score, xpToAward)
+}
 
-		// Update user progress
+// recordChallengeXP records a challenge_solved XP event and adds the XP to the user's progress
+func (s *ChallengeService) recordChallengeXP(tx *sql.Tx, userID uuid.UUID, challenge *models.Challenge, score, xp int) error {
+	metadata := map[string]interface{}{
+		"challenge_id":    challenge.ID.String(),
+		"challenge_title": challenge.Title,
+		"score":           score,
+		"passed":          true,
+	}
+	xp, metadata, err := decayXPInTx(tx, userID, "challenge_solved", xp, metadata)
+	if err != nil {
+		return err
+	}
+	metadataJSON, _ := json.Marshal(metadata)
+
+	if err := recordXPEvent(s.db, tx, userID, "challenge_solved", xp, metadataJSON); err != nil {
+		return fmt.Errorf("failed to award XP: %w", err)
+	}
+
+	// Update user progress
+	if s.projection != nil {
+		err = s.projection.Apply(tx, userID, xp)
+	} else {
 		_, err = tx.Exec(`
 			UPDATE user_progress
 			SET total_xp = total_xp + $1, updated_at = NOW()
 			WHERE user_id = $2
-		`, xpToAward, userID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to update progress: %w", err)
+		`, xp, userID)
+		if err == nil {
+			err = recordDirectMilestones(tx, userID, xp)
 		}
-
-		log.Printf("User %s completed challenge %s (XP: %d, Score: %d)", userID, challenge.Title, xpToAward, score)
 	}
-
-	// Commit transaction
-	if err = tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	if err != nil {
+		return fmt.Errorf("failed to update progress: %w", err)
 	}
 
-	return &submission, nil
+	log.Printf("User %s completed challenge %s (XP: %d, Score: %d)", userID, challenge.Title, xp, score)
+	return nil
 }
 
-// GetUserSubmissions retrieves a user's challenge submission history
-func (s *ChallengeService) GetUserSubmissions(userID uuid.UUID, limit int) ([]models.ChallengeSubmission, error) {
-	if limit <= 0 {
-		limit = 20
+// GetUserSubmissions retrieves a page of a user's challenge submission history, newest first,
+// only passed or failed submissions when passed is given
+func (s *ChallengeService) GetUserSubmissions(userID uuid.UUID, page api.Page, passed *bool) ([]models.ChallengeSubmission, error) {
+	args := []interface{}{userID}
+	var passedSQL string
+	if passed != nil {
+		args = append(args, *passed)
+		passedSQL = " AND passed = $2"
 	}
-
+	where, limit, args := pageSQL(page, "submitted_at", "id", args)
 	rows, err := s.db.Query(`
-		SELECT id, user_id, challenge_id, submission_code, test_results,
-		       passed, score, feedback, time_taken_seconds, submitted_at
+		SELECT id, user_id, challenge_id, COALESCE(submission_code, ''), test_results,
+		       passed, score, feedback, time_taken_seconds, COALESCE(is_shared, false), submitted_at,
+		       code_ref, test_results_ref, runtime_ms, memory_kb,
+		       review_status, COALESCE(writeup, ''), assets, evaluation
 		FROM challenge_submissions
-		WHERE user_id = $1
-		ORDER BY submitted_at DESC
-		LIMIT $2
-	`, userID, limit)
+		WHERE user_id = $1`+passedSQL+where+`
+		ORDER BY submitted_at DESC, id
+		`+limit, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query submissions: %w", err)
 	}
 	defer rows.Close()
 
-	var submissions []models.ChallengeSubmission
+	stored := []submissionRow{}
 	for rows.Next() {
-		var s models.ChallengeSubmission
-		var timeTaken sql.NullInt64
+		var row submissionRow
+		var timeTaken, memoryKB sql.NullInt64
+		var runtimeMs sql.NullFloat64
+		var testResults, assets, evaluation []byte
 
 		err := rows.Scan(
-			&s.ID, &s.UserID, &s.ChallengeID, &s.SubmissionCode,
-			&s.TestResults, &s.Passed, &s.Score, &s.Feedback,
-			&timeTaken, &s.SubmittedAt,
+			&row.ID, &row.UserID, &row.ChallengeID, &row.SubmissionCode,
+			&testResults, &row.Passed, &row.Score, &row.Feedback,
+			&timeTaken, &row.IsShared, &row.SubmittedAt,
+			&row.codeRef, &row.testResultsRef, &runtimeMs, &memoryKB,
+			&row.Status, &row.Writeup, &assets, &evaluation,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan submission: %w", err)
 		}
+		row.TestResults = decodeTestResults(testResults)
+		row.Assets, row.Evaluation = decodeDesignAssets(assets), decodeDesignEvaluation(evaluation)
+		row.RuntimeMs, row.MemoryKB = runtimeMs.Float64, memoryKB.Int64
 
 		if timeTaken.Valid {
-			s.TimeTakenSeconds = int(timeTaken.Int64)
+			row.TimeTakenSeconds = int(timeTaken.Int64)
 		}
 
-		submissions = append(submissions, s)
+		stored = append(stored, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read submissions: %w", err)
 	}
+	rows.Close()
 
+	submissions := make([]models.ChallengeSubmission, 0, len(stored))
+	for i := range stored {
+		if err := s.loadSubmissionParts(context.Background(), &stored[i]); err != nil {
+			return nil, err
+		}
+		stored[i].TestResults = RedactHiddenTests(stored[i].TestResults)
+		submissions = append(submissions, stored[i].ChallengeSubmission)
+	}
 	return submissions, nil
 }
 
-// validateSubmission validates a submission against test cases
-// This is a simplified version - in production would use a secure code execution sandbox
-func (s *ChallengeService) validateSubmission(submissionCode string, testCasesJSON json.RawMessage) (map[string]interface{}, bool, int) {
-	// Parse test cases
-	var testCases []map[string]interface{}
-	if err := json.Unmarshal(testCasesJSON, &testCases); err != nil {
-		return map[string]interface{}{
-			"error": "Failed to parse test cases",
-		}, false, 0
+// HasPassedChallenge reports whether the user has any passing submission for a challenge
+func (s *ChallengeService) HasPassedChallenge(userID uuid.UUID, challengeID uuid.UUID) (bool, error) {
+	var passed bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM challenge_submissions
+			WHERE user_id = $1 AND challenge_id = $2 AND passed = true
+		)
+	`, userID, challengeID).Scan(&passed)
+	if err != nil {
+		return false, fmt.Errorf("failed to check challenge pass: %w", err)
+	}
+
+	return passed, nil
+}
+
+// GetSolutions reveals the canonical solution and top community solutions to users who passed.
+// Community solutions are each sharer's best submission, ranked by score then conciseness.
+func (s *ChallengeService) GetSolutions(challengeID uuid.UUID, userID uuid.UUID, limit int) (*models.ChallengeSolutions, error) {
+	if limit <= 0 {
+		limit = 10
 	}
 
-	// For now, this is a placeholder that simulates validation
-	// In production, this would:
-	// 1. Execute code in isolated sandbox
-	// 2. Run against test cases
-	// 3. Capture results, errors, and performance metrics
+	passed, err := s.HasPassedChallenge(userID, challengeID)
+	if err != nil {
+		return nil, err
+	}
+	if !passed {
+		return nil, ErrSolutionLocked
+	}
 
-	results := map[string]interface{}{
-		"total_tests":  len(testCases),
-		"passed_tests": len(testCases), // Simplified: assume all pass for now
-		"failed_tests": 0,
-		"test_details": []map[string]interface{}{},
-		"note":         "Sandbox execution not yet implemented - all tests pass by default",
+	challenge, err := s.GetChallenge(challengeID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, submission_code, score, code_length, submitted_at, code_ref
+		FROM (
+			SELECT DISTINCT ON (user_id)
+			       id, COALESCE(submission_code, '') AS submission_code, COALESCE(score, 0) AS score,
+			       COALESCE(code_length, LENGTH(submission_code), 0) AS code_length, submitted_at, code_ref
+			FROM challenge_submissions
+			WHERE challenge_id = $1 AND passed = true AND is_shared = true
+			ORDER BY user_id, score DESC, COALESCE(code_length, LENGTH(submission_code)) ASC, submitted_at ASC
+		) best
+		ORDER BY score DESC, code_length ASC, submitted_at ASC, id
+		LIMIT $2
+	`, challengeID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query community solutions: %w", err)
 	}
+	defer rows.Close()
 
-	// Simulate test execution
-	passedCount := len(testCases)
-	totalTests := len(testCases)
+	solutions := &models.ChallengeSolutions{
+		ChallengeID:        challengeID,
+		SolutionTemplate:   challenge.SolutionTemplate,
+		CommunitySolutions: []models.CommunitySolution{},
+	}
+	var codeRefs []sql.NullString
+	for rows.Next() {
+		var cs models.CommunitySolution
+		var codeRef sql.NullString
+		if err := rows.Scan(&cs.SubmissionID, &cs.SubmissionCode, &cs.Score, &cs.CodeLength, &cs.SubmittedAt, &codeRef); err != nil {
+			return nil, fmt.Errorf("failed to scan community solution: %w", err)
+		}
+		solutions.CommunitySolutions = append(solutions.CommunitySolutions, cs)
+		codeRefs = append(codeRefs, codeRef)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read community solutions: %w", err)
+	}
+	rows.Close()
 
-	// Calculate score
-	score := 0
-	if totalTests > 0 {
-		score = (passedCount * 100) / totalTests
+	for i, ref := range codeRefs {
+		code, err := s.loadSubmissionBlob(context.Background(), "code", ref)
+		if err != nil {
+			return nil, err
+		}
+		if code != nil {
+			solutions.CommunitySolutions[i].SubmissionCode = string(code)
+		}
 	}
 
+	return solutions, nil
+}
+
+// SetSubmissionShared opts a user's own passing submission in or out of community solutions.
+// Minor accounts cannot opt in.
+func (s *ChallengeService) SetSubmissionShared(userID uuid.UUID, submissionID uuid.UUID, shared bool) error {
+	if shared {
+		var minor bool
+		if err := s.db.QueryRow(`SELECT `+minorUserSQL, userID).Scan(&minor); err != nil {
+			return fmt.Errorf("failed to check age band: %w", err)
+		}
+		if minor {
+			return fmt.Errorf("%w: sharing solutions", ErrRestrictedForMinor)
+		}
+	}
+
+	result, err := s.db.Exec(`
+		UPDATE challenge_submissions
+		SET is_shared = $1
+		WHERE id = $2 AND user_id = $3 AND passed = true
+	`, shared, submissionID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update submission sharing: %w", err)
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrSubmissionNotFound
+	}
+
+	return nil
+}
+
+// validateSubmission runs a submission against the challenge's test cases in the sandbox and
+// grades it
+func (s *ChallengeService) validateSubmission(submissionCode string, challenge *models.Challenge) (*models.TestResults, bool, int) {
+	testCases, err := ParseTestCases(challenge.TestCases)
+	if err != nil {
+		results := &models.TestResults{Error: "Failed to parse test cases"}
+		GradeTestResults(results)
+		return results, false, 0
+	}
+
+	results := s.runSandbox(ChallengeLanguage(challenge, s.config.SandboxLanguage), submissionCode, testCases, challenge.Limits)
+
+	score := GradeTestResults(results)
 	passed := score >= 60 // Pass threshold
 
+	// Optimization challenges need every test to pass, then score on performance
+	if challenge.ChallengeType == ChallengeTypeOptimization {
+		passed = results.Error == "" && results.TotalTests > 0 && results.FailedTests == 0
+		if passed {
+			score = ScorePerformance(results, challenge.BaselineRuntimeMs, challenge.BaselineMemoryKB)
+		}
+	}
+
 	return results, passed, score
 }
 
 // generateFeedback creates feedback based on submission results
-func (s *ChallengeService) generateFeedback(passed bool, score int, challengeType string) string {
+func (s *ChallengeService) generateFeedback(passed bool, score int, challengeType string, results *models.TestResults) string {
+	if passed && results != nil && results.Performance != nil {
+		return performanceFeedback(results.Performance)
+	}
+
+	feedback := "Your solution needs more work. Review the requirements and test cases, then try again."
 	if passed {
 		if score >= 100 {
 			return "Excellent work! Your solution passed all test cases with perfect execution."
 		} else if score >= 80 {
-			return "Great job! Your solution is solid and passes most test cases."
+			feedback = "Great job! Your solution is solid and passes most test cases."
 		} else {
-			return "Good effort! Your solution meets the basic requirements."
+			feedback = "Good effort! Your solution meets the basic requirements."
 		}
 	}
 
-	return "Your solution needs more work. Review the requirements and test cases, then try again."
+	if name := firstFailedTest(results); name != "" {
+		feedback += fmt.Sprintf(" Start with %q; each test's feedback says what went wrong.", name)
+	}
+	return feedback
 }
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	ChatRatingUp   = "up"
+	ChatRatingDown = "down"
+
+	TicketOpen     = "open"
+	TicketClaimed  = "claimed"
+	TicketResolved = "resolved"
+	// TicketStatusAll lists tickets in every status
+	TicketStatusAll = "all"
+
+	// chatCommentMaxChars bounds feedback comments and escalation notes
+	chatCommentMaxChars = 2000
+	// ticketResolutionMaxChars bounds an educator's answer to an escalation
+	ticketResolutionMaxChars = 4000
+)
+
+var (
+	ErrChatMessageNotFound = errors.New("chat message not found")
+	ErrInvalidChatFeedback = errors.New("invalid chat feedback")
+	ErrTicketNotFound      = errors.New("ticket not found")
+	ErrInvalidTicket       = errors.New("invalid ticket")
+	ErrTicketClaimed       = errors.New("ticket is claimed by another educator")
+	ErrTicketResolved      = errors.New("ticket is already resolved")
+)
+
+var (
+	chatFeedback = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_chat_feedback_total",
+			Help: "Tutor chat answers rated by learners, by rating: up or down.",
+		},
+		[]string{"rating"},
+	)
+	educatorTickets = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_educator_tickets_total",
+			Help: "Tutor chat escalations by event: created, claimed or resolved.",
+		},
+		[]string{"event"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(chatFeedback, educatorTickets)
+}
+
+// optionalText trims text, reading blank text as none, and checks it against maxChars
+func optionalText(text *string, field string, maxChars int, invalid error) (*string, error) {
+	if text == nil {
+		return nil, nil
+	}
+	trimmed := strings.TrimSpace(*text)
+	if trimmed == "" {
+		return nil, nil
+	}
+	if utf8.RuneCountInString(trimmed) > maxChars {
+		return nil, fmt.Errorf("%w: %s must be at most %d characters", invalid, field, maxChars)
+	}
+	return &trimmed, nil
+}
+
+// ValidateChatFeedback checks a rating and normalises its comment
+func ValidateChatFeedback(req models.ChatFeedbackRequest) (models.ChatFeedbackRequest, error) {
+	if req.Rating != ChatRatingUp && req.Rating != ChatRatingDown {
+		return req, fmt.Errorf("%w: rating must be up or down", ErrInvalidChatFeedback)
+	}
+	comment, err := optionalText(req.Comment, "comment", chatCommentMaxChars, ErrInvalidChatFeedback)
+	req.Comment = comment
+	return req, err
+}
+
+// ChatFeedbackService keeps tutor chat exchanges so learners can rate them and escalate them to
+// human educators, and runs the educators' ticket queue
+type ChatFeedbackService struct {
+	db *database.DB
+}
+
+func NewChatFeedbackService(db *database.DB) *ChatFeedbackService {
+	return &ChatFeedbackService{db: db}
+}
+
+// RecordChatMessage keeps a tutor chat exchange and returns its ID, which the learner rates or
+// escalates it by
+func (s *ChatFeedbackService) RecordChatMessage(userID, lessonID, sessionID uuid.UUID, message, response string) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := s.db.QueryRow(`
+		INSERT INTO ngs_chat_messages (user_id, lesson_id, session_id, message, response)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, userID, lessonID, sessionID, message, response).Scan(&id)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to record chat message: %w", err)
+	}
+	return id, nil
+}
+
+// SetFeedback rates one of the user's chat answers, replacing any earlier rating
+func (s *ChatFeedbackService) SetFeedback(userID, messageID uuid.UUID, req models.ChatFeedbackRequest) (*models.ChatFeedback, error) {
+	req, err := ValidateChatFeedback(req)
+	if err != nil {
+		return nil, err
+	}
+
+	feedback := models.ChatFeedback{MessageID: messageID}
+	var comment sql.NullString
+	err = s.db.QueryRow(`
+		INSERT INTO ngs_chat_feedback (message_id, user_id, rating, comment)
+		SELECT id, user_id, $3, $4 FROM ngs_chat_messages WHERE id = $1 AND user_id = $2
+		ON CONFLICT (message_id) DO UPDATE
+		SET rating = EXCLUDED.rating, comment = EXCLUDED.comment, updated_at = NOW()
+		RETURNING rating, comment, created_at, updated_at
+	`, messageID, userID, req.Rating, req.Comment).Scan(&feedback.Rating, &comment, &feedback.CreatedAt, &feedback.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrChatMessageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save chat feedback: %w", err)
+	}
+	if comment.Valid {
+		feedback.Comment = &comment.String
+	}
+
+	chatFeedback.WithLabelValues(feedback.Rating).Inc()
+	return &feedback, nil
+}
+
+const ticketColumns = `t.id, t.user_id, m.lesson_id, COALESCE(l.title, ''), t.message_id, m.session_id,
+	m.message, m.response, f.rating, t.note, t.status, t.assigned_to, t.resolution,
+	t.created_at, t.updated_at, t.resolved_at`
+
+const ticketFrom = `ngs_educator_tickets t
+	JOIN ngs_chat_messages m ON m.id = t.message_id
+	LEFT JOIN lessons l ON l.id = m.lesson_id
+	LEFT JOIN ngs_chat_feedback f ON f.message_id = t.message_id`
+
+func scanTicket(row rowScanner) (*models.EducatorTicket, error) {
+	var t models.EducatorTicket
+	var rating, note, resolution sql.NullString
+	var assignedTo uuid.NullUUID
+	var resolvedAt sql.NullTime
+	err := row.Scan(&t.ID, &t.UserID, &t.LessonID, &t.LessonTitle, &t.MessageID, &t.SessionID,
+		&t.Message, &t.Response, &rating, &note, &t.Status, &assignedTo, &resolution,
+		&t.CreatedAt, &t.UpdatedAt, &resolvedAt)
+	if err != nil {
+		return nil, err
+	}
+	if rating.Valid {
+		t.Rating = &rating.String
+	}
+	if note.Valid {
+		t.Note = &note.String
+	}
+	if assignedTo.Valid {
+		t.AssignedTo = &assignedTo.UUID
+	}
+	if resolution.Valid {
+		t.Resolution = &resolution.String
+	}
+	if resolvedAt.Valid {
+		t.ResolvedAt = &resolvedAt.Time
+	}
+	return &t, nil
+}
+
+func (s *ChatFeedbackService) queryTickets(where string, args ...interface{}) ([]models.EducatorTicket, error) {
+	rows, err := s.db.Query(`SELECT `+ticketColumns+` FROM `+ticketFrom+` `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tickets: %w", err)
+	}
+	defer rows.Close()
+
+	tickets := []models.EducatorTicket{}
+	for rows.Next() {
+		t, err := scanTicket(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan ticket: %w", err)
+		}
+		tickets = append(tickets, *t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tickets: %w", err)
+	}
+	return tickets, nil
+}
+
+// GetTicket returns a ticket by ID
+func (s *ChatFeedbackService) GetTicket(ticketID uuid.UUID) (*models.EducatorTicket, error) {
+	t, err := scanTicket(s.db.QueryRow(`SELECT `+ticketColumns+` FROM `+ticketFrom+` WHERE t.id = $1`, ticketID))
+	if err == sql.ErrNoRows {
+		return nil, ErrTicketNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ticket: %w", err)
+	}
+	return t, nil
+}
+
+// Escalate opens a ticket for educators on one of the user's chat answers. An answer is
+// escalated once: escalating it again returns its ticket, and created is false.
+func (s *ChatFeedbackService) Escalate(userID, messageID uuid.UUID, req models.EscalateChatRequest) (ticket *models.EducatorTicket, created bool, err error) {
+	note, err := optionalText(req.Note, "note", chatCommentMaxChars, ErrInvalidTicket)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var ticketID uuid.UUID
+	err = s.db.QueryRow(`
+		INSERT INTO ngs_educator_tickets (message_id, user_id, note)
+		SELECT id, user_id, $3 FROM ngs_chat_messages WHERE id = $1 AND user_id = $2
+		ON CONFLICT (message_id) DO NOTHING
+		RETURNING id
+	`, messageID, userID, note).Scan(&ticketID)
+	if err == sql.ErrNoRows {
+		err = s.db.QueryRow(`SELECT id FROM ngs_educator_tickets WHERE message_id = $1 AND user_id = $2`, messageID, userID).Scan(&ticketID)
+		if err == sql.ErrNoRows {
+			return nil, false, ErrChatMessageNotFound
+		}
+	} else if err == nil {
+		created = true
+		educatorTickets.WithLabelValues("created").Inc()
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to escalate chat message: %w", err)
+	}
+
+	ticket, err = s.GetTicket(ticketID)
+	return ticket, created, err
+}
+
+// ListUserTickets returns the user's escalations, newest first, so they can read the answers
+func (s *ChatFeedbackService) ListUserTickets(userID uuid.UUID, limit int) ([]models.EducatorTicket, error) {
+	return s.queryTickets(`WHERE t.user_id = $1 ORDER BY t.created_at DESC, t.id LIMIT $2`, userID, limit)
+}
+
+// ListTickets returns the educators' queue in a status, or every status with TicketStatusAll.
+// Open and claimed tickets come oldest first, so the longest waiting are answered first; the
+// others newest first. With assignedTo, only tickets claimed or resolved by that educator.
+func (s *ChatFeedbackService) ListTickets(status string, assignedTo uuid.NullUUID, limit int) ([]models.EducatorTicket, error) {
+	order := "t.created_at DESC, t.id"
+	switch status {
+	case TicketOpen, TicketClaimed:
+		order = "t.created_at, t.id"
+	case TicketResolved, TicketStatusAll:
+	default:
+		return nil, fmt.Errorf("%w: status must be open, claimed, resolved or all", ErrInvalidTicket)
+	}
+	return s.queryTickets(`
+		WHERE ($1 = 'all' OR t.status = $1) AND ($2::uuid IS NULL OR t.assigned_to = $2)
+		ORDER BY `+order+` LIMIT $3`, status, assignedTo, limit)
+}
+
+// ticketConflict explains why a claim or resolution matched no ticket
+func (s *ChatFeedbackService) ticketConflict(ticketID uuid.UUID) error {
+	var status string
+	err := s.db.QueryRow(`SELECT status FROM ngs_educator_tickets WHERE id = $1`, ticketID).Scan(&status)
+	switch {
+	case err == sql.ErrNoRows:
+		return ErrTicketNotFound
+	case err != nil:
+		return fmt.Errorf("failed to get ticket: %w", err)
+	case status == TicketResolved:
+		return ErrTicketResolved
+	}
+	return ErrTicketClaimed
+}
+
+// ClaimTicket assigns an open ticket to an educator. Claiming one's own ticket again changes
+// nothing; a ticket claimed by another educator can only be taken over with override (admins).
+func (s *ChatFeedbackService) ClaimTicket(ticketID, educatorID uuid.UUID, override bool) (*models.EducatorTicket, error) {
+	res, err := s.db.Exec(`
+		UPDATE ngs_educator_tickets
+		SET status = 'claimed', assigned_to = $2,
+		    updated_at = CASE WHEN assigned_to IS DISTINCT FROM $2 THEN NOW() ELSE updated_at END
+		WHERE id = $1 AND (status = 'open' OR (status = 'claimed' AND (assigned_to = $2 OR $3)))
+	`, ticketID, educatorID, override)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim ticket: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, s.ticketConflict(ticketID)
+	}
+	educatorTickets.WithLabelValues("claimed").Inc()
+	return s.GetTicket(ticketID)
+}
+
+// ResolveTicket answers an open ticket, or one the educator claimed, and closes it. With
+// override (admins) a ticket claimed by another educator can be resolved too.
+func (s *ChatFeedbackService) ResolveTicket(ticketID, educatorID uuid.UUID, req models.ResolveTicketRequest, override bool) (*models.EducatorTicket, error) {
+	resolution := strings.TrimSpace(req.Resolution)
+	if resolution == "" {
+		return nil, fmt.Errorf("%w: resolution is required", ErrInvalidTicket)
+	}
+	if utf8.RuneCountInString(resolution) > ticketResolutionMaxChars {
+		return nil, fmt.Errorf("%w: resolution must be at most %d characters", ErrInvalidTicket, ticketResolutionMaxChars)
+	}
+
+	res, err := s.db.Exec(`
+		UPDATE ngs_educator_tickets
+		SET status = 'resolved', assigned_to = $2, resolution = $3, resolved_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND (status = 'open' OR (status = 'claimed' AND (assigned_to = $2 OR $4)))
+	`, ticketID, educatorID, resolution, override)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve ticket: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, s.ticketConflict(ticketID)
+	}
+	educatorTickets.WithLabelValues("resolved").Inc()
+	return s.GetTicket(ticketID)
+}
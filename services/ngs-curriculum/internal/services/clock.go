@@ -0,0 +1,18 @@
+package services
+
+import "time"
+
+// Clock tells services the current time. Streaks, deadlines, expiries and retention cutoffs
+// read it, and pass the result to SQL instead of calling NOW(), so tests can fix or advance
+// time. Audit columns such as updated_at still default to NOW().
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the wall clock used in production. It reports UTC, the zone the TIMESTAMP
+// columns are written and read in.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now().UTC()
+}
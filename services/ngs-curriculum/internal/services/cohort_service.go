@@ -0,0 +1,351 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrCohortNotFound    = errors.New("cohort not found")
+	ErrInvalidCohort     = errors.New("cohort name is required")
+	ErrInvalidAgeBand    = errors.New("age band must be child, teen or adult")
+	ErrNotCohortEducator = errors.New("only the cohort's educator can manage it")
+	ErrInvalidCurriculum = errors.New("invalid cohort curriculum")
+)
+
+type CohortService struct {
+	db *database.DB
+}
+
+func NewCohortService(db *database.DB) *CohortService {
+	return &CohortService{
+		db: db,
+	}
+}
+
+const cohortColumns = `c.id, c.name, c.educator_id, c.content_age_band, c.created_at, c.updated_at,
+	(SELECT COUNT(*) FROM cohort_members cm WHERE cm.cohort_id = c.id)`
+
+func scanCohort(row rowScanner) (*models.Cohort, error) {
+	var cohort models.Cohort
+	var band sql.NullString
+	err := row.Scan(
+		&cohort.ID, &cohort.Name, &cohort.EducatorID, &band,
+		&cohort.CreatedAt, &cohort.UpdatedAt, &cohort.MemberCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if band.Valid {
+		cohort.ContentAgeBand = &band.String
+	}
+	return &cohort, nil
+}
+
+// validContentAgeBand accepts nil (no override) or a known age band
+func validContentAgeBand(band *string) error {
+	if band == nil {
+		return nil
+	}
+	if _, ok := ageBandRank[*band]; !ok {
+		return ErrInvalidAgeBand
+	}
+	return nil
+}
+
+// CreateCohort creates a cohort owned by the educator
+func (s *CohortService) CreateCohort(educatorID uuid.UUID, req models.CreateCohortRequest) (*models.Cohort, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, ErrInvalidCohort
+	}
+	if err := validContentAgeBand(req.ContentAgeBand); err != nil {
+		return nil, err
+	}
+
+	var cohortID uuid.UUID
+	err := s.db.QueryRow(`
+		INSERT INTO cohorts (name, educator_id, content_age_band)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, name, educatorID, req.ContentAgeBand).Scan(&cohortID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cohort: %w", err)
+	}
+
+	log.Printf("Educator %s created cohort %s", educatorID, cohortID)
+	return s.GetCohort(cohortID, educatorID)
+}
+
+// GetCohort returns a cohort to its educator
+func (s *CohortService) GetCohort(cohortID uuid.UUID, educatorID uuid.UUID) (*models.Cohort, error) {
+	cohort, err := scanCohort(s.db.QueryRow(`SELECT `+cohortColumns+` FROM cohorts c WHERE c.id = $1`, cohortID))
+	if err == sql.ErrNoRows {
+		return nil, ErrCohortNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cohort: %w", err)
+	}
+	if cohort.EducatorID != educatorID {
+		return nil, ErrNotCohortEducator
+	}
+	return cohort, nil
+}
+
+// AddMember enrolls a learner in the educator's cohort
+func (s *CohortService) AddMember(cohortID uuid.UUID, educatorID uuid.UUID, userID uuid.UUID) (*models.Cohort, error) {
+	if _, err := s.GetCohort(cohortID, educatorID); err != nil {
+		return nil, err
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO cohort_members (cohort_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, cohortID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add cohort member: %w", err)
+	}
+
+	return s.GetCohort(cohortID, educatorID)
+}
+
+// RemoveMember removes a learner from the educator's cohort
+func (s *CohortService) RemoveMember(cohortID uuid.UUID, educatorID uuid.UUID, userID uuid.UUID) (*models.Cohort, error) {
+	if _, err := s.GetCohort(cohortID, educatorID); err != nil {
+		return nil, err
+	}
+
+	_, err := s.db.Exec(`DELETE FROM cohort_members WHERE cohort_id = $1 AND user_id = $2`, cohortID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove cohort member: %w", err)
+	}
+
+	return s.GetCohort(cohortID, educatorID)
+}
+
+// SetContentAgeBand sets the educator override for content shown to cohort members.
+// Members see content up to the override band even when their own band is younger;
+// nil clears the override.
+func (s *CohortService) SetContentAgeBand(cohortID uuid.UUID, educatorID uuid.UUID, band *string) (*models.Cohort, error) {
+	if err := validContentAgeBand(band); err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(`
+		UPDATE cohorts SET content_age_band = $1, updated_at = NOW()
+		WHERE id = $2 AND educator_id = $3
+	`, band, cohortID, educatorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update cohort age band: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		// Distinguish a missing cohort from one owned by another educator
+		if _, err := s.GetCohort(cohortID, educatorID); err != nil {
+			return nil, err
+		}
+	}
+
+	if band != nil {
+		log.Printf("Educator %s set cohort %s content age band to %s", educatorID, cohortID, *band)
+	} else {
+		log.Printf("Educator %s cleared cohort %s content age band", educatorID, cohortID)
+	}
+	return s.GetCohort(cohortID, educatorID)
+}
+
+// lessonTrackSQL maps a lesson_order expression to its LessonTracks key
+func lessonTrackSQL(expr string) string {
+	keys := make([]string, 0, len(LessonTracks))
+	for key := range LessonTracks {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return LessonTracks[keys[i]] < LessonTracks[keys[j]] })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "(CASE %s", expr)
+	for _, key := range keys {
+		fmt.Fprintf(&b, " WHEN %d THEN '%s'", LessonTracks[key], key)
+	}
+	b.WriteString(" END)")
+	return b.String()
+}
+
+// cohortHiddenSQL is true when one of the cohorts of the user bound to userParam hides the
+// optional lessons row qualified by table, either directly or through its track. Overrides on a
+// base lesson also apply to its overlays.
+func cohortHiddenSQL(table, userParam string) string {
+	return fmt.Sprintf(`(NOT COALESCE(%[1]s.is_required, true) AND EXISTS (
+			SELECT 1 FROM cohort_curriculum_overrides cco
+			JOIN cohort_members ccm ON ccm.cohort_id = cco.cohort_id
+			WHERE ccm.user_id = %[2]s AND cco.hidden
+			  AND (cco.lesson_id IN (%[1]s.id, %[1]s.overlay_of) OR cco.track = %[3]s)))`,
+		table, userParam, lessonTrackSQL(table+".lesson_order"))
+}
+
+// cohortLessonOrderSQL is the lesson_order the user bound to userParam sees for the lessons row
+// qualified by table. When several of the user's cohorts reorder the lesson the earliest wins.
+func cohortLessonOrderSQL(table, userParam string) string {
+	return fmt.Sprintf(`COALESCE((
+			SELECT MIN(cco.lesson_order) FROM cohort_curriculum_overrides cco
+			JOIN cohort_members ccm ON ccm.cohort_id = cco.cohort_id
+			WHERE ccm.user_id = %[2]s AND cco.lesson_id IN (%[1]s.id, %[1]s.overlay_of)
+		), %[1]s.lesson_order)`, table, userParam)
+}
+
+// ValidateCohortCurriculum checks a cohort curriculum update before it is saved
+func ValidateCohortCurriculum(req models.SetCohortCurriculumRequest) error {
+	seenLessons := make(map[uuid.UUID]bool, len(req.Lessons))
+	for _, o := range req.Lessons {
+		if o.LessonID == uuid.Nil {
+			return fmt.Errorf("%w: lesson_id is required", ErrInvalidCurriculum)
+		}
+		if seenLessons[o.LessonID] {
+			return fmt.Errorf("%w: lesson %s listed twice", ErrInvalidCurriculum, o.LessonID)
+		}
+		seenLessons[o.LessonID] = true
+		if o.LessonOrder != nil && *o.LessonOrder < 1 {
+			return fmt.Errorf("%w: lesson_order must be positive", ErrInvalidCurriculum)
+		}
+		if o.LessonOrder == nil && !o.Hidden {
+			return fmt.Errorf("%w: lesson %s sets neither lesson_order nor hidden", ErrInvalidCurriculum, o.LessonID)
+		}
+	}
+
+	seenTracks := make(map[string]bool, len(req.HiddenTracks))
+	for _, track := range req.HiddenTracks {
+		if _, ok := LessonTracks[track]; !ok {
+			return fmt.Errorf("%w: unknown track %q", ErrInvalidCurriculum, track)
+		}
+		if seenTracks[track] {
+			return fmt.Errorf("%w: track %q listed twice", ErrInvalidCurriculum, track)
+		}
+		seenTracks[track] = true
+	}
+	return nil
+}
+
+// GetCurriculum returns the cohort's sequencing to its educator
+func (s *CohortService) GetCurriculum(cohortID uuid.UUID, educatorID uuid.UUID) (*models.CohortCurriculum, error) {
+	if _, err := s.GetCohort(cohortID, educatorID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT o.lesson_id, o.track, o.lesson_order, o.hidden, o.updated_at
+		FROM cohort_curriculum_overrides o
+		LEFT JOIN lessons l ON l.id = o.lesson_id
+		WHERE o.cohort_id = $1
+		ORDER BY o.track NULLS FIRST, l.level_id, COALESCE(o.lesson_order, l.lesson_order)
+	`, cohortID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cohort curriculum: %w", err)
+	}
+	defer rows.Close()
+
+	curriculum := &models.CohortCurriculum{
+		CohortID:     cohortID,
+		Lessons:      []models.CohortLessonOverride{},
+		HiddenTracks: []string{},
+	}
+	for rows.Next() {
+		var lessonID uuid.NullUUID
+		var track sql.NullString
+		var order sql.NullInt64
+		var hidden bool
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&lessonID, &track, &order, &hidden, &updatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan cohort curriculum: %w", err)
+		}
+		if updatedAt.Valid && (curriculum.UpdatedAt == nil || updatedAt.Time.After(*curriculum.UpdatedAt)) {
+			curriculum.UpdatedAt = &updatedAt.Time
+		}
+		if track.Valid {
+			if hidden {
+				curriculum.HiddenTracks = append(curriculum.HiddenTracks, track.String)
+			}
+			continue
+		}
+		o := models.CohortLessonOverride{LessonID: lessonID.UUID, Hidden: hidden}
+		if order.Valid {
+			lessonOrder := int(order.Int64)
+			o.LessonOrder = &lessonOrder
+		}
+		curriculum.Lessons = append(curriculum.Lessons, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cohort curriculum: %w", err)
+	}
+
+	return curriculum, nil
+}
+
+// SetCurriculum replaces the cohort's sequencing. Overrides apply when members read lessons;
+// the lessons themselves are not changed.
+func (s *CohortService) SetCurriculum(cohortID uuid.UUID, educatorID uuid.UUID, req models.SetCohortCurriculumRequest) (*models.CohortCurriculum, error) {
+	if err := ValidateCohortCurriculum(req); err != nil {
+		return nil, err
+	}
+	if _, err := s.GetCohort(cohortID, educatorID); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM cohort_curriculum_overrides WHERE cohort_id = $1`, cohortID); err != nil {
+		return nil, fmt.Errorf("failed to clear cohort curriculum: %w", err)
+	}
+
+	for _, o := range req.Lessons {
+		var required bool
+		err := tx.QueryRow(`SELECT COALESCE(is_required, true) FROM lessons WHERE id = $1`, o.LessonID).Scan(&required)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: lesson %s does not exist", ErrInvalidCurriculum, o.LessonID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to query lesson: %w", err)
+		}
+		if o.Hidden && required {
+			return nil, fmt.Errorf("%w: lesson %s is required and cannot be hidden", ErrInvalidCurriculum, o.LessonID)
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO cohort_curriculum_overrides (cohort_id, lesson_id, lesson_order, hidden, updated_by)
+			VALUES ($1, $2, $3, $4, $5)
+		`, cohortID, o.LessonID, o.LessonOrder, o.Hidden, educatorID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save lesson override: %w", err)
+		}
+	}
+
+	for _, track := range req.HiddenTracks {
+		_, err := tx.Exec(`
+			INSERT INTO cohort_curriculum_overrides (cohort_id, track, hidden, updated_by)
+			VALUES ($1, $2, true, $3)
+		`, cohortID, track, educatorID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save track override: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Educator %s set cohort %s curriculum: %d lesson overrides, %d hidden tracks",
+		educatorID, cohortID, len(req.Lessons), len(req.HiddenTracks))
+	return s.GetCurriculum(cohortID, educatorID)
+}
@@ -0,0 +1,635 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ChallengeTypeCollaboration challenges are answered by a group, one role per member
+const ChallengeTypeCollaboration = "collaboration"
+
+const (
+	collaborationFeedback = "Your team filled every role. Well done working together!"
+	// maxCollaborationRoles caps the roles, and so the members, of one collaboration
+	maxCollaborationRoles = 20
+)
+
+var (
+	ErrCollaborationRequired     = errors.New("collaboration challenges are submitted through a collaboration at /ngs/challenges/:id/collaborations")
+	ErrNotCollaborationChallenge = errors.New("challenge does not take collaborations")
+	ErrCollaborationNotFound     = errors.New("collaboration not found")
+	ErrNotCollaborator           = errors.New("user is not a member of this collaboration")
+	ErrCollaborationClosed       = errors.New("collaboration has already been submitted")
+	ErrAlreadyCollaborator       = errors.New("user is already in an open collaboration for this challenge")
+	ErrRoleTaken                 = errors.New("role is already taken")
+	ErrInvalidCollaborationRole  = errors.New("invalid collaboration role")
+	ErrCollaborationIncomplete   = errors.New("collaboration is incomplete")
+)
+
+// defaultCollaborationRoles are used by collaboration challenges whose metadata has no roles
+var defaultCollaborationRoles = []models.CollaborationRole{{Name: "Lead", Weight: 1}, {Name: "Contributor", Weight: 1}}
+
+type CollaborationService struct {
+	db               *database.DB
+	config           *config.Config
+	challengeService *ChallengeService
+	clock            Clock
+}
+
+func NewCollaborationService(db *database.DB, cfg *config.Config, challengeService *ChallengeService, clock Clock) *CollaborationService {
+	return &CollaborationService{
+		db:               db,
+		config:           cfg,
+		challengeService: challengeService,
+		clock:            clock,
+	}
+}
+
+// CollaborationRoles returns a collaboration challenge's metadata.roles, or the default Lead and
+// Contributor roles when it has none. Unnamed and repeated roles are skipped and weights below 1
+// count as 1.
+func CollaborationRoles(challenge *models.Challenge) []models.CollaborationRole {
+	var metadata struct {
+		Roles []models.CollaborationRole `json:"roles"`
+	}
+	if len(challenge.Metadata) > 0 {
+		if err := json.Unmarshal(challenge.Metadata, &metadata); err != nil {
+			log.Printf("Ignoring unreadable roles of challenge %s: %v", challenge.ID, err)
+		}
+	}
+
+	var roles []models.CollaborationRole
+	seen := make(map[string]bool)
+	for _, role := range metadata.Roles {
+		role.Name = strings.TrimSpace(role.Name)
+		key := strings.ToLower(role.Name)
+		if role.Name == "" || seen[key] || len(roles) == maxCollaborationRoles {
+			continue
+		}
+		seen[key] = true
+		if role.Weight < 1 {
+			role.Weight = 1
+		}
+		roles = append(roles, role)
+	}
+	if len(roles) == 0 {
+		return defaultCollaborationRoles
+	}
+	return roles
+}
+
+// MissingCollaborationRoles lists, in role order, the roles without a member or whose member's
+// section is empty or shorter than the role's min_chars
+func MissingCollaborationRoles(roles []models.CollaborationRole, members []models.CollaborationMember) []string {
+	sections := make(map[string]string, len(members))
+	for _, member := range members {
+		sections[member.Role] = member.Section
+	}
+
+	missing := []string{}
+	for _, role := range roles {
+		section, ok := sections[role.Name]
+		length := utf8.RuneCountInString(strings.TrimSpace(section))
+		if !ok || length == 0 || length < role.MinChars {
+			missing = append(missing, role.Name)
+		}
+	}
+	return missing
+}
+
+// SplitCollaborationXP splits a challenge's XP reward between members in proportion to their
+// roles' weights, rounding down
+func SplitCollaborationXP(reward int, roles []models.CollaborationRole, members []models.CollaborationMember) map[uuid.UUID]int {
+	weights := make(map[string]int, len(roles))
+	for _, role := range roles {
+		weights[role.Name] = max(role.Weight, 1)
+	}
+
+	total := 0
+	for _, member := range members {
+		total += weights[member.Role]
+	}
+
+	split := make(map[uuid.UUID]int, len(members))
+	for _, member := range members {
+		if total > 0 {
+			split[member.UserID] = reward * weights[member.Role] / total
+		}
+	}
+	return split
+}
+
+// findRole matches a requested role name case-insensitively against the challenge's roles
+func findRole(roles []models.CollaborationRole, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	for _, role := range roles {
+		if strings.EqualFold(role.Name, name) {
+			return role.Name, nil
+		}
+	}
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+	return "", fmt.Errorf("%w: role must be one of %s", ErrInvalidCollaborationRole, strings.Join(names, ", "))
+}
+
+// collaborationChallenge loads an active collaboration challenge suitable for the user
+func (s *CollaborationService) collaborationChallenge(challengeID, userID uuid.UUID) (*models.Challenge, error) {
+	challenge, err := scanChallenge(s.db.QueryRow(`
+		SELECT `+challengeColumns+`
+		FROM challenges
+		WHERE id = $1 AND is_active = true AND `+contentAgeFilterSQL("min_age_band", "$2")+`
+	`, challengeID, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrChallengeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query challenge: %w", err)
+	}
+	if challenge.ChallengeType != ChallengeTypeCollaboration {
+		return nil, ErrNotCollaborationChallenge
+	}
+	return &challenge, nil
+}
+
+// checkNotCollaborating fails when the user is already in an open collaboration for the challenge
+func checkNotCollaborating(tx *sql.Tx, challengeID, userID uuid.UUID) error {
+	var collaborating bool
+	err := tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1
+			FROM challenge_collaboration_members m
+			JOIN challenge_collaborations c ON c.id = m.collaboration_id
+			WHERE c.challenge_id = $1 AND c.status = $2 AND m.user_id = $3
+		)
+	`, challengeID, models.CollaborationOpen, userID).Scan(&collaborating)
+	if err != nil {
+		return fmt.Errorf("failed to check open collaborations: %w", err)
+	}
+	if collaborating {
+		return ErrAlreadyCollaborator
+	}
+	return nil
+}
+
+// CreateCollaboration opens a collaboration on a challenge with the user in the given role
+func (s *CollaborationService) CreateCollaboration(challengeID, userID uuid.UUID, role string) (*models.Collaboration, error) {
+	challenge, err := s.collaborationChallenge(challengeID, userID)
+	if err != nil {
+		return nil, err
+	}
+	role, err = findRole(CollaborationRoles(challenge), role)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkNotCollaborating(tx, challengeID, userID); err != nil {
+		return nil, err
+	}
+
+	collaborationID := uuid.New()
+	now := s.clock.Now()
+	_, err = tx.Exec(`
+		INSERT INTO challenge_collaborations (id, challenge_id, created_by, status, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, collaborationID, challengeID, userID, models.CollaborationOpen, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create collaboration: %w", err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO challenge_collaboration_members (collaboration_id, user_id, role, joined_at)
+		VALUES ($1, $2, $3, $4)
+	`, collaborationID, userID, role, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add collaboration member: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return s.GetCollaboration(collaborationID, userID)
+}
+
+// ListOpenCollaborations lists a challenge's open collaborations, newest first, so learners can
+// find one with a free role. Sections are left out.
+func (s *CollaborationService) ListOpenCollaborations(challengeID, userID uuid.UUID, limit int) ([]models.Collaboration, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	challenge, err := s.collaborationChallenge(challengeID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id
+		FROM challenge_collaborations
+		WHERE challenge_id = $1 AND status = $2
+		ORDER BY created_at DESC, id
+		LIMIT $3
+	`, challengeID, models.CollaborationOpen, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query collaborations: %w", err)
+	}
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan collaboration: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("failed to read collaborations: %w", err)
+	}
+	rows.Close()
+
+	collaborations := []models.Collaboration{}
+	for _, id := range ids {
+		collaboration, err := s.loadCollaboration(s.db, id, challenge, false)
+		if err == ErrCollaborationNotFound {
+			continue // submitted or emptied since it was listed
+		}
+		if err != nil {
+			return nil, err
+		}
+		collaborations = append(collaborations, *collaboration)
+	}
+	return collaborations, nil
+}
+
+// GetCollaboration returns a collaboration. Only members see the sections.
+func (s *CollaborationService) GetCollaboration(collaborationID, userID uuid.UUID) (*models.Collaboration, error) {
+	var challengeID uuid.UUID
+	err := s.db.QueryRow(`SELECT challenge_id FROM challenge_collaborations WHERE id = $1`, collaborationID).Scan(&challengeID)
+	if err == sql.ErrNoRows {
+		return nil, ErrCollaborationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query collaboration: %w", err)
+	}
+
+	challenge, err := s.challengeService.GetChallenge(challengeID)
+	if err != nil {
+		return nil, err
+	}
+
+	collaboration, err := s.loadCollaboration(s.db, collaborationID, challenge, true)
+	if err != nil {
+		return nil, err
+	}
+	if !isCollaborator(collaboration, userID) {
+		for i := range collaboration.Members {
+			collaboration.Members[i].Section = ""
+		}
+	}
+	return collaboration, nil
+}
+
+// queryer is satisfied by both *database.DB and *sql.Tx
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// loadCollaboration reads a collaboration and its members, in the order they joined, and works out
+// which roles are still missing. withSections false leaves the sections out.
+func (s *CollaborationService) loadCollaboration(q queryer, collaborationID uuid.UUID, challenge *models.Challenge, withSections bool) (*models.Collaboration, error) {
+	collaboration := models.Collaboration{Roles: CollaborationRoles(challenge), Members: []models.CollaborationMember{}}
+	var submittedBy uuid.NullUUID
+	var submittedAt sql.NullTime
+	err := q.QueryRow(`
+		SELECT id, challenge_id, created_by, status, submitted_by, submitted_at, created_at
+		FROM challenge_collaborations
+		WHERE id = $1
+	`, collaborationID).Scan(
+		&collaboration.ID, &collaboration.ChallengeID, &collaboration.CreatedBy, &collaboration.Status,
+		&submittedBy, &submittedAt, &collaboration.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrCollaborationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query collaboration: %w", err)
+	}
+	if submittedBy.Valid {
+		collaboration.SubmittedBy = &submittedBy.UUID
+	}
+	if submittedAt.Valid {
+		collaboration.SubmittedAt = &submittedAt.Time
+	}
+
+	rows, err := q.Query(`
+		SELECT user_id, role, section, section_updated_at, COALESCE(xp_awarded, 0), joined_at
+		FROM challenge_collaboration_members
+		WHERE collaboration_id = $1
+		ORDER BY joined_at, user_id
+	`, collaborationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query collaboration members: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var member models.CollaborationMember
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&member.UserID, &member.Role, &member.Section, &updatedAt, &member.XPAwarded, &member.JoinedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan collaboration member: %w", err)
+		}
+		if updatedAt.Valid {
+			member.SectionUpdatedAt = &updatedAt.Time
+		}
+		collaboration.Members = append(collaboration.Members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read collaboration members: %w", err)
+	}
+
+	collaboration.MissingRoles = MissingCollaborationRoles(collaboration.Roles, collaboration.Members)
+	if !withSections {
+		for i := range collaboration.Members {
+			collaboration.Members[i].Section = ""
+		}
+	}
+	return &collaboration, nil
+}
+
+// lockOpenCollaboration locks a collaboration row for a change and checks it is still open
+func lockOpenCollaboration(tx *sql.Tx, collaborationID uuid.UUID) (uuid.UUID, error) {
+	var challengeID uuid.UUID
+	var status string
+	err := tx.QueryRow(`
+		SELECT challenge_id, status
+		FROM challenge_collaborations
+		WHERE id = $1
+		FOR UPDATE
+	`, collaborationID).Scan(&challengeID, &status)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, ErrCollaborationNotFound
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to query collaboration: %w", err)
+	}
+	if status != models.CollaborationOpen {
+		return uuid.Nil, ErrCollaborationClosed
+	}
+	return challengeID, nil
+}
+
+// JoinCollaboration adds the user to an open collaboration in a free role
+func (s *CollaborationService) JoinCollaboration(collaborationID, userID uuid.UUID, role string) (*models.Collaboration, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The lock serialises joins, so two users cannot take the same role
+	challengeID, err := lockOpenCollaboration(tx, collaborationID)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := s.collaborationChallenge(challengeID, userID)
+	if err != nil {
+		return nil, err
+	}
+	role, err = findRole(CollaborationRoles(challenge), role)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNotCollaborating(tx, challengeID, userID); err != nil {
+		return nil, err
+	}
+
+	var taken bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM challenge_collaboration_members WHERE collaboration_id = $1 AND role = $2
+		)
+	`, collaborationID, role).Scan(&taken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check role: %w", err)
+	}
+	if taken {
+		return nil, fmt.Errorf("%w: %s", ErrRoleTaken, role)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO challenge_collaboration_members (collaboration_id, user_id, role, joined_at)
+		VALUES ($1, $2, $3, $4)
+	`, collaborationID, userID, role, s.clock.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to add collaboration member: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return s.GetCollaboration(collaborationID, userID)
+}
+
+// UpdateSection replaces the user's section of an open collaboration. Sections are capped at
+// SUBMISSION_MAX_CODE_BYTES.
+func (s *CollaborationService) UpdateSection(collaborationID, userID uuid.UUID, content string) (*models.Collaboration, error) {
+	if max := s.config.SubmissionMaxCodeBytes; max > 0 && len(content) > max {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrSubmissionTooLarge, len(content), max)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := lockOpenCollaboration(tx, collaborationID); err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE challenge_collaboration_members
+		SET section = $1, section_updated_at = $2
+		WHERE collaboration_id = $3 AND user_id = $4
+	`, content, s.clock.Now(), collaborationID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update section: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return nil, ErrNotCollaborator
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return s.GetCollaboration(collaborationID, userID)
+}
+
+// LeaveCollaboration removes the user from an open collaboration, freeing their role. The
+// collaboration is deleted when its last member leaves.
+func (s *CollaborationService) LeaveCollaboration(collaborationID, userID uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := lockOpenCollaboration(tx, collaborationID); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`
+		DELETE FROM challenge_collaboration_members
+		WHERE collaboration_id = $1 AND user_id = $2
+	`, collaborationID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to leave collaboration: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotCollaborator
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM challenge_collaborations c
+		WHERE c.id = $1
+		  AND NOT EXISTS (SELECT 1 FROM challenge_collaboration_members m WHERE m.collaboration_id = c.id)
+	`, collaborationID)
+	if err != nil {
+		return fmt.Errorf("failed to delete empty collaboration: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// SubmitCollaboration submits a collaboration once every role has a member with a complete
+// section. Each member gets a passing submission holding the assembled sections and their share
+// of the challenge XP.
+func (s *CollaborationService) SubmitCollaboration(collaborationID, userID uuid.UUID) (*models.Collaboration, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The lock keeps section edits and a second submit out until this one commits
+	challengeID, err := lockOpenCollaboration(tx, collaborationID)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := s.challengeService.GetChallenge(challengeID)
+	if err != nil {
+		return nil, err
+	}
+
+	collaboration, err := s.loadCollaboration(tx, collaborationID, challenge, true)
+	if err != nil {
+		return nil, err
+	}
+	if !isCollaborator(collaboration, userID) {
+		return nil, ErrNotCollaborator
+	}
+	if len(collaboration.MissingRoles) > 0 {
+		return nil, fmt.Errorf("%w: missing %s", ErrCollaborationIncomplete, strings.Join(collaboration.MissingRoles, ", "))
+	}
+
+	document := assembleCollaboration(collaboration)
+	split := SplitCollaborationXP(challenge.XPReward, collaboration.Roles, collaboration.Members)
+	for i, member := range collaboration.Members {
+		_, err = tx.Exec(`
+			INSERT INTO challenge_submissions (
+				user_id, challenge_id, submission_code, passed, score, feedback,
+				code_length, review_status, writeup, collaboration_id
+			)
+			VALUES ($1, $2, '', true, 100, $3, $4, $5, $6, $7)
+		`, member.UserID, challengeID, collaborationFeedback, utf8.RuneCountInString(document),
+			models.ReviewGraded, document, collaborationID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create submission: %w", err)
+		}
+
+		xp := split[member.UserID]
+		if err := s.challengeService.recordChallengeXP(tx, member.UserID, challenge, 100, xp); err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(`
+			UPDATE challenge_collaboration_members
+			SET xp_awarded = $1
+			WHERE collaboration_id = $2 AND user_id = $3
+		`, xp, collaborationID, member.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record member XP: %w", err)
+		}
+		collaboration.Members[i].XPAwarded = xp
+	}
+
+	now := s.clock.Now()
+	_, err = tx.Exec(`
+		UPDATE challenge_collaborations
+		SET status = $1, submitted_by = $2, submitted_at = $3
+		WHERE id = $4
+	`, models.CollaborationSubmitted, userID, now, collaborationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit collaboration: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	invalidateLeaderboards(s.challengeService.leaderboards)
+
+	collaboration.Status = models.CollaborationSubmitted
+	collaboration.SubmittedBy = &userID
+	collaboration.SubmittedAt = &now
+	return collaboration, nil
+}
+
+// assembleCollaboration joins the sections into one markdown document, in role order
+func assembleCollaboration(collaboration *models.Collaboration) string {
+	sections := make(map[string]string, len(collaboration.Members))
+	for _, member := range collaboration.Members {
+		sections[member.Role] = strings.TrimSpace(member.Section)
+	}
+
+	var document strings.Builder
+	for i, role := range collaboration.Roles {
+		if i > 0 {
+			document.WriteString("\n\n")
+		}
+		fmt.Fprintf(&document, "## %s\n\n%s", role.Name, sections[role.Name])
+	}
+	return document.String()
+}
+
+func isCollaborator(collaboration *models.Collaboration, userID uuid.UUID) bool {
+	for _, member := range collaboration.Members {
+		if member.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"noble-ngs-curriculum/internal/models"
+)
+
+// CompletionCriteriaError lists the criteria a completion attempt did not meet
+type CompletionCriteriaError struct {
+	Unmet []string
+}
+
+func (e *CompletionCriteriaError) Error() string {
+	return "lesson completion criteria not met: " + strings.Join(e.Unmet, "; ")
+}
+
+// ParseCompletionCriteria decodes a lesson's completion_criteria, rejecting unknown keys
+// and negative thresholds. An empty value means the lesson completes on reading alone.
+func ParseCompletionCriteria(raw json.RawMessage) (models.CompletionCriteria, error) {
+	var criteria models.CompletionCriteria
+	if len(bytes.TrimSpace(raw)) == 0 || string(bytes.TrimSpace(raw)) == "null" {
+		return criteria, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&criteria); err != nil {
+		return criteria, fmt.Errorf("invalid completion criteria: %w", err)
+	}
+
+	if criteria.MinReflectionWords < 0 {
+		return criteria, fmt.Errorf("invalid completion criteria: min_reflection_words must not be negative")
+	}
+	if criteria.MinQuizScore < 0 || criteria.MinQuizScore > 100 {
+		return criteria, fmt.Errorf("invalid completion criteria: min_quiz_score must be between 0 and 100")
+	}
+
+	return criteria, nil
+}
+
+// EvaluateCompletionCriteria returns a description of every criterion the request fails.
+// quizScore is the learner's best graded attempt at the lesson quiz.
+func EvaluateCompletionCriteria(criteria models.CompletionCriteria, req models.CompleteLessonRequest, quizScore int) []string {
+	var unmet []string
+
+	if criteria.MinReflectionWords > 0 {
+		words := len(strings.Fields(req.ReflectionText))
+		if words < criteria.MinReflectionWords {
+			unmet = append(unmet, fmt.Sprintf("reflection must be at least %d words (got %d)", criteria.MinReflectionWords, words))
+		}
+	}
+
+	if criteria.MinQuizScore > 0 && quizScore < criteria.MinQuizScore {
+		unmet = append(unmet, fmt.Sprintf("quiz score must be at least %d (got %d)", criteria.MinQuizScore, quizScore))
+	}
+
+	if criteria.RequiredArtifact != "" {
+		value, ok := req.Metadata[criteria.RequiredArtifact]
+		if !ok || value == nil || strings.TrimSpace(fmt.Sprint(value)) == "" {
+			unmet = append(unmet, fmt.Sprintf("artifact %q is required", criteria.RequiredArtifact))
+		}
+	}
+
+	return unmet
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrConsentNotRequired = errors.New("guardian consent is only needed for minor accounts")
+	ErrConsentNotFound    = errors.New("consent request not found")
+	ErrConsentClosed      = errors.New("consent request is no longer open")
+	ErrInvalidGuardian    = errors.New("a valid guardian email is required")
+)
+
+type ConsentService struct {
+	db     *database.DB
+	config *config.Config
+	clock  Clock
+}
+
+func NewConsentService(db *database.DB, cfg *config.Config, clock Clock) *ConsentService {
+	return &ConsentService{
+		db:     db,
+		config: cfg,
+		clock:  clock,
+	}
+}
+
+const guardianConsentColumns = `id, user_id, guardian_email, status, age_band, requested_at, expires_at, responded_at`
+
+func scanGuardianConsent(row rowScanner) (*models.GuardianConsent, error) {
+	var consent models.GuardianConsent
+	var respondedAt sql.NullTime
+	err := row.Scan(
+		&consent.ID, &consent.UserID, &consent.GuardianEmail, &consent.Status, &consent.AgeBand,
+		&consent.RequestedAt, &consent.ExpiresAt, &respondedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if respondedAt.Valid {
+		consent.RespondedAt = &respondedAt.Time
+	}
+	return &consent, nil
+}
+
+// hashConsentToken returns the stored form of a consent link token
+func hashConsentToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RequestConsent opens a consent request for a minor account and returns it with the one-time
+// consent link. Earlier pending requests are superseded. Delivering the link to the guardian is
+// left to the caller (the auth service owns outbound email).
+func (s *ConsentService) RequestConsent(userID uuid.UUID, guardianEmail string) (*models.GuardianConsent, error) {
+	address, err := mail.ParseAddress(guardianEmail)
+	if err != nil {
+		return nil, ErrInvalidGuardian
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ageBand := AgeBandAdult
+	err = tx.QueryRow(`SELECT age_band FROM user_settings WHERE user_id = $1`, userID).Scan(&ageBand)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get age band: %w", err)
+	}
+	if !IsMinorAgeBand(ageBand) {
+		return nil, ErrConsentNotRequired
+	}
+
+	_, err = tx.Exec(`
+		UPDATE guardian_consents SET status = 'superseded'
+		WHERE user_id = $1 AND status = 'pending'
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to supersede consent requests: %w", err)
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate consent token: %w", err)
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	now := s.clock.Now()
+	consent, err := scanGuardianConsent(tx.QueryRow(`
+		INSERT INTO guardian_consents (user_id, guardian_email, token_hash, age_band, requested_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+guardianConsentColumns,
+		userID, address.Address, hashConsentToken(token), ageBand,
+		now, now.Add(time.Duration(s.config.GuardianConsentTTLHours)*time.Hour),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consent request: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit consent request: %w", err)
+	}
+
+	consent.ConsentURL = s.config.GuardianConsentURL + "?token=" + url.QueryEscape(token)
+	log.Printf("Guardian consent requested for user %s", userID)
+	return consent, nil
+}
+
+// GetConsentStatus returns whether a user needs guardian consent and the latest request
+func (s *ConsentService) GetConsentStatus(userID uuid.UUID) (*models.ConsentStatus, error) {
+	status := &models.ConsentStatus{AgeBand: AgeBandAdult}
+	err := s.db.QueryRow(`SELECT age_band FROM user_settings WHERE user_id = $1`, userID).Scan(&status.AgeBand)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get age band: %w", err)
+	}
+	status.Required = IsMinorAgeBand(status.AgeBand)
+
+	latest, err := scanGuardianConsent(s.db.QueryRow(`
+		SELECT `+guardianConsentColumns+`
+		FROM guardian_consents
+		WHERE user_id = $1 AND status <> 'superseded'
+		ORDER BY requested_at DESC
+		LIMIT 1
+	`, userID))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get consent request: %w", err)
+	}
+	status.Latest = latest
+
+	switch {
+	case !status.Required:
+		status.Status = "not_required"
+	case latest == nil:
+		status.Status = "missing"
+	default:
+		status.Status = latest.Status
+	}
+
+	return status, nil
+}
+
+// GetConsentByToken returns the request behind a consent link for the guardian to review
+func (s *ConsentService) GetConsentByToken(token string) (*models.GuardianConsentView, error) {
+	var view models.GuardianConsentView
+	err := s.db.QueryRow(`
+		SELECT status, age_band, guardian_email, requested_at, expires_at, expires_at < $2
+		FROM guardian_consents
+		WHERE token_hash = $1
+	`, hashConsentToken(token), s.clock.Now()).Scan(
+		&view.Status, &view.AgeBand, &view.GuardianEmail, &view.RequestedAt, &view.ExpiresAt, &view.Expired,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrConsentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get consent request: %w", err)
+	}
+
+	return &view, nil
+}
+
+// RespondConsent records the guardian's decision on a pending, unexpired request
+func (s *ConsentService) RespondConsent(token string, grant bool, responderIP string) (*models.GuardianConsentView, error) {
+	status := "denied"
+	if grant {
+		status = "granted"
+	}
+
+	result, err := s.db.Exec(`
+		UPDATE guardian_consents
+		SET status = $1, responded_at = $4, responder_ip = $2
+		WHERE token_hash = $3 AND status = 'pending' AND expires_at >= $4
+	`, status, responderIP, hashConsentToken(token), s.clock.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to record consent decision: %w", err)
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		if _, err := s.GetConsentByToken(token); err != nil {
+			return nil, err
+		}
+		return nil, ErrConsentClosed
+	}
+
+	return s.GetConsentByToken(token)
+}
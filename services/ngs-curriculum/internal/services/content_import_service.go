@@ -0,0 +1,505 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"noble-ngs-curriculum/internal/clients/connectors"
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidContentImport = errors.New("invalid content import")
+	ErrDraftNotFound        = errors.New("lesson draft not found")
+	ErrDraftIncomplete      = errors.New("lesson draft is missing required fields")
+)
+
+// lessonFieldAliases maps normalized front-matter keys to lesson fields
+var lessonFieldAliases = map[string]string{
+	"title":             "title",
+	"description":       "description",
+	"summary":           "description",
+	"level":             "level_id",
+	"level_id":          "level_id",
+	"order":             "lesson_order",
+	"lesson_order":      "lesson_order",
+	"type":              "lesson_type",
+	"lesson_type":       "lesson_type",
+	"xp":                "xp_reward",
+	"xp_reward":         "xp_reward",
+	"minutes":           "estimated_minutes",
+	"duration":          "estimated_minutes",
+	"estimated_minutes": "estimated_minutes",
+	"required":          "is_required",
+	"is_required":       "is_required",
+	"reflection":        "reflection_prompt",
+	"reflection_prompt": "reflection_prompt",
+	"practice":          "human_practice",
+	"human_practice":    "human_practice",
+	"age_band":          "min_age_band",
+	"min_age_band":      "min_age_band",
+	"external_id":       "external_id",
+	"prerequisites":     "prerequisites",
+	"prereqs":           "prerequisites",
+	"requires":          "prerequisites",
+}
+
+// lessonDraftFieldNames are the valid targets of a field map
+var lessonDraftFieldNames = map[string]bool{
+	"title": true, "description": true, "level_id": true, "lesson_order": true, "lesson_type": true,
+	"xp_reward": true, "estimated_minutes": true, "is_required": true, "reflection_prompt": true,
+	"human_practice": true, "min_age_band": true, "external_id": true, "prerequisites": true,
+}
+
+// normalizeFrontMatterKey matches connectors.ParseFrontMatter key normalization
+func normalizeFrontMatterKey(key string) string {
+	return strings.Join(strings.Fields(strings.ToLower(key)), "_")
+}
+
+// MapLessonFields maps front matter onto lesson fields. fieldMap adds or overrides
+// front-matter key to field mappings on top of the built-in aliases (title, level, order,
+// type, xp, minutes, required, ...). Unknown keys and unusable values are returned as warnings.
+func MapLessonFields(frontMatter map[string]string, fieldMap map[string]string, fallbackTitle string) (models.LessonDraftFields, []string) {
+	var fields models.LessonDraftFields
+	warnings := []string{}
+
+	aliases := make(map[string]string, len(lessonFieldAliases)+len(fieldMap))
+	for key, field := range lessonFieldAliases {
+		aliases[key] = field
+	}
+	for key, field := range fieldMap {
+		aliases[normalizeFrontMatterKey(key)] = field
+	}
+
+	keys := make([]string, 0, len(frontMatter))
+	for key := range frontMatter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	positiveInt := func(key, value string) *int {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			warnings = append(warnings, fmt.Sprintf("%s: %q is not a whole number", key, value))
+			return nil
+		}
+		return &n
+	}
+
+	for _, key := range keys {
+		value := strings.TrimSpace(frontMatter[key])
+		field, ok := aliases[key]
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("ignored front-matter key %q", key))
+			continue
+		}
+		if value == "" {
+			continue
+		}
+
+		switch field {
+		case "title":
+			fields.Title = value
+		case "description":
+			fields.Description = value
+		case "level_id":
+			if n := positiveInt(key, value); n != nil {
+				if *n < 1 || *n > 24 {
+					warnings = append(warnings, fmt.Sprintf("%s: level must be between 1 and 24", key))
+				} else {
+					fields.LevelID = n
+				}
+			}
+		case "lesson_order":
+			fields.LessonOrder = positiveInt(key, value)
+		case "lesson_type":
+			fields.LessonType = strings.ToLower(value)
+		case "xp_reward":
+			fields.XPReward = positiveInt(key, value)
+		case "estimated_minutes":
+			fields.EstimatedMinutes = positiveInt(key, value)
+		case "is_required":
+			b, err := strconv.ParseBool(strings.ToLower(value))
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s: %q is not true or false", key, value))
+			} else {
+				fields.IsRequired = &b
+			}
+		case "reflection_prompt":
+			fields.ReflectionPrompt = value
+		case "human_practice":
+			fields.HumanPractice = value
+		case "min_age_band":
+			if _, ok := ageBandRank[strings.ToLower(value)]; !ok {
+				warnings = append(warnings, fmt.Sprintf("%s: age band must be child, teen or adult", key))
+			} else {
+				fields.MinAgeBand = strings.ToLower(value)
+			}
+		case "external_id":
+			fields.ExternalID = value
+		case "prerequisites":
+			// Accepts "a, b" or a flow list "[a, b]"
+			for _, ref := range strings.Split(strings.Trim(value, "[]"), ",") {
+				ref = strings.Trim(strings.TrimSpace(ref), `"'`)
+				if ref != "" {
+					fields.Prerequisites = append(fields.Prerequisites, ref)
+				}
+			}
+		default:
+			warnings = append(warnings, fmt.Sprintf("%s maps to unknown lesson field %q", key, field))
+		}
+	}
+
+	if fields.Title == "" {
+		fields.Title = strings.TrimSpace(fallbackTitle)
+	}
+
+	return fields, warnings
+}
+
+type ContentImportService struct {
+	db     *database.DB
+	config *config.Config
+}
+
+func NewContentImportService(db *database.DB, cfg *config.Config) *ContentImportService {
+	return &ContentImportService{
+		db:     db,
+		config: cfg,
+	}
+}
+
+// Connector builds the connector for an admin API request. Local paths must be relative to
+// CONTENT_IMPORT_ROOT, and are disabled when it is unset.
+func (s *ContentImportService) Connector(req models.ContentImportRequest) (connectors.Connector, error) {
+	opts := connectors.Options{
+		ArchiveURL:  req.ArchiveURL,
+		Subdir:      req.Subdir,
+		DocumentIDs: req.DocumentIDs,
+		AccessToken: s.config.GoogleDocsAccessToken,
+	}
+
+	if req.Path != "" {
+		if s.config.ContentImportRoot == "" {
+			return nil, fmt.Errorf("%w: local paths are disabled (CONTENT_IMPORT_ROOT is not set)", ErrInvalidContentImport)
+		}
+		root, err := filepath.Abs(s.config.ContentImportRoot)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve import root: %w", err)
+		}
+		path := filepath.Join(root, filepath.Clean("/"+req.Path))
+		if path != root && !strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%w: path must be inside the import root", ErrInvalidContentImport)
+		}
+		opts.Path = path
+	}
+
+	conn, err := connectors.New(req.Source, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidContentImport, err)
+	}
+	return conn, nil
+}
+
+// ImportContent fetches documents from a connector and saves each as a lesson draft. Drafts
+// are keyed by source and document ref; unchanged documents are left alone, and changed ones
+// return to draft status even if they were published.
+func (s *ContentImportService) ImportContent(ctx context.Context, conn connectors.Connector, fieldMap map[string]string) (*models.ContentImportReport, error) {
+	for key, field := range fieldMap {
+		if !lessonDraftFieldNames[field] {
+			return nil, fmt.Errorf("%w: field_map.%s targets unknown lesson field %q", ErrInvalidContentImport, key, field)
+		}
+	}
+
+	docs, err := conn.Fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s content: %w", conn.Name(), err)
+	}
+
+	report := &models.ContentImportReport{
+		Source:    conn.Name(),
+		Documents: len(docs),
+		Errors:    []models.ContentImportError{},
+		DraftIDs:  []uuid.UUID{},
+	}
+
+	for _, doc := range docs {
+		frontMatter, body := connectors.ParseFrontMatter(doc.Content)
+		fields, warnings := MapLessonFields(frontMatter, fieldMap, doc.Title)
+
+		id, outcome, err := s.saveDraft(conn.Name(), doc.Ref, fields, frontMatter, body, warnings)
+		if err != nil {
+			report.Failed++
+			report.Errors = append(report.Errors, models.ContentImportError{Ref: doc.Ref, Error: err.Error()})
+			continue
+		}
+		switch outcome {
+		case "created":
+			report.Created++
+		case "updated":
+			report.Updated++
+		default:
+			report.Unchanged++
+		}
+		report.DraftIDs = append(report.DraftIDs, id)
+	}
+
+	log.Printf("Content import from %s: %d documents, %d created, %d updated, %d unchanged, %d failed",
+		conn.Name(), report.Documents, report.Created, report.Updated, report.Unchanged, report.Failed)
+	return report, nil
+}
+
+// saveDraft upserts one draft and reports whether it was created, updated or unchanged
+func (s *ContentImportService) saveDraft(source, ref string, fields models.LessonDraftFields, frontMatter map[string]string, body string, warnings []string) (uuid.UUID, string, error) {
+	fieldsJSON, _ := json.Marshal(fields)
+	frontMatterJSON, _ := json.Marshal(frontMatter)
+	warningsJSON, _ := json.Marshal(warnings)
+	sum := sha256.Sum256(append(fieldsJSON, body...))
+	hash := hex.EncodeToString(sum[:])
+
+	var id uuid.UUID
+	var inserted bool
+	err := s.db.QueryRow(`
+		INSERT INTO lesson_drafts (source, source_ref, fields, front_matter, content_markdown, content_hash, warnings)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (source, source_ref) DO UPDATE
+		SET fields = EXCLUDED.fields, front_matter = EXCLUDED.front_matter,
+		    content_markdown = EXCLUDED.content_markdown, content_hash = EXCLUDED.content_hash,
+		    warnings = EXCLUDED.warnings, status = 'draft', updated_at = NOW()
+		WHERE lesson_drafts.content_hash <> EXCLUDED.content_hash
+		RETURNING id, (xmax = 0)
+	`, source, ref, fieldsJSON, frontMatterJSON, body, hash, warningsJSON).Scan(&id, &inserted)
+	if err == sql.ErrNoRows {
+		err = s.db.QueryRow(`SELECT id FROM lesson_drafts WHERE source = $1 AND source_ref = $2`, source, ref).Scan(&id)
+		if err != nil {
+			return uuid.Nil, "", fmt.Errorf("failed to load draft: %w", err)
+		}
+		return id, "unchanged", nil
+	}
+	if err != nil {
+		return uuid.Nil, "", fmt.Errorf("failed to save draft: %w", err)
+	}
+	if inserted {
+		return id, "created", nil
+	}
+	return id, "updated", nil
+}
+
+const lessonDraftColumns = `id, source, source_ref, fields, front_matter, content_markdown, warnings,
+	status, lesson_id, imported_at, updated_at, published_at`
+
+func scanLessonDraft(row rowScanner) (*models.LessonDraft, error) {
+	var draft models.LessonDraft
+	var fieldsJSON, frontMatterJSON, warningsJSON []byte
+	var lessonID uuid.NullUUID
+	var publishedAt sql.NullTime
+	err := row.Scan(
+		&draft.ID, &draft.Source, &draft.SourceRef, &fieldsJSON, &frontMatterJSON, &draft.ContentMarkdown,
+		&warningsJSON, &draft.Status, &lessonID, &draft.ImportedAt, &draft.UpdatedAt, &publishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(fieldsJSON, &draft.Fields); err != nil {
+		return nil, fmt.Errorf("failed to parse draft fields: %w", err)
+	}
+	if err := json.Unmarshal(frontMatterJSON, &draft.FrontMatter); err != nil {
+		return nil, fmt.Errorf("failed to parse draft front matter: %w", err)
+	}
+	if err := json.Unmarshal(warningsJSON, &draft.Warnings); err != nil {
+		return nil, fmt.Errorf("failed to parse draft warnings: %w", err)
+	}
+	if lessonID.Valid {
+		draft.LessonID = &lessonID.UUID
+	}
+	if publishedAt.Valid {
+		draft.PublishedAt = &publishedAt.Time
+	}
+	return &draft, nil
+}
+
+// ListDrafts returns lesson drafts, newest first, optionally filtered by status
+func (s *ContentImportService) ListDrafts(status string, limit int) ([]models.LessonDraft, error) {
+	rows, err := s.db.Query(`
+		SELECT `+lessonDraftColumns+`
+		FROM lesson_drafts
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY updated_at DESC, id
+		LIMIT $2
+	`, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lesson drafts: %w", err)
+	}
+	defer rows.Close()
+
+	drafts := []models.LessonDraft{}
+	for rows.Next() {
+		draft, err := scanLessonDraft(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan lesson draft: %w", err)
+		}
+		drafts = append(drafts, *draft)
+	}
+	return drafts, rows.Err()
+}
+
+// PublishDraft creates the lesson for a draft, or updates the lesson it was published as
+// before. New lessons need a title, level and order; updates bump the lesson's content
+// version so cached audio is regenerated. Prerequisites may name lesson IDs or external IDs;
+// publishing fails with a PrerequisiteError if they do not resolve or break the graph.
+func (s *ContentImportService) PublishDraft(draftID uuid.UUID) (*models.LessonDraft, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	draft, err := scanLessonDraft(tx.QueryRow(`SELECT `+lessonDraftColumns+` FROM lesson_drafts WHERE id = $1 FOR UPDATE`, draftID))
+	if err == sql.ErrNoRows {
+		return nil, ErrDraftNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lesson draft: %w", err)
+	}
+	if draft.Status == "published" {
+		return draft, nil
+	}
+
+	f := draft.Fields
+	var missing []string
+	if f.Title == "" {
+		missing = append(missing, "title")
+	}
+	if f.LevelID == nil {
+		missing = append(missing, "level_id")
+	}
+	if f.LessonOrder == nil {
+		missing = append(missing, "lesson_order")
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrDraftIncomplete, strings.Join(missing, ", "))
+	}
+
+	lessonType := f.LessonType
+	if lessonType == "" {
+		lessonType = "tutorial"
+	}
+	xpReward, minutes, required, ageBand := 50, 30, true, AgeBandChild
+	if f.XPReward != nil {
+		xpReward = *f.XPReward
+	}
+	if f.EstimatedMinutes != nil {
+		minutes = *f.EstimatedMinutes
+	}
+	if f.IsRequired != nil {
+		required = *f.IsRequired
+	}
+	if f.MinAgeBand != "" {
+		ageBand = f.MinAgeBand
+	}
+
+	if f.ExternalID != "" {
+		var taken bool
+		err = tx.QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM lessons WHERE external_id = $1 AND ($2::uuid IS NULL OR id <> $2))
+		`, f.ExternalID, draft.LessonID).Scan(&taken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check external ID: %w", err)
+		}
+		if taken {
+			return nil, ErrExternalIDTaken
+		}
+	}
+
+	var prereqIDs []uuid.UUID
+	if len(f.Prerequisites) > 0 {
+		lessonRef := uuid.Nil
+		if draft.LessonID != nil {
+			lessonRef = *draft.LessonID
+		}
+		if prereqIDs, err = resolvePrerequisiteRefs(tx, lessonRef, f.Prerequisites); err != nil {
+			return nil, err
+		}
+	}
+	prereqJSON, _ := json.Marshal(models.LessonPrerequisites{MinLevel: *f.LevelID, Lessons: prereqIDs})
+
+	var lessonID uuid.UUID
+	if draft.LessonID != nil {
+		err = tx.QueryRow(`
+			UPDATE lessons
+			SET level_id = $1, title = $2, description = $3, lesson_order = $4, lesson_type = $5,
+			    content_markdown = $6, human_practice = $7, reflection_prompt = $8, xp_reward = $9,
+			    estimated_minutes = $10, is_required = $11, min_age_band = $12, external_id = NULLIF($13, ''),
+			    prerequisites = $14, content_version = COALESCE(content_version, 0) + 1, updated_at = NOW()
+			WHERE id = $15
+			RETURNING id
+		`, *f.LevelID, f.Title, f.Description, *f.LessonOrder, lessonType, draft.ContentMarkdown,
+			f.HumanPractice, f.ReflectionPrompt, xpReward, minutes, required, ageBand, f.ExternalID,
+			prereqJSON, *draft.LessonID).Scan(&lessonID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to update lesson: %w", err)
+		}
+	}
+
+	// New drafts, and drafts whose lesson has since been deleted, create a lesson
+	if lessonID == uuid.Nil {
+		metadataJSON, _ := json.Marshal(map[string]interface{}{
+			"source":     draft.Source,
+			"source_ref": draft.SourceRef,
+		})
+		criteriaJSON, _ := json.Marshal(defaultCompletionCriteria(lessonType))
+		err = tx.QueryRow(`
+			INSERT INTO lessons (
+				level_id, title, description, lesson_order, lesson_type, content_markdown,
+				human_practice, reflection_prompt, xp_reward, estimated_minutes, metadata,
+				is_required, completion_criteria, min_age_band, external_id, prerequisites
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NULLIF($15, ''), $16)
+			RETURNING id
+		`, *f.LevelID, f.Title, f.Description, *f.LessonOrder, lessonType, draft.ContentMarkdown,
+			f.HumanPractice, f.ReflectionPrompt, xpReward, minutes, metadataJSON,
+			required, criteriaJSON, ageBand, f.ExternalID, prereqJSON).Scan(&lessonID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create lesson: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE lesson_drafts
+		SET status = 'published', lesson_id = $1, published_at = NOW(), updated_at = NOW()
+		WHERE id = $2
+	`, lessonID, draftID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark draft published: %w", err)
+	}
+	if err := recordLessonVersion(tx, lessonID, LessonVersionImport, nil); err != nil {
+		return nil, err
+	}
+
+	if err := validateLessonPrerequisites(tx, lessonID); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Published lesson draft %s (%s:%s) as lesson %s", draftID, draft.Source, draft.SourceRef, lessonID)
+
+	draft.Status = "published"
+	draft.LessonID = &lessonID
+	return draft, nil
+}
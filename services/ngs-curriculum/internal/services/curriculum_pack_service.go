@@ -0,0 +1,876 @@
+package services
+
+import (
+	"bytes"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// CurriculumPackVersion is the pack format this service reads and writes
+const CurriculumPackVersion = 1
+
+// Pack formats
+const (
+	PackFormatJSON = "json"
+	PackFormatYAML = "yaml"
+)
+
+var ErrInvalidCurriculumPack = errors.New("invalid curriculum pack")
+
+// CurriculumPackError lists everything wrong with a pack, each entry prefixed with where it is
+type CurriculumPackError struct {
+	Problems []string
+}
+
+func (e *CurriculumPackError) Error() string {
+	return "invalid curriculum pack: " + strings.Join(e.Problems, "; ")
+}
+
+func (e *CurriculumPackError) Unwrap() error {
+	return ErrInvalidCurriculumPack
+}
+
+// defaultPack is the curriculum seeded at startup
+//
+//go:embed packs/default.yaml
+var defaultPack []byte
+
+// DefaultCurriculumPack returns the built-in curriculum: the 24 levels and their core, computer
+// science, data science, ethical AI and ML engineering lessons
+func DefaultCurriculumPack() (*models.CurriculumPack, error) {
+	return ParseCurriculumPack(defaultPack, PackFormatYAML)
+}
+
+// ParseCurriculumPack decodes a JSON or YAML pack. Both are read through the JSON field names,
+// and unknown fields are rejected so typos do not silently drop content.
+func ParseCurriculumPack(data []byte, format string) (*models.CurriculumPack, error) {
+	if format == PackFormatYAML {
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCurriculumPack, err)
+		}
+		converted, err := json.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: mapping keys must be strings", ErrInvalidCurriculumPack)
+		}
+		data = converted
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var pack models.CurriculumPack
+	if err := decoder.Decode(&pack); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCurriculumPack, strings.TrimPrefix(err.Error(), "json: "))
+	}
+	return &pack, nil
+}
+
+// MarshalCurriculumPack encodes a pack as indented JSON or as YAML with the same field order.
+// Multi-line YAML strings are written as literal blocks so lesson markdown stays readable.
+func MarshalCurriculumPack(pack *models.CurriculumPack, format string) ([]byte, error) {
+	data, err := json.MarshalIndent(pack, "", "  ")
+	if err != nil || format != PackFormatYAML {
+		return data, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	node, err := yamlNodeFromJSON(decoder)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// yamlNodeFromJSON reads the next JSON value as a YAML node, keeping object key order
+func yamlNodeFromJSON(decoder *json.Decoder) (*yaml.Node, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := token.(type) {
+	case json.Delim:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		if v == '{' {
+			node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		for decoder.More() {
+			if node.Kind == yaml.MappingNode {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key.(string)})
+			}
+			child, err := yamlNodeFromJSON(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, child)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case string:
+		node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+		if strings.Contains(v, "\n") {
+			node.Style = yaml.LiteralStyle
+		}
+		return node, nil
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(v.String(), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: v.String()}, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}, nil
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+}
+
+// ValidateCurriculumPack checks a pack before anything is written and reports every problem.
+// References to lessons outside the pack are checked on import.
+func ValidateCurriculumPack(pack *models.CurriculumPack) error {
+	var problems []string
+	problem := func(path, format string, args ...interface{}) {
+		problems = append(problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if pack.Version != CurriculumPackVersion {
+		problem("version", "must be %d", CurriculumPackVersion)
+	}
+	if len(pack.Levels) == 0 {
+		problem("levels", "a pack needs at least one level")
+	}
+
+	levels := make(map[int]bool)
+	lessons := make(map[string]bool)
+	challenges := make(map[string]bool)
+	for i, level := range pack.Levels {
+		path := fmt.Sprintf("levels[%d]", i)
+		if level.Number < 1 {
+			problem(path, "number must be at least 1")
+		} else if levels[level.Number] {
+			problem(path, "level %d appears more than once", level.Number)
+		}
+		levels[level.Number] = true
+		if strings.TrimSpace(level.Title) == "" {
+			problem(path, "title is required")
+		}
+		if level.XPRequired != nil && *level.XPRequired < 0 {
+			problem(path, "xp_required must not be negative")
+		}
+
+		for j, lesson := range level.Lessons {
+			path := fmt.Sprintf("levels[%d].lessons[%d]", i, j)
+			id := strings.TrimSpace(lesson.ExternalID)
+			switch {
+			case id == "" || len(id) > 255:
+				problem(path, "external_id is required and must be at most 255 characters")
+			case lessons[id]:
+				problem(path, "external_id %q is used by another lesson", id)
+			}
+			lessons[id] = true
+			if title := strings.TrimSpace(lesson.Title); title == "" || utf8.RuneCountInString(title) > 255 {
+				problem(path, "title is required and must be at most 255 characters")
+			}
+			if lesson.Order < 1 {
+				problem(path, "order must be at least 1")
+			}
+			if lesson.MinAgeBand != "" {
+				if _, ok := ageBandRank[lesson.MinAgeBand]; !ok {
+					problem(path, "%v", ErrInvalidAgeBand)
+				}
+			}
+			if (lesson.XPReward != nil && *lesson.XPReward < 0) || (lesson.EstimatedMinutes != nil && *lesson.EstimatedMinutes < 0) {
+				problem(path, "xp_reward and estimated_minutes must not be negative")
+			}
+			if lesson.MinLevel < 0 {
+				problem(path, "min_level must not be negative")
+			}
+			if lesson.CompletionCriteria != nil {
+				raw, _ := json.Marshal(lesson.CompletionCriteria)
+				if _, err := ParseCompletionCriteria(raw); err != nil {
+					problem(path, "%v", err)
+				}
+			}
+			if len(lesson.Metadata) > 0 && !bytes.HasPrefix(bytes.TrimSpace(lesson.Metadata), []byte("{")) {
+				problem(path, "metadata must be an object")
+			}
+		}
+
+		for j, pc := range level.Challenges {
+			path := fmt.Sprintf("levels[%d].challenges[%d]", i, j)
+			id := strings.TrimSpace(pc.ExternalID)
+			switch {
+			case id == "" || len(id) > 255:
+				problem(path, "external_id is required and must be at most 255 characters")
+			case challenges[id]:
+				problem(path, "external_id %q is used by another challenge", id)
+			}
+			challenges[id] = true
+			c := packChallenge(level.Number, pc)
+			if err := ValidateChallenge(&c); err != nil {
+				problem(path, "%s", strings.TrimPrefix(err.Error(), ErrInvalidChallenge.Error()+": "))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &CurriculumPackError{Problems: problems}
+	}
+	return nil
+}
+
+// packLessonRow is a pack lesson with its defaults filled in, as it is written to lessons
+type packLessonRow struct {
+	Type             string
+	Description      string
+	ContentMarkdown  string
+	XPReward         int
+	EstimatedMinutes int
+	Required         bool
+	MinAgeBand       string
+	Criteria         []byte
+	MinLevel         int
+}
+
+func newPackLessonRow(levelNumber int, lesson models.PackLesson) packLessonRow {
+	row := packLessonRow{
+		Type:             strings.TrimSpace(lesson.Type),
+		Description:      lesson.Description,
+		ContentMarkdown:  lesson.ContentMarkdown,
+		XPReward:         50,
+		EstimatedMinutes: 30,
+		Required:         true,
+		MinAgeBand:       lesson.MinAgeBand,
+		MinLevel:         lesson.MinLevel,
+	}
+	if row.Type == "" {
+		row.Type = "tutorial"
+	}
+	if row.ContentMarkdown == "" {
+		body := lesson.CoreLesson
+		if body == "" {
+			body = lesson.Description
+		}
+		row.ContentMarkdown = "# " + strings.TrimSpace(lesson.Title) + "\n\n" + body
+	}
+	if lesson.XPReward != nil {
+		row.XPReward = *lesson.XPReward
+	}
+	if lesson.EstimatedMinutes != nil {
+		row.EstimatedMinutes = *lesson.EstimatedMinutes
+	}
+	if lesson.Required != nil {
+		row.Required = *lesson.Required
+	}
+	if row.MinAgeBand == "" {
+		row.MinAgeBand = AgeBandChild
+	}
+	criteria := defaultCompletionCriteria(row.Type)
+	if lesson.CompletionCriteria != nil {
+		criteria = *lesson.CompletionCriteria
+	}
+	row.Criteria, _ = json.Marshal(criteria)
+	if row.MinLevel == 0 {
+		row.MinLevel = levelNumber
+	}
+	return row
+}
+
+// packChallenge is a pack challenge as a challenge of its level, with the authoring defaults.
+// Its lesson is resolved on import.
+func packChallenge(levelNumber int, pc models.PackChallenge) models.Challenge {
+	c := models.Challenge{Difficulty: "medium", XPReward: 100, MinAgeBand: AgeBandChild, IsActive: true}
+	xpReward := pc.XPReward
+	if pc.Limits == nil {
+		pc.Limits = &models.ResourceLimits{}
+	}
+	tags := pc.Tags
+	ApplyChallengeRequest(&c, models.ChallengeRequest{
+		LevelID:           &levelNumber,
+		Title:             &pc.Title,
+		Description:       &pc.Description,
+		ChallengeType:     &pc.ChallengeType,
+		StarterCode:       &pc.StarterCode,
+		TestCases:         pc.TestCases,
+		SolutionTemplate:  &pc.SolutionTemplate,
+		XPReward:          xpReward,
+		TimeLimitMinutes:  &pc.TimeLimitMinutes,
+		Limits:            pc.Limits,
+		BaselineRuntimeMs: &pc.BaselineRuntimeMs,
+		BaselineMemoryKB:  &pc.BaselineMemoryKB,
+		Tags:              &tags,
+		Metadata:          pc.Metadata,
+	})
+	if pc.Difficulty != "" {
+		c.Difficulty = strings.ToLower(strings.TrimSpace(pc.Difficulty))
+	}
+	if pc.MinAgeBand != "" {
+		c.MinAgeBand = strings.TrimSpace(pc.MinAgeBand)
+	}
+	if pc.Active != nil {
+		c.IsActive = *pc.Active
+	}
+	return c
+}
+
+// PackImportOptions control how a pack is imported
+type PackImportOptions struct {
+	// DryRun runs the whole import and rolls it back
+	DryRun bool
+	// MissingOnly adds what is missing and leaves existing content alone. A lesson already at a
+	// pack lesson's level and order, from before packs, takes on its external ID instead.
+	MissingOnly bool
+}
+
+// CurriculumPackService imports and exports curriculum packs
+type CurriculumPackService struct {
+	db     *database.DB
+	config *config.Config
+}
+
+func NewCurriculumPackService(db *database.DB, cfg *config.Config) *CurriculumPackService {
+	return &CurriculumPackService{
+		db:     db,
+		config: cfg,
+	}
+}
+
+// ImportPack upserts a pack's levels, lessons and challenges in one transaction. Levels match by
+// number; lessons and challenges match by external ID or, for content exported without one, by
+// ID. Existing entries are overwritten with the pack's values, so the pack is the source of
+// truth for what it contains; content outside the pack is left alone.
+func (s *CurriculumPackService) ImportPack(pack *models.CurriculumPack, importerID uuid.NullUUID, opts PackImportOptions) (*models.CurriculumPackImport, error) {
+	if err := ValidateCurriculumPack(pack); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result := &models.CurriculumPackImport{DryRun: opts.DryRun}
+	for _, level := range pack.Levels {
+		if err := s.importLevel(tx, level, opts, &result.Levels); err != nil {
+			return nil, err
+		}
+	}
+
+	lessonIDs, err := s.importLessons(tx, pack, opts, &result.Lessons)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, level := range pack.Levels {
+		for _, pc := range level.Challenges {
+			if err := s.importChallenge(tx, level.Number, pc, lessonIDs, importerID, opts, &result.Challenges); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if opts.DryRun {
+		return result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Imported curriculum pack %q: levels %+v, lessons %+v, challenges %+v",
+		pack.Name, result.Levels, result.Lessons, result.Challenges)
+	return result, nil
+}
+
+func (s *CurriculumPackService) importLevel(tx *sql.Tx, level models.PackLevel, opts PackImportOptions, counts *models.PackImportCounts) error {
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM curriculum_levels WHERE level_number = $1)`, level.Number).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to query level %d: %w", level.Number, err)
+	}
+
+	var xpRequired sql.NullInt64
+	if level.XPRequired != nil {
+		xpRequired = sql.NullInt64{Int64: int64(*level.XPRequired), Valid: true}
+	}
+
+	if !exists {
+		if !xpRequired.Valid {
+			if level.Number > len(s.config.LevelUpXPThresholds) {
+				return &CurriculumPackError{Problems: []string{fmt.Sprintf(
+					"level %d: xp_required is needed beyond the %d configured level thresholds", level.Number, len(s.config.LevelUpXPThresholds))}}
+			}
+			xpRequired = sql.NullInt64{Int64: int64(s.config.LevelUpXPThresholds[level.Number-1]), Valid: true}
+		}
+		_, err := tx.Exec(`
+			INSERT INTO curriculum_levels (id, level_number, title, description, xp_required)
+			VALUES ($1, $1, $2, $3, $4)
+		`, level.Number, strings.TrimSpace(level.Title), level.Description, xpRequired)
+		if err != nil {
+			return fmt.Errorf("failed to create level %d: %w", level.Number, err)
+		}
+		counts.Created++
+		return nil
+	}
+
+	if opts.MissingOnly {
+		counts.Unchanged++
+		return nil
+	}
+	res, err := tx.Exec(`
+		UPDATE curriculum_levels SET (title, description, xp_required) = ($2, $3, COALESCE($4, xp_required))
+		WHERE level_number = $1 AND (title, description, xp_required) IS DISTINCT FROM ($2, $3, COALESCE($4, xp_required))
+	`, level.Number, strings.TrimSpace(level.Title), level.Description, xpRequired)
+	if err != nil {
+		return fmt.Errorf("failed to update level %d: %w", level.Number, err)
+	}
+	countWrite(res, counts)
+	return nil
+}
+
+// countWrite counts a conditional update as updated when it changed the row, else unchanged
+func countWrite(res sql.Result, counts *models.PackImportCounts) {
+	if n, _ := res.RowsAffected(); n > 0 {
+		counts.Updated++
+	} else {
+		counts.Unchanged++
+	}
+}
+
+// findPackEntry returns the row of table matching a pack key: its external ID, or its ID for
+// content exported without an external ID
+func findPackEntry(q queryRower, table, key string) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := q.QueryRow(`
+		SELECT id FROM `+table+`
+		WHERE external_id = $1 OR (external_id IS NULL AND id::text = $1)
+		ORDER BY external_id IS NULL
+		LIMIT 1
+	`, key).Scan(&id)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, nil
+	}
+	return id, err
+}
+
+// importLessons writes the pack's lessons, then their prerequisites once every lesson of the
+// pack has an ID, and returns the lesson IDs by external ID
+func (s *CurriculumPackService) importLessons(tx *sql.Tx, pack *models.CurriculumPack, opts PackImportOptions, counts *models.PackImportCounts) (map[string]uuid.UUID, error) {
+	ids := make(map[string]uuid.UUID)
+	status := make(map[uuid.UUID]string)
+	var order []uuid.UUID
+	prerequisites := make(map[uuid.UUID]models.PackLesson)
+
+	for _, level := range pack.Levels {
+		for _, lesson := range level.Lessons {
+			key := strings.TrimSpace(lesson.ExternalID)
+			row := newPackLessonRow(level.Number, lesson)
+
+			id, err := findPackEntry(tx, "lessons", key)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find lesson %s: %w", key, err)
+			}
+
+			if id == uuid.Nil && opts.MissingOnly {
+				err := tx.QueryRow(`
+					SELECT id FROM lessons
+					WHERE level_id = $1 AND lesson_order = $2 AND overlay_of IS NULL
+					ORDER BY created_at, id
+					LIMIT 1
+				`, level.Number, lesson.Order).Scan(&id)
+				if err != nil && err != sql.ErrNoRows {
+					return nil, fmt.Errorf("failed to find lesson L%d.%d: %w", level.Number, lesson.Order, err)
+				}
+				if id != uuid.Nil {
+					if _, err := tx.Exec(`UPDATE lessons SET external_id = $1 WHERE id = $2 AND external_id IS NULL`, key, id); err != nil {
+						return nil, fmt.Errorf("failed to set external ID of lesson %s: %w", id, err)
+					}
+				}
+			}
+
+			switch {
+			case id == uuid.Nil:
+				var metadata interface{} = []byte(`{"version": 1}`)
+				if len(lesson.Metadata) > 0 {
+					metadata = []byte(lesson.Metadata)
+				}
+				prereqJSON, _ := json.Marshal(models.LessonPrerequisites{MinLevel: row.MinLevel})
+				err = tx.QueryRow(`
+					INSERT INTO lessons (
+						level_id, title, description, lesson_order, lesson_type, content_markdown,
+						core_lesson, human_practice, reflection_prompt, agent_unlock, xp_reward,
+						estimated_minutes, is_required, min_age_band, completion_criteria, metadata,
+						prerequisites, external_id
+					) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+					RETURNING id
+				`, level.Number, strings.TrimSpace(lesson.Title), row.Description, lesson.Order, row.Type, row.ContentMarkdown,
+					lesson.CoreLesson, lesson.HumanPractice, lesson.ReflectionPrompt, lesson.AgentUnlock, row.XPReward,
+					row.EstimatedMinutes, row.Required, row.MinAgeBand, row.Criteria, metadata, prereqJSON, key).Scan(&id)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create lesson %s: %w", key, err)
+				}
+				if err := recordLessonVersion(tx, id, LessonVersionPack, nil); err != nil {
+					return nil, err
+				}
+				status[id] = "created"
+				prerequisites[id] = lesson
+			case opts.MissingOnly:
+				status[id] = "unchanged"
+			default:
+				var metadata interface{}
+				if len(lesson.Metadata) > 0 {
+					metadata = []byte(lesson.Metadata)
+				}
+				res, err := tx.Exec(`
+					UPDATE lessons SET
+						(level_id, title, description, lesson_order, lesson_type, content_markdown, core_lesson,
+						 human_practice, reflection_prompt, agent_unlock, xp_reward, estimated_minutes,
+						 is_required, min_age_band, completion_criteria, metadata)
+						= ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, COALESCE($16::jsonb, metadata)),
+						content_version = COALESCE(content_version, 0) + 1, updated_at = NOW()
+					WHERE id = $17 AND
+						(level_id, title, description, lesson_order, lesson_type, content_markdown, core_lesson,
+						 human_practice, reflection_prompt, agent_unlock, xp_reward, estimated_minutes,
+						 is_required, min_age_band, completion_criteria, metadata)
+						IS DISTINCT FROM ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15::jsonb, COALESCE($16::jsonb, metadata))
+				`, level.Number, strings.TrimSpace(lesson.Title), row.Description, lesson.Order, row.Type, row.ContentMarkdown,
+					lesson.CoreLesson, lesson.HumanPractice, lesson.ReflectionPrompt, lesson.AgentUnlock, row.XPReward,
+					row.EstimatedMinutes, row.Required, row.MinAgeBand, row.Criteria, metadata, id)
+				if err != nil {
+					return nil, fmt.Errorf("failed to update lesson %s: %w", key, err)
+				}
+				status[id] = "unchanged"
+				if n, _ := res.RowsAffected(); n > 0 {
+					status[id] = "updated"
+					if err := recordLessonVersion(tx, id, LessonVersionPack, nil); err != nil {
+						return nil, err
+					}
+				}
+				prerequisites[id] = lesson
+			}
+			ids[key] = id
+			order = append(order, id)
+		}
+	}
+
+	// Prerequisites may name lessons later in the pack, so they are written last
+	touched := make(map[uuid.UUID]bool)
+	for id, lesson := range prerequisites {
+		var refs []uuid.UUID
+		for _, ref := range lesson.Prerequisites {
+			ref = strings.TrimSpace(ref)
+			if refID, ok := ids[ref]; ok {
+				refs = append(refs, refID)
+				continue
+			}
+			resolved, err := resolvePrerequisiteRefs(tx, id, []string{ref})
+			if err != nil {
+				return nil, err
+			}
+			refs = append(refs, resolved...)
+		}
+		minLevel := lesson.MinLevel
+		if minLevel == 0 {
+			if err := tx.QueryRow(`SELECT level_id FROM lessons WHERE id = $1`, id).Scan(&minLevel); err != nil {
+				return nil, fmt.Errorf("failed to read lesson %s: %w", id, err)
+			}
+		}
+		prereqJSON, _ := json.Marshal(models.LessonPrerequisites{MinLevel: minLevel, Lessons: refs})
+		res, err := tx.Exec(`
+			UPDATE lessons SET prerequisites = $1
+			WHERE id = $2 AND prerequisites IS DISTINCT FROM $1::jsonb
+		`, prereqJSON, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set prerequisites of lesson %s: %w", id, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 && status[id] == "unchanged" {
+			status[id] = "updated"
+		}
+		touched[id] = true
+	}
+	if len(touched) > 0 {
+		if err := validateLessonsPrerequisites(tx, touched); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, id := range order {
+		switch status[id] {
+		case "created":
+			counts.Created++
+		case "updated":
+			counts.Updated++
+		default:
+			counts.Unchanged++
+		}
+	}
+	return ids, nil
+}
+
+func (s *CurriculumPackService) importChallenge(tx *sql.Tx, levelNumber int, pc models.PackChallenge, lessonIDs map[string]uuid.UUID,
+	importerID uuid.NullUUID, opts PackImportOptions, counts *models.PackImportCounts) error {
+	key := strings.TrimSpace(pc.ExternalID)
+	c := packChallenge(levelNumber, pc)
+
+	if ref := strings.TrimSpace(pc.Lesson); ref != "" {
+		lessonID, ok := lessonIDs[ref]
+		if !ok {
+			var err error
+			if lessonID, err = findPackEntry(tx, "lessons", ref); err != nil {
+				return fmt.Errorf("failed to find lesson %s: %w", ref, err)
+			}
+		}
+		if lessonID == uuid.Nil {
+			return &CurriculumPackError{Problems: []string{fmt.Sprintf("challenge %s: lesson %q does not exist", key, ref)}}
+		}
+		c.LessonID = lessonID
+	}
+
+	id, err := findPackEntry(tx, "challenges", key)
+	if err != nil {
+		return fmt.Errorf("failed to find challenge %s: %w", key, err)
+	}
+
+	if id == uuid.Nil {
+		_, err := tx.Exec(`
+			INSERT INTO challenges (
+				lesson_id, level_id, title, description, challenge_type, difficulty, starter_code,
+				test_cases, solution_template, xp_reward, time_limit_minutes, tags, metadata,
+				min_age_band, run_time_limit_ms, run_cpu_limit_ms, run_memory_limit_mb,
+				baseline_runtime_ms, baseline_memory_kb, created_by, updated_by, updated_at, is_active, external_id
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $20, NOW(), $21, $22)
+		`, append(challengeWriteArgs(&c, importerID), c.IsActive, key)...)
+		if err != nil {
+			return fmt.Errorf("failed to create challenge %s: %w", key, err)
+		}
+		counts.Created++
+		return nil
+	}
+
+	if opts.MissingOnly {
+		counts.Unchanged++
+		return nil
+	}
+	res, err := tx.Exec(`
+		UPDATE challenges SET
+			(lesson_id, level_id, title, description, challenge_type, difficulty, starter_code, test_cases,
+			 solution_template, xp_reward, time_limit_minutes, tags, metadata, min_age_band,
+			 run_time_limit_ms, run_cpu_limit_ms, run_memory_limit_mb, baseline_runtime_ms, baseline_memory_kb, is_active)
+			= ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $21),
+			updated_by = $20, updated_at = NOW()
+		WHERE id = $22 AND
+			(lesson_id, level_id, title, description, challenge_type, difficulty, starter_code, test_cases,
+			 solution_template, xp_reward, time_limit_minutes, tags, metadata, min_age_band,
+			 run_time_limit_ms, run_cpu_limit_ms, run_memory_limit_mb, baseline_runtime_ms, baseline_memory_kb, is_active)
+			IS DISTINCT FROM ($1::uuid, $2::int, $3, $4, $5, $6, $7, $8::jsonb, $9, $10::int, $11::int, $12::text[], $13::jsonb, $14,
+			 $15::int, $16::int, $17::int, $18::float8, $19::bigint, $21::boolean)
+	`, append(challengeWriteArgs(&c, importerID), c.IsActive, id)...)
+	if err != nil {
+		return fmt.Errorf("failed to update challenge %s: %w", key, err)
+	}
+	countWrite(res, counts)
+	return nil
+}
+
+// ExportPack dumps every level, base lesson and challenge, inactive ones included, as a pack.
+// Cohort overlays are left out. Lessons and challenges without an external ID are keyed by ID.
+func (s *CurriculumPackService) ExportPack() (*models.CurriculumPack, error) {
+	pack := &models.CurriculumPack{Version: CurriculumPackVersion, Levels: []models.PackLevel{}}
+	levelIndex := make(map[int]int)
+
+	rows, err := s.db.Query(`
+		SELECT level_number, title, COALESCE(description, ''), xp_required
+		FROM curriculum_levels
+		ORDER BY level_number
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query levels: %w", err)
+	}
+	for rows.Next() {
+		var level models.PackLevel
+		var xpRequired int
+		if err := rows.Scan(&level.Number, &level.Title, &level.Description, &xpRequired); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan level: %w", err)
+		}
+		level.XPRequired = &xpRequired
+		levelIndex[level.Number] = len(pack.Levels)
+		pack.Levels = append(pack.Levels, level)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read levels: %w", err)
+	}
+
+	if err := s.exportLessons(pack, levelIndex); err != nil {
+		return nil, err
+	}
+	if err := s.exportChallenges(pack, levelIndex); err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
+
+func (s *CurriculumPackService) exportLessons(pack *models.CurriculumPack, levelIndex map[int]int) error {
+	rows, err := s.db.Query(`
+		SELECT id, COALESCE(external_id, id::text), level_id, lesson_order, title, lesson_type,
+		       COALESCE(description, ''), COALESCE(content_markdown, ''), COALESCE(core_lesson, ''),
+		       COALESCE(human_practice, ''), COALESCE(reflection_prompt, ''), COALESCE(agent_unlock, ''),
+		       COALESCE(xp_reward, 0), COALESCE(estimated_minutes, 0), COALESCE(is_required, true), min_age_band,
+		       completion_criteria, prerequisites, metadata
+		FROM lessons
+		WHERE overlay_of IS NULL
+		ORDER BY level_id, lesson_order, id
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to query lessons: %w", err)
+	}
+	defer rows.Close()
+
+	type exported struct {
+		level   int
+		index   int
+		prereqs models.LessonPrerequisites
+	}
+	keys := make(map[uuid.UUID]string)
+	var lessons []exported
+	for rows.Next() {
+		var id uuid.UUID
+		var lesson models.PackLesson
+		var level, xpReward, minutes int
+		var required bool
+		var criteria, prerequisites, metadata []byte
+		err := rows.Scan(&id, &lesson.ExternalID, &level, &lesson.Order, &lesson.Title, &lesson.Type,
+			&lesson.Description, &lesson.ContentMarkdown, &lesson.CoreLesson,
+			&lesson.HumanPractice, &lesson.ReflectionPrompt, &lesson.AgentUnlock,
+			&xpReward, &minutes, &required, &lesson.MinAgeBand,
+			&criteria, &prerequisites, &metadata)
+		if err != nil {
+			return fmt.Errorf("failed to scan lesson: %w", err)
+		}
+		i, ok := levelIndex[level]
+		if !ok {
+			continue
+		}
+
+		lesson.XPReward, lesson.EstimatedMinutes, lesson.Required = &xpReward, &minutes, &required
+		if parsed, err := ParseCompletionCriteria(criteria); err == nil && parsed != defaultCompletionCriteria(lesson.Type) {
+			lesson.CompletionCriteria = &parsed
+		}
+		if len(metadata) > 0 && string(metadata) != "null" {
+			lesson.Metadata = metadata
+		}
+		var prereqs models.LessonPrerequisites
+		if len(prerequisites) > 0 {
+			_ = json.Unmarshal(prerequisites, &prereqs)
+		}
+		if prereqs.MinLevel != 0 && prereqs.MinLevel != level {
+			lesson.MinLevel = prereqs.MinLevel
+		}
+
+		keys[id] = lesson.ExternalID
+		lessons = append(lessons, exported{level: i, index: len(pack.Levels[i].Lessons), prereqs: prereqs})
+		pack.Levels[i].Lessons = append(pack.Levels[i].Lessons, lesson)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read lessons: %w", err)
+	}
+
+	// Prerequisites are written as keys once every lesson's key is known
+	for _, l := range lessons {
+		for _, ref := range l.prereqs.Lessons {
+			key, ok := keys[ref]
+			if !ok {
+				key = ref.String()
+			}
+			lesson := &pack.Levels[l.level].Lessons[l.index]
+			lesson.Prerequisites = append(lesson.Prerequisites, key)
+		}
+	}
+	return nil
+}
+
+func (s *CurriculumPackService) exportChallenges(pack *models.CurriculumPack, levelIndex map[int]int) error {
+	rows, err := s.db.Query(`
+		SELECT ` + challengeColumns + `, COALESCE(external_id, id::text),
+		       (SELECT COALESCE(l.external_id, l.id::text) FROM lessons l WHERE l.id = challenges.lesson_id)
+		FROM challenges
+		ORDER BY level_id, title, id
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to query challenges: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var lessonKey sql.NullString
+		c, err := scanChallenge(extraColumns{row: rows, extra: []interface{}{&key, &lessonKey}})
+		if err != nil {
+			return fmt.Errorf("failed to scan challenge: %w", err)
+		}
+		i, ok := levelIndex[c.LevelID]
+		if !ok {
+			continue
+		}
+
+		xpReward, active := c.XPReward, c.IsActive
+		pc := models.PackChallenge{
+			ExternalID:        key,
+			Lesson:            lessonKey.String,
+			Title:             c.Title,
+			Description:       c.Description,
+			ChallengeType:     c.ChallengeType,
+			Difficulty:        c.Difficulty,
+			StarterCode:       c.StarterCode,
+			SolutionTemplate:  c.SolutionTemplate,
+			TestCases:         c.TestCases,
+			XPReward:          &xpReward,
+			TimeLimitMinutes:  c.TimeLimitMinutes,
+			BaselineRuntimeMs: c.BaselineRuntimeMs,
+			BaselineMemoryKB:  c.BaselineMemoryKB,
+			Tags:              c.Tags,
+			Metadata:          c.Metadata,
+			MinAgeBand:        c.MinAgeBand,
+			Active:            &active,
+		}
+		if c.Limits != (models.ResourceLimits{}) {
+			limits := c.Limits
+			pc.Limits = &limits
+		}
+		pack.Levels[i].Challenges = append(pack.Levels[i].Challenges, pc)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read challenges: %w", err)
+	}
+	return nil
+}
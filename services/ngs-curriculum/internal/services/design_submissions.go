@@ -0,0 +1,547 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"noble-ngs-curriculum/internal/clients/intelligence"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ChallengeTypeDesign challenges are answered with a write-up and diagrams and scored on a rubric
+const ChallengeTypeDesign = "design"
+
+const (
+	// designEvaluationTimeout bounds the intelligence service's rubric evaluation; a submission
+	// it does not finish stays in the review queue
+	designEvaluationTimeout = 60 * time.Second
+
+	designPendingFeedback = "Your design has been submitted and is waiting for rubric review."
+)
+
+var (
+	ErrNotDesignChallenge       = errors.New("challenge does not take design submissions")
+	ErrDesignSubmissionRequired = errors.New("design challenges take a write-up and assets at /ngs/challenges/:id/design")
+	ErrInvalidDesignSubmission  = errors.New("invalid design submission")
+	ErrDesignAssetsUnavailable  = errors.New("design asset uploads need a blob store")
+	ErrInvalidReview            = errors.New("invalid rubric review")
+	ErrSubmissionReviewed       = errors.New("submission has already been reviewed")
+	ErrAssetNotFound            = errors.New("asset not found")
+)
+
+// designAssetTypes are the sniffed content types accepted for design assets. SVG is left out
+// because it can carry script.
+var designAssetTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+// defaultDesignRubric scores design challenges whose metadata has no rubric
+var defaultDesignRubric = []models.RubricCriterion{
+	{Name: "Requirements", Description: "Covers the functional and non-functional requirements", MaxPoints: 10},
+	{Name: "Architecture", Description: "Components, data flow and interfaces are clear and sound", MaxPoints: 10},
+	{Name: "Trade-offs", Description: "Alternatives are weighed and choices justified", MaxPoints: 10},
+	{Name: "Communication", Description: "The write-up and diagrams are clear and consistent", MaxPoints: 10},
+}
+
+var designReviews = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ngs_design_reviews_total",
+		Help: "Design submission rubric evaluations, by method (educator or ai) and result (passed, failed or error).",
+	},
+	[]string{"method", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(designReviews)
+}
+
+// DesignEvaluator scores design submissions against a rubric; *intelligence.Client is one
+type DesignEvaluator interface {
+	EvaluateDesign(ctx context.Context, req intelligence.EvaluateDesignRequest, userID, userEmail, userRole string) (*intelligence.EvaluateDesignResponse, error)
+}
+
+// SetDesignEvaluator has design submissions scored by evaluator as they arrive. Submissions it
+// fails to score wait for an educator.
+func (s *ChallengeService) SetDesignEvaluator(evaluator DesignEvaluator) {
+	s.evaluator = evaluator
+}
+
+// DesignUpload is an asset file uploaded with a design submission
+type DesignUpload struct {
+	FileName string
+	Data     []byte
+}
+
+// storedDesignAsset is an entry of challenge_submissions.assets, with the asset's blob store key
+type storedDesignAsset struct {
+	models.DesignAsset
+	Key string `json:"key"`
+}
+
+// DesignRubric returns a design challenge's metadata.rubric, or the default rubric when it has
+// none. Criteria without a name or points are skipped.
+func DesignRubric(challenge *models.Challenge) []models.RubricCriterion {
+	var metadata struct {
+		Rubric []models.RubricCriterion `json:"rubric"`
+	}
+	if len(challenge.Metadata) > 0 {
+		if err := json.Unmarshal(challenge.Metadata, &metadata); err != nil {
+			log.Printf("Ignoring unreadable rubric of challenge %s: %v", challenge.ID, err)
+		}
+	}
+
+	var rubric []models.RubricCriterion
+	for _, criterion := range metadata.Rubric {
+		if strings.TrimSpace(criterion.Name) != "" && criterion.MaxPoints > 0 {
+			rubric = append(rubric, criterion)
+		}
+	}
+	if len(rubric) == 0 {
+		return defaultDesignRubric
+	}
+	return rubric
+}
+
+// ScoreRubric checks that scores cover every rubric criterion once, matched case-insensitively,
+// within its points. It returns the scores in rubric order and the percentage of points earned.
+func ScoreRubric(rubric []models.RubricCriterion, scores []models.RubricScore) ([]models.RubricScore, int, error) {
+	byName := make(map[string]models.RubricScore, len(scores))
+	for _, score := range scores {
+		name := strings.ToLower(strings.TrimSpace(score.Criterion))
+		if _, dup := byName[name]; dup {
+			return nil, 0, fmt.Errorf("%w: %q is scored more than once", ErrInvalidReview, score.Criterion)
+		}
+		byName[name] = score
+	}
+	if len(byName) > len(rubric) {
+		return nil, 0, fmt.Errorf("%w: scores name criteria outside the rubric", ErrInvalidReview)
+	}
+
+	graded := make([]models.RubricScore, 0, len(rubric))
+	earned, possible := 0, 0
+	for _, criterion := range rubric {
+		score, ok := byName[strings.ToLower(criterion.Name)]
+		if !ok {
+			return nil, 0, fmt.Errorf("%w: %q is not scored", ErrInvalidReview, criterion.Name)
+		}
+		if score.Points < 0 || score.Points > criterion.MaxPoints {
+			return nil, 0, fmt.Errorf("%w: %q must be scored from 0 to %d", ErrInvalidReview, criterion.Name, criterion.MaxPoints)
+		}
+		graded = append(graded, models.RubricScore{
+			Criterion: criterion.Name,
+			Points:    score.Points,
+			MaxPoints: criterion.MaxPoints,
+			Comment:   strings.TrimSpace(score.Comment),
+		})
+		earned += score.Points
+		possible += criterion.MaxPoints
+	}
+
+	return graded, int(math.Round(float64(earned) * 100 / float64(possible))), nil
+}
+
+// SubmitDesign stores a design challenge submission: a markdown write-up, capped at
+// SUBMISSION_MAX_CODE_BYTES, and up to DESIGN_MAX_ASSETS diagrams or documents written to the blob
+// store. The submission waits for rubric review unless the design evaluator scores it straight away.
+func (s *ChallengeService) SubmitDesign(userID uuid.UUID, userEmail, userRole string, challengeID uuid.UUID, writeup string, uploads []DesignUpload) (*models.ChallengeSubmission, error) {
+	writeup = strings.TrimSpace(writeup)
+	if writeup == "" {
+		return nil, fmt.Errorf("%w: a write-up is required", ErrInvalidDesignSubmission)
+	}
+	if max := s.config.SubmissionMaxCodeBytes; max > 0 && len(writeup) > max {
+		return nil, fmt.Errorf("%w: write-up is %d bytes, limit %d", ErrSubmissionTooLarge, len(writeup), max)
+	}
+	if len(uploads) > s.config.DesignMaxAssets {
+		return nil, fmt.Errorf("%w: at most %d assets", ErrInvalidDesignSubmission, s.config.DesignMaxAssets)
+	}
+	if len(uploads) > 0 && s.blobs == nil {
+		return nil, ErrDesignAssetsUnavailable
+	}
+
+	assets := make([]storedDesignAsset, 0, len(uploads))
+	for i, upload := range uploads {
+		if max := s.config.DesignAssetMaxBytes; max > 0 && len(upload.Data) > max {
+			return nil, fmt.Errorf("%w: asset %q is %d bytes, limit %d", ErrSubmissionTooLarge, upload.FileName, len(upload.Data), max)
+		}
+		contentType := http.DetectContentType(upload.Data)
+		if !designAssetTypes[contentType] {
+			return nil, fmt.Errorf("%w: asset %q must be a PNG, JPEG, GIF, WebP or PDF file", ErrInvalidDesignSubmission, upload.FileName)
+		}
+		name := strings.TrimSpace(filepath.Base(upload.FileName))
+		if name == "" || name == "." || name == string(filepath.Separator) {
+			name = fmt.Sprintf("asset-%d", i+1)
+		}
+		if utf8.RuneCountInString(name) > 255 {
+			name = string([]rune(name)[:255])
+		}
+		assets = append(assets, storedDesignAsset{DesignAsset: models.DesignAsset{
+			ID:          uuid.New(),
+			FileName:    name,
+			ContentType: contentType,
+			SizeBytes:   int64(len(upload.Data)),
+		}})
+	}
+
+	challenge, err := scanChallenge(s.db.QueryRow(`
+		SELECT `+challengeColumns+`
+		FROM challenges
+		WHERE id = $1 AND is_active = true AND `+contentAgeFilterSQL("min_age_band", "$2")+`
+	`, challengeID, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrChallengeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query challenge: %w", err)
+	}
+	if challenge.ChallengeType != ChallengeTypeDesign {
+		return nil, ErrNotDesignChallenge
+	}
+
+	// Write the assets first so the row can reference them
+	submissionID := uuid.New()
+	ctx := context.Background()
+	var keys []string
+	saved := false
+	defer func() {
+		if !saved {
+			s.deleteSubmissionBlobs(ctx, keys...)
+		}
+	}()
+	for i := range assets {
+		assets[i].Key = submissionBlobKey(userID, submissionID, "assets/"+assets[i].ID.String())
+		if err := s.blobs.Put(ctx, assets[i].Key, uploads[i].Data, assets[i].ContentType); err != nil {
+			submissionBlobs.WithLabelValues("design_asset", "put", "error").Inc()
+			return nil, fmt.Errorf("failed to store asset %q: %w", assets[i].FileName, err)
+		}
+		submissionBlobs.WithLabelValues("design_asset", "put", "success").Inc()
+		keys = append(keys, assets[i].Key)
+	}
+	assetsJSON, _ := json.Marshal(assets)
+
+	submission := models.ChallengeSubmission{
+		Feedback: designPendingFeedback,
+		Status:   models.ReviewPending,
+		Writeup:  writeup,
+		Assets:   publicDesignAssets(assets),
+	}
+	err = s.db.QueryRow(`
+		INSERT INTO challenge_submissions (
+			id, user_id, challenge_id, submission_code, passed, score, feedback,
+			code_length, review_status, writeup, assets
+		)
+		VALUES ($1, $2, $3, '', false, 0, $4, $5, $6, $7, $8)
+		RETURNING id, user_id, challenge_id, COALESCE(is_shared, false), submitted_at
+	`, submissionID, userID, challengeID, designPendingFeedback, utf8.RuneCountInString(writeup),
+		models.ReviewPending, writeup, assetsJSON).Scan(
+		&submission.ID, &submission.UserID, &submission.ChallengeID, &submission.IsShared, &submission.SubmittedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create submission: %w", err)
+	}
+	saved = true
+
+	if s.evaluator == nil {
+		return &submission, nil
+	}
+	graded, err := s.evaluateDesign(&challenge, &submission, uploads, userEmail, userRole)
+	if err != nil {
+		designReviews.WithLabelValues(models.EvaluationAI, "error").Inc()
+		log.Printf("Design submission %s left for educator review, AI evaluation failed: %v", submission.ID, err)
+		return &submission, nil
+	}
+	return graded, nil
+}
+
+// evaluateDesign asks the design evaluator to score a new submission and records its evaluation
+func (s *ChallengeService) evaluateDesign(challenge *models.Challenge, submission *models.ChallengeSubmission, uploads []DesignUpload, userEmail, userRole string) (*models.ChallengeSubmission, error) {
+	rubric := DesignRubric(challenge)
+	req := intelligence.EvaluateDesignRequest{
+		ChallengeTitle:       challenge.Title,
+		ChallengeDescription: challenge.Description,
+		Writeup:              submission.Writeup,
+	}
+	for _, criterion := range rubric {
+		req.Rubric = append(req.Rubric, intelligence.RubricCriterion(criterion))
+	}
+	for i, asset := range submission.Assets {
+		req.Assets = append(req.Assets, intelligence.DesignAsset{
+			FileName:    asset.FileName,
+			ContentType: asset.ContentType,
+			Data:        uploads[i].Data,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), designEvaluationTimeout)
+	defer cancel()
+	resp, err := s.evaluator.EvaluateDesign(ctx, req, submission.UserID.String(), userEmail, userRole)
+	if err != nil {
+		return nil, err
+	}
+
+	evaluation := models.DesignEvaluation{Method: models.EvaluationAI, Comments: resp.Comments}
+	for _, score := range resp.Scores {
+		evaluation.Scores = append(evaluation.Scores, models.RubricScore{
+			Criterion: score.Criterion,
+			Points:    score.Points,
+			Comment:   score.Comment,
+		})
+	}
+	return s.gradeDesignSubmission(submission.ID, evaluation)
+}
+
+// ReviewDesignSubmission records an educator's rubric scores for a pending design submission,
+// awarding XP when it passes
+func (s *ChallengeService) ReviewDesignSubmission(submissionID uuid.UUID, reviewerID uuid.UUID, req models.ReviewDesignRequest) (*models.ChallengeSubmission, error) {
+	return s.gradeDesignSubmission(submissionID, models.DesignEvaluation{
+		Method:     models.EvaluationEducator,
+		ReviewerID: &reviewerID,
+		Scores:     req.Scores,
+		Comments:   strings.TrimSpace(req.Comments),
+	})
+}
+
+// gradeDesignSubmission scores a pending design submission on its challenge's rubric, marks it
+// graded and awards XP when it passes. The row is locked so two reviews cannot both grade it.
+func (s *ChallengeService) gradeDesignSubmission(submissionID uuid.UUID, evaluation models.DesignEvaluation) (*models.ChallengeSubmission, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var submission models.ChallengeSubmission
+	var assets []byte
+	err = tx.QueryRow(`
+		SELECT id, user_id, challenge_id, COALESCE(is_shared, false), submitted_at,
+		       review_status, COALESCE(writeup, ''), assets
+		FROM challenge_submissions
+		WHERE id = $1
+		FOR UPDATE
+	`, submissionID).Scan(
+		&submission.ID, &submission.UserID, &submission.ChallengeID, &submission.IsShared,
+		&submission.SubmittedAt, &submission.Status, &submission.Writeup, &assets,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrSubmissionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query submission: %w", err)
+	}
+	if submission.Status != models.ReviewPending {
+		return nil, ErrSubmissionReviewed
+	}
+	submission.Assets = decodeDesignAssets(assets)
+
+	challenge, err := scanChallenge(tx.QueryRow(`
+		SELECT `+challengeColumns+`
+		FROM challenges
+		WHERE id = $1
+	`, submission.ChallengeID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query challenge: %w", err)
+	}
+
+	scores, score, err := ScoreRubric(DesignRubric(&challenge), evaluation.Scores)
+	if err != nil {
+		return nil, err
+	}
+	evaluation.Scores = scores
+	evaluation.ReviewedAt = time.Now().UTC()
+	evaluationJSON, _ := json.Marshal(evaluation)
+
+	submission.Score = score
+	submission.Passed = score >= 60
+	submission.Feedback = designFeedback(submission.Passed, score, evaluation)
+	submission.Status = models.ReviewGraded
+	submission.Evaluation = &evaluation
+
+	_, err = tx.Exec(`
+		UPDATE challenge_submissions
+		SET passed = $1, score = $2, feedback = $3, evaluation = $4, review_status = $5
+		WHERE id = $6 AND submitted_at = $7
+	`, submission.Passed, score, submission.Feedback, evaluationJSON, models.ReviewGraded,
+		submission.ID, submission.SubmittedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record review: %w", err)
+	}
+
+	if submission.Passed {
+		if err := s.awardChallengeXP(tx, submission.UserID, &challenge, score); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if submission.Passed {
+		invalidateLeaderboards(s.leaderboards)
+	}
+
+	result := "failed"
+	if submission.Passed {
+		result = "passed"
+	}
+	designReviews.WithLabelValues(evaluation.Method, result).Inc()
+	return &submission, nil
+}
+
+// designFeedback summarises a rubric evaluation, naming the weakest criterion
+func designFeedback(passed bool, score int, evaluation models.DesignEvaluation) string {
+	feedback := fmt.Sprintf("Your design scored %d%% on the rubric and needs more work.", score)
+	if passed {
+		feedback = fmt.Sprintf("Your design passed with %d%% on the rubric.", score)
+	}
+
+	if weakest := weakestCriterion(evaluation.Scores); weakest >= 0 {
+		feedback += fmt.Sprintf(" Focus next on %s.", evaluation.Scores[weakest].Criterion)
+	}
+	if evaluation.Comments != "" {
+		feedback += " " + evaluation.Comments
+	}
+	return feedback
+}
+
+// weakestCriterion is the index of the score with the lowest share of its points, or -1 when
+// every criterion earned full points
+func weakestCriterion(scores []models.RubricScore) int {
+	weakest := -1
+	for i, s := range scores {
+		if s.Points < s.MaxPoints && (weakest < 0 || s.Points*scores[weakest].MaxPoints < scores[weakest].Points*s.MaxPoints) {
+			weakest = i
+		}
+	}
+	return weakest
+}
+
+// GetReviewQueue lists design submissions awaiting rubric review, oldest first
+func (s *ChallengeService) GetReviewQueue(limit int) ([]models.ChallengeSubmission, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, user_id, challenge_id, COALESCE(feedback, ''), submitted_at, COALESCE(writeup, ''), assets
+		FROM challenge_submissions
+		WHERE review_status = $1
+		ORDER BY submitted_at, id
+		LIMIT $2
+	`, models.ReviewPending, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query review queue: %w", err)
+	}
+	defer rows.Close()
+
+	submissions := []models.ChallengeSubmission{}
+	for rows.Next() {
+		submission := models.ChallengeSubmission{Status: models.ReviewPending}
+		var assets []byte
+		if err := rows.Scan(
+			&submission.ID, &submission.UserID, &submission.ChallengeID, &submission.Feedback,
+			&submission.SubmittedAt, &submission.Writeup, &assets,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan submission: %w", err)
+		}
+		submission.Assets = decodeDesignAssets(assets)
+		submissions = append(submissions, submission)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read review queue: %w", err)
+	}
+
+	return submissions, nil
+}
+
+// GetDesignAsset reads an asset of a design submission. Only the submitter and reviewers may
+// read it; anyone else gets ErrSubmissionNotFound.
+func (s *ChallengeService) GetDesignAsset(submissionID, assetID, userID uuid.UUID, reviewer bool) (*models.DesignAsset, []byte, error) {
+	var ownerID uuid.UUID
+	var assets []byte
+	err := s.db.QueryRow(`
+		SELECT user_id, assets FROM challenge_submissions WHERE id = $1
+	`, submissionID).Scan(&ownerID, &assets)
+	if err == sql.ErrNoRows || (err == nil && ownerID != userID && !reviewer) {
+		return nil, nil, ErrSubmissionNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query submission: %w", err)
+	}
+
+	var stored []storedDesignAsset
+	if len(assets) > 0 {
+		if err := json.Unmarshal(assets, &stored); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse submission assets: %w", err)
+		}
+	}
+	for _, asset := range stored {
+		if asset.ID != assetID {
+			continue
+		}
+		if s.blobs == nil {
+			return nil, nil, ErrDesignAssetsUnavailable
+		}
+		data, err := s.blobs.Get(context.Background(), asset.Key)
+		if err != nil {
+			submissionBlobs.WithLabelValues("design_asset", "get", "error").Inc()
+			return nil, nil, fmt.Errorf("failed to load asset %s: %w", asset.Key, err)
+		}
+		submissionBlobs.WithLabelValues("design_asset", "get", "success").Inc()
+		return &asset.DesignAsset, data, nil
+	}
+	return nil, nil, ErrAssetNotFound
+}
+
+// publicDesignAssets drops the blob store keys from stored assets
+func publicDesignAssets(stored []storedDesignAsset) []models.DesignAsset {
+	if len(stored) == 0 {
+		return nil
+	}
+	assets := make([]models.DesignAsset, 0, len(stored))
+	for _, asset := range stored {
+		assets = append(assets, asset.DesignAsset)
+	}
+	return assets
+}
+
+// decodeDesignAssets reads challenge_submissions.assets; NULL and unreadable values read as nil
+func decodeDesignAssets(raw []byte) []models.DesignAsset {
+	if len(raw) == 0 {
+		return nil
+	}
+	var stored []storedDesignAsset
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		log.Printf("Ignoring unreadable submission assets: %v", err)
+		return nil
+	}
+	return publicDesignAssets(stored)
+}
+
+// decodeDesignEvaluation reads challenge_submissions.evaluation; NULL and unreadable values read as nil
+func decodeDesignEvaluation(raw []byte) *models.DesignEvaluation {
+	if len(raw) == 0 {
+		return nil
+	}
+	var evaluation models.DesignEvaluation
+	if err := json.Unmarshal(raw, &evaluation); err != nil {
+		log.Printf("Ignoring unreadable submission evaluation: %v", err)
+		return nil
+	}
+	return &evaluation
+}
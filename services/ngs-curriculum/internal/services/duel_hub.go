@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// duelEventsChannel is the Postgres channel duel changes are announced on, so every replica's
+// sockets hear about a change whichever replica made it
+const duelEventsChannel = "ngs_duel_events"
+
+// notifyDuel announces a change to a duel; Postgres delivers it when tx commits
+func notifyDuel(tx *sql.Tx, duelID uuid.UUID) error {
+	if _, err := tx.Exec(`SELECT pg_notify($1, $2)`, duelEventsChannel, duelID.String()); err != nil {
+		return fmt.Errorf("failed to announce duel change: %w", err)
+	}
+	return nil
+}
+
+// DuelHub fans duel change notifications out to the sockets watching each duel
+type DuelHub struct {
+	databaseURL string
+	mu          sync.Mutex
+	watchers    map[uuid.UUID]map[chan struct{}]struct{}
+	running     sync.WaitGroup
+}
+
+// NewDuelHub creates a hub that listens for duel changes on databaseURL once started
+func NewDuelHub(databaseURL string) *DuelHub {
+	return &DuelHub{
+		databaseURL: databaseURL,
+		watchers:    make(map[uuid.UUID]map[chan struct{}]struct{}),
+	}
+}
+
+// Subscribe returns a channel that receives a signal whenever the duel changes, and a function
+// to stop watching. Signals coalesce, so a slow watcher re-reads the duel once rather than
+// falling behind.
+func (h *DuelHub) Subscribe(duelID uuid.UUID) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	h.mu.Lock()
+	if h.watchers[duelID] == nil {
+		h.watchers[duelID] = make(map[chan struct{}]struct{})
+	}
+	h.watchers[duelID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.watchers[duelID], ch)
+		if len(h.watchers[duelID]) == 0 {
+			delete(h.watchers, duelID)
+		}
+	}
+}
+
+// Publish signals every watcher of a duel without blocking
+func (h *DuelHub) Publish(duelID uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.watchers[duelID] {
+		signal(ch)
+	}
+}
+
+// publishAll signals every watcher, after a reconnect may have dropped notifications
+func (h *DuelHub) publishAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, watchers := range h.watchers {
+		for ch := range watchers {
+			signal(ch)
+		}
+	}
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// Start listens for duel change notifications until ctx is done
+func (h *DuelHub) Start(ctx context.Context) {
+	listener := pq.NewListener(h.databaseURL, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Duel event listener: %v", err)
+		}
+	})
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	h.running.Add(1)
+	go func() {
+		defer h.running.Done()
+		// Listen waits for the first connection, so it runs here rather than holding up startup
+		if err := listener.Listen(duelEventsChannel); err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to listen for duel events, sockets will only update on their countdown: %v", err)
+			}
+			return
+		}
+		// Notify is closed once the listener is
+		for n := range listener.Notify {
+			// A nil notification means the connection was re-established
+			if n == nil {
+				h.publishAll()
+				continue
+			}
+			duelID, err := uuid.Parse(n.Extra)
+			if err != nil {
+				log.Printf("Ignoring malformed duel event %q", n.Extra)
+				continue
+			}
+			h.Publish(duelID)
+		}
+	}()
+}
+
+// Wait blocks until the listener started by Start has closed
+func (h *DuelHub) Wait() {
+	h.running.Wait()
+}
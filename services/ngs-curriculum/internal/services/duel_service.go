@@ -0,0 +1,517 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultDuelRating = 1200
+	duelRatingK       = 32.0
+	// duelQueueWindow is how long a waiting duel stays open to matchmaking
+	duelQueueWindow = 15 * time.Minute
+)
+
+var (
+	ErrDuelNotFound       = errors.New("duel not found")
+	ErrNotDuelParticipant = errors.New("user is not a participant in this duel")
+	ErrDuelNotActive      = errors.New("duel is not active")
+	ErrNoDuelChallenge    = errors.New("no duel challenges available for this level")
+)
+
+type DuelService struct {
+	db               *database.DB
+	config           *config.Config
+	challengeService *ChallengeService
+	clock            Clock
+}
+
+func NewDuelService(db *database.DB, cfg *config.Config, challengeService *ChallengeService, clock Clock) *DuelService {
+	return &DuelService{
+		db:               db,
+		config:           cfg,
+		challengeService: challengeService,
+		clock:            clock,
+	}
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+const duelColumns = `id, challenge_id, level_number, player_one_id, player_two_id, status,
+	winner_id, time_limit_seconds, started_at, ends_at, completed_at, created_at`
+
+// scanDuel scans duelColumns, followed by any extra selected columns
+func scanDuel(row rowScanner) (*models.Duel, error) {
+	var d models.Duel
+	var playerTwoID, winnerID uuid.NullUUID
+	var startedAt, endsAt, completedAt sql.NullTime
+
+	err := row.Scan(
+		&d.ID, &d.ChallengeID, &d.LevelNumber, &d.PlayerOneID, &playerTwoID, &d.Status,
+		&winnerID, &d.TimeLimitSeconds, &startedAt, &endsAt, &completedAt, &d.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if playerTwoID.Valid {
+		d.PlayerTwoID = &playerTwoID.UUID
+	}
+	if winnerID.Valid {
+		d.WinnerID = &winnerID.UUID
+	}
+	if startedAt.Valid {
+		d.StartedAt = &startedAt.Time
+	}
+	if endsAt.Valid {
+		d.EndsAt = &endsAt.Time
+	}
+	if completedAt.Valid {
+		d.CompletedAt = &completedAt.Time
+	}
+
+	return &d, nil
+}
+
+// JoinQueue matches the user against a waiting opponent at the same level,
+// or opens a new waiting duel if nobody is available
+func (s *DuelService) JoinQueue(userID uuid.UUID) (*models.DuelState, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// One join per user at a time, so two requests cannot both find no open duel and queue twice
+	if err := database.XactLock(tx, "ngs_duel_queue:"+userID.String()); err != nil {
+		return nil, err
+	}
+
+	// Re-use an open duel rather than queueing twice
+	existing, err := scanDuel(tx.QueryRow(`
+		SELECT `+duelColumns+`
+		FROM duels
+		WHERE (player_one_id = $1 OR player_two_id = $1) AND status IN ('waiting', 'active')
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, userID))
+	if err == nil {
+		tx.Rollback()
+		return s.GetDuel(existing.ID, userID)
+	} else if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to check open duels: %w", err)
+	}
+
+	var level int
+	err = tx.QueryRow(`SELECT current_level FROM user_progress WHERE user_id = $1`, userID).Scan(&level)
+	if err == sql.ErrNoRows {
+		level = 1
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get user level: %w", err)
+	}
+
+	// Look for a recent waiting duel at the same level whose challenge suits the user's age band,
+	// which may differ from the opponent's who picked it
+	now := s.clock.Now()
+	var duelID uuid.UUID
+	err = tx.QueryRow(`
+		SELECT d.id FROM duels d
+		JOIN challenges c ON c.id = d.challenge_id
+		WHERE d.level_number = $1 AND d.status = 'waiting' AND d.player_one_id <> $2
+		  AND d.created_at > $3
+		  AND `+contentAgeFilterSQL("c.min_age_band", "$2")+`
+		ORDER BY d.created_at ASC
+		LIMIT 1
+		FOR UPDATE OF d SKIP LOCKED
+	`, level, userID, now.Add(-duelQueueWindow)).Scan(&duelID)
+
+	if err == nil {
+		_, err = tx.Exec(`
+			UPDATE duels
+			SET player_two_id = $1, status = 'active', started_at = $3,
+			    ends_at = $3::timestamp + make_interval(secs => time_limit_seconds)
+			WHERE id = $2
+		`, userID, duelID, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start duel: %w", err)
+		}
+		if err := notifyDuel(tx, duelID); err != nil {
+			return nil, err
+		}
+		log.Printf("User %s matched into duel %s (level %d)", userID, duelID, level)
+	} else if err == sql.ErrNoRows {
+		var challengeID uuid.UUID
+		err = tx.QueryRow(`
+			SELECT id FROM challenges
+			WHERE level_id = $1 AND is_active = true AND challenge_type = 'coding'
+			  AND `+contentAgeFilterSQL("min_age_band", "$2")+`
+			ORDER BY random()
+			LIMIT 1
+		`, level, userID).Scan(&challengeID)
+		if err == sql.ErrNoRows {
+			return nil, ErrNoDuelChallenge
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to pick duel challenge: %w", err)
+		}
+
+		err = tx.QueryRow(`
+			INSERT INTO duels (challenge_id, level_number, player_one_id, time_limit_seconds, created_at)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id
+		`, challengeID, level, userID, s.config.DuelTimeLimitSeconds, now).Scan(&duelID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create duel: %w", err)
+		}
+		log.Printf("User %s opened duel %s (level %d)", userID, duelID, level)
+	} else {
+		return nil, fmt.Errorf("failed to search for opponent: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return s.GetDuel(duelID, userID)
+}
+
+// GetDuel returns the duel state for a participant, settling it first if the countdown has run out
+func (s *DuelService) GetDuel(duelID uuid.UUID, userID uuid.UUID) (*models.DuelState, error) {
+	if err := s.expireIfDue(duelID); err != nil {
+		return nil, err
+	}
+
+	state := models.DuelState{ServerTime: s.clock.Now()}
+
+	d, err := scanDuel(s.db.QueryRow(`
+		SELECT `+duelColumns+`
+		FROM duels
+		WHERE id = $1
+	`, duelID))
+	if err == sql.ErrNoRows {
+		return nil, ErrDuelNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query duel: %w", err)
+	}
+
+	if !isDuelParticipant(d, userID) {
+		return nil, ErrNotDuelParticipant
+	}
+
+	state.Duel = *d
+	if d.Status == "active" && d.EndsAt != nil {
+		if remaining := d.EndsAt.Sub(state.ServerTime).Seconds(); remaining > 0 {
+			state.SecondsRemaining = int(math.Ceil(remaining))
+		}
+	}
+
+	// The challenge is only revealed once the countdown has started
+	if d.Status != "waiting" {
+		challenge, err := s.challengeService.GetChallenge(d.ChallengeID)
+		if err != nil {
+			return nil, err
+		}
+		challenge.SolutionTemplate = ""
+		state.Challenge = challenge
+	}
+
+	return &state, nil
+}
+
+// SubmitDuel grades a duel attempt; the first passing submission wins the duel
+func (s *DuelService) SubmitDuel(duelID uuid.UUID, userID uuid.UUID, submissionCode string) (*models.DuelSubmitResult, error) {
+	if max := s.config.SubmissionMaxCodeBytes; max > 0 && len(submissionCode) > max {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrSubmissionTooLarge, len(submissionCode), max)
+	}
+	if err := s.expireIfDue(duelID); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the duel so simultaneous passing submissions cannot both win
+	duel, err := scanDuel(tx.QueryRow(`
+		SELECT `+duelColumns+`
+		FROM duels
+		WHERE id = $1
+		FOR UPDATE
+	`, duelID))
+	if err == sql.ErrNoRows {
+		return nil, ErrDuelNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query duel: %w", err)
+	}
+
+	if !isDuelParticipant(duel, userID) {
+		return nil, ErrNotDuelParticipant
+	}
+	if duel.Status != "active" {
+		return nil, ErrDuelNotActive
+	}
+
+	challenge, err := s.challengeService.GetChallenge(duel.ChallengeID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, passed, score := s.challengeService.validateSubmission(submissionCode, challenge)
+
+	_, err = tx.Exec(`
+		INSERT INTO duel_submissions (duel_id, user_id, submission_code, passed, score)
+		VALUES ($1, $2, $3, $4, $5)
+	`, duelID, userID, submissionCode, passed, score)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record duel submission: %w", err)
+	}
+
+	won := false
+	if passed {
+		_, err = tx.Exec(`
+			UPDATE duels
+			SET status = 'completed', winner_id = $1, completed_at = $3
+			WHERE id = $2
+		`, userID, duelID, s.clock.Now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to complete duel: %w", err)
+		}
+
+		if err := s.updateRatings(tx, userID, duelOpponent(duel, userID), 1.0); err != nil {
+			return nil, err
+		}
+		if err := notifyDuel(tx, duelID); err != nil {
+			return nil, err
+		}
+		won = true
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if won {
+		log.Printf("User %s won duel %s (score: %d)", userID, duelID, score)
+	}
+
+	state, err := s.GetDuel(duelID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.DuelSubmitResult{
+		Passed: passed,
+		Score:  score,
+		Won:    won,
+		Duel:   *state,
+	}, nil
+}
+
+// CancelDuel withdraws a duel that has not been matched yet
+func (s *DuelService) CancelDuel(duelID uuid.UUID, userID uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE duels
+		SET status = 'cancelled', completed_at = $3
+		WHERE id = $1 AND player_one_id = $2 AND status = 'waiting'
+	`, duelID, userID, s.clock.Now())
+	if err != nil {
+		return fmt.Errorf("failed to cancel duel: %w", err)
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrDuelNotActive
+	}
+	if err := notifyDuel(tx, duelID); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetDuelHistory retrieves a user's finished duels, newest first
+func (s *DuelService) GetDuelHistory(userID uuid.UUID, limit int) ([]models.Duel, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+duelColumns+`
+		FROM duels
+		WHERE (player_one_id = $1 OR player_two_id = $1)
+		  AND status IN ('completed', 'expired')
+		ORDER BY completed_at DESC, id
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query duel history: %w", err)
+	}
+	defer rows.Close()
+
+	duels := []models.Duel{}
+	for rows.Next() {
+		d, err := scanDuel(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan duel: %w", err)
+		}
+		duels = append(duels, *d)
+	}
+
+	return duels, nil
+}
+
+// GetRating retrieves a user's duel rating, defaulting for users who have never dueled
+func (s *DuelService) GetRating(userID uuid.UUID) (*models.DuelRating, error) {
+	rating := models.DuelRating{UserID: userID, Rating: defaultDuelRating}
+
+	err := s.db.QueryRow(`
+		SELECT rating, wins, losses, draws, updated_at
+		FROM duel_ratings
+		WHERE user_id = $1
+	`, userID).Scan(&rating.Rating, &rating.Wins, &rating.Losses, &rating.Draws, &rating.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get duel rating: %w", err)
+	}
+
+	return &rating, nil
+}
+
+// expireIfDue settles an active duel whose countdown has elapsed as a draw
+func (s *DuelService) expireIfDue(duelID uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var playerOne uuid.UUID
+	var playerTwo uuid.NullUUID
+	err = tx.QueryRow(`
+		UPDATE duels
+		SET status = 'expired', completed_at = $2
+		WHERE id = $1 AND status = 'active' AND ends_at <= $2
+		RETURNING player_one_id, player_two_id
+	`, duelID, s.clock.Now()).Scan(&playerOne, &playerTwo)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to expire duel: %w", err)
+	}
+
+	if playerTwo.Valid {
+		if err := s.updateRatings(tx, playerOne, playerTwo.UUID, 0.5); err != nil {
+			return err
+		}
+	}
+	if err := notifyDuel(tx, duelID); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Duel %s expired without a winner", duelID)
+	return nil
+}
+
+// updateRatings applies an Elo update; scoreA is 1 for a win by A, 0.5 for a draw
+func (s *DuelService) updateRatings(tx *sql.Tx, userA, userB uuid.UUID, scoreA float64) error {
+	_, err := tx.Exec(`
+		INSERT INTO duel_ratings (user_id, rating)
+		VALUES ($1, $3), ($2, $3)
+		ON CONFLICT (user_id) DO NOTHING
+	`, userA, userB, defaultDuelRating)
+	if err != nil {
+		return fmt.Errorf("failed to initialize duel ratings: %w", err)
+	}
+
+	var ratingA, ratingB int
+	if err := tx.QueryRow(`SELECT rating FROM duel_ratings WHERE user_id = $1 FOR UPDATE`, userA).Scan(&ratingA); err != nil {
+		return fmt.Errorf("failed to get duel rating: %w", err)
+	}
+	if err := tx.QueryRow(`SELECT rating FROM duel_ratings WHERE user_id = $1 FOR UPDATE`, userB).Scan(&ratingB); err != nil {
+		return fmt.Errorf("failed to get duel rating: %w", err)
+	}
+
+	newA, newB := CalculateElo(ratingA, ratingB, scoreA)
+
+	outcomeColumn := func(score float64) string {
+		switch {
+		case score > 0.5:
+			return "wins"
+		case score < 0.5:
+			return "losses"
+		default:
+			return "draws"
+		}
+	}
+
+	for _, update := range []struct {
+		userID uuid.UUID
+		rating int
+		column string
+	}{
+		{userA, newA, outcomeColumn(scoreA)},
+		{userB, newB, outcomeColumn(1 - scoreA)},
+	} {
+		_, err := tx.Exec(`
+			UPDATE duel_ratings
+			SET rating = $1, `+update.column+` = `+update.column+` + 1, updated_at = NOW()
+			WHERE user_id = $2
+		`, update.rating, update.userID)
+		if err != nil {
+			return fmt.Errorf("failed to update duel rating: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// CalculateElo returns the new ratings for A and B given A's result
+// (1 = A won, 0.5 = draw, 0 = A lost)
+func CalculateElo(ratingA, ratingB int, scoreA float64) (int, int) {
+	expectedA := 1 / (1 + math.Pow(10, float64(ratingB-ratingA)/400))
+	delta := duelRatingK * (scoreA - expectedA)
+
+	newA := int(math.Round(float64(ratingA) + delta))
+	newB := int(math.Round(float64(ratingB) - delta))
+	return newA, newB
+}
+
+func isDuelParticipant(d *models.Duel, userID uuid.UUID) bool {
+	return d.PlayerOneID == userID || (d.PlayerTwoID != nil && *d.PlayerTwoID == userID)
+}
+
+func duelOpponent(d *models.Duel, userID uuid.UUID) uuid.UUID {
+	if d.PlayerOneID == userID && d.PlayerTwoID != nil {
+		return *d.PlayerTwoID
+	}
+	return d.PlayerOneID
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/domain/xp"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidSimulation = errors.New("invalid simulation")
+
+type EconomyService struct {
+	db     *database.DB
+	config *config.Config
+}
+
+func NewEconomyService(db *database.DB, cfg *config.Config) *EconomyService {
+	return &EconomyService{
+		db:     db,
+		config: cfg,
+	}
+}
+
+// ValidateXPCurve checks a hypothetical economy: thresholds start at 0 and strictly increase,
+// and source awards are not negative
+func ValidateXPCurve(req models.XPCurveSimulationRequest) error {
+	if len(req.Thresholds) > 0 {
+		if req.Thresholds[0] != 0 {
+			return fmt.Errorf("%w: the first threshold must be 0", ErrInvalidSimulation)
+		}
+		for i := 1; i < len(req.Thresholds); i++ {
+			if req.Thresholds[i] <= req.Thresholds[i-1] {
+				return fmt.Errorf("%w: thresholds must strictly increase (level %d)", ErrInvalidSimulation, i+1)
+			}
+		}
+	}
+	for source, amount := range req.XPSources {
+		if amount < 0 {
+			return fmt.Errorf("%w: xp_sources.%s must not be negative", ErrInvalidSimulation, source)
+		}
+	}
+	return nil
+}
+
+// ProjectSourceXP rescales historical XP from a source to a new default award. Events keep their
+// size relative to the current default, so custom amounts scale proportionally; sources without
+// a current default are valued at the new award per event.
+func ProjectSourceXP(historicalXP int, events int, currentDefault int, newDefault int) int {
+	if currentDefault > 0 {
+		return int(math.Round(float64(historicalXP) * float64(newDefault) / float64(currentDefault)))
+	}
+	return events * newDefault
+}
+
+// SimulateXPCurve replays historical XP events under hypothetical thresholds and source awards
+// and compares the resulting level distribution with the current one
+func (s *EconomyService) SimulateXPCurve(req models.XPCurveSimulationRequest) (*models.XPCurveSimulation, error) {
+	if err := ValidateXPCurve(req); err != nil {
+		return nil, err
+	}
+
+	thresholds := req.Thresholds
+	if len(thresholds) == 0 {
+		thresholds = s.config.LevelUpXPThresholds
+	}
+	sources := make(map[string]int, len(s.config.XPSources)+len(req.XPSources))
+	for source, amount := range s.config.XPSources {
+		sources[source] = amount
+	}
+	for source, amount := range req.XPSources {
+		sources[source] = amount
+	}
+
+	// Compacted events, including those in archived partitions, are counted from their rollups
+	rows, err := s.db.Query(`
+		SELECT user_id, source, SUM(events), SUM(xp)
+		FROM (
+			SELECT user_id, COALESCE(source, '') AS source, COUNT(*) AS events, COALESCE(SUM(xp_awarded), 0) AS xp
+			FROM xp_events
+			WHERE compacted_at IS NULL
+			GROUP BY 1, 2
+			UNION ALL
+			SELECT user_id, source, event_count, xp_total
+			FROM xp_event_rollups
+		) e
+		GROUP BY user_id, source
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query XP events: %w", err)
+	}
+	defer rows.Close()
+
+	currentXP := make(map[uuid.UUID]int)
+	projectedXP := make(map[uuid.UUID]int)
+	for rows.Next() {
+		var userID uuid.UUID
+		var source string
+		var events, xp int
+		if err := rows.Scan(&userID, &source, &events, &xp); err != nil {
+			return nil, fmt.Errorf("failed to scan XP events: %w", err)
+		}
+
+		currentXP[userID] += xp
+		if amount, ok := req.XPSources[source]; ok {
+			projectedXP[userID] += ProjectSourceXP(xp, events, s.config.XPSources[source], amount)
+		} else {
+			projectedXP[userID] += xp
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read XP events: %w", err)
+	}
+
+	maxLevel := len(thresholds)
+	if len(s.config.LevelUpXPThresholds) > maxLevel {
+		maxLevel = len(s.config.LevelUpXPThresholds)
+	}
+	if maxLevel == 0 {
+		maxLevel = 1
+	}
+
+	sim := &models.XPCurveSimulation{
+		Users:        len(currentXP),
+		Thresholds:   thresholds,
+		XPSources:    sources,
+		Distribution: make([]models.LevelDistributionBucket, maxLevel),
+	}
+	for i := range sim.Distribution {
+		sim.Distribution[i].Level = i + 1
+	}
+
+	// Users start from the level their XP reaches today, and the projection applies the level-down
+	// policy to it, so users only lose levels under the strict policy
+	policy := levelPolicy(s.config)
+	currentTotal, projectedTotal := 0, 0
+	for userID, totalXP := range currentXP {
+		current := xp.LevelForXP(s.config.LevelUpXPThresholds, totalXP)
+		projected := policy.Level(thresholds, current, projectedXP[userID])
+		if projected > xp.LevelForXP(thresholds, projectedXP[userID]) {
+			sim.UsersProtected++
+		}
+
+		sim.Distribution[current-1].CurrentUsers++
+		sim.Distribution[projected-1].ProjectedUsers++
+		currentTotal += current
+		projectedTotal += projected
+
+		switch {
+		case projected > current:
+			sim.UsersPromoted++
+		case projected < current:
+			sim.UsersDemoted++
+		}
+		if policy.AgentUnlocked(current >= s.config.AgentUnlockLevel, projected, s.config.AgentUnlockLevel) {
+			sim.AgentUnlockUsers++
+		}
+	}
+
+	if sim.Users > 0 {
+		sim.CurrentMeanLevel = float64(currentTotal) / float64(sim.Users)
+		sim.ProjectedMeanLevel = float64(projectedTotal) / float64(sim.Users)
+	}
+
+	return sim, nil
+}
@@ -0,0 +1,471 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// examQuizWeight is the share of the total exam score taken by the quiz when a challenge is included
+	examQuizWeight = 0.6
+	// examGraceSeconds absorbs network latency on submissions right at the deadline
+	examGraceSeconds = 30
+)
+
+var (
+	ErrExamNotFound    = errors.New("exam not found")
+	ErrExamLevelLocked = errors.New("level must be reached before taking its exam")
+	ErrExamUnavailable = errors.New("no exam content available for this level")
+	ErrExamNotActive   = errors.New("exam is not in progress")
+	ErrExamExpired     = errors.New("exam time limit has passed")
+)
+
+// examQuestionKey is the stored question snapshot, including the answer key
+type examQuestionKey struct {
+	models.ExamQuestion
+	Answer      interface{} `json:"answer"`
+	Explanation string      `json:"explanation,omitempty"`
+}
+
+type ExamService struct {
+	db               *database.DB
+	config           *config.Config
+	challengeService *ChallengeService
+	clock            Clock
+}
+
+func NewExamService(db *database.DB, cfg *config.Config, challengeService *ChallengeService, clock Clock) *ExamService {
+	return &ExamService{
+		db:               db,
+		config:           cfg,
+		challengeService: challengeService,
+		clock:            clock,
+	}
+}
+
+// StartExam opens a timed exam for a level the user has reached, or resumes the open one
+func (s *ExamService) StartExam(userID uuid.UUID, levelNumber int) (*models.LevelExam, error) {
+	var currentLevel int
+	err := s.db.QueryRow(`SELECT current_level FROM user_progress WHERE user_id = $1`, userID).Scan(&currentLevel)
+	if err == sql.ErrNoRows {
+		currentLevel = 1
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get user level: %w", err)
+	}
+	if levelNumber > currentLevel {
+		return nil, ErrExamLevelLocked
+	}
+
+	now := s.clock.Now()
+	var openExamID uuid.UUID
+	err = s.db.QueryRow(`
+		SELECT id FROM level_exams
+		WHERE user_id = $1 AND level_number = $2 AND status = 'in_progress' AND ends_at > $3
+		ORDER BY started_at DESC
+		LIMIT 1
+	`, userID, levelNumber, now).Scan(&openExamID)
+	if err == nil {
+		return s.GetExam(openExamID, userID)
+	} else if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to check open exams: %w", err)
+	}
+
+	questions, err := s.collectQuestions(levelNumber, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var challengeID uuid.NullUUID
+	err = s.db.QueryRow(`
+		SELECT id FROM challenges
+		WHERE level_id = $1 AND is_active = true AND `+contentAgeFilterSQL("min_age_band", "$2")+`
+		ORDER BY random()
+		LIMIT 1
+	`, levelNumber, userID).Scan(&challengeID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to pick exam challenge: %w", err)
+	}
+
+	if len(questions) == 0 && !challengeID.Valid {
+		return nil, ErrExamUnavailable
+	}
+
+	questionsJSON, err := json.Marshal(questions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal exam questions: %w", err)
+	}
+
+	timeLimit := s.config.ExamTimeLimitMinutes * 60
+	endsAt := now.Add(time.Duration(timeLimit) * time.Second)
+	var examID uuid.UUID
+	err = s.db.QueryRow(`
+		INSERT INTO level_exams (user_id, level_number, questions, challenge_id, time_limit_seconds, started_at, ends_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, userID, levelNumber, questionsJSON, challengeID, timeLimit, now, endsAt).Scan(&examID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exam: %w", err)
+	}
+
+	log.Printf("User %s started level %d exam %s (%d questions)", userID, levelNumber, examID, len(questions))
+	return s.GetExam(examID, userID)
+}
+
+// collectQuestions samples assessment checks from the level's generated lessons suitable for the user
+func (s *ExamService) collectQuestions(levelNumber int, userID uuid.UUID) ([]examQuestionKey, error) {
+	rows, err := s.db.Query(`
+		SELECT id, metadata
+		FROM lessons
+		WHERE level_id = $1 AND metadata -> 'assessment' -> 'checks' IS NOT NULL
+		  AND `+contentAgeFilterSQL("min_age_band", "$2")+`
+		ORDER BY lesson_order
+	`, levelNumber, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exam lessons: %w", err)
+	}
+	defer rows.Close()
+
+	pool := []examQuestionKey{}
+	for rows.Next() {
+		var lessonID uuid.UUID
+		var metadata json.RawMessage
+		if err := rows.Scan(&lessonID, &metadata); err != nil {
+			return nil, fmt.Errorf("failed to scan exam lesson: %w", err)
+		}
+
+		var structured struct {
+			Assessment struct {
+				Checks []struct {
+					Type        string      `json:"type"`
+					Question    string      `json:"question"`
+					Choices     []string    `json:"choices"`
+					Answer      interface{} `json:"answer"`
+					Explanation string      `json:"explanation"`
+				} `json:"checks"`
+			} `json:"assessment"`
+		}
+		if err := json.Unmarshal(metadata, &structured); err != nil {
+			log.Printf("Skipping lesson %s with unparseable assessment: %v", lessonID, err)
+			continue
+		}
+
+		for _, check := range structured.Assessment.Checks {
+			if check.Question == "" || check.Answer == nil {
+				continue
+			}
+			pool = append(pool, examQuestionKey{
+				ExamQuestion: models.ExamQuestion{
+					LessonID: lessonID,
+					Type:     check.Type,
+					Question: check.Question,
+					Choices:  check.Choices,
+				},
+				Answer:      check.Answer,
+				Explanation: check.Explanation,
+			})
+		}
+	}
+
+	rand.Shuffle(len(pool), func(i, j int) { pool[i], pool[j] = pool[j], pool[i] })
+	if len(pool) > s.config.ExamQuestionCount {
+		pool = pool[:s.config.ExamQuestionCount]
+	}
+	for i := range pool {
+		pool[i].Index = i
+	}
+
+	return pool, nil
+}
+
+// queryRower is satisfied by both *database.DB and *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// loadExam reads an exam row together with its answer key, with the seconds remaining by the service clock
+func (s *ExamService) loadExam(q queryRower, examID uuid.UUID, forUpdate bool) (*models.LevelExam, []examQuestionKey, uuid.NullUUID, error) {
+	query := `
+		SELECT id, user_id, level_number, status, questions, challenge_id, time_limit_seconds,
+		       started_at, ends_at, submitted_at, quiz_score, challenge_score, total_score,
+		       COALESCE(passed, false)
+		FROM level_exams
+		WHERE id = $1`
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	var exam models.LevelExam
+	var questionsJSON json.RawMessage
+	var challengeID uuid.NullUUID
+	var submittedAt sql.NullTime
+	var quizScore, challengeScore, totalScore sql.NullInt64
+
+	err := q.QueryRow(query, examID).Scan(
+		&exam.ID, &exam.UserID, &exam.LevelNumber, &exam.Status, &questionsJSON, &challengeID,
+		&exam.TimeLimitSeconds, &exam.StartedAt, &exam.EndsAt, &submittedAt,
+		&quizScore, &challengeScore, &totalScore, &exam.Passed,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil, challengeID, ErrExamNotFound
+	}
+	if err != nil {
+		return nil, nil, challengeID, fmt.Errorf("failed to query exam: %w", err)
+	}
+
+	var keys []examQuestionKey
+	if err := json.Unmarshal(questionsJSON, &keys); err != nil {
+		return nil, nil, challengeID, fmt.Errorf("failed to parse exam questions: %w", err)
+	}
+
+	exam.Questions = make([]models.ExamQuestion, len(keys))
+	for i, k := range keys {
+		exam.Questions[i] = k.ExamQuestion
+	}
+	if submittedAt.Valid {
+		exam.SubmittedAt = &submittedAt.Time
+	}
+	if quizScore.Valid {
+		v := int(quizScore.Int64)
+		exam.QuizScore = &v
+	}
+	if challengeScore.Valid {
+		v := int(challengeScore.Int64)
+		exam.ChallengeScore = &v
+	}
+	if totalScore.Valid {
+		v := int(totalScore.Int64)
+		exam.TotalScore = &v
+	}
+	if remaining := exam.EndsAt.Sub(s.clock.Now()).Seconds(); exam.Status == "in_progress" && remaining > 0 {
+		exam.SecondsRemaining = int(math.Ceil(remaining))
+	}
+
+	return &exam, keys, challengeID, nil
+}
+
+// GetExam returns an exam for its owner without answer keys
+func (s *ExamService) GetExam(examID uuid.UUID, userID uuid.UUID) (*models.LevelExam, error) {
+	exam, _, challengeID, err := s.loadExam(s.db, examID, false)
+	if err != nil {
+		return nil, err
+	}
+	if exam.UserID != userID {
+		return nil, ErrExamNotFound
+	}
+
+	if challengeID.Valid {
+		challenge, err := s.challengeService.GetChallenge(challengeID.UUID)
+		if err != nil {
+			return nil, err
+		}
+		challenge.SolutionTemplate = ""
+		exam.Challenge = challenge
+	}
+
+	return exam, nil
+}
+
+// SubmitExam grades the quiz answers and challenge solution, certifying the level on a pass
+func (s *ExamService) SubmitExam(examID uuid.UUID, userID uuid.UUID, req models.SubmitExamRequest) (*models.ExamResult, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	exam, keys, challengeID, err := s.loadExam(tx, examID, true)
+	if err != nil {
+		return nil, err
+	}
+	if exam.UserID != userID {
+		return nil, ErrExamNotFound
+	}
+	if exam.Status != "in_progress" {
+		return nil, ErrExamNotActive
+	}
+
+	now := s.clock.Now()
+	if now.After(exam.EndsAt.Add(examGraceSeconds * time.Second)) {
+		if _, err := tx.Exec(`UPDATE level_exams SET status = 'expired' WHERE id = $1`, examID); err != nil {
+			return nil, fmt.Errorf("failed to expire exam: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return nil, ErrExamExpired
+	}
+
+	// Grade quiz questions
+	results := make([]models.ExamQuestionResult, 0, len(keys))
+	correct := 0
+	for _, k := range keys {
+		given := req.Answers[strconv.Itoa(k.Index)]
+		ok := ExamAnswerMatches(k.Answer, given, k.Choices)
+		if ok {
+			correct++
+		}
+		results = append(results, models.ExamQuestionResult{
+			Index:       k.Index,
+			Correct:     ok,
+			Given:       given,
+			Answer:      k.Answer,
+			Explanation: k.Explanation,
+		})
+	}
+
+	var quizScore, challengeScore sql.NullInt64
+	if len(keys) > 0 {
+		quizScore = sql.NullInt64{Int64: int64(correct * 100 / len(keys)), Valid: true}
+	}
+
+	// Grade the challenge
+	if challengeID.Valid {
+		challenge, err := s.challengeService.GetChallenge(challengeID.UUID)
+		if err != nil {
+			return nil, err
+		}
+		score := 0
+		if req.SubmissionCode != "" {
+			_, _, score = s.challengeService.validateSubmission(req.SubmissionCode, challenge)
+		}
+		challengeScore = sql.NullInt64{Int64: int64(score), Valid: true}
+	}
+
+	var total int
+	switch {
+	case quizScore.Valid && challengeScore.Valid:
+		total = int(math.Round(float64(quizScore.Int64)*examQuizWeight + float64(challengeScore.Int64)*(1-examQuizWeight)))
+	case quizScore.Valid:
+		total = int(quizScore.Int64)
+	default:
+		total = int(challengeScore.Int64)
+	}
+	passed := total >= s.config.ExamPassScore
+
+	resultsJSON, _ := json.Marshal(results)
+	_, err = tx.Exec(`
+		UPDATE level_exams
+		SET status = 'submitted', submitted_at = $6, quiz_score = $1, challenge_score = $2,
+		    total_score = $3, passed = $4, results = $5
+		WHERE id = $7
+	`, quizScore, challengeScore, total, passed, resultsJSON, now, examID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record exam result: %w", err)
+	}
+
+	result := &models.ExamResult{Results: results}
+
+	if passed {
+		var cert models.LevelCertification
+		var certExamID uuid.NullUUID
+		err = tx.QueryRow(`
+			INSERT INTO level_certifications (user_id, level_number, exam_id, score, certified_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (user_id, level_number) DO UPDATE
+			SET exam_id = EXCLUDED.exam_id, score = EXCLUDED.score, certified_at = EXCLUDED.certified_at
+			WHERE level_certifications.score < EXCLUDED.score
+			RETURNING id, user_id, level_number, exam_id, score, certified_at
+		`, userID, exam.LevelNumber, examID, total, now).Scan(
+			&cert.ID, &cert.UserID, &cert.LevelNumber, &certExamID, &cert.Score, &cert.CertifiedAt,
+		)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to record certification: %w", err)
+		}
+		if err == nil {
+			if certExamID.Valid {
+				cert.ExamID = &certExamID.UUID
+			}
+			result.Certification = &cert
+
+			achievementJSON, _ := json.Marshal(map[string]interface{}{
+				"level": exam.LevelNumber,
+				"score": total,
+			})
+			_, err = tx.Exec(`
+				INSERT INTO achievements (user_id, achievement_type, achievement_data)
+				VALUES ($1, $2, $3)
+			`, userID, "level_certified", achievementJSON)
+			if err != nil {
+				log.Printf("Warning: Failed to record level certification achievement: %v", err)
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("User %s submitted level %d exam %s (score: %d, passed: %t)", userID, exam.LevelNumber, examID, total, passed)
+
+	updated, err := s.GetExam(examID, userID)
+	if err != nil {
+		return nil, err
+	}
+	result.Exam = *updated
+	return result, nil
+}
+
+// GetCertifications lists the levels a user has certified
+func (s *ExamService) GetCertifications(userID uuid.UUID) ([]models.LevelCertification, error) {
+	rows, err := s.db.Query(`
+		SELECT id, user_id, level_number, exam_id, score, certified_at
+		FROM level_certifications
+		WHERE user_id = $1
+		ORDER BY level_number
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query certifications: %w", err)
+	}
+	defer rows.Close()
+
+	certifications := []models.LevelCertification{}
+	for rows.Next() {
+		var cert models.LevelCertification
+		var examID uuid.NullUUID
+		if err := rows.Scan(&cert.ID, &cert.UserID, &cert.LevelNumber, &examID, &cert.Score, &cert.CertifiedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan certification: %w", err)
+		}
+		if examID.Valid {
+			cert.ExamID = &examID.UUID
+		}
+		certifications = append(certifications, cert)
+	}
+
+	return certifications, nil
+}
+
+// ExamAnswerMatches compares a learner answer to the answer key. Answers match
+// case-insensitively; a numeric key on a multiple-choice question also accepts the choice text.
+func ExamAnswerMatches(expected interface{}, given interface{}, choices []string) bool {
+	if given == nil {
+		return false
+	}
+
+	normalize := func(v interface{}) string {
+		return strings.ToLower(strings.TrimSpace(fmt.Sprint(v)))
+	}
+
+	if normalize(expected) == normalize(given) {
+		return true
+	}
+
+	if idx, ok := expected.(float64); ok && idx == math.Trunc(idx) && int(idx) >= 0 && int(idx) < len(choices) {
+		return normalize(choices[int(idx)]) == normalize(given)
+	}
+
+	return false
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ExperimentActive    = "active"
+	ExperimentPaused    = "paused"
+	ExperimentConcluded = "concluded"
+)
+
+var (
+	ErrExperimentNotFound = errors.New("experiment not found")
+	ErrInvalidExperiment  = errors.New("invalid experiment")
+)
+
+var experimentKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,99}$`)
+
+type ExperimentService struct {
+	db *database.DB
+}
+
+func NewExperimentService(db *database.DB) *ExperimentService {
+	return &ExperimentService{
+		db: db,
+	}
+}
+
+// AssignVariant deterministically buckets a user into one of the weighted variants. The same
+// experiment key and user always map to the same variant, and different experiments are
+// bucketed independently. Returns "" when there are no variants with positive weight.
+func AssignVariant(experimentKey string, userID uuid.UUID, variants []models.ExperimentVariant) string {
+	total := 0
+	for _, v := range variants {
+		if v.Weight > 0 {
+			total += v.Weight
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(experimentKey + ":" + userID.String()))
+	bucket := int(binary.BigEndian.Uint64(sum[:8]) % uint64(total))
+
+	for _, v := range variants {
+		if v.Weight <= 0 {
+			continue
+		}
+		if bucket < v.Weight {
+			return v.Name
+		}
+		bucket -= v.Weight
+	}
+	return ""
+}
+
+// ValidateExperiment checks an experiment definition before it is saved
+func ValidateExperiment(req models.UpsertExperimentRequest) error {
+	if !experimentKeyPattern.MatchString(req.Key) {
+		return fmt.Errorf("%w: key must be lowercase letters, digits, '_', '.' or '-'", ErrInvalidExperiment)
+	}
+
+	switch req.Status {
+	case "", ExperimentActive, ExperimentPaused, ExperimentConcluded:
+	default:
+		return fmt.Errorf("%w: status must be active, paused or concluded", ErrInvalidExperiment)
+	}
+
+	if len(req.Variants) < 2 {
+		return fmt.Errorf("%w: at least two variants are required", ErrInvalidExperiment)
+	}
+	seen := make(map[string]bool, len(req.Variants))
+	for _, v := range req.Variants {
+		if v.Name == "" {
+			return fmt.Errorf("%w: variant names are required", ErrInvalidExperiment)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("%w: duplicate variant %q", ErrInvalidExperiment, v.Name)
+		}
+		if v.Weight <= 0 {
+			return fmt.Errorf("%w: variant %q must have a positive weight", ErrInvalidExperiment, v.Name)
+		}
+		seen[v.Name] = true
+	}
+
+	return nil
+}
+
+const experimentColumns = `key, COALESCE(description, ''), variants, status, created_at, updated_at`
+
+func scanExperiment(row rowScanner) (*models.Experiment, error) {
+	var exp models.Experiment
+	var variantsJSON []byte
+	err := row.Scan(&exp.Key, &exp.Description, &variantsJSON, &exp.Status, &exp.CreatedAt, &exp.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(variantsJSON, &exp.Variants); err != nil {
+		return nil, fmt.Errorf("failed to parse experiment variants: %w", err)
+	}
+	return &exp, nil
+}
+
+// GetExperiment returns an experiment definition
+func (s *ExperimentService) GetExperiment(key string) (*models.Experiment, error) {
+	exp, err := scanExperiment(s.db.QueryRow(`SELECT `+experimentColumns+` FROM experiments WHERE key = $1`, key))
+	if err == sql.ErrNoRows {
+		return nil, ErrExperimentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query experiment: %w", err)
+	}
+	return exp, nil
+}
+
+// UpsertExperiment creates or replaces an experiment definition. Changing variants or weights
+// on a running experiment reshuffles unexposed users only; logged exposures keep their variant.
+func (s *ExperimentService) UpsertExperiment(req models.UpsertExperimentRequest) (*models.Experiment, error) {
+	if err := ValidateExperiment(req); err != nil {
+		return nil, err
+	}
+	if req.Status == "" {
+		req.Status = ExperimentActive
+	}
+
+	variantsJSON, err := json.Marshal(req.Variants)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal experiment variants: %w", err)
+	}
+
+	exp, err := scanExperiment(s.db.QueryRow(`
+		INSERT INTO experiments (key, description, variants, status)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO UPDATE
+		SET description = EXCLUDED.description, variants = EXCLUDED.variants,
+		    status = EXCLUDED.status, updated_at = NOW()
+		RETURNING `+experimentColumns,
+		req.Key, req.Description, variantsJSON, req.Status,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save experiment: %w", err)
+	}
+
+	log.Printf("Experiment %s saved (%s, %d variants)", exp.Key, exp.Status, len(exp.Variants))
+	return exp, nil
+}
+
+// Assign returns the user's variant and logs an exposure. A user keeps the variant of their
+// first exposure. Paused and concluded experiments serve the first variant without logging.
+func (s *ExperimentService) Assign(key string, userID uuid.UUID) (*models.ExperimentAssignment, error) {
+	exp, err := s.GetExperiment(key)
+	if err != nil {
+		return nil, err
+	}
+
+	assignment := &models.ExperimentAssignment{
+		ExperimentKey: key,
+		UserID:        userID,
+		Active:        exp.Status == ExperimentActive,
+	}
+
+	if !assignment.Active {
+		assignment.Variant = exp.Variants[0].Name
+		return assignment, nil
+	}
+
+	variant := AssignVariant(key, userID, exp.Variants)
+	err = s.db.QueryRow(`
+		INSERT INTO experiment_exposures (experiment_key, user_id, variant)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (experiment_key, user_id) DO UPDATE
+		SET last_exposed_at = NOW(), exposure_count = experiment_exposures.exposure_count + 1
+		RETURNING variant
+	`, key, userID, variant).Scan(&assignment.Variant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to log experiment exposure: %w", err)
+	}
+
+	return assignment, nil
+}
+
+// GetResults rolls up outcomes per variant for users exposed to an experiment. Only activity
+// after each user's first exposure counts.
+func (s *ExperimentService) GetResults(key string) (*models.ExperimentResults, error) {
+	exp, err := s.GetExperiment(key)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT e.variant,
+		       COUNT(*),
+		       COALESCE(SUM((SELECT COALESCE(SUM(x.xp_awarded), 0) FROM xp_events x
+		                     WHERE x.user_id = e.user_id AND x.created_at >= e.first_exposed_at)), 0),
+		       COALESCE(SUM((SELECT COUNT(*) FROM lesson_completions lc
+		                     WHERE lc.user_id = e.user_id AND lc.completed_at >= e.first_exposed_at)), 0),
+		       COALESCE(SUM((SELECT COUNT(DISTINCT cs.challenge_id) FROM challenge_submissions cs
+		                     WHERE cs.user_id = e.user_id AND cs.passed AND cs.submitted_at >= e.first_exposed_at)), 0),
+		       COUNT(*) FILTER (WHERE EXISTS (
+		           SELECT 1 FROM xp_events x
+		           WHERE x.user_id = e.user_id AND x.created_at >= e.first_exposed_at + INTERVAL '7 days'))
+		FROM experiment_exposures e
+		WHERE e.experiment_key = $1
+		GROUP BY e.variant
+	`, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query experiment results: %w", err)
+	}
+	defer rows.Close()
+
+	byVariant := make(map[string]models.VariantMetrics)
+	for rows.Next() {
+		var m models.VariantMetrics
+		if err := rows.Scan(&m.Variant, &m.ExposedUsers, &m.TotalXP, &m.LessonsCompleted, &m.ChallengesPassed, &m.RetainedUsers7d); err != nil {
+			return nil, fmt.Errorf("failed to scan experiment results: %w", err)
+		}
+		if m.ExposedUsers > 0 {
+			m.AvgXPPerUser = float64(m.TotalXP) / float64(m.ExposedUsers)
+		}
+		byVariant[m.Variant] = m
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read experiment results: %w", err)
+	}
+
+	// Report variants in definition order, including ones without exposures yet, followed by
+	// any variants that have since been removed from the definition
+	results := &models.ExperimentResults{Experiment: *exp, Variants: []models.VariantMetrics{}}
+	for _, v := range exp.Variants {
+		m, ok := byVariant[v.Name]
+		if !ok {
+			m = models.VariantMetrics{Variant: v.Name}
+		}
+		results.Variants = append(results.Variants, m)
+		delete(byVariant, v.Name)
+	}
+	for _, m := range byVariant {
+		results.Variants = append(results.Variants, m)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"noble-ngs-curriculum/internal/clients/intelligence"
+	"noble-ngs-curriculum/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var ErrInvalidGeneratedLesson = errors.New("invalid generated lesson")
+
+// GeneratedLessonError lists everything wrong with a generated lesson, each entry prefixed with
+// its path in the structured lesson
+type GeneratedLessonError struct {
+	Problems []string
+}
+
+func (e *GeneratedLessonError) Error() string {
+	return "invalid generated lesson: " + strings.Join(e.Problems, "; ")
+}
+
+func (e *GeneratedLessonError) Unwrap() error {
+	return ErrInvalidGeneratedLesson
+}
+
+// Quarantine sources
+const (
+	QuarantineSourceLesson      = "lesson"
+	QuarantineSourceRemediation = "remediation"
+)
+
+// Bounds on generated lessons. Anything past them is more likely a runaway generation than a
+// lesson worth publishing.
+const (
+	maxGeneratedMarkdownBytes = 200_000
+	maxGeneratedTitleRunes    = 255
+	maxGeneratedTextRunes     = 10_000
+	maxGeneratedListItems     = 30
+	maxGeneratedArtifactItems = 50
+	maxGeneratedChoices       = 10
+	maxGeneratedMinutes       = 240
+	maxGeneratedReadingLevel  = 20
+)
+
+// GeneratedDifficulties are the difficulty values a generated lesson may have
+var GeneratedDifficulties = []string{"beginner", "intermediate", "advanced", "expert"}
+
+// Assessment check types
+const (
+	CheckTypeMCQ         = "mcq"
+	CheckTypeShortAnswer = "short_answer"
+	CheckTypeTrueFalse   = "true_false"
+)
+
+var generationQuarantined = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ngs_generation_quarantined_total",
+		Help: "Generated lessons rejected by schema validation and quarantined, by source (lesson or remediation).",
+	},
+	[]string{"source"},
+)
+
+func init() {
+	prometheus.MustRegister(generationQuarantined)
+}
+
+// ValidateGeneratedLesson checks a generation response against the structured lesson schema:
+// every required section is there, sizes are bounded and enumerated values are known. The
+// accessibility section is required when constraints asked for it. Every problem is reported.
+func ValidateGeneratedLesson(resp *intelligence.GenerateLessonResponse, constraints intelligence.GenerationConstraints) error {
+	var problems []string
+	problem := func(path, format string, args ...interface{}) {
+		problems = append(problems, path+": "+fmt.Sprintf(format, args...))
+	}
+	text := func(path, value string, max int) {
+		if strings.TrimSpace(value) == "" {
+			problem(path, "is required")
+		} else if utf8.RuneCountInString(value) > max {
+			problem(path, "must be at most %d characters", max)
+		}
+	}
+	optionalText := func(path, value string) {
+		if utf8.RuneCountInString(value) > maxGeneratedTextRunes {
+			problem(path, "must be at most %d characters", maxGeneratedTextRunes)
+		}
+	}
+	count := func(path string, n, min, max int) {
+		if n < min || n > max {
+			problem(path, "must have between %d and %d items, not %d", min, max, n)
+		}
+	}
+
+	if strings.TrimSpace(resp.ContentMarkdown) == "" {
+		problem("content_markdown", "is required")
+	} else if len(resp.ContentMarkdown) > maxGeneratedMarkdownBytes {
+		problem("content_markdown", "must be at most %d bytes", maxGeneratedMarkdownBytes)
+	}
+	if resp.TokensUsed < 0 {
+		problem("tokens_used", "must not be negative")
+	}
+	lesson := resp.StructuredLesson
+
+	meta := lesson.Metadata
+	text("metadata.title", meta.Title, maxGeneratedTitleRunes)
+	count("metadata.outcomes", len(meta.Outcomes), 1, maxGeneratedListItems)
+	for i, outcome := range meta.Outcomes {
+		text(fmt.Sprintf("metadata.outcomes[%d]", i), outcome, maxGeneratedTextRunes)
+	}
+	if !containsString(GeneratedDifficulties, meta.Difficulty) {
+		problem("metadata.difficulty", "must be one of %s, not %q", strings.Join(GeneratedDifficulties, ", "), meta.Difficulty)
+	}
+	count("metadata.prerequisites", len(meta.Prerequisites), 0, maxGeneratedListItems)
+	if meta.EstimatedMinutes < 1 || meta.EstimatedMinutes > maxGeneratedMinutes {
+		problem("metadata.estimated_minutes", "must be between 1 and %d", maxGeneratedMinutes)
+	}
+
+	teach := lesson.Teach
+	text("teach.overview", teach.Overview, maxGeneratedTextRunes)
+	count("teach.concepts", len(teach.Concepts), 1, maxGeneratedListItems)
+	for i, concept := range teach.Concepts {
+		path := fmt.Sprintf("teach.concepts[%d]", i)
+		text(path+".name", concept.Name, maxGeneratedTitleRunes)
+		text(path+".explanation", concept.Explanation, maxGeneratedTextRunes)
+		text(path+".example", concept.Example, maxGeneratedTextRunes)
+		if concept.Analogy != nil {
+			optionalText(path+".analogy", *concept.Analogy)
+		}
+	}
+	count("teach.steps", len(teach.Steps), 1, maxGeneratedListItems)
+	for i, step := range teach.Steps {
+		text(fmt.Sprintf("teach.steps[%d]", i), step, maxGeneratedTextRunes)
+	}
+	count("teach.visuals", len(teach.Visuals), 0, maxGeneratedListItems)
+	for i, visual := range teach.Visuals {
+		text(fmt.Sprintf("teach.visuals[%d]", i), visual, maxGeneratedTextRunes)
+	}
+
+	count("guided_practice", len(lesson.GuidedPractice), 1, maxGeneratedListItems)
+	for i, task := range lesson.GuidedPractice {
+		path := fmt.Sprintf("guided_practice[%d]", i)
+		text(path+".task", task.Task, maxGeneratedTextRunes)
+		text(path+".hint", task.Hint, maxGeneratedTextRunes)
+		text(path+".solution", task.Solution, maxGeneratedTextRunes)
+	}
+
+	count("assessment.checks", len(lesson.Assessment.Checks), 1, maxGeneratedListItems)
+	for i, check := range lesson.Assessment.Checks {
+		path := fmt.Sprintf("assessment.checks[%d]", i)
+		text(path+".question", check.Question, maxGeneratedTextRunes)
+		text(path+".explanation", check.Explanation, maxGeneratedTextRunes)
+		for j, choice := range check.Choices {
+			text(fmt.Sprintf("%s.choices[%d]", path, j), choice, maxGeneratedTextRunes)
+		}
+		if msg := checkAnswerProblem(check); msg != "" {
+			problem(path, "%s", msg)
+		}
+	}
+	if lesson.Assessment.Rubric != nil {
+		optionalText("assessment.rubric", *lesson.Assessment.Rubric)
+	}
+
+	text("summary", lesson.Summary, maxGeneratedTextRunes)
+
+	artifacts := lesson.Artifacts
+	count("artifacts.quiz_items", len(artifacts.QuizItems), 0, maxGeneratedArtifactItems)
+	count("artifacts.notes_outline", len(artifacts.NotesOutline), 0, maxGeneratedArtifactItems)
+	count("artifacts.code_snippets", len(artifacts.CodeSnippets), 0, maxGeneratedArtifactItems)
+	count("artifacts.glossary", len(artifacts.Glossary), 0, maxGeneratedArtifactItems)
+	for i, term := range artifacts.Glossary {
+		path := fmt.Sprintf("artifacts.glossary[%d]", i)
+		text(path+".term", term.Term, maxGeneratedTitleRunes)
+		text(path+".definition", term.Definition, maxGeneratedTextRunes)
+	}
+
+	if a := lesson.Accessibility; a != nil {
+		text("accessibility.plain_language_summary", a.PlainLanguageSummary, maxGeneratedTextRunes)
+		count("accessibility.alt_text", len(a.AltText), 0, maxGeneratedListItems)
+		for i, alt := range a.AltText {
+			path := fmt.Sprintf("accessibility.alt_text[%d]", i)
+			text(path+".visual", alt.Visual, maxGeneratedTextRunes)
+			text(path+".alt_text", alt.AltText, maxGeneratedTextRunes)
+		}
+		if a.ReadingLevel != nil && (*a.ReadingLevel < 0 || *a.ReadingLevel > maxGeneratedReadingLevel) {
+			problem("accessibility.reading_level", "must be between 0 and %d", maxGeneratedReadingLevel)
+		}
+	} else if constraints.IncludeAccessibility {
+		problem("accessibility", "is required when accessibility was requested")
+	}
+
+	if len(problems) > 0 {
+		return &GeneratedLessonError{Problems: problems}
+	}
+	return nil
+}
+
+// checkAnswerProblem describes what is wrong with an assessment check's type, choices or answer
+func checkAnswerProblem(check intelligence.AssessmentCheck) string {
+	if check.Answer == nil {
+		return "answer is required"
+	}
+	switch check.Type {
+	case CheckTypeMCQ:
+		if len(check.Choices) < 2 || len(check.Choices) > maxGeneratedChoices {
+			return fmt.Sprintf("an mcq needs between 2 and %d choices", maxGeneratedChoices)
+		}
+		switch answer := check.Answer.(type) {
+		case float64:
+			if answer != math.Trunc(answer) || answer < 0 || int(answer) >= len(check.Choices) {
+				return "answer must be the index of one of the choices"
+			}
+		case string:
+			if strings.TrimSpace(answer) == "" {
+				return "answer is required"
+			}
+		default:
+			return "answer must be a choice index or text"
+		}
+	case CheckTypeTrueFalse:
+		switch answer := check.Answer.(type) {
+		case bool:
+		case string:
+			if a := strings.ToLower(strings.TrimSpace(answer)); a != "true" && a != "false" {
+				return "answer must be true or false"
+			}
+		default:
+			return "answer must be true or false"
+		}
+	case CheckTypeShortAnswer:
+		if answer, ok := check.Answer.(string); ok && strings.TrimSpace(answer) == "" {
+			return "answer is required"
+		}
+	default:
+		return fmt.Sprintf("type must be one of %s, %s or %s, not %q", CheckTypeMCQ, CheckTypeShortAnswer, CheckTypeTrueFalse, check.Type)
+	}
+	return ""
+}
+
+// QuarantineGeneratedLesson keeps a lesson generated for lessonID that failed validation, for
+// review instead of publishing it
+func (s *LessonService) QuarantineGeneratedLesson(lessonID, userID uuid.UUID, resp *intelligence.GenerateLessonResponse, validationErr error) {
+	quarantineGeneration(s.db, QuarantineSourceLesson, lessonID, userID, resp, validationErr)
+}
+
+// quarantineGeneration keeps a generated lesson that failed validation, with its problems.
+// subjectID is the lesson or remediation lesson it was generated for, by source. Failing to
+// quarantine is logged, since the lesson is rejected either way.
+func quarantineGeneration(db *database.DB, source string, subjectID, userID uuid.UUID, resp *intelligence.GenerateLessonResponse, validationErr error) {
+	generationQuarantined.WithLabelValues(source).Inc()
+	problems := []string{validationErr.Error()}
+	var lessonErr *GeneratedLessonError
+	if errors.As(validationErr, &lessonErr) {
+		problems = lessonErr.Problems
+	}
+	problemsJSON, err := json.Marshal(problems)
+	if err != nil {
+		log.Printf("Failed to quarantine generated %s for %s: %v", source, subjectID, err)
+		return
+	}
+	responseJSON, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Failed to quarantine generated %s for %s: %v", source, subjectID, err)
+		return
+	}
+
+	lessonID, remediationID := &subjectID, (*uuid.UUID)(nil)
+	if source == QuarantineSourceRemediation {
+		lessonID, remediationID = nil, &subjectID
+	}
+	_, err = db.Exec(`
+		INSERT INTO ngs_generation_quarantine (source, lesson_id, remediation_id, user_id, provider, problems, response)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, source, lessonID, remediationID, userID, resp.Provider, problemsJSON, responseJSON)
+	if err != nil {
+		log.Printf("Failed to quarantine generated %s for %s: %v", source, subjectID, err)
+	}
+}
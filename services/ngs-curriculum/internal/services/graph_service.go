@@ -0,0 +1,646 @@
+package services
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	GraphNodeLevel      = "level"
+	GraphNodeLesson     = "lesson"
+	GraphNodeCapability = "capability"
+
+	GraphEdgePrerequisite = "prerequisite"
+	GraphEdgeUnlock       = "unlock"
+)
+
+const (
+	ViolationCycle             = "cycle"
+	ViolationMissingLesson     = "missing_lesson"
+	ViolationUnpublishedLesson = "unpublished_lesson"
+	ViolationUnknownLevel      = "unknown_level"
+	ViolationLevelOrder        = "level_order"
+	ViolationInvalidFormat     = "invalid_format"
+)
+
+var ErrInvalidPrerequisites = errors.New("invalid lesson prerequisites")
+
+// PrerequisiteError lists the prerequisite violations that blocked a write
+type PrerequisiteError struct {
+	Violations []models.PrerequisiteViolation
+}
+
+func (e *PrerequisiteError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return "invalid lesson prerequisites: " + strings.Join(messages, "; ")
+}
+
+func (e *PrerequisiteError) Is(target error) bool {
+	return target == ErrInvalidPrerequisites
+}
+
+// graphQuerier is satisfied by both *database.DB and *sql.Tx so the graph can be validated
+// inside an import transaction
+type graphQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+type GraphService struct {
+	db *database.DB
+}
+
+func NewGraphService(db *database.DB) *GraphService {
+	return &GraphService{
+		db: db,
+	}
+}
+
+// ParseLessonPrerequisites reads a lessons.prerequisites value: either an object with
+// min_level and lessons, or a bare array of lesson IDs. Empty and null values have no
+// prerequisites.
+func ParseLessonPrerequisites(raw json.RawMessage) (models.LessonPrerequisites, error) {
+	var prereqs models.LessonPrerequisites
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return prereqs, nil
+	}
+
+	if trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &prereqs.Lessons); err != nil {
+			return prereqs, fmt.Errorf("invalid prerequisite lesson IDs: %w", err)
+		}
+		return prereqs, nil
+	}
+
+	if err := json.Unmarshal(trimmed, &prereqs); err != nil {
+		return prereqs, fmt.Errorf("invalid prerequisites: %w", err)
+	}
+	return prereqs, nil
+}
+
+// capabilitySlug derives a stable node ID from an agent_unlock description
+func capabilitySlug(text string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// BuildCurriculumGraph turns levels and lessons into graph nodes and edges. Levels gate the
+// next level (unlock_requirements.prev_level, else the previous level number) and the lessons
+// whose min_level they satisfy; lessons gate the lessons listing them as prerequisites and
+// unlock their agent capability. Prerequisites that cannot be parsed or point at levels or
+// lessons outside the input are skipped; they, and prerequisites above a lesson's own level,
+// are returned as violations. Cycles are found separately by FindPrerequisiteCycle.
+func BuildCurriculumGraph(levels []models.CurriculumLevel, lessons []models.Lesson) (*models.CurriculumGraph, []models.PrerequisiteViolation) {
+	graph := &models.CurriculumGraph{Nodes: []models.GraphNode{}, Edges: []models.GraphEdge{}}
+	violations := []models.PrerequisiteViolation{}
+
+	addEdge := func(source, target, edgeType string) {
+		graph.Edges = append(graph.Edges, models.GraphEdge{
+			ID:     source + "->" + target,
+			Source: source,
+			Target: target,
+			Type:   edgeType,
+		})
+	}
+
+	sortedLevels := append([]models.CurriculumLevel(nil), levels...)
+	sort.Slice(sortedLevels, func(i, j int) bool { return sortedLevels[i].LevelNumber < sortedLevels[j].LevelNumber })
+
+	levelNodeByNumber := make(map[int]string, len(sortedLevels))
+	levelNumberByID := make(map[int]int, len(sortedLevels))
+	for _, level := range sortedLevels {
+		levelNodeByNumber[level.LevelNumber] = fmt.Sprintf("level:%d", level.ID)
+		levelNumberByID[level.ID] = level.LevelNumber
+	}
+
+	for _, level := range sortedLevels {
+		var unlock struct {
+			Phase     string `json:"phase"`
+			PrevLevel *int   `json:"prev_level"`
+		}
+		if len(level.UnlockRequirements) > 0 {
+			if err := json.Unmarshal(level.UnlockRequirements, &unlock); err != nil {
+				violations = append(violations, models.PrerequisiteViolation{
+					Code:    ViolationInvalidFormat,
+					Message: fmt.Sprintf("level %d has invalid unlock_requirements", level.LevelNumber),
+				})
+			}
+		}
+
+		node := models.GraphNode{
+			ID:    levelNodeByNumber[level.LevelNumber],
+			Type:  GraphNodeLevel,
+			Label: level.Title,
+			Level: level.LevelNumber,
+			Data: map[string]interface{}{
+				"description": level.Description,
+				"xp_required": level.XPRequired,
+			},
+		}
+		if unlock.Phase != "" {
+			node.Data["phase"] = unlock.Phase
+		}
+		graph.Nodes = append(graph.Nodes, node)
+
+		prev := level.LevelNumber - 1
+		if unlock.PrevLevel != nil {
+			prev = *unlock.PrevLevel
+		}
+		if source, ok := levelNodeByNumber[prev]; ok {
+			addEdge(source, node.ID, GraphEdgePrerequisite)
+			if prev >= level.LevelNumber {
+				violations = append(violations, models.PrerequisiteViolation{
+					Code:    ViolationLevelOrder,
+					Message: fmt.Sprintf("level %d requires level %d, which is not below it", level.LevelNumber, prev),
+				})
+			}
+		}
+	}
+
+	sortedLessons := append([]models.Lesson(nil), lessons...)
+	sort.SliceStable(sortedLessons, func(i, j int) bool {
+		if sortedLessons[i].LevelID != sortedLessons[j].LevelID {
+			return sortedLessons[i].LevelID < sortedLessons[j].LevelID
+		}
+		return sortedLessons[i].LessonOrder < sortedLessons[j].LessonOrder
+	})
+
+	levelOf := func(levelID int) int {
+		if number, ok := levelNumberByID[levelID]; ok {
+			return number
+		}
+		return levelID
+	}
+	lessonsByID := make(map[uuid.UUID]models.Lesson, len(sortedLessons))
+	for _, lesson := range sortedLessons {
+		lessonsByID[lesson.ID] = lesson
+	}
+
+	capabilities := map[string]*models.GraphNode{}
+	var capabilityOrder []string
+
+	for _, lesson := range sortedLessons {
+		lessonID := lesson.ID
+		levelNumber := levelOf(lesson.LevelID)
+		violation := func(code, ref, format string, args ...interface{}) {
+			violations = append(violations, models.PrerequisiteViolation{
+				Code:      code,
+				LessonID:  &lessonID,
+				Reference: ref,
+				Message:   fmt.Sprintf("lesson %q (%s): ", lesson.Title, lessonID) + fmt.Sprintf(format, args...),
+			})
+		}
+
+		node := models.GraphNode{
+			ID:    "lesson:" + lesson.ID.String(),
+			Type:  GraphNodeLesson,
+			Label: lesson.Title,
+			Level: levelNumber,
+			Data: map[string]interface{}{
+				"lesson_type":       lesson.LessonType,
+				"lesson_order":      lesson.LessonOrder,
+				"xp_reward":         lesson.XPReward,
+				"estimated_minutes": lesson.EstimatedMinutes,
+				"is_required":       lesson.IsRequired,
+				"min_age_band":      lesson.MinAgeBand,
+			},
+		}
+		graph.Nodes = append(graph.Nodes, node)
+
+		prereqs, err := ParseLessonPrerequisites(lesson.Prerequisites)
+		if err != nil {
+			violation(ViolationInvalidFormat, "", "%v", err)
+		}
+		if prereqs.MinLevel > 0 {
+			source, ok := levelNodeByNumber[prereqs.MinLevel]
+			switch {
+			case !ok:
+				violation(ViolationUnknownLevel, fmt.Sprint(prereqs.MinLevel), "min_level %d does not exist", prereqs.MinLevel)
+			case prereqs.MinLevel > levelNumber:
+				addEdge(source, node.ID, GraphEdgePrerequisite)
+				violation(ViolationLevelOrder, fmt.Sprint(prereqs.MinLevel),
+					"min_level %d is above the lesson's own level %d, so learners on that level cannot take it", prereqs.MinLevel, levelNumber)
+			default:
+				addEdge(source, node.ID, GraphEdgePrerequisite)
+			}
+		}
+		for _, prereqID := range prereqs.Lessons {
+			prereq, ok := lessonsByID[prereqID]
+			if !ok {
+				violation(ViolationMissingLesson, prereqID.String(), "prerequisite lesson %s does not exist", prereqID)
+				continue
+			}
+			addEdge("lesson:"+prereqID.String(), node.ID, GraphEdgePrerequisite)
+			if prereqLevel := levelOf(prereq.LevelID); prereqLevel > levelNumber {
+				violation(ViolationLevelOrder, prereqID.String(),
+					"prerequisite %q is on level %d, above the lesson's own level %d; move one of them or drop the prerequisite",
+					prereq.Title, prereqLevel, levelNumber)
+			}
+		}
+
+		unlock := strings.TrimSpace(lesson.AgentUnlock)
+		slug := capabilitySlug(unlock)
+		if slug == "" {
+			continue
+		}
+		capabilityID := "capability:" + slug
+		if _, ok := capabilities[capabilityID]; !ok {
+			capabilities[capabilityID] = &models.GraphNode{
+				ID:    capabilityID,
+				Type:  GraphNodeCapability,
+				Label: unlock,
+				Level: levelNumber,
+			}
+			capabilityOrder = append(capabilityOrder, capabilityID)
+		}
+		addEdge(node.ID, capabilityID, GraphEdgeUnlock)
+	}
+
+	// Capabilities sit at the earliest level that unlocks them, which is their first occurrence
+	for _, id := range capabilityOrder {
+		graph.Nodes = append(graph.Nodes, *capabilities[id])
+	}
+
+	return graph, violations
+}
+
+// FindPrerequisiteCycle returns the node IDs of a prerequisite cycle, starting and ending with
+// the same node, or nil when prerequisites form a DAG
+func FindPrerequisiteCycle(graph *models.CurriculumGraph) []string {
+	next := make(map[string][]string)
+	for _, edge := range graph.Edges {
+		if edge.Type == GraphEdgePrerequisite {
+			next[edge.Source] = append(next[edge.Source], edge.Target)
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(graph.Nodes))
+	var stack []string
+
+	var visit func(id string) []string
+	visit = func(id string) []string {
+		state[id] = visiting
+		stack = append(stack, id)
+		for _, target := range next[id] {
+			switch state[target] {
+			case visiting:
+				for i, onStack := range stack {
+					if onStack == target {
+						return append(append([]string(nil), stack[i:]...), target)
+					}
+				}
+			case unvisited:
+				if cycle := visit(target); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[id] = done
+		return nil
+	}
+
+	for _, node := range graph.Nodes {
+		if state[node.ID] == unvisited {
+			if cycle := visit(node.ID); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// loadCurriculumGraph reads levels and the lessons matching lessonFilter and builds the graph
+func loadCurriculumGraph(q graphQuerier, lessonFilter string, args ...interface{}) (*models.CurriculumGraph, []models.PrerequisiteViolation, error) {
+	rows, err := q.Query(`
+		SELECT id, level_number, title, COALESCE(description, ''), COALESCE(unlock_requirements, '{}'), xp_required
+		FROM curriculum_levels
+		ORDER BY level_number
+	`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query levels: %w", err)
+	}
+	var levels []models.CurriculumLevel
+	for rows.Next() {
+		var level models.CurriculumLevel
+		if err := rows.Scan(&level.ID, &level.LevelNumber, &level.Title, &level.Description, &level.UnlockRequirements, &level.XPRequired); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan level: %w", err)
+		}
+		levels = append(levels, level)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read levels: %w", err)
+	}
+
+	rows, err = q.Query(`
+		SELECT id, level_id, title, lesson_order, lesson_type, COALESCE(agent_unlock, ''),
+		       COALESCE(xp_reward, 0), COALESCE(estimated_minutes, 0), COALESCE(is_required, true),
+		       prerequisites, min_age_band
+		FROM lessons
+		WHERE `+lessonFilter+`
+		ORDER BY level_id, lesson_order, id`, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query lessons: %w", err)
+	}
+	defer rows.Close()
+
+	var lessons []models.Lesson
+	for rows.Next() {
+		var lesson models.Lesson
+		var prereqs []byte
+		err := rows.Scan(
+			&lesson.ID, &lesson.LevelID, &lesson.Title, &lesson.LessonOrder, &lesson.LessonType, &lesson.AgentUnlock,
+			&lesson.XPReward, &lesson.EstimatedMinutes, &lesson.IsRequired, &prereqs, &lesson.MinAgeBand,
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to scan lesson: %w", err)
+		}
+		lesson.Prerequisites = prereqs
+		lessons = append(lessons, lesson)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read lessons: %w", err)
+	}
+
+	graph, violations := BuildCurriculumGraph(levels, lessons)
+	return graph, violations, nil
+}
+
+// cycleViolation describes a prerequisite cycle using node labels
+func cycleViolation(graph *models.CurriculumGraph, cycle []string) models.PrerequisiteViolation {
+	labels := make(map[string]string, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		labels[node.ID] = fmt.Sprintf("%q", node.Label)
+	}
+	path := make([]string, len(cycle))
+	for i, id := range cycle {
+		path[i] = labels[id]
+	}
+	return models.PrerequisiteViolation{
+		Code:    ViolationCycle,
+		Path:    cycle,
+		Message: "prerequisites form a cycle: " + strings.Join(path, " -> ") + "; remove one of these prerequisites",
+	}
+}
+
+// ValidateCurriculumGraph checks that lesson and level prerequisites are acyclic. It runs after
+// seeding; other violations in existing content are only logged.
+func ValidateCurriculumGraph(q graphQuerier) error {
+	graph, violations, err := loadCurriculumGraph(q, "true")
+	if err != nil {
+		return err
+	}
+	for _, v := range violations {
+		log.Printf("Curriculum graph: %s", v.Message)
+	}
+
+	if cycle := FindPrerequisiteCycle(graph); cycle != nil {
+		return &PrerequisiteError{Violations: []models.PrerequisiteViolation{cycleViolation(graph, cycle)}}
+	}
+	return nil
+}
+
+// validateLessonPrerequisites checks the graph after a write to lessonID's prerequisites,
+// inside the writing transaction. Only violations of that lesson block the write; since the
+// graph was acyclic before, any cycle runs through it.
+func validateLessonPrerequisites(q graphQuerier, lessonID uuid.UUID) error {
+	return validateLessonsPrerequisites(q, map[uuid.UUID]bool{lessonID: true})
+}
+
+// validateLessonsPrerequisites is validateLessonPrerequisites for a write to several lessons
+func validateLessonsPrerequisites(q graphQuerier, lessonIDs map[uuid.UUID]bool) error {
+	graph, violations, err := loadCurriculumGraph(q, "true")
+	if err != nil {
+		return err
+	}
+
+	var blocking []models.PrerequisiteViolation
+	for _, v := range violations {
+		if v.LessonID != nil && lessonIDs[*v.LessonID] {
+			blocking = append(blocking, v)
+		}
+	}
+	if cycle := FindPrerequisiteCycle(graph); cycle != nil {
+		blocking = append(blocking, cycleViolation(graph, cycle))
+	}
+
+	if len(blocking) > 0 {
+		return &PrerequisiteError{Violations: blocking}
+	}
+	return nil
+}
+
+// resolvePrerequisiteRefs maps prerequisite references, lesson IDs or external IDs, to lesson
+// IDs. Every unresolved reference is reported, distinguishing drafts that are not published yet.
+func resolvePrerequisiteRefs(q queryRower, lessonID uuid.UUID, refs []string) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	var violations []models.PrerequisiteViolation
+	seen := map[uuid.UUID]bool{}
+
+	for _, ref := range refs {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			continue
+		}
+
+		var id uuid.UUID
+		var err error
+		if parsed, parseErr := uuid.Parse(ref); parseErr == nil {
+			err = q.QueryRow(`SELECT id FROM lessons WHERE id = $1`, parsed).Scan(&id)
+		} else {
+			err = q.QueryRow(`SELECT id FROM lessons WHERE external_id = $1`, ref).Scan(&id)
+		}
+
+		if err == sql.ErrNoRows {
+			var draftStatus string
+			draftErr := q.QueryRow(`
+				SELECT status FROM lesson_drafts
+				WHERE fields->>'external_id' = $1 OR id::text = $1
+				ORDER BY updated_at DESC
+				LIMIT 1
+			`, ref).Scan(&draftStatus)
+			if draftErr != nil && draftErr != sql.ErrNoRows {
+				return nil, fmt.Errorf("failed to look up prerequisite draft %s: %w", ref, draftErr)
+			}
+
+			v := models.PrerequisiteViolation{Code: ViolationMissingLesson, LessonID: &lessonID, Reference: ref,
+				Message: fmt.Sprintf("prerequisite %q does not match any lesson ID or external ID", ref)}
+			if draftErr == nil {
+				v.Code = ViolationUnpublishedLesson
+				v.Message = fmt.Sprintf("prerequisite %q is an unpublished lesson draft; publish it first", ref)
+			}
+			violations = append(violations, v)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve prerequisite %s: %w", ref, err)
+		}
+
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if len(violations) > 0 {
+		return nil, &PrerequisiteError{Violations: violations}
+	}
+	return ids, nil
+}
+
+// SetLessonPrerequisites replaces a lesson's prerequisites after validating the resulting graph
+func (s *GraphService) SetLessonPrerequisites(lessonID uuid.UUID, req models.SetPrerequisitesRequest) (*models.LessonPrerequisites, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var levelID int
+	err = tx.QueryRow(`SELECT level_id FROM lessons WHERE id = $1 FOR UPDATE`, lessonID).Scan(&levelID)
+	if err == sql.ErrNoRows {
+		return nil, ErrLessonNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lesson: %w", err)
+	}
+
+	ids, err := resolvePrerequisiteRefs(tx, lessonID, req.Lessons)
+	if err != nil {
+		return nil, err
+	}
+
+	prereqs := models.LessonPrerequisites{MinLevel: levelID, Lessons: ids}
+	if req.MinLevel != nil {
+		prereqs.MinLevel = *req.MinLevel
+	}
+	prereqJSON, err := json.Marshal(prereqs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal prerequisites: %w", err)
+	}
+
+	_, err = tx.Exec(`UPDATE lessons SET prerequisites = $1, updated_at = NOW() WHERE id = $2`, prereqJSON, lessonID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update prerequisites: %w", err)
+	}
+
+	if err := validateLessonPrerequisites(tx, lessonID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Lesson %s prerequisites set to min level %d and %d lessons", lessonID, prereqs.MinLevel, len(prereqs.Lessons))
+	return &prereqs, nil
+}
+
+// GetCurriculumGraph returns the curriculum map with the lessons suitable for the user's age
+// band. Edges to hidden lessons are left out.
+func (s *GraphService) GetCurriculumGraph(userID uuid.UUID) (*models.CurriculumGraph, error) {
+	graph, _, err := loadCurriculumGraph(s.db, lessonVisibleSQL("lessons", "$1"), userID)
+	if err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
+
+// ApplyCurriculumMapStatus sets lock and completion status on map levels for a learner at
+// currentLevel with totalXP. Levels up to the current one are unlocked.
+func ApplyCurriculumMapStatus(levels []models.CurriculumMapLevel, currentLevel, totalXP int) {
+	for i := range levels {
+		level := &levels[i]
+		level.Locked = level.LevelNumber > currentLevel
+		level.XPToUnlock = 0
+		if level.Locked && level.XPRequired > totalXP {
+			level.XPToUnlock = level.XPRequired - totalXP
+		}
+		level.Completed = level.RequiredLessonCount > 0 && level.CompletedRequiredCount >= level.RequiredLessonCount
+	}
+}
+
+// GetCurriculumMap returns every level with its lesson and completion counts and the user's lock
+// status, using one grouped query over the lessons visible to the user
+func (s *GraphService) GetCurriculumMap(userID uuid.UUID) (*models.CurriculumMap, error) {
+	progress, err := loadProgress(s.db, nil, userID)
+	if err == sql.ErrNoRows {
+		progress = models.UserProgress{CurrentLevel: 1}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get progress: %w", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT cl.id, cl.level_number, cl.title, COALESCE(cl.description, ''),
+		       COALESCE(cl.unlock_requirements, '{}'), cl.xp_required,
+		       COUNT(l.id),
+		       COUNT(l.id) FILTER (WHERE COALESCE(l.is_required, true)),
+		       COUNT(lc.id),
+		       COUNT(lc.id) FILTER (WHERE COALESCE(l.is_required, true))
+		FROM curriculum_levels cl
+		LEFT JOIN lessons l ON l.level_id = cl.id AND `+lessonVisibleSQL("l", "$1")+`
+		LEFT JOIN lesson_completions lc ON lc.lesson_id = l.id AND lc.user_id = $1
+		GROUP BY cl.id
+		ORDER BY cl.level_number
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query curriculum map: %w", err)
+	}
+	defer rows.Close()
+
+	levels := []models.CurriculumMapLevel{}
+	for rows.Next() {
+		var level models.CurriculumMapLevel
+		err := rows.Scan(
+			&level.ID, &level.LevelNumber, &level.Title, &level.Description,
+			&level.UnlockRequirements, &level.XPRequired,
+			&level.LessonCount, &level.RequiredLessonCount,
+			&level.CompletedCount, &level.CompletedRequiredCount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan map level: %w", err)
+		}
+		levels = append(levels, level)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read curriculum map: %w", err)
+	}
+
+	ApplyCurriculumMapStatus(levels, progress.CurrentLevel, progress.TotalXP)
+	return &models.CurriculumMap{
+		CurrentLevel: progress.CurrentLevel,
+		TotalXP:      progress.TotalXP,
+		Levels:       levels,
+	}, nil
+}
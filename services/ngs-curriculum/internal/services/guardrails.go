@@ -0,0 +1,99 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+
+	"noble-ngs-curriculum/internal/clients/intelligence"
+	"noble-ngs-curriculum/internal/domain/guardrails"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrGuardrailsFailed is returned for generated lessons kept from publication by a failed
+// guardrail check
+var ErrGuardrailsFailed = errors.New("generated lesson failed guardrail checks")
+
+var guardrailPolicy atomic.Pointer[guardrails.Policy]
+
+var guardrailChecks = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ngs_guardrail_checks_total",
+		Help: "Guardrail checks run on generated lessons before publication, by check and result (passed or failed).",
+	},
+	[]string{"check", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(guardrailChecks)
+}
+
+// ConfigureGuardrails checks every generated lesson against policy before it is published.
+// Without it lessons are only checked for personal data.
+func ConfigureGuardrails(policy guardrails.Policy) {
+	guardrailPolicy.Store(&policy)
+}
+
+func currentGuardrails() guardrails.Policy {
+	if policy := guardrailPolicy.Load(); policy != nil {
+		return *policy
+	}
+	return guardrails.Policy{}
+}
+
+// CheckGeneratedLesson runs the guardrail checklist on a generated lesson. The ethics sections
+// are required when constraints asked for ethics guardrails.
+func CheckGeneratedLesson(lesson *intelligence.GenerateLessonResponse, constraints intelligence.GenerationConstraints) guardrails.Report {
+	report := currentGuardrails().Check(guardrails.Lesson{
+		Markdown:      lesson.ContentMarkdown,
+		Text:          structuredLessonText(lesson.StructuredLesson),
+		ReadingLevel:  EstimateReadingLevel(lesson.ContentMarkdown),
+		RequireEthics: constraints.RequireEthicsGuardrails,
+	})
+	for _, result := range report.Results {
+		outcome := "passed"
+		if !result.Passed {
+			outcome = "failed"
+		}
+		guardrailChecks.WithLabelValues(result.Check, outcome).Inc()
+	}
+	return report
+}
+
+// structuredLessonText is the text of a structured lesson that the intelligence service may not
+// have compiled into its markdown
+func structuredLessonText(lesson intelligence.StructuredLesson) []string {
+	text := []string{lesson.Metadata.Title, lesson.Teach.Overview, lesson.Summary}
+	text = append(text, lesson.Metadata.Outcomes...)
+	text = append(text, lesson.Teach.Steps...)
+	text = append(text, lesson.Teach.Visuals...)
+	for _, concept := range lesson.Teach.Concepts {
+		text = append(text, concept.Name, concept.Explanation, concept.Example)
+	}
+	for _, task := range lesson.GuidedPractice {
+		text = append(text, task.Task, task.Hint, task.Solution)
+	}
+	for _, check := range lesson.Assessment.Checks {
+		text = append(text, check.Question, check.Explanation)
+		text = append(text, check.Choices...)
+	}
+	for _, term := range lesson.Artifacts.Glossary {
+		text = append(text, term.Term, term.Definition)
+	}
+	// Free-form artifacts are checked as their JSON
+	for _, items := range [][]map[string]interface{}{lesson.Artifacts.QuizItems, lesson.Artifacts.NotesOutline, lesson.Artifacts.CodeSnippets} {
+		for _, item := range items {
+			if data, err := json.Marshal(item); err == nil {
+				text = append(text, string(data))
+			}
+		}
+	}
+	if lesson.Accessibility != nil {
+		text = append(text, lesson.Accessibility.PlainLanguageSummary)
+		for _, alt := range lesson.Accessibility.AltText {
+			text = append(text, alt.AltText)
+		}
+	}
+	return text
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// IdempotencyKeyMaxLength bounds the Idempotency-Key header
+const IdempotencyKeyMaxLength = 255
+
+var (
+	ErrIdempotencyKeyReused     = errors.New("Idempotency-Key was already used for a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this Idempotency-Key is still in progress")
+)
+
+var idempotentReplays = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "ngs_idempotent_replays_total",
+	Help: "Requests answered with the stored response of an earlier request with the same Idempotency-Key.",
+})
+
+func init() {
+	prometheus.MustRegister(idempotentReplays)
+}
+
+// IdempotentResponse is the stored response of a completed request
+type IdempotentResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// IdempotencyService records requests made with an Idempotency-Key and their responses, so a
+// retried request is answered with the original response instead of running twice
+type IdempotencyService struct {
+	db    *database.DB
+	clock Clock
+	// ttl is how long a key is honoured; 0 honours keys until they are deleted
+	ttl time.Duration
+}
+
+func NewIdempotencyService(db *database.DB, cfg *config.Config, clock Clock) *IdempotencyService {
+	return &IdempotencyService{
+		db:    db,
+		clock: clock,
+		ttl:   time.Duration(cfg.IdempotencyKeyDays) * 24 * time.Hour,
+	}
+}
+
+// RequestHash fingerprints a request body, so a key reused with another body is refused
+func RequestHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// Begin claims a key for a request. It returns nil when the request should run, or the stored
+// response of the earlier request with the key. A key whose first request is still running, or
+// that was used for another method, path or body, is refused. Expired keys are claimed afresh.
+func (s *IdempotencyService) Begin(userID uuid.UUID, key, method, path string, body []byte) (*IdempotentResponse, error) {
+	hash := RequestHash(body)
+	expired := time.Time{}
+	if s.ttl > 0 {
+		expired = s.clock.Now().Add(-s.ttl)
+	}
+
+	var claimed bool
+	err := s.db.QueryRow(`
+		INSERT INTO idempotency_keys (user_id, key, method, path, request_hash, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, key) DO UPDATE
+		SET method = EXCLUDED.method, path = EXCLUDED.path, request_hash = EXCLUDED.request_hash,
+		    status_code = NULL, content_type = NULL, response_body = NULL,
+		    created_at = EXCLUDED.created_at, completed_at = NULL
+		WHERE idempotency_keys.created_at < $7
+		RETURNING true
+	`, userID, key, method, path, hash, s.clock.Now(), expired).Scan(&claimed)
+	if err == nil {
+		return nil, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+
+	var storedMethod, storedPath, storedHash string
+	var status sql.NullInt64
+	var contentType sql.NullString
+	var responseBody []byte
+	err = s.db.QueryRow(`
+		SELECT method, path, request_hash, status_code, content_type, response_body
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2
+	`, userID, key).Scan(&storedMethod, &storedPath, &storedHash, &status, &contentType, &responseBody)
+	if err == sql.ErrNoRows {
+		// Released between the two statements; the client can retry straight away
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read idempotency key: %w", err)
+	}
+
+	if storedMethod != method || storedPath != path || storedHash != hash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if !status.Valid {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	idempotentReplays.Inc()
+	return &IdempotentResponse{
+		StatusCode:  int(status.Int64),
+		ContentType: contentType.String,
+		Body:        responseBody,
+	}, nil
+}
+
+// Complete stores the response of a request that claimed a key
+func (s *IdempotencyService) Complete(userID uuid.UUID, key string, response IdempotentResponse) error {
+	_, err := s.db.Exec(`
+		UPDATE idempotency_keys
+		SET status_code = $3, content_type = $4, response_body = $5, completed_at = NOW()
+		WHERE user_id = $1 AND key = $2 AND status_code IS NULL
+	`, userID, key, response.StatusCode, response.ContentType, response.Body)
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+// Release frees a key whose request failed, so the client can retry it
+func (s *IdempotencyService) Release(userID uuid.UUID, key string) error {
+	_, err := s.db.Exec(`DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND status_code IS NULL`, userID, key)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func pruneIdempotencyKeys(tx *sql.Tx, cutoff time.Time, limit int) (int64, error) {
+	result, err := tx.Exec(`
+		DELETE FROM idempotency_keys
+		WHERE (user_id, key) IN (
+			SELECT user_id, key FROM idempotency_keys
+			WHERE created_at < $1
+			ORDER BY created_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+	`, cutoff, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	ErrInvalidImpersonation  = errors.New("invalid impersonation request")
+	ErrImpersonationNotFound = errors.New("impersonation session not found")
+	ErrImpersonationInvalid  = errors.New("impersonation token is invalid, expired or ended")
+)
+
+var impersonatedRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ngs_impersonated_requests_total",
+		Help: "Requests made by admins under an impersonation session, by result (allowed, rejected or audit_error).",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	prometheus.MustRegister(impersonatedRequests)
+}
+
+// ImpersonationService manages admin sessions that act as a learner, and their audit trail
+type ImpersonationService struct {
+	db     *database.DB
+	config *config.Config
+	clock  Clock
+}
+
+func NewImpersonationService(db *database.DB, cfg *config.Config, clock Clock) *ImpersonationService {
+	return &ImpersonationService{
+		db:     db,
+		config: cfg,
+		clock:  clock,
+	}
+}
+
+const impersonationColumns = `id, admin_id, user_id, reason, created_at, expires_at, ended_at, ended_by`
+
+// scanImpersonation reads a session; Active is judged at now
+func scanImpersonation(row rowScanner, now time.Time) (*models.ImpersonationSession, error) {
+	var session models.ImpersonationSession
+	var endedAt sql.NullTime
+	var endedBy uuid.NullUUID
+	err := row.Scan(
+		&session.ID, &session.AdminID, &session.UserID, &session.Reason,
+		&session.CreatedAt, &session.ExpiresAt, &endedAt, &endedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if endedAt.Valid {
+		session.EndedAt = &endedAt.Time
+	}
+	if endedBy.Valid {
+		session.EndedBy = &endedBy.UUID
+	}
+	session.Active = session.EndedAt == nil && now.Before(session.ExpiresAt)
+	return &session, nil
+}
+
+// hashImpersonationToken returns the stored form of a session token
+func hashImpersonationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ImpersonationTTL is the lifetime of a session asked to last minutes: the configured default
+// when minutes is 0, and never more than IMPERSONATION_MAX_TTL_MINUTES
+func (s *ImpersonationService) ImpersonationTTL(minutes int) (time.Duration, error) {
+	if minutes == 0 {
+		minutes = s.config.ImpersonationTTLMinutes
+	}
+	if minutes < 1 || minutes > s.config.ImpersonationMaxTTLMinutes {
+		return 0, fmt.Errorf("%w: ttl_minutes must be from 1 to %d", ErrInvalidImpersonation, s.config.ImpersonationMaxTTLMinutes)
+	}
+	return time.Duration(minutes) * time.Minute, nil
+}
+
+// StartImpersonation opens a session for adminID to act as req.UserID and returns it with its
+// token, which is not stored and cannot be read back
+func (s *ImpersonationService) StartImpersonation(adminID uuid.UUID, req models.StartImpersonationRequest) (*models.ImpersonationSession, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if req.UserID == uuid.Nil {
+		return nil, fmt.Errorf("%w: user_id is required", ErrInvalidImpersonation)
+	}
+	if req.UserID == adminID {
+		return nil, fmt.Errorf("%w: admins cannot impersonate themselves", ErrInvalidImpersonation)
+	}
+	if reason == "" {
+		return nil, fmt.Errorf("%w: a reason is required", ErrInvalidImpersonation)
+	}
+	ttl, err := s.ImpersonationTTL(req.TTLMinutes)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate impersonation token: %w", err)
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	now := s.clock.Now()
+	session, err := scanImpersonation(s.db.QueryRow(`
+		INSERT INTO ngs_impersonation_sessions (admin_id, user_id, reason, token_hash, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+impersonationColumns,
+		adminID, req.UserID, reason, hashImpersonationToken(token), now, now.Add(ttl),
+	), now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start impersonation: %w", err)
+	}
+
+	session.Token = token
+	log.Printf("Admin %s started impersonating user %s until %s: %s",
+		adminID, req.UserID, session.ExpiresAt.Format(time.RFC3339), reason)
+	return session, nil
+}
+
+// ResolveImpersonation returns the active session for token, which must belong to adminID
+func (s *ImpersonationService) ResolveImpersonation(token string, adminID uuid.UUID) (*models.ImpersonationSession, error) {
+	now := s.clock.Now()
+	session, err := scanImpersonation(s.db.QueryRow(`
+		SELECT `+impersonationColumns+`
+		FROM ngs_impersonation_sessions
+		WHERE token_hash = $1
+	`, hashImpersonationToken(token)), now)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to query impersonation: %w", err)
+	}
+	if err == sql.ErrNoRows || !session.Active || session.AdminID != adminID {
+		impersonatedRequests.WithLabelValues("rejected").Inc()
+		return nil, ErrImpersonationInvalid
+	}
+	return session, nil
+}
+
+// EndImpersonation ends a session early; ending one that is already over changes nothing
+func (s *ImpersonationService) EndImpersonation(sessionID, adminID uuid.UUID) (*models.ImpersonationSession, error) {
+	now := s.clock.Now()
+	session, err := scanImpersonation(s.db.QueryRow(`
+		UPDATE ngs_impersonation_sessions
+		SET ended_at = COALESCE(ended_at, LEAST($2, expires_at)),
+		    ended_by = COALESCE(ended_by, $3)
+		WHERE id = $1
+		RETURNING `+impersonationColumns,
+		sessionID, now, adminID,
+	), now)
+	if err == sql.ErrNoRows {
+		return nil, ErrImpersonationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to end impersonation: %w", err)
+	}
+
+	log.Printf("Admin %s ended impersonation session %s", adminID, sessionID)
+	return session, nil
+}
+
+// ListImpersonations lists sessions, newest first, optionally only those acting as userID
+func (s *ImpersonationService) ListImpersonations(userID *uuid.UUID, limit int) ([]models.ImpersonationSession, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	now := s.clock.Now()
+	rows, err := s.db.Query(`
+		SELECT `+impersonationColumns+`
+		FROM ngs_impersonation_sessions
+		WHERE $1::uuid IS NULL OR user_id = $1
+		ORDER BY created_at DESC, id
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query impersonations: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []models.ImpersonationSession{}
+	for rows.Next() {
+		session, err := scanImpersonation(rows, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan impersonation: %w", err)
+		}
+		sessions = append(sessions, *session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read impersonations: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// GetImpersonationAudit lists the requests made under a session, oldest first
+func (s *ImpersonationService) GetImpersonationAudit(sessionID uuid.UUID, limit int) ([]models.ImpersonationAuditEntry, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	var exists bool
+	if err := s.db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM ngs_impersonation_sessions WHERE id = $1)
+	`, sessionID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to query impersonation: %w", err)
+	}
+	if !exists {
+		return nil, ErrImpersonationNotFound
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, session_id, admin_id, user_id, method, path, status, created_at
+		FROM ngs_impersonation_audit
+		WHERE session_id = $1
+		ORDER BY id
+		LIMIT $2
+	`, sessionID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query impersonation audit: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.ImpersonationAuditEntry{}
+	for rows.Next() {
+		var entry models.ImpersonationAuditEntry
+		var status sql.NullInt64
+		if err := rows.Scan(
+			&entry.ID, &entry.SessionID, &entry.AdminID, &entry.UserID,
+			&entry.Method, &entry.Path, &status, &entry.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan impersonation audit: %w", err)
+		}
+		if status.Valid {
+			code := int(status.Int64)
+			entry.Status = &code
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read impersonation audit: %w", err)
+	}
+
+	return entries, nil
+}
+
+// BeginAudit records a request made under session before it is handled, so a request that
+// cannot be audited is never made. FinishAudit fills in its status.
+func (s *ImpersonationService) BeginAudit(session *models.ImpersonationSession, method, path string) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`
+		INSERT INTO ngs_impersonation_audit (session_id, admin_id, user_id, method, path, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, session.ID, session.AdminID, session.UserID, method, path, s.clock.Now()).Scan(&id)
+	if err != nil {
+		impersonatedRequests.WithLabelValues("audit_error").Inc()
+		return 0, fmt.Errorf("failed to audit impersonated request: %w", err)
+	}
+	impersonatedRequests.WithLabelValues("allowed").Inc()
+	return id, nil
+}
+
+// FinishAudit records the status an audited request was answered with
+func (s *ImpersonationService) FinishAudit(auditID int64, status int) {
+	if _, err := s.db.Exec(`
+		UPDATE ngs_impersonation_audit SET status = $1 WHERE id = $2
+	`, status, auditID); err != nil {
+		log.Printf("Failed to record status of impersonated request %d: %v", auditID, err)
+	}
+}
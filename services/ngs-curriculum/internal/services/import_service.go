@@ -0,0 +1,460 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// importBatchSize is the number of records written per transaction
+	importBatchSize = 500
+	// MaxImportRecords caps a single import request
+	MaxImportRecords = 50000
+	// importXPSource tags XP events created from legacy history
+	importXPSource = "legacy_import"
+)
+
+var (
+	ErrInvalidImport   = errors.New("invalid import")
+	ErrExternalIDTaken = errors.New("external ID is already mapped to another lesson")
+)
+
+var importRequiredFields = []string{"user_id", "lesson_external_id"}
+
+// importRow is a validated record ready to be written
+type importRow struct {
+	row         int
+	record      models.ImportRecord
+	userID      uuid.UUID
+	completedAt *time.Time
+}
+
+// importLesson is the NGS lesson an external ID maps to
+type importLesson struct {
+	id       uuid.UUID
+	xpReward int
+}
+
+type ImportService struct {
+	db     *database.DB
+	config *config.Config
+	clock  Clock
+	// leaderboards drops cached leaderboards after XP is imported; nil leaves them to expire
+	leaderboards LeaderboardInvalidator
+}
+
+func NewImportService(db *database.DB, cfg *config.Config, clock Clock) *ImportService {
+	return &ImportService{
+		db:     db,
+		config: cfg,
+		clock:  clock,
+	}
+}
+
+// ParseImportCSV reads import records from CSV with a header row. user_id and
+// lesson_external_id are required columns; score, time_spent_seconds, xp and completed_at are
+// optional. Rows with unparseable numbers are reported as errors and left out.
+func ParseImportCSV(r io.Reader) ([]models.ImportRecord, []int, []models.ImportError, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: missing CSV header", ErrInvalidImport)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range importRequiredFields {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, nil, fmt.Errorf("%w: CSV header must include %s", ErrInvalidImport, required)
+		}
+	}
+
+	field := func(fields []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(fields) {
+			return strings.TrimSpace(fields[i])
+		}
+		return ""
+	}
+	optionalInt := func(fields []string, name string) (*int, error) {
+		value := field(fields, name)
+		if value == "" {
+			return nil, nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a whole number", name)
+		}
+		return &n, nil
+	}
+
+	var records []models.ImportRecord
+	var rows []int
+	var parseErrors []models.ImportError
+	line := 1
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			parseErrors = append(parseErrors, models.ImportError{Row: line, Error: err.Error()})
+			continue
+		}
+
+		record := models.ImportRecord{
+			UserID:           field(fields, "user_id"),
+			LessonExternalID: field(fields, "lesson_external_id"),
+			CompletedAt:      field(fields, "completed_at"),
+		}
+		var fieldErr error
+		if record.Score, fieldErr = optionalInt(fields, "score"); fieldErr == nil {
+			if record.TimeSpentSeconds, fieldErr = optionalInt(fields, "time_spent_seconds"); fieldErr == nil {
+				record.XP, fieldErr = optionalInt(fields, "xp")
+			}
+		}
+		if fieldErr != nil {
+			parseErrors = append(parseErrors, models.ImportError{
+				Row: line, UserID: record.UserID, LessonExternalID: record.LessonExternalID, Error: fieldErr.Error(),
+			})
+			continue
+		}
+
+		records = append(records, record)
+		rows = append(rows, line)
+	}
+
+	return records, rows, parseErrors, nil
+}
+
+// ValidateImportRecord checks a record and parses its user ID and completion time, which must
+// not be after now
+func ValidateImportRecord(record models.ImportRecord, now time.Time) (uuid.UUID, *time.Time, error) {
+	userID, err := uuid.Parse(record.UserID)
+	if err != nil {
+		return uuid.Nil, nil, fmt.Errorf("user_id must be a UUID")
+	}
+	if record.LessonExternalID == "" {
+		return uuid.Nil, nil, fmt.Errorf("lesson_external_id is required")
+	}
+	if record.Score != nil && (*record.Score < 0 || *record.Score > 100) {
+		return uuid.Nil, nil, fmt.Errorf("score must be between 0 and 100")
+	}
+	if record.TimeSpentSeconds != nil && *record.TimeSpentSeconds < 0 {
+		return uuid.Nil, nil, fmt.Errorf("time_spent_seconds must not be negative")
+	}
+	if record.XP != nil && *record.XP < 0 {
+		return uuid.Nil, nil, fmt.Errorf("xp must not be negative")
+	}
+
+	if record.CompletedAt == "" {
+		return userID, nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, record.CompletedAt); err == nil {
+			if t.After(now) {
+				return uuid.Nil, nil, fmt.Errorf("completed_at must not be in the future")
+			}
+			t = t.UTC()
+			return userID, &t, nil
+		}
+	}
+	return uuid.Nil, nil, fmt.Errorf("completed_at must be RFC 3339 or YYYY-MM-DD")
+}
+
+// ImportUsers writes historical completions, XP events and progress for legacy LMS users.
+// Records are written in batches with a savepoint per record, so a bad record is reported
+// without failing its batch. Completions the user already has are skipped. In dry-run mode
+// every batch runs and is rolled back, so the report matches what a real run would do.
+func (s *ImportService) ImportUsers(records []models.ImportRecord, rowNumbers []int, dryRun bool) (*models.ImportReport, error) {
+	if len(records) > MaxImportRecords {
+		return nil, fmt.Errorf("%w: at most %d records per import", ErrInvalidImport, MaxImportRecords)
+	}
+
+	report := &models.ImportReport{DryRun: dryRun, TotalRecords: len(records), Errors: []models.ImportError{}}
+	fail := func(row int, record models.ImportRecord, msg string) {
+		report.Failed++
+		report.Errors = append(report.Errors, models.ImportError{
+			Row: row, UserID: record.UserID, LessonExternalID: record.LessonExternalID, Error: msg,
+		})
+	}
+
+	var valid []importRow
+	externalIDs := []string{}
+	seenExternal := make(map[string]bool)
+	seenRecord := make(map[string]bool)
+	now := s.clock.Now()
+	for i, record := range records {
+		row := i + 1
+		if i < len(rowNumbers) {
+			row = rowNumbers[i]
+		}
+		userID, completedAt, err := ValidateImportRecord(record, now)
+		if err != nil {
+			fail(row, record, err.Error())
+			continue
+		}
+		key := userID.String() + "|" + record.LessonExternalID
+		if seenRecord[key] {
+			fail(row, record, "duplicate of an earlier record in this import")
+			continue
+		}
+		seenRecord[key] = true
+		valid = append(valid, importRow{row: row, record: record, userID: userID, completedAt: completedAt})
+		if !seenExternal[record.LessonExternalID] {
+			seenExternal[record.LessonExternalID] = true
+			externalIDs = append(externalIDs, record.LessonExternalID)
+		}
+	}
+
+	lessons, err := s.lessonsByExternalID(externalIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make(map[uuid.UUID]bool)
+	for start := 0; start < len(valid); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(valid) {
+			end = len(valid)
+		}
+		batch := valid[start:end]
+
+		if err := s.importBatch(batch, lessons, dryRun, report, users, fail); err != nil {
+			log.Printf("Import batch starting at row %d failed: %v", batch[0].row, err)
+			for _, r := range batch {
+				fail(r.row, r.record, "batch failed: "+err.Error())
+			}
+		}
+	}
+	report.UsersAffected = len(users)
+
+	log.Printf("Legacy import (dry run: %t): %d records, %d imported, %d skipped, %d failed",
+		dryRun, report.TotalRecords, report.Imported, report.Skipped, report.Failed)
+	return report, nil
+}
+
+// lessonsByExternalID resolves external lesson IDs to NGS lessons
+func (s *ImportService) lessonsByExternalID(externalIDs []string) (map[string]importLesson, error) {
+	lessons := make(map[string]importLesson)
+	if len(externalIDs) == 0 {
+		return lessons, nil
+	}
+
+	rows, err := s.db.Query(`
+		SELECT external_id, id, xp_reward FROM lessons WHERE external_id = ANY($1)
+	`, pq.Array(externalIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve lesson external IDs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var externalID string
+		var lesson importLesson
+		if err := rows.Scan(&externalID, &lesson.id, &lesson.xpReward); err != nil {
+			return nil, fmt.Errorf("failed to scan lesson: %w", err)
+		}
+		lessons[externalID] = lesson
+	}
+	return lessons, rows.Err()
+}
+
+// importBatch writes one batch in a transaction; counts are only added to the report when the
+// batch commits (or, in dry-run mode, finishes)
+func (s *ImportService) importBatch(batch []importRow, lessons map[string]importLesson, dryRun bool,
+	report *models.ImportReport, users map[uuid.UUID]bool, fail func(int, models.ImportRecord, string)) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	type rowFailure struct {
+		row    importRow
+		reason string
+	}
+	var failures []rowFailure
+	imported, skipped := 0, 0
+	xpByUser := make(map[uuid.UUID]int)
+	touched := make(map[uuid.UUID]bool)
+
+	for _, r := range batch {
+		lesson, ok := lessons[r.record.LessonExternalID]
+		if !ok {
+			failures = append(failures, rowFailure{r, "no lesson with this external ID"})
+			continue
+		}
+		xp := lesson.xpReward
+		if r.record.XP != nil {
+			xp = *r.record.XP
+		}
+
+		if _, err := tx.Exec(`SAVEPOINT import_record`); err != nil {
+			return fmt.Errorf("failed to create savepoint: %w", err)
+		}
+		inserted, err := s.importRecord(tx, r, lesson, xp)
+		if err != nil {
+			if _, rbErr := tx.Exec(`ROLLBACK TO SAVEPOINT import_record`); rbErr != nil {
+				return fmt.Errorf("failed to roll back savepoint: %w", rbErr)
+			}
+			failures = append(failures, rowFailure{r, err.Error()})
+			continue
+		}
+		if _, err := tx.Exec(`RELEASE SAVEPOINT import_record`); err != nil {
+			return fmt.Errorf("failed to release savepoint: %w", err)
+		}
+
+		if !inserted {
+			skipped++
+			continue
+		}
+		imported++
+		xpByUser[r.userID] += xp
+		touched[r.userID] = true
+	}
+
+	for userID, xp := range xpByUser {
+		if err := s.applyImportedXP(tx, userID, xp); err != nil {
+			return err
+		}
+	}
+
+	if !dryRun {
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		if len(xpByUser) > 0 {
+			invalidateLeaderboards(s.leaderboards)
+		}
+	}
+
+	for _, f := range failures {
+		fail(f.row.row, f.row.record, f.reason)
+	}
+	report.Imported += imported
+	report.Skipped += skipped
+	for userID, xp := range xpByUser {
+		report.XPAwarded += xp
+		users[userID] = true
+	}
+	for userID := range touched {
+		users[userID] = true
+	}
+	return nil
+}
+
+// importRecord inserts one completion and its XP event; false means the user had already
+// completed the lesson
+func (s *ImportService) importRecord(tx *sql.Tx, r importRow, lesson importLesson, xp int) (bool, error) {
+	completionData, _ := json.Marshal(map[string]interface{}{
+		"source":             importXPSource,
+		"lesson_external_id": r.record.LessonExternalID,
+	})
+
+	var completionID uuid.UUID
+	err := tx.QueryRow(`
+		INSERT INTO lesson_completions (user_id, lesson_id, score, time_spent_seconds, completion_data, completed_at)
+		VALUES ($1, $2, $3, $4, $5, COALESCE($6, NOW()))
+		ON CONFLICT (user_id, lesson_id) DO NOTHING
+		RETURNING id
+	`, r.userID, lesson.id, r.record.Score, r.record.TimeSpentSeconds, completionData, r.completedAt).Scan(&completionID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to insert completion: %w", err)
+	}
+
+	if xp > 0 {
+		metadata, _ := json.Marshal(map[string]interface{}{
+			"lesson_id":          lesson.id,
+			"lesson_external_id": r.record.LessonExternalID,
+		})
+		_, err = tx.Exec(`
+			INSERT INTO xp_events (user_id, source, xp_awarded, metadata, created_at)
+			VALUES ($1, $2, $3, $4, COALESCE($5, NOW()))
+		`, r.userID, importXPSource, xp, metadata, r.completedAt)
+		if err != nil {
+			return false, fmt.Errorf("failed to record XP event: %w", err)
+		}
+	}
+
+	return true, nil
+}
+
+// applyImportedXP adds imported XP to a user's progress, creating it if needed. Levels follow
+// the level-down policy, and no level-up achievements are recorded for historical XP.
+func (s *ImportService) applyImportedXP(tx *sql.Tx, userID uuid.UUID, amount int) error {
+	var totalXP, currentLevel int
+	var agentUnlocked bool
+	err := tx.QueryRow(`
+		INSERT INTO user_progress (user_id, current_level, total_xp, agent_creation_unlocked)
+		VALUES ($1, 1, $2, false)
+		ON CONFLICT (user_id) DO UPDATE
+		SET total_xp = user_progress.total_xp + EXCLUDED.total_xp, updated_at = NOW()
+		RETURNING total_xp, current_level, agent_creation_unlocked
+	`, userID, amount).Scan(&totalXP, &currentLevel, &agentUnlocked)
+	if err != nil {
+		return fmt.Errorf("failed to update progress: %w", err)
+	}
+
+	policy := levelPolicy(s.config)
+	level := policy.Level(s.config.LevelUpXPThresholds, currentLevel, totalXP)
+	_, err = tx.Exec(`
+		UPDATE user_progress
+		SET current_level = $1, agent_creation_unlocked = $2
+		WHERE user_id = $3
+	`, level, policy.AgentUnlocked(agentUnlocked, level, s.config.AgentUnlockLevel), userID)
+	if err != nil {
+		return fmt.Errorf("failed to update level: %w", err)
+	}
+	return nil
+}
+
+// SetLessonExternalID maps a lesson to its legacy LMS identifier; an empty ID clears it
+func (s *ImportService) SetLessonExternalID(lessonID uuid.UUID, externalID string) error {
+	externalID = strings.TrimSpace(externalID)
+
+	var taken bool
+	if externalID != "" {
+		err := s.db.QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM lessons WHERE external_id = $1 AND id <> $2)
+		`, externalID, lessonID).Scan(&taken)
+		if err != nil {
+			return fmt.Errorf("failed to check external ID: %w", err)
+		}
+	}
+	if taken {
+		return ErrExternalIDTaken
+	}
+
+	result, err := s.db.Exec(`
+		UPDATE lessons SET external_id = NULLIF($1, ''), updated_at = NOW() WHERE id = $2
+	`, externalID, lessonID)
+	if err != nil {
+		return fmt.Errorf("failed to set lesson external ID: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrLessonNotFound
+	}
+	return nil
+}
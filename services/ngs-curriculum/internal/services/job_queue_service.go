@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/jobs"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidJobSettings = errors.New("invalid job queue settings")
+
+const (
+	// jobSettingsReload is how often each instance re-reads the admin overrides
+	jobSettingsReload = 30 * time.Second
+
+	maxJobWorkers        = 64
+	maxJobMaxWaitSeconds = 60 * 60
+)
+
+// DefaultJobSettings returns the job pool settings from JOB_WORKERS, JOB_MAX_WAIT_SECONDS and
+// JOB_CLASS_LIMITS
+func DefaultJobSettings(cfg *config.Config) (jobs.Settings, error) {
+	limits, err := jobs.ParseClassLimits(cfg.JobClassLimits)
+	if err != nil {
+		return jobs.Settings{}, fmt.Errorf("invalid JOB_CLASS_LIMITS: %w", err)
+	}
+	settings := jobs.Settings{
+		Workers:     cfg.JobWorkers,
+		MaxWait:     time.Duration(cfg.JobMaxWaitSeconds) * time.Second,
+		ClassLimits: limits,
+	}
+	if err := settings.Validate(); err != nil {
+		return jobs.Settings{}, fmt.Errorf("invalid job settings: %w", err)
+	}
+	return settings, nil
+}
+
+// ValidateJobQueueRequest checks job pool overrides before they are saved
+func ValidateJobQueueRequest(req models.SetJobQueueRequest) error {
+	if req.Workers != nil && (*req.Workers < 1 || *req.Workers > maxJobWorkers) {
+		return fmt.Errorf("%w: workers must be between 1 and %d", ErrInvalidJobSettings, maxJobWorkers)
+	}
+	if req.MaxWaitSeconds != nil && (*req.MaxWaitSeconds < 1 || *req.MaxWaitSeconds > maxJobMaxWaitSeconds) {
+		return fmt.Errorf("%w: max_wait_seconds must be between 1 and %d", ErrInvalidJobSettings, maxJobMaxWaitSeconds)
+	}
+	for class, limit := range req.ClassLimits {
+		if !jobs.IsClass(class) {
+			return fmt.Errorf("%w: unknown class %q", ErrInvalidJobSettings, class)
+		}
+		if limit < 0 || limit > maxJobWorkers {
+			return fmt.Errorf("%w: %s limit must be between 0 and %d", ErrInvalidJobSettings, class, maxJobWorkers)
+		}
+	}
+	return nil
+}
+
+// JobQueueService keeps the background job pool's settings in step with the admin overrides
+// stored in ngs_job_queue_settings
+type JobQueueService struct {
+	db       *database.DB
+	clock    Clock
+	defaults jobs.Settings
+	queue    *jobs.Queue
+
+	mu        sync.Mutex
+	updatedBy *uuid.UUID
+	updatedAt *time.Time
+
+	running sync.WaitGroup
+}
+
+func NewJobQueueService(db *database.DB, defaults jobs.Settings, queue *jobs.Queue, clock Clock) *JobQueueService {
+	return &JobQueueService{
+		db:       db,
+		clock:    clock,
+		defaults: defaults,
+		queue:    queue,
+	}
+}
+
+// Start applies the stored overrides, then re-reads them every jobSettingsReload until ctx is done
+func (s *JobQueueService) Start(ctx context.Context) {
+	s.reload()
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		ticker := time.NewTicker(jobSettingsReload)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.reload()
+			}
+		}
+	}()
+}
+
+// Wait blocks until the reloader started by Start has stopped
+func (s *JobQueueService) Wait() {
+	s.running.Wait()
+}
+
+// reload applies the stored overrides, keeping the current settings if they cannot be read
+func (s *JobQueueService) reload() {
+	if err := s.apply(); err != nil {
+		log.Printf("Failed to reload job queue settings, keeping current ones: %v", err)
+	}
+}
+
+// Status returns the pool's settings and each class's running and queued jobs
+func (s *JobQueueService) Status() models.JobQueueStatus {
+	settings := s.queue.Settings()
+	status := models.JobQueueStatus{
+		Workers:        settings.Workers,
+		MaxWaitSeconds: int(settings.MaxWait / time.Second),
+		Classes:        []models.JobClassStatus{},
+	}
+	for _, class := range s.queue.Stats() {
+		status.Classes = append(status.Classes, models.JobClassStatus{
+			Class:         class.Class,
+			MaxConcurrent: class.MaxConcurrent,
+			Running:       class.Running,
+			Queued:        class.Queued,
+			OldestWaitMs:  class.OldestWait.Milliseconds(),
+		})
+	}
+
+	s.mu.Lock()
+	status.UpdatedBy, status.UpdatedAt = s.updatedBy, s.updatedAt
+	s.mu.Unlock()
+	return status
+}
+
+// SetSettings stores overrides for every instance and applies them to this one straight away
+func (s *JobQueueService) SetSettings(userID uuid.UUID, req models.SetJobQueueRequest) (*models.JobQueueStatus, error) {
+	if err := ValidateJobQueueRequest(req); err != nil {
+		return nil, err
+	}
+
+	limits := req.ClassLimits
+	if limits == nil {
+		limits = map[string]int{}
+	}
+	encoded, err := json.Marshal(limits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode class limits: %w", err)
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO ngs_job_queue_settings (id, workers, max_wait_seconds, class_limits, updated_by, updated_at)
+		VALUES (true, $1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE
+		SET workers = EXCLUDED.workers, max_wait_seconds = EXCLUDED.max_wait_seconds,
+		    class_limits = EXCLUDED.class_limits,
+		    updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at
+	`, req.Workers, req.MaxWaitSeconds, encoded, userID, s.clock.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to update job queue settings: %w", err)
+	}
+
+	if err := s.apply(); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Admin %s changed the job queue settings", userID)
+	status := s.Status()
+	return &status, nil
+}
+
+// apply reads the stored overrides and configures the queue with them on top of the defaults
+func (s *JobQueueService) apply() error {
+	var workers, maxWait sql.NullInt64
+	var encoded []byte
+	var updatedBy uuid.NullUUID
+	var updatedAt time.Time
+	err := s.db.QueryRow(`
+		SELECT workers, max_wait_seconds, class_limits, updated_by, updated_at
+		FROM ngs_job_queue_settings
+		WHERE id
+	`).Scan(&workers, &maxWait, &encoded, &updatedBy, &updatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read job queue settings: %w", err)
+	}
+
+	var overrides models.SetJobQueueRequest
+	if err == nil {
+		if err := json.Unmarshal(encoded, &overrides.ClassLimits); err != nil {
+			return fmt.Errorf("failed to decode class limits: %w", err)
+		}
+		if workers.Valid {
+			n := int(workers.Int64)
+			overrides.Workers = &n
+		}
+		if maxWait.Valid {
+			n := int(maxWait.Int64)
+			overrides.MaxWaitSeconds = &n
+		}
+	}
+	if err := s.queue.Configure(MergeJobSettings(s.defaults, overrides)); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidJobSettings, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if updatedBy.Valid {
+		s.updatedBy, s.updatedAt = &updatedBy.UUID, &updatedAt
+	} else {
+		s.updatedBy, s.updatedAt = nil, nil
+	}
+	return nil
+}
+
+// MergeJobSettings applies overrides on top of the default settings
+func MergeJobSettings(defaults jobs.Settings, req models.SetJobQueueRequest) jobs.Settings {
+	settings := jobs.Settings{
+		Workers:     defaults.Workers,
+		MaxWait:     defaults.MaxWait,
+		ClassLimits: make(map[string]int, len(defaults.ClassLimits)+len(req.ClassLimits)),
+	}
+	if req.Workers != nil {
+		settings.Workers = *req.Workers
+	}
+	if req.MaxWaitSeconds != nil {
+		settings.MaxWait = time.Duration(*req.MaxWaitSeconds) * time.Second
+	}
+	for class, limit := range defaults.ClassLimits {
+		settings.ClassLimits[class] = limit
+	}
+	for class, limit := range req.ClassLimits {
+		settings.ClassLimits[class] = limit
+	}
+	return settings
+}
@@ -0,0 +1,324 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/jobs"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Dead letter states
+const (
+	DeadLetterDead      = "dead"
+	DeadLetterRetrying  = "retrying"
+	DeadLetterResolved  = "resolved"
+	DeadLetterDiscarded = "discarded"
+)
+
+var (
+	ErrUnknownJobKind     = errors.New("unknown job kind")
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	ErrDeadLetterState    = errors.New("dead letter is not waiting for a retry")
+	ErrInvalidDeadLetter  = errors.New("invalid dead letter filter")
+)
+
+// staleRetryAfter lets a retry orphaned by a restart be retried again
+const staleRetryAfter = 15 * time.Minute
+
+var (
+	deadLetters = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_dead_letters_total",
+			Help: "Background jobs that failed and were kept as dead letters, by kind.",
+		},
+		[]string{"kind"},
+	)
+
+	deadLetterRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_dead_letter_retries_total",
+			Help: "Admin retries of dead letters, by kind and outcome (resolved or failed).",
+		},
+		[]string{"kind", "outcome"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(deadLetters, deadLetterRetries)
+}
+
+// JobHandler runs one job of a kind from its payload
+type JobHandler func(ctx context.Context, payload json.RawMessage) error
+
+type jobKind struct {
+	class   string
+	handler JobHandler
+}
+
+// JobService runs background jobs on the shared queue by kind. A job that fails is kept in
+// ngs_dead_letters with its payload and error, so an admin can retry it or discard it instead
+// of it only appearing in the logs.
+type JobService struct {
+	db    *database.DB
+	queue *jobs.Queue
+	clock Clock
+
+	mu    sync.RWMutex
+	kinds map[string]jobKind
+}
+
+func NewJobService(db *database.DB, queue *jobs.Queue, clock Clock) *JobService {
+	return &JobService{
+		db:    db,
+		queue: queue,
+		clock: clock,
+		kinds: make(map[string]jobKind),
+	}
+}
+
+// Register makes kind runnable under class. Services register their kinds when they are
+// created, before any of their jobs are submitted or retried.
+func (s *JobService) Register(kind, class string, handler JobHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.kinds[kind] = jobKind{class: class, handler: handler}
+}
+
+func (s *JobService) kind(kind string) (jobKind, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.kinds[kind]
+	if !ok {
+		return jobKind{}, fmt.Errorf("%w %q", ErrUnknownJobKind, kind)
+	}
+	return k, nil
+}
+
+// Submit queues a job of kind. payload is stored as JSON if the job fails, so it must carry
+// everything the handler needs. name identifies the job in logs.
+func (s *JobService) Submit(kind, name string, payload interface{}) error {
+	k, err := s.kind(kind)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s payload: %w", kind, err)
+	}
+
+	return s.queue.Submit(k.class, name, func(ctx context.Context) {
+		if err := k.handler(ctx, encoded); err != nil {
+			s.deadLetter(kind, k.class, name, encoded, err)
+		}
+	})
+}
+
+// deadLetter keeps a failed job for an admin
+func (s *JobService) deadLetter(kind, class, name string, payload json.RawMessage, jobErr error) {
+	deadLetters.WithLabelValues(kind).Inc()
+	now := s.clock.Now()
+	var id int64
+	err := s.db.QueryRow(`
+		INSERT INTO ngs_dead_letters (kind, class, payload, error, first_failed_at, last_failed_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		RETURNING id
+	`, kind, class, []byte(payload), jobErr.Error(), now).Scan(&id)
+	if err != nil {
+		log.Printf("Failed to keep dead letter for job %s (%s): %v; job error: %v", name, kind, err, jobErr)
+		return
+	}
+	log.Printf("Job %s (%s) failed and was kept as dead letter %d: %v", name, kind, id, jobErr)
+}
+
+const deadLetterColumns = `id, kind, class, payload, error, attempts, status, first_failed_at, last_failed_at,
+	retried_by, retried_at, resolved_at, discarded_by, discarded_at`
+
+func scanDeadLetter(row rowScanner) (*models.DeadLetter, error) {
+	var d models.DeadLetter
+	var payload []byte
+	var retriedBy, discardedBy uuid.NullUUID
+	var retriedAt, resolvedAt, discardedAt sql.NullTime
+	err := row.Scan(
+		&d.ID, &d.Kind, &d.Class, &payload, &d.Error, &d.Attempts, &d.Status, &d.FirstFailedAt, &d.LastFailedAt,
+		&retriedBy, &retriedAt, &resolvedAt, &discardedBy, &discardedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	d.Payload = json.RawMessage(payload)
+	if retriedBy.Valid {
+		d.RetriedBy = &retriedBy.UUID
+	}
+	if retriedAt.Valid {
+		d.RetriedAt = &retriedAt.Time
+	}
+	if resolvedAt.Valid {
+		d.ResolvedAt = &resolvedAt.Time
+	}
+	if discardedBy.Valid {
+		d.DiscardedBy = &discardedBy.UUID
+	}
+	if discardedAt.Valid {
+		d.DiscardedAt = &discardedAt.Time
+	}
+	return &d, nil
+}
+
+// ValidateDeadLetterStatus checks a status filter; empty lists every status
+func ValidateDeadLetterStatus(status string) error {
+	switch status {
+	case "", DeadLetterDead, DeadLetterRetrying, DeadLetterResolved, DeadLetterDiscarded:
+		return nil
+	}
+	return fmt.Errorf("%w: status must be dead, retrying, resolved or discarded", ErrInvalidDeadLetter)
+}
+
+// ListDeadLetters lists dead letters, newest first, optionally only those with status or kind
+func (s *JobService) ListDeadLetters(status, kind string, limit int) ([]models.DeadLetter, error) {
+	if err := ValidateDeadLetterStatus(status); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+deadLetterColumns+`
+		FROM ngs_dead_letters
+		WHERE ($1 = '' OR status = $1) AND ($2 = '' OR kind = $2)
+		ORDER BY id DESC
+		LIMIT $3
+	`, status, kind, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	letters := []models.DeadLetter{}
+	for rows.Next() {
+		d, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dead letter: %w", err)
+		}
+		letters = append(letters, *d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dead letters: %w", err)
+	}
+
+	return letters, nil
+}
+
+// missingOrState tells apart a dead letter that does not exist from one in the wrong state
+func (s *JobService) missingOrState(id int64) error {
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM ngs_dead_letters WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to query dead letter: %w", err)
+	}
+	if !exists {
+		return ErrDeadLetterNotFound
+	}
+	return ErrDeadLetterState
+}
+
+// Retry runs a dead letter's job again. The dead letter is retrying until the job finishes,
+// then resolved, or dead again with the new error. A retry orphaned by a restart may be retried
+// once it is staleRetryAfter old.
+func (s *JobService) Retry(id int64, adminID uuid.UUID) (*models.DeadLetter, error) {
+	now := s.clock.Now()
+	d, err := scanDeadLetter(s.db.QueryRow(`
+		UPDATE ngs_dead_letters
+		SET status = 'retrying', retried_by = $2, retried_at = $3
+		WHERE id = $1
+		  AND (status = 'dead' OR (status = 'retrying' AND retried_at < $4))
+		RETURNING `+deadLetterColumns,
+		id, adminID, now, now.Add(-staleRetryAfter),
+	))
+	if err == sql.ErrNoRows {
+		return nil, s.missingOrState(id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim dead letter: %w", err)
+	}
+
+	k, err := s.kind(d.Kind)
+	if err == nil {
+		name := fmt.Sprintf("retry dead letter %d", d.ID)
+		err = s.queue.Submit(k.class, name, func(ctx context.Context) {
+			s.finishRetry(d.ID, d.Kind, k.handler(ctx, d.Payload))
+		})
+	}
+	if err != nil {
+		s.release(d.ID)
+		return nil, err
+	}
+
+	log.Printf("Admin %s retried dead letter %d (%s)", adminID, d.ID, d.Kind)
+	return d, nil
+}
+
+// release puts a dead letter whose retry could not be queued back to dead
+func (s *JobService) release(id int64) {
+	if _, err := s.db.Exec(`
+		UPDATE ngs_dead_letters SET status = 'dead', retried_by = NULL, retried_at = NULL
+		WHERE id = $1 AND status = 'retrying'
+	`, id); err != nil {
+		log.Printf("Failed to release dead letter %d: %v", id, err)
+	}
+}
+
+// finishRetry records how a retry ended
+func (s *JobService) finishRetry(id int64, kind string, jobErr error) {
+	now := s.clock.Now()
+	var err error
+	if jobErr == nil {
+		deadLetterRetries.WithLabelValues(kind, "resolved").Inc()
+		_, err = s.db.Exec(`
+			UPDATE ngs_dead_letters SET status = 'resolved', resolved_at = $2
+			WHERE id = $1 AND status = 'retrying'
+		`, id, now)
+		log.Printf("Dead letter %d (%s) resolved by retry", id, kind)
+	} else {
+		deadLetterRetries.WithLabelValues(kind, "failed").Inc()
+		_, err = s.db.Exec(`
+			UPDATE ngs_dead_letters
+			SET status = 'dead', error = $2, attempts = attempts + 1, last_failed_at = $3
+			WHERE id = $1 AND status = 'retrying'
+		`, id, jobErr.Error(), now)
+		log.Printf("Retry of dead letter %d (%s) failed: %v", id, kind, jobErr)
+	}
+	if err != nil {
+		log.Printf("Failed to record retry of dead letter %d: %v", id, err)
+	}
+}
+
+// Discard gives up on a dead letter; it stays listed as discarded
+func (s *JobService) Discard(id int64, adminID uuid.UUID) (*models.DeadLetter, error) {
+	d, err := scanDeadLetter(s.db.QueryRow(`
+		UPDATE ngs_dead_letters
+		SET status = 'discarded', discarded_by = $2, discarded_at = $3
+		WHERE id = $1 AND status = 'dead'
+		RETURNING `+deadLetterColumns,
+		id, adminID, s.clock.Now(),
+	))
+	if err == sql.ErrNoRows {
+		return nil, s.missingOrState(id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to discard dead letter: %w", err)
+	}
+
+	log.Printf("Admin %s discarded dead letter %d (%s)", adminID, d.ID, d.Kind)
+	return d, nil
+}
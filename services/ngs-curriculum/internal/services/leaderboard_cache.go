@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// leaderboardGenerationKey holds the current leaderboard generation. Cached boards are keyed by
+// it, so starting a new generation drops every board at once, whatever its period or limit.
+const leaderboardGenerationKey = "leaderboard_generation"
+
+// leaderboardGenerationTTL keeps a generation far longer than any board cached under it
+const leaderboardGenerationTTL = 24 * time.Hour
+
+// LeaderboardInvalidator drops cached leaderboards once XP has been awarded; *ProgressService
+// implements it
+type LeaderboardInvalidator interface {
+	InvalidateLeaderboards()
+}
+
+// InvalidateLeaderboards starts a new leaderboard generation, so the next read of each board
+// goes to the store. Call it after the transaction awarding XP commits; invalidating earlier
+// could cache the board as it was before the award. A failure is logged, and the boards then
+// expire after LeaderboardCacheSeconds as usual.
+func (s *ProgressService) InvalidateLeaderboards() {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.Set(context.Background(), leaderboardGenerationKey, []byte(uuid.NewString()), leaderboardGenerationTTL); err != nil {
+		log.Printf("Failed to invalidate leaderboards: %v", err)
+	}
+}
+
+// leaderboardKey returns the cache key of a board in the current generation
+func (s *ProgressService) leaderboardKey(format string, args ...interface{}) string {
+	generation := "0"
+	if s.cache != nil {
+		data, ok, err := s.cache.Get(context.Background(), leaderboardGenerationKey)
+		if err != nil {
+			log.Printf("Cache read %s failed: %v", leaderboardGenerationKey, err)
+		} else if ok {
+			generation = string(data)
+		}
+	}
+	return "leaderboard:" + generation + ":" + fmt.Sprintf(format, args...)
+}
+
+// invalidateLeaderboards invalidates through invalidator, if there is one
+func invalidateLeaderboards(invalidator LeaderboardInvalidator) {
+	if invalidator != nil {
+		invalidator.InvalidateLeaderboards()
+	}
+}
+
+// SetLeaderboardInvalidator has cached leaderboards dropped whenever lesson completions or
+// reflections award XP
+func (s *LessonService) SetLeaderboardInvalidator(invalidator LeaderboardInvalidator) {
+	s.leaderboards = invalidator
+}
+
+// SetLeaderboardInvalidator has cached leaderboards dropped whenever challenges award XP
+func (s *ChallengeService) SetLeaderboardInvalidator(invalidator LeaderboardInvalidator) {
+	s.leaderboards = invalidator
+}
+
+// SetLeaderboardInvalidator has cached leaderboards dropped after imports that add XP
+func (s *ImportService) SetLeaderboardInvalidator(invalidator LeaderboardInvalidator) {
+	s.leaderboards = invalidator
+}
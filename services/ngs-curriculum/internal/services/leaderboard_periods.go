@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"noble-ngs-curriculum/internal/cache"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Leaderboard periods
+const (
+	LeaderboardAllTime = "all"
+	LeaderboardDaily   = "daily"
+	LeaderboardWeekly  = "weekly"
+	LeaderboardMonthly = "monthly"
+)
+
+var ErrInvalidLeaderboardPeriod = errors.New("period must be daily, weekly, monthly or all")
+
+// LeaderboardPeriod is the window a leaderboard ranks XP earned in. Windows are calendar days,
+// ISO weeks (from Monday) and months in UTC, so everyone competes over the same window and
+// it resets for everyone at once. The all-time board has no window.
+type LeaderboardPeriod struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+// AllTime reports whether the period ranks lifetime XP
+func (p LeaderboardPeriod) AllTime() bool {
+	return p.Name == LeaderboardAllTime
+}
+
+// ParseLeaderboardPeriod returns the named period's window around now; empty is all-time
+func ParseLeaderboardPeriod(name string, now time.Time) (LeaderboardPeriod, error) {
+	day := dayOf(now)
+	switch name {
+	case "", LeaderboardAllTime:
+		return LeaderboardPeriod{Name: LeaderboardAllTime}, nil
+	case LeaderboardDaily:
+		return LeaderboardPeriod{Name: name, Start: day, End: day.AddDate(0, 0, 1)}, nil
+	case LeaderboardWeekly:
+		// Monday is day 0 of the week
+		start := day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		return LeaderboardPeriod{Name: name, Start: start, End: start.AddDate(0, 0, 7)}, nil
+	case LeaderboardMonthly:
+		start := monthOf(day)
+		return LeaderboardPeriod{Name: name, Start: start, End: start.AddDate(0, 1, 0)}, nil
+	}
+	return LeaderboardPeriod{}, ErrInvalidLeaderboardPeriod
+}
+
+// LeaderboardPeriod returns the named period's current window
+func (s *ProgressService) LeaderboardPeriod(name string) (LeaderboardPeriod, error) {
+	return ParseLeaderboardPeriod(name, s.clock.Now())
+}
+
+// GetPeriodLeaderboard ranks users by the XP they earned in the period, leaving out the same
+// users as GetLeaderboard and users who earned none. The all-time period is GetLeaderboard.
+func (s *ProgressService) GetPeriodLeaderboard(period LeaderboardPeriod, limit int) ([]models.LeaderboardEntry, error) {
+	if period.AllTime() {
+		return s.GetLeaderboard(limit)
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	ttl := time.Duration(s.config.LeaderboardCacheSeconds) * time.Second
+	if ttl <= 0 {
+		return s.withProfiles(leaderboardWithPrestige(s.store.PeriodLeaderboard(period.Start, period.End, limit)))
+	}
+	// The window is in the key, so a cached board is not served into the next window
+	key := s.leaderboardKey("%s:%s:%d", period.Name, period.Start.Format("2006-01-02"), limit)
+	return s.withProfiles(cache.Load(context.Background(), s.cache, key, ttl, func() ([]models.LeaderboardEntry, error) {
+		return leaderboardWithPrestige(s.store.PeriodLeaderboard(period.Start, period.End, limit))
+	}))
+}
+
+// MaxLeaderboardNeighbors caps how many users GetLeaderboardPosition returns on either side
+const MaxLeaderboardNeighbors = 10
+
+// GetLeaderboardPosition finds the user on the period's leaderboard, with up to neighbors users
+// ranked just above and below. It is not cached, so users see their own XP straight away.
+func (s *ProgressService) GetLeaderboardPosition(userID uuid.UUID, period LeaderboardPeriod, neighbors int) (models.LeaderboardPosition, error) {
+	if neighbors < 0 {
+		neighbors = 0
+	}
+	if neighbors > MaxLeaderboardNeighbors {
+		neighbors = MaxLeaderboardNeighbors
+	}
+
+	var entries []models.LeaderboardEntry
+	var total int
+	var err error
+	if period.AllTime() {
+		entries, total, err = s.store.LeaderboardAround(userID, neighbors)
+	} else {
+		entries, total, err = s.store.PeriodLeaderboardAround(userID, period.Start, period.End, neighbors)
+	}
+	if err == nil {
+		entries, err = s.withProfiles(leaderboardWithPrestige(entries, nil))
+	}
+	if err != nil {
+		return models.LeaderboardPosition{}, err
+	}
+
+	position := models.LeaderboardPosition{Above: []models.LeaderboardEntry{}, Below: []models.LeaderboardEntry{}}
+	for i := range entries {
+		if entries[i].UserID != userID {
+			continue
+		}
+		position.Ranked = true
+		position.Me = &entries[i]
+		position.TotalRanked = total
+		for j := i - 1; j >= 0; j-- {
+			position.Above = append(position.Above, entries[j])
+		}
+		position.Below = append(position.Below, entries[i+1:]...)
+		break
+	}
+	return position, nil
+}
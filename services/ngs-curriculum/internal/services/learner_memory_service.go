@@ -0,0 +1,385 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"noble-ngs-curriculum/internal/clients/intelligence"
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/jobs"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// JobChatSummary is the job kind of summarizing a finished tutor chat session
+	JobChatSummary = "chat_summary"
+
+	// LearnerMemoryMaxChars bounds a learner's memory
+	LearnerMemoryMaxChars = 4000
+)
+
+const (
+	// chatSummaryMaxTurns is how many of a session's latest unsummarized exchanges are sent
+	chatSummaryMaxTurns = 100
+	// chatMemoryLookback is how far back the scan looks for quiet sessions; older sessions left
+	// unsummarized, e.g. while the scan was disabled, are not picked up
+	chatMemoryLookback = 7 * 24 * time.Hour
+	// chatMemoryBatch bounds the sessions queued by one scan
+	chatMemoryBatch = 100
+	// chatMemoryAttempts bounds how often a summary is redone because another session of the
+	// same learner was folded into their memory first
+	chatMemoryAttempts = 3
+	// chatSummaryTimeout bounds summarizing one session
+	chatSummaryTimeout = 2 * time.Minute
+	// chatSummaryUserRole is the role summaries run as; they run in the background on the
+	// learner's behalf
+	chatSummaryUserRole = "student"
+)
+
+var errLearnerMemoryChanged = errors.New("learner memory changed while the session was summarized")
+
+var chatSessionSummaries = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ngs_chat_session_summaries_total",
+		Help: "Tutor chat sessions summarized into learner memory, by outcome: summarized or failed.",
+	},
+	[]string{"outcome"},
+)
+
+func init() {
+	prometheus.MustRegister(chatSessionSummaries)
+}
+
+// ChatSummarizer summarizes tutor chat sessions; *intelligence.Client is one
+type ChatSummarizer interface {
+	SummarizeChatSession(ctx context.Context, req intelligence.SummarizeChatSessionRequest, userID, userEmail, userRole string) (*intelligence.SummarizeChatSessionResponse, error)
+}
+
+// LearnerMemoryService gives the tutor continuity across sessions and levels. Chat sessions
+// that have gone quiet are summarized, and each summary is folded into a rolling memory per
+// learner that seeds new chat sessions.
+type LearnerMemoryService struct {
+	db         *database.DB
+	clock      Clock
+	summarizer ChatSummarizer
+	// jobService runs summaries as generation jobs, so they queue behind nothing interactive
+	jobService *JobService
+	// interval is how often quiet sessions are looked for; 0 never looks
+	interval time.Duration
+	// idle is how long a session must be quiet to count as finished
+	idle    time.Duration
+	running sync.WaitGroup
+}
+
+func NewLearnerMemoryService(db *database.DB, cfg *config.Config, clock Clock, summarizer ChatSummarizer, jobService *JobService) *LearnerMemoryService {
+	s := &LearnerMemoryService{
+		db:         db,
+		clock:      clock,
+		summarizer: summarizer,
+		jobService: jobService,
+		interval:   time.Duration(cfg.ChatMemoryIntervalMinutes) * time.Minute,
+		idle:       time.Duration(cfg.ChatSessionIdleMinutes) * time.Minute,
+	}
+	jobService.Register(JobChatSummary, jobs.ClassGeneration, s.RunChatSummary)
+	return s
+}
+
+// chatSummaryJob is the payload of a chat summary job, kept with its dead letter if it fails
+type chatSummaryJob struct {
+	SessionID uuid.UUID `json:"session_id"`
+}
+
+// Start looks for finished chat sessions now and then on the configured interval until ctx is
+// cancelled
+func (s *LearnerMemoryService) Start(ctx context.Context) {
+	if s.interval <= 0 {
+		log.Println("Chat session summaries disabled")
+		return
+	}
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			if _, err := s.QueueFinishedSessions(); err != nil {
+				log.Printf("Failed to queue chat session summaries: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Printf("Chat session summaries queued every %s", s.interval)
+}
+
+// Wait blocks until the loop started by Start has stopped
+func (s *LearnerMemoryService) Wait() {
+	s.running.Wait()
+}
+
+// QueueFinishedSessions queues a summary of each chat session that has been quiet for the idle
+// time and has exchanges not yet summarized, and returns how many were queued. One instance
+// scans at a time; a session queued twice is still summarized once.
+func (s *LearnerMemoryService) QueueFinishedSessions() (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	locked, err := database.TryXactLock(tx, "ngs_chat_memory")
+	if err != nil || !locked {
+		return 0, err
+	}
+
+	now := s.clock.Now()
+	rows, err := tx.Query(`
+		SELECT m.session_id
+		FROM ngs_chat_messages m
+		LEFT JOIN ngs_chat_session_summaries ss ON ss.session_id = m.session_id
+		WHERE m.session_id IN (SELECT session_id FROM ngs_chat_messages WHERE created_at >= $1)
+		GROUP BY m.session_id, ss.message_count
+		HAVING MAX(m.created_at) < $2 AND COUNT(*) > COALESCE(ss.message_count, 0)
+		ORDER BY MAX(m.created_at)
+		LIMIT $3
+	`, now.Add(-chatMemoryLookback), now.Add(-s.idle), chatMemoryBatch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find finished chat sessions: %w", err)
+	}
+	var sessions []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan chat session: %w", err)
+		}
+		sessions = append(sessions, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read chat sessions: %w", err)
+	}
+
+	queued := 0
+	for _, id := range sessions {
+		if err := s.jobService.Submit(JobChatSummary, "summarize chat session "+id.String(), chatSummaryJob{SessionID: id}); err != nil {
+			// Picked up again by the next scan
+			log.Printf("Chat session %s summary not queued: %v", id, err)
+			continue
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+// chatSession is a chat session's exchanges and what is already summarized of it
+type chatSession struct {
+	userID      uuid.UUID
+	lessonID    uuid.UUID
+	lessonTitle string
+	level       int
+	turns       []intelligence.ChatTurn
+	lastAt      time.Time
+	// summarized is how many of turns the stored summary covers
+	summarized      int
+	previousSummary string
+}
+
+// RunChatSummary summarizes the exchanges of a chat session not summarized yet and folds them
+// into the learner's memory. A failed summary is returned, so the job is kept as a dead letter
+// for an admin to retry.
+func (s *LearnerMemoryService) RunChatSummary(ctx context.Context, payload json.RawMessage) error {
+	var job chatSummaryJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return fmt.Errorf("invalid chat summary payload: %w", err)
+	}
+
+	session, err := s.loadChatSession(job.SessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.summarized >= len(session.turns) {
+		// Deleted with its lesson, or summarized by an earlier job
+		return nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = s.summarize(ctx, job.SessionID, session)
+		if !errors.Is(err, errLearnerMemoryChanged) || attempt == chatMemoryAttempts {
+			break
+		}
+	}
+	if err != nil {
+		chatSessionSummaries.WithLabelValues("failed").Inc()
+		return fmt.Errorf("failed to summarize chat session %s: %w", job.SessionID, err)
+	}
+	chatSessionSummaries.WithLabelValues("summarized").Inc()
+	return nil
+}
+
+// loadChatSession reads a session's exchanges, oldest first, or returns nil when it has none
+func (s *LearnerMemoryService) loadChatSession(sessionID uuid.UUID) (*chatSession, error) {
+	rows, err := s.db.Query(`
+		SELECT m.user_id, m.lesson_id, l.title, l.level_id, m.message, m.response, m.created_at
+		FROM ngs_chat_messages m
+		JOIN lessons l ON l.id = m.lesson_id
+		WHERE m.session_id = $1
+		ORDER BY m.created_at, m.id
+	`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chat session %s: %w", sessionID, err)
+	}
+	defer rows.Close()
+
+	session := &chatSession{}
+	for rows.Next() {
+		var turn intelligence.ChatTurn
+		if err := rows.Scan(&session.userID, &session.lessonID, &session.lessonTitle, &session.level,
+			&turn.Message, &turn.Response, &session.lastAt); err != nil {
+			return nil, fmt.Errorf("failed to scan chat message: %w", err)
+		}
+		session.turns = append(session.turns, turn)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read chat session %s: %w", sessionID, err)
+	}
+	if len(session.turns) == 0 {
+		return nil, nil
+	}
+
+	err = s.db.QueryRow(`
+		SELECT message_count, summary FROM ngs_chat_session_summaries WHERE session_id = $1
+	`, sessionID).Scan(&session.summarized, &session.previousSummary)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to load chat session summary %s: %w", sessionID, err)
+	}
+	return session, nil
+}
+
+// summarize asks the intelligence service to fold the session into the learner's current
+// memory and stores both. It returns errLearnerMemoryChanged when another session of the
+// learner was folded in meanwhile, so the summary is redone on top of it.
+func (s *LearnerMemoryService) summarize(ctx context.Context, sessionID uuid.UUID, session *chatSession) error {
+	memory, err := s.LearnerMemory(session.userID)
+	if err != nil {
+		return err
+	}
+
+	turns := session.turns[session.summarized:]
+	if len(turns) > chatSummaryMaxTurns {
+		turns = turns[len(turns)-chatSummaryMaxTurns:]
+	}
+	ctx, cancel := context.WithTimeout(ctx, chatSummaryTimeout)
+	defer cancel()
+	resp, err := s.summarizer.SummarizeChatSession(ctx, intelligence.SummarizeChatSessionRequest{
+		LessonTitle:     session.lessonTitle,
+		LevelNumber:     session.level,
+		Turns:           turns,
+		PreviousSummary: session.previousSummary,
+		LearnerMemory:   memory.Summary,
+		MaxMemoryChars:  LearnerMemoryMaxChars,
+	}, session.userID.String(), "", chatSummaryUserRole)
+	if err != nil {
+		return err
+	}
+	learnerMemory := strings.TrimSpace(resp.LearnerMemory)
+	if learnerMemory == "" {
+		return errors.New("intelligence service returned an empty learner memory")
+	}
+	if runes := []rune(learnerMemory); len(runes) > LearnerMemoryMaxChars {
+		learnerMemory = string(runes[:LearnerMemoryMaxChars])
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := s.clock.Now()
+	var stored bool
+	err = tx.QueryRow(`
+		INSERT INTO ngs_chat_session_summaries (session_id, user_id, lesson_id, summary, message_count, last_message_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT (session_id) DO UPDATE
+		SET summary = EXCLUDED.summary, message_count = EXCLUDED.message_count,
+			last_message_at = EXCLUDED.last_message_at, updated_at = EXCLUDED.updated_at
+		WHERE ngs_chat_session_summaries.message_count < EXCLUDED.message_count
+		RETURNING true
+	`, sessionID, session.userID, session.lessonID, strings.TrimSpace(resp.SessionSummary), len(session.turns), session.lastAt, now).Scan(&stored)
+	if err == sql.ErrNoRows {
+		// Another job summarized the same exchanges first
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store chat session summary: %w", err)
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO ngs_learner_memory (user_id, summary, sessions_summarized, updated_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET summary = EXCLUDED.summary, sessions_summarized = ngs_learner_memory.sessions_summarized + 1,
+			updated_at = EXCLUDED.updated_at
+		WHERE ngs_learner_memory.sessions_summarized = $4
+		RETURNING true
+	`, session.userID, learnerMemory, now, memory.SessionsSummarized).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return errLearnerMemoryChanged
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store learner memory: %w", err)
+	}
+	return tx.Commit()
+}
+
+// LearnerMemory returns what the tutor remembers about a learner; it is empty until their first
+// chat session has been summarized
+func (s *LearnerMemoryService) LearnerMemory(userID uuid.UUID) (*models.LearnerMemory, error) {
+	memory := &models.LearnerMemory{}
+	var updatedAt time.Time
+	err := s.db.QueryRow(`
+		SELECT summary, sessions_summarized, updated_at FROM ngs_learner_memory WHERE user_id = $1
+	`, userID).Scan(&memory.Summary, &memory.SessionsSummarized, &updatedAt)
+	if err == sql.ErrNoRows {
+		return memory, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get learner memory: %w", err)
+	}
+	memory.UpdatedAt = &updatedAt
+	return memory, nil
+}
+
+// ForgetLearnerMemory clears a learner's memory and the summaries of their sessions. Sessions
+// already summarized are not summarized again; later ones start a new memory.
+func (s *LearnerMemoryService) ForgetLearnerMemory(userID uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM ngs_learner_memory WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete learner memory: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE ngs_chat_session_summaries SET summary = '' WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to clear chat session summaries: %w", err)
+	}
+	return tx.Commit()
+}
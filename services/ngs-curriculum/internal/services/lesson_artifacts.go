@@ -0,0 +1,199 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Study artifact types served by GET /ngs/lessons/:id/artifacts?type=
+const (
+	StudyArtifactNotes    = "notes"
+	StudyArtifactSnippets = "snippets"
+)
+
+var ErrInvalidArtifactType = errors.New("artifact type must be notes or snippets")
+
+// generatedArtifactTypes are the artifact types a structured lesson carries, as stored in
+// lesson_artifacts
+var generatedArtifactTypes = []string{"quiz_items", "notes_outline", "code_snippets", "glossary"}
+
+// storeLessonArtifacts replaces the lesson's artifacts with those of the structured lesson in
+// metadata. Types the lesson has none of are stored empty, so a regeneration clears them.
+func storeLessonArtifacts(e execer, lessonID uuid.UUID, metadata json.RawMessage) error {
+	var lesson struct {
+		Artifacts map[string]json.RawMessage `json:"artifacts"`
+	}
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &lesson); err != nil {
+			return fmt.Errorf("failed to read lesson artifacts: %w", err)
+		}
+	}
+
+	for _, artifactType := range generatedArtifactTypes {
+		data := lesson.Artifacts[artifactType]
+		if len(data) == 0 || string(data) == "null" {
+			data = json.RawMessage("[]")
+		}
+		_, err := e.Exec(`
+			INSERT INTO lesson_artifacts (lesson_id, artifact_type, artifact_data)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (lesson_id, artifact_type) DO UPDATE
+			SET artifact_data = EXCLUDED.artifact_data, updated_at = NOW()
+		`, lessonID, artifactType, []byte(data))
+		if err != nil {
+			return fmt.Errorf("failed to store lesson artifacts: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetLessonNotes returns the lesson's generated notes outline, each entry with copy-ready
+// markdown, and the whole outline as one markdown document
+func (s *MediaService) GetLessonNotes(lessonID uuid.UUID, userID uuid.UUID) ([]models.LessonNote, string, error) {
+	items, err := s.generatedArtifacts(lessonID, userID, "notes_outline")
+	if err != nil {
+		return nil, "", err
+	}
+	notes, markdown := FormatNotesOutline(items)
+	return notes, markdown, nil
+}
+
+// GetLessonCodeSnippets returns the lesson's generated code snippets, each with a copy-ready
+// fenced code block
+func (s *MediaService) GetLessonCodeSnippets(lessonID uuid.UUID, userID uuid.UUID) ([]models.LessonCodeSnippet, error) {
+	items, err := s.generatedArtifacts(lessonID, userID, "code_snippets")
+	if err != nil {
+		return nil, err
+	}
+	return FormatCodeSnippets(items), nil
+}
+
+// generatedArtifacts returns the items of one type of the lesson's generated artifacts; none
+// when the lesson was never generated. A lesson above the user's level is ErrLevelLocked.
+func (s *MediaService) generatedArtifacts(lessonID uuid.UUID, userID uuid.UUID, artifactType string) ([]map[string]interface{}, error) {
+	if err := s.lessonOpen(lessonID, userID); err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	err := s.db.QueryRow(`
+		SELECT artifact_data FROM lesson_artifacts
+		WHERE lesson_id = $1 AND artifact_type = $2
+	`, lessonID, artifactType).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lesson artifacts: %w", err)
+	}
+
+	var items []map[string]interface{}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("failed to read lesson artifacts: %w", err)
+	}
+	return items, nil
+}
+
+// FormatNotesOutline turns generated notes outline items ({"heading", "content", "level"}) into
+// notes with markdown headings at their level. Content given as a list becomes bullets, and
+// entries with neither heading nor content are skipped.
+func FormatNotesOutline(items []map[string]interface{}) ([]models.LessonNote, string) {
+	notes := []models.LessonNote{}
+	var blocks []string
+	for _, item := range items {
+		note := models.LessonNote{
+			Heading: strings.TrimSpace(artifactString(item, "heading", "title")),
+			Content: strings.TrimSpace(artifactText(item, "content", "points")),
+			Level:   1,
+		}
+		if note.Heading == "" && note.Content == "" {
+			continue
+		}
+		if level, ok := item["level"].(float64); ok && level >= 1 {
+			note.Level = int(level)
+		}
+		if note.Level > 6 {
+			note.Level = 6
+		}
+
+		var parts []string
+		if note.Heading != "" {
+			parts = append(parts, strings.Repeat("#", note.Level)+" "+note.Heading)
+		}
+		if note.Content != "" {
+			parts = append(parts, note.Content)
+		}
+		note.Markdown = strings.Join(parts, "\n\n")
+		notes = append(notes, note)
+		blocks = append(blocks, note.Markdown)
+	}
+	return notes, strings.Join(blocks, "\n\n")
+}
+
+// FormatCodeSnippets turns generated code snippet items ({"language", "code", "description"})
+// into snippets with their code trimmed of surrounding blank lines and fenced for markdown.
+// Snippets without code are skipped.
+func FormatCodeSnippets(items []map[string]interface{}) []models.LessonCodeSnippet {
+	snippets := []models.LessonCodeSnippet{}
+	for _, item := range items {
+		code := strings.ReplaceAll(artifactString(item, "code"), "\r\n", "\n")
+		code = strings.Trim(code, "\n")
+		if strings.TrimSpace(code) == "" {
+			continue
+		}
+		// The info string is one word, so a language such as "Python 3" keeps only "python"
+		language := strings.ToLower(strings.TrimSpace(artifactString(item, "language", "lang")))
+		if fields := strings.Fields(language); len(fields) > 0 {
+			language = fields[0]
+		}
+
+		// The fence is longer than any run of backticks in the code, so the code cannot close it
+		fence := "```"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+		snippets = append(snippets, models.LessonCodeSnippet{
+			Language:    language,
+			Description: strings.TrimSpace(artifactString(item, "description", "title")),
+			Code:        code,
+			Markdown:    fence + language + "\n" + code + "\n" + fence,
+		})
+	}
+	return snippets
+}
+
+// artifactString returns the first of keys that is a string in item
+func artifactString(item map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := item[key].(string); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// artifactText is artifactString, with a list of strings under a key given as markdown bullets
+func artifactText(item map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch value := item[key].(type) {
+		case string:
+			return value
+		case []interface{}:
+			var bullets []string
+			for _, v := range value {
+				if text, ok := v.(string); ok && strings.TrimSpace(text) != "" {
+					bullets = append(bullets, "- "+strings.TrimSpace(text))
+				}
+			}
+			return strings.Join(bullets, "\n")
+		}
+	}
+	return ""
+}
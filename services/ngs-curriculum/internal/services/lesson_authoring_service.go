@@ -0,0 +1,451 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidAuthoring = errors.New("invalid lesson authoring request")
+	ErrTemplateNotFound = errors.New("lesson template not found")
+	ErrOverlayExists    = errors.New("the cohort already has an overlay for this lesson")
+)
+
+// lessonTemplates are the built-in starting points, one per lesson type
+var lessonTemplates = []models.LessonTemplate{
+	{
+		Type:        "tutorial",
+		Name:        "Concept tutorial",
+		Description: "Teach one concept with an explanation, a worked example and a check for understanding.",
+		ContentMarkdown: "# {{title}}\n\n## Why it matters\n\n_Connect the concept to something the learner already cares about._\n\n" +
+			"## Core idea\n\n_Explain the concept in plain language. Add an analogy._\n\n" +
+			"## Worked example\n\n_Walk through one concrete example step by step._\n\n" +
+			"## Check your understanding\n\n1. _Question_\n2. _Question_\n\n## Summary\n\n- _Key point_\n- _Key point_\n",
+		HumanPractice:    "Apply the idea to one situation from your own week and note what changed.",
+		ReflectionPrompt: "Which part of the explanation clicked, and which still feels unclear?",
+		XPReward:         50,
+		EstimatedMinutes: 30,
+	},
+	{
+		Type:        "exercise",
+		Name:        "Guided exercise",
+		Description: "Practise a skill through scaffolded steps with hints before an open task.",
+		ContentMarkdown: "# {{title}}\n\n## Goal\n\n_What the learner will be able to do afterwards._\n\n" +
+			"## Warm-up\n\n_A small task that reuses a previous skill._\n\n" +
+			"## Guided steps\n\n1. _Step_ — **Hint:** _hint_\n2. _Step_ — **Hint:** _hint_\n3. _Step_ — **Hint:** _hint_\n\n" +
+			"## Open task\n\n_The same skill without scaffolding._\n\n## Self-check\n\n- [ ] _Criterion_\n- [ ] _Criterion_\n",
+		HumanPractice:    "Complete the open task and share the artifact you produced.",
+		ReflectionPrompt: "Where did you need a hint, and what would you do differently next time?",
+		XPReward:         60,
+		EstimatedMinutes: 40,
+		CompletionCriteria: models.CompletionCriteria{
+			RequiredArtifact: "artifact_url",
+		},
+	},
+	{
+		Type:        "quiz",
+		Name:        "Knowledge quiz",
+		Description: "Check recall and application with a short scored quiz.",
+		ContentMarkdown: "# {{title}}\n\n_Briefly recap what this quiz covers._\n\n" +
+			"## Questions\n\n1. _Question_\n   - A) _Option_\n   - B) _Option_\n   - C) _Option_\n\n" +
+			"2. _Question_\n   - A) _Option_\n   - B) _Option_\n   - C) _Option_\n\n" +
+			"## Answer notes\n\n_Explain why each correct answer is correct._\n",
+		HumanPractice:    "Revisit any question you missed and explain the correct answer in your own words.",
+		ReflectionPrompt: "Which question surprised you most?",
+		XPReward:         40,
+		EstimatedMinutes: 15,
+		CompletionCriteria: models.CompletionCriteria{
+			MinQuizScore: 60,
+		},
+	},
+	{
+		Type:        "challenge",
+		Name:        "Applied challenge",
+		Description: "Pose an open problem that combines several skills from the level.",
+		ContentMarkdown: "# {{title}}\n\n## The challenge\n\n_Describe the problem and who it helps._\n\n" +
+			"## Constraints\n\n- _Constraint_\n- _Constraint_\n\n## Deliverable\n\n_What to submit and in which format._\n\n" +
+			"## Evaluation\n\n- _What a strong solution shows_\n",
+		HumanPractice:    "Present your solution to someone else and capture one piece of feedback.",
+		ReflectionPrompt: "What trade-off did you make, and why?",
+		XPReward:         80,
+		EstimatedMinutes: 60,
+		CompletionCriteria: models.CompletionCriteria{
+			RequiredArtifact: "artifact_url",
+		},
+	},
+	{
+		Type:        "reflection",
+		Name:        "Guided reflection",
+		Description: "Consolidate learning through structured prompts.",
+		ContentMarkdown: "# {{title}}\n\n_Set the scene: what has the learner just worked through?_\n\n" +
+			"## Look back\n\n_What happened? What did you notice?_\n\n## Make sense\n\n_Why did it happen that way?_\n\n" +
+			"## Look ahead\n\n_What will you do differently?_\n",
+		HumanPractice:    "Write your reflection, then share one insight with a peer or mentor.",
+		ReflectionPrompt: "What is one belief about yourself this level challenged?",
+		XPReward:         40,
+		EstimatedMinutes: 20,
+		CompletionCriteria: models.CompletionCriteria{
+			MinReflectionWords: 30,
+		},
+	},
+}
+
+// LessonTemplates returns the built-in lesson templates
+func LessonTemplates() []models.LessonTemplate {
+	return append([]models.LessonTemplate(nil), lessonTemplates...)
+}
+
+// GetLessonTemplate returns the built-in template for a lesson type
+func GetLessonTemplate(lessonType string) (models.LessonTemplate, error) {
+	for _, t := range lessonTemplates {
+		if t.Type == strings.ToLower(strings.TrimSpace(lessonType)) {
+			return t, nil
+		}
+	}
+	return models.LessonTemplate{}, ErrTemplateNotFound
+}
+
+// lessonVisibleSQL is true when the lessons row qualified by table is shown to the user bound to
+// userParam: suitable for their age band, not restricted to a cohort they are outside of, not
+// replaced by an overlay for one of their cohorts and not hidden by their cohort's curriculum
+func lessonVisibleSQL(table, userParam string) string {
+	return contentAgeFilterSQL(table+".min_age_band", userParam) + " AND NOT " + cohortHiddenSQL(table, userParam) + fmt.Sprintf(`
+		AND (%[1]s.overlay_cohort_id IS NULL OR EXISTS (
+			SELECT 1 FROM cohort_members vcm WHERE vcm.cohort_id = %[1]s.overlay_cohort_id AND vcm.user_id = %[2]s))
+		AND NOT EXISTS (
+			SELECT 1 FROM lessons ov JOIN cohort_members ocm ON ocm.cohort_id = ov.overlay_cohort_id
+			WHERE ov.overlay_of = %[1]s.id AND ocm.user_id = %[2]s)`, table, userParam)
+}
+
+type LessonAuthoringService struct {
+	db *database.DB
+}
+
+func NewLessonAuthoringService(db *database.DB) *LessonAuthoringService {
+	return &LessonAuthoringService{
+		db: db,
+	}
+}
+
+// checkCohortOwner confirms the educator owns the cohort a lesson is being scoped to
+func checkCohortOwner(q queryRower, cohortID uuid.UUID, educatorID uuid.UUID) error {
+	var owner uuid.UUID
+	err := q.QueryRow(`SELECT educator_id FROM cohorts WHERE id = $1`, cohortID).Scan(&owner)
+	if err == sql.ErrNoRows {
+		return ErrCohortNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query cohort: %w", err)
+	}
+	if owner != educatorID {
+		return ErrNotCohortEducator
+	}
+	return nil
+}
+
+// nextLessonOrder returns the order after the last shared lesson in a level
+func nextLessonOrder(q queryRower, levelID int) (int, error) {
+	var order int
+	err := q.QueryRow(`
+		SELECT COALESCE(MAX(lesson_order), 0) + 1 FROM lessons WHERE level_id = $1 AND overlay_cohort_id IS NULL
+	`, levelID).Scan(&order)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find next lesson order: %w", err)
+	}
+	return order, nil
+}
+
+// levelExists reports whether a curriculum level ID exists
+func levelExists(q queryRower, levelID int) error {
+	var exists bool
+	if err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM curriculum_levels WHERE id = $1)`, levelID).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to query level: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("%w: level %d does not exist", ErrInvalidAuthoring, levelID)
+	}
+	return nil
+}
+
+const authoredLessonColumns = `id, level_id, title, COALESCE(description, ''), lesson_order, lesson_type,
+	COALESCE(content_markdown, ''), COALESCE(core_lesson, ''), COALESCE(human_practice, ''),
+	COALESCE(reflection_prompt, ''), COALESCE(agent_unlock, ''), COALESCE(xp_reward, 0),
+	COALESCE(estimated_minutes, 0), COALESCE(prerequisites, '{}'), COALESCE(metadata, '{}'),
+	COALESCE(is_required, true), created_at, updated_at, COALESCE(completion_criteria, '{}'), min_age_band,
+	cloned_from, overlay_of, overlay_cohort_id`
+
+func scanAuthoredLesson(row rowScanner) (*models.AuthoredLesson, error) {
+	var l models.AuthoredLesson
+	var clonedFrom, overlayOf, overlayCohort uuid.NullUUID
+	err := row.Scan(
+		&l.ID, &l.LevelID, &l.Title, &l.Description, &l.LessonOrder, &l.LessonType,
+		&l.ContentMarkdown, &l.CoreLesson, &l.HumanPractice,
+		&l.ReflectionPrompt, &l.AgentUnlock, &l.XPReward,
+		&l.EstimatedMinutes, &l.Prerequisites, &l.Metadata,
+		&l.IsRequired, &l.CreatedAt, &l.UpdatedAt, &l.CompletionCriteria, &l.MinAgeBand,
+		&clonedFrom, &overlayOf, &overlayCohort,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if clonedFrom.Valid {
+		l.ClonedFrom = &clonedFrom.UUID
+	}
+	if overlayOf.Valid {
+		l.OverlayOf = &overlayOf.UUID
+	}
+	if overlayCohort.Valid {
+		l.OverlayCohortID = &overlayCohort.UUID
+	}
+	return &l, nil
+}
+
+// CloneLesson copies a lesson's content, structure, completion criteria and structured
+// artifacts. Completion-linked state is reset: the clone has no completions, no external ID and
+// starts at content version 0. Plain clones also copy the lesson's challenges; lesson
+// prerequisites are kept when cloning within the source's level and dropped otherwise.
+// Cloning with a cohort creates an overlay that replaces the base lesson for that cohort.
+func (s *LessonAuthoringService) CloneLesson(sourceID uuid.UUID, educatorID uuid.UUID, req models.CloneLessonRequest) (*models.AuthoredLesson, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	source, err := scanAuthoredLesson(tx.QueryRow(`SELECT `+authoredLessonColumns+` FROM lessons WHERE id = $1`, sourceID))
+	if err == sql.ErrNoRows {
+		return nil, ErrLessonNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lesson: %w", err)
+	}
+
+	title := strings.TrimSpace(req.Title)
+	levelID := source.LevelID
+	if req.LevelID != nil {
+		levelID = *req.LevelID
+	}
+
+	var overlayOf, overlayCohort *uuid.UUID
+	order := 0
+	if req.CohortID != nil {
+		if err := checkCohortOwner(tx, *req.CohortID, educatorID); err != nil {
+			return nil, err
+		}
+		// Overlays always replace the base lesson in place
+		base := source.ID
+		if source.OverlayOf != nil {
+			base = *source.OverlayOf
+		}
+		if levelID != source.LevelID {
+			return nil, fmt.Errorf("%w: an overlay stays on the base lesson's level", ErrInvalidAuthoring)
+		}
+		var exists bool
+		err = tx.QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM lessons WHERE overlay_of = $1 AND overlay_cohort_id = $2)
+		`, base, *req.CohortID).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check existing overlay: %w", err)
+		}
+		if exists {
+			return nil, ErrOverlayExists
+		}
+		overlayOf, overlayCohort = &base, req.CohortID
+		order = source.LessonOrder
+		if title == "" {
+			title = source.Title
+		}
+	} else {
+		if err := levelExists(tx, levelID); err != nil {
+			return nil, err
+		}
+		if req.LessonOrder != nil {
+			order = *req.LessonOrder
+		} else if order, err = nextLessonOrder(tx, levelID); err != nil {
+			return nil, err
+		}
+		if title == "" {
+			title = source.Title + " (Copy)"
+		}
+	}
+	if order < 1 {
+		return nil, fmt.Errorf("%w: lesson_order must be positive", ErrInvalidAuthoring)
+	}
+
+	prereqs, err := ParseLessonPrerequisites(source.Prerequisites)
+	if err != nil {
+		prereqs = models.LessonPrerequisites{}
+	}
+	prereqs.MinLevel = levelID
+	if levelID != source.LevelID {
+		prereqs.Lessons = nil
+	}
+	prereqJSON, err := json.Marshal(prereqs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal prerequisites: %w", err)
+	}
+
+	var cloneID uuid.UUID
+	err = tx.QueryRow(`
+		INSERT INTO lessons (
+			level_id, title, description, lesson_order, lesson_type, content_markdown, core_lesson,
+			human_practice, reflection_prompt, agent_unlock, xp_reward, estimated_minutes, prerequisites,
+			metadata, is_required, completion_criteria, min_age_band, plain_language_summary, alt_text,
+			reading_level, content_version, cloned_from, overlay_of, overlay_cohort_id, created_by
+		)
+		SELECT $2, $3, description, $4, lesson_type, content_markdown, core_lesson,
+			human_practice, reflection_prompt, agent_unlock, xp_reward, estimated_minutes, $5,
+			COALESCE(metadata, '{}'::jsonb) || jsonb_build_object('cloned_from', id::text), is_required,
+			completion_criteria, min_age_band, plain_language_summary, alt_text,
+			reading_level, 0, id, $6, $7, $8
+		FROM lessons WHERE id = $1
+		RETURNING id
+	`, sourceID, levelID, title, order, prereqJSON, overlayOf, overlayCohort, educatorID).Scan(&cloneID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone lesson: %w", err)
+	}
+	if err := recordLessonVersion(tx, cloneID, LessonVersionAuthored, &educatorID); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO lesson_artifacts (lesson_id, artifact_type, artifact_data)
+		SELECT $2, artifact_type, artifact_data FROM lesson_artifacts WHERE lesson_id = $1
+	`, sourceID, cloneID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy lesson artifacts: %w", err)
+	}
+
+	challengesCopied := 0
+	if overlayOf == nil {
+		result, err := tx.Exec(`
+			INSERT INTO challenges (
+				lesson_id, level_id, title, description, challenge_type, difficulty, starter_code,
+				test_cases, solution_template, xp_reward, time_limit_minutes, tags, metadata,
+				is_active, min_age_band, run_time_limit_ms, run_cpu_limit_ms, run_memory_limit_mb,
+				baseline_runtime_ms, baseline_memory_kb
+			)
+			SELECT $2, $3, title, description, challenge_type, difficulty, starter_code,
+				test_cases, solution_template, xp_reward, time_limit_minutes, tags, metadata,
+				is_active, min_age_band, run_time_limit_ms, run_cpu_limit_ms, run_memory_limit_mb,
+				baseline_runtime_ms, baseline_memory_kb
+			FROM challenges WHERE lesson_id = $1
+		`, sourceID, cloneID, levelID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy challenges: %w", err)
+		}
+		n, _ := result.RowsAffected()
+		challengesCopied = int(n)
+	}
+
+	if err := validateLessonPrerequisites(tx, cloneID); err != nil {
+		return nil, err
+	}
+
+	clone, err := scanAuthoredLesson(tx.QueryRow(`SELECT `+authoredLessonColumns+` FROM lessons WHERE id = $1`, cloneID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cloned lesson: %w", err)
+	}
+	clone.ChallengesCopied = challengesCopied
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if overlayCohort != nil {
+		log.Printf("Educator %s created overlay %s of lesson %s for cohort %s", educatorID, cloneID, *overlayOf, *overlayCohort)
+	} else {
+		log.Printf("Educator %s cloned lesson %s as %s (L%d.%d)", educatorID, sourceID, cloneID, levelID, order)
+	}
+	return clone, nil
+}
+
+// CreateLessonFromTemplate creates a lesson from a built-in template. The template's markdown
+// skeleton has {{title}} replaced; everything else is left for the author to fill in.
+func (s *LessonAuthoringService) CreateLessonFromTemplate(educatorID uuid.UUID, req models.CreateLessonFromTemplateRequest) (*models.AuthoredLesson, error) {
+	template, err := GetLessonTemplate(req.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		return nil, fmt.Errorf("%w: title is required", ErrInvalidAuthoring)
+	}
+	ageBand := AgeBandChild
+	if req.MinAgeBand != "" {
+		if _, ok := ageBandRank[req.MinAgeBand]; !ok {
+			return nil, ErrInvalidAgeBand
+		}
+		ageBand = req.MinAgeBand
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := levelExists(tx, req.LevelID); err != nil {
+		return nil, err
+	}
+	if req.CohortID != nil {
+		if err := checkCohortOwner(tx, *req.CohortID, educatorID); err != nil {
+			return nil, err
+		}
+	}
+
+	var order int
+	if req.LessonOrder != nil {
+		order = *req.LessonOrder
+	} else if order, err = nextLessonOrder(tx, req.LevelID); err != nil {
+		return nil, err
+	}
+	if order < 1 {
+		return nil, fmt.Errorf("%w: lesson_order must be positive", ErrInvalidAuthoring)
+	}
+
+	prereqJSON, _ := json.Marshal(models.LessonPrerequisites{MinLevel: req.LevelID})
+	metadataJSON, _ := json.Marshal(map[string]interface{}{"version": 1, "template": template.Type})
+	criteriaJSON, _ := json.Marshal(template.CompletionCriteria)
+	content := strings.ReplaceAll(template.ContentMarkdown, "{{title}}", title)
+
+	var lessonID uuid.UUID
+	err = tx.QueryRow(`
+		INSERT INTO lessons (
+			level_id, title, description, lesson_order, lesson_type, content_markdown, human_practice,
+			reflection_prompt, xp_reward, estimated_minutes, prerequisites, metadata, is_required,
+			completion_criteria, min_age_band, overlay_cohort_id, created_by
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, true, $13, $14, $15, $16)
+		RETURNING id
+	`, req.LevelID, title, strings.TrimSpace(req.Description), order, template.Type, content, template.HumanPractice,
+		template.ReflectionPrompt, template.XPReward, template.EstimatedMinutes, prereqJSON, metadataJSON,
+		criteriaJSON, ageBand, req.CohortID, educatorID).Scan(&lessonID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lesson: %w", err)
+	}
+	if err := recordLessonVersion(tx, lessonID, LessonVersionAuthored, &educatorID); err != nil {
+		return nil, err
+	}
+
+	lesson, err := scanAuthoredLesson(tx.QueryRow(`SELECT `+authoredLessonColumns+` FROM lessons WHERE id = $1`, lessonID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load lesson: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Educator %s created lesson %s from the %s template", educatorID, lessonID, template.Type)
+	return lesson, nil
+}
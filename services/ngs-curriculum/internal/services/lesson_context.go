@@ -0,0 +1,181 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// LessonContextChunks is how many passages a tutor chat message carries
+	LessonContextChunks = 4
+	// LessonContextChunkChars bounds a passage, so a message carries a few KB of lesson text
+	LessonContextChunkChars = 1500
+)
+
+// ChunkLessonText splits lesson markdown or a media transcript into passages of at most
+// maxChars. Passages break at headings, which they keep as their heading, then between
+// paragraphs; code blocks are not split at their blank lines. A paragraph longer than maxChars
+// is cut between words.
+func ChunkLessonText(source, text string, maxChars int) []models.LessonContextChunk {
+	chunks := []models.LessonContextChunk{}
+	heading := ""
+	var current, paragraph strings.Builder
+	inFence := false
+
+	flushChunk := func() {
+		if content := strings.TrimSpace(current.String()); content != "" {
+			chunks = append(chunks, models.LessonContextChunk{Source: source, Heading: heading, Content: content})
+		}
+		current.Reset()
+	}
+	flushParagraph := func() {
+		p := strings.TrimSpace(paragraph.String())
+		paragraph.Reset()
+		if p == "" {
+			return
+		}
+		if current.Len() > 0 && current.Len()+2+len(p) > maxChars {
+			flushChunk()
+		}
+		for len(p) > maxChars {
+			cut := strings.LastIndexAny(p[:maxChars], " \n\t")
+			if cut <= 0 {
+				cut = maxChars
+			}
+			current.WriteString(p[:cut])
+			flushChunk()
+			p = strings.TrimSpace(p[cut:])
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n\n")
+		}
+		current.WriteString(p)
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			inFence = !inFence
+		case inFence:
+		case strings.HasPrefix(trimmed, "#"):
+			flushParagraph()
+			flushChunk()
+			heading = strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+			continue
+		case trimmed == "":
+			flushParagraph()
+			continue
+		}
+		paragraph.WriteString(line)
+		paragraph.WriteString("\n")
+	}
+	flushParagraph()
+	flushChunk()
+
+	return chunks
+}
+
+// SelectLessonContext picks up to n passages by rank, best first, leaving out passages that
+// do not match at all. When none match, such as "I don't get it", the first n passages are
+// sent instead: the opening of the lesson is the best guess at what the learner is on.
+func SelectLessonContext(chunks []models.LessonContextChunk, ranks []float64, n int) []models.LessonContextChunk {
+	picked := []models.LessonContextChunk{}
+	for i, chunk := range chunks {
+		if i < len(ranks) && ranks[i] > 0 {
+			chunk.Rank = ranks[i]
+			picked = append(picked, chunk)
+		}
+	}
+	if len(picked) == 0 {
+		if len(chunks) > n {
+			chunks = chunks[:n]
+		}
+		return append(picked, chunks...)
+	}
+
+	sort.SliceStable(picked, func(i, j int) bool {
+		return picked[i].Rank > picked[j].Rank
+	})
+	if len(picked) > n {
+		picked = picked[:n]
+	}
+	return picked
+}
+
+// LessonContext returns the passages of a lesson the user can see, and of its media
+// transcripts and summaries, that best match a tutor chat message. Passages are ranked with
+// the same English text search as the lesson search index.
+func (s *MediaService) LessonContext(userID, lessonID uuid.UUID, message string) ([]models.LessonContextChunk, error) {
+	var markdown sql.NullString
+	err := s.db.QueryRow(`
+		SELECT content_markdown FROM lessons
+		WHERE id = $1 AND `+lessonVisibleSQL("lessons", "$2")+`
+	`, lessonID, userID).Scan(&markdown)
+	if err == sql.ErrNoRows {
+		return nil, ErrLessonNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lesson: %w", err)
+	}
+	chunks := ChunkLessonText("lesson", markdown.String, LessonContextChunkChars)
+
+	rows, err := s.db.Query(`
+		SELECT artifact_type, content FROM lesson_media_artifacts
+		WHERE lesson_id = $1
+		ORDER BY created_at, id
+	`, lessonID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lesson artifacts: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var source, content string
+		if err := rows.Scan(&source, &content); err != nil {
+			return nil, fmt.Errorf("failed to scan lesson artifact: %w", err)
+		}
+		chunks = append(chunks, ChunkLessonText(source, content, LessonContextChunkChars)...)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read lesson artifacts: %w", err)
+	}
+	if len(chunks) == 0 {
+		return chunks, nil
+	}
+
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Heading + "\n" + chunk.Content
+	}
+	rankRows, err := s.db.Query(`
+		SELECT t.i, ts_rank(to_tsvector('english', t.text), websearch_to_tsquery('english', $1))
+		FROM unnest($2::text[]) WITH ORDINALITY AS t(text, i)
+	`, message, pq.Array(texts))
+	if err != nil {
+		return nil, fmt.Errorf("failed to rank lesson passages: %w", err)
+	}
+	defer rankRows.Close()
+	ranks := make([]float64, len(chunks))
+	for rankRows.Next() {
+		var i int
+		var rank float64
+		if err := rankRows.Scan(&i, &rank); err != nil {
+			return nil, fmt.Errorf("failed to scan passage rank: %w", err)
+		}
+		if i >= 1 && i <= len(ranks) {
+			ranks[i-1] = rank
+		}
+	}
+	if err := rankRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read passage ranks: %w", err)
+	}
+
+	return SelectLessonContext(chunks, ranks, LessonContextChunks), nil
+}
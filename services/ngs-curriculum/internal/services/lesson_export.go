@@ -0,0 +1,105 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"noble-ngs-curriculum/internal/clients/intelligence"
+	"noble-ngs-curriculum/internal/domain/lessonexport"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var ErrInvalidExportFormat = errors.New("format must be pdf or md")
+
+var lessonExports = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ngs_lesson_exports_total",
+		Help: "Lessons exported for printing or offline use, by format (pdf or md).",
+	},
+	[]string{"format"},
+)
+
+func init() {
+	prometheus.MustRegister(lessonExports)
+}
+
+// LessonExport is a lesson rendered as a file to download
+type LessonExport struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+}
+
+// ExportLesson renders the lesson's published content as a PDF or markdown file, with a metadata
+// header and the glossary as an appendix. The user must be able to open the lesson; a locked
+// lesson is ErrLevelLocked.
+func (s *LessonService) ExportLesson(lessonID uuid.UUID, userID uuid.UUID, format string) (*LessonExport, error) {
+	if format != lessonexport.FormatPDF && format != lessonexport.FormatMarkdown {
+		return nil, ErrInvalidExportFormat
+	}
+	lesson, err := s.GetLesson(lessonID, userID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := exportDocument(lesson.Lesson)
+	name := capabilitySlug(lesson.Title)
+	if name == "" {
+		name = "lesson"
+	}
+	export := &LessonExport{FileName: name + "." + format}
+	if format == lessonexport.FormatPDF {
+		export.ContentType, export.Content = "application/pdf", lessonexport.PDF(doc)
+	} else {
+		export.ContentType, export.Content = "text/markdown; charset=utf-8", lessonexport.Markdown(doc)
+	}
+	lessonExports.WithLabelValues(format).Inc()
+	return export, nil
+}
+
+// exportDocument gathers what an export shows of the lesson. Difficulty, outcomes and the
+// glossary come from the structured lesson of generated lessons; lessons never generated are
+// exported from their core lesson, practice and reflection prompt.
+func exportDocument(lesson models.Lesson) lessonexport.Document {
+	doc := lessonexport.Document{
+		Title:            lesson.Title,
+		Description:      lesson.Description,
+		Level:            lesson.LevelID,
+		Order:            lesson.LessonOrder,
+		LessonType:       lesson.LessonType,
+		EstimatedMinutes: lesson.EstimatedMinutes,
+		UpdatedAt:        lesson.UpdatedAt,
+		Body:             lesson.ContentMarkdown,
+	}
+	if strings.TrimSpace(doc.Body) == "" {
+		var sections []string
+		for _, section := range []struct{ heading, text string }{
+			{"Lesson", lesson.CoreLesson},
+			{"Practice", lesson.HumanPractice},
+			{"Reflection", lesson.ReflectionPrompt},
+		} {
+			if text := strings.TrimSpace(section.text); text != "" {
+				sections = append(sections, "## "+section.heading+"\n\n"+text)
+			}
+		}
+		doc.Body = strings.Join(sections, "\n\n")
+	}
+
+	// Metadata that is not a structured lesson adds nothing to the export
+	var structured intelligence.StructuredLesson
+	if len(lesson.Metadata) > 0 && json.Unmarshal(lesson.Metadata, &structured) == nil {
+		doc.Difficulty = structured.Metadata.Difficulty
+		doc.Outcomes = structured.Metadata.Outcomes
+		if doc.EstimatedMinutes <= 0 {
+			doc.EstimatedMinutes = structured.Metadata.EstimatedMinutes
+		}
+		for _, term := range structured.Artifacts.Glossary {
+			doc.Glossary = append(doc.Glossary, lessonexport.Term{Term: term.Term, Definition: term.Definition})
+		}
+	}
+	return doc
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var ErrNoLessonQuiz = errors.New("lesson has no quiz")
+
+// lessonQuizAnswerKeys are the quiz item fields that give the answer away
+var lessonQuizAnswerKeys = []string{"correct_answer", "answer", "explanation"}
+
+// lessonQuizItem is a generated quiz item with its answer key
+type lessonQuizItem struct {
+	Question      string      `json:"question"`
+	Choices       []string    `json:"choices"`
+	CorrectAnswer interface{} `json:"correct_answer"`
+}
+
+// SubmitLessonQuiz grades answers to the lesson's generated quiz and records the attempt. The
+// score is the percentage of questions answered correctly; items without a question or an
+// answer key are not counted. A lesson above the user's level is ErrLevelLocked.
+func (s *LessonService) SubmitLessonQuiz(lessonID uuid.UUID, userID uuid.UUID, answers map[string]interface{}) (*models.LessonQuizAttempt, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	err = tx.QueryRow(`
+		SELECT `+lessonLockedSQL("lessons", "$2")+`
+		FROM lessons WHERE id = $1 AND `+lessonVisibleSQL("lessons", "$2")+`
+	`, lessonID, userID).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return nil, ErrLessonNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lesson: %w", err)
+	}
+	if locked {
+		return nil, ErrLevelLocked
+	}
+
+	var data []byte
+	err = tx.QueryRow(`
+		SELECT artifact_data FROM lesson_artifacts
+		WHERE lesson_id = $1 AND artifact_type = 'quiz_items'
+	`, lessonID).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoLessonQuiz
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lesson quiz: %w", err)
+	}
+	var items []lessonQuizItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("failed to read lesson quiz: %w", err)
+	}
+
+	results := []models.LessonQuizQuestionResult{}
+	correct := 0
+	for i, item := range items {
+		if item.Question == "" || item.CorrectAnswer == nil {
+			continue
+		}
+		ok := ExamAnswerMatches(item.CorrectAnswer, answers[strconv.Itoa(i)], item.Choices)
+		if ok {
+			correct++
+		}
+		results = append(results, models.LessonQuizQuestionResult{Index: i, Correct: ok})
+	}
+	if len(results) == 0 {
+		return nil, ErrNoLessonQuiz
+	}
+
+	attempt := models.LessonQuizAttempt{LessonID: lessonID, Score: correct * 100 / len(results), Results: results}
+	answersJSON, _ := json.Marshal(answers)
+	resultsJSON, _ := json.Marshal(results)
+	err = tx.QueryRow(`
+		INSERT INTO lesson_quiz_attempts (user_id, lesson_id, answers, results, score)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, userID, lessonID, answersJSON, resultsJSON, attempt.Score).Scan(&attempt.ID, &attempt.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record quiz attempt: %w", err)
+	}
+
+	if attempt.BestScore, err = bestQuizScore(tx, userID, lessonID); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("User %s scored %d on the quiz of lesson %s", userID, attempt.Score, lessonID)
+	return &attempt, nil
+}
+
+// bestQuizScore returns the user's best graded attempt at the lesson's quiz; 0 without one
+func bestQuizScore(q queryRower, userID uuid.UUID, lessonID uuid.UUID) (int, error) {
+	var best int
+	err := q.QueryRow(`
+		SELECT COALESCE(MAX(score), 0) FROM lesson_quiz_attempts
+		WHERE user_id = $1 AND lesson_id = $2
+	`, userID, lessonID).Scan(&best)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get best quiz score: %w", err)
+	}
+	return best, nil
+}
+
+// HideQuizAnswers removes the answer keys from the quiz items of a structured lesson's
+// metadata, so learners cannot read them before the service grades their answers. Metadata
+// that is not a structured lesson is returned unchanged.
+func HideQuizAnswers(metadata json.RawMessage) json.RawMessage {
+	var lesson map[string]json.RawMessage
+	if len(metadata) == 0 || json.Unmarshal(metadata, &lesson) != nil {
+		return metadata
+	}
+	var artifacts map[string]json.RawMessage
+	if json.Unmarshal(lesson["artifacts"], &artifacts) != nil {
+		return metadata
+	}
+	var items []map[string]json.RawMessage
+	if json.Unmarshal(artifacts["quiz_items"], &items) != nil || len(items) == 0 {
+		return metadata
+	}
+
+	for _, item := range items {
+		for _, key := range lessonQuizAnswerKeys {
+			delete(item, key)
+		}
+	}
+	artifacts["quiz_items"], _ = json.Marshal(items)
+	lesson["artifacts"], _ = json.Marshal(artifacts)
+	hidden, err := json.Marshal(lesson)
+	if err != nil {
+		return metadata
+	}
+	return hidden
+}
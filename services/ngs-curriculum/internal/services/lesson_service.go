@@ -3,17 +3,28 @@ package services
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 
+	"noble-ngs-curriculum/internal/api"
 	"noble-ngs-curriculum/internal/database"
 	"noble-ngs-curriculum/internal/models"
 
 	"github.com/google/uuid"
 )
 
+var ErrLessonNotFound = errors.New("lesson not found")
+
+// ErrLevelLocked is returned for lessons above the user's current level
+var ErrLevelLocked = errors.New("lesson is above your current level")
+
 type LessonService struct {
 	db *database.DB
+	// projection applies XP to progress in projection mode; nil updates the total directly
+	projection *ProgressProjection
+	// leaderboards drops cached leaderboards after XP is awarded; nil leaves them to expire
+	leaderboards LeaderboardInvalidator
 }
 
 func NewLessonService(db *database.DB) *LessonService {
@@ -22,95 +33,98 @@ func NewLessonService(db *database.DB) *LessonService {
 	}
 }
 
-// GetLessonsByLevel retrieves all lessons for a specific level
-func (s *LessonService) GetLessonsByLevel(levelID int, userID uuid.UUID) ([]models.LessonWithCompletion, error) {
-	rows, err := s.db.Query(`
-		SELECT 
-			l.id, l.level_id, l.title, l.description, l.lesson_order, l.lesson_type,
-			l.content_markdown, l.core_lesson, l.human_practice, l.reflection_prompt,
-			l.agent_unlock, l.xp_reward, l.estimated_minutes, l.prerequisites, 
-			l.metadata, l.is_required, l.created_at, l.updated_at,
-			COALESCE(lc.id IS NOT NULL, false) as completed,
-			lc.completed_at, lc.score
-		FROM lessons l
-		LEFT JOIN lesson_completions lc ON l.id = lc.lesson_id AND lc.user_id = $1
-		WHERE l.level_id = $2
-		ORDER BY l.lesson_order ASC
-	`, userID, levelID)
+// GetLessonsByLevel retrieves a page of the lessons for a specific level, in the user's cohort
+// sequence, only those of lessonType when it is given. A level above the user's current level
+// returns ErrLevelLocked, or with preview its lessons without their content.
+func (s *LessonService) GetLessonsByLevel(levelID int, userID uuid.UUID, preview bool, lessonType string, page api.Page) ([]models.LessonWithCompletion, error) {
+	lessons, err := queryLessonsByLevel(s.db, levelID, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query lessons: %w", err)
-	}
-	defer rows.Close()
-
-	var lessons []models.LessonWithCompletion
-	for rows.Next() {
-		var l models.LessonWithCompletion
-		var completedAt sql.NullTime
-		var score sql.NullInt64
-
-		err := rows.Scan(
-			&l.ID, &l.LevelID, &l.Title, &l.Description, &l.LessonOrder, &l.LessonType,
-			&l.ContentMarkdown, &l.CoreLesson, &l.HumanPractice, &l.ReflectionPrompt,
-			&l.AgentUnlock, &l.XPReward, &l.EstimatedMinutes, &l.Prerequisites,
-			&l.Metadata, &l.IsRequired, &l.CreatedAt, &l.UpdatedAt,
-			&l.Completed, &completedAt, &score,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan lesson: %w", err)
+		return nil, err
+	}
+	// A level has few lessons, in an order computed per user, so they are paged here
+	selected := []models.LessonWithCompletion{}
+	started := page.After == nil
+	for i := range lessons {
+		if lessons[i].Locked {
+			if !preview {
+				return nil, ErrLevelLocked
+			}
+			hideLessonContent(&lessons[i])
 		}
-
-		if completedAt.Valid {
-			l.CompletedAt = completedAt.Time
+		if lessonType != "" && lessons[i].LessonType != lessonType {
+			continue
 		}
-		if score.Valid {
-			l.UserScore = int(score.Int64)
+		if !started {
+			started = lessons[i].ID == page.After.ID
+			continue
 		}
-
-		lessons = append(lessons, l)
+		selected = append(selected, lessons[i])
 	}
-
-	return lessons, nil
+	if !started {
+		return nil, fmt.Errorf("%w: the cursor's lesson is not in this list", api.ErrInvalidPage)
+	}
+	if page.Limit > 0 && len(selected) > page.Limit {
+		selected = selected[:page.Limit]
+	}
+	return selected, nil
 }
 
-// GetLesson retrieves a specific lesson by ID
-func (s *LessonService) GetLesson(lessonID uuid.UUID, userID uuid.UUID) (*models.LessonWithCompletion, error) {
-	var l models.LessonWithCompletion
-	var completedAt sql.NullTime
-	var score sql.NullInt64
-
-	err := s.db.QueryRow(`
-		SELECT 
-			l.id, l.level_id, l.title, l.description, l.lesson_order, l.lesson_type,
-			l.content_markdown, l.core_lesson, l.human_practice, l.reflection_prompt,
-			l.agent_unlock, l.xp_reward, l.estimated_minutes, l.prerequisites, 
-			l.metadata, l.is_required, l.created_at, l.updated_at,
-			COALESCE(lc.id IS NOT NULL, false) as completed,
-			lc.completed_at, lc.score
-		FROM lessons l
-		LEFT JOIN lesson_completions lc ON l.id = lc.lesson_id AND lc.user_id = $1
-		WHERE l.id = $2
-	`, userID, lessonID).Scan(
-		&l.ID, &l.LevelID, &l.Title, &l.Description, &l.LessonOrder, &l.LessonType,
-		&l.ContentMarkdown, &l.CoreLesson, &l.HumanPractice, &l.ReflectionPrompt,
-		&l.AgentUnlock, &l.XPReward, &l.EstimatedMinutes, &l.Prerequisites,
-		&l.Metadata, &l.IsRequired, &l.CreatedAt, &l.UpdatedAt,
-		&l.Completed, &completedAt, &score,
-	)
+// GetLesson retrieves a specific lesson by ID. A lesson above the user's current level returns
+// ErrLevelLocked, or with preview the lesson without its content.
+func (s *LessonService) GetLesson(lessonID uuid.UUID, userID uuid.UUID, preview bool) (*models.LessonWithCompletion, error) {
+	l, err := queryLesson(s.db, lessonID, userID)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("lesson not found")
+		return nil, ErrLessonNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to query lesson: %w", err)
 	}
-
-	if completedAt.Valid {
-		l.CompletedAt = completedAt.Time
+	if l.Locked {
+		if !preview {
+			return nil, ErrLevelLocked
+		}
+		hideLessonContent(l)
 	}
-	if score.Valid {
-		l.UserScore = int(score.Int64)
+	return l, nil
+}
+
+// hideLessonContent clears what a learner reads and practises in a lesson, leaving the
+// metadata shown in a preview
+func hideLessonContent(l *models.LessonWithCompletion) {
+	l.ContentMarkdown, l.CoreLesson, l.HumanPractice = "", "", ""
+	l.ReflectionPrompt, l.AgentUnlock = "", ""
+}
+
+// GetCompletedLessons returns all of a user's completed lessons in one query, ordered by level
+// and lesson order, so level maps need not fetch each level's lessons
+func (s *LessonService) GetCompletedLessons(userID uuid.UUID) (*models.LessonCompletionSet, error) {
+	rows, err := s.db.Query(`
+		SELECT lc.lesson_id, l.level_id
+		FROM lesson_completions lc
+		JOIN lessons l ON l.id = lc.lesson_id
+		WHERE lc.user_id = $1
+		ORDER BY l.level_id, l.lesson_order
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query completions: %w", err)
 	}
+	defer rows.Close()
 
-	return &l, nil
+	set := &models.LessonCompletionSet{LessonIDs: []uuid.UUID{}, ByLevel: map[int]int{}}
+	for rows.Next() {
+		var lessonID uuid.UUID
+		var levelID int
+		if err := rows.Scan(&lessonID, &levelID); err != nil {
+			return nil, fmt.Errorf("failed to scan completion: %w", err)
+		}
+		set.LessonIDs = append(set.LessonIDs, lessonID)
+		set.ByLevel[levelID]++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read completions: %w", err)
+	}
+	set.Count = len(set.LessonIDs)
+	return set, nil
 }
 
 // CompleteLesson marks a lesson as completed and awards XP
@@ -124,14 +138,18 @@ func (s *LessonService) CompleteLesson(userID uuid.UUID, req models.CompleteLess
 
 	// Get lesson details
 	var lesson models.Lesson
+	var locked bool
 	err = tx.QueryRow(`
-		SELECT id, level_id, title, xp_reward
+		SELECT id, level_id, title, xp_reward, COALESCE(completion_criteria, '{}'), `+lessonLockedSQL("lessons", "$2")+`
 		FROM lessons
-		WHERE id = $1
-	`, req.LessonID).Scan(&lesson.ID, &lesson.LevelID, &lesson.Title, &lesson.XPReward)
+		WHERE id = $1 AND `+lessonVisibleSQL("lessons", "$2")+`
+	`, req.LessonID, userID).Scan(&lesson.ID, &lesson.LevelID, &lesson.Title, &lesson.XPReward, &lesson.CompletionCriteria, &locked)
 	if err != nil {
 		return nil, fmt.Errorf("lesson not found: %w", err)
 	}
+	if locked {
+		return nil, ErrLevelLocked
+	}
 
 	// Check if already completed
 	var existingID uuid.UUID
@@ -162,6 +180,19 @@ func (s *LessonService) CompleteLesson(userID uuid.UUID, req models.CompleteLess
 		return nil, fmt.Errorf("failed to check completion: %w", err)
 	}
 
+	// Enforce the lesson's completion criteria against the best quiz attempt the service graded
+	score, err := bestQuizScore(tx, userID, req.LessonID)
+	if err != nil {
+		return nil, err
+	}
+	criteria, err := ParseCompletionCriteria(lesson.CompletionCriteria)
+	if err != nil {
+		return nil, fmt.Errorf("lesson %s: %w", lesson.ID, err)
+	}
+	if unmet := EvaluateCompletionCriteria(criteria, req, score); len(unmet) > 0 {
+		return nil, &CompletionCriteriaError{Unmet: unmet}
+	}
+
 	// Create lesson completion record
 	var completionData json.RawMessage
 	if req.Metadata != nil {
@@ -173,7 +204,7 @@ func (s *LessonService) CompleteLesson(userID uuid.UUID, req models.CompleteLess
 		INSERT INTO lesson_completions (user_id, lesson_id, score, time_spent_seconds, reflection_text, completion_data)
 		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id, user_id, lesson_id, score, time_spent_seconds, reflection_text, completion_data, completed_at
-	`, userID, req.LessonID, req.Score, req.TimeSpentSeconds, req.ReflectionText, completionData).Scan(
+	`, userID, req.LessonID, score, req.TimeSpentSeconds, req.ReflectionText, completionData).Scan(
 		&completion.ID, &completion.UserID, &completion.LessonID,
 		&completion.Score, &completion.TimeSpentSeconds, &completion.ReflectionText,
 		&completion.CompletionData, &completion.CompletedAt,
@@ -184,12 +215,12 @@ func (s *LessonService) CompleteLesson(userID uuid.UUID, req models.CompleteLess
 
 	// Calculate XP based on score (for quizzes)
 	xpToAward := lesson.XPReward
-	if req.Score > 0 {
-		if req.Score >= 100 {
+	if score > 0 {
+		if score >= 100 {
 			xpToAward = 100 // Perfect quiz
-		} else if req.Score >= 80 {
+		} else if score >= 80 {
 			xpToAward = 75 // Good quiz
-		} else if req.Score >= 60 {
+		} else if score >= 60 {
 			xpToAward = 50 // Pass quiz
 		}
 	}
@@ -198,26 +229,52 @@ func (s *LessonService) CompleteLesson(userID uuid.UUID, req models.CompleteLess
 	metadata := map[string]interface{}{
 		"lesson_id":    lesson.ID.String(),
 		"lesson_title": lesson.Title,
-		"score":        req.Score,
+		"score":        score,
+	}
+	xpToAward, metadata, err = decayXPInTx(tx, userID, "lesson_completion", xpToAward, metadata)
+	if err != nil {
+		return nil, err
 	}
 	metadataJSON, _ := json.Marshal(metadata)
 
-	_, err = tx.Exec(`
-		INSERT INTO xp_events (user_id, source, xp_awarded, metadata)
-		VALUES ($1, $2, $3, $4)
-	`, userID, "lesson_completion", xpToAward, metadataJSON)
-	if err != nil {
+	if err = recordXPEvent(s.db, tx, userID, "lesson_completion", xpToAward, metadataJSON); err != nil {
 		return nil, fmt.Errorf("failed to award XP: %w", err)
 	}
 
-	// Update user progress
-	_, err = tx.Exec(`
+	// Update user progress. Projected progress follows the XP curve rather than curriculum_levels.
+	if s.projection != nil {
+		if err = s.projection.Apply(tx, userID, xpToAward); err != nil {
+			return nil, fmt.Errorf("failed to update progress: %w", err)
+		}
+	} else if err = s.addLessonXPDirect(tx, userID, xpToAward); err != nil {
+		return nil, err
+	}
+
+	// Completing the last of a track's lessons in a stage earns its mastery star
+	if err = awardMasteryStar(tx, userID, lesson.ID); err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	invalidateLeaderboards(s.leaderboards)
+
+	log.Printf("User %s completed lesson %s (XP: %d)", userID, lesson.Title, xpToAward)
+	return &completion, nil
+}
+
+// addLessonXPDirect adds XP to the user's progress, raises their level to the highest
+// curriculum level the new total reaches and records any prestige milestones
+func (s *LessonService) addLessonXPDirect(tx *sql.Tx, userID uuid.UUID, amount int) error {
+	_, err := tx.Exec(`
 		UPDATE user_progress
 		SET total_xp = total_xp + $1, updated_at = NOW()
 		WHERE user_id = $2
-	`, xpToAward, userID)
+	`, amount, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update progress: %w", err)
+		return fmt.Errorf("failed to update progress: %w", err)
 	}
 
 	// Check for level up
@@ -230,7 +287,7 @@ func (s *LessonService) CompleteLesson(userID uuid.UUID, req models.CompleteLess
 		)
 	`, userID).Scan(&newLevel)
 	if err != nil {
-		return nil, fmt.Errorf("failed to calculate new level: %w", err)
+		return fmt.Errorf("failed to calculate new level: %w", err)
 	}
 
 	// Update level if changed
@@ -239,7 +296,7 @@ func (s *LessonService) CompleteLesson(userID uuid.UUID, req models.CompleteLess
 		SELECT current_level FROM user_progress WHERE user_id = $1
 	`, userID).Scan(&currentLevel)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get current level: %w", err)
+		return fmt.Errorf("failed to get current level: %w", err)
 	}
 
 	if newLevel > currentLevel {
@@ -249,7 +306,7 @@ func (s *LessonService) CompleteLesson(userID uuid.UUID, req models.CompleteLess
 			WHERE user_id = $2
 		`, newLevel, userID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to update level: %w", err)
+			return fmt.Errorf("failed to update level: %w", err)
 		}
 
 		// Create level-up achievement
@@ -264,41 +321,31 @@ func (s *LessonService) CompleteLesson(userID uuid.UUID, req models.CompleteLess
 			VALUES ($1, $2, $3)
 		`, userID, "level_up", achievementJSON)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create achievement: %w", err)
+			return fmt.Errorf("failed to create achievement: %w", err)
 		}
 
 		log.Printf("User %s leveled up: %d → %d", userID, currentLevel, newLevel)
 	}
 
-	// Commit transaction
-	if err = tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	log.Printf("User %s completed lesson %s (XP: %d)", userID, lesson.Title, xpToAward)
-	return &completion, nil
+	return recordDirectMilestones(tx, userID, amount)
 }
 
-// GetUserReflections retrieves user's reflection history
-func (s *LessonService) GetUserReflections(userID uuid.UUID, limit int) ([]models.UserReflection, error) {
-	if limit <= 0 {
-		limit = 20
-	}
-
+// GetUserReflections retrieves a page of the user's reflection history, newest first
+func (s *LessonService) GetUserReflections(userID uuid.UUID, page api.Page) ([]models.UserReflection, error) {
+	where, limit, args := pageSQL(page, "created_at", "id", []interface{}{userID})
 	rows, err := s.db.Query(`
 		SELECT id, user_id, lesson_id, level_number, reflection_prompt, 
 		       reflection_text, quality_score, xp_awarded, is_public, created_at
 		FROM user_reflections
-		WHERE user_id = $1
-		ORDER BY created_at DESC
-		LIMIT $2
-	`, userID, limit)
+		WHERE user_id = $1`+where+`
+		ORDER BY created_at DESC, id
+		`+limit, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query reflections: %w", err)
 	}
 	defer rows.Close()
 
-	var reflections []models.UserReflection
+	reflections := []models.UserReflection{}
 	for rows.Next() {
 		var r models.UserReflection
 		var lessonID sql.NullString
@@ -329,10 +376,11 @@ func (s *LessonService) GetUserReflections(userID uuid.UUID, limit int) ([]model
 	return reflections, nil
 }
 
-// SubmitReflection saves a user reflection and awards XP
+// SubmitReflection saves a user reflection and awards XP.
+// Reflections by minor accounts are always private.
 func (s *LessonService) SubmitReflection(userID uuid.UUID, req models.SubmitReflectionRequest) (*models.UserReflection, error) {
 	// Calculate quality score (simplified - in production would use AI)
-	qualityScore := s.calculateReflectionQuality(req.ReflectionText)
+	qualityScore := ReflectionQuality(req.ReflectionText)
 
 	// Award XP based on quality
 	xpAwarded := 15 // Medium quality default
@@ -349,6 +397,12 @@ func (s *LessonService) SubmitReflection(userID uuid.UUID, req models.SubmitRefl
 	}
 	defer tx.Rollback()
 
+	// Repeated reflections in a day give less XP
+	xpAwarded, decayMetadata, err := decayXPInTx(tx, userID, "reflection_quality", xpAwarded, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	// Insert reflection
 	var reflection models.UserReflection
 	var lessonID interface{}
@@ -364,7 +418,7 @@ func (s *LessonService) SubmitReflection(userID uuid.UUID, req models.SubmitRefl
 	err = tx.QueryRow(`
 		INSERT INTO user_reflections (user_id, lesson_id, level_number, reflection_prompt, 
 		                               reflection_text, quality_score, xp_awarded, is_public)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8 AND NOT `+minorUserSQL+`)
 		RETURNING id, user_id, lesson_id, level_number, reflection_prompt, 
 		          reflection_text, quality_score, xp_awarded, is_public, created_at
 	`, userID, lessonID, levelNumber, req.ReflectionPrompt, req.ReflectionText,
@@ -382,22 +436,28 @@ func (s *LessonService) SubmitReflection(userID uuid.UUID, req models.SubmitRefl
 		"reflection_id": reflection.ID.String(),
 		"quality_score": qualityScore,
 	}
+	for k, v := range decayMetadata {
+		metadata[k] = v
+	}
 	metadataJSON, _ := json.Marshal(metadata)
 
-	_, err = tx.Exec(`
-		INSERT INTO xp_events (user_id, source, xp_awarded, metadata)
-		VALUES ($1, $2, $3, $4)
-	`, userID, "reflection_quality", xpAwarded, metadataJSON)
-	if err != nil {
+	if err = recordXPEvent(s.db, tx, userID, "reflection_quality", xpAwarded, metadataJSON); err != nil {
 		return nil, fmt.Errorf("failed to award XP: %w", err)
 	}
 
 	// Update user progress
-	_, err = tx.Exec(`
-		UPDATE user_progress
-		SET total_xp = total_xp + $1, updated_at = NOW()
-		WHERE user_id = $2
-	`, xpAwarded, userID)
+	if s.projection != nil {
+		err = s.projection.Apply(tx, userID, xpAwarded)
+	} else {
+		_, err = tx.Exec(`
+			UPDATE user_progress
+			SET total_xp = total_xp + $1, updated_at = NOW()
+			WHERE user_id = $2
+		`, xpAwarded, userID)
+		if err == nil {
+			err = recordDirectMilestones(tx, userID, xpAwarded)
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to update progress: %w", err)
 	}
@@ -405,38 +465,87 @@ func (s *LessonService) SubmitReflection(userID uuid.UUID, req models.SubmitRefl
 	if err = tx.Commit(); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	invalidateLeaderboards(s.leaderboards)
 
 	log.Printf("User %s submitted reflection (XP: %d, quality: %.2f)", userID, xpAwarded, qualityScore)
 	return &reflection, nil
 }
 
-// calculateReflectionQuality is a simplified quality assessment
-// In production, this would integrate with an AI model
-func (s *LessonService) calculateReflectionQuality(text string) float64 {
-	length := len(text)
+// UpdateLessonContent stores regenerated content as the lesson's next content version, keeps the
+// version for diffing and replaces the lesson's artifacts with the structured lesson's
+func (s *LessonService) UpdateLessonContent(lessonID uuid.UUID, contentMarkdown string, metadata json.RawMessage, generatedBy uuid.UUID, accessibility *models.LessonAccessibility) error {
+	if accessibility == nil {
+		accessibility = &models.LessonAccessibility{}
+	}
+	if accessibility.ReadingLevel <= 0 {
+		accessibility.ReadingLevel = EstimateReadingLevel(contentMarkdown)
+	}
+	if accessibility.AltText == nil {
+		accessibility.AltText = []models.VisualAltText{}
+	}
+	altTextJSON, _ := json.Marshal(accessibility.AltText)
 
-	// Basic heuristics
-	if length < 50 {
-		return 0.3
-	} else if length < 150 {
-		return 0.6
-	} else if length < 300 {
-		return 0.8
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	return 0.9
-}
+	defer tx.Rollback()
 
-func (s *LessonService) UpdateLessonContent(lessonID uuid.UUID, contentMarkdown string, metadata json.RawMessage, version int) error {
-	_, err := s.db.Exec(`
+	_, err = tx.Exec(`
 		UPDATE lessons
-		SET content_markdown = $1, metadata = $2, content_version = $3, updated_at = NOW()
-		WHERE id = $4
-	`, contentMarkdown, metadata, version, lessonID)
-	
+		SET content_markdown = $1, metadata = $2, content_version = COALESCE(content_version, 0) + 1,
+			plain_language_summary = NULLIF($4, ''), alt_text = $5, reading_level = $6,
+			updated_at = NOW()
+		WHERE id = $3
+	`, contentMarkdown, metadata, lessonID, accessibility.PlainLanguageSummary, altTextJSON, accessibility.ReadingLevel)
 	if err != nil {
 		return fmt.Errorf("failed to update lesson content: %w", err)
 	}
-	
-	log.Printf("Updated lesson %s with generated content (version %d)", lessonID, version)
+	if err := recordLessonVersion(tx, lessonID, LessonVersionGenerated, &generatedBy); err != nil {
+		return err
+	}
+	if err := storeLessonArtifacts(tx, lessonID, metadata); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Updated lesson %s with generated content", lessonID)
 	return nil
 }
+
+// GetLessonAccessibility returns a lesson's accessibility metadata. Lessons that were never
+// generated have no stored reading level, so it is estimated from the current content.
+func (s *LessonService) GetLessonAccessibility(lessonID uuid.UUID) (*models.LessonAccessibility, error) {
+	var summary, content sql.NullString
+	var altText []byte
+	var readingLevel sql.NullFloat64
+
+	err := s.db.QueryRow(`
+		SELECT plain_language_summary, COALESCE(alt_text, '[]'), reading_level, content_markdown
+		FROM lessons
+		WHERE id = $1
+	`, lessonID).Scan(&summary, &altText, &readingLevel, &content)
+	if err == sql.ErrNoRows {
+		return nil, ErrLessonNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lesson accessibility: %w", err)
+	}
+
+	accessibility := &models.LessonAccessibility{
+		PlainLanguageSummary: summary.String,
+		AltText:              []models.VisualAltText{},
+		ReadingLevel:         readingLevel.Float64,
+	}
+	if err := json.Unmarshal(altText, &accessibility.AltText); err != nil {
+		return nil, fmt.Errorf("failed to decode alt text: %w", err)
+	}
+	if !readingLevel.Valid {
+		accessibility.ReadingLevel = EstimateReadingLevel(content.String)
+		accessibility.ReadingLevelEstimated = true
+	}
+
+	return accessibility, nil
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/domain/lessondiff"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var ErrLessonVersionNotFound = errors.New("lesson version not found")
+
+// Lesson version sources
+const (
+	LessonVersionGenerated = "generated"
+	LessonVersionImport    = "import"
+	LessonVersionPack      = "pack"
+	LessonVersionAuthored  = "authored"
+)
+
+// LessonVersionDiff is what changed from one version of a lesson to another
+type LessonVersionDiff struct {
+	LessonID uuid.UUID               `json:"lesson_id"`
+	From     models.LessonVersion    `json:"from"`
+	To       models.LessonVersion    `json:"to"`
+	Markdown lessondiff.MarkdownDiff `json:"markdown"`
+	// Sections are the structured lesson's top-level sections, from the lessons' metadata
+	Sections []lessondiff.Section `json:"sections"`
+}
+
+// execer is satisfied by both *database.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// recordLessonVersion snapshots the lesson's current content under its content_version. Call it
+// after every write that bumps content_version, in the same transaction. A version already kept
+// is not overwritten.
+func recordLessonVersion(e execer, lessonID uuid.UUID, source string, createdBy *uuid.UUID) error {
+	_, err := e.Exec(`
+		INSERT INTO ngs_lesson_versions (lesson_id, version, content_markdown, metadata, source, created_by)
+		SELECT id, COALESCE(content_version, 0), COALESCE(content_markdown, ''), metadata, $2, $3
+		FROM lessons WHERE id = $1
+		ON CONFLICT (lesson_id, version) DO NOTHING
+	`, lessonID, source, createdBy)
+	if err != nil {
+		return fmt.Errorf("failed to record lesson version: %w", err)
+	}
+	return nil
+}
+
+// LessonVersionService lets educators review what changed between versions of a lesson's
+// content before approving it
+type LessonVersionService struct {
+	db *database.DB
+}
+
+func NewLessonVersionService(db *database.DB) *LessonVersionService {
+	return &LessonVersionService{db: db}
+}
+
+// ListVersions returns the lesson's versions, newest first, without their content
+func (s *LessonVersionService) ListVersions(lessonID uuid.UUID) ([]models.LessonVersion, error) {
+	rows, err := s.db.Query(`
+		SELECT lesson_id, version, source, created_by, created_at
+		FROM ngs_lesson_versions
+		WHERE lesson_id = $1
+		ORDER BY version DESC
+	`, lessonID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lesson versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []models.LessonVersion{}
+	for rows.Next() {
+		var version models.LessonVersion
+		var createdBy uuid.NullUUID
+		if err := rows.Scan(&version.LessonID, &version.Version, &version.Source, &createdBy, &version.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan lesson version: %w", err)
+		}
+		if createdBy.Valid {
+			version.CreatedBy = &createdBy.UUID
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+// GetVersion returns one version of the lesson with its content
+func (s *LessonVersionService) GetVersion(lessonID uuid.UUID, version int) (*models.LessonVersion, error) {
+	var v models.LessonVersion
+	var createdBy uuid.NullUUID
+	var metadata []byte
+	err := s.db.QueryRow(`
+		SELECT lesson_id, version, source, created_by, created_at, content_markdown, metadata
+		FROM ngs_lesson_versions
+		WHERE lesson_id = $1 AND version = $2
+	`, lessonID, version).Scan(&v.LessonID, &v.Version, &v.Source, &createdBy, &v.CreatedAt, &v.ContentMarkdown, &metadata)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: version %d", ErrLessonVersionNotFound, version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lesson version: %w", err)
+	}
+	if createdBy.Valid {
+		v.CreatedBy = &createdBy.UUID
+	}
+	v.Metadata = metadata
+	return &v, nil
+}
+
+// Diff compares version from of the lesson with version to: a line diff of the markdown, and how
+// each section of the structured lesson changed
+func (s *LessonVersionService) Diff(lessonID uuid.UUID, from, to int) (*LessonVersionDiff, error) {
+	a, err := s.GetVersion(lessonID, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.GetVersion(lessonID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &LessonVersionDiff{
+		LessonID: lessonID,
+		Markdown: lessondiff.Markdown(a.ContentMarkdown, b.ContentMarkdown),
+		Sections: lessondiff.Sections(a.Metadata, b.Metadata),
+	}
+	// The content is in the diff, so the versions are described without it
+	diff.From, diff.To = *a, *b
+	diff.From.ContentMarkdown, diff.From.Metadata = "", nil
+	diff.To.ContentMarkdown, diff.To.Metadata = "", nil
+	return diff, nil
+}
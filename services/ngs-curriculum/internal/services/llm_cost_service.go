@@ -0,0 +1,464 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"noble-ngs-curriculum/internal/clients/intelligence"
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/egress"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// llmCostFlushInterval is how often buffered LLM usage is added to ngs_llm_usage and the day's
+// spend checked against the budgets
+const llmCostFlushInterval = time.Minute
+
+// maxLLMCostDays bounds the days one LLM cost report covers
+const maxLLMCostDays = 366
+
+// llmBudgetAllScope is the ngs_llm_budget_alerts scope of the budget on all spend
+const llmBudgetAllScope = "*"
+
+var ErrInvalidDayRange = errors.New("from and to must be YYYY-MM-DD dates, from not after to, at most 366 days apart")
+
+var (
+	llmCost = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_llm_cost_usd_total",
+			Help: "LLM spend in USD priced with LLM_PRICING, by provider and operation.",
+		},
+		[]string{"provider", "operation"},
+	)
+
+	llmBudgetAlerts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_llm_budget_alerts_total",
+			Help: "Daily LLM budget alerts by scope (all or org) and delivery (logged, sent or failed).",
+		},
+		[]string{"scope", "delivery"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(llmCost, llmBudgetAlerts)
+}
+
+// LLMPricing is the USD price of 1,000 tokens by provider. Providers not priced cost nothing.
+type LLMPricing map[string]float64
+
+// ParseLLMPricing reads pricing such as "openai=0.01,gemini=0.0035". "none" prices nothing.
+func ParseLLMPricing(spec string) (LLMPricing, error) {
+	pricing := make(LLMPricing)
+	if strings.TrimSpace(spec) == "none" {
+		return pricing, nil
+	}
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		provider, price, ok := strings.Cut(field, "=")
+		provider = strings.TrimSpace(provider)
+		if !ok || provider == "" {
+			return nil, fmt.Errorf("%q is not provider=price", field)
+		}
+		if _, dup := pricing[provider]; dup {
+			return nil, fmt.Errorf("price for %s listed twice", provider)
+		}
+		usd, err := strconv.ParseFloat(strings.TrimSpace(price), 64)
+		if err != nil || usd < 0 || math.IsInf(usd, 0) || math.IsNaN(usd) {
+			return nil, fmt.Errorf("price for %s must be a non-negative number of USD per 1,000 tokens", provider)
+		}
+		pricing[provider] = usd
+	}
+	return pricing, nil
+}
+
+// Cost is the USD price of tokens served by provider
+func (p LLMPricing) Cost(provider string, tokens int64) float64 {
+	return float64(tokens) / 1000 * p[provider]
+}
+
+// String lists the prices in provider order, for logs
+func (p LLMPricing) String() string {
+	if len(p) == 0 {
+		return "none"
+	}
+	fields := make([]string, 0, len(p))
+	for provider, usd := range p {
+		fields = append(fields, provider+"="+strconv.FormatFloat(usd, 'f', -1, 64))
+	}
+	sort.Strings(fields)
+	return strings.Join(fields, ",")
+}
+
+// ParseDayRange reads a from and to YYYY-MM-DD, both included. Without from the range starts
+// 29 days before to, and without to it ends today.
+func ParseDayRange(from, to string, now time.Time) (time.Time, time.Time, error) {
+	end := dayOf(now)
+	if to != "" {
+		t, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidDayRange
+		}
+		end = t
+	}
+	start := end.AddDate(0, 0, -29)
+	if from != "" {
+		t, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidDayRange
+		}
+		start = t
+	}
+	if start.After(end) || end.Sub(start) >= maxLLMCostDays*24*time.Hour {
+		return time.Time{}, time.Time{}, ErrInvalidDayRange
+	}
+	return start, end, nil
+}
+
+func dayOf(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+type llmUsageKey struct {
+	day       time.Time
+	orgID     string
+	provider  string
+	operation string
+}
+
+type llmUsage struct {
+	tokens int64
+	cost   float64
+}
+
+// LLMBudgetAlert is posted to LLM_BUDGET_WEBHOOK_URL when a day's spend passes a budget
+type LLMBudgetAlert struct {
+	Type      string  `json:"type"`
+	Day       string  `json:"day"`
+	OrgID     string  `json:"org_id,omitempty"` // Empty for the budget on all spend
+	BudgetUSD float64 `json:"budget_usd"`
+	SpendUSD  float64 `json:"spend_usd"`
+}
+
+// LLMCostService prices the tokens intelligence service requests use and sums them per day,
+// organization, provider and operation. Like usage metering, usage is buffered in memory and
+// added to ngs_llm_usage every llmCostFlushInterval, after which the day's spend is checked
+// against LLM_DAILY_BUDGET_USD and LLM_ORG_DAILY_BUDGET_USD. Each budget passed is alerted
+// once a day across replicas: logged, and posted to LLM_BUDGET_WEBHOOK_URL when set.
+type LLMCostService struct {
+	db      *database.DB
+	config  *config.Config
+	clock   Clock
+	pricing LLMPricing
+	webhook *http.Client
+
+	mu      sync.Mutex
+	pending map[llmUsageKey]llmUsage
+
+	running sync.WaitGroup
+}
+
+func NewLLMCostService(db *database.DB, cfg *config.Config, clock Clock, pricing LLMPricing) *LLMCostService {
+	return &LLMCostService{
+		db:      db,
+		config:  cfg,
+		clock:   clock,
+		pricing: pricing,
+		webhook: egress.NewClient(egress.DestinationAlerts, 10*time.Second),
+		pending: make(map[llmUsageKey]llmUsage),
+	}
+}
+
+// Start flushes buffered usage and checks the budgets every llmCostFlushInterval, and flushes
+// once more when ctx is done
+func (s *LLMCostService) Start(ctx context.Context) {
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		ticker := time.NewTicker(llmCostFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				if err := s.Flush(); err != nil {
+					log.Printf("Lost buffered LLM usage at shutdown: %v", err)
+				}
+				return
+			case <-ticker.C:
+				if err := s.Flush(); err != nil {
+					log.Printf("Failed to flush LLM usage, retrying next time: %v", err)
+					continue
+				}
+				if err := s.CheckBudgets(ctx); err != nil {
+					log.Printf("Failed to check LLM budgets: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Wait blocks until the flusher started by Start has made its last flush
+func (s *LLMCostService) Wait() {
+	s.running.Wait()
+}
+
+// Record prices a request's tokens and adds them to today's usage. It is the intelligence
+// client's usage recorder.
+func (s *LLMCostService) Record(usage intelligence.Usage) {
+	if usage.Tokens <= 0 {
+		return
+	}
+	tokens := int64(usage.Tokens)
+	cost := s.pricing.Cost(usage.Provider, tokens)
+	llmCost.WithLabelValues(usage.Provider, usage.Operation).Add(cost)
+
+	key := llmUsageKey{day: dayOf(s.clock.Now()), orgID: usage.OrgID, provider: usage.Provider, operation: usage.Operation}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := s.pending[key]
+	pending.tokens += tokens
+	pending.cost += cost
+	s.pending[key] = pending
+}
+
+// Flush adds buffered usage to ngs_llm_usage in one transaction. On failure the usage stays
+// buffered for the next flush.
+func (s *LLMCostService) Flush() error {
+	s.mu.Lock()
+	batch := s.pending
+	s.pending = make(map[llmUsageKey]llmUsage)
+	s.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	if err := s.write(batch); err != nil {
+		s.mu.Lock()
+		for key, usage := range batch {
+			pending := s.pending[key]
+			pending.tokens += usage.tokens
+			pending.cost += usage.cost
+			s.pending[key] = pending
+		}
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (s *LLMCostService) write(batch map[llmUsageKey]llmUsage) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := s.clock.Now()
+	for key, usage := range batch {
+		_, err := tx.Exec(`
+			INSERT INTO ngs_llm_usage (day, org_id, provider, operation, tokens, cost_usd, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (day, org_id, provider, operation) DO UPDATE
+			SET tokens = ngs_llm_usage.tokens + EXCLUDED.tokens,
+			    cost_usd = ngs_llm_usage.cost_usd + EXCLUDED.cost_usd,
+			    updated_at = EXCLUDED.updated_at
+		`, key.day, key.orgID, key.provider, key.operation, usage.tokens, usage.cost, now)
+		if err != nil {
+			return fmt.Errorf("failed to add LLM usage: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit LLM usage: %w", err)
+	}
+	return nil
+}
+
+// CheckBudgets alerts each budget today's stored spend has passed and that has not been
+// alerted today. Usage still buffered on an instance is not counted until it is flushed.
+func (s *LLMCostService) CheckBudgets(ctx context.Context) error {
+	allBudget, orgBudget := s.config.LLMDailyBudgetUSD, s.config.LLMOrgDailyBudgetUSD
+	if allBudget <= 0 && orgBudget <= 0 {
+		return nil
+	}
+	day := dayOf(s.clock.Now())
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT org_id, SUM(cost_usd)
+		FROM ngs_llm_usage
+		WHERE day = $1
+		GROUP BY org_id
+		ORDER BY org_id
+	`, day)
+	if err != nil {
+		return fmt.Errorf("failed to query LLM spend: %w", err)
+	}
+	var alerts []LLMBudgetAlert
+	var total float64
+	for rows.Next() {
+		var orgID string
+		var spend float64
+		if err := rows.Scan(&orgID, &spend); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan LLM spend: %w", err)
+		}
+		total += spend
+		if orgBudget > 0 && orgID != "" && spend > orgBudget {
+			alerts = append(alerts, LLMBudgetAlert{OrgID: orgID, BudgetUSD: orgBudget, SpendUSD: spend})
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read LLM spend: %w", err)
+	}
+	if allBudget > 0 && total > allBudget {
+		alerts = append([]LLMBudgetAlert{{BudgetUSD: allBudget, SpendUSD: total}}, alerts...)
+	}
+
+	for _, alert := range alerts {
+		alert.Type = "llm_budget_exceeded"
+		alert.Day = day.Format("2006-01-02")
+		if err := s.alert(ctx, day, alert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// alert records and sends a budget alert unless it was sent today. The record is committed
+// only once the alert is sent, so a failed webhook is retried at the next check.
+func (s *LLMCostService) alert(ctx context.Context, day time.Time, alert LLMBudgetAlert) error {
+	scope, scopeLabel := llmBudgetAllScope, "all"
+	if alert.OrgID != "" {
+		scope, scopeLabel = alert.OrgID, "org"
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO ngs_llm_budget_alerts (day, scope, budget_usd, spend_usd, alerted_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (day, scope) DO NOTHING
+	`, day, scope, alert.BudgetUSD, alert.SpendUSD, s.clock.Now())
+	if err != nil {
+		return fmt.Errorf("failed to record LLM budget alert: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil
+	}
+
+	if alert.OrgID == "" {
+		log.Printf("LLM spend on %s is $%.2f, past the daily budget of $%.2f", alert.Day, alert.SpendUSD, alert.BudgetUSD)
+	} else {
+		log.Printf("LLM spend of organization %s on %s is $%.2f, past the daily budget of $%.2f", alert.OrgID, alert.Day, alert.SpendUSD, alert.BudgetUSD)
+	}
+	delivery := "logged"
+	if s.config.LLMBudgetWebhookURL != "" {
+		if err := s.postAlert(ctx, alert); err != nil {
+			llmBudgetAlerts.WithLabelValues(scopeLabel, "failed").Inc()
+			return err
+		}
+		delivery = "sent"
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit LLM budget alert: %w", err)
+	}
+	llmBudgetAlerts.WithLabelValues(scopeLabel, delivery).Inc()
+	return nil
+}
+
+func (s *LLMCostService) postAlert(ctx context.Context, alert LLMBudgetAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to marshal LLM budget alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.LLMBudgetWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create LLM budget alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.webhook.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post LLM budget alert: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("LLM budget webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// DailyCosts returns LLM spend per day and organization from start to end, both included,
+// newest day first, optionally only orgID's. Usage still buffered on an instance is not
+// included.
+func (s *LLMCostService) DailyCosts(start, end time.Time, orgID string, limit int) ([]models.LLMDailyCost, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	// Providers are folded into their day and organization; the limit counts those
+	rows, err := s.db.Query(`
+		WITH days AS (
+			SELECT day, org_id
+			FROM ngs_llm_usage
+			WHERE day >= $1 AND day <= $2 AND ($3 = '' OR org_id = $3)
+			GROUP BY day, org_id
+			ORDER BY day DESC, org_id
+			LIMIT $4
+		)
+		SELECT u.day, u.org_id, u.provider, SUM(u.tokens), SUM(u.cost_usd)
+		FROM ngs_llm_usage u
+		JOIN days d ON d.day = u.day AND d.org_id = u.org_id
+		GROUP BY u.day, u.org_id, u.provider
+		ORDER BY u.day DESC, u.org_id, u.provider
+	`, start, end, orgID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query LLM costs: %w", err)
+	}
+	defer rows.Close()
+
+	costs := []models.LLMDailyCost{}
+	for rows.Next() {
+		var day time.Time
+		var org, provider string
+		var usage models.LLMProviderCost
+		if err := rows.Scan(&day, &org, &provider, &usage.Tokens, &usage.CostUSD); err != nil {
+			return nil, fmt.Errorf("failed to scan LLM costs: %w", err)
+		}
+		dayStr := day.Format("2006-01-02")
+		if n := len(costs); n == 0 || costs[n-1].Day != dayStr || costs[n-1].OrgID != org {
+			costs = append(costs, models.LLMDailyCost{Day: dayStr, OrgID: org, ByProvider: map[string]models.LLMProviderCost{}})
+		}
+		cost := &costs[len(costs)-1]
+		cost.Tokens += usage.Tokens
+		cost.CostUSD += usage.CostUSD
+		cost.ByProvider[provider] = usage
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read LLM costs: %w", err)
+	}
+	return costs, nil
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// loadSampleInterval is how often the shedder samples the database pool and job queues
+	loadSampleInterval = time.Second
+	// loadShedHold keeps shedding this long after the last saturated sample, so a pool hovering
+	// around its threshold does not flap
+	loadShedHold = 5 * time.Second
+)
+
+var (
+	loadShedding = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ngs_load_shedding",
+		Help: "Whether this instance is shedding low-priority requests (1) or not (0).",
+	})
+
+	dbPoolWait = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ngs_db_pool_wait_seconds",
+		Help: "Average wait for a database connection over the last load sample.",
+	})
+
+	jobQueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ngs_job_queue_depth",
+			Help: "Background jobs waiting to start, by queue.",
+		},
+		[]string{"queue"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(loadShedding, dbPoolWait, jobQueueDepth)
+}
+
+// PoolWait returns the average time connection requests waited for a free connection between
+// two samples of the pool's stats, or 0 when none had to wait
+func PoolWait(previous, current sql.DBStats) time.Duration {
+	waits := current.WaitCount - previous.WaitCount
+	if waits <= 0 {
+		return 0
+	}
+	return (current.WaitDuration - previous.WaitDuration) / time.Duration(waits)
+}
+
+// jobQueue is a background job queue watched by the shedder
+type jobQueue struct {
+	name  string
+	depth func() int
+}
+
+// LoadShedder decides when the instance is saturated: when requests wait too long for a
+// database connection, or a job queue backs up. Low-priority requests are then turned away so
+// completions and XP awards keep their connections.
+type LoadShedder struct {
+	db     *database.DB
+	config *config.Config
+	clock  Clock
+
+	queues []jobQueue
+
+	shedding atomic.Bool
+
+	mu          sync.Mutex
+	status      models.LoadStatus
+	lastStats   sql.DBStats
+	saturatedAt time.Time
+
+	running sync.WaitGroup
+}
+
+func NewLoadShedder(db *database.DB, cfg *config.Config, clock Clock) *LoadShedder {
+	return &LoadShedder{
+		db:     db,
+		config: cfg,
+		clock:  clock,
+	}
+}
+
+// AddQueue watches a job queue's depth. Call it before Start.
+func (s *LoadShedder) AddQueue(name string, depth func() int) {
+	s.queues = append(s.queues, jobQueue{name: name, depth: depth})
+}
+
+func (s *LoadShedder) enabled() bool {
+	return s.config.LoadShedDBWaitMs > 0 || (s.config.LoadShedQueueDepth > 0 && len(s.queues) > 0)
+}
+
+// Start samples load every loadSampleInterval until ctx is done
+func (s *LoadShedder) Start(ctx context.Context) {
+	if !s.enabled() {
+		log.Println("Load shedding disabled")
+		return
+	}
+
+	s.mu.Lock()
+	s.lastStats = s.db.Stats()
+	s.mu.Unlock()
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		ticker := time.NewTicker(loadSampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.Sample()
+			}
+		}
+	}()
+}
+
+// Wait blocks until the sampler started by Start has stopped
+func (s *LoadShedder) Wait() {
+	s.running.Wait()
+}
+
+// Shedding reports whether low-priority requests should be turned away
+func (s *LoadShedder) Shedding() bool {
+	return s.shedding.Load()
+}
+
+// Status returns the latest load sample
+func (s *LoadShedder) Status() models.LoadStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Sample reads the database pool and job queues, and starts or stops shedding
+func (s *LoadShedder) Sample() models.LoadStatus {
+	stats := s.db.Stats()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	wait := PoolWait(s.lastStats, stats)
+	s.lastStats = stats
+	dbPoolWait.Set(wait.Seconds())
+
+	status := models.LoadStatus{
+		Reasons:       []string{},
+		DBPoolWaitMs:  float64(wait.Microseconds()) / 1000,
+		DBPoolInUse:   stats.InUse,
+		DBPoolMaxOpen: stats.MaxOpenConnections,
+		QueueDepths:   make(map[string]int, len(s.queues)),
+		SampledAt:     now,
+	}
+	if s.config.LoadShedDBWaitMs > 0 && wait >= time.Duration(s.config.LoadShedDBWaitMs)*time.Millisecond {
+		status.Reasons = append(status.Reasons, "db_pool_wait")
+	}
+	for _, queue := range s.queues {
+		depth := queue.depth()
+		status.QueueDepths[queue.name] = depth
+		jobQueueDepth.WithLabelValues(queue.name).Set(float64(depth))
+		if s.config.LoadShedQueueDepth > 0 && depth >= s.config.LoadShedQueueDepth {
+			status.Reasons = append(status.Reasons, "queue:"+queue.name)
+		}
+	}
+
+	if len(status.Reasons) > 0 {
+		s.saturatedAt = now
+	}
+	status.Shedding = !s.saturatedAt.IsZero() && now.Sub(s.saturatedAt) < loadShedHold
+
+	switch {
+	case status.Shedding && s.status.Shedding:
+		status.SheddingSince = s.status.SheddingSince
+	case status.Shedding:
+		status.SheddingSince = &now
+		log.Printf("Shedding low-priority requests: %s", strings.Join(status.Reasons, ", "))
+	case s.status.Shedding:
+		log.Printf("Stopped shedding low-priority requests after %s", now.Sub(*s.status.SheddingSince).Round(time.Second))
+	}
+
+	s.status = status
+	s.shedding.Store(status.Shedding)
+	if status.Shedding {
+		loadShedding.Set(1)
+	} else {
+		loadShedding.Set(0)
+	}
+	return status
+}
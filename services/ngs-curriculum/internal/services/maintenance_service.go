@@ -0,0 +1,166 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidMaintenance = errors.New("invalid maintenance settings")
+
+const (
+	// maintenanceCacheTTL is how long each instance trusts its copy of the switch, so a toggle
+	// reaches every replica within this time without a query per request
+	maintenanceCacheTTL = 5 * time.Second
+
+	maxMaintenanceMessage    = 500
+	maxMaintenanceRetryAfter = 24 * 60 * 60
+)
+
+// MaintenanceExempt reports whether path stays available during maintenance: health, metrics
+// and the admin and internal APIs
+func MaintenanceExempt(path string) bool {
+	switch path {
+	case "/", "/health", "/metrics":
+		return true
+	}
+	return strings.HasPrefix(path, "/ngs/admin/") || strings.HasPrefix(path, "/ngs/internal/")
+}
+
+// ValidateMaintenanceRequest checks a maintenance mode change before it is saved
+func ValidateMaintenanceRequest(req models.SetMaintenanceRequest) error {
+	if len(req.Message) > maxMaintenanceMessage {
+		return fmt.Errorf("%w: message must be at most %d characters", ErrInvalidMaintenance, maxMaintenanceMessage)
+	}
+	if req.RetryAfterSeconds != nil && (*req.RetryAfterSeconds < 1 || *req.RetryAfterSeconds > maxMaintenanceRetryAfter) {
+		return fmt.Errorf("%w: retry_after_seconds must be between 1 and %d", ErrInvalidMaintenance, maxMaintenanceRetryAfter)
+	}
+	return nil
+}
+
+type MaintenanceService struct {
+	db             *database.DB
+	clock          Clock
+	forced         bool
+	defaultMessage string
+
+	mu       sync.Mutex
+	status   models.MaintenanceStatus
+	loadedAt time.Time
+}
+
+func NewMaintenanceService(db *database.DB, cfg *config.Config, clock Clock) *MaintenanceService {
+	return &MaintenanceService{
+		db:             db,
+		clock:          clock,
+		forced:         cfg.MaintenanceMode,
+		defaultMessage: cfg.MaintenanceMessage,
+	}
+}
+
+// Status returns the maintenance switch, re-reading it once the cached copy is older than
+// maintenanceCacheTTL. If the read fails the last known state is kept.
+func (s *MaintenanceService) Status() models.MaintenanceStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.clock.Now().Sub(s.loadedAt) >= maintenanceCacheTTL {
+		status, err := s.load()
+		if err != nil {
+			log.Printf("Failed to read maintenance mode, keeping last state: %v", err)
+		} else {
+			s.status = status
+		}
+		s.loadedAt = s.clock.Now()
+	}
+	return s.withDefaults(s.status)
+}
+
+// SetStatus turns maintenance mode on or off for every instance
+func (s *MaintenanceService) SetStatus(userID uuid.UUID, req models.SetMaintenanceRequest) (*models.MaintenanceStatus, error) {
+	if err := ValidateMaintenanceRequest(req); err != nil {
+		return nil, err
+	}
+
+	var message sql.NullString
+	if strings.TrimSpace(req.Message) != "" {
+		message = sql.NullString{String: strings.TrimSpace(req.Message), Valid: true}
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO ngs_maintenance (id, enabled, message, retry_after_seconds, updated_by, updated_at)
+		VALUES (true, $1, $2, $3, $4, NOW())
+		ON CONFLICT (id) DO UPDATE
+		SET enabled = EXCLUDED.enabled, message = EXCLUDED.message,
+		    retry_after_seconds = EXCLUDED.retry_after_seconds,
+		    updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at
+	`, req.Enabled, message, req.RetryAfterSeconds, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update maintenance mode: %w", err)
+	}
+
+	status, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.status, s.loadedAt = status, s.clock.Now()
+	s.mu.Unlock()
+
+	state := "off"
+	if req.Enabled {
+		state = "on"
+	}
+	log.Printf("Admin %s turned maintenance mode %s", userID, state)
+	status = s.withDefaults(status)
+	return &status, nil
+}
+
+func (s *MaintenanceService) load() (models.MaintenanceStatus, error) {
+	var status models.MaintenanceStatus
+	var message sql.NullString
+	var retryAfter sql.NullInt64
+	var updatedBy uuid.NullUUID
+	err := s.db.QueryRow(`
+		SELECT enabled, message, retry_after_seconds, updated_by, updated_at
+		FROM ngs_maintenance
+		WHERE id
+	`).Scan(&status.Enabled, &message, &retryAfter, &updatedBy, &status.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return status, nil
+	}
+	if err != nil {
+		return status, fmt.Errorf("failed to read maintenance mode: %w", err)
+	}
+
+	status.Message = message.String
+	if retryAfter.Valid {
+		seconds := int(retryAfter.Int64)
+		status.RetryAfterSeconds = &seconds
+	}
+	if updatedBy.Valid {
+		status.UpdatedBy = &updatedBy.UUID
+	}
+	return status, nil
+}
+
+// withDefaults applies the environment override and the default message
+func (s *MaintenanceService) withDefaults(status models.MaintenanceStatus) models.MaintenanceStatus {
+	if s.forced {
+		status.Enabled, status.Forced = true, true
+	}
+	if status.Message == "" {
+		status.Message = s.defaultMessage
+	}
+	return status
+}
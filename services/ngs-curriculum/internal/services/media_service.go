@@ -0,0 +1,436 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"noble-ngs-curriculum/internal/clients/intelligence"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/jobs"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MediaPending    = "pending"
+	MediaProcessing = "processing"
+	MediaCompleted  = "completed"
+	MediaFailed     = "failed"
+
+	ArtifactTranscript = "transcript"
+	ArtifactSummary    = "summary"
+
+	// JobTranscription is the job kind of media transcriptions
+	JobTranscription = "transcription"
+)
+
+var (
+	ErrInvalidMedia   = errors.New("invalid media")
+	ErrInvalidSearch  = errors.New("search query is required")
+	ErrMediaNotFound  = errors.New("media not found")
+	ErrMediaDuplicate = errors.New("media is already attached to this lesson")
+)
+
+const (
+	// transcriptionTimeout covers download, transcription and summary of one video
+	transcriptionTimeout = 10 * time.Minute
+	// staleMediaAfter lets a job orphaned by a restart be re-triggered by attaching it again
+	staleMediaAfter = 15 * time.Minute
+
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+)
+
+var mediaLanguagePattern = regexp.MustCompile(`^[a-z]{2}$`)
+
+type MediaService struct {
+	db                 *database.DB
+	intelligenceClient *intelligence.Client
+	// jobService runs transcriptions as generation jobs, so the generation limit keeps a bulk
+	// attach from flooding the intelligence service
+	jobService *JobService
+}
+
+func NewMediaService(db *database.DB, intelligenceClient *intelligence.Client, jobService *JobService) *MediaService {
+	s := &MediaService{
+		db:                 db,
+		intelligenceClient: intelligenceClient,
+		jobService:         jobService,
+	}
+	jobService.Register(JobTranscription, jobs.ClassGeneration, s.RunTranscription)
+	return s
+}
+
+// transcriptionJob is the payload of a transcription job, kept with its dead letter if it fails
+type transcriptionJob struct {
+	MediaID     uuid.UUID `json:"media_id"`
+	MediaURL    string    `json:"media_url"`
+	LessonTitle string    `json:"lesson_title"`
+	Language    *string   `json:"language,omitempty"`
+	UserID      string    `json:"user_id"`
+	UserEmail   string    `json:"user_email"`
+	UserRole    string    `json:"user_role"`
+}
+
+// ValidateMediaRequest checks a media attachment before it is saved
+func ValidateMediaRequest(req models.AttachMediaRequest) error {
+	if len(req.URL) > 2048 {
+		return fmt.Errorf("%w: url must be at most 2048 characters", ErrInvalidMedia)
+	}
+	u, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%w: url must be an https URL", ErrInvalidMedia)
+	}
+	if req.Title != nil && len(*req.Title) > 255 {
+		return fmt.Errorf("%w: title must be at most 255 characters", ErrInvalidMedia)
+	}
+	if req.Language != nil && !mediaLanguagePattern.MatchString(*req.Language) {
+		return fmt.Errorf("%w: language must be a two-letter ISO-639-1 code", ErrInvalidMedia)
+	}
+	return nil
+}
+
+const mediaColumns = `id, lesson_id, media_type, url, title, language, status, error,
+	attached_by, created_at, updated_at, processed_at`
+
+func scanMedia(row rowScanner) (*models.LessonMedia, error) {
+	var m models.LessonMedia
+	var title, language, mediaErr sql.NullString
+	var processedAt sql.NullTime
+	err := row.Scan(
+		&m.ID, &m.LessonID, &m.MediaType, &m.URL, &title, &language, &m.Status, &mediaErr,
+		&m.AttachedBy, &m.CreatedAt, &m.UpdatedAt, &processedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if title.Valid {
+		m.Title = &title.String
+	}
+	if language.Valid {
+		m.Language = &language.String
+	}
+	if mediaErr.Valid {
+		m.Error = &mediaErr.String
+	}
+	if processedAt.Valid {
+		m.ProcessedAt = &processedAt.Time
+	}
+	return &m, nil
+}
+
+// AttachMedia attaches a video to a lesson and starts a background transcription job. Attaching
+// a URL again re-triggers the job when the previous attempt failed or was orphaned; otherwise
+// it is reported as a duplicate.
+func (s *MediaService) AttachMedia(lessonID uuid.UUID, attachedBy uuid.UUID, userEmail, userRole string, req models.AttachMediaRequest) (*models.LessonMedia, error) {
+	if err := ValidateMediaRequest(req); err != nil {
+		return nil, err
+	}
+	mediaURL := strings.TrimSpace(req.URL)
+
+	var lessonTitle string
+	err := s.db.QueryRow(`SELECT title FROM lessons WHERE id = $1`, lessonID).Scan(&lessonTitle)
+	if err == sql.ErrNoRows {
+		return nil, ErrLessonNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lesson: %w", err)
+	}
+
+	media, err := scanMedia(s.db.QueryRow(`
+		INSERT INTO lesson_media (lesson_id, url, title, language, attached_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (lesson_id, url) DO UPDATE
+		SET title = COALESCE(EXCLUDED.title, lesson_media.title),
+		    language = COALESCE(EXCLUDED.language, lesson_media.language),
+		    attached_by = EXCLUDED.attached_by,
+		    status = 'pending', error = NULL, updated_at = NOW()
+		WHERE lesson_media.status = 'failed'
+		   OR (lesson_media.status IN ('pending', 'processing') AND lesson_media.updated_at < NOW() - $6::interval)
+		RETURNING `+mediaColumns,
+		lessonID, mediaURL, req.Title, req.Language, attachedBy, fmt.Sprintf("%d seconds", int(staleMediaAfter.Seconds())),
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrMediaDuplicate
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach media: %w", err)
+	}
+
+	title := lessonTitle
+	if media.Title != nil {
+		title = lessonTitle + " - " + *media.Title
+	}
+	err = s.jobService.Submit(JobTranscription, "transcribe "+media.ID.String(), transcriptionJob{
+		MediaID:     media.ID,
+		MediaURL:    mediaURL,
+		LessonTitle: title,
+		Language:    media.Language,
+		UserID:      attachedBy.String(),
+		UserEmail:   userEmail,
+		UserRole:    userRole,
+	})
+	if err != nil {
+		// Left pending; attaching the media again after restart retries it
+		log.Printf("Transcription of media %s not queued: %v", media.ID, err)
+	}
+
+	log.Printf("User %s attached media %s to lesson %s", attachedBy, media.ID, lessonID)
+	return media, nil
+}
+
+// RunTranscription runs one transcription job and stores the transcript and summary as
+// artifacts. On failure, including a drain cancelling ctx, the media is marked failed and the
+// error returned, so the job is kept as a dead letter; retrying it or attaching the media again
+// runs it again.
+func (s *MediaService) RunTranscription(ctx context.Context, payload json.RawMessage) error {
+	var job transcriptionJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return fmt.Errorf("invalid transcription payload: %w", err)
+	}
+	return s.transcribe(ctx, job.MediaID, job.MediaURL, job.LessonTitle, job.Language, job.UserID, job.UserEmail, job.UserRole)
+}
+
+func (s *MediaService) transcribe(ctx context.Context, mediaID uuid.UUID, mediaURL, lessonTitle string, language *string, userID, userEmail, userRole string) error {
+	if _, err := s.db.Exec(`UPDATE lesson_media SET status = 'processing', updated_at = NOW() WHERE id = $1`, mediaID); err != nil {
+		return fmt.Errorf("failed to mark media %s processing: %w", mediaID, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, transcriptionTimeout)
+	defer cancel()
+
+	resp, err := s.intelligenceClient.TranscribeMedia(ctx, intelligence.TranscribeMediaRequest{
+		MediaURL:    mediaURL,
+		LessonTitle: lessonTitle,
+		Language:    language,
+	}, userID, userEmail, userRole)
+	if err == nil {
+		err = s.storeArtifacts(mediaID, resp)
+	}
+	if err != nil {
+		log.Printf("Transcription failed for media %s: %v", mediaID, err)
+		if _, dbErr := s.db.Exec(`
+			UPDATE lesson_media SET status = 'failed', error = $2, updated_at = NOW() WHERE id = $1
+		`, mediaID, err.Error()); dbErr != nil {
+			log.Printf("Failed to mark media %s failed: %v", mediaID, dbErr)
+		}
+		return err
+	}
+
+	log.Printf("Media %s transcribed (%d characters, %d tokens)", mediaID, len(resp.Transcript), resp.TokensUsed)
+	return nil
+}
+
+// storeArtifacts replaces the media's artifacts and marks it completed
+func (s *MediaService) storeArtifacts(mediaID uuid.UUID, resp *intelligence.TranscribeMediaResponse) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var lessonID uuid.UUID
+	err = tx.QueryRow(`
+		UPDATE lesson_media
+		SET status = 'completed', error = NULL, language = COALESCE($2, language),
+		    processed_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+		RETURNING lesson_id
+	`, mediaID, resp.Language).Scan(&lessonID)
+	if err == sql.ErrNoRows {
+		// The media or its lesson was deleted while the job ran
+		return ErrMediaNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update media: %w", err)
+	}
+
+	artifacts := []struct {
+		artifactType string
+		content      string
+		model        string
+	}{
+		{ArtifactTranscript, resp.Transcript, resp.Model},
+		{ArtifactSummary, resp.Summary, resp.Provider},
+	}
+	for _, a := range artifacts {
+		_, err = tx.Exec(`
+			INSERT INTO lesson_media_artifacts (lesson_id, media_id, artifact_type, content, model)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (media_id, artifact_type) DO UPDATE
+			SET content = EXCLUDED.content, model = EXCLUDED.model, created_at = NOW()
+		`, lessonID, mediaID, a.artifactType, a.content, a.model)
+		if err != nil {
+			return fmt.Errorf("failed to store %s: %w", a.artifactType, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit artifacts: %w", err)
+	}
+	return nil
+}
+
+// lessonOpen reports whether the lesson is shown to the user and at or below their level
+func (s *MediaService) lessonOpen(lessonID uuid.UUID, userID uuid.UUID) error {
+	var locked bool
+	err := s.db.QueryRow(`
+		SELECT `+lessonLockedSQL("lessons", "$2")+`
+		FROM lessons WHERE id = $1 AND `+lessonVisibleSQL("lessons", "$2")+`
+	`, lessonID, userID).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return ErrLessonNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query lesson: %w", err)
+	}
+	if locked {
+		return ErrLevelLocked
+	}
+	return nil
+}
+
+// ListLessonMedia returns the media attached to a lesson with their transcription status. A
+// lesson above the user's level is ErrLevelLocked.
+func (s *MediaService) ListLessonMedia(lessonID uuid.UUID, userID uuid.UUID) ([]models.LessonMedia, error) {
+	if err := s.lessonOpen(lessonID, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+mediaColumns+` FROM lesson_media
+		WHERE lesson_id = $1
+		ORDER BY created_at, id
+	`, lessonID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lesson media: %w", err)
+	}
+	defer rows.Close()
+
+	media := []models.LessonMedia{}
+	for rows.Next() {
+		m, err := scanMedia(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan lesson media: %w", err)
+		}
+		media = append(media, *m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read lesson media: %w", err)
+	}
+
+	return media, nil
+}
+
+// GetLessonArtifacts returns the transcripts and summaries generated for a lesson's media. A
+// lesson above the user's level is ErrLevelLocked.
+func (s *MediaService) GetLessonArtifacts(lessonID uuid.UUID, userID uuid.UUID) ([]models.LessonArtifact, error) {
+	if err := s.lessonOpen(lessonID, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT a.id, a.lesson_id, a.media_id, a.artifact_type, a.content, a.model, a.created_at
+		FROM lesson_media_artifacts a
+		JOIN lesson_media m ON m.id = a.media_id
+		WHERE a.lesson_id = $1
+		ORDER BY m.created_at, a.artifact_type, a.id
+	`, lessonID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lesson artifacts: %w", err)
+	}
+	defer rows.Close()
+
+	artifacts := []models.LessonArtifact{}
+	for rows.Next() {
+		var a models.LessonArtifact
+		var model sql.NullString
+		if err := rows.Scan(&a.ID, &a.LessonID, &a.MediaID, &a.ArtifactType, &a.Content, &model, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan lesson artifact: %w", err)
+		}
+		if model.Valid {
+			a.Model = &model.String
+		}
+		artifacts = append(artifacts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read lesson artifacts: %w", err)
+	}
+
+	return artifacts, nil
+}
+
+// Search runs a full-text query over lessons and their media artifacts. Each lesson appears
+// once, with a snippet from whichever of its texts matched best. Lessons above the user's level
+// are left out, so their content never shows in a snippet.
+func (s *MediaService) Search(userID uuid.UUID, query string, limit int) (*models.SearchResponse, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, ErrInvalidSearch
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	rows, err := s.db.Query(`
+		WITH q AS (SELECT websearch_to_tsquery('english', $2) AS query),
+		matches AS (
+			SELECT l.id AS lesson_id, 'lesson' AS source, ts_rank(l.search_vector, q.query) AS rank,
+			       COALESCE(l.description, '') || ' ' || COALESCE(l.content_markdown, '') AS body
+			FROM lessons l, q
+			WHERE l.search_vector @@ q.query
+			UNION ALL
+			SELECT a.lesson_id, a.artifact_type, ts_rank(a.search_vector, q.query), a.content
+			FROM lesson_media_artifacts a, q
+			WHERE a.search_vector @@ q.query
+		),
+		best AS (
+			SELECT DISTINCT ON (m.lesson_id) m.lesson_id, m.source, m.rank, m.body
+			FROM matches m
+			JOIN lessons l ON l.id = m.lesson_id
+			WHERE `+lessonVisibleSQL("l", "$1")+` AND NOT `+lessonLockedSQL("l", "$1")+`
+			ORDER BY m.lesson_id, m.rank DESC
+		)
+		SELECT l.id, l.level_id, l.title, COALESCE(l.description, ''), b.source,
+		       ts_headline('english', b.body, q.query, 'MaxFragments=2, MaxWords=30, MinWords=10'),
+		       b.rank
+		FROM best b
+		JOIN lessons l ON l.id = b.lesson_id, q
+		ORDER BY b.rank DESC, l.level_id, l.lesson_order, l.id
+		LIMIT $3
+	`, userID, query, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search lessons: %w", err)
+	}
+	defer rows.Close()
+
+	resp := &models.SearchResponse{Query: query, Results: []models.SearchResult{}}
+	for rows.Next() {
+		var r models.SearchResult
+		if err := rows.Scan(&r.LessonID, &r.LevelID, &r.Title, &r.Description, &r.Source, &r.Snippet, &r.Rank); err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		resp.Results = append(resp.Results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read search results: %w", err)
+	}
+	if len(resp.Results) > limit {
+		resp.Results, resp.HasMore = resp.Results[:limit], true
+	}
+	resp.Count = len(resp.Results)
+
+	return resp, nil
+}
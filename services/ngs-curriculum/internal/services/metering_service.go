@@ -0,0 +1,326 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sync"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Meters
+const (
+	MeterAPICalls     = "api_calls"
+	MeterLLMTokens    = "llm_tokens"
+	MeterSandboxMs    = "sandbox_ms"
+	MeterStorageBytes = "storage_bytes"
+)
+
+// meterFlushInterval is how often buffered usage is added to usage_meters
+const meterFlushInterval = 15 * time.Second
+
+var (
+	ErrInvalidOrgID = errors.New("org ID must be 1-64 letters, digits, '-' or '_'")
+	ErrInvalidMonth = errors.New("month must be YYYY-MM")
+)
+
+var orgIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+var meterFlushFailures = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "ngs_usage_meter_flush_failures_total",
+	Help: "Failed writes of buffered usage to usage_meters; the usage is kept for the next flush.",
+})
+
+func init() {
+	prometheus.MustRegister(meterFlushFailures)
+}
+
+// ValidateOrgID checks an X-Org-Id value
+func ValidateOrgID(orgID string) error {
+	if !orgIDPattern.MatchString(orgID) {
+		return ErrInvalidOrgID
+	}
+	return nil
+}
+
+// ParseMonth reads a YYYY-MM month; empty is the month of now
+func ParseMonth(month string, now time.Time) (time.Time, error) {
+	if month == "" {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+	}
+	start, err := time.Parse("2006-01", month)
+	if err != nil {
+		return time.Time{}, ErrInvalidMonth
+	}
+	return start, nil
+}
+
+type meterKey struct {
+	orgID string
+	meter string
+	day   time.Time
+}
+
+// orgCalls is an organization's API calls this month, for the quota
+type orgCalls struct {
+	month time.Time
+	used  int64
+}
+
+// MeteringService meters API calls, LLM tokens, sandbox time and stored bytes per organization.
+// Usage is buffered in memory and added to usage_meters every meterFlushInterval, so metering
+// costs a request no database write. The API call quota (ORG_API_CALLS_PER_MONTH) is checked
+// against this instance's view of the month, refreshed from the table at each flush.
+type MeteringService struct {
+	db     *database.DB
+	config *config.Config
+	clock  Clock
+
+	mu      sync.Mutex
+	pending map[meterKey]int64
+	calls   map[string]*orgCalls
+
+	running sync.WaitGroup
+}
+
+func NewMeteringService(db *database.DB, cfg *config.Config, clock Clock) *MeteringService {
+	return &MeteringService{
+		db:      db,
+		config:  cfg,
+		clock:   clock,
+		pending: make(map[meterKey]int64),
+		calls:   make(map[string]*orgCalls),
+	}
+}
+
+// Start flushes buffered usage every meterFlushInterval, and once more when ctx is done
+func (s *MeteringService) Start(ctx context.Context) {
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		ticker := time.NewTicker(meterFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				if err := s.Flush(); err != nil {
+					log.Printf("Lost buffered usage at shutdown: %v", err)
+				}
+				return
+			case <-ticker.C:
+				if err := s.Flush(); err != nil {
+					log.Printf("Failed to flush usage meters, retrying next time: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Wait blocks until the flusher started by Start has made its last flush
+func (s *MeteringService) Wait() {
+	s.running.Wait()
+}
+
+// Record adds quantity to an organization's meter for today
+func (s *MeteringService) Record(orgID, meter string, quantity int64) {
+	if quantity <= 0 {
+		return
+	}
+	now := s.clock.Now()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[meterKey{orgID: orgID, meter: meter, day: day}] += quantity
+	if meter == MeterAPICalls {
+		if calls := s.calls[orgID]; calls != nil && calls.month.Equal(monthOf(day)) {
+			calls.used += quantity
+		}
+	}
+}
+
+func monthOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// CheckQuota reports whether the organization may make another API call this month, and when
+// its quota resets. Without ORG_API_CALLS_PER_MONTH every call is allowed.
+func (s *MeteringService) CheckQuota(orgID string) (bool, time.Time, error) {
+	month := monthOf(s.clock.Now())
+	resetsAt := month.AddDate(0, 1, 0)
+	limit := int64(s.config.OrgAPICallsPerMonth)
+	if limit <= 0 {
+		return true, resetsAt, nil
+	}
+
+	s.mu.Lock()
+	calls := s.calls[orgID]
+	s.mu.Unlock()
+	if calls == nil || !calls.month.Equal(month) {
+		if err := s.loadCalls(orgID, month); err != nil {
+			return false, resetsAt, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[orgID].used < limit, resetsAt, nil
+}
+
+// loadCalls sets an organization's calls this month to the stored total plus what is buffered
+func (s *MeteringService) loadCalls(orgID string, month time.Time) error {
+	var stored int64
+	err := s.db.QueryRow(`
+		SELECT COALESCE(SUM(quantity), 0) FROM usage_meters
+		WHERE org_id = $1 AND meter = 'api_calls' AND day >= $2 AND day < $3
+	`, orgID, month, month.AddDate(0, 1, 0)).Scan(&stored)
+	if err != nil {
+		return fmt.Errorf("failed to query API calls: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, quantity := range s.pending {
+		if key.orgID == orgID && key.meter == MeterAPICalls && monthOf(key.day).Equal(month) {
+			stored += quantity
+		}
+	}
+	s.calls[orgID] = &orgCalls{month: month, used: stored}
+	return nil
+}
+
+// Flush adds buffered usage to usage_meters in one transaction. On failure the usage stays
+// buffered for the next flush. Quota counts are then refreshed, picking up other instances'
+// calls.
+func (s *MeteringService) Flush() error {
+	s.mu.Lock()
+	batch := s.pending
+	s.pending = make(map[meterKey]int64)
+	s.mu.Unlock()
+
+	if len(batch) > 0 {
+		if err := s.write(batch); err != nil {
+			meterFlushFailures.Inc()
+			s.mu.Lock()
+			for key, quantity := range batch {
+				s.pending[key] += quantity
+			}
+			s.mu.Unlock()
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	month := monthOf(s.clock.Now())
+	var orgs []string
+	for orgID, calls := range s.calls {
+		if calls.month.Equal(month) {
+			orgs = append(orgs, orgID)
+		} else {
+			delete(s.calls, orgID)
+		}
+	}
+	s.mu.Unlock()
+	for _, orgID := range orgs {
+		if err := s.loadCalls(orgID, month); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MeteringService) write(batch map[meterKey]int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := s.clock.Now()
+	for key, quantity := range batch {
+		_, err := tx.Exec(`
+			INSERT INTO usage_meters (org_id, meter, day, quantity, updated_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (org_id, meter, day) DO UPDATE
+			SET quantity = usage_meters.quantity + EXCLUDED.quantity, updated_at = EXCLUDED.updated_at
+		`, key.orgID, key.meter, key.day, quantity, now)
+		if err != nil {
+			return fmt.Errorf("failed to add usage: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit usage: %w", err)
+	}
+	return nil
+}
+
+// MonthlyUsage sums each organization's meters over the month starting at month, ordered by
+// organization, optionally only orgID. Usage still buffered on an instance is not included.
+func (s *MeteringService) MonthlyUsage(month time.Time, orgID string, limit int) ([]models.OrgUsage, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	rows, err := s.db.Query(`
+		SELECT org_id,
+		       COALESCE(SUM(quantity) FILTER (WHERE meter = 'api_calls'), 0),
+		       COALESCE(SUM(quantity) FILTER (WHERE meter = 'llm_tokens'), 0),
+		       COALESCE(SUM(quantity) FILTER (WHERE meter = 'sandbox_ms'), 0),
+		       COALESCE(SUM(quantity) FILTER (WHERE meter = 'storage_bytes'), 0)
+		FROM usage_meters
+		WHERE day >= $1 AND day < $2 AND ($3 = '' OR org_id = $3)
+		GROUP BY org_id
+		ORDER BY org_id
+		LIMIT $4
+	`, month, month.AddDate(0, 1, 0), orgID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage meters: %w", err)
+	}
+	defer rows.Close()
+
+	usage := []models.OrgUsage{}
+	for rows.Next() {
+		u := models.OrgUsage{Month: month.Format("2006-01")}
+		var sandboxMs int64
+		if err := rows.Scan(&u.OrgID, &u.APICalls, &u.LLMTokens, &sandboxMs, &u.StorageBytes); err != nil {
+			return nil, fmt.Errorf("failed to scan usage meters: %w", err)
+		}
+		u.SandboxMinutes = math.Round(float64(sandboxMs)/600) / 100
+		u.APICallsLimit = s.apiCallsLimit()
+		usage = append(usage, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage meters: %w", err)
+	}
+
+	return usage, nil
+}
+
+// OrgMonthlyUsage returns one organization's usage over the month, zero if nothing was metered
+func (s *MeteringService) OrgMonthlyUsage(month time.Time, orgID string) (*models.OrgUsage, error) {
+	usage, err := s.MonthlyUsage(month, orgID, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(usage) == 0 {
+		return &models.OrgUsage{OrgID: orgID, Month: month.Format("2006-01"), APICallsLimit: s.apiCallsLimit()}, nil
+	}
+	return &usage[0], nil
+}
+
+func (s *MeteringService) apiCallsLimit() *int64 {
+	if s.config.OrgAPICallsPerMonth <= 0 {
+		return nil
+	}
+	limit := int64(s.config.OrgAPICallsPerMonth)
+	return &limit
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ChallengeTypeOptimization challenges pass when every test passes and score the solution's
+// runtime and memory against the reference baselines
+const ChallengeTypeOptimization = "optimization"
+
+// Shares of runtime and memory in an optimization score when both baselines are set
+const (
+	optimizationRuntimeWeight = 0.7
+	optimizationMemoryWeight  = 0.3
+)
+
+// ErrNotOptimizationChallenge is returned for runtime leaderboards of other challenge types
+var ErrNotOptimizationChallenge = errors.New("runtime leaderboards are only kept for optimization challenges")
+
+// measureUsage returns a run's total runtime over all cases and its peak memory
+func measureUsage(results *models.TestResults) (float64, int64) {
+	var runtimeMs float64
+	var memoryKB int64
+	for _, r := range results.Tests {
+		runtimeMs += r.RuntimeMs
+		if r.MemoryKB > memoryKB {
+			memoryKB = r.MemoryKB
+		}
+	}
+	return runtimeMs, memoryKB
+}
+
+// baselineScore is 100 at or under the baseline, falling in proportion to how far measured is over it
+func baselineScore(baseline, measured float64) int {
+	if measured <= baseline || measured <= 0 {
+		return 100
+	}
+	return int(math.Round(100 * baseline / measured))
+}
+
+// ScorePerformance scores a correct run against the baselines, recording the comparison on
+// results. A missing baseline leaves the score to the other; with neither the score is 100.
+func ScorePerformance(results *models.TestResults, baselineRuntimeMs float64, baselineMemoryKB int64) int {
+	runtimeMs, memoryKB := measureUsage(results)
+	perf := &models.PerformanceScore{
+		RuntimeMs:         runtimeMs,
+		MemoryKB:          memoryKB,
+		BaselineRuntimeMs: baselineRuntimeMs,
+		BaselineMemoryKB:  baselineMemoryKB,
+		RuntimeScore:      100,
+		MemoryScore:       100,
+	}
+	results.Performance = perf
+
+	hasRuntime, hasMemory := baselineRuntimeMs > 0, baselineMemoryKB > 0
+	if hasRuntime {
+		perf.RuntimeScore = baselineScore(baselineRuntimeMs, runtimeMs)
+	}
+	if hasMemory {
+		perf.MemoryScore = baselineScore(float64(baselineMemoryKB), float64(memoryKB))
+	}
+
+	switch {
+	case hasRuntime && hasMemory:
+		return int(math.Round(optimizationRuntimeWeight*float64(perf.RuntimeScore) + optimizationMemoryWeight*float64(perf.MemoryScore)))
+	case hasRuntime:
+		return perf.RuntimeScore
+	case hasMemory:
+		return perf.MemoryScore
+	default:
+		return 100
+	}
+}
+
+// performanceFeedback compares a correct optimization solution with the baselines
+func performanceFeedback(p *models.PerformanceScore) string {
+	if p.RuntimeScore >= 100 && p.MemoryScore >= 100 {
+		return "Excellent work! Every test passes and your solution is as fast and lean as the reference."
+	}
+
+	var parts []string
+	if p.BaselineRuntimeMs > 0 && p.RuntimeScore < 100 {
+		parts = append(parts, fmt.Sprintf("took %.1f ms against a %.1f ms baseline", p.RuntimeMs, p.BaselineRuntimeMs))
+	}
+	if p.BaselineMemoryKB > 0 && p.MemoryScore < 100 {
+		parts = append(parts, fmt.Sprintf("peaked at %d KB against a %d KB baseline", p.MemoryKB, p.BaselineMemoryKB))
+	}
+	return "Every test passes. Your solution " + strings.Join(parts, " and ") + "; optimize it to raise your score."
+}
+
+// GetRuntimeLeaderboard ranks users by their fastest passing submission to an optimization
+// challenge, then by memory, skipping users who opted out of leaderboards, minor accounts and
+// deactivated accounts
+func (s *ChallengeService) GetRuntimeLeaderboard(challengeID uuid.UUID, limit int) ([]models.RuntimeLeaderboardEntry, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	challenge, err := s.GetChallenge(challengeID)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.ChallengeType != ChallengeTypeOptimization {
+		return nil, ErrNotOptimizationChallenge
+	}
+
+	rows, err := s.db.Query(`
+		SELECT RANK() OVER (ORDER BY runtime_ms, memory_kb), user_id, id, runtime_ms,
+		       COALESCE(memory_kb, 0), COALESCE(score, 0), submitted_at
+		FROM (
+			SELECT DISTINCT ON (user_id) user_id, id, runtime_ms, memory_kb, score, submitted_at
+			FROM challenge_submissions cs
+			WHERE challenge_id = $1 AND passed = true AND runtime_ms IS NOT NULL
+			  AND NOT EXISTS (
+				SELECT 1 FROM user_settings us
+				WHERE us.user_id = cs.user_id
+				  AND (us.show_on_leaderboard = false OR us.age_band IN ('child', 'teen') OR us.deactivated_at IS NOT NULL)
+			  )
+			ORDER BY user_id, runtime_ms, memory_kb NULLS LAST, submitted_at
+		) best
+		ORDER BY runtime_ms, memory_kb NULLS LAST, submitted_at, user_id
+		LIMIT $2
+	`, challengeID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query runtime leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.RuntimeLeaderboardEntry{}
+	for rows.Next() {
+		var e models.RuntimeLeaderboardEntry
+		if err := rows.Scan(&e.Rank, &e.UserID, &e.SubmissionID, &e.RuntimeMs, &e.MemoryKB, &e.Score, &e.SubmittedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan runtime leaderboard entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read runtime leaderboard: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidOrgSettings  = errors.New("invalid organization settings")
+	ErrInvalidEducator     = errors.New("invalid educator invitation")
+	ErrEducatorExists      = errors.New("user is already an educator of this organization")
+	ErrEducatorNotFound    = errors.New("educator not found in this organization")
+	ErrInvitationNotFound  = errors.New("no invitation to this organization")
+	ErrInvalidEducatorList = errors.New("status must be invited, active or all")
+)
+
+// Org educator statuses
+const (
+	OrgEducatorInvited = "invited"
+	OrgEducatorActive  = "active"
+)
+
+// OrganizationService manages an organization's educators and the defaults its members start from
+type OrganizationService struct {
+	db     *database.DB
+	config *config.Config
+	clock  Clock
+}
+
+func NewOrganizationService(db *database.DB, cfg *config.Config, clock Clock) *OrganizationService {
+	return &OrganizationService{
+		db:     db,
+		config: cfg,
+		clock:  clock,
+	}
+}
+
+// ValidateOrgSettingsUpdate checks the agent unlock level, tracks and locale in a settings update
+func ValidateOrgSettingsUpdate(req models.UpdateOrgSettingsRequest, maxLevel int) error {
+	if req.AgentUnlockLevel != nil {
+		if req.ClearAgentUnlockLevel {
+			return fmt.Errorf("%w: agent_unlock_level cannot be set and cleared", ErrInvalidOrgSettings)
+		}
+		if *req.AgentUnlockLevel < 1 || *req.AgentUnlockLevel > maxLevel {
+			return fmt.Errorf("%w: agent_unlock_level must be between 1 and %d", ErrInvalidOrgSettings, maxLevel)
+		}
+	}
+
+	if req.EnabledTracks != nil {
+		if req.ClearEnabledTracks {
+			return fmt.Errorf("%w: enabled_tracks cannot be set and cleared", ErrInvalidOrgSettings)
+		}
+		if len(*req.EnabledTracks) == 0 {
+			return fmt.Errorf("%w: enabled_tracks needs at least one track", ErrInvalidOrgSettings)
+		}
+		seen := map[string]bool{}
+		for _, track := range *req.EnabledTracks {
+			if _, ok := LessonTracks[track]; !ok {
+				return fmt.Errorf("%w: unknown track %q", ErrInvalidOrgSettings, track)
+			}
+			if seen[track] {
+				return fmt.Errorf("%w: track %q listed twice", ErrInvalidOrgSettings, track)
+			}
+			seen[track] = true
+		}
+	}
+
+	if req.Locale != nil {
+		if req.ClearLocale {
+			return fmt.Errorf("%w: locale cannot be set and cleared", ErrInvalidOrgSettings)
+		}
+		if !localePattern.MatchString(*req.Locale) {
+			return fmt.Errorf("%w: locale must look like \"en\" or \"en-US\"", ErrInvalidOrgSettings)
+		}
+	}
+
+	return nil
+}
+
+// maxLevel is the highest level a user can reach
+func (s *OrganizationService) maxLevel() int {
+	if len(s.config.LevelUpXPThresholds) == 0 {
+		return 1
+	}
+	return len(s.config.LevelUpXPThresholds)
+}
+
+const orgSettingsColumns = `org_id, agent_unlock_level, enabled_tracks, locale, updated_by, updated_at`
+
+func scanOrgSettings(row rowScanner) (*models.OrgSettings, error) {
+	var settings models.OrgSettings
+	var level sql.NullInt64
+	var tracks []byte
+	var locale sql.NullString
+	var updatedBy uuid.UUID
+	var updatedAt time.Time
+
+	if err := row.Scan(&settings.OrgID, &level, &tracks, &locale, &updatedBy, &updatedAt); err != nil {
+		return nil, err
+	}
+	if level.Valid {
+		n := int(level.Int64)
+		settings.AgentUnlockLevel = &n
+	}
+	if tracks != nil {
+		if err := json.Unmarshal(tracks, &settings.EnabledTracks); err != nil {
+			return nil, fmt.Errorf("failed to decode enabled tracks: %w", err)
+		}
+	}
+	if locale.Valid {
+		settings.Locale = &locale.String
+	}
+	settings.UpdatedBy, settings.UpdatedAt = &updatedBy, &updatedAt
+	return &settings, nil
+}
+
+// GetSettings returns an organization's defaults; an organization nobody has configured has none
+func (s *OrganizationService) GetSettings(orgID string) (*models.OrgSettings, error) {
+	settings, err := scanOrgSettings(s.db.QueryRow(`
+		SELECT `+orgSettingsColumns+`
+		FROM organizations
+		WHERE org_id = $1
+	`, orgID))
+	if err == sql.ErrNoRows {
+		return &models.OrgSettings{OrgID: orgID}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization settings: %w", err)
+	}
+	return settings, nil
+}
+
+// UpdateSettings applies a partial update to an organization's defaults, creating its row on
+// first write
+func (s *OrganizationService) UpdateSettings(orgID string, adminID uuid.UUID, req models.UpdateOrgSettingsRequest) (*models.OrgSettings, error) {
+	if err := ValidateOrgSettingsUpdate(req, s.maxLevel()); err != nil {
+		return nil, err
+	}
+
+	var tracks []byte
+	if req.EnabledTracks != nil {
+		tracks, _ = json.Marshal(*req.EnabledTracks)
+	}
+
+	settings, err := scanOrgSettings(s.db.QueryRow(`
+		INSERT INTO organizations (org_id, agent_unlock_level, enabled_tracks, locale, updated_by, updated_at)
+		VALUES ($1, $2, $3::jsonb, $4, $5, $6)
+		ON CONFLICT (org_id) DO UPDATE SET
+			agent_unlock_level = CASE WHEN $7 THEN NULL ELSE COALESCE(EXCLUDED.agent_unlock_level, organizations.agent_unlock_level) END,
+			enabled_tracks = CASE WHEN $8 THEN NULL ELSE COALESCE(EXCLUDED.enabled_tracks, organizations.enabled_tracks) END,
+			locale = CASE WHEN $9 THEN NULL ELSE COALESCE(EXCLUDED.locale, organizations.locale) END,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = EXCLUDED.updated_at
+		RETURNING `+orgSettingsColumns,
+		orgID, req.AgentUnlockLevel, tracks, req.Locale, adminID, s.clock.Now(),
+		req.ClearAgentUnlockLevel, req.ClearEnabledTracks, req.ClearLocale,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update organization settings: %w", err)
+	}
+
+	log.Printf("Admin %s updated the settings of organization %s", adminID, orgID)
+	return settings, nil
+}
+
+const orgEducatorColumns = `org_id, user_id, email, status, invited_by, invited_at, accepted_at`
+
+func scanOrgEducator(row rowScanner) (*models.OrgEducator, error) {
+	var educator models.OrgEducator
+	var email sql.NullString
+	var acceptedAt sql.NullTime
+	err := row.Scan(
+		&educator.OrgID, &educator.UserID, &email, &educator.Status,
+		&educator.InvitedBy, &educator.InvitedAt, &acceptedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if email.Valid {
+		educator.Email = &email.String
+	}
+	if acceptedAt.Valid {
+		educator.AcceptedAt = &acceptedAt.Time
+	}
+	return &educator, nil
+}
+
+// ValidateEducatorStatus checks a status filter for ListEducators; "all" and "" list both
+func ValidateEducatorStatus(status string) error {
+	switch status {
+	case "", "all", OrgEducatorInvited, OrgEducatorActive:
+		return nil
+	}
+	return ErrInvalidEducatorList
+}
+
+// ListEducators returns an organization's educators, oldest invitation first
+func (s *OrganizationService) ListEducators(orgID, status string, limit int) ([]models.OrgEducator, error) {
+	if err := ValidateEducatorStatus(status); err != nil {
+		return nil, err
+	}
+	if status == "all" {
+		status = ""
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+orgEducatorColumns+`
+		FROM org_educators
+		WHERE org_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY invited_at, user_id
+		LIMIT $3
+	`, orgID, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query educators: %w", err)
+	}
+	defer rows.Close()
+
+	educators := []models.OrgEducator{}
+	for rows.Next() {
+		educator, err := scanOrgEducator(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan educator: %w", err)
+		}
+		educators = append(educators, *educator)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read educators: %w", err)
+	}
+
+	return educators, nil
+}
+
+// InviteEducator invites a user to be one of the organization's educators. They stay invited
+// until they accept.
+func (s *OrganizationService) InviteEducator(orgID string, adminID uuid.UUID, req models.InviteEducatorRequest) (*models.OrgEducator, error) {
+	if req.UserID == uuid.Nil {
+		return nil, fmt.Errorf("%w: user_id is required", ErrInvalidEducator)
+	}
+	if req.Email != nil {
+		email := strings.TrimSpace(*req.Email)
+		if email == "" || len(email) > 320 || !strings.Contains(email, "@") {
+			return nil, fmt.Errorf("%w: email is not an email address", ErrInvalidEducator)
+		}
+		req.Email = &email
+	}
+
+	educator, err := scanOrgEducator(s.db.QueryRow(`
+		INSERT INTO org_educators (org_id, user_id, email, status, invited_by, invited_at)
+		VALUES ($1, $2, $3, 'invited', $4, $5)
+		ON CONFLICT (org_id, user_id) DO NOTHING
+		RETURNING `+orgEducatorColumns,
+		orgID, req.UserID, req.Email, adminID, s.clock.Now(),
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrEducatorExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to invite educator: %w", err)
+	}
+
+	log.Printf("Admin %s invited %s to be an educator of organization %s", adminID, req.UserID, orgID)
+	return educator, nil
+}
+
+// RemoveEducator removes an educator, or withdraws their invitation
+func (s *OrganizationService) RemoveEducator(orgID string, adminID, userID uuid.UUID) error {
+	result, err := s.db.Exec(`DELETE FROM org_educators WHERE org_id = $1 AND user_id = $2`, orgID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove educator: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrEducatorNotFound
+	}
+
+	log.Printf("Admin %s removed educator %s from organization %s", adminID, userID, orgID)
+	return nil
+}
+
+// AcceptInvitation makes an invited user an active educator of the organization. Accepting again
+// changes nothing.
+func (s *OrganizationService) AcceptInvitation(orgID string, userID uuid.UUID) (*models.OrgEducator, error) {
+	educator, err := scanOrgEducator(s.db.QueryRow(`
+		UPDATE org_educators
+		SET status = 'active', accepted_at = COALESCE(accepted_at, $3)
+		WHERE org_id = $1 AND user_id = $2
+		RETURNING `+orgEducatorColumns,
+		orgID, userID, s.clock.Now(),
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+	return educator, nil
+}
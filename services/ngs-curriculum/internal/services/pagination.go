@@ -0,0 +1,33 @@
+package services
+
+import (
+	"fmt"
+
+	"noble-ngs-curriculum/internal/api"
+)
+
+// pageSQL returns the conditions selecting page from a list ordered by timeColumn DESC, then
+// idColumn, each starting with AND, and its LIMIT clause, or "" for an unbounded page. Their
+// arguments are appended to args, numbered after those already there.
+func pageSQL(page api.Page, timeColumn, idColumn string, args []interface{}) (string, string, []interface{}) {
+	var where string
+	if page.From != nil {
+		args = append(args, *page.From)
+		where += fmt.Sprintf(" AND %s >= $%d", timeColumn, len(args))
+	}
+	if page.To != nil {
+		args = append(args, *page.To)
+		where += fmt.Sprintf(" AND %s < $%d", timeColumn, len(args))
+	}
+	if page.After != nil {
+		args = append(args, page.After.At, page.After.ID)
+		where += fmt.Sprintf(" AND (%[1]s < $%[3]d OR (%[1]s = $%[3]d AND %[2]s > $%[4]d))",
+			timeColumn, idColumn, len(args)-1, len(args))
+	}
+	var limit string
+	if page.Limit > 0 {
+		args = append(args, page.Limit)
+		limit = fmt.Sprintf("LIMIT $%d", len(args))
+	}
+	return where, limit, args
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ArchiveSchema holds detached partitions
+const ArchiveSchema = "ngs_archive"
+
+var (
+	partitionActions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_partition_actions_total",
+			Help: "Partitions created or archived by the partition maintenance job.",
+		},
+		[]string{"table", "action"},
+	)
+
+	partitionErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_partition_maintenance_errors_total",
+			Help: "Failed partition maintenance steps.",
+		},
+		[]string{"table"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(partitionActions, partitionErrors)
+}
+
+// partitionedTable is a table range-partitioned by month on column
+type partitionedTable struct {
+	name   string
+	column string
+	// archiveMonths detaches partitions this many months before the current one; 0 never does
+	archiveMonths int
+	// beforeArchive runs in the detaching transaction, before the partition leaves the table
+	beforeArchive func(tx *sql.Tx, from, to time.Time) error
+}
+
+// MonthStart returns the first instant of t's month in UTC
+func MonthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// PartitionName returns the name of table's partition for the month containing month
+func PartitionName(table string, month time.Time) string {
+	month = MonthStart(month)
+	return fmt.Sprintf("%s_p%04d_%02d", table, month.Year(), int(month.Month()))
+}
+
+// ParsePartitionMonth returns the month of a partition named by PartitionName. The default
+// partition and unrelated names report false.
+func ParsePartitionMonth(table, partition string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(partition, table+"_p")
+	if !ok || len(suffix) != 7 || suffix[4] != '_' {
+		return time.Time{}, false
+	}
+	year, err := strconv.Atoi(suffix[:4])
+	if err != nil {
+		return time.Time{}, false
+	}
+	month, err := strconv.Atoi(suffix[5:])
+	if err != nil || month < 1 || month > 12 {
+		return time.Time{}, false
+	}
+	return time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC), true
+}
+
+// PartitionsToArchive returns the partitions entirely older than keepMonths before now's month,
+// oldest first
+func PartitionsToArchive(table string, partitions []string, now time.Time, keepMonths int) []string {
+	if keepMonths <= 0 {
+		return nil
+	}
+	cutoff := MonthStart(now).AddDate(0, -keepMonths, 0)
+
+	var old []string
+	for _, p := range partitions {
+		if month, ok := ParsePartitionMonth(table, p); ok && month.Before(cutoff) {
+			old = append(old, p)
+		}
+	}
+	sort.Strings(old)
+	return old
+}
+
+type PartitionService struct {
+	db       *database.DB
+	clock    Clock
+	interval time.Duration
+	premake  int
+	tables   []partitionedTable
+	running  sync.WaitGroup
+}
+
+func NewPartitionService(db *database.DB, cfg *config.Config, clock Clock) *PartitionService {
+	return &PartitionService{
+		db:       db,
+		clock:    clock,
+		interval: time.Duration(cfg.PartitionMaintenanceMinutes) * time.Minute,
+		premake:  cfg.PartitionPremakeMonths,
+		tables: []partitionedTable{
+			{name: "xp_events", column: "created_at", archiveMonths: cfg.XPEventsArchiveMonths, beforeArchive: rollupXPEventRange},
+			// Submissions decide whether a challenge was ever passed, so they are never archived
+			{name: "challenge_submissions", column: "submitted_at"},
+		},
+	}
+}
+
+// rollupXPEventRange compacts every remaining event of an archived month so the XP curve
+// simulation still counts it from xp_event_rollups
+func rollupXPEventRange(tx *sql.Tx, from, to time.Time) error {
+	var n int64
+	err := tx.QueryRow(rollupXPEventsSQL(`
+			SELECT id, created_at FROM xp_events
+			WHERE compacted_at IS NULL AND created_at >= $1 AND created_at < $2`), from, to).Scan(&n)
+	if err != nil {
+		return fmt.Errorf("failed to roll up XP events: %w", err)
+	}
+	return nil
+}
+
+// Start runs partition maintenance now and then on the configured interval until ctx is cancelled
+func (s *PartitionService) Start(ctx context.Context) {
+	if s.interval <= 0 {
+		log.Println("Partition maintenance disabled")
+		return
+	}
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			s.Maintain(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Printf("Partition maintenance running every %s", s.interval)
+}
+
+// Wait blocks until the maintenance loop started by Start has stopped
+func (s *PartitionService) Wait() {
+	s.running.Wait()
+}
+
+// Maintain creates the current and upcoming monthly partitions of each table and archives
+// partitions past their table's retention. Failures are logged and retried on the next run.
+func (s *PartitionService) Maintain(ctx context.Context) {
+	now := s.clock.Now()
+	for _, t := range s.tables {
+		for i := 0; i <= s.premake && ctx.Err() == nil; i++ {
+			month := MonthStart(now).AddDate(0, i, 0)
+			if err := s.ensurePartition(t, month); err != nil {
+				partitionErrors.WithLabelValues(t.name).Inc()
+				log.Printf("Failed to create partition %s: %v", PartitionName(t.name, month), err)
+			}
+		}
+
+		if t.archiveMonths <= 0 || ctx.Err() != nil {
+			continue
+		}
+		partitions, err := s.listPartitions(t.name)
+		if err != nil {
+			partitionErrors.WithLabelValues(t.name).Inc()
+			log.Printf("Failed to list partitions of %s: %v", t.name, err)
+			continue
+		}
+		for _, p := range PartitionsToArchive(t.name, partitions, now, t.archiveMonths) {
+			if ctx.Err() != nil {
+				break
+			}
+			if err := s.archivePartition(t, p); err != nil {
+				partitionErrors.WithLabelValues(t.name).Inc()
+				log.Printf("Failed to archive partition %s: %v", p, err)
+			}
+		}
+	}
+}
+
+// lockTable takes a transaction-scoped advisory lock so only one instance maintains a table at a
+// time, reporting false when another instance holds it
+func lockTable(tx *sql.Tx, table string) (bool, error) {
+	return database.TryXactLock(tx, "ngs_partitions:"+table)
+}
+
+// ensurePartition creates the month's partition if it is missing. Rows the default partition
+// already holds for that month are moved into it first, since attaching would fail otherwise.
+func (s *PartitionService) ensurePartition(t partitionedTable, month time.Time) error {
+	name := PartitionName(t.name, month)
+
+	var exists bool
+	if err := s.db.QueryRow(`SELECT to_regclass($1) IS NOT NULL`, "public."+name).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check partition: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if locked, err := lockTable(tx, t.name); err != nil || !locked {
+		return err
+	}
+
+	from, to := month, month.AddDate(0, 1, 0)
+	table, partition, def := pq.QuoteIdentifier(t.name), pq.QuoteIdentifier(name), pq.QuoteIdentifier(t.name+"_default")
+	column := pq.QuoteIdentifier(t.column)
+	statements := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (LIKE %s INCLUDING DEFAULTS INCLUDING CONSTRAINTS)`, partition, table),
+		fmt.Sprintf(`WITH moved AS (DELETE FROM %s WHERE %s >= %s AND %s < %s RETURNING *) INSERT INTO %s SELECT * FROM moved`,
+			def, column, pq.QuoteLiteral(from.Format("2006-01-02")), column, pq.QuoteLiteral(to.Format("2006-01-02")), partition),
+		fmt.Sprintf(`ALTER TABLE %s ATTACH PARTITION %s FOR VALUES FROM (%s) TO (%s)`,
+			table, partition, pq.QuoteLiteral(from.Format("2006-01-02")), pq.QuoteLiteral(to.Format("2006-01-02"))),
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit partition: %w", err)
+	}
+
+	partitionActions.WithLabelValues(t.name, "created").Inc()
+	log.Printf("Created partition %s", name)
+	return nil
+}
+
+// listPartitions returns the partitions currently attached to table
+func (s *PartitionService) listPartitions(table string) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = to_regclass($1)
+	`, "public."+table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var partitions []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		partitions = append(partitions, name)
+	}
+	return partitions, rows.Err()
+}
+
+// archivePartition detaches a partition and moves it to the archive schema, where it can still
+// be queried directly or dropped once no longer needed
+func (s *PartitionService) archivePartition(t partitionedTable, name string) error {
+	month, ok := ParsePartitionMonth(t.name, name)
+	if !ok {
+		return fmt.Errorf("not a monthly partition: %s", name)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if locked, err := lockTable(tx, t.name); err != nil || !locked {
+		return err
+	}
+
+	if t.beforeArchive != nil {
+		if err := t.beforeArchive(tx, month, month.AddDate(0, 1, 0)); err != nil {
+			return err
+		}
+	}
+
+	partition := pq.QuoteIdentifier(name)
+	if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s DETACH PARTITION %s`, pq.QuoteIdentifier(t.name), partition)); err != nil {
+		return fmt.Errorf("failed to detach: %w", err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s SET SCHEMA %s`, partition, ArchiveSchema)); err != nil {
+		return fmt.Errorf("failed to move to %s: %w", ArchiveSchema, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit archive: %w", err)
+	}
+
+	partitionActions.WithLabelValues(t.name, "archived").Inc()
+	log.Printf("Archived partition %s to %s", name, ArchiveSchema)
+	return nil
+}
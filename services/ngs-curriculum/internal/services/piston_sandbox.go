@@ -0,0 +1,269 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"noble-ngs-curriculum/internal/egress"
+	"noble-ngs-curriculum/internal/models"
+)
+
+// pistonOutputLimit caps the stdout and stderr kept from a case, so a chatty submission cannot
+// bloat its stored results
+const pistonOutputLimit = 4 << 10
+
+// pistonCompileTimeoutMs bounds compiling, which is not part of a case's limits
+const pistonCompileTimeoutMs = 10000
+
+// PistonSandbox runs submissions on a Piston judge (https://github.com/engineer-man/piston), which
+// isolates each run and enforces its time, CPU and memory limits. Every test case is one execute
+// request: the case's input goes to stdin, strings as they are and other values as JSON, and
+// the program's trimmed stdout is compared with the expected value.
+type PistonSandbox struct {
+	url        string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewPistonSandbox creates a sandbox for the Piston API at url, e.g. http://piston:2000
+func NewPistonSandbox(url, apiKey string) *PistonSandbox {
+	return &PistonSandbox{
+		url:        strings.TrimSuffix(url, "/"),
+		apiKey:     apiKey,
+		httpClient: egress.NewClient(egress.DestinationSandbox, 2*time.Minute),
+	}
+}
+
+type pistonFile struct {
+	Content string `json:"content"`
+}
+
+type pistonRequest struct {
+	Language       string       `json:"language"`
+	Version        string       `json:"version"`
+	Files          []pistonFile `json:"files"`
+	Stdin          string       `json:"stdin"`
+	CompileTimeout int          `json:"compile_timeout"`
+	RunTimeout     int          `json:"run_timeout,omitempty"`
+	RunCPUTime     int          `json:"run_cpu_time,omitempty"`
+	RunMemoryLimit int64        `json:"run_memory_limit,omitempty"`
+}
+
+// pistonStage is the outcome of compiling or running. Status is set when the run did not end
+// normally: RE runtime error, SG killed by a signal, TO timed out, OL or EL output limit, XX the
+// judge itself failed. Older judges report no usage.
+type pistonStage struct {
+	Stdout   string  `json:"stdout"`
+	Stderr   string  `json:"stderr"`
+	Output   string  `json:"output"`
+	Code     *int    `json:"code"`
+	Signal   *string `json:"signal"`
+	Status   *string `json:"status"`
+	Message  *string `json:"message"`
+	CPUTime  float64 `json:"cpu_time"`
+	WallTime float64 `json:"wall_time"`
+	Memory   int64   `json:"memory"` // bytes
+}
+
+func (st *pistonStage) status() string {
+	if st == nil || st.Status == nil {
+		return ""
+	}
+	return *st.Status
+}
+
+func (st *pistonStage) failed() bool {
+	return st != nil && (st.status() != "" || (st.Code != nil && *st.Code != 0) || st.Signal != nil)
+}
+
+type pistonResponse struct {
+	Run     pistonStage  `json:"run"`
+	Compile *pistonStage `json:"compile"`
+}
+
+func (p *PistonSandbox) Run(ctx context.Context, language, code string, tests []models.TestCase, limits models.ResourceLimits) (*models.TestResults, error) {
+	results := &models.TestResults{Tests: make([]models.TestCaseResult, 0, len(tests))}
+	for i, tc := range tests {
+		resp, err := p.execute(ctx, pistonRequest{
+			Language:       language,
+			Version:        "*",
+			Files:          []pistonFile{{Content: code}},
+			Stdin:          pistonStdin(tc.Input),
+			CompileTimeout: pistonCompileTimeoutMs,
+			RunTimeout:     limits.TimeLimitMs,
+			RunCPUTime:     limits.CPULimitMs,
+			RunMemoryLimit: int64(limits.MemoryLimitMB) << 20,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// Code that does not compile fails every case the same way, so stop here
+		if resp.Compile.failed() {
+			output := truncateOutput(firstNonEmpty(resp.Compile.Stderr, resp.Compile.Output))
+			for _, tc := range tests[i:] {
+				results.Tests = append(results.Tests, models.TestCaseResult{
+					Name:     tc.Name,
+					Status:   models.TestError,
+					Hidden:   tc.Hidden,
+					Input:    tc.Input,
+					Expected: tc.Expected,
+					Output:   output,
+				})
+			}
+			return results, nil
+		}
+
+		result, err := pistonCaseResult(tc, &resp.Run, limits)
+		if err != nil {
+			return nil, err
+		}
+		results.Tests = append(results.Tests, result)
+	}
+	return results, nil
+}
+
+func (p *PistonSandbox) execute(ctx context.Context, req pistonRequest) (*pistonResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.url+"/api/v2/execute", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", p.apiKey)
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("piston returned status %d: %s", resp.StatusCode, string(data))
+	}
+
+	var result pistonResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &result, nil
+}
+
+// pistonCaseResult grades one case's run. A failure of the judge itself is an error, as the
+// submission was not graded.
+func pistonCaseResult(tc models.TestCase, run *pistonStage, limits models.ResourceLimits) (models.TestCaseResult, error) {
+	result := models.TestCaseResult{
+		Name:      tc.Name,
+		Hidden:    tc.Hidden,
+		Input:     tc.Input,
+		Expected:  tc.Expected,
+		Output:    truncateOutput(run.Stderr),
+		RuntimeMs: run.WallTime,
+		CPUMs:     run.CPUTime,
+		MemoryKB:  run.Memory / 1024,
+	}
+
+	switch status := run.status(); {
+	case status == "XX":
+		message := ""
+		if run.Message != nil {
+			message = *run.Message
+		}
+		return result, fmt.Errorf("piston failed to run the submission: %s", message)
+	case status == "TO":
+		result.Status = models.TestTimeout
+	case run.Signal != nil && *run.Signal == "SIGKILL" && limits.MemoryLimitMB > 0 && run.Memory >= int64(limits.MemoryLimitMB)<<20:
+		result.Status = models.TestMemoryExceeded
+	case status == "OL" || status == "EL":
+		result.Status = models.TestError
+		result.Output = "Your code printed more output than is allowed"
+	case run.failed():
+		result.Status = models.TestError
+		if result.Output == "" && run.Message != nil {
+			result.Output = *run.Message
+		}
+	default:
+		actual, matched := matchOutput(tc.Expected, run.Stdout)
+		result.Actual = actual
+		result.Status = models.TestFailed
+		if matched {
+			result.Status = models.TestPassed
+		}
+	}
+	return result, nil
+}
+
+// pistonStdin is a case's input as the program reads it: a string as it is, anything else as JSON
+func pistonStdin(input json.RawMessage) string {
+	if len(input) == 0 || string(input) == "null" {
+		return ""
+	}
+	var text string
+	if err := json.Unmarshal(input, &text); err == nil {
+		return text
+	}
+	return string(input)
+}
+
+// matchOutput compares a program's stdout with the expected value. Trimmed stdout that is JSON
+// is compared as JSON; a string is also matched by the plain text. Actual is the stdout as JSON,
+// quoted when it is not JSON itself. A case without an expected value passes on any output.
+func matchOutput(expected json.RawMessage, stdout string) (json.RawMessage, bool) {
+	text := strings.TrimSpace(truncateOutput(stdout))
+	var actual json.RawMessage
+	var got interface{}
+	if text != "" && json.Unmarshal([]byte(text), &got) == nil {
+		var compact bytes.Buffer
+		_ = json.Compact(&compact, []byte(text))
+		actual = compact.Bytes()
+	} else {
+		actual, _ = json.Marshal(text)
+		got = text
+	}
+
+	if len(expected) == 0 {
+		return actual, true
+	}
+	var want interface{}
+	if err := json.Unmarshal(expected, &want); err != nil {
+		return actual, false
+	}
+	if wantText, ok := want.(string); ok && wantText == text {
+		return actual, true
+	}
+	return actual, reflect.DeepEqual(want, got)
+}
+
+// truncateOutput cuts output to pistonOutputLimit bytes
+func truncateOutput(output string) string {
+	if len(output) <= pistonOutputLimit {
+		return output
+	}
+	return strings.ToValidUTF8(output[:pistonOutputLimit], "") + "\n... (output truncated)"
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
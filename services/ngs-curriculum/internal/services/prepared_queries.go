@@ -0,0 +1,211 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Hot-path queries (progress reads, lesson lists and XP inserts) run as prepared statements
+// through database.DB.Stmt. Each column list sits next to the one function that scans it.
+// Nullable lesson columns scan into sql.Null types, so rows inserted outside the service with
+// NULLs (content imports, manual fixes) cannot break a Scan. The statements are hand-written
+// rather than generated by sqlc, since the lesson queries are composed from the SQL fragments
+// below.
+
+const progressColumns = `id, user_id, current_level, total_xp, agent_creation_unlocked, created_at, updated_at`
+
+const (
+	selectProgressSQL = `SELECT ` + progressColumns + ` FROM user_progress WHERE user_id = $1`
+
+	selectProgressForUpdateSQL = selectProgressSQL + ` FOR UPDATE`
+
+	insertInitialProgressSQL = `
+		INSERT INTO user_progress (user_id, current_level, total_xp, agent_creation_unlocked)
+		VALUES ($1, 1, 0, false)
+		RETURNING ` + progressColumns
+
+	updateProgressSQL = `
+		UPDATE user_progress
+		SET total_xp = $1, current_level = $2, agent_creation_unlocked = $3, updated_at = NOW()
+		WHERE user_id = $4`
+
+	insertXPEventSQL = `
+		INSERT INTO xp_events (user_id, source, xp_awarded, metadata)
+		VALUES ($1, $2, $3, $4)`
+)
+
+// lessonLockedSQL is true when the lessons row qualified by table is above the current level of
+// the user bound to userParam. Users without progress are at level 1.
+func lessonLockedSQL(table, userParam string) string {
+	return fmt.Sprintf(`%s.level_id > COALESCE((
+			SELECT up.current_level FROM user_progress up WHERE up.user_id = %s
+		), 1)`, table, userParam)
+}
+
+// lessonWithCompletionColumns selects a lessons row aliased l, with the cohort lesson order,
+// completion and level lock of the user bound to $1 from lesson_completions aliased lc
+var lessonWithCompletionColumns = `
+	l.id, l.level_id, l.title, l.description, ` + cohortLessonOrderSQL("l", "$1") + `, l.lesson_type,
+	l.content_markdown, l.core_lesson, l.human_practice,
+	l.reflection_prompt, l.agent_unlock, l.xp_reward,
+	l.estimated_minutes, l.prerequisites, l.metadata, l.is_required,
+	l.created_at, l.updated_at, l.completion_criteria, l.min_age_band,
+	lc.id IS NOT NULL, lc.completed_at, lc.score, ` + lessonLockedSQL("l", "$1")
+
+var (
+	selectLessonsByLevelSQL = `
+		SELECT ` + lessonWithCompletionColumns + `
+		FROM lessons l
+		LEFT JOIN lesson_completions lc ON l.id = lc.lesson_id AND lc.user_id = $1
+		WHERE l.level_id = $2 AND ` + lessonVisibleSQL("l", "$1") + `
+		ORDER BY ` + cohortLessonOrderSQL("l", "$1") + ` ASC, l.lesson_order ASC, l.id ASC`
+
+	selectLessonSQL = `
+		SELECT ` + lessonWithCompletionColumns + `
+		FROM lessons l
+		LEFT JOIN lesson_completions lc ON l.id = lc.lesson_id AND lc.user_id = $1
+		WHERE l.id = $2 AND ` + lessonVisibleSQL("l", "$1")
+)
+
+// preparedStmt returns the prepared statement for query, bound to tx when one is given
+func preparedStmt(db *database.DB, tx *sql.Tx, query string) (*sql.Stmt, error) {
+	stmt, err := db.Stmt(query)
+	if err != nil {
+		return nil, err
+	}
+	if tx != nil {
+		return tx.Stmt(stmt), nil
+	}
+	return stmt, nil
+}
+
+func scanProgress(row rowScanner) (models.UserProgress, error) {
+	var p models.UserProgress
+	err := row.Scan(&p.ID, &p.UserID, &p.CurrentLevel, &p.TotalXP, &p.AgentCreationUnlocked, &p.CreatedAt, &p.UpdatedAt)
+	return p, err
+}
+
+// loadProgress reads a user's progress, locking the row when tx is given. A missing row is
+// returned as sql.ErrNoRows.
+func loadProgress(db *database.DB, tx *sql.Tx, userID uuid.UUID) (models.UserProgress, error) {
+	query := selectProgressSQL
+	if tx != nil {
+		query = selectProgressForUpdateSQL
+	}
+	stmt, err := preparedStmt(db, tx, query)
+	if err != nil {
+		return models.UserProgress{}, err
+	}
+	return scanProgress(stmt.QueryRow(userID))
+}
+
+// insertInitialProgress creates level 1 progress for a user
+func insertInitialProgress(db *database.DB, userID uuid.UUID) (models.UserProgress, error) {
+	stmt, err := preparedStmt(db, nil, insertInitialProgressSQL)
+	if err != nil {
+		return models.UserProgress{}, err
+	}
+	return scanProgress(stmt.QueryRow(userID))
+}
+
+// updateProgress stores a user's new XP total, level and agent unlock within tx
+func updateProgress(db *database.DB, tx *sql.Tx, userID uuid.UUID, totalXP, level int, agentUnlocked bool) error {
+	stmt, err := preparedStmt(db, tx, updateProgressSQL)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(totalXP, level, agentUnlocked, userID)
+	return err
+}
+
+// recordXPEvent inserts an XP event within tx. metadata is stored as given, typically
+// marshaled JSON.
+func recordXPEvent(db *database.DB, tx *sql.Tx, userID uuid.UUID, source string, amount int, metadata []byte) error {
+	stmt, err := preparedStmt(db, tx, insertXPEventSQL)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(userID, source, amount, metadata)
+	return err
+}
+
+// scanLessonWithCompletion reads lessonWithCompletionColumns. NULL text, numbers and JSON become
+// zero values, a NULL is_required means required and NULL completion criteria mean none.
+func scanLessonWithCompletion(row rowScanner) (*models.LessonWithCompletion, error) {
+	var l models.LessonWithCompletion
+	var description, content, coreLesson, humanPractice, reflectionPrompt, agentUnlock sql.NullString
+	var xpReward, estimatedMinutes sql.NullInt64
+	var prerequisites, metadata, completionCriteria []byte
+	var isRequired sql.NullBool
+	var createdAt, updatedAt, completedAt sql.NullTime
+	var score sql.NullInt64
+	err := row.Scan(
+		&l.ID, &l.LevelID, &l.Title, &description, &l.LessonOrder, &l.LessonType,
+		&content, &coreLesson, &humanPractice,
+		&reflectionPrompt, &agentUnlock, &xpReward,
+		&estimatedMinutes, &prerequisites, &metadata, &isRequired,
+		&createdAt, &updatedAt, &completionCriteria, &l.MinAgeBand,
+		&l.Completed, &completedAt, &score, &l.Locked,
+	)
+	if err != nil {
+		return nil, err
+	}
+	l.Description, l.ContentMarkdown, l.CoreLesson = description.String, content.String, coreLesson.String
+	l.HumanPractice, l.ReflectionPrompt, l.AgentUnlock = humanPractice.String, reflectionPrompt.String, agentUnlock.String
+	l.XPReward, l.EstimatedMinutes = int(xpReward.Int64), int(estimatedMinutes.Int64)
+	l.IsRequired = !isRequired.Valid || isRequired.Bool
+	l.CreatedAt, l.UpdatedAt = createdAt.Time, updatedAt.Time
+	l.Prerequisites, l.Metadata = prerequisites, metadata
+	l.CompletionCriteria = completionCriteria
+	if completionCriteria == nil {
+		l.CompletionCriteria = json.RawMessage(`{}`)
+	}
+	if completedAt.Valid {
+		l.CompletedAt = completedAt.Time
+	}
+	if score.Valid {
+		l.UserScore = int(score.Int64)
+	}
+	return &l, nil
+}
+
+// queryLessonsByLevel lists the level's lessons visible to the user, in their cohort sequence
+func queryLessonsByLevel(db *database.DB, levelID int, userID uuid.UUID) ([]models.LessonWithCompletion, error) {
+	stmt, err := preparedStmt(db, nil, selectLessonsByLevelSQL)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.Query(userID, levelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lessons: %w", err)
+	}
+	defer rows.Close()
+
+	lessons := []models.LessonWithCompletion{}
+	for rows.Next() {
+		l, err := scanLessonWithCompletion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan lesson: %w", err)
+		}
+		lessons = append(lessons, *l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read lessons: %w", err)
+	}
+	return lessons, nil
+}
+
+// queryLesson returns one lesson visible to the user, or sql.ErrNoRows
+func queryLesson(db *database.DB, lessonID uuid.UUID, userID uuid.UUID) (*models.LessonWithCompletion, error) {
+	stmt, err := preparedStmt(db, nil, selectLessonSQL)
+	if err != nil {
+		return nil, err
+	}
+	return scanLessonWithCompletion(stmt.QueryRow(userID, lessonID))
+}
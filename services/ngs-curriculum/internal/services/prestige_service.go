@@ -0,0 +1,222 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/domain/xp"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var prestigeConfig atomic.Pointer[xp.Prestige]
+
+var (
+	prestigeMilestones = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ngs_prestige_milestones_total",
+		Help: "Prestige milestones reached past the top of the XP curve.",
+	})
+	masteryStarsEarned = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_mastery_stars_total",
+			Help: "Mastery stars earned, by track.",
+		},
+		[]string{"track"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(prestigeMilestones, masteryStarsEarned)
+}
+
+// ConfigurePrestige has every XP award record prestige_milestone achievements for the
+// milestones it passes. Without it no milestones are recorded or shown.
+func ConfigurePrestige(prestige xp.Prestige) {
+	prestigeConfig.Store(&prestige)
+}
+
+func currentPrestige() xp.Prestige {
+	if prestige := prestigeConfig.Load(); prestige != nil {
+		return *prestige
+	}
+	return xp.Prestige{}
+}
+
+// milestoneAchievements returns a prestige_milestone achievement for each milestone passed by
+// moving from beforeXP to afterXP
+func milestoneAchievements(userID uuid.UUID, beforeXP, afterXP int, at time.Time) []models.Achievement {
+	prestige := currentPrestige()
+	var achievements []models.Achievement
+	for milestone := prestige.Milestones(beforeXP) + 1; milestone <= prestige.Milestones(afterXP); milestone++ {
+		data, _ := json.Marshal(map[string]interface{}{
+			"milestone": milestone,
+			"xp":        afterXP,
+		})
+		achievements = append(achievements, models.Achievement{
+			UserID:          userID,
+			AchievementType: "prestige_milestone",
+			AchievementData: data,
+			UnlockedAt:      at,
+		})
+		prestigeMilestones.Inc()
+	}
+	return achievements
+}
+
+// recordDirectMilestones records the milestones passed by an award of amount that was already
+// added to the user's total in tx, for writers that update user_progress directly
+func recordDirectMilestones(tx *sql.Tx, userID uuid.UUID, amount int) error {
+	if !currentPrestige().Enabled() || amount <= 0 {
+		return nil
+	}
+	var totalXP int
+	err := tx.QueryRow(`SELECT total_xp FROM user_progress WHERE user_id = $1`, userID).Scan(&totalXP)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read total XP: %w", err)
+	}
+	return recordAchievements(tx, userID, milestoneAchievements(userID, totalXP-amount, totalXP, time.Now()))
+}
+
+// masteryTrack returns the track key for a lesson_order, if the order is a track's
+func masteryTrack(lessonOrder int) (string, bool) {
+	for track, order := range LessonTracks {
+		if order == lessonOrder {
+			return track, true
+		}
+	}
+	return "", false
+}
+
+// awardMasteryStar gives the user the star for a completed lesson's track and stage, and
+// records a mastery_star achievement, once every lesson of the track in the stage that the user
+// can see is completed. It runs in the completion's transaction.
+func awardMasteryStar(tx *sql.Tx, userID, lessonID uuid.UUID) error {
+	var level, order int
+	err := tx.QueryRow(`SELECT level_id, lesson_order FROM lessons WHERE id = $1`, lessonID).Scan(&level, &order)
+	if err != nil {
+		return fmt.Errorf("failed to read lesson track: %w", err)
+	}
+	track, ok := masteryTrack(order)
+	if !ok {
+		return nil
+	}
+	stage := stageOfLevel(level)
+	stageKey := strings.ToLower(stage.Name)
+
+	result, err := tx.Exec(`
+		INSERT INTO mastery_stars (user_id, track, stage)
+		SELECT $1, $2, $3
+		WHERE NOT EXISTS (
+			SELECT 1 FROM lessons l
+			WHERE l.lesson_order = $4 AND l.level_id BETWEEN $5 AND $6
+				AND `+lessonVisibleSQL("l", "$1")+`
+				AND NOT EXISTS (SELECT 1 FROM lesson_completions lc WHERE lc.user_id = $1 AND lc.lesson_id = l.id)
+		)
+		ON CONFLICT (user_id, track, stage) DO NOTHING
+	`, userID, track, stageKey, order, stage.FirstLevel, stage.LastLevel)
+	if err != nil {
+		return fmt.Errorf("failed to award mastery star: %w", err)
+	}
+	if earned, _ := result.RowsAffected(); earned == 0 {
+		return nil
+	}
+
+	masteryStarsEarned.WithLabelValues(track).Inc()
+	data, _ := json.Marshal(map[string]interface{}{
+		"track": track,
+		"stage": stageKey,
+	})
+	return recordAchievements(tx, userID, []models.Achievement{{
+		UserID:          userID,
+		AchievementType: "mastery_star",
+		AchievementData: data,
+	}})
+}
+
+// PrestigeService reports progression past the top level
+type PrestigeService struct {
+	db     *database.DB
+	config *config.Config
+}
+
+func NewPrestigeService(db *database.DB, cfg *config.Config) *PrestigeService {
+	return &PrestigeService{
+		db:     db,
+		config: cfg,
+	}
+}
+
+// GetPrestige returns a user's milestones and their stars in every track. Users without
+// progress have none.
+func (s *PrestigeService) GetPrestige(userID uuid.UUID) (*models.PrestigeStatus, error) {
+	status := &models.PrestigeStatus{UserID: userID}
+	err := s.db.QueryRow(`SELECT total_xp FROM user_progress WHERE user_id = $1`, userID).Scan(&status.TotalXP)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get progress: %w", err)
+	}
+	prestige := currentPrestige()
+	status.Milestones = prestige.Milestones(status.TotalXP)
+	status.MilestoneXP = prestige.MilestoneXP
+	status.MilestonesStartXP = prestige.StartXP
+	status.XPToNextMilestone = prestige.XPToNextMilestone(status.TotalXP)
+
+	rows, err := s.db.Query(`
+		SELECT track, stage, earned_at FROM mastery_stars
+		WHERE user_id = $1
+		ORDER BY earned_at, track, stage
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mastery stars: %w", err)
+	}
+	defer rows.Close()
+	stars := make(map[string][]models.MasteryStar)
+	for rows.Next() {
+		var track string
+		var star models.MasteryStar
+		if err := rows.Scan(&track, &star.Stage, &star.EarnedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan mastery star: %w", err)
+		}
+		stars[track] = append(stars[track], star)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read mastery stars: %w", err)
+	}
+
+	status.Tracks = masteryTracks(stars)
+	for _, track := range status.Tracks {
+		status.MasteryStars += track.Stars
+	}
+	return status, nil
+}
+
+// masteryTracks lists every track in lesson order with the stars earned in it
+func masteryTracks(stars map[string][]models.MasteryStar) []models.TrackMastery {
+	tracks := make([]models.TrackMastery, 0, len(LessonTracks))
+	for track := range LessonTracks {
+		earned := stars[track]
+		if earned == nil {
+			earned = []models.MasteryStar{}
+		}
+		tracks = append(tracks, models.TrackMastery{
+			Track:    track,
+			Stars:    len(earned),
+			MaxStars: len(curriculumStages),
+			Stages:   earned,
+		})
+	}
+	sort.Slice(tracks, func(i, j int) bool {
+		return LessonTracks[tracks[i].Track] < LessonTracks[tracks[j].Track]
+	})
+	return tracks
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Progress modes (PROGRESS_MODE)
+const (
+	// ProgressModeDirect lets each XP writer update user_progress its own way
+	ProgressModeDirect = "direct"
+	// ProgressModeProjection keeps user_progress a projection of the XP ledger
+	ProgressModeProjection = "projection"
+)
+
+// replayBatchSize is how many users the replay rebuilds per transaction
+const replayBatchSize = 500
+
+var (
+	ErrProjectionDisabled = errors.New("progress projection is not enabled (PROGRESS_MODE=projection)")
+	ErrProgressReplayBusy = errors.New("a progress replay or XP reconciliation is already running")
+)
+
+// ProgressProjection maintains user_progress as a projection of the XP ledger. Every XP event
+// is applied the same way, so replaying the ledger with LevelPolicy.Replay reproduces each user's total,
+// level and agent unlock exactly.
+type ProgressProjection struct {
+	db     *database.DB
+	config *config.Config
+	clock  Clock
+}
+
+func NewProgressProjection(db *database.DB, cfg *config.Config, clock Clock) *ProgressProjection {
+	return &ProgressProjection{
+		db:     db,
+		config: cfg,
+		clock:  clock,
+	}
+}
+
+// SetProgressProjection has lesson XP applied through projection instead of updating
+// user_progress directly
+func (s *LessonService) SetProgressProjection(projection *ProgressProjection) {
+	s.projection = projection
+}
+
+// SetProgressProjection has challenge XP applied through projection instead of updating
+// user_progress directly
+func (s *ChallengeService) SetProgressProjection(projection *ProgressProjection) {
+	s.projection = projection
+}
+
+// Apply projects an XP event recorded in tx onto the user's progress, creating it if needed, and
+// records any level-up and agent unlock achievements
+func (p *ProgressProjection) Apply(tx *sql.Tx, userID uuid.UUID, amount int) error {
+	if _, err := tx.Exec(`
+		INSERT INTO user_progress (user_id, current_level, total_xp, agent_creation_unlocked)
+		VALUES ($1, 1, 0, false)
+		ON CONFLICT (user_id) DO NOTHING
+	`, userID); err != nil {
+		return fmt.Errorf("failed to create progress: %w", err)
+	}
+	current, err := loadProgress(p.db, tx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get progress: %w", err)
+	}
+
+	updated := current
+	updated.TotalXP = current.TotalXP + amount
+	policy := levelPolicy(p.config)
+	updated.CurrentLevel = policy.Level(p.config.LevelUpXPThresholds, current.CurrentLevel, updated.TotalXP)
+	updated.AgentCreationUnlocked = policy.AgentUnlocked(current.AgentCreationUnlocked, updated.CurrentLevel, p.config.AgentUnlockLevel)
+	updated.UpdatedAt = p.clock.Now()
+
+	if err := updateProgress(p.db, tx, userID, updated.TotalXP, updated.CurrentLevel, updated.AgentCreationUnlocked); err != nil {
+		return fmt.Errorf("failed to update progress: %w", err)
+	}
+	return recordAchievements(tx, userID, progressAchievements(current, updated))
+}
+
+// recordAchievements inserts achievements for userID within tx
+func recordAchievements(tx *sql.Tx, userID uuid.UUID, achievements []models.Achievement) error {
+	for _, achievement := range achievements {
+		if _, err := tx.Exec(`
+			INSERT INTO achievements (user_id, achievement_type, achievement_data)
+			VALUES ($1, $2, $3)
+		`, userID, achievement.AchievementType, []byte(achievement.AchievementData)); err != nil {
+			return fmt.Errorf("failed to record %s achievement: %w", achievement.AchievementType, err)
+		}
+	}
+	return nil
+}
+
+// Replay rebuilds user_progress from the XP ledger, for one user or, when userID is nil, for
+// everyone with progress or XP. Users without any XP go back to level 1. A dry run reports the
+// changes without keeping them. It shares a lock with XP reconciliation so the two never
+// overlap, and outside projection mode it only runs dry.
+func (p *ProgressProjection) Replay(ctx context.Context, userID *uuid.UUID, dryRun bool) (*models.ProgressReplay, error) {
+	if !dryRun && p.config.ProgressMode != ProgressModeProjection {
+		return nil, ErrProjectionDisabled
+	}
+
+	lock, err := p.db.TryLock(ctx, "ngs_xp_reconciliation")
+	if err != nil {
+		return nil, err
+	}
+	if lock == nil {
+		return nil, ErrProgressReplayBusy
+	}
+	defer lock.Release()
+
+	start := time.Now()
+	replay := &models.ProgressReplay{StartedAt: p.clock.Now(), DryRun: dryRun, Changes: []models.ProgressReplayChange{}}
+	after := uuid.Nil
+	for {
+		if err := ctx.Err(); err != nil {
+			return replay, err
+		}
+		if err := lock.Context().Err(); err != nil {
+			return replay, fmt.Errorf("lost replay lock: %w", err)
+		}
+		n, last, err := p.replayBatch(replay, userID, after)
+		if err != nil {
+			return replay, err
+		}
+		if n < replayBatchSize {
+			break
+		}
+		after = last
+	}
+	replay.DurationMs = time.Since(start).Milliseconds()
+
+	log.Printf("Progress replay rebuilt %d users, %d changed (dry run: %t)", replay.Users, replay.Changed, dryRun)
+	return replay, nil
+}
+
+// replayBatch rebuilds the next batch of users after the given user ID. Their progress rows are
+// created and locked before the ledger is read, so XP awarded meanwhile is applied on top of
+// the replayed total.
+func (p *ProgressProjection) replayBatch(replay *models.ProgressReplay, userID *uuid.UUID, after uuid.UUID) (int, uuid.UUID, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return 0, after, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ids, err := uuidColumn(tx.Query(`
+		SELECT user_id FROM (
+			SELECT user_id FROM user_progress
+			UNION SELECT user_id FROM xp_events
+			UNION SELECT user_id FROM xp_event_rollups
+		) u
+		WHERE user_id > $1 AND ($2::uuid IS NULL OR user_id = $2)
+		ORDER BY user_id
+		LIMIT $3
+	`, after, userID, replayBatchSize))
+	if err != nil {
+		return 0, after, fmt.Errorf("failed to list users: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, after, nil
+	}
+	idStrings := make([]string, len(ids))
+	for i, id := range ids {
+		idStrings[i] = id.String()
+	}
+	batch := pq.Array(idStrings)
+
+	if _, err := tx.Exec(`
+		INSERT INTO user_progress (user_id, current_level, total_xp, agent_creation_unlocked)
+		SELECT id, 1, 0, false FROM UNNEST($1::uuid[]) AS id
+		ON CONFLICT (user_id) DO NOTHING
+	`, batch); err != nil {
+		return 0, after, fmt.Errorf("failed to create progress: %w", err)
+	}
+
+	current := make(map[uuid.UUID]models.UserProgress, len(ids))
+	rows, err := tx.Query(`
+		SELECT user_id, total_xp, current_level, agent_creation_unlocked
+		FROM user_progress
+		WHERE user_id = ANY($1::uuid[])
+		FOR UPDATE
+	`, batch)
+	if err != nil {
+		return 0, after, fmt.Errorf("failed to lock progress: %w", err)
+	}
+	for rows.Next() {
+		var progress models.UserProgress
+		if err := rows.Scan(&progress.UserID, &progress.TotalXP, &progress.CurrentLevel, &progress.AgentCreationUnlocked); err != nil {
+			rows.Close()
+			return 0, after, fmt.Errorf("failed to scan progress: %w", err)
+		}
+		current[progress.UserID] = progress
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, after, fmt.Errorf("failed to read progress: %w", err)
+	}
+
+	// Compacted events only survive as monthly rollups, which are replayed at the start of
+	// their month, before that month's remaining events
+	awards := make(map[uuid.UUID][]int, len(ids))
+	rows, err = tx.Query(`
+		SELECT user_id, xp FROM (
+			SELECT user_id, month::timestamp AS at, 0 AS kind, xp_total AS xp
+			FROM xp_event_rollups
+			WHERE user_id = ANY($1::uuid[])
+			UNION ALL
+			SELECT user_id, created_at, 1, xp_awarded
+			FROM xp_events
+			WHERE compacted_at IS NULL AND user_id = ANY($1::uuid[])
+		) l
+		ORDER BY user_id, at, kind
+	`, batch)
+	if err != nil {
+		return 0, after, fmt.Errorf("failed to read XP ledger: %w", err)
+	}
+	for rows.Next() {
+		var id uuid.UUID
+		var amount int
+		if err := rows.Scan(&id, &amount); err != nil {
+			rows.Close()
+			return 0, after, fmt.Errorf("failed to scan XP ledger: %w", err)
+		}
+		awards[id] = append(awards[id], amount)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, after, fmt.Errorf("failed to read XP ledger: %w", err)
+	}
+
+	for _, id := range ids {
+		before := current[id]
+		total, level, agentUnlocked := levelPolicy(p.config).Replay(p.config.LevelUpXPThresholds, p.config.AgentUnlockLevel, awards[id])
+		if total == before.TotalXP && level == before.CurrentLevel && agentUnlocked == before.AgentCreationUnlocked {
+			continue
+		}
+
+		replay.Changed++
+		if len(replay.Changes) < maxReportedDrifts {
+			replay.Changes = append(replay.Changes, models.ProgressReplayChange{
+				UserID:          id,
+				PreviousTotalXP: before.TotalXP,
+				TotalXP:         total,
+				PreviousLevel:   before.CurrentLevel,
+				Level:           level,
+			})
+		}
+		if err := updateProgress(p.db, tx, id, total, level, agentUnlocked); err != nil {
+			return 0, after, fmt.Errorf("failed to rebuild progress of %s: %w", id, err)
+		}
+	}
+	replay.Users += len(ids)
+
+	// A dry run does the same work and throws it away
+	if !replay.DryRun {
+		if err := tx.Commit(); err != nil {
+			return 0, after, fmt.Errorf("failed to commit replay batch: %w", err)
+		}
+	}
+	return len(ids), ids[len(ids)-1], nil
+}
+
+// uuidColumn reads a single-column UUID result
+func uuidColumn(rows *sql.Rows, err error) ([]uuid.UUID, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
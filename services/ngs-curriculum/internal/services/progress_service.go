@@ -1,50 +1,74 @@
 package services
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"time"
 
+	"noble-ngs-curriculum/internal/api"
+	"noble-ngs-curriculum/internal/cache"
+	"noble-ngs-curriculum/internal/clients/users"
 	"noble-ngs-curriculum/internal/config"
 	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/domain/xp"
 	"noble-ngs-curriculum/internal/models"
 
 	"github.com/google/uuid"
 )
 
+// levelsCacheTTL bounds how long cached levels are used; levels only change when seeding adds them
+const levelsCacheTTL = 5 * time.Minute
+
 type ProgressService struct {
-	db     *database.DB
-	config *config.Config
+	store      ProgressStore
+	config     *config.Config
+	clock      Clock
+	cache      cache.Cache
+	calculator xp.XPCalculator
+	// directory names leaderboard entries; nil leaves them unnamed
+	directory UserDirectory
+}
+
+// UserDirectory resolves user IDs to how they are shown to others; *users.Client implements it
+type UserDirectory interface {
+	Profiles(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]users.Profile, error)
 }
 
-func NewProgressService(db *database.DB, cfg *config.Config) *ProgressService {
+func NewProgressService(db *database.DB, cfg *config.Config, clock Clock) *ProgressService {
+	return NewProgressServiceWithStore(NewPostgresProgressStore(db), cfg, clock)
+}
+
+// NewProgressServiceWithStore creates a ProgressService on another store, e.g. an in-memory one in tests
+func NewProgressServiceWithStore(store ProgressStore, cfg *config.Config, clock Clock) *ProgressService {
 	return &ProgressService{
-		db:     db,
-		config: cfg,
+		store:      store,
+		config:     cfg,
+		clock:      clock,
+		calculator: xp.Linear{},
 	}
 }
 
+// SetCache caches levels and the leaderboard in c. Without a cache every read goes to the store.
+func (s *ProgressService) SetCache(c cache.Cache) {
+	s.cache = c
+}
+
+// SetUserDirectory fills in leaderboard entries' display names and avatars from directory
+func (s *ProgressService) SetUserDirectory(directory UserDirectory) {
+	s.directory = directory
+}
+
+// SetXPCalculator applies calculator's formula to every award. Without one awards are linear.
+func (s *ProgressService) SetXPCalculator(calculator xp.XPCalculator) {
+	s.calculator = calculator
+}
+
 // GetProgress retrieves or creates user progress
 func (s *ProgressService) GetProgress(userID uuid.UUID) (*models.ProgressResponse, error) {
-	var progress models.UserProgress
-
-	err := s.db.QueryRow(`
-		SELECT id, user_id, current_level, total_xp, agent_creation_unlocked, created_at, updated_at
-		FROM user_progress
-		WHERE user_id = $1
-	`, userID).Scan(
-		&progress.ID,
-		&progress.UserID,
-		&progress.CurrentLevel,
-		&progress.TotalXP,
-		&progress.AgentCreationUnlocked,
-		&progress.CreatedAt,
-		&progress.UpdatedAt,
-	)
-
-	if err == sql.ErrNoRows {
+	progress, err := s.store.GetProgress(userID)
+	if err == ErrProgressNotFound {
 		// Create new progress entry
 		progress, err = s.createInitialProgress(userID)
 		if err != nil {
@@ -61,21 +85,7 @@ func (s *ProgressService) GetProgress(userID uuid.UUID) (*models.ProgressRespons
 
 // createInitialProgress creates a new progress entry for a user
 func (s *ProgressService) createInitialProgress(userID uuid.UUID) (models.UserProgress, error) {
-	var progress models.UserProgress
-	err := s.db.QueryRow(`
-		INSERT INTO user_progress (user_id, current_level, total_xp, agent_creation_unlocked)
-		VALUES ($1, 1, 0, false)
-		RETURNING id, user_id, current_level, total_xp, agent_creation_unlocked, created_at, updated_at
-	`, userID).Scan(
-		&progress.ID,
-		&progress.UserID,
-		&progress.CurrentLevel,
-		&progress.TotalXP,
-		&progress.AgentCreationUnlocked,
-		&progress.CreatedAt,
-		&progress.UpdatedAt,
-	)
-
+	progress, err := s.store.CreateProgress(userID)
 	if err != nil {
 		return progress, fmt.Errorf("failed to insert initial progress: %w", err)
 	}
@@ -87,144 +97,107 @@ func (s *ProgressService) createInitialProgress(userID uuid.UUID) (models.UserPr
 // AwardXP awards XP to a user and updates their level
 func (s *ProgressService) AwardXP(userID uuid.UUID, source string, amount int, metadata map[string]interface{}) (*models.ProgressResponse, error) {
 	// If amount not specified, use default from config
-	if amount <= 0 {
-		if defaultAmount, ok := s.config.XPSources[source]; ok {
-			amount = defaultAmount
-		} else {
-			amount = 10 // fallback
-		}
-	}
-
-	// Start transaction
-	tx, err := s.db.Begin()
+	base := xp.AwardAmount(s.config.XPSources, source, amount)
+	amount, err := s.applyFormula(userID, source, base, metadata)
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, err
 	}
-	defer tx.Rollback()
-
-	// Get current progress
-	var progress models.UserProgress
-	err = tx.QueryRow(`
-		SELECT id, user_id, current_level, total_xp, agent_creation_unlocked, created_at, updated_at
-		FROM user_progress
-		WHERE user_id = $1
-		FOR UPDATE
-	`, userID).Scan(
-		&progress.ID,
-		&progress.UserID,
-		&progress.CurrentLevel,
-		&progress.TotalXP,
-		&progress.AgentCreationUnlocked,
-		&progress.CreatedAt,
-		&progress.UpdatedAt,
-	)
-
-	if err == sql.ErrNoRows {
-		// Create initial progress
-		progress, err = s.createInitialProgress(userID)
-		if err != nil {
-			return nil, err
-		}
-	} else if err != nil {
-		return nil, fmt.Errorf("failed to get progress: %w", err)
+	if amount != base {
+		// Keep what the award was before the formula, for support and audits
+		metadata = withXPFormula(metadata, s.calculator.Name(), base)
 	}
-
-	// Record XP event
-	metadataJSON, _ := json.Marshal(metadata)
-	_, err = tx.Exec(`
-		INSERT INTO xp_events (user_id, source, xp_awarded, metadata)
-		VALUES ($1, $2, $3, $4)
-	`, userID, source, amount, metadataJSON)
-
+	amount, metadata, err = decayXP(source, amount, metadata, func() (int, error) {
+		return s.store.CountXPEvents(userID, source, s.clock.Now().Add(-xpDecayWindow))
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to record XP event: %w", err)
+		return nil, err
 	}
 
-	// Update total XP
-	newTotalXP := progress.TotalXP + amount
-
-	// Calculate new level
-	newLevel := s.calculateLevel(newTotalXP)
-
-	// Check if level up occurred
-	leveledUp := newLevel > progress.CurrentLevel
-
-	// Check if agent creation should be unlocked
-	agentUnlocked := progress.AgentCreationUnlocked || (newLevel >= s.config.AgentUnlockLevel)
-
-	// Update progress
-	_, err = tx.Exec(`
-		UPDATE user_progress
-		SET total_xp = $1, current_level = $2, agent_creation_unlocked = $3, updated_at = NOW()
-		WHERE user_id = $4
-	`, newTotalXP, newLevel, agentUnlocked, userID)
-
+	metadataJSON, _ := json.Marshal(metadata)
+	progress, err := s.store.AwardXP(userID, source, amount, metadataJSON, func(current models.UserProgress) (models.UserProgress, []models.Achievement) {
+		updated := current
+		updated.TotalXP = current.TotalXP + amount
+		policy := levelPolicy(s.config)
+		updated.CurrentLevel = policy.Level(s.config.LevelUpXPThresholds, current.CurrentLevel, updated.TotalXP)
+		updated.AgentCreationUnlocked = policy.AgentUnlocked(current.AgentCreationUnlocked, updated.CurrentLevel, s.config.AgentUnlockLevel)
+		updated.UpdatedAt = s.clock.Now()
+		return updated, progressAchievements(current, updated)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to update progress: %w", err)
+		return nil, err
 	}
+	s.InvalidateLeaderboards()
 
-	// If leveled up, record achievement
-	if leveledUp {
-		achievementData := map[string]interface{}{
-			"from_level": progress.CurrentLevel,
-			"to_level":   newLevel,
-			"xp":         newTotalXP,
-		}
-		achievementJSON, _ := json.Marshal(achievementData)
-
-		_, err = tx.Exec(`
-			INSERT INTO achievements (user_id, achievement_type, achievement_data)
-			VALUES ($1, $2, $3)
-		`, userID, "level_up", achievementJSON)
+	response := s.buildProgressResponse(&progress)
+	return response, nil
+}
 
-		if err != nil {
-			log.Printf("Warning: Failed to record level-up achievement: %v", err)
-		}
+// applyFormula returns the XP given for an award of base from source under the deployment's
+// formula. Repeats are counted before the award's transaction, so concurrent awards from one
+// source may both count as the same repeat.
+func (s *ProgressService) applyFormula(userID uuid.UUID, source string, base int, metadata map[string]interface{}) (int, error) {
+	award := xp.Award{Source: source, Base: base}
+	if difficulty, ok := metadata["difficulty"].(string); ok {
+		award.Difficulty = difficulty
 	}
-
-	// If agent creation unlocked, record achievement
-	if agentUnlocked && !progress.AgentCreationUnlocked {
-		achievementData := map[string]interface{}{
-			"level": newLevel,
-		}
-		achievementJSON, _ := json.Marshal(achievementData)
-
-		_, err = tx.Exec(`
-			INSERT INTO achievements (user_id, achievement_type, achievement_data)
-			VALUES ($1, $2, $3)
-		`, userID, "agent_creation_unlocked", achievementJSON)
-
+	if s.calculator.CountsRepeats() && base > 0 {
+		window := time.Duration(s.config.XPRepeatWindowHours) * time.Hour
+		repeats, err := s.store.CountXPEvents(userID, source, s.clock.Now().Add(-window))
 		if err != nil {
-			log.Printf("Warning: Failed to record agent unlock achievement: %v", err)
+			return 0, err
 		}
+		award.Repeats = repeats
 	}
+	return s.calculator.Amount(award), nil
+}
 
-	// Commit transaction
-	if err = tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+// withXPFormula returns a copy of metadata recording the formula and the amount before it
+func withXPFormula(metadata map[string]interface{}, formula string, base int) map[string]interface{} {
+	annotated := make(map[string]interface{}, len(metadata)+2)
+	for k, v := range metadata {
+		annotated[k] = v
 	}
+	annotated["xp_formula"] = formula
+	annotated["xp_base"] = base
+	return annotated
+}
 
-	// Get updated progress
-	progress.TotalXP = newTotalXP
-	progress.CurrentLevel = newLevel
-	progress.AgentCreationUnlocked = agentUnlocked
-	progress.UpdatedAt = time.Now()
+// progressAchievements returns the achievements unlocked by moving from before to after
+func progressAchievements(before, after models.UserProgress) []models.Achievement {
+	var achievements []models.Achievement
+	add := func(achievementType string, data map[string]interface{}) {
+		dataJSON, _ := json.Marshal(data)
+		achievements = append(achievements, models.Achievement{
+			UserID:          after.UserID,
+			AchievementType: achievementType,
+			AchievementData: dataJSON,
+			UnlockedAt:      after.UpdatedAt,
+		})
+	}
 
-	response := s.buildProgressResponse(&progress)
-	return response, nil
+	if after.CurrentLevel > before.CurrentLevel {
+		add("level_up", map[string]interface{}{
+			"from_level": before.CurrentLevel,
+			"to_level":   after.CurrentLevel,
+			"xp":         after.TotalXP,
+		})
+	}
+	if after.AgentCreationUnlocked && !before.AgentCreationUnlocked {
+		add("agent_creation_unlocked", map[string]interface{}{
+			"level": after.CurrentLevel,
+		})
+	}
+	return append(achievements, milestoneAchievements(after.UserID, before.TotalXP, after.TotalXP, after.UpdatedAt)...)
 }
 
-// calculateLevel determines the level based on total XP
-func (s *ProgressService) calculateLevel(totalXP int) int {
-	level := 1
-	for i, threshold := range s.config.LevelUpXPThresholds {
-		if totalXP >= threshold {
-			level = i + 1
-		} else {
-			break
-		}
+// levelPolicy returns the deployment's level-down and agent unlock policy. The values are
+// checked at startup.
+func levelPolicy(cfg *config.Config) xp.LevelPolicy {
+	return xp.LevelPolicy{
+		LevelDown:   cfg.LevelDownPolicy,
+		GraceXP:     cfg.LevelDownGraceXP,
+		AgentUnlock: cfg.AgentUnlockPolicy,
 	}
-	return level
 }
 
 // buildProgressResponse enriches progress with level info
@@ -233,27 +206,17 @@ func (s *ProgressService) buildProgressResponse(progress *models.UserProgress) *
 		UserProgress: *progress,
 	}
 
-	// Get current level info
-	if progress.CurrentLevel > 0 && progress.CurrentLevel <= len(s.config.LevelUpXPThresholds) {
-		currentLevel, _ := s.GetLevel(progress.CurrentLevel)
-		response.CurrentLevelInfo = currentLevel
-	}
+	levelProgress := xp.Progress(s.config.LevelUpXPThresholds, progress.CurrentLevel, progress.TotalXP)
+	response.XPToNextLevel = levelProgress.XPToNextLevel
+	response.ProgressPercent = levelProgress.Percent
+	response.IsMaxLevel = levelProgress.MaxLevel
 
-	// Get next level info
-	if progress.CurrentLevel < len(s.config.LevelUpXPThresholds) {
-		nextLevel, _ := s.GetLevel(progress.CurrentLevel + 1)
+	// Get current and next level info
+	currentLevel, _ := s.GetLevel(levelProgress.Level)
+	response.CurrentLevelInfo = currentLevel
+	if !levelProgress.MaxLevel {
+		nextLevel, _ := s.GetLevel(levelProgress.Level + 1)
 		response.NextLevelInfo = nextLevel
-
-		// Calculate XP to next level
-		currentThreshold := s.config.LevelUpXPThresholds[progress.CurrentLevel-1]
-		nextThreshold := s.config.LevelUpXPThresholds[progress.CurrentLevel]
-		xpInCurrentLevel := progress.TotalXP - currentThreshold
-		xpNeededForLevel := nextThreshold - currentThreshold
-
-		response.XPToNextLevel = nextThreshold - progress.TotalXP
-		if xpNeededForLevel > 0 {
-			response.ProgressPercent = (float64(xpInCurrentLevel) / float64(xpNeededForLevel)) * 100
-		}
 	}
 
 	return response
@@ -261,126 +224,84 @@ func (s *ProgressService) buildProgressResponse(progress *models.UserProgress) *
 
 // GetLevel retrieves a curriculum level by level number
 func (s *ProgressService) GetLevel(levelNumber int) (*models.CurriculumLevel, error) {
-	var level models.CurriculumLevel
-	err := s.db.QueryRow(`
-		SELECT id, level_number, title, description, COALESCE(unlock_requirements, '{}'), xp_required
-		FROM curriculum_levels
-		WHERE level_number = $1
-	`, levelNumber).Scan(
-		&level.ID,
-		&level.LevelNumber,
-		&level.Title,
-		&level.Description,
-		&level.UnlockRequirements,
-		&level.XPRequired,
-	)
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to get level: %w", err)
+	if s.cache == nil {
+		return s.store.GetLevel(levelNumber)
 	}
-
-	return &level, nil
-}
-
-// GetAllLevels retrieves all curriculum levels
-func (s *ProgressService) GetAllLevels() ([]models.CurriculumLevel, error) {
-	rows, err := s.db.Query(`
-		SELECT id, level_number, title, description, COALESCE(unlock_requirements, '{}'), xp_required
-		FROM curriculum_levels
-		ORDER BY level_number
-	`)
+	levels, err := s.GetAllLevels()
 	if err != nil {
-		return nil, fmt.Errorf("failed to query levels: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
-
-	var levels []models.CurriculumLevel
-	for rows.Next() {
-		var level models.CurriculumLevel
-		err := rows.Scan(
-			&level.ID,
-			&level.LevelNumber,
-			&level.Title,
-			&level.Description,
-			&level.UnlockRequirements,
-			&level.XPRequired,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan level: %w", err)
+	for i := range levels {
+		if levels[i].LevelNumber == levelNumber {
+			return &levels[i], nil
 		}
-		levels = append(levels, level)
 	}
-
-	return levels, nil
+	// Not cached yet, or missing; the store reports which
+	return s.store.GetLevel(levelNumber)
 }
 
-// GetAchievements retrieves a user's achievements
-func (s *ProgressService) GetAchievements(userID uuid.UUID) ([]models.Achievement, error) {
-	rows, err := s.db.Query(`
-		SELECT id, user_id, achievement_type, COALESCE(achievement_data, '{}'), unlocked_at
-		FROM achievements
-		WHERE user_id = $1
-		ORDER BY unlocked_at DESC
-	`, userID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query achievements: %w", err)
-	}
-	defer rows.Close()
-
-	var achievements []models.Achievement
-	for rows.Next() {
-		var achievement models.Achievement
-		err := rows.Scan(
-			&achievement.ID,
-			&achievement.UserID,
-			&achievement.AchievementType,
-			&achievement.AchievementData,
-			&achievement.UnlockedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan achievement: %w", err)
-		}
-		achievements = append(achievements, achievement)
-	}
+// GetAllLevels retrieves all curriculum levels
+func (s *ProgressService) GetAllLevels() ([]models.CurriculumLevel, error) {
+	return cache.Load(context.Background(), s.cache, "levels", levelsCacheTTL, s.store.ListLevels)
+}
 
-	return achievements, nil
+// GetAchievements retrieves a page of a user's achievements, newest first
+func (s *ProgressService) GetAchievements(userID uuid.UUID, page api.Page) ([]models.Achievement, error) {
+	return s.store.ListAchievements(userID, page)
 }
 
-// GetLeaderboard retrieves top users by XP
+// GetLeaderboard retrieves top users by XP, skipping users who opted out in their settings,
+// minor accounts and deactivated accounts
 func (s *ProgressService) GetLeaderboard(limit int) ([]models.LeaderboardEntry, error) {
 	if limit <= 0 {
 		limit = 10
 	}
+	ttl := time.Duration(s.config.LeaderboardCacheSeconds) * time.Second
+	if ttl <= 0 {
+		return s.withProfiles(leaderboardWithPrestige(s.store.Leaderboard(limit)))
+	}
+	return s.withProfiles(cache.Load(context.Background(), s.cache, s.leaderboardKey("%d", limit), ttl, func() ([]models.LeaderboardEntry, error) {
+		return leaderboardWithPrestige(s.store.Leaderboard(limit))
+	}))
+}
+
+// profileLookupTimeout bounds how long a leaderboard waits on the user directory
+const profileLookupTimeout = 2 * time.Second
 
-	rows, err := s.db.Query(`
-		SELECT 
-			user_id,
-			current_level,
-			total_xp,
-			RANK() OVER (ORDER BY total_xp DESC) as rank
-		FROM user_progress
-		ORDER BY total_xp DESC
-		LIMIT $1
-	`, limit)
+// withProfiles names entries from the user directory in one batch. Profiles are cached by the
+// directory rather than with the board, so a rename shows up on every board alike. A failed
+// lookup is logged and the entries are served unnamed.
+func (s *ProgressService) withProfiles(entries []models.LeaderboardEntry, err error) ([]models.LeaderboardEntry, error) {
+	if err != nil || s.directory == nil || len(entries) == 0 {
+		return entries, err
+	}
+	ids := make([]uuid.UUID, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.UserID
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), profileLookupTimeout)
+	defer cancel()
+	profiles, err := s.directory.Profiles(ctx, ids)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query leaderboard: %w", err)
+		log.Printf("Failed to look up leaderboard users: %v", err)
 	}
-	defer rows.Close()
-
-	var entries []models.LeaderboardEntry
-	for rows.Next() {
-		var entry models.LeaderboardEntry
-		err := rows.Scan(
-			&entry.UserID,
-			&entry.CurrentLevel,
-			&entry.TotalXP,
-			&entry.Rank,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan leaderboard entry: %w", err)
+	for i := range entries {
+		if profile, ok := profiles[entries[i].UserID]; ok {
+			entries[i].Username = profile.DisplayName
+			entries[i].AvatarURL = profile.AvatarURL
 		}
-		entries = append(entries, entry)
 	}
+	return entries, nil
+}
 
+// leaderboardWithPrestige fills in each entry's prestige milestones from its total
+func leaderboardWithPrestige(entries []models.LeaderboardEntry, err error) ([]models.LeaderboardEntry, error) {
+	if err != nil {
+		return nil, err
+	}
+	prestige := currentPrestige()
+	for i := range entries {
+		entries[i].Prestige = prestige.Milestones(entries[i].TotalXP)
+	}
 	return entries, nil
 }
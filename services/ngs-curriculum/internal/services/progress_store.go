@@ -0,0 +1,404 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"noble-ngs-curriculum/internal/api"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrProgressNotFound is returned by a ProgressStore for users without progress
+var ErrProgressNotFound = errors.New("progress not found")
+
+// XPUpdate computes a user's progress after an XP award, with any achievements it unlocks
+type XPUpdate func(current models.UserProgress) (models.UserProgress, []models.Achievement)
+
+// ProgressStore persists what ProgressService reads and writes. Production uses Postgres;
+// internal/testsupport has an in-memory store for service tests.
+type ProgressStore interface {
+	GetProgress(userID uuid.UUID) (models.UserProgress, error)
+	CreateProgress(userID uuid.UUID) (models.UserProgress, error)
+	// AwardXP records the XP event and saves the progress and achievements computed by update
+	// atomically, holding the user's progress locked meanwhile. It returns the saved progress.
+	AwardXP(userID uuid.UUID, source string, amount int, metadata []byte, update XPUpdate) (models.UserProgress, error)
+	// CountXPEvents counts the user's XP events from source since the given time
+	CountXPEvents(userID uuid.UUID, source string, since time.Time) (int, error)
+	GetLevel(levelNumber int) (*models.CurriculumLevel, error)
+	ListLevels() ([]models.CurriculumLevel, error)
+	// ListAchievements returns a page of the user's achievements, newest first
+	ListAchievements(userID uuid.UUID, page api.Page) ([]models.Achievement, error)
+	Leaderboard(limit int) ([]models.LeaderboardEntry, error)
+	// PeriodLeaderboard ranks users by the XP of their events from start until end
+	PeriodLeaderboard(start, end time.Time, limit int) ([]models.LeaderboardEntry, error)
+	// LeaderboardAround returns the user's Leaderboard entry with up to neighbors entries on
+	// either side, in board order, and how many users are ranked. It returns no entries for users
+	// not on the board.
+	LeaderboardAround(userID uuid.UUID, neighbors int) ([]models.LeaderboardEntry, int, error)
+	// PeriodLeaderboardAround is LeaderboardAround on the PeriodLeaderboard from start until end
+	PeriodLeaderboardAround(userID uuid.UUID, start, end time.Time, neighbors int) ([]models.LeaderboardEntry, int, error)
+}
+
+// postgresProgressStore is the ProgressStore used in production
+type postgresProgressStore struct {
+	db *database.DB
+}
+
+// NewPostgresProgressStore returns a ProgressStore backed by the database
+func NewPostgresProgressStore(db *database.DB) ProgressStore {
+	return &postgresProgressStore{db: db}
+}
+
+func (s *postgresProgressStore) GetProgress(userID uuid.UUID) (models.UserProgress, error) {
+	progress, err := loadProgress(s.db, nil, userID)
+	if err == sql.ErrNoRows {
+		return progress, ErrProgressNotFound
+	}
+	return progress, err
+}
+
+func (s *postgresProgressStore) CreateProgress(userID uuid.UUID) (models.UserProgress, error) {
+	return insertInitialProgress(s.db, userID)
+}
+
+func (s *postgresProgressStore) AwardXP(userID uuid.UUID, source string, amount int, metadata []byte, update XPUpdate) (models.UserProgress, error) {
+	// Start transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.UserProgress{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Get current progress
+	progress, err := loadProgress(s.db, tx, userID)
+	if err == sql.ErrNoRows {
+		// Create initial progress
+		progress, err = s.CreateProgress(userID)
+		if err != nil {
+			return progress, fmt.Errorf("failed to insert initial progress: %w", err)
+		}
+		log.Printf("Created initial progress for user %s", userID)
+	} else if err != nil {
+		return progress, fmt.Errorf("failed to get progress: %w", err)
+	}
+
+	// Record XP event
+	if err := recordXPEvent(s.db, tx, userID, source, amount, metadata); err != nil {
+		return progress, fmt.Errorf("failed to record XP event: %w", err)
+	}
+
+	updated, achievements := update(progress)
+	if err := updateProgress(s.db, tx, userID, updated.TotalXP, updated.CurrentLevel, updated.AgentCreationUnlocked); err != nil {
+		return progress, fmt.Errorf("failed to update progress: %w", err)
+	}
+
+	for _, achievement := range achievements {
+		_, err = tx.Exec(`
+			INSERT INTO achievements (user_id, achievement_type, achievement_data)
+			VALUES ($1, $2, $3)
+		`, userID, achievement.AchievementType, []byte(achievement.AchievementData))
+		if err != nil {
+			log.Printf("Warning: Failed to record %s achievement: %v", achievement.AchievementType, err)
+		}
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return progress, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return updated, nil
+}
+
+func (s *postgresProgressStore) CountXPEvents(userID uuid.UUID, source string, since time.Time) (int, error) {
+	var count int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM xp_events
+		WHERE user_id = $1 AND source = $2 AND created_at >= $3
+	`, userID, source, since).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count XP events: %w", err)
+	}
+	return count, nil
+}
+
+const levelColumns = `id, level_number, title, description, COALESCE(unlock_requirements, '{}'), xp_required`
+
+// scanLevel reads levelColumns. The description is optional and reads as empty when NULL.
+func scanLevel(row rowScanner) (models.CurriculumLevel, error) {
+	var level models.CurriculumLevel
+	var description sql.NullString
+	err := row.Scan(
+		&level.ID,
+		&level.LevelNumber,
+		&level.Title,
+		&description,
+		&level.UnlockRequirements,
+		&level.XPRequired,
+	)
+	level.Description = description.String
+	return level, err
+}
+
+func (s *postgresProgressStore) GetLevel(levelNumber int) (*models.CurriculumLevel, error) {
+	level, err := scanLevel(s.db.QueryRow(`
+		SELECT `+levelColumns+`
+		FROM curriculum_levels
+		WHERE level_number = $1
+	`, levelNumber))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get level: %w", err)
+	}
+
+	return &level, nil
+}
+
+func (s *postgresProgressStore) ListLevels() ([]models.CurriculumLevel, error) {
+	rows, err := s.db.Query(`
+		SELECT ` + levelColumns + `
+		FROM curriculum_levels
+		ORDER BY level_number
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query levels: %w", err)
+	}
+	defer rows.Close()
+
+	levels := []models.CurriculumLevel{}
+	for rows.Next() {
+		level, err := scanLevel(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan level: %w", err)
+		}
+		levels = append(levels, level)
+	}
+
+	return levels, nil
+}
+
+func (s *postgresProgressStore) ListAchievements(userID uuid.UUID, page api.Page) ([]models.Achievement, error) {
+	where, limit, args := pageSQL(page, "unlocked_at", "id", []interface{}{userID})
+	rows, err := s.db.Query(`
+		SELECT id, user_id, achievement_type, COALESCE(achievement_data, '{}'), unlocked_at
+		FROM achievements
+		WHERE user_id = $1`+where+`
+		ORDER BY unlocked_at DESC, id
+		`+limit, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query achievements: %w", err)
+	}
+	defer rows.Close()
+
+	achievements := []models.Achievement{}
+	for rows.Next() {
+		var achievement models.Achievement
+		err := rows.Scan(
+			&achievement.ID,
+			&achievement.UserID,
+			&achievement.AchievementType,
+			&achievement.AchievementData,
+			&achievement.UnlockedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan achievement: %w", err)
+		}
+		achievements = append(achievements, achievement)
+	}
+
+	return achievements, nil
+}
+
+// Leaderboard skips users who opted out in their settings, minor accounts and deactivated accounts
+func (s *postgresProgressStore) Leaderboard(limit int) ([]models.LeaderboardEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			user_id,
+			current_level,
+			total_xp,
+			(SELECT COUNT(*) FROM mastery_stars ms WHERE ms.user_id = up.user_id) AS mastery_stars,
+			RANK() OVER (ORDER BY total_xp DESC) as rank
+		FROM user_progress up
+		WHERE `+leaderboardVisible+`
+		ORDER BY total_xp DESC, user_id
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.LeaderboardEntry{}
+	for rows.Next() {
+		var entry models.LeaderboardEntry
+		err := rows.Scan(
+			&entry.UserID,
+			&entry.CurrentLevel,
+			&entry.TotalXP,
+			&entry.MasteryStars,
+			&entry.Rank,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan leaderboard entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// PeriodLeaderboard skips the same users as Leaderboard, and users whose events in the period
+// net no XP
+func (s *postgresProgressStore) PeriodLeaderboard(start, end time.Time, limit int) ([]models.LeaderboardEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			up.user_id,
+			up.current_level,
+			up.total_xp,
+			(SELECT COUNT(*) FROM mastery_stars ms WHERE ms.user_id = up.user_id) AS mastery_stars,
+			earned.xp,
+			RANK() OVER (ORDER BY earned.xp DESC) as rank
+		FROM (
+			SELECT user_id, SUM(xp_awarded) AS xp
+			FROM xp_events
+			WHERE created_at >= $1 AND created_at < $2
+			GROUP BY user_id
+			HAVING SUM(xp_awarded) > 0
+		) earned
+		JOIN user_progress up ON up.user_id = earned.user_id
+		WHERE `+leaderboardVisible+`
+		ORDER BY earned.xp DESC, up.user_id
+		LIMIT $3
+	`, start, end, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query period leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.LeaderboardEntry{}
+	for rows.Next() {
+		var entry models.LeaderboardEntry
+		err := rows.Scan(
+			&entry.UserID,
+			&entry.CurrentLevel,
+			&entry.TotalXP,
+			&entry.MasteryStars,
+			&entry.PeriodXP,
+			&entry.Rank,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan leaderboard entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// leaderboardVisible is the condition on user_progress up that skips users who opted out in
+// their settings, minor accounts and deactivated accounts
+const leaderboardVisible = `NOT EXISTS (
+			SELECT 1 FROM user_settings us
+			WHERE us.user_id = up.user_id
+				AND (us.show_on_leaderboard = false OR us.age_band IN ('child', 'teen') OR us.deactivated_at IS NOT NULL)
+		)`
+
+// LeaderboardAround ranks the whole board in one pass with window functions and keeps the rows
+// within neighbors positions of the user's, so the client need not page to find itself
+func (s *postgresProgressStore) LeaderboardAround(userID uuid.UUID, neighbors int) ([]models.LeaderboardEntry, int, error) {
+	rows, err := s.db.Query(`
+		WITH ranked AS (
+			SELECT
+				user_id,
+				current_level,
+				total_xp,
+				0 AS period_xp,
+				RANK() OVER (ORDER BY total_xp DESC) AS rank,
+				ROW_NUMBER() OVER (ORDER BY total_xp DESC, user_id) AS position,
+				COUNT(*) OVER () AS total
+			FROM user_progress up
+			WHERE `+leaderboardVisible+`
+		)
+		`+aroundSelect, userID, neighbors)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query leaderboard position: %w", err)
+	}
+	return scanLeaderboardAround(rows)
+}
+
+// PeriodLeaderboardAround is LeaderboardAround over the XP earned from start until end
+func (s *postgresProgressStore) PeriodLeaderboardAround(userID uuid.UUID, start, end time.Time, neighbors int) ([]models.LeaderboardEntry, int, error) {
+	rows, err := s.db.Query(`
+		WITH ranked AS (
+			SELECT
+				up.user_id,
+				up.current_level,
+				up.total_xp,
+				earned.xp AS period_xp,
+				RANK() OVER (ORDER BY earned.xp DESC) AS rank,
+				ROW_NUMBER() OVER (ORDER BY earned.xp DESC, up.user_id) AS position,
+				COUNT(*) OVER () AS total
+			FROM (
+				SELECT user_id, SUM(xp_awarded) AS xp
+				FROM xp_events
+				WHERE created_at >= $3 AND created_at < $4
+				GROUP BY user_id
+				HAVING SUM(xp_awarded) > 0
+			) earned
+			JOIN user_progress up ON up.user_id = earned.user_id
+			WHERE `+leaderboardVisible+`
+		)
+		`+aroundSelect, userID, neighbors, start, end)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query period leaderboard position: %w", err)
+	}
+	return scanLeaderboardAround(rows)
+}
+
+// aroundSelect picks the rows of ranked within $2 positions of user $1. Mastery stars are only
+// counted for those rows.
+const aroundSelect = `SELECT
+			r.user_id,
+			r.current_level,
+			r.total_xp,
+			(SELECT COUNT(*) FROM mastery_stars ms WHERE ms.user_id = r.user_id) AS mastery_stars,
+			r.period_xp,
+			r.rank,
+			r.total
+		FROM ranked r
+		JOIN ranked me ON me.user_id = $1
+		WHERE r.position BETWEEN me.position - $2 AND me.position + $2
+		ORDER BY r.position`
+
+func scanLeaderboardAround(rows *sql.Rows) ([]models.LeaderboardEntry, int, error) {
+	defer rows.Close()
+
+	entries := []models.LeaderboardEntry{}
+	total := 0
+	for rows.Next() {
+		var entry models.LeaderboardEntry
+		err := rows.Scan(
+			&entry.UserID,
+			&entry.CurrentLevel,
+			&entry.TotalXP,
+			&entry.MasteryStars,
+			&entry.PeriodXP,
+			&entry.Rank,
+			&total,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan leaderboard entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read leaderboard position: %w", err)
+	}
+
+	return entries, total, nil
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+)
+
+// Question flags. A flagged question is likely wrong, ambiguous or off topic and worth a rewrite.
+const (
+	// QuestionFlagRarelyCorrect marks a question almost everyone misses
+	QuestionFlagRarelyCorrect = "rarely_correct"
+	// QuestionFlagLowDiscrimination marks a question whose outcome does not follow how well
+	// learners did on the rest of the exam, or runs against it
+	QuestionFlagLowDiscrimination = "low_discrimination"
+)
+
+// DefaultQuestionMinAttempts is how many answers a question needs before it is flagged
+const DefaultQuestionMinAttempts = 20
+
+const (
+	questionRarelyCorrectRate    = 0.2
+	questionMinDiscrimination    = 0.15
+	questionAnalyticsLessonTitle = "(deleted lesson)"
+)
+
+// FlagQuestion sets the flags of a question once it has at least minAttempts attempts; with fewer
+// the rates are too noisy to judge and no flags are set
+func FlagQuestion(stats *models.QuestionStats, minAttempts int) {
+	stats.Flags = []string{}
+	if stats.Attempts < minAttempts || stats.Attempts == 0 {
+		return
+	}
+	if stats.CorrectRate < questionRarelyCorrectRate {
+		stats.Flags = append(stats.Flags, QuestionFlagRarelyCorrect)
+	}
+	if stats.Discrimination != nil && *stats.Discrimination < questionMinDiscrimination {
+		stats.Flags = append(stats.Flags, QuestionFlagLowDiscrimination)
+	}
+}
+
+// SortQuestionStats orders flagged questions first, then by discrimination with unknown last,
+// then by correct rate
+func SortQuestionStats(stats []models.QuestionStats) {
+	sort.SliceStable(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if (len(a.Flags) > 0) != (len(b.Flags) > 0) {
+			return len(a.Flags) > 0
+		}
+		if (a.Discrimination == nil) != (b.Discrimination == nil) {
+			return a.Discrimination != nil
+		}
+		if a.Discrimination != nil && *a.Discrimination != *b.Discrimination {
+			return *a.Discrimination < *b.Discrimination
+		}
+		if a.CorrectRate != b.CorrectRate {
+			return a.CorrectRate < b.CorrectRate
+		}
+		if a.LessonID != b.LessonID {
+			return a.LessonID.String() < b.LessonID.String()
+		}
+		return a.Question < b.Question
+	})
+}
+
+// QuestionAnalyticsService reports how exam questions perform so bad generated questions get fixed
+type QuestionAnalyticsService struct {
+	db *database.DB
+}
+
+func NewQuestionAnalyticsService(db *database.DB) *QuestionAnalyticsService {
+	return &QuestionAnalyticsService{
+		db: db,
+	}
+}
+
+// GetQuestionStats aggregates every answer in submitted exams by question, optionally for one
+// level (0 for all), flags them and returns them in SortQuestionStats order. Discrimination
+// correlates each answer with the share of the exam's other questions answered correctly, so a
+// question does not count towards its own discrimination.
+func (s *QuestionAnalyticsService) GetQuestionStats(level int, minAttempts int, flaggedOnly bool) ([]models.QuestionStats, error) {
+	rows, err := s.db.Query(`
+		WITH answers AS (
+			SELECT e.id AS exam_id, e.level_number, e.submitted_at, q,
+			       CASE WHEN COALESCE((r->>'correct')::boolean, false) THEN 1 ELSE 0 END AS correct
+			FROM level_exams e
+			CROSS JOIN LATERAL jsonb_array_elements(COALESCE(e.results, '[]'::jsonb)) AS r
+			JOIN LATERAL jsonb_array_elements(e.questions) AS q ON q->>'index' = r->>'index'
+			WHERE e.status = 'submitted' AND ($1 = 0 OR e.level_number = $1)
+		), scored AS (
+			SELECT a.*,
+			       (SUM(correct) OVER (PARTITION BY exam_id) - correct)::float8
+			           / NULLIF(COUNT(*) OVER (PARTITION BY exam_id) - 1, 0) AS rest_score
+			FROM answers a
+		)
+		SELECT s.q->>'lesson_id', COALESCE(MAX(l.title), $2), MAX(s.level_number),
+		       COALESCE(MAX(s.q->>'type'), ''), s.q->>'question',
+		       (array_agg(s.q->'choices' ORDER BY s.submitted_at DESC))[1],
+		       (array_agg(s.q->'answer' ORDER BY s.submitted_at DESC))[1],
+		       COUNT(*), SUM(s.correct), corr(s.correct::float8, s.rest_score)
+		FROM scored s
+		LEFT JOIN lessons l ON l.id::text = s.q->>'lesson_id'
+		GROUP BY s.q->>'lesson_id', s.q->>'question'
+	`, level, questionAnalyticsLessonTitle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate exam answers: %w", err)
+	}
+	defer rows.Close()
+
+	stats := []models.QuestionStats{}
+	for rows.Next() {
+		var q models.QuestionStats
+		var choices, answer []byte
+		var discrimination sql.NullFloat64
+		if err := rows.Scan(&q.LessonID, &q.LessonTitle, &q.LevelNumber, &q.Type, &q.Question,
+			&choices, &answer, &q.Attempts, &q.Correct, &discrimination); err != nil {
+			return nil, fmt.Errorf("failed to scan question stats: %w", err)
+		}
+		if len(choices) > 0 {
+			_ = json.Unmarshal(choices, &q.Choices)
+		}
+		if len(answer) > 0 && string(answer) != "null" {
+			q.Answer = answer
+		}
+		if q.Attempts > 0 {
+			q.CorrectRate = float64(q.Correct) / float64(q.Attempts)
+		}
+		if discrimination.Valid {
+			d := discrimination.Float64
+			q.Discrimination = &d
+		}
+
+		FlagQuestion(&q, minAttempts)
+		if flaggedOnly && len(q.Flags) == 0 {
+			continue
+		}
+		stats = append(stats, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read question stats: %w", err)
+	}
+
+	SortQuestionStats(stats)
+	return stats, nil
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type RecommendationService struct {
+	db              *database.DB
+	settingsService *SettingsService
+}
+
+func NewRecommendationService(db *database.DB, settingsService *SettingsService) *RecommendationService {
+	return &RecommendationService{
+		db:              db,
+		settingsService: settingsService,
+	}
+}
+
+// nextActionCandidate looks up one kind of next action; nil means nothing of that kind applies
+type nextActionCandidate func(userID uuid.UUID, level int) (*models.NextAction, error)
+
+// NextAction returns the highest-priority action for a user. Unfinished timed work comes
+// first, then started lessons, failed challenges and remediation lessons on weak topics, then new
+// material at the current level.
+func (s *RecommendationService) NextAction(userID uuid.UUID) (*models.NextAction, error) {
+	level := 1
+	err := s.db.QueryRow(`SELECT current_level FROM user_progress WHERE user_id = $1`, userID).Scan(&level)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get current level: %w", err)
+	}
+
+	candidates := []nextActionCandidate{
+		s.activeExam,
+		s.activeDuel,
+		s.startedLesson,
+		s.failedChallenge,
+		s.remediationLesson,
+		s.nextLesson,
+		s.newChallenge,
+		s.levelExam,
+	}
+	for _, candidate := range candidates {
+		action, err := candidate(userID, level)
+		if err != nil {
+			return nil, err
+		}
+		if action != nil {
+			return action, nil
+		}
+	}
+
+	return &models.NextAction{
+		Type:        "caught_up",
+		Title:       "You're all caught up",
+		Reason:      "Every lesson, challenge and exam available at your level is done",
+		LevelNumber: level,
+	}, nil
+}
+
+// lookup runs a query returning (id, level, title) and builds an action from it
+func (s *RecommendationService) lookup(actionType, reason, endpoint string, query string, args ...interface{}) (*models.NextAction, error) {
+	var id uuid.UUID
+	var level int
+	var title string
+	err := s.db.QueryRow(query, args...).Scan(&id, &level, &title)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find %s action: %w", actionType, err)
+	}
+
+	return &models.NextAction{
+		Type:        actionType,
+		Title:       title,
+		Reason:      reason,
+		LevelNumber: level,
+		ResourceID:  &id,
+		Endpoint:    fmt.Sprintf(endpoint, id),
+	}, nil
+}
+
+func (s *RecommendationService) activeExam(userID uuid.UUID, _ int) (*models.NextAction, error) {
+	return s.lookup("resume_exam", "Your level exam is still running", "/ngs/exams/%s", `
+		SELECT id, level_number, 'Level ' || level_number || ' exam'
+		FROM level_exams
+		WHERE user_id = $1 AND status = 'in_progress' AND ends_at > NOW()
+		ORDER BY started_at DESC
+		LIMIT 1
+	`, userID)
+}
+
+func (s *RecommendationService) activeDuel(userID uuid.UUID, _ int) (*models.NextAction, error) {
+	return s.lookup("resume_duel", "Your duel is in progress", "/ngs/duels/%s", `
+		SELECT d.id, d.level_number, c.title
+		FROM duels d
+		JOIN challenges c ON c.id = d.challenge_id
+		WHERE (d.player_one_id = $1 OR d.player_two_id = $1)
+			AND d.status = 'active' AND d.ends_at > NOW()
+		ORDER BY d.started_at DESC
+		LIMIT 1
+	`, userID)
+}
+
+// startedLesson finds the most recently touched lesson (tutor chat or lesson metrics) that is not
+// yet complete and that the user can still open: lessons since hidden from them or above their
+// level are skipped
+func (s *RecommendationService) startedLesson(userID uuid.UUID, _ int) (*models.NextAction, error) {
+	return s.lookup("resume_lesson", "Pick up where you left off", "/ngs/lessons/%s", `
+		SELECT l.id, l.level_id, l.title
+		FROM lessons l
+		JOIN (
+			SELECT lesson_id, updated_at AS touched_at FROM lesson_metrics WHERE user_id = $1
+			UNION ALL
+			SELECT lesson_id, created_at FROM educator_chat_sessions WHERE user_id = $1 AND status = 'active'
+		) started ON started.lesson_id = l.id
+		WHERE NOT `+lessonLockedSQL("l", "$1")+`
+			AND `+lessonVisibleSQL("l", "$1")+`
+			AND NOT EXISTS (
+				SELECT 1 FROM lesson_completions lc WHERE lc.lesson_id = l.id AND lc.user_id = $1
+			)
+		ORDER BY started.touched_at DESC
+		LIMIT 1
+	`, userID)
+}
+
+// failedChallenge finds the most recently attempted challenge the user has not passed yet
+func (s *RecommendationService) failedChallenge(userID uuid.UUID, _ int) (*models.NextAction, error) {
+	return s.lookup("retry_challenge", "You were close on this challenge", "/ngs/challenges/%s", `
+		SELECT c.id, c.level_id, c.title
+		FROM challenges c
+		JOIN challenge_submissions cs ON cs.challenge_id = c.id AND cs.user_id = $1
+		WHERE c.is_active = true
+		GROUP BY c.id, c.level_id, c.title
+		HAVING NOT bool_or(COALESCE(cs.passed, false))
+		ORDER BY MAX(cs.submitted_at) DESC
+		LIMIT 1
+	`, userID)
+}
+
+// remediationLesson finds the ready remediation lesson on the user's weakest topic. It is optional
+// but short, so it is offered before new material builds on the topic.
+func (s *RecommendationService) remediationLesson(userID uuid.UUID, level int) (*models.NextAction, error) {
+	return s.lookup("remediation_lesson", "Optional: a short refresher on a topic you keep missing", "/ngs/remediation/%s", `
+		SELECT rl.id, COALESCE(l.level_id, $2), COALESCE(rl.title, rl.topic)
+		FROM remediation_lessons rl
+		LEFT JOIN lessons l ON l.id = rl.lesson_id
+		WHERE rl.user_id = $1 AND rl.status = 'ready'
+		ORDER BY rl.weak_score DESC, rl.ready_at DESC
+		LIMIT 1
+	`, userID, level)
+}
+
+// nextLesson finds the first incomplete lesson at or below the user's level, required lessons
+// first and, within a level, following the user's preferred track order and then their cohort's
+// sequence
+func (s *RecommendationService) nextLesson(userID uuid.UUID, level int) (*models.NextAction, error) {
+	trackOrder, err := s.settingsService.preferredLessonOrders(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.lookup("next_lesson", "Next lesson on your path", "/ngs/lessons/%s", `
+		SELECT l.id, l.level_id, l.title
+		FROM lessons l
+		WHERE l.level_id <= $2
+			AND `+lessonVisibleSQL("l", "$1")+`
+			AND NOT EXISTS (
+				SELECT 1 FROM lesson_completions lc WHERE lc.lesson_id = l.id AND lc.user_id = $1
+			)
+		ORDER BY l.is_required DESC, l.level_id ASC,
+			COALESCE(array_position($3::int[], l.lesson_order), 2147483647) ASC,
+			`+cohortLessonOrderSQL("l", "$1")+` ASC, l.lesson_order ASC
+		LIMIT 1
+	`, userID, level, pq.Array(trackOrder))
+}
+
+func (s *RecommendationService) newChallenge(userID uuid.UUID, level int) (*models.NextAction, error) {
+	return s.lookup("start_challenge", "Put this level into practice", "/ngs/challenges/%s", `
+		SELECT c.id, c.level_id, c.title
+		FROM challenges c
+		WHERE c.level_id = $2 AND c.is_active = true
+			AND `+contentAgeFilterSQL("c.min_age_band", "$1")+`
+			AND NOT EXISTS (
+				SELECT 1 FROM challenge_submissions cs WHERE cs.challenge_id = c.id AND cs.user_id = $1
+			)
+		ORDER BY c.created_at ASC
+		LIMIT 1
+	`, userID, level)
+}
+
+// levelExam suggests certifying the current level once its material is done
+func (s *RecommendationService) levelExam(userID uuid.UUID, level int) (*models.NextAction, error) {
+	var certified bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM level_certifications WHERE user_id = $1 AND level_number = $2)
+	`, userID, level).Scan(&certified)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check certification: %w", err)
+	}
+	if certified {
+		return nil, nil
+	}
+
+	return &models.NextAction{
+		Type:        "take_exam",
+		Title:       fmt.Sprintf("Level %d exam", level),
+		Reason:      "You've finished this level's material; certify it",
+		LevelNumber: level,
+		Endpoint:    fmt.Sprintf("/ngs/levels/%d/exam", level),
+	}, nil
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ChallengeTypeReflection challenges are answered with a written reflection and graded by the
+// reflection quality scorer rather than the code validator
+const ChallengeTypeReflection = "reflection"
+
+// Reflection rubric criteria, each scored by the reflection quality scorer
+const (
+	ReflectionDepth         = "Depth"
+	ReflectionSpecificity   = "Specificity"
+	ReflectionSelfAwareness = "Self-awareness"
+	ReflectionNextSteps     = "Next steps"
+	ReflectionRelevance     = "Relevance"
+)
+
+// defaultReflectionRubric scores reflection challenges whose metadata has no rubric
+var defaultReflectionRubric = []models.RubricCriterion{
+	{Name: ReflectionDepth, Description: "Develops its points beyond a sentence or two", MaxPoints: 10},
+	{Name: ReflectionSpecificity, Description: "Cites concrete examples, code or numbers", MaxPoints: 10},
+	{Name: ReflectionSelfAwareness, Description: "Says what was learned, noticed or struggled with", MaxPoints: 10},
+	{Name: ReflectionNextSteps, Description: "Says what to practise or try next", MaxPoints: 10},
+	{Name: ReflectionRelevance, Description: "Addresses the challenge prompt", MaxPoints: 10},
+}
+
+// Phrases the scorer counts as evidence for a criterion, matched case-insensitively
+var (
+	specificityMarkers   = []string{"for example", "for instance", "e.g.", "such as", "`", "()", "because", "specifically"}
+	selfAwarenessMarkers = []string{"i learned", "i realized", "i realised", "i noticed", "i found", "i struggled", "i was", "i didn't", "i did not", "mistake", "confus", "surprised"}
+	nextStepMarkers      = []string{"next time", "next", "i will", "i'll", "plan to", "going to", "want to", "improve", "practice", "practise"}
+)
+
+var reflectionGrades = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ngs_reflection_challenge_grades_total",
+		Help: "Reflection challenge submissions graded by the reflection quality scorer, by result (passed or failed).",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	prometheus.MustRegister(reflectionGrades)
+}
+
+// ReflectionQuality is a simplified quality assessment of a reflection, from 0 to 1.
+// In production, this would integrate with an AI model.
+func ReflectionQuality(text string) float64 {
+	length := len(text)
+
+	// Basic heuristics
+	if length < 50 {
+		return 0.3
+	} else if length < 150 {
+		return 0.6
+	} else if length < 300 {
+		return 0.8
+	}
+	return 0.9
+}
+
+// ReflectionRubric returns a reflection challenge's metadata.rubric, or the default rubric when
+// it has none. Only the criteria the scorer knows are kept, so a rubric can reweight or drop
+// criteria but not add new ones.
+func ReflectionRubric(challenge *models.Challenge) []models.RubricCriterion {
+	var metadata struct {
+		Rubric []models.RubricCriterion `json:"rubric"`
+	}
+	if len(challenge.Metadata) > 0 {
+		if err := json.Unmarshal(challenge.Metadata, &metadata); err != nil {
+			log.Printf("Ignoring unreadable rubric of challenge %s: %v", challenge.ID, err)
+		}
+	}
+
+	var rubric []models.RubricCriterion
+	for _, criterion := range metadata.Rubric {
+		known := reflectionCriterion(criterion.Name)
+		if known == nil || criterion.MaxPoints <= 0 {
+			log.Printf("Ignoring reflection rubric criterion %q of challenge %s", criterion.Name, challenge.ID)
+			continue
+		}
+		criterion.Name = known.Name
+		if criterion.Description == "" {
+			criterion.Description = known.Description
+		}
+		rubric = append(rubric, criterion)
+	}
+	if len(rubric) == 0 {
+		return defaultReflectionRubric
+	}
+	return rubric
+}
+
+// reflectionCriterion finds the default rubric criterion named name, case-insensitively
+func reflectionCriterion(name string) *models.RubricCriterion {
+	for i := range defaultReflectionRubric {
+		if strings.EqualFold(strings.TrimSpace(name), defaultReflectionRubric[i].Name) {
+			return &defaultReflectionRubric[i]
+		}
+	}
+	return nil
+}
+
+// ReflectionMinQuality is the score, 0-100, a reflection must reach to pass the challenge: its
+// metadata.min_quality, or fallback when that is unset or out of range
+func ReflectionMinQuality(challenge *models.Challenge, fallback int) int {
+	var metadata struct {
+		MinQuality int `json:"min_quality"`
+	}
+	if len(challenge.Metadata) > 0 {
+		_ = json.Unmarshal(challenge.Metadata, &metadata)
+	}
+	if metadata.MinQuality > 0 && metadata.MinQuality <= 100 {
+		return metadata.MinQuality
+	}
+	return fallback
+}
+
+// ScoreReflection grades text against a reflection rubric. It returns the scores in rubric order
+// and the percentage of points earned.
+func ScoreReflection(challenge *models.Challenge, rubric []models.RubricCriterion, text string) ([]models.RubricScore, int) {
+	lower := strings.ToLower(text)
+
+	scores := make([]models.RubricScore, 0, len(rubric))
+	earned, possible := 0, 0
+	for _, criterion := range rubric {
+		var fraction float64
+		switch criterion.Name {
+		case ReflectionDepth:
+			fraction = ReflectionQuality(text)
+		case ReflectionSpecificity:
+			hits := countMarkers(lower, specificityMarkers)
+			if strings.IndexFunc(text, unicode.IsDigit) >= 0 {
+				hits++
+			}
+			fraction = math.Min(1, float64(hits)/3)
+		case ReflectionSelfAwareness:
+			fraction = math.Min(1, float64(countMarkers(lower, selfAwarenessMarkers))/3)
+		case ReflectionNextSteps:
+			fraction = math.Min(1, float64(countMarkers(lower, nextStepMarkers))/2)
+		case ReflectionRelevance:
+			fraction = promptOverlap(challenge, lower)
+		}
+
+		points := int(math.Round(fraction * float64(criterion.MaxPoints)))
+		scores = append(scores, models.RubricScore{
+			Criterion: criterion.Name,
+			Points:    points,
+			MaxPoints: criterion.MaxPoints,
+		})
+		earned += points
+		possible += criterion.MaxPoints
+	}
+
+	return scores, int(math.Round(float64(earned) * 100 / float64(possible)))
+}
+
+// countMarkers counts the markers that occur in text
+func countMarkers(text string, markers []string) int {
+	hits := 0
+	for _, marker := range markers {
+		if strings.Contains(text, marker) {
+			hits++
+		}
+	}
+	return hits
+}
+
+// promptOverlap is the share of the challenge prompt's keywords, up to ten of them, that the
+// reflection mentions. Prompts without keywords count as fully covered.
+func promptOverlap(challenge *models.Challenge, text string) float64 {
+	seen := map[string]bool{}
+	var keywords []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(challenge.Title+" "+challenge.Description), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		if utf8.RuneCountInString(word) >= 5 && !seen[word] {
+			seen[word] = true
+			keywords = append(keywords, word)
+		}
+	}
+	if len(keywords) == 0 {
+		return 1
+	}
+
+	matched := 0
+	for _, keyword := range keywords {
+		if strings.Contains(text, keyword) {
+			matched++
+		}
+	}
+	return math.Min(1, float64(matched)/math.Min(10, float64(len(keywords))))
+}
+
+// submitReflection grades a reflection challenge submission with the reflection quality scorer,
+// records it and awards XP when it reaches the challenge's minimum quality. It commits tx.
+func (s *ChallengeService) submitReflection(tx *sql.Tx, userID uuid.UUID, challenge *models.Challenge, text string) (*models.ChallengeSubmission, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("%w: reflection challenges take reflection_text", ErrEmptySubmission)
+	}
+	if max := s.config.SubmissionMaxCodeBytes; max > 0 && len(text) > max {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrSubmissionTooLarge, len(text), max)
+	}
+
+	scores, score := ScoreReflection(challenge, ReflectionRubric(challenge), text)
+	evaluation := models.DesignEvaluation{
+		Method:     models.EvaluationScorer,
+		Scores:     scores,
+		ReviewedAt: time.Now().UTC(),
+	}
+	evaluationJSON, _ := json.Marshal(evaluation)
+	minQuality := ReflectionMinQuality(challenge, s.config.ReflectionChallengeMinQuality)
+
+	submission := models.ChallengeSubmission{
+		Passed:     score >= minQuality,
+		Score:      score,
+		Status:     models.ReviewGraded,
+		Writeup:    text,
+		Evaluation: &evaluation,
+	}
+	submission.Feedback = reflectionFeedback(submission.Passed, score, minQuality, evaluation)
+
+	err := tx.QueryRow(`
+		INSERT INTO challenge_submissions (
+			id, user_id, challenge_id, submission_code, passed, score, feedback,
+			code_length, review_status, writeup, evaluation
+		)
+		VALUES ($1, $2, $3, '', $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, user_id, challenge_id, COALESCE(is_shared, false), submitted_at
+	`, uuid.New(), userID, challenge.ID, submission.Passed, score, submission.Feedback,
+		utf8.RuneCountInString(text), models.ReviewGraded, text, evaluationJSON).Scan(
+		&submission.ID, &submission.UserID, &submission.ChallengeID, &submission.IsShared, &submission.SubmittedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create submission: %w", err)
+	}
+
+	if submission.Passed {
+		if err := s.awardChallengeXP(tx, userID, challenge, score); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if submission.Passed {
+		invalidateLeaderboards(s.leaderboards)
+	}
+
+	result := "failed"
+	if submission.Passed {
+		result = "passed"
+	}
+	reflectionGrades.WithLabelValues(result).Inc()
+	return &submission, nil
+}
+
+// reflectionFeedback summarises a scored reflection, naming the weakest criterion
+func reflectionFeedback(passed bool, score, minQuality int, evaluation models.DesignEvaluation) string {
+	feedback := fmt.Sprintf("Your reflection scored %d%%; %d%% is needed to pass.", score, minQuality)
+	if passed {
+		feedback = fmt.Sprintf("Your reflection passed with %d%%.", score)
+	}
+	if weakest := weakestCriterion(evaluation.Scores); weakest >= 0 {
+		feedback += fmt.Sprintf(" Focus next on %s.", evaluation.Scores[weakest].Criterion)
+	}
+	return feedback
+}
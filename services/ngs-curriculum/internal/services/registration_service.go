@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"noble-ngs-curriculum/internal/clients/registry"
+	"noble-ngs-curriculum/internal/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RegistryServiceName is the name curriculum replicas register under
+const RegistryServiceName = "ngs-curriculum"
+
+var registryErrors = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ngs_registry_errors_total",
+		Help: "Failed service registry calls.",
+	},
+	[]string{"operation"},
+)
+
+func init() {
+	prometheus.MustRegister(registryErrors)
+}
+
+// RegistrationService announces this replica to the service registry, heartbeats while the
+// service runs and deregisters it on shutdown. A nil registry disables it.
+type RegistrationService struct {
+	registry registry.Registry
+	instance registry.Instance
+	interval time.Duration
+	running  sync.WaitGroup
+}
+
+// NewRegistrationService describes this replica from the config. The advertised host defaults
+// to the machine's hostname and the port to the listening port.
+func NewRegistrationService(reg registry.Registry, cfg *config.Config) *RegistrationService {
+	host := cfg.ServiceAdvertiseHost
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	port := cfg.ServiceAdvertisePort
+	if port == 0 {
+		port, _ = strconv.Atoi(cfg.Port)
+	}
+
+	return &RegistrationService{
+		registry: reg,
+		instance: registry.Instance{
+			ID:         fmt.Sprintf("%s-%s-%d", RegistryServiceName, host, port),
+			Name:       RegistryServiceName,
+			Address:    host,
+			Port:       port,
+			HealthPath: "/health",
+			Tags:       []string{"ngs", "curriculum"},
+		},
+		interval: time.Duration(cfg.RegistryHeartbeatSeconds) * time.Second,
+	}
+}
+
+// Instance returns the replica as announced to the registry
+func (s *RegistrationService) Instance() registry.Instance {
+	return s.instance
+}
+
+// ttl gives a replica three missed heartbeats before the registry marks it unhealthy
+func (s *RegistrationService) ttl() time.Duration {
+	return 3 * s.interval
+}
+
+// Start registers the replica in the background, then heartbeats on the configured interval
+// until ctx is cancelled. A failed registration or heartbeat is logged and retried on the next tick by
+// registering again, so the replica reappears after a registry restart.
+func (s *RegistrationService) Start(ctx context.Context) {
+	if s.registry == nil || s.interval <= 0 {
+		log.Println("Service registration disabled")
+		return
+	}
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		registered := s.register(ctx)
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if !registered {
+				registered = s.register(ctx)
+				continue
+			}
+			if err := s.registry.Heartbeat(ctx, s.instance.ID); err != nil && ctx.Err() == nil {
+				registryErrors.WithLabelValues("heartbeat").Inc()
+				log.Printf("Service registry heartbeat failed: %v", err)
+				registered = s.register(ctx)
+			}
+		}
+	}()
+	log.Printf("Service registration with %s running as %s, heartbeat every %s", s.registry.Name(), s.instance.ID, s.interval)
+}
+
+// register announces the replica and marks it passing, reporting whether both succeeded
+func (s *RegistrationService) register(ctx context.Context) bool {
+	if err := s.registry.Register(ctx, s.instance, s.ttl()); err != nil {
+		registryErrors.WithLabelValues("register").Inc()
+		log.Printf("Service registration failed: %v", err)
+		return false
+	}
+	if err := s.registry.Heartbeat(ctx, s.instance.ID); err != nil {
+		registryErrors.WithLabelValues("heartbeat").Inc()
+		log.Printf("Service registry heartbeat failed: %v", err)
+		return false
+	}
+	return true
+}
+
+// Wait blocks until the heartbeat loop started by Start has stopped
+func (s *RegistrationService) Wait() {
+	s.running.Wait()
+}
+
+// Deregister removes the replica from the registry. Call it before shutting the server down so
+// the gateway stops routing new requests here first.
+func (s *RegistrationService) Deregister(ctx context.Context) error {
+	if s.registry == nil || s.interval <= 0 {
+		return nil
+	}
+	if err := s.registry.Deregister(ctx, s.instance.ID); err != nil {
+		registryErrors.WithLabelValues("deregister").Inc()
+		return fmt.Errorf("failed to deregister %s: %w", s.instance.ID, err)
+	}
+	log.Printf("Deregistered %s", s.instance.ID)
+	return nil
+}
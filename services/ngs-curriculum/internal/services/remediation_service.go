@@ -0,0 +1,401 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"noble-ngs-curriculum/internal/clients/intelligence"
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/jobs"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	RemediationPending   = "pending"
+	RemediationReady     = "ready"
+	RemediationFailed    = "failed"
+	RemediationCompleted = "completed"
+
+	RemediationGenerated = "generated"
+	RemediationSelected  = "selected"
+
+	// JobRemediation is the job kind of remediation lesson generation
+	JobRemediation = "remediation"
+)
+
+var (
+	ErrRemediationNotFound = errors.New("remediation lesson not found")
+	ErrRemediationNotReady = errors.New("remediation lesson is not ready yet")
+)
+
+const (
+	// remediationTimeout bounds generating one remediation lesson
+	remediationTimeout = 2 * time.Minute
+	// remediationUserRole is the role generation runs as; remediation runs in the background on
+	// the learner's behalf
+	remediationUserRole = "student"
+)
+
+var remediationLessons = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ngs_remediation_lessons_total",
+		Help: "Remediation lessons by event: created, generated, selected, failed or completed.",
+	},
+	[]string{"event"},
+)
+
+func init() {
+	prometheus.MustRegister(remediationLessons)
+}
+
+// LessonGenerator generates lesson content; *intelligence.Client is one
+type LessonGenerator interface {
+	GenerateLesson(ctx context.Context, req intelligence.GenerateLessonRequest, userID, userEmail, userRole string) (*intelligence.GenerateLessonResponse, error)
+}
+
+// RemediationService gives learners a short optional lesson on each weak topic that reaches
+// REMEDIATION_THRESHOLD, and reports whether completing it helped their exam answers on the topic
+type RemediationService struct {
+	db        *database.DB
+	config    *config.Config
+	clock     Clock
+	generator LessonGenerator
+	// jobService runs generation as generation jobs, so a burst of weak topics does not flood
+	// the intelligence service
+	jobService *JobService
+}
+
+func NewRemediationService(db *database.DB, cfg *config.Config, clock Clock, generator LessonGenerator, jobService *JobService) *RemediationService {
+	s := &RemediationService{
+		db:         db,
+		config:     cfg,
+		clock:      clock,
+		generator:  generator,
+		jobService: jobService,
+	}
+	jobService.Register(JobRemediation, jobs.ClassGeneration, s.RunRemediation)
+	return s
+}
+
+// remediationJob is the payload of a remediation job, kept with its dead letter if it fails
+type remediationJob struct {
+	RemediationID uuid.UUID `json:"remediation_id"`
+}
+
+// NewRemediationOutcome compares exam answers on a topic before and after its remediation was
+// completed. It counts as improved when the share answered correctly went up.
+func NewRemediationOutcome(beforeAnswered, beforeCorrect, afterAnswered, afterCorrect int) *models.RemediationOutcome {
+	outcome := &models.RemediationOutcome{
+		Before: quizPerformance(beforeAnswered, beforeCorrect),
+		After:  quizPerformance(afterAnswered, afterCorrect),
+	}
+	if outcome.Before.Accuracy != nil && outcome.After.Accuracy != nil {
+		improved := *outcome.After.Accuracy > *outcome.Before.Accuracy
+		outcome.Improved = &improved
+	}
+	return outcome
+}
+
+func quizPerformance(answered, correct int) models.QuizPerformance {
+	performance := models.QuizPerformance{Answered: answered, Correct: correct}
+	if answered > 0 {
+		accuracy := float64(correct) / float64(answered)
+		performance.Accuracy = &accuracy
+	}
+	return performance
+}
+
+// Remediate creates a remediation lesson for each topic the user has none open for and queues
+// its generation. Failures are logged: remediation must not fail the refresh that triggered it.
+func (s *RemediationService) Remediate(userID uuid.UUID, topics []models.LearnerWeakTopic) {
+	for _, topic := range topics {
+		var id uuid.UUID
+		err := s.db.QueryRow(`
+			INSERT INTO remediation_lessons (user_id, topic, lesson_id, weak_score, created_at)
+			VALUES ($1, $2, (
+				SELECT l.id FROM lessons l
+				WHERE l.title = $2 AND `+lessonVisibleSQL("l", "$1")+`
+				ORDER BY l.level_id, l.lesson_order
+				LIMIT 1
+			), $3, $4)
+			ON CONFLICT (user_id, topic) WHERE completed_at IS NULL DO NOTHING
+			RETURNING id
+		`, userID, topic.Topic, topic.Score, s.clock.Now()).Scan(&id)
+		if err == sql.ErrNoRows {
+			// One is already open for this topic
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to create remediation for user %s on %q: %v", userID, topic.Topic, err)
+			continue
+		}
+		remediationLessons.WithLabelValues("created").Inc()
+
+		if err := s.jobService.Submit(JobRemediation, "remediate "+id.String(), remediationJob{RemediationID: id}); err != nil {
+			// Left pending; an admin can find it by its status
+			log.Printf("Remediation %s not queued: %v", id, err)
+		}
+	}
+}
+
+// RunRemediation generates one remediation lesson. If generation fails and the topic is named
+// after a lesson, that lesson is selected for review instead; otherwise the remediation is marked
+// failed and the error returned, so the job is kept as a dead letter for an admin to retry.
+func (s *RemediationService) RunRemediation(ctx context.Context, payload json.RawMessage) error {
+	var job remediationJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return fmt.Errorf("invalid remediation payload: %w", err)
+	}
+
+	var userID uuid.UUID
+	var topic string
+	var lessonID uuid.NullUUID
+	var lessonTitle, lessonDescription sql.NullString
+	var level int
+	err := s.db.QueryRow(`
+		SELECT rl.user_id, rl.topic, rl.lesson_id, l.title, l.description,
+			COALESCE(l.level_id, up.current_level, 1)
+		FROM remediation_lessons rl
+		LEFT JOIN lessons l ON l.id = rl.lesson_id
+		LEFT JOIN user_progress up ON up.user_id = rl.user_id
+		WHERE rl.id = $1 AND rl.status IN ('pending', 'failed')
+	`, job.RemediationID).Scan(&userID, &topic, &lessonID, &lessonTitle, &lessonDescription, &level)
+	if err == sql.ErrNoRows {
+		// Already ready, or deleted with its learner
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load remediation %s: %w", job.RemediationID, err)
+	}
+
+	genErr := s.generate(ctx, job.RemediationID, userID, topic, lessonDescription.String, level)
+	if genErr == nil {
+		remediationLessons.WithLabelValues(RemediationGenerated).Inc()
+		return nil
+	}
+	log.Printf("Remediation %s not generated: %v", job.RemediationID, genErr)
+
+	if lessonID.Valid {
+		_, err := s.db.Exec(`
+			UPDATE remediation_lessons
+			SET status = 'ready', source = 'selected', title = $2, error = $3, ready_at = $4
+			WHERE id = $1 AND status IN ('pending', 'failed')
+		`, job.RemediationID, lessonTitle.String, genErr.Error(), s.clock.Now())
+		if err != nil {
+			return fmt.Errorf("failed to select lesson for remediation %s: %w", job.RemediationID, err)
+		}
+		remediationLessons.WithLabelValues(RemediationSelected).Inc()
+		return nil
+	}
+
+	remediationLessons.WithLabelValues(RemediationFailed).Inc()
+	if _, err := s.db.Exec(`
+		UPDATE remediation_lessons SET status = 'failed', error = $2 WHERE id = $1 AND status = 'pending'
+	`, job.RemediationID, genErr.Error()); err != nil {
+		log.Printf("Failed to mark remediation %s failed: %v", job.RemediationID, err)
+	}
+	return genErr
+}
+
+// generate asks the intelligence service for a short lesson on topic and stores it
+func (s *RemediationService) generate(ctx context.Context, id, userID uuid.UUID, topic, lessonDescription string, level int) error {
+	ctx, cancel := context.WithTimeout(intelligence.WithOperation(ctx, intelligence.OperationRemediation), remediationTimeout)
+	defer cancel()
+
+	summary := fmt.Sprintf("A short remediation lesson on %q for a learner who keeps getting it wrong. "+
+		"Revisit the core idea from a different angle, address common misconceptions and end with a few quick checks.", topic)
+	if lessonDescription != "" {
+		summary += "\n\nThe original lesson: " + lessonDescription
+	}
+	constraints := intelligence.GenerationConstraints{
+		TargetMinutes:           s.config.RemediationTargetMinutes,
+		Prereqs:                 []string{},
+		RequireEthicsGuardrails: true,
+		IncludeAccessibility:    true,
+	}
+	resp, err := s.generator.GenerateLesson(ctx, intelligence.GenerateLessonRequest{
+		LessonSummary: summary,
+		LevelNumber:   level,
+		LearnerProfile: intelligence.LearnerProfile{
+			CurrentLevel: level,
+			WeakTopics:   []string{topic},
+			PriorLessons: []string{},
+			Preferences:  map[string]interface{}{},
+		},
+		Constraints: constraints,
+	}, userID.String(), "", remediationUserRole)
+	if err != nil {
+		return err
+	}
+	if err := ValidateGeneratedLesson(resp, constraints); err != nil {
+		quarantineGeneration(s.db, QuarantineSourceRemediation, id, userID, resp, err)
+		return err
+	}
+	// A lesson failing the guardrail checklist is not published; the failure is its report
+	if report := CheckGeneratedLesson(resp, constraints); !report.Passed {
+		return fmt.Errorf("%w: %s", ErrGuardrailsFailed, report)
+	}
+
+	metadata, err := json.Marshal(resp.StructuredLesson)
+	if err != nil {
+		return fmt.Errorf("failed to marshal remediation metadata: %w", err)
+	}
+	title := strings.TrimSpace(resp.StructuredLesson.Metadata.Title)
+	if title == "" {
+		title = "Refresher: " + topic
+	}
+	if len(title) > 255 {
+		title = strings.ToValidUTF8(title[:255], "")
+	}
+	_, err = s.db.Exec(`
+		UPDATE remediation_lessons
+		SET status = 'ready', source = 'generated', title = $2, content_markdown = $3, metadata = $4,
+			error = NULL, ready_at = $5
+		WHERE id = $1 AND status IN ('pending', 'failed')
+	`, id, title, resp.ContentMarkdown, metadata, s.clock.Now())
+	if err != nil {
+		return fmt.Errorf("failed to store remediation %s: %w", id, err)
+	}
+	return nil
+}
+
+// remediationSelect selects remediation lessons with the exam answers on their topic before and
+// after completion. content is the expression for the content and metadata columns, so listings
+// can leave them out.
+func remediationSelect(content string) string {
+	return `
+		SELECT rl.id, rl.user_id, rl.topic, rl.lesson_id, rl.source, rl.status, rl.title, ` + content + `,
+			rl.error, rl.weak_score, rl.created_at, rl.ready_at, rl.completed_at,
+			o.before_answered, o.before_correct, o.after_answered, o.after_correct
+		FROM remediation_lessons rl
+		LEFT JOIN LATERAL (
+			SELECT COUNT(*) FILTER (WHERE a.submitted_at < rl.completed_at) AS before_answered,
+				COUNT(*) FILTER (WHERE a.submitted_at < rl.completed_at AND a.correct) AS before_correct,
+				COUNT(*) FILTER (WHERE a.submitted_at >= rl.completed_at) AS after_answered,
+				COUNT(*) FILTER (WHERE a.submitted_at >= rl.completed_at AND a.correct) AS after_correct
+			FROM (` + examAnswersSQL("rl.user_id") + `) a
+			WHERE a.topic = rl.topic
+		) o ON rl.completed_at IS NOT NULL`
+}
+
+func scanRemediation(row rowScanner) (*models.RemediationLesson, error) {
+	var r models.RemediationLesson
+	var lessonID uuid.NullUUID
+	var source, title, content, remediationErr sql.NullString
+	var metadata []byte
+	var readyAt, completedAt sql.NullTime
+	var beforeAnswered, beforeCorrect, afterAnswered, afterCorrect sql.NullInt64
+	err := row.Scan(&r.ID, &r.UserID, &r.Topic, &lessonID, &source, &r.Status, &title, &content, &metadata,
+		&remediationErr, &r.WeakScore, &r.CreatedAt, &readyAt, &completedAt,
+		&beforeAnswered, &beforeCorrect, &afterAnswered, &afterCorrect)
+	if err != nil {
+		return nil, err
+	}
+	if lessonID.Valid {
+		r.LessonID = &lessonID.UUID
+	}
+	if source.Valid {
+		r.Source = &source.String
+	}
+	if title.Valid {
+		r.Title = &title.String
+	}
+	if content.Valid {
+		r.ContentMarkdown = &content.String
+	}
+	if len(metadata) > 0 {
+		r.Metadata = metadata
+	}
+	if remediationErr.Valid {
+		r.Error = &remediationErr.String
+	}
+	if readyAt.Valid {
+		r.ReadyAt = &readyAt.Time
+	}
+	if completedAt.Valid {
+		r.CompletedAt = &completedAt.Time
+		r.Outcome = NewRemediationOutcome(int(beforeAnswered.Int64), int(beforeCorrect.Int64), int(afterAnswered.Int64), int(afterCorrect.Int64))
+	}
+
+	// A selected lesson is studied where it lives; everything else opens here
+	r.Endpoint = "/ngs/remediation/" + r.ID.String()
+	if r.Source != nil && *r.Source == RemediationSelected && r.LessonID != nil {
+		r.Endpoint = "/ngs/lessons/" + r.LessonID.String()
+	}
+	return &r, nil
+}
+
+// ListRemediation returns up to limit of the user's remediation lessons, open ones first and then
+// newest first, without their content
+func (s *RemediationService) ListRemediation(userID uuid.UUID, limit int) ([]models.RemediationLesson, error) {
+	rows, err := s.db.Query(remediationSelect("NULL::text, NULL::jsonb")+`
+		WHERE rl.user_id = $1
+		ORDER BY rl.completed_at IS NOT NULL, rl.created_at DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remediation lessons: %w", err)
+	}
+	defer rows.Close()
+
+	lessons := []models.RemediationLesson{}
+	for rows.Next() {
+		r, err := scanRemediation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan remediation lesson: %w", err)
+		}
+		lessons = append(lessons, *r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read remediation lessons: %w", err)
+	}
+	return lessons, nil
+}
+
+// GetRemediation returns one of the user's remediation lessons with its content
+func (s *RemediationService) GetRemediation(userID, id uuid.UUID) (*models.RemediationLesson, error) {
+	r, err := scanRemediation(s.db.QueryRow(remediationSelect("rl.content_markdown, rl.metadata")+`
+		WHERE rl.id = $1 AND rl.user_id = $2
+	`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrRemediationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get remediation lesson: %w", err)
+	}
+	return r, nil
+}
+
+// CompleteRemediation marks a ready remediation lesson completed. Exam answers on its topic from
+// then on count towards its outcome. Completing it again changes nothing.
+func (s *RemediationService) CompleteRemediation(userID, id uuid.UUID) (*models.RemediationLesson, error) {
+	result, err := s.db.Exec(`
+		UPDATE remediation_lessons SET status = 'completed', completed_at = $3
+		WHERE id = $1 AND user_id = $2 AND status = 'ready'
+	`, id, userID, s.clock.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete remediation lesson: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		remediationLessons.WithLabelValues(RemediationCompleted).Inc()
+	}
+
+	r, err := s.GetRemediation(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if r.Status != RemediationCompleted {
+		return nil, ErrRemediationNotReady
+	}
+	return r, nil
+}
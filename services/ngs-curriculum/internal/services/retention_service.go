@@ -0,0 +1,356 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	RetentionChatSessions = "chat_session_archive"
+	RetentionStaleDrafts  = "stale_draft_prune"
+	RetentionXPMetadata   = "xp_metadata_rollup"
+	RetentionIdempotency  = "idempotency_key_prune"
+)
+
+var (
+	ErrUnknownRetentionPolicy  = errors.New("unknown retention policy")
+	ErrRetentionPolicyDisabled = errors.New("retention policy is disabled")
+)
+
+var (
+	retentionRows = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_retention_rows_total",
+			Help: "Rows archived, pruned or compacted by each retention policy.",
+		},
+		[]string{"policy"},
+	)
+
+	retentionRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_retention_runs_total",
+			Help: "Retention policy runs by outcome (success, error, skipped).",
+		},
+		[]string{"policy", "status"},
+	)
+
+	retentionRunDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ngs_retention_run_duration_seconds",
+			Help:    "Duration of retention policy runs.",
+			Buckets: prometheus.ExponentialBuckets(0.05, 4, 8),
+		},
+		[]string{"policy"},
+	)
+
+	retentionLastSuccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ngs_retention_last_success_timestamp_seconds",
+			Help: "Unix time of the last successful run of each retention policy.",
+		},
+		[]string{"policy"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(retentionRows, retentionRuns, retentionRunDuration, retentionLastSuccess)
+}
+
+// retentionBatch processes at most limit rows older than cutoff and returns how many it handled
+type retentionBatch func(tx *sql.Tx, cutoff time.Time, limit int) (int64, error)
+
+type retentionPolicy struct {
+	name        string
+	description string
+	days        int
+	batch       retentionBatch
+}
+
+type RetentionService struct {
+	db       *database.DB
+	clock    Clock
+	interval time.Duration
+	batch    int
+	policies []retentionPolicy
+
+	// runMu serializes runs on this instance; the advisory lock covers other instances
+	runMu    sync.Mutex
+	mu       sync.Mutex
+	lastRuns map[string]models.RetentionRun
+	running  sync.WaitGroup
+}
+
+func NewRetentionService(db *database.DB, cfg *config.Config, clock Clock) *RetentionService {
+	batch := cfg.RetentionBatchSize
+	if batch <= 0 {
+		batch = 1000
+	}
+	return &RetentionService{
+		db:       db,
+		clock:    clock,
+		interval: time.Duration(cfg.RetentionIntervalMinutes) * time.Minute,
+		batch:    batch,
+		policies: []retentionPolicy{
+			{RetentionChatSessions, "Moves tutor chat sessions that ended (or started, if never ended) before the cutoff to educator_chat_sessions_archive", cfg.ChatSessionArchiveDays, archiveChatSessions},
+			{RetentionStaleDrafts, "Deletes never-published lesson drafts untouched since the cutoff", cfg.StaleDraftDays, pruneStaleDrafts},
+			{RetentionXPMetadata, "Counts XP events older than the cutoff into monthly rollups and drops their metadata", cfg.XPMetadataRetentionDays, rollupXPMetadata},
+			{RetentionIdempotency, "Deletes Idempotency-Key records older than the cutoff", cfg.IdempotencyKeyDays, pruneIdempotencyKeys},
+		},
+		lastRuns: make(map[string]models.RetentionRun),
+	}
+}
+
+func archiveChatSessions(tx *sql.Tx, cutoff time.Time, limit int) (int64, error) {
+	var n int64
+	err := tx.QueryRow(`
+		WITH moved AS (
+			DELETE FROM educator_chat_sessions
+			WHERE id IN (
+				SELECT id FROM educator_chat_sessions
+				WHERE COALESCE(ended_at, created_at) < $1
+				ORDER BY created_at
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, user_id, lesson_id, session_id, message_count, created_at, ended_at
+		), archived AS (
+			INSERT INTO educator_chat_sessions_archive
+				(id, user_id, lesson_id, session_id, status, message_count, created_at, ended_at, archived_at)
+			SELECT id, user_id, lesson_id, session_id, 'archived', message_count, created_at,
+			       COALESCE(ended_at, NOW()), NOW()
+			FROM moved
+			RETURNING 1
+		)
+		SELECT COUNT(*) FROM archived
+	`, cutoff, limit).Scan(&n)
+	return n, err
+}
+
+// pruneStaleDrafts leaves drafts linked to a lesson alone: they hold changes to a published
+// lesson, and deleting them would make the next import create a duplicate
+func pruneStaleDrafts(tx *sql.Tx, cutoff time.Time, limit int) (int64, error) {
+	result, err := tx.Exec(`
+		DELETE FROM lesson_drafts
+		WHERE id IN (
+			SELECT id FROM lesson_drafts
+			WHERE status = 'draft' AND lesson_id IS NULL
+			  AND updated_at < $1
+			ORDER BY updated_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+	`, cutoff, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// rollupXPEventsSQL compacts the xp_events selected by batchQuery (id and created_at of
+// uncompacted events) into xp_event_rollups and selects how many events it compacted
+func rollupXPEventsSQL(batchQuery string) string {
+	return `
+		WITH batch AS (` + batchQuery + `
+		), compacted AS (
+			UPDATE xp_events x SET metadata = NULL, compacted_at = NOW()
+			FROM batch WHERE x.id = batch.id AND x.created_at = batch.created_at
+			RETURNING x.user_id, x.source, x.xp_awarded, x.created_at
+		), rolled AS (
+			INSERT INTO xp_event_rollups AS r (user_id, month, source, event_count, xp_total)
+			SELECT user_id, DATE_TRUNC('month', created_at)::date, COALESCE(source, ''), COUNT(*), SUM(xp_awarded)
+			FROM compacted
+			GROUP BY 1, 2, 3
+			ON CONFLICT (user_id, month, source) DO UPDATE
+			SET event_count = r.event_count + EXCLUDED.event_count,
+			    xp_total = r.xp_total + EXCLUDED.xp_total,
+			    updated_at = NOW()
+			RETURNING 1
+		)
+		SELECT COUNT(*) FROM compacted`
+}
+
+// rollupXPMetadata keeps the events themselves, which the XP curve simulation and activity
+// history still read, and drops only their metadata
+func rollupXPMetadata(tx *sql.Tx, cutoff time.Time, limit int) (int64, error) {
+	var n int64
+	err := tx.QueryRow(rollupXPEventsSQL(`
+			SELECT id, created_at FROM xp_events
+			WHERE compacted_at IS NULL AND created_at < $1
+			ORDER BY created_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED`), cutoff, limit).Scan(&n)
+	return n, err
+}
+
+// Start runs every enabled policy on the configured interval until ctx is cancelled
+func (s *RetentionService) Start(ctx context.Context) {
+	if s.interval <= 0 {
+		log.Println("Retention scheduler disabled")
+		return
+	}
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			s.RunAll(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Printf("Retention scheduler running every %s", s.interval)
+}
+
+// Wait blocks until the scheduler started by Start has stopped
+func (s *RetentionService) Wait() {
+	s.running.Wait()
+}
+
+// RunAll runs every enabled policy once
+func (s *RetentionService) RunAll(ctx context.Context) []models.RetentionRun {
+	runs := []models.RetentionRun{}
+	for _, p := range s.policies {
+		if p.days <= 0 {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		runs = append(runs, s.run(ctx, p))
+	}
+	return runs
+}
+
+// RunPolicy runs one enabled policy now
+func (s *RetentionService) RunPolicy(ctx context.Context, name string) (*models.RetentionRun, error) {
+	for _, p := range s.policies {
+		if p.name != name {
+			continue
+		}
+		if p.days <= 0 {
+			return nil, ErrRetentionPolicyDisabled
+		}
+		run := s.run(ctx, p)
+		return &run, nil
+	}
+	return nil, ErrUnknownRetentionPolicy
+}
+
+// run processes a policy in batches until a batch comes back short. Each batch commits on its
+// own so a long backlog never holds one large transaction.
+func (s *RetentionService) run(ctx context.Context, p retentionPolicy) models.RetentionRun {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	start := time.Now()
+	run := models.RetentionRun{Policy: p.name, StartedAt: s.clock.Now()}
+	var err error
+	for ctx.Err() == nil {
+		var n int64
+		var locked bool
+		n, locked, err = s.runBatch(p)
+		if err != nil {
+			break
+		}
+		if !locked {
+			// Another instance took over; only a run that did nothing counts as skipped
+			run.Skipped = run.Rows == 0
+			break
+		}
+		run.Rows += n
+		if n < int64(s.batch) {
+			break
+		}
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	elapsed := time.Since(start)
+	run.DurationMs = elapsed.Milliseconds()
+
+	retentionRows.WithLabelValues(p.name).Add(float64(run.Rows))
+	retentionRunDuration.WithLabelValues(p.name).Observe(elapsed.Seconds())
+	switch {
+	case err != nil:
+		run.Error = err.Error()
+		retentionRuns.WithLabelValues(p.name, "error").Inc()
+		log.Printf("Retention policy %s failed after %d rows: %v", p.name, run.Rows, err)
+	case run.Skipped:
+		retentionRuns.WithLabelValues(p.name, "skipped").Inc()
+	default:
+		retentionRuns.WithLabelValues(p.name, "success").Inc()
+		retentionLastSuccess.WithLabelValues(p.name).SetToCurrentTime()
+		if run.Rows > 0 {
+			log.Printf("Retention policy %s processed %d rows in %s", p.name, run.Rows, elapsed.Round(time.Millisecond))
+		}
+	}
+
+	s.mu.Lock()
+	s.lastRuns[p.name] = run
+	s.mu.Unlock()
+	return run
+}
+
+// runBatch runs one batch under a transaction-scoped advisory lock, reporting locked=false when
+// another instance is running the same policy
+func (s *RetentionService) runBatch(p retentionPolicy) (int64, bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	locked, err := database.TryXactLock(tx, "ngs_retention:"+p.name)
+	if err != nil {
+		return 0, false, err
+	}
+	if !locked {
+		return 0, false, nil
+	}
+
+	n, err := p.batch(tx, s.clock.Now().AddDate(0, 0, -p.days), s.batch)
+	if err != nil {
+		return 0, true, fmt.Errorf("failed to run %s batch: %w", p.name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, true, fmt.Errorf("failed to commit %s batch: %w", p.name, err)
+	}
+	return n, true, nil
+}
+
+// Policies returns the configured policies with their last run on this instance
+func (s *RetentionService) Policies() []models.RetentionPolicy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	policies := make([]models.RetentionPolicy, 0, len(s.policies))
+	for _, p := range s.policies {
+		policy := models.RetentionPolicy{
+			Name:          p.name,
+			Description:   p.description,
+			RetentionDays: p.days,
+			Enabled:       p.days > 0,
+		}
+		if run, ok := s.lastRuns[p.name]; ok {
+			policy.LastRun = &run
+		}
+		policies = append(policies, policy)
+	}
+	return policies
+}
@@ -0,0 +1,379 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sandboxRunGrace is added to the whole-run deadline for starting and tearing down the sandbox
+const sandboxRunGrace = 2 * time.Second
+
+var (
+	sandboxRuns = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ngs_sandbox_run_duration_seconds",
+			Help:    "Wall time of sandbox runs over all of a submission's test cases, by outcome.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+		},
+		[]string{"outcome"},
+	)
+
+	sandboxCaseCPU = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "ngs_sandbox_case_cpu_seconds",
+		Help:    "CPU time used by single test case runs.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+	})
+
+	sandboxCaseMemory = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "ngs_sandbox_case_memory_bytes",
+		Help:    "Peak memory of single test case runs.",
+		Buckets: prometheus.ExponentialBuckets(1<<20, 2, 12),
+	})
+
+	sandboxLimitsExceeded = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_sandbox_limit_exceeded_total",
+			Help: "Test case runs stopped or failed for exceeding a resource limit (time, cpu or memory).",
+		},
+		[]string{"limit"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(sandboxRuns, sandboxCaseCPU, sandboxCaseMemory, sandboxLimitsExceeded)
+}
+
+// Sandbox runs submission code in language against a challenge's test cases, reporting each
+// case's status, output, runtime, CPU time and peak memory. It should kill a case as soon as it
+// exceeds a limit, and stop when ctx ends. Counts and feedback are filled in by the caller.
+type Sandbox interface {
+	Run(ctx context.Context, language, code string, tests []models.TestCase, limits models.ResourceLimits) (*models.TestResults, error)
+}
+
+// NewSandbox returns the sandbox selected by name: "placeholder" (or empty) or "piston", which
+// needs the judge's URL
+func NewSandbox(name, url, apiKey string) (Sandbox, error) {
+	switch name {
+	case "", "placeholder":
+		return PlaceholderSandbox{}, nil
+	case "piston":
+		if url == "" {
+			return nil, fmt.Errorf("sandbox %q requires a URL", name)
+		}
+		return NewPistonSandbox(url, apiKey), nil
+	default:
+		return nil, fmt.Errorf("unknown sandbox %q", name)
+	}
+}
+
+// SetSandbox has submissions graded by sandbox
+func (s *ChallengeService) SetSandbox(sandbox Sandbox) {
+	s.sandbox = sandbox
+}
+
+// ChallengeLanguage returns the language a challenge's code runs in: its metadata.language, or
+// fallback when it has none
+func ChallengeLanguage(challenge *models.Challenge, fallback string) string {
+	var metadata struct {
+		Language string `json:"language"`
+	}
+	if len(challenge.Metadata) > 0 {
+		if err := json.Unmarshal(challenge.Metadata, &metadata); err != nil {
+			log.Printf("Ignoring unreadable language of challenge %s: %v", challenge.ID, err)
+		}
+	}
+	if language := strings.ToLower(strings.TrimSpace(metadata.Language)); language != "" {
+		return language
+	}
+	return fallback
+}
+
+// PlaceholderSandbox executes nothing and passes every case. It is for development without a
+// judge; graded results say so in their note.
+type PlaceholderSandbox struct{}
+
+func (PlaceholderSandbox) Run(ctx context.Context, language, code string, tests []models.TestCase, limits models.ResourceLimits) (*models.TestResults, error) {
+	results := &models.TestResults{
+		Tests: make([]models.TestCaseResult, 0, len(tests)),
+		Note:  "Code was not run: the placeholder sandbox passes every test by default",
+	}
+	for _, tc := range tests {
+		results.Tests = append(results.Tests, models.TestCaseResult{
+			Name:     tc.Name,
+			Status:   models.TestPassed,
+			Hidden:   tc.Hidden,
+			Input:    tc.Input,
+			Expected: tc.Expected,
+			Actual:   tc.Expected,
+		})
+	}
+	return results, nil
+}
+
+// resolveLimits fills a challenge's unset limits from the service defaults
+func (s *ChallengeService) resolveLimits(limits models.ResourceLimits) models.ResourceLimits {
+	if limits.TimeLimitMs <= 0 {
+		limits.TimeLimitMs = s.config.SandboxTimeLimitMs
+	}
+	if limits.CPULimitMs <= 0 {
+		limits.CPULimitMs = s.config.SandboxCPULimitMs
+	}
+	if limits.MemoryLimitMB <= 0 {
+		limits.MemoryLimitMB = s.config.SandboxMemoryLimitMB
+	}
+	return limits
+}
+
+// runSandbox runs code in language under limits. The sandbox enforces each case's limits
+// itself; the run as a whole is also cut off once every case could have used its full time, and
+// usage the sandbox reports over a limit fails the case.
+func (s *ChallengeService) runSandbox(language, code string, tests []models.TestCase, limits models.ResourceLimits) *models.TestResults {
+	ctx := context.Background()
+	if limits.TimeLimitMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(limits.TimeLimitMs*len(tests))*time.Millisecond+sandboxRunGrace)
+		defer cancel()
+	}
+
+	started := time.Now()
+	results, err := s.sandbox.Run(ctx, language, code, tests, limits)
+	outcome := "completed"
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || (err == nil && ctx.Err() != nil):
+		outcome = "timeout"
+		sandboxLimitsExceeded.WithLabelValues("time").Inc()
+		results = timedOutResults(tests)
+	case err != nil:
+		outcome = "error"
+		log.Printf("Sandbox run failed: %v", err)
+		results = &models.TestResults{Error: "Your code could not be run. Please try again."}
+	}
+	sandboxRuns.WithLabelValues(outcome).Observe(time.Since(started).Seconds())
+
+	EnforceLimits(results, limits)
+	assignPoints(results, tests)
+	return results
+}
+
+// assignPoints gives each result its case's points. Sandboxes report cases in order, so results
+// are matched to cases by position; a result that does not line up keeps the default of 1.
+func assignPoints(results *models.TestResults, tests []models.TestCase) {
+	for i := range results.Tests {
+		if i < len(tests) && tests[i].Name == results.Tests[i].Name {
+			results.Tests[i].Points = tests[i].Points
+		}
+	}
+}
+
+// timedOutResults marks every case timed out when the run as a whole was cut off
+func timedOutResults(tests []models.TestCase) *models.TestResults {
+	results := &models.TestResults{Tests: make([]models.TestCaseResult, 0, len(tests))}
+	for _, tc := range tests {
+		results.Tests = append(results.Tests, models.TestCaseResult{
+			Name:   tc.Name,
+			Status: models.TestTimeout,
+			Hidden: tc.Hidden,
+		})
+	}
+	return results
+}
+
+// EnforceLimits records the limits on results and fails cases whose reported usage went over
+// them: past the time or CPU limit is a timeout, past the memory limit is memory_exceeded.
+// Zero limits are not enforced.
+func EnforceLimits(results *models.TestResults, limits models.ResourceLimits) {
+	results.Limits = &limits
+	for i := range results.Tests {
+		r := &results.Tests[i]
+		sandboxCaseCPU.Observe(r.CPUMs / 1000)
+		sandboxCaseMemory.Observe(float64(r.MemoryKB) * 1024)
+
+		var exceeded string
+		switch {
+		case limits.MemoryLimitMB > 0 && r.MemoryKB > int64(limits.MemoryLimitMB)*1024:
+			exceeded = "memory"
+			r.Status = models.TestMemoryExceeded
+		case limits.TimeLimitMs > 0 && r.RuntimeMs > float64(limits.TimeLimitMs):
+			exceeded = "time"
+			r.Status = models.TestTimeout
+		case limits.CPULimitMs > 0 && r.CPUMs > float64(limits.CPULimitMs):
+			exceeded = "cpu"
+			r.Status = models.TestTimeout
+		case r.Status == models.TestTimeout:
+			exceeded = "time"
+		case r.Status == models.TestMemoryExceeded:
+			exceeded = "memory"
+		}
+		if exceeded != "" {
+			sandboxLimitsExceeded.WithLabelValues(exceeded).Inc()
+		}
+	}
+}
+
+// ParseTestCases reads a challenge's test_cases, naming unnamed cases "Test 1", "Test 2", ...
+// and giving cases without points 1. NULL test cases read as none.
+func ParseTestCases(raw json.RawMessage) ([]models.TestCase, error) {
+	tests := []models.TestCase{}
+	if len(raw) == 0 || string(raw) == "null" {
+		return tests, nil
+	}
+	if err := json.Unmarshal(raw, &tests); err != nil {
+		return nil, fmt.Errorf("invalid test cases: %w", err)
+	}
+	for i := range tests {
+		if tests[i].Name == "" {
+			tests[i].Name = fmt.Sprintf("Test %d", i+1)
+		}
+		if tests[i].Points < 0 {
+			return nil, fmt.Errorf("invalid test cases: %s has negative points", tests[i].Name)
+		}
+		if tests[i].Points == 0 {
+			tests[i].Points = 1
+		}
+	}
+	return tests, nil
+}
+
+// GradeTestResults counts the cases, credits each passed case with its points (1 when unset),
+// writes each case's feedback and returns the score: the percentage of points earned, 0 when
+// grading could not run
+func GradeTestResults(results *models.TestResults) int {
+	if results.Tests == nil {
+		results.Tests = []models.TestCaseResult{}
+	}
+	results.TotalTests = len(results.Tests)
+	results.PassedTests = 0
+	results.EarnedPoints, results.MaxPoints = 0, 0
+	for i := range results.Tests {
+		r := &results.Tests[i]
+		if r.Points <= 0 {
+			r.Points = 1
+		}
+		r.Passed = r.Status == models.TestPassed
+		r.PointsEarned = 0
+		if r.Passed {
+			r.PointsEarned = r.Points
+			results.PassedTests++
+		}
+		results.EarnedPoints += r.PointsEarned
+		results.MaxPoints += r.Points
+		r.Feedback = testCaseFeedback(*r, results.Limits)
+	}
+	results.FailedTests = results.TotalTests - results.PassedTests
+
+	if results.Error != "" || results.MaxPoints == 0 {
+		return 0
+	}
+	return (results.EarnedPoints * 100) / results.MaxPoints
+}
+
+// testCaseFeedback tells the learner what happened in one case without revealing hidden cases
+func testCaseFeedback(r models.TestCaseResult, limits *models.ResourceLimits) string {
+	switch r.Status {
+	case models.TestPassed:
+		if r.RuntimeMs > 0 {
+			return fmt.Sprintf("Passed in %.1f ms", r.RuntimeMs)
+		}
+		return "Passed"
+	case models.TestTimeout:
+		if limits != nil && limits.CPULimitMs > 0 && r.CPUMs > float64(limits.CPULimitMs) {
+			return fmt.Sprintf("Used %.0f ms of CPU, over the %d ms limit", r.CPUMs, limits.CPULimitMs)
+		}
+		if limits != nil && limits.TimeLimitMs > 0 {
+			return fmt.Sprintf("Ran past the %d ms time limit", limits.TimeLimitMs)
+		}
+		return "Ran past the time limit"
+	case models.TestMemoryExceeded:
+		if limits != nil && limits.MemoryLimitMB > 0 {
+			if r.MemoryKB > 0 {
+				return fmt.Sprintf("Used %.1f MB, over the %d MB memory limit", float64(r.MemoryKB)/1024, limits.MemoryLimitMB)
+			}
+			return fmt.Sprintf("Went over the %d MB memory limit", limits.MemoryLimitMB)
+		}
+		return "Went over the memory limit"
+	case models.TestError:
+		if r.Hidden || r.Output == "" {
+			return "Your code raised an error"
+		}
+		line, _, _ := strings.Cut(strings.TrimSpace(r.Output), "\n")
+		return "Your code raised an error: " + line
+	default:
+		if r.Hidden {
+			return "Failed a hidden test case"
+		}
+		if len(r.Expected) > 0 {
+			actual := string(r.Actual)
+			if actual == "" {
+				actual = "no output"
+			}
+			return fmt.Sprintf("Expected %s, got %s", r.Expected, actual)
+		}
+		return "Failed"
+	}
+}
+
+// firstFailedTest returns the name of the first case that did not pass, or ""
+func firstFailedTest(results *models.TestResults) string {
+	if results == nil {
+		return ""
+	}
+	for _, r := range results.Tests {
+		if r.Status != models.TestPassed {
+			return r.Name
+		}
+	}
+	return ""
+}
+
+// RedactHiddenTests strips the input and output of hidden cases before results go to learners
+func RedactHiddenTests(results *models.TestResults) *models.TestResults {
+	if results == nil {
+		return nil
+	}
+	redacted := *results
+	redacted.Tests = make([]models.TestCaseResult, len(results.Tests))
+	for i, r := range results.Tests {
+		if r.Hidden {
+			r.Input, r.Expected, r.Actual, r.Output = nil, nil, nil, ""
+		}
+		redacted.Tests[i] = r
+	}
+	return &redacted
+}
+
+// decodeTestResults reads stored test results. Results stored before they had a schema decode
+// with their counts and no cases, and results stored before partial credit count a point a
+// case; NULL or unreadable results decode as nil.
+func decodeTestResults(data []byte) *models.TestResults {
+	if len(data) == 0 {
+		return nil
+	}
+	var results models.TestResults
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil
+	}
+	if results.Tests == nil {
+		results.Tests = []models.TestCaseResult{}
+	}
+	if results.MaxPoints == 0 {
+		results.EarnedPoints, results.MaxPoints = results.PassedTests, results.TotalTests
+		for i := range results.Tests {
+			r := &results.Tests[i]
+			r.Points = 1
+			r.Passed = r.Status == models.TestPassed
+			if r.Passed {
+				r.PointsEarned = 1
+			}
+		}
+	}
+	return &results
+}
@@ -0,0 +1,429 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidSettings    = errors.New("invalid settings")
+	ErrRestrictedForMinor = errors.New("not available for minor accounts")
+)
+
+// Age bands, youngest first
+const (
+	AgeBandChild = "child" // Under 13
+	AgeBandTeen  = "teen"  // 13-17
+	AgeBandAdult = "adult"
+)
+
+var ageBandRank = map[string]int{
+	AgeBandChild: 0,
+	AgeBandTeen:  1,
+	AgeBandAdult: 2,
+}
+
+// IsMinorAgeBand reports whether an age band gets minor-account restrictions
+func IsMinorAgeBand(band string) bool {
+	return band == AgeBandChild || band == AgeBandTeen
+}
+
+// LessonTracks maps track keys to the lesson_order each track occupies within a level
+var LessonTracks = map[string]int{
+	"core":             1,
+	"computer_science": 2,
+	"data_science":     3,
+	"ethical_ai":       4,
+	"ml_engineering":   5,
+}
+
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)
+
+// Learning preference values; balanced is the default for both styles
+var (
+	exampleStyles = []string{"examples", "balanced", "theory"}
+	codeStyles    = []string{"code_heavy", "balanced", "conceptual"}
+	// programmingLanguages are the languages examples can be written in; python is the default
+	programmingLanguages = []string{"python", "javascript", "typescript", "java", "c", "cpp", "csharp", "go", "rust", "r", "julia"}
+)
+
+// LearningPreferenceOptions returns the values each learning preference accepts
+func LearningPreferenceOptions() models.LearningPreferenceOptions {
+	return models.LearningPreferenceOptions{
+		Examples:             exampleStyles,
+		CodeStyle:            codeStyles,
+		ProgrammingLanguages: programmingLanguages,
+	}
+}
+
+// minorUserSQL is true when the user bound to $1 has a minor age band
+const minorUserSQL = `EXISTS (SELECT 1 FROM user_settings WHERE user_id = $1 AND age_band IN ('child', 'teen'))`
+
+// ageBandRankSQL ranks an age band expression youngest first; unknown or NULL bands rank as child
+func ageBandRankSQL(expr string) string {
+	return fmt.Sprintf("(CASE %s WHEN 'adult' THEN 2 WHEN 'teen' THEN 1 ELSE 0 END)", expr)
+}
+
+// contentAgeFilterSQL is true when content with the given min_age_band column is suitable for the
+// user bound to userParam. The user's own band applies (adult when unset) unless one of their
+// cohorts has an educator override allowing an older band.
+func contentAgeFilterSQL(column, userParam string) string {
+	return fmt.Sprintf(`%s <= GREATEST(
+		COALESCE((SELECT %s FROM user_settings us WHERE us.user_id = %s), 2),
+		COALESCE((SELECT MAX(%s) FROM cohort_members cm JOIN cohorts co ON co.id = cm.cohort_id
+			WHERE cm.user_id = %s AND co.content_age_band IS NOT NULL), 0)
+	)`, ageBandRankSQL(column), ageBandRankSQL("us.age_band"), userParam, ageBandRankSQL("co.content_age_band"), userParam)
+}
+
+// userTimezoneSQL resolves the timezone of the user bound to $1, defaulting to UTC
+const userTimezoneSQL = `COALESCE((SELECT timezone FROM user_settings WHERE user_id = $1), 'UTC')`
+
+type SettingsService struct {
+	db *database.DB
+}
+
+func NewSettingsService(db *database.DB) *SettingsService {
+	return &SettingsService{
+		db: db,
+	}
+}
+
+// DefaultUserSettings returns the settings a user has before saving any; they match the table defaults
+func DefaultUserSettings(userID uuid.UUID) *models.UserSettings {
+	return &models.UserSettings{
+		UserID:            userID,
+		ShowOnLeaderboard: true,
+		PublicProfile:     false,
+		Locale:            "en",
+		Timezone:          "UTC",
+		AgeBand:           AgeBandAdult,
+		Notifications: models.NotificationPreferences{
+			WeeklyDigest:     true,
+			StreakReminders:  true,
+			ChallengeUpdates: true,
+			PushEnabled:      false,
+		},
+		Content: models.ContentPreferences{
+			PreferredTrackOrder: []string{},
+		},
+		Learning: models.LearningPreferences{
+			Examples:            "balanced",
+			CodeStyle:           "balanced",
+			ProgrammingLanguage: "python",
+		},
+	}
+}
+
+const settingsColumns = `user_id, show_on_leaderboard, public_profile, locale, timezone,
+	notification_preferences, content_preferences, updated_at, age_band, deactivated_at,
+	learning_preferences`
+
+func scanSettings(row rowScanner) (*models.UserSettings, error) {
+	var settings models.UserSettings
+	var notifications, content, learning []byte
+	var updatedAt time.Time
+	var deactivatedAt sql.NullTime
+
+	err := row.Scan(
+		&settings.UserID, &settings.ShowOnLeaderboard, &settings.PublicProfile,
+		&settings.Locale, &settings.Timezone, &notifications, &content, &updatedAt,
+		&settings.AgeBand, &deactivatedAt, &learning,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defaults := DefaultUserSettings(settings.UserID)
+	settings.Notifications = defaults.Notifications
+	settings.Content = defaults.Content
+	settings.Learning = defaults.Learning
+	if err := json.Unmarshal(notifications, &settings.Notifications); err != nil {
+		return nil, fmt.Errorf("failed to decode notification preferences: %w", err)
+	}
+	if err := json.Unmarshal(content, &settings.Content); err != nil {
+		return nil, fmt.Errorf("failed to decode content preferences: %w", err)
+	}
+	if err := json.Unmarshal(learning, &settings.Learning); err != nil {
+		return nil, fmt.Errorf("failed to decode learning preferences: %w", err)
+	}
+	if settings.Content.PreferredTrackOrder == nil {
+		settings.Content.PreferredTrackOrder = []string{}
+	}
+	settings.UpdatedAt = &updatedAt
+	settings.IsMinor = IsMinorAgeBand(settings.AgeBand)
+	// Deactivated accounts get no notifications; their saved preferences come back on reactivation
+	if deactivatedAt.Valid {
+		settings.DeactivatedAt = &deactivatedAt.Time
+		settings.Notifications = models.NotificationPreferences{}
+	}
+
+	return &settings, nil
+}
+
+// GetSettings returns a user's settings, or the defaults if none were saved
+func (s *SettingsService) GetSettings(userID uuid.UUID) (*models.UserSettings, error) {
+	settings, err := scanSettings(s.db.QueryRow(`
+		SELECT `+settingsColumns+`
+		FROM user_settings
+		WHERE user_id = $1
+	`, userID))
+	if err == sql.ErrNoRows {
+		return DefaultUserSettings(userID), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+// UpdateSettings applies a partial update, creating the settings row on first write
+func (s *SettingsService) UpdateSettings(userID uuid.UUID, req models.UpdateSettingsRequest) (*models.UserSettings, error) {
+	if err := ValidateSettingsUpdate(req); err != nil {
+		return nil, err
+	}
+
+	notificationsPatch := []byte(`{}`)
+	if req.Notifications != nil {
+		notificationsPatch, _ = json.Marshal(req.Notifications)
+	}
+	contentPatch := []byte(`{}`)
+	if req.Content != nil {
+		if req.Content.PreferredTrackOrder == nil {
+			req.Content.PreferredTrackOrder = []string{}
+		}
+		contentPatch, _ = json.Marshal(req.Content)
+	}
+	learningPatch := []byte(`{}`)
+	if req.Learning != nil {
+		learningPatch, _ = json.Marshal(req.Learning)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO user_settings (user_id)
+		VALUES ($1)
+		ON CONFLICT (user_id) DO NOTHING
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create settings: %w", err)
+	}
+
+	var currentBand string
+	err = tx.QueryRow(`SELECT age_band FROM user_settings WHERE user_id = $1 FOR UPDATE`, userID).Scan(&currentBand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock settings: %w", err)
+	}
+	if err := checkMinorSettings(currentBand, req); err != nil {
+		return nil, err
+	}
+
+	settings, err := scanSettings(tx.QueryRow(`
+		UPDATE user_settings SET
+			show_on_leaderboard = COALESCE($2, show_on_leaderboard),
+			public_profile = COALESCE($3, public_profile),
+			locale = COALESCE($4, locale),
+			timezone = COALESCE($5, timezone),
+			notification_preferences = notification_preferences || $6::jsonb,
+			content_preferences = content_preferences || $7::jsonb,
+			age_band = COALESCE($8, age_band),
+			learning_preferences = learning_preferences || $9::jsonb,
+			updated_at = NOW()
+		WHERE user_id = $1
+		RETURNING `+settingsColumns,
+		userID, req.ShowOnLeaderboard, req.PublicProfile, req.Locale, req.Timezone,
+		notificationsPatch, contentPatch, req.AgeBand, learningPatch,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update settings: %w", err)
+	}
+
+	if settings.IsMinor && !IsMinorAgeBand(currentBand) {
+		if err := applyMinorRestrictions(tx, userID); err != nil {
+			return nil, err
+		}
+		settings.ShowOnLeaderboard = false
+		settings.PublicProfile = false
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+// checkMinorSettings enforces age-band rules: bands can only be lowered by the user
+// (raising one needs an administrator) and minors cannot make themselves public
+func checkMinorSettings(currentBand string, req models.UpdateSettingsRequest) error {
+	band := currentBand
+	if req.AgeBand != nil {
+		if ageBandRank[*req.AgeBand] > ageBandRank[currentBand] {
+			return fmt.Errorf("%w: age band can only be raised by an administrator", ErrInvalidSettings)
+		}
+		band = *req.AgeBand
+	}
+
+	if IsMinorAgeBand(band) {
+		if req.ShowOnLeaderboard != nil && *req.ShowOnLeaderboard {
+			return fmt.Errorf("%w: leaderboard display", ErrRestrictedForMinor)
+		}
+		if req.PublicProfile != nil && *req.PublicProfile {
+			return fmt.Errorf("%w: public profile", ErrRestrictedForMinor)
+		}
+	}
+
+	return nil
+}
+
+// applyMinorRestrictions hides everything a user had made public when their account becomes a minor account
+func applyMinorRestrictions(tx *sql.Tx, userID uuid.UUID) error {
+	_, err := tx.Exec(`
+		UPDATE user_settings SET show_on_leaderboard = false, public_profile = false
+		WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to restrict settings: %w", err)
+	}
+
+	_, err = tx.Exec(`UPDATE user_reflections SET is_public = false WHERE user_id = $1 AND is_public = true`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to hide reflections: %w", err)
+	}
+
+	_, err = tx.Exec(`UPDATE challenge_submissions SET is_shared = false WHERE user_id = $1 AND is_shared = true`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to unshare submissions: %w", err)
+	}
+
+	return nil
+}
+
+// ValidateSettingsUpdate checks age band, locale, timezone, track order and learning preference
+// values in a settings update
+func ValidateSettingsUpdate(req models.UpdateSettingsRequest) error {
+	if req.Locale != nil && !localePattern.MatchString(*req.Locale) {
+		return fmt.Errorf("%w: locale must look like \"en\" or \"en-US\"", ErrInvalidSettings)
+	}
+
+	if req.AgeBand != nil {
+		if _, ok := ageBandRank[*req.AgeBand]; !ok {
+			return fmt.Errorf("%w: age_band must be child, teen or adult", ErrInvalidSettings)
+		}
+	}
+
+	if req.Timezone != nil {
+		if *req.Timezone == "" || *req.Timezone == "Local" {
+			return fmt.Errorf("%w: timezone must be an IANA zone name", ErrInvalidSettings)
+		}
+		if _, err := time.LoadLocation(*req.Timezone); err != nil {
+			return fmt.Errorf("%w: unknown timezone %q", ErrInvalidSettings, *req.Timezone)
+		}
+	}
+
+	if req.Content != nil {
+		seen := map[string]bool{}
+		for _, track := range req.Content.PreferredTrackOrder {
+			if _, ok := LessonTracks[track]; !ok {
+				return fmt.Errorf("%w: unknown track %q", ErrInvalidSettings, track)
+			}
+			if seen[track] {
+				return fmt.Errorf("%w: track %q listed twice", ErrInvalidSettings, track)
+			}
+			seen[track] = true
+		}
+	}
+
+	if req.Learning != nil {
+		if err := checkOption("examples", req.Learning.Examples, exampleStyles); err != nil {
+			return err
+		}
+		if err := checkOption("code_style", req.Learning.CodeStyle, codeStyles); err != nil {
+			return err
+		}
+		if err := checkOption("programming_language", req.Learning.ProgrammingLanguage, programmingLanguages); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkOption accepts an unset value or one of options
+func checkOption(field string, value *string, options []string) error {
+	if value == nil {
+		return nil
+	}
+	for _, option := range options {
+		if *value == option {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s must be one of %s", ErrInvalidSettings, field, strings.Join(options, ", "))
+}
+
+// GetLearningPreferences returns a user's learning preferences and the values they can choose
+func (s *SettingsService) GetLearningPreferences(userID uuid.UUID) (*models.LearningPreferencesResponse, error) {
+	settings, err := s.GetSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.LearningPreferencesResponse{Preferences: settings.Learning, Options: LearningPreferenceOptions()}, nil
+}
+
+// UpdateLearningPreferences changes the learning preferences that are set in patch
+func (s *SettingsService) UpdateLearningPreferences(userID uuid.UUID, patch models.LearningPreferencesPatch) (*models.LearningPreferencesResponse, error) {
+	settings, err := s.UpdateSettings(userID, models.UpdateSettingsRequest{Learning: &patch})
+	if err != nil {
+		return nil, err
+	}
+	return &models.LearningPreferencesResponse{Preferences: settings.Learning, Options: LearningPreferenceOptions()}, nil
+}
+
+// LearnerPreferences returns a user's learning preferences as the intelligence service's learner
+// profile preferences
+func (s *SettingsService) LearnerPreferences(userID uuid.UUID) (map[string]interface{}, error) {
+	settings, err := s.GetSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+	return LearnerProfilePreferences(settings.Learning), nil
+}
+
+// LearnerProfilePreferences converts learning preferences to learner profile preferences
+func LearnerProfilePreferences(prefs models.LearningPreferences) map[string]interface{} {
+	return map[string]interface{}{
+		"examples":             prefs.Examples,
+		"code_style":           prefs.CodeStyle,
+		"programming_language": prefs.ProgrammingLanguage,
+	}
+}
+
+// preferredLessonOrders converts a user's track order into lesson_order values for ranking queries
+func (s *SettingsService) preferredLessonOrders(userID uuid.UUID) ([]int64, error) {
+	settings, err := s.GetSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]int64, 0, len(settings.Content.PreferredTrackOrder))
+	for _, track := range settings.Content.PreferredTrackOrder {
+		orders = append(orders, int64(LessonTracks[track]))
+	}
+	return orders, nil
+}
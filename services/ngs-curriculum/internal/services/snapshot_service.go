@@ -0,0 +1,245 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// SnapshotVersion is the bundle format written by Snapshot
+const SnapshotVersion = 1
+
+// snapshotTables are the tables holding a learner's curriculum state, keyed by user_id, in the
+// order they are restored. Rows referencing other tables (lessons, challenges, exams) need
+// those rows to exist in the target environment.
+var snapshotTables = []string{
+	"user_progress",
+	"user_settings",
+	"user_learning_paths",
+	"xp_events",
+	"xp_event_rollups",
+	"achievements",
+	"lesson_quiz_attempts",
+	"lesson_completions",
+	"mastery_stars",
+	"user_reflections",
+	"challenge_submissions",
+	"level_exams",
+	"level_certifications",
+	"duel_ratings",
+	"weak_topics",
+	"weak_topic_refreshes",
+	"remediation_lessons",
+}
+
+var (
+	ErrSnapshotsDisabled = errors.New("learner snapshots are not enabled")
+	ErrInvalidSnapshot   = errors.New("invalid learner snapshot")
+	ErrSnapshotSignature = errors.New("learner snapshot signature does not match")
+	ErrSnapshotRestore   = errors.New("learner snapshot cannot be restored here")
+)
+
+// SnapshotService takes and restores signed snapshots of a single learner's curriculum state,
+// e.g. before a risky manual fix or to move a learner between environments
+type SnapshotService struct {
+	db     *database.DB
+	config *config.Config
+	clock  Clock
+}
+
+func NewSnapshotService(db *database.DB, cfg *config.Config, clock Clock) *SnapshotService {
+	return &SnapshotService{
+		db:     db,
+		config: cfg,
+		clock:  clock,
+	}
+}
+
+// snapshotSignature returns the HMAC-SHA256 of the snapshot without its signature. Table rows
+// are compacted when marshaled, so reformatting the bundle's whitespace does not break it.
+func (s *SnapshotService) snapshotSignature(snapshot models.LearnerSnapshot) (string, error) {
+	if s.config.LearnerSnapshotKey == "" {
+		return "", ErrSnapshotsDisabled
+	}
+	snapshot.Signature = ""
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	mac := hmac.New(sha256.New, []byte(s.config.LearnerSnapshotKey))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// SignSnapshot sets the snapshot's signature
+func (s *SnapshotService) SignSnapshot(snapshot *models.LearnerSnapshot) error {
+	signature, err := s.snapshotSignature(*snapshot)
+	if err != nil {
+		return err
+	}
+	snapshot.Signature = signature
+	return nil
+}
+
+// VerifySnapshot checks a snapshot's signature and that it can be restored for userID into
+// this schema
+func (s *SnapshotService) VerifySnapshot(snapshot models.LearnerSnapshot, userID uuid.UUID) error {
+	expected, err := s.snapshotSignature(snapshot)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(snapshot.Signature))) {
+		return ErrSnapshotSignature
+	}
+
+	if snapshot.Version != SnapshotVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidSnapshot, snapshot.Version)
+	}
+	if snapshot.UserID != userID {
+		return fmt.Errorf("%w: the snapshot belongs to user %s", ErrSnapshotRestore, snapshot.UserID)
+	}
+	if snapshot.SchemaVersion > database.RequiredSchemaVersion {
+		return fmt.Errorf("%w: taken at schema version %d, newer than %d", ErrSnapshotRestore,
+			snapshot.SchemaVersion, database.RequiredSchemaVersion)
+	}
+	for table := range snapshot.Tables {
+		if !isSnapshotTable(table) {
+			return fmt.Errorf("%w: unknown table %q", ErrInvalidSnapshot, table)
+		}
+	}
+	return nil
+}
+
+func isSnapshotTable(table string) bool {
+	for _, t := range snapshotTables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
+
+// Snapshot returns a signed bundle of the learner's rows in every snapshot table, read from one
+// consistent view of the database
+func (s *SnapshotService) Snapshot(ctx context.Context, userID, adminID uuid.UUID, note string) (*models.LearnerSnapshot, error) {
+	if s.config.LearnerSnapshotKey == "" {
+		return nil, ErrSnapshotsDisabled
+	}
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	snapshot, err := s.snapshot(tx, userID, adminID, note)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Admin %s took a snapshot of user %s", adminID, userID)
+	return snapshot, nil
+}
+
+// snapshot reads and signs the learner's rows within tx
+func (s *SnapshotService) snapshot(tx *sql.Tx, userID, adminID uuid.UUID, note string) (*models.LearnerSnapshot, error) {
+	snapshot := &models.LearnerSnapshot{
+		Version:       SnapshotVersion,
+		UserID:        userID,
+		SchemaVersion: database.RequiredSchemaVersion,
+		CreatedAt:     s.clock.Now(),
+		CreatedBy:     adminID,
+		Note:          strings.TrimSpace(note),
+		Tables:        make(map[string]json.RawMessage, len(snapshotTables)),
+	}
+	for _, table := range snapshotTables {
+		var rows []byte
+		err := tx.QueryRow(fmt.Sprintf(`
+			SELECT COALESCE(jsonb_agg(to_jsonb(t)), '[]'::jsonb)
+			FROM %s t
+			WHERE user_id = $1
+		`, pq.QuoteIdentifier(table)), userID).Scan(&rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", table, err)
+		}
+		snapshot.Tables[table] = rows
+	}
+
+	if err := s.SignSnapshot(snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Restore replaces the learner's rows in every snapshot table with the snapshot's, in one
+// transaction. Tables missing from the snapshot are left empty. The learner's state just before
+// the restore is returned as a snapshot of its own.
+func (s *SnapshotService) Restore(ctx context.Context, userID, adminID uuid.UUID, snapshot models.LearnerSnapshot) (*models.SnapshotRestore, error) {
+	if err := s.VerifySnapshot(snapshot, userID); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Hold the learner's progress row so XP awarded meanwhile waits for the restore
+	if _, err := tx.Exec(`SELECT 1 FROM user_progress WHERE user_id = $1 FOR UPDATE`, userID); err != nil {
+		return nil, fmt.Errorf("failed to lock progress: %w", err)
+	}
+	previous, err := s.snapshot(tx, userID, adminID, "Taken automatically before a restore")
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(snapshotTables) - 1; i >= 0; i-- {
+		table := pq.QuoteIdentifier(snapshotTables[i])
+		if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, table), userID); err != nil {
+			return nil, fmt.Errorf("failed to clear %s: %w", snapshotTables[i], err)
+		}
+	}
+
+	restore := &models.SnapshotRestore{UserID: userID, Rows: make(map[string]int64, len(snapshotTables)), Previous: previous}
+	for _, table := range snapshotTables {
+		rows, ok := snapshot.Tables[table]
+		if !ok {
+			continue
+		}
+		// Columns this schema lacks are ignored; columns the bundle lacks are NULL
+		quoted := pq.QuoteIdentifier(table)
+		result, err := tx.Exec(fmt.Sprintf(`
+			INSERT INTO %s
+			SELECT * FROM jsonb_populate_recordset(NULL::%s, $1::jsonb)
+			WHERE user_id = $2
+		`, quoted, quoted), []byte(rows), userID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to restore %s: %v", ErrSnapshotRestore, table, err)
+		}
+		restore.Rows[table], _ = result.RowsAffected()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit restore: %w", err)
+	}
+
+	log.Printf("Admin %s restored user %s from a snapshot taken %s by %s",
+		adminID, userID, snapshot.CreatedAt.Format(time.RFC3339), snapshot.CreatedBy)
+	return restore, nil
+}
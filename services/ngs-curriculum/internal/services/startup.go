@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// StartupBackoff returns the delay before retry number attempt (1-based): initial, doubling
+// each attempt, capped at max
+func StartupBackoff(attempt int, initial, max time.Duration) time.Duration {
+	delay := initial
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// WaitForDependency runs check until it succeeds, at most attempts times with exponential
+// backoff between tries, so the service only binds its port once name is usable
+func WaitForDependency(ctx context.Context, name string, attempts int, maxBackoff time.Duration, check func(context.Context) error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = check(ctx); err == nil {
+			if attempt > 1 {
+				log.Printf("%s ready after %d attempts", name, attempt)
+			}
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+
+		delay := StartupBackoff(attempt, time.Second, maxBackoff)
+		log.Printf("%s not ready (attempt %d/%d), retrying in %s: %v", name, attempt, attempts, delay, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	return fmt.Errorf("%s not ready after %d attempts: %w", name, attempts, err)
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrSubmissionTooLarge is returned for submission code over SUBMISSION_MAX_CODE_BYTES
+var ErrSubmissionTooLarge = errors.New("submission code is too large")
+
+var submissionBlobs = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ngs_submission_blobs_total",
+		Help: "Submission code and test results written to or read from the blob store, by kind, op and result.",
+	},
+	[]string{"kind", "op", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(submissionBlobs)
+}
+
+// submissionBlobKey is where an offloaded part of a submission is stored. Keys sit under the
+// user so a user's objects can be found together.
+func submissionBlobKey(userID, submissionID uuid.UUID, name string) string {
+	return fmt.Sprintf("submissions/%s/%s/%s", userID, submissionID, name)
+}
+
+// offloadSubmissionBlob writes data to the blob store when it is over the offload size,
+// returning its key. It returns an empty key, leaving data to be stored inline, when offloading
+// is off, the data is small or the write fails; a failed write only costs database space.
+func (s *ChallengeService) offloadSubmissionBlob(ctx context.Context, kind, key string, data []byte, contentType string) string {
+	if s.blobs == nil || s.config.SubmissionOffloadBytes <= 0 || len(data) <= s.config.SubmissionOffloadBytes {
+		return ""
+	}
+	if err := s.blobs.Put(ctx, key, data, contentType); err != nil {
+		submissionBlobs.WithLabelValues(kind, "put", "error").Inc()
+		log.Printf("Keeping %s inline, blob store write failed: %v", key, err)
+		return ""
+	}
+	submissionBlobs.WithLabelValues(kind, "put", "success").Inc()
+	return key
+}
+
+// deleteSubmissionBlobs removes objects written for a submission that was not saved
+func (s *ChallengeService) deleteSubmissionBlobs(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if err := s.blobs.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete unsaved blob %s: %v", key, err)
+		}
+	}
+}
+
+// loadSubmissionBlob reads an offloaded part of a submission. A NULL ref reads as nil.
+func (s *ChallengeService) loadSubmissionBlob(ctx context.Context, kind string, ref sql.NullString) ([]byte, error) {
+	if !ref.Valid || ref.String == "" {
+		return nil, nil
+	}
+	if s.blobs == nil {
+		return nil, fmt.Errorf("submission %s %s is offloaded but no blob store is configured", kind, ref.String)
+	}
+	data, err := s.blobs.Get(ctx, ref.String)
+	if err != nil {
+		submissionBlobs.WithLabelValues(kind, "get", "error").Inc()
+		return nil, fmt.Errorf("failed to load submission %s %s: %w", kind, ref.String, err)
+	}
+	submissionBlobs.WithLabelValues(kind, "get", "success").Inc()
+	return data, nil
+}
+
+// loadSubmissionParts fills in the code and test results of a submission read with refs
+func (s *ChallengeService) loadSubmissionParts(ctx context.Context, submission *submissionRow) error {
+	code, err := s.loadSubmissionBlob(ctx, "code", submission.codeRef)
+	if err != nil {
+		return err
+	}
+	if code != nil {
+		submission.SubmissionCode = string(code)
+	}
+	results, err := s.loadSubmissionBlob(ctx, "test_results", submission.testResultsRef)
+	if err != nil {
+		return err
+	}
+	if results != nil {
+		submission.TestResults = decodeTestResults(results)
+	}
+	return nil
+}
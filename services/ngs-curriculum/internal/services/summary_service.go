@@ -0,0 +1,342 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type SummaryService struct {
+	db    *database.DB
+	clock Clock
+}
+
+func NewSummaryService(db *database.DB, clock Clock) *SummaryService {
+	return &SummaryService{
+		db:    db,
+		clock: clock,
+	}
+}
+
+// GetWeeklySummary aggregates a user's activity over the past 7 days
+func (s *SummaryService) GetWeeklySummary(userID uuid.UUID) (*models.WeeklySummary, error) {
+	now := s.clock.Now()
+	summary := &models.WeeklySummary{
+		PeriodStart: now.AddDate(0, 0, -7),
+		PeriodEnd:   now,
+		XPBySource:  map[string]int{},
+	}
+
+	rows, err := s.db.Query(`
+		SELECT COALESCE(source, 'other'), SUM(xp_awarded)
+		FROM xp_events
+		WHERE user_id = $1 AND created_at >= $2
+		GROUP BY 1
+	`, userID, summary.PeriodStart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query weekly XP: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var source string
+		var xp int
+		if err := rows.Scan(&source, &xp); err != nil {
+			return nil, fmt.Errorf("failed to scan weekly XP: %w", err)
+		}
+		summary.XPBySource[source] = xp
+		summary.TotalXP += xp
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read weekly XP: %w", err)
+	}
+
+	err = s.db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM lesson_completions WHERE user_id = $1 AND completed_at >= $2),
+			(SELECT COUNT(DISTINCT challenge_id) FROM challenge_submissions
+				WHERE user_id = $1 AND passed = true AND submitted_at >= $2)
+	`, userID, summary.PeriodStart).Scan(&summary.LessonsCompleted, &summary.ChallengesCompleted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count weekly completions: %w", err)
+	}
+
+	streak, err := s.getStreak(userID, now)
+	if err != nil {
+		return nil, err
+	}
+	summary.Streak = streak
+
+	if err := s.fillPercentile(userID, summary); err != nil {
+		return nil, err
+	}
+
+	weakTopic, err := s.getWeakTopic(userID, summary.PeriodStart)
+	if err != nil {
+		return nil, err
+	}
+	summary.WeakTopic = weakTopic
+
+	return summary, nil
+}
+
+// getStreak reads the user's distinct activity days (by XP events, in their timezone) and counts
+// the streak as of now
+func (s *SummaryService) getStreak(userID uuid.UUID, now time.Time) (models.StreakStatus, error) {
+	var today time.Time
+	err := s.db.QueryRow(`SELECT DATE($2::timestamp AT TIME ZONE 'UTC' AT TIME ZONE `+userTimezoneSQL+`)`, userID, now).Scan(&today)
+	if err != nil {
+		return models.StreakStatus{}, fmt.Errorf("failed to get current date: %w", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT DISTINCT DATE(created_at AT TIME ZONE 'UTC' AT TIME ZONE `+userTimezoneSQL+`) AS day
+		FROM xp_events
+		WHERE user_id = $1 AND created_at >= $2::timestamp - INTERVAL '367 days'
+		ORDER BY day DESC
+	`, userID, now)
+	if err != nil {
+		return models.StreakStatus{}, fmt.Errorf("failed to query activity days: %w", err)
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			return models.StreakStatus{}, fmt.Errorf("failed to scan activity day: %w", err)
+		}
+		days = append(days, day)
+	}
+	if err := rows.Err(); err != nil {
+		return models.StreakStatus{}, fmt.Errorf("failed to read activity days: %w", err)
+	}
+
+	// Deactivation periods, with their reactivation grace days, freeze the streak
+	rows, err = s.db.Query(`
+		SELECT
+			DATE(deactivated_at AT TIME ZONE 'UTC' AT TIME ZONE `+userTimezoneSQL+`),
+			CASE WHEN reactivated_at IS NULL THEN $3::date
+			     ELSE DATE(reactivated_at AT TIME ZONE 'UTC' AT TIME ZONE `+userTimezoneSQL+`) + grace_days - 1
+			END
+		FROM ngs_account_deactivations
+		WHERE user_id = $1 AND COALESCE(reactivated_at, $2) >= $2::timestamp - INTERVAL '367 days'
+	`, userID, now, today)
+	if err != nil {
+		return models.StreakStatus{}, fmt.Errorf("failed to query deactivations: %w", err)
+	}
+	defer rows.Close()
+
+	var freezes []StreakFreeze
+	for rows.Next() {
+		var freeze StreakFreeze
+		if err := rows.Scan(&freeze.From, &freeze.To); err != nil {
+			return models.StreakStatus{}, fmt.Errorf("failed to scan deactivation: %w", err)
+		}
+		freezes = append(freezes, freeze)
+	}
+	if err := rows.Err(); err != nil {
+		return models.StreakStatus{}, fmt.Errorf("failed to read deactivations: %w", err)
+	}
+
+	return CalculateFrozenStreak(days, today, freezes), nil
+}
+
+// CalculateStreak counts consecutive activity days ending today or yesterday.
+// days must be distinct dates in descending order. A streak whose last day is
+// yesterday is still alive but at risk until the user is active today.
+func CalculateStreak(days []time.Time, today time.Time) models.StreakStatus {
+	today = truncateDay(today)
+	if len(days) == 0 {
+		return models.StreakStatus{Status: "none"}
+	}
+
+	expected := today
+	status := "active"
+	if first := truncateDay(days[0]); !first.Equal(today) {
+		if !first.Equal(today.AddDate(0, 0, -1)) {
+			return models.StreakStatus{Status: "none"}
+		}
+		expected = first
+		status = "at_risk"
+	}
+
+	count := 0
+	for _, day := range days {
+		if !truncateDay(day).Equal(expected) {
+			break
+		}
+		count++
+		expected = expected.AddDate(0, 0, -1)
+	}
+
+	return models.StreakStatus{Days: count, Status: status}
+}
+
+// StreakFreeze is an inclusive range of days that neither extend nor break a streak
+type StreakFreeze struct {
+	From time.Time
+	To   time.Time
+}
+
+// CalculateFrozenStreak is CalculateStreak with the frozen days left out of the calendar. Days
+// with activity are never frozen, so activity during a freeze still counts. While today is
+// frozen, a live streak has status frozen.
+func CalculateFrozenStreak(days []time.Time, today time.Time, freezes []StreakFreeze) models.StreakStatus {
+	today = truncateDay(today)
+	if len(days) == 0 || len(freezes) == 0 {
+		return CalculateStreak(days, today)
+	}
+
+	active := make(map[time.Time]bool, len(days))
+	for _, day := range days {
+		active[truncateDay(day)] = true
+	}
+	frozen := func(day time.Time) bool {
+		if active[day] {
+			return false
+		}
+		for _, freeze := range freezes {
+			if !day.Before(truncateDay(freeze.From)) && !day.After(truncateDay(freeze.To)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Move each activity day forward past the frozen days between it and today. Today itself is
+	// left out: a streak that was at risk when the freeze began is still at risk while it lasts.
+	shifted := make([]time.Time, 0, len(days))
+	skipped := 0
+	cursor := today.AddDate(0, 0, -1)
+	for _, day := range days {
+		day = truncateDay(day)
+		for cursor.After(day) {
+			if frozen(cursor) {
+				skipped++
+			}
+			cursor = cursor.AddDate(0, 0, -1)
+		}
+		shifted = append(shifted, day.AddDate(0, 0, skipped))
+	}
+
+	streak := CalculateStreak(shifted, today)
+	if streak.Days > 0 && frozen(today) {
+		streak.Status = "frozen"
+	}
+	return streak
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// fillPercentile ranks the user by total XP now and as it stood before this week's XP
+func (s *SummaryService) fillPercentile(userID uuid.UUID, summary *models.WeeklySummary) error {
+	var nowRank, thenRank float64
+	err := s.db.QueryRow(`
+		WITH recent AS (
+			SELECT user_id, SUM(xp_awarded) AS xp
+			FROM xp_events
+			WHERE created_at >= $2
+			GROUP BY user_id
+		), ranked AS (
+			SELECT
+				up.user_id,
+				PERCENT_RANK() OVER (ORDER BY up.total_xp) AS now_rank,
+				PERCENT_RANK() OVER (ORDER BY up.total_xp - COALESCE(r.xp, 0)) AS then_rank
+			FROM user_progress up
+			LEFT JOIN recent r ON r.user_id = up.user_id
+		)
+		SELECT now_rank, then_rank FROM ranked WHERE user_id = $1
+	`, userID, summary.PeriodStart).Scan(&nowRank, &thenRank)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to calculate percentile: %w", err)
+	}
+
+	summary.Percentile = int(math.Round(nowRank * 100))
+	summary.PercentileChange = summary.Percentile - int(math.Round(thenRank*100))
+	return nil
+}
+
+// getWeakTopic picks the challenge tag with the most failed attempts this period
+func (s *SummaryService) getWeakTopic(userID uuid.UUID, since time.Time) (*models.WeakTopic, error) {
+	var topic models.WeakTopic
+	var avgScore sql.NullFloat64
+	err := s.db.QueryRow(`
+		SELECT tag, COUNT(*) FILTER (WHERE NOT COALESCE(cs.passed, false)) AS failed, AVG(cs.score)
+		FROM challenge_submissions cs
+		JOIN challenges c ON c.id = cs.challenge_id
+		CROSS JOIN LATERAL unnest(CASE WHEN cardinality(c.tags) > 0 THEN c.tags ELSE ARRAY[c.challenge_type::TEXT] END) AS tag
+		WHERE cs.user_id = $1 AND cs.submitted_at >= $2
+		GROUP BY tag
+		HAVING COUNT(*) FILTER (WHERE NOT COALESCE(cs.passed, false)) > 0
+		ORDER BY failed DESC, AVG(cs.score) ASC NULLS LAST
+		LIMIT 1
+	`, userID, since).Scan(&topic.Topic, &topic.FailedCount, &avgScore)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find weak topic: %w", err)
+	}
+
+	if avgScore.Valid {
+		topic.AverageScore = int(math.Round(avgScore.Float64))
+	}
+	return &topic, nil
+}
+
+// GetActivityHeatmap groups a user's XP events by day for one calendar year.
+// Days follow the user's timezone setting; the padded created_at range keeps the index usable.
+func (s *SummaryService) GetActivityHeatmap(userID uuid.UUID, year int) (*models.ActivityHeatmap, error) {
+	rows, err := s.db.Query(`
+		WITH events AS (
+			SELECT DATE(created_at AT TIME ZONE 'UTC' AT TIME ZONE `+userTimezoneSQL+`) AS day, xp_awarded
+			FROM xp_events
+			WHERE user_id = $1
+				AND created_at >= make_date($2, 1, 1) - INTERVAL '1 day'
+				AND created_at < make_date($2 + 1, 1, 1) + INTERVAL '1 day'
+		)
+		SELECT TO_CHAR(day, 'YYYY-MM-DD'), COUNT(*), COALESCE(SUM(xp_awarded), 0)
+		FROM events
+		WHERE EXTRACT(YEAR FROM day) = $2
+		GROUP BY day
+		ORDER BY day
+	`, userID, year)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query activity: %w", err)
+	}
+	defer rows.Close()
+
+	heatmap := &models.ActivityHeatmap{
+		Year: year,
+		Days: []models.ActivityDay{},
+	}
+	for rows.Next() {
+		var day models.ActivityDay
+		if err := rows.Scan(&day.Date, &day.Count, &day.XP); err != nil {
+			return nil, fmt.Errorf("failed to scan activity day: %w", err)
+		}
+		heatmap.Days = append(heatmap.Days, day)
+		heatmap.TotalCount += day.Count
+		if day.Count > heatmap.MaxCount {
+			heatmap.MaxCount = day.Count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read activity: %w", err)
+	}
+	heatmap.ActiveDays = len(heatmap.Days)
+
+	return heatmap, nil
+}
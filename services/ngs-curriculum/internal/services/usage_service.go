@@ -0,0 +1,91 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Subscription tiers, as forwarded by the gateway in X-User-Tier
+const (
+	TierFreeTrial = "free_trial"
+	TierBasic     = "basic"
+	TierPro       = "pro"
+)
+
+// UsageService reports a user's consumption of the quotas that answer 429
+type UsageService struct {
+	db     *database.DB
+	config *config.Config
+	clock  Clock
+}
+
+func NewUsageService(db *database.DB, cfg *config.Config, clock Clock) *UsageService {
+	return &UsageService{
+		db:     db,
+		config: cfg,
+		clock:  clock,
+	}
+}
+
+// TokensPerDay is the daily LLM token allowance of a tier, -1 for unlimited. Unknown tiers get
+// the free allowance, as the intelligence service does.
+func (s *UsageService) TokensPerDay(tier string) int {
+	switch tier {
+	case TierBasic:
+		return s.config.BasicTierTokensDay
+	case TierPro:
+		return s.config.ProTierTokensDay
+	default:
+		return s.config.FreeTierTokensDay
+	}
+}
+
+// GetUsage reads today's LLM tokens from the shared usage ledger and today's XP from
+// xp_events. Days are UTC, matching the intelligence service's quota check.
+func (s *UsageService) GetUsage(userID uuid.UUID, tier string) (*models.UserUsage, error) {
+	if tier == "" {
+		tier = TierFreeTrial
+	}
+	now := s.clock.Now()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	resetsAt := dayStart.AddDate(0, 0, 1)
+
+	var tokens, xp int
+	err := s.db.QueryRow(`
+		SELECT
+			COALESCE((SELECT SUM(amount) FROM usage_ledger
+			          WHERE user_id = $1 AND resource_type = 'llm_tokens' AND timestamp >= $2), 0),
+			COALESCE((SELECT SUM(xp_awarded) FROM xp_events
+			          WHERE user_id = $1 AND created_at >= $2), 0)
+	`, userID, dayStart).Scan(&tokens, &xp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage: %w", err)
+	}
+
+	return &models.UserUsage{
+		UserID:    userID,
+		Tier:      tier,
+		LLMTokens: NewQuota(tokens, s.TokensPerDay(tier), &resetsAt),
+		DailyXP:   NewQuota(xp, -1, &resetsAt),
+	}, nil
+}
+
+// NewQuota builds a quota from what was used and its limit; a negative limit is unlimited.
+// Remaining never goes below zero.
+func NewQuota(used, limit int, resetsAt *time.Time) models.Quota {
+	quota := models.Quota{Used: used, ResetsAt: resetsAt}
+	if limit >= 0 {
+		remaining := limit - used
+		if remaining < 0 {
+			remaining = 0
+		}
+		quota.Limit, quota.Remaining = &limit, &remaining
+	}
+	return quota
+}
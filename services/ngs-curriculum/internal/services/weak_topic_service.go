@@ -0,0 +1,343 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Where a weak topic signal came from
+const (
+	WeakTopicSourceQuiz       = "quiz"       // an exam question, by its lesson
+	WeakTopicSourceChallenge  = "challenge"  // a graded submission, by each challenge tag
+	WeakTopicSourceReflection = "reflection" // a reflection, by its lesson
+)
+
+// weakTopicSettle holds back the newest activity from a refresh, so a submission whose
+// transaction commits just after the refresh is not skipped for good
+const weakTopicSettle = 30 * time.Second
+
+// examAnswersSQL selects the user's answers to submitted exam questions as (topic, correct,
+// submitted_at), where the topic is the title of the question's lesson
+func examAnswersSQL(userParam string) string {
+	return fmt.Sprintf(`
+		SELECT l.title AS topic, COALESCE((r->>'correct')::boolean, false) AS correct, e.submitted_at
+		FROM level_exams e
+		CROSS JOIN LATERAL jsonb_array_elements(COALESCE(e.results, '[]'::jsonb)) AS r
+		JOIN LATERAL jsonb_array_elements(e.questions) AS q ON q->>'index' = r->>'index'
+		JOIN lessons l ON l.id::text = q->>'lesson_id'
+		WHERE e.user_id = %s AND e.status = 'submitted'`, userParam)
+}
+
+// WeakTopicSignal is one graded piece of activity on a topic: a miss or a success
+type WeakTopicSignal struct {
+	Topic  string
+	Source string
+	Missed bool
+	At     time.Time
+}
+
+// FoldWeakTopicSignals applies signals to topics in time order. A miss raises the topic's score
+// and counts against its source; a success lowers the score, never below 0, and counts as a
+// recovery. Successes on topics with no misses are ignored. It returns the topics that changed.
+func FoldWeakTopicSignals(topics map[string]*models.LearnerWeakTopic, signals []WeakTopicSignal) map[string]*models.LearnerWeakTopic {
+	sort.SliceStable(signals, func(i, j int) bool { return signals[i].At.Before(signals[j].At) })
+	changed := make(map[string]*models.LearnerWeakTopic)
+	for _, signal := range signals {
+		topic := topics[signal.Topic]
+		if topic == nil {
+			if !signal.Missed {
+				continue
+			}
+			topic = &models.LearnerWeakTopic{Topic: signal.Topic}
+			topics[signal.Topic] = topic
+		}
+
+		if !signal.Missed {
+			if topic.Score > 0 {
+				topic.Score--
+				topic.Recoveries++
+				changed[signal.Topic] = topic
+			}
+			continue
+		}
+		topic.Score++
+		switch signal.Source {
+		case WeakTopicSourceQuiz:
+			topic.QuizMisses++
+		case WeakTopicSourceChallenge:
+			topic.ChallengeFailures++
+		case WeakTopicSourceReflection:
+			topic.LowReflections++
+		}
+		at := signal.At
+		topic.LastMissedAt = &at
+		changed[signal.Topic] = topic
+	}
+	return changed
+}
+
+// WeakTopicService keeps each learner's weak topics up to date from their challenge submissions,
+// exam answers and reflections
+type WeakTopicService struct {
+	db     *database.DB
+	config *config.Config
+	clock  Clock
+	// remediation is given the topics that reach REMEDIATION_THRESHOLD; nil gives none
+	remediation *RemediationService
+}
+
+func NewWeakTopicService(db *database.DB, cfg *config.Config, clock Clock) *WeakTopicService {
+	return &WeakTopicService{
+		db:     db,
+		config: cfg,
+		clock:  clock,
+	}
+}
+
+// SetRemediationService has topics that reach REMEDIATION_THRESHOLD get a remediation lesson
+func (s *WeakTopicService) SetRemediationService(remediation *RemediationService) {
+	s.remediation = remediation
+}
+
+// GetWeakTopics brings the user's weak topics up to date and returns the weakest, up to
+// WEAK_TOPICS_LIMIT
+func (s *WeakTopicService) GetWeakTopics(userID uuid.UUID) (*models.WeakTopicsResponse, error) {
+	through, err := s.Refresh(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT topic, score, quiz_misses, challenge_failures, low_reflections, recoveries, last_missed_at
+		FROM weak_topics
+		WHERE user_id = $1 AND score > 0
+		ORDER BY score DESC, last_missed_at DESC NULLS LAST, topic
+		LIMIT $2
+	`, userID, s.config.WeakTopicsLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get weak topics: %w", err)
+	}
+	defer rows.Close()
+
+	response := &models.WeakTopicsResponse{UserID: userID, Topics: []models.LearnerWeakTopic{}, RefreshedThrough: through}
+	for rows.Next() {
+		topic, err := scanWeakTopic(rows)
+		if err != nil {
+			return nil, err
+		}
+		response.Topics = append(response.Topics, *topic)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read weak topics: %w", err)
+	}
+	return response, nil
+}
+
+// WeakTopicNames returns the names of the user's weakest topics, for lesson generation
+func (s *WeakTopicService) WeakTopicNames(userID uuid.UUID) ([]string, error) {
+	weak, err := s.GetWeakTopics(userID)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(weak.Topics))
+	for _, topic := range weak.Topics {
+		names = append(names, topic.Topic)
+	}
+	return names, nil
+}
+
+func scanWeakTopic(row rowScanner) (*models.LearnerWeakTopic, error) {
+	var topic models.LearnerWeakTopic
+	var lastMissedAt sql.NullTime
+	err := row.Scan(&topic.Topic, &topic.Score, &topic.QuizMisses, &topic.ChallengeFailures,
+		&topic.LowReflections, &topic.Recoveries, &lastMissedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan weak topic: %w", err)
+	}
+	if lastMissedAt.Valid {
+		topic.LastMissedAt = &lastMissedAt.Time
+	}
+	return &topic, nil
+}
+
+// Refresh folds the user's activity since their last refresh into their weak topics and returns
+// how far they are now up to date. Concurrent refreshes of one user wait for each other. Topics
+// whose score reached REMEDIATION_THRESHOLD are passed on for remediation once committed.
+func (s *WeakTopicService) Refresh(userID uuid.UUID) (time.Time, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO weak_topic_refreshes (user_id, refreshed_through)
+		VALUES ($1, 'epoch')
+		ON CONFLICT (user_id) DO NOTHING
+	`, userID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to create weak topic refresh: %w", err)
+	}
+	var since time.Time
+	err = tx.QueryRow(`SELECT refreshed_through FROM weak_topic_refreshes WHERE user_id = $1 FOR UPDATE`, userID).Scan(&since)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to lock weak topic refresh: %w", err)
+	}
+
+	through := s.clock.Now().Add(-weakTopicSettle)
+	if !through.After(since) {
+		return since, nil
+	}
+
+	signals, err := s.signalsBetween(tx, userID, since, through)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var crossed []models.LearnerWeakTopic
+	if len(signals) > 0 {
+		if crossed, err = s.applySignals(tx, userID, signals); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE weak_topic_refreshes SET refreshed_through = $2 WHERE user_id = $1`, userID, through)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to record weak topic refresh: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return time.Time{}, fmt.Errorf("failed to commit weak topics: %w", err)
+	}
+	if s.remediation != nil && len(crossed) > 0 {
+		s.remediation.Remediate(userID, crossed)
+	}
+	return through, nil
+}
+
+// signalsBetween reads the user's graded activity in (since, through]: challenge submissions by
+// tag (or challenge type when untagged), exam answers by the question's lesson, and scored
+// reflections by lesson. Submissions still awaiting review are left out.
+func (s *WeakTopicService) signalsBetween(tx *sql.Tx, userID uuid.UUID, since, through time.Time) ([]WeakTopicSignal, error) {
+	queries := []struct {
+		source string
+		query  string
+		args   []interface{}
+	}{
+		{WeakTopicSourceChallenge, `
+			SELECT tag, NOT cs.passed, cs.submitted_at
+			FROM challenge_submissions cs
+			JOIN challenges c ON c.id = cs.challenge_id
+			CROSS JOIN LATERAL unnest(CASE WHEN cardinality(c.tags) > 0 THEN c.tags ELSE ARRAY[c.challenge_type::TEXT] END) AS tag
+			WHERE cs.user_id = $1 AND cs.submitted_at > $2 AND cs.submitted_at <= $3
+				AND cs.review_status = 'graded' AND cs.passed IS NOT NULL
+		`, nil},
+		{WeakTopicSourceQuiz, `
+			SELECT topic, NOT correct, submitted_at
+			FROM (` + examAnswersSQL("$1") + `) answers
+			WHERE submitted_at > $2 AND submitted_at <= $3
+		`, nil},
+		{WeakTopicSourceReflection, `
+			SELECT COALESCE(l.title, 'Level ' || ur.level_number || ' reflection'), ur.quality_score * 100 < $4, ur.created_at
+			FROM user_reflections ur
+			LEFT JOIN lessons l ON l.id = ur.lesson_id
+			WHERE ur.user_id = $1 AND ur.created_at > $2 AND ur.created_at <= $3
+				AND ur.quality_score IS NOT NULL AND (l.id IS NOT NULL OR ur.level_number IS NOT NULL)
+		`, []interface{}{s.config.WeakTopicReflectionQuality}},
+	}
+
+	var signals []WeakTopicSignal
+	for _, q := range queries {
+		rows, err := tx.Query(q.query, append([]interface{}{userID, since, through}, q.args...)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s activity: %w", q.source, err)
+		}
+		for rows.Next() {
+			signal := WeakTopicSignal{Source: q.source}
+			if err := rows.Scan(&signal.Topic, &signal.Missed, &signal.At); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan %s activity: %w", q.source, err)
+			}
+			signals = append(signals, signal)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s activity: %w", q.source, err)
+		}
+	}
+	return signals, nil
+}
+
+// applySignals folds signals into the stored topics they touch and writes back those that
+// changed. It returns the topics whose score reached REMEDIATION_THRESHOLD.
+func (s *WeakTopicService) applySignals(tx *sql.Tx, userID uuid.UUID, signals []WeakTopicSignal) ([]models.LearnerWeakTopic, error) {
+	names := make([]string, 0, len(signals))
+	for _, signal := range signals {
+		names = append(names, signal.Topic)
+	}
+	rows, err := tx.Query(`
+		SELECT topic, score, quiz_misses, challenge_failures, low_reflections, recoveries, last_missed_at
+		FROM weak_topics
+		WHERE user_id = $1 AND topic = ANY($2)
+		FOR UPDATE
+	`, userID, pq.Array(names))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock weak topics: %w", err)
+	}
+	topics := make(map[string]*models.LearnerWeakTopic)
+	scores := make(map[string]int)
+	for rows.Next() {
+		topic, err := scanWeakTopic(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		topics[topic.Topic] = topic
+		scores[topic.Topic] = topic.Score
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read weak topics: %w", err)
+	}
+
+	now := s.clock.Now()
+	threshold := s.config.RemediationThreshold
+	var crossed []models.LearnerWeakTopic
+	for _, topic := range FoldWeakTopicSignals(topics, signals) {
+		if threshold > 0 && scores[topic.Topic] < threshold && topic.Score >= threshold {
+			crossed = append(crossed, *topic)
+		}
+		var lastMissedAt sql.NullTime
+		if topic.LastMissedAt != nil {
+			lastMissedAt = sql.NullTime{Time: *topic.LastMissedAt, Valid: true}
+		}
+		_, err := tx.Exec(`
+			INSERT INTO weak_topics (user_id, topic, score, quiz_misses, challenge_failures,
+				low_reflections, recoveries, last_missed_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (user_id, topic) DO UPDATE SET
+				score = EXCLUDED.score,
+				quiz_misses = EXCLUDED.quiz_misses,
+				challenge_failures = EXCLUDED.challenge_failures,
+				low_reflections = EXCLUDED.low_reflections,
+				recoveries = EXCLUDED.recoveries,
+				last_missed_at = EXCLUDED.last_missed_at,
+				updated_at = EXCLUDED.updated_at
+		`, userID, topic.Topic, topic.Score, topic.QuizMisses, topic.ChallengeFailures,
+			topic.LowReflections, topic.Recoveries, lastMissedAt, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save weak topic: %w", err)
+		}
+	}
+	sort.Slice(crossed, func(i, j int) bool { return crossed[i].Topic < crossed[j].Topic })
+	return crossed, nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"noble-ngs-curriculum/internal/domain/xp"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// xpDecayWindow is the "day" repeated awards are counted over. It is rolling rather than a
+// calendar day so changing timezone cannot reset it.
+const xpDecayWindow = 24 * time.Hour
+
+var xpDecayCurves atomic.Pointer[xp.DecayCurves]
+
+var xpDecayed = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ngs_xp_decayed_total",
+		Help: "XP withheld from repeated awards by the source's decay curve, by source.",
+	},
+	[]string{"source"},
+)
+
+func init() {
+	prometheus.MustRegister(xpDecayed)
+}
+
+// ConfigureXPDecay applies per-source decay curves to every XP award: lesson completions,
+// reflections, challenges and AwardXP. Without it awards do not decay.
+func ConfigureXPDecay(curves xp.DecayCurves) {
+	xpDecayCurves.Store(&curves)
+}
+
+func currentXPDecay() xp.DecayCurves {
+	if curves := xpDecayCurves.Load(); curves != nil {
+		return *curves
+	}
+	return nil
+}
+
+// decayXP applies the source's decay curve to an award, counting earlier awards with count. It
+// returns the XP to give and, when the award decayed, metadata recording the amount before.
+func decayXP(source string, amount int, metadata map[string]interface{}, count func() (int, error)) (int, map[string]interface{}, error) {
+	curves := currentXPDecay()
+	if amount <= 0 || !curves.Decays(source) {
+		return amount, metadata, nil
+	}
+	earlier, err := count()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to count repeated XP awards: %w", err)
+	}
+
+	decayed := curves.Decayed(source, amount, earlier)
+	if decayed == amount {
+		return amount, metadata, nil
+	}
+	xpDecayed.WithLabelValues(source).Add(float64(amount - decayed))
+
+	annotated := make(map[string]interface{}, len(metadata)+2)
+	for k, v := range metadata {
+		annotated[k] = v
+	}
+	annotated["xp_before_decay"] = amount
+	annotated["xp_repeat"] = earlier + 1
+	return decayed, annotated, nil
+}
+
+// decayXPInTx applies decayXP to an award recorded in tx, counting the user's awards from
+// source in the last xpDecayWindow
+func decayXPInTx(tx *sql.Tx, userID uuid.UUID, source string, amount int, metadata map[string]interface{}) (int, map[string]interface{}, error) {
+	return decayXP(source, amount, metadata, func() (int, error) {
+		var earlier int
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FROM xp_events
+			WHERE user_id = $1 AND source = $2 AND created_at >= NOW() - make_interval(secs => $3)
+		`, userID, source, xpDecayWindow.Seconds()).Scan(&earlier)
+		return earlier, err
+	})
+}
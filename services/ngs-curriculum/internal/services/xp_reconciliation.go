@@ -0,0 +1,482 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Who started a reconciliation run
+const (
+	ReconciliationScheduled = "scheduled"
+	ReconciliationAdmin     = "admin"
+)
+
+// maxReportedDrifts caps the drifted users listed in a run
+const maxReportedDrifts = 50
+
+var (
+	xpDriftUsers = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ngs_xp_drift_users",
+			Help: "Users whose total_xp differed from their XP ledger in the last completed reconciliation run.",
+		},
+	)
+
+	xpDriftXP = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ngs_xp_drift_xp",
+			Help: "Sum of the absolute difference between total_xp and the XP ledger in the last completed reconciliation run.",
+		},
+	)
+
+	xpRepairs = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ngs_xp_reconciliation_repairs_total",
+			Help: "XP totals reset to the ledger by reconciliation.",
+		},
+	)
+
+	xpReconciliationRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ngs_xp_reconciliation_runs_total",
+			Help: "XP reconciliation runs by outcome (success, error, skipped).",
+		},
+		[]string{"status"},
+	)
+
+	xpReconciliationLastSuccess = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ngs_xp_reconciliation_last_success_timestamp_seconds",
+			Help: "Unix time of the last successful XP reconciliation run.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(xpDriftUsers, xpDriftXP, xpRepairs, xpReconciliationRuns, xpReconciliationLastSuccess)
+}
+
+// NextXPReconciliation returns the first time after now at hour:00 UTC
+func NextXPReconciliation(now time.Time, hour int) time.Time {
+	now = now.UTC()
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, time.UTC)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// ReconciliationService compares each user's total_xp with their XP ledger, the uncompacted
+// xp_events plus xp_event_rollups, and resets totals that drifted after partial failures
+type ReconciliationService struct {
+	db     *database.DB
+	config *config.Config
+	clock  Clock
+	hour   int
+	repair bool
+	batch  int
+
+	// runMu serializes runs on this instance; the session lock covers other instances
+	runMu   sync.Mutex
+	mu      sync.Mutex
+	lastRun *models.XPReconciliationRun
+	running sync.WaitGroup
+}
+
+func NewReconciliationService(db *database.DB, cfg *config.Config, clock Clock) *ReconciliationService {
+	batch := cfg.XPReconciliationBatchSize
+	if batch <= 0 {
+		batch = 500
+	}
+	return &ReconciliationService{
+		db:     db,
+		config: cfg,
+		clock:  clock,
+		hour:   cfg.XPReconciliationHour,
+		repair: cfg.XPReconciliationRepair,
+		batch:  batch,
+	}
+}
+
+// enabled reports whether the nightly run is scheduled
+func (s *ReconciliationService) enabled() bool {
+	return s.hour >= 0 && s.hour < 24
+}
+
+// Start runs reconciliation every night at XP_RECONCILIATION_HOUR UTC until ctx is cancelled
+func (s *ReconciliationService) Start(ctx context.Context) {
+	if !s.enabled() {
+		log.Println("XP reconciliation disabled")
+		return
+	}
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		for {
+			timer := time.NewTimer(NextXPReconciliation(s.clock.Now(), s.hour).Sub(s.clock.Now()))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			s.Reconcile(ctx, !s.repair, ReconciliationScheduled)
+		}
+	}()
+	log.Printf("XP reconciliation running nightly at %02d:00 UTC", s.hour)
+}
+
+// Wait blocks until the scheduler started by Start has stopped
+func (s *ReconciliationService) Wait() {
+	s.running.Wait()
+}
+
+// Status returns the schedule and the last run on this instance
+func (s *ReconciliationService) Status() models.XPReconciliationStatus {
+	status := models.XPReconciliationStatus{
+		Enabled: s.enabled(),
+		HourUTC: s.hour,
+		Repair:  s.repair,
+	}
+	if status.Enabled {
+		next := NextXPReconciliation(s.clock.Now(), s.hour)
+		status.NextRunAt = &next
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastRun != nil {
+		run := *s.lastRun
+		status.LastRun = &run
+	}
+	return status
+}
+
+// Reconcile checks every user's total in batches of XP_RECONCILIATION_BATCH_SIZE, each in its
+// own transaction. Unless dryRun is set, drifted totals are reset to the ledger and recorded in
+// ngs_xp_reconciliation_repairs. Only one instance runs at a time; the others skip.
+func (s *ReconciliationService) Reconcile(ctx context.Context, dryRun bool, source string) models.XPReconciliationRun {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	start := time.Now()
+	run := models.XPReconciliationRun{StartedAt: s.clock.Now(), DryRun: dryRun, Drifts: []models.XPDrift{}}
+
+	lock, err := s.db.TryLock(ctx, "ngs_xp_reconciliation")
+	if err == nil && lock == nil {
+		run.Skipped = true
+	}
+	if lock != nil {
+		defer lock.Release()
+		after := uuid.Nil
+		for err == nil {
+			if err = ctx.Err(); err != nil {
+				break
+			}
+			if err = lock.Context().Err(); err != nil {
+				err = fmt.Errorf("lost reconciliation lock: %w", err)
+				break
+			}
+			var n int
+			n, after, err = s.reconcileBatch(&run, after, source)
+			if n < s.batch {
+				break
+			}
+		}
+	}
+	run.DurationMs = time.Since(start).Milliseconds()
+
+	switch {
+	case err != nil:
+		run.Error = err.Error()
+		xpReconciliationRuns.WithLabelValues("error").Inc()
+		log.Printf("XP reconciliation failed after %d users: %v", run.Checked, err)
+	case run.Skipped:
+		xpReconciliationRuns.WithLabelValues("skipped").Inc()
+	default:
+		xpReconciliationRuns.WithLabelValues("success").Inc()
+		xpReconciliationLastSuccess.SetToCurrentTime()
+		xpDriftUsers.Set(float64(run.Drifted))
+		xpDriftXP.Set(float64(run.DriftXP))
+		if run.Drifted > 0 {
+			log.Printf("XP reconciliation found %d of %d users drifted by %d XP in total; repaired %d",
+				run.Drifted, run.Checked, run.DriftXP, run.Repaired)
+		}
+	}
+
+	s.mu.Lock()
+	s.lastRun = &run
+	s.mu.Unlock()
+	return run
+}
+
+// reconcileBatch checks the next batch of users after the given user ID and returns how many it
+// checked and the last one. Rows are locked before the ledger is summed, so XP awarded while the
+// batch runs lands on the repaired total.
+func (s *ReconciliationService) reconcileBatch(run *models.XPReconciliationRun, after uuid.UUID, source string) (int, uuid.UUID, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, after, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT user_id, total_xp, current_level, agent_creation_unlocked
+		FROM user_progress
+		WHERE user_id > $1
+		ORDER BY user_id
+		LIMIT $2
+		FOR UPDATE
+	`, after, s.batch)
+	if err != nil {
+		return 0, after, fmt.Errorf("failed to lock progress: %w", err)
+	}
+	var batch []models.UserProgress
+	for rows.Next() {
+		var p models.UserProgress
+		if err := rows.Scan(&p.UserID, &p.TotalXP, &p.CurrentLevel, &p.AgentCreationUnlocked); err != nil {
+			rows.Close()
+			return 0, after, fmt.Errorf("failed to scan progress: %w", err)
+		}
+		batch = append(batch, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, after, fmt.Errorf("failed to read progress: %w", err)
+	}
+	if len(batch) == 0 {
+		return 0, after, nil
+	}
+	last := batch[len(batch)-1].UserID
+
+	ledger, err := ledgerTotals(tx, "user_id > $1 AND user_id <= $2", after, last)
+	if err != nil {
+		return 0, after, err
+	}
+
+	var drifts []models.XPDrift
+	for _, p := range batch {
+		drift := XPDrift(p, ledger[p.UserID], s.ledgerLevel(p, ledger[p.UserID]))
+		if drift == nil {
+			continue
+		}
+		drifts = append(drifts, *drift)
+		if run.DryRun {
+			continue
+		}
+		if err := s.repairTotal(tx, p, drift.LedgerXP, run.StartedAt, source); err != nil {
+			return 0, after, fmt.Errorf("failed to repair user %s: %w", p.UserID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, after, fmt.Errorf("failed to commit reconciliation batch: %w", err)
+	}
+
+	run.Checked += len(batch)
+	run.Drifted += len(drifts)
+	for _, drift := range drifts {
+		run.DriftXP += int64(max(drift.Drift, -drift.Drift))
+		if len(run.Drifts) < maxReportedDrifts {
+			run.Drifts = append(run.Drifts, drift)
+		}
+	}
+	if !run.DryRun {
+		run.Repaired += len(drifts)
+		xpRepairs.Add(float64(len(drifts)))
+	}
+	return len(batch), last, nil
+}
+
+// ReconcileUser compares one user's total and level with their ledger and, unless dryRun is
+// set, resets them to it and records the repair in ngs_xp_reconciliation_repairs. The user's
+// progress is locked while the ledger is summed, so XP awarded meanwhile lands on the repaired
+// total. It returns ErrProgressNotFound for a user without progress.
+func (s *ReconciliationService) ReconcileUser(userID uuid.UUID, dryRun bool) (*models.XPUserReconciliation, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var p models.UserProgress
+	err = tx.QueryRow(`
+		SELECT user_id, total_xp, current_level, agent_creation_unlocked
+		FROM user_progress
+		WHERE user_id = $1
+		FOR UPDATE
+	`, userID).Scan(&p.UserID, &p.TotalXP, &p.CurrentLevel, &p.AgentCreationUnlocked)
+	if err == sql.ErrNoRows {
+		return nil, ErrProgressNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock progress: %w", err)
+	}
+
+	ledger, err := ledgerTotals(tx, "user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+	ledgerXP := ledger[userID]
+	ledgerLevel := s.ledgerLevel(p, ledgerXP)
+
+	result := &models.XPUserReconciliation{DryRun: dryRun, CheckedAt: s.clock.Now()}
+	drift := XPDrift(p, ledgerXP, ledgerLevel)
+	if drift == nil {
+		result.XPDrift = models.XPDrift{
+			UserID:       userID,
+			TotalXP:      p.TotalXP,
+			LedgerXP:     ledgerXP,
+			CurrentLevel: p.CurrentLevel,
+			LedgerLevel:  ledgerLevel,
+		}
+		return result, nil
+	}
+	result.XPDrift = *drift
+	if dryRun {
+		return result, nil
+	}
+
+	if err := s.repairTotal(tx, p, drift.LedgerXP, result.CheckedAt, ReconciliationAdmin); err != nil {
+		return nil, fmt.Errorf("failed to repair user %s: %w", userID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit reconciliation: %w", err)
+	}
+	result.Repaired = true
+	xpRepairs.Inc()
+	return result, nil
+}
+
+// ledgerLevel returns the level a user's ledger total gives them, reached the way an award
+// would from their current level
+func (s *ReconciliationService) ledgerLevel(current models.UserProgress, ledgerXP int) int {
+	return levelPolicy(s.config).Level(s.config.LevelUpXPThresholds, current.CurrentLevel, ledgerXP)
+}
+
+// ledgerTotals sums the XP ledger of the users matching the users condition on user_id,
+// counting compacted events, including those in archived partitions, from their rollups
+func ledgerTotals(tx *sql.Tx, users string, args ...interface{}) (map[uuid.UUID]int, error) {
+	rows, err := tx.Query(`
+		SELECT user_id, SUM(xp)
+		FROM (
+			SELECT user_id, COALESCE(SUM(xp_awarded), 0) AS xp
+			FROM xp_events
+			WHERE compacted_at IS NULL AND `+users+`
+			GROUP BY user_id
+			UNION ALL
+			SELECT user_id, COALESCE(SUM(xp_total), 0)
+			FROM xp_event_rollups
+			WHERE `+users+`
+			GROUP BY user_id
+		) l
+		GROUP BY user_id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum XP ledger: %w", err)
+	}
+	defer rows.Close()
+
+	totals := make(map[uuid.UUID]int)
+	for rows.Next() {
+		var userID uuid.UUID
+		var total int
+		if err := rows.Scan(&userID, &total); err != nil {
+			return nil, fmt.Errorf("failed to scan XP ledger: %w", err)
+		}
+		totals[userID] = total
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read XP ledger: %w", err)
+	}
+	return totals, nil
+}
+
+// repairTotal resets a user's total to the ledger. The level is recomputed the way an award
+// would, so it only drops under the strict level-down policy, and level-ups are recorded as
+// achievements.
+func (s *ReconciliationService) repairTotal(tx *sql.Tx, current models.UserProgress, ledgerXP int, runStartedAt time.Time, source string) error {
+	updated := current
+	updated.TotalXP = ledgerXP
+	policy := levelPolicy(s.config)
+	updated.CurrentLevel = s.ledgerLevel(current, ledgerXP)
+	updated.AgentCreationUnlocked = policy.AgentUnlocked(current.AgentCreationUnlocked, updated.CurrentLevel, s.config.AgentUnlockLevel)
+	updated.UpdatedAt = s.clock.Now()
+
+	if err := updateProgress(s.db, tx, current.UserID, updated.TotalXP, updated.CurrentLevel, updated.AgentCreationUnlocked); err != nil {
+		return err
+	}
+	if err := recordAchievements(tx, current.UserID, progressAchievements(current, updated)); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+		INSERT INTO ngs_xp_reconciliation_repairs
+			(user_id, run_started_at, previous_total_xp, ledger_xp, previous_level, new_level, source)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, current.UserID, runStartedAt, current.TotalXP, ledgerXP, current.CurrentLevel, updated.CurrentLevel, source)
+	return err
+}
+
+// XPDrift compares a user's progress with their ledger total and the level it gives them,
+// returning nil when they agree
+func XPDrift(progress models.UserProgress, ledgerXP, ledgerLevel int) *models.XPDrift {
+	if progress.TotalXP == ledgerXP && progress.CurrentLevel == ledgerLevel {
+		return nil
+	}
+	return &models.XPDrift{
+		UserID:       progress.UserID,
+		TotalXP:      progress.TotalXP,
+		LedgerXP:     ledgerXP,
+		Drift:        progress.TotalXP - ledgerXP,
+		CurrentLevel: progress.CurrentLevel,
+		LedgerLevel:  ledgerLevel,
+	}
+}
+
+// ListXPRepairs lists repairs, newest first, optionally only those of userID
+func (s *ReconciliationService) ListXPRepairs(userID *uuid.UUID, limit int) ([]models.XPRepair, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, user_id, run_started_at, previous_total_xp, ledger_xp, previous_level, new_level, source, created_at
+		FROM ngs_xp_reconciliation_repairs
+		WHERE $1::uuid IS NULL OR user_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query XP repairs: %w", err)
+	}
+	defer rows.Close()
+
+	repairs := []models.XPRepair{}
+	for rows.Next() {
+		var r models.XPRepair
+		if err := rows.Scan(
+			&r.ID, &r.UserID, &r.RunStartedAt, &r.PreviousTotalXP, &r.LedgerXP,
+			&r.PreviousLevel, &r.NewLevel, &r.Source, &r.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan XP repair: %w", err)
+		}
+		repairs = append(repairs, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read XP repairs: %w", err)
+	}
+
+	return repairs, nil
+}
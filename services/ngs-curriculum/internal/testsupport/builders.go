@@ -0,0 +1,222 @@
+// Package testsupport has fixtures and in-memory stores for exercising services without
+// Postgres. It is only imported by tests.
+package testsupport
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/domain/xp"
+	"noble-ngs-curriculum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Epoch is the fixed creation time of built fixtures, so test output does not depend on the clock
+var Epoch = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Config returns the service configuration, with the built-in XP thresholds and sources
+func Config() *config.Config {
+	return config.Load()
+}
+
+// Levels returns one curriculum level per XP threshold, numbered from 1
+func Levels(thresholds []int) []models.CurriculumLevel {
+	levels := make([]models.CurriculumLevel, len(thresholds))
+	for i, xp := range thresholds {
+		levels[i] = models.CurriculumLevel{
+			ID:                 i + 1,
+			LevelNumber:        i + 1,
+			Title:              fmt.Sprintf("Level %d", i+1),
+			UnlockRequirements: json.RawMessage(`{}`),
+			XPRequired:         xp,
+		}
+	}
+	return levels
+}
+
+// LessonBuilder builds a lesson, defaulting to a required level 1 tutorial for every age band
+type LessonBuilder struct {
+	lesson models.Lesson
+}
+
+func Lesson() *LessonBuilder {
+	return &LessonBuilder{lesson: models.Lesson{
+		ID:                 uuid.New(),
+		LevelID:            1,
+		Title:              "Test lesson",
+		LessonOrder:        1,
+		LessonType:         "tutorial",
+		XPReward:           50,
+		EstimatedMinutes:   10,
+		IsRequired:         true,
+		Metadata:           json.RawMessage(`{}`),
+		CompletionCriteria: json.RawMessage(`{}`),
+		MinAgeBand:         "child",
+		CreatedAt:          Epoch,
+		UpdatedAt:          Epoch,
+	}}
+}
+
+func (b *LessonBuilder) ID(id uuid.UUID) *LessonBuilder {
+	b.lesson.ID = id
+	return b
+}
+
+func (b *LessonBuilder) Title(title string) *LessonBuilder {
+	b.lesson.Title = title
+	return b
+}
+
+// Level sets the lesson's level and its order within it
+func (b *LessonBuilder) Level(level, order int) *LessonBuilder {
+	b.lesson.LevelID, b.lesson.LessonOrder = level, order
+	return b
+}
+
+func (b *LessonBuilder) Type(lessonType string) *LessonBuilder {
+	b.lesson.LessonType = lessonType
+	return b
+}
+
+func (b *LessonBuilder) XP(xp int) *LessonBuilder {
+	b.lesson.XPReward = xp
+	return b
+}
+
+func (b *LessonBuilder) Optional() *LessonBuilder {
+	b.lesson.IsRequired = false
+	return b
+}
+
+func (b *LessonBuilder) AgeBand(band string) *LessonBuilder {
+	b.lesson.MinAgeBand = band
+	return b
+}
+
+func (b *LessonBuilder) AgentUnlock(capability string) *LessonBuilder {
+	b.lesson.AgentUnlock = capability
+	return b
+}
+
+// Prerequisites stores the lesson prerequisites in the object format
+func (b *LessonBuilder) Prerequisites(minLevel int, lessons ...uuid.UUID) *LessonBuilder {
+	b.lesson.Prerequisites = mustJSON(models.LessonPrerequisites{MinLevel: minLevel, Lessons: lessons})
+	return b
+}
+
+func (b *LessonBuilder) CompletionCriteria(criteria models.CompletionCriteria) *LessonBuilder {
+	b.lesson.CompletionCriteria = mustJSON(criteria)
+	return b
+}
+
+func (b *LessonBuilder) Build() models.Lesson {
+	return b.lesson
+}
+
+// ChallengeBuilder builds an active, easy level 1 coding challenge
+type ChallengeBuilder struct {
+	challenge models.Challenge
+}
+
+func Challenge() *ChallengeBuilder {
+	return &ChallengeBuilder{challenge: models.Challenge{
+		ID:            uuid.New(),
+		LevelID:       1,
+		Title:         "Test challenge",
+		ChallengeType: "coding",
+		Difficulty:    "easy",
+		TestCases:     json.RawMessage(`[]`),
+		XPReward:      100,
+		Metadata:      json.RawMessage(`{}`),
+		IsActive:      true,
+		MinAgeBand:    "child",
+		CreatedAt:     Epoch,
+	}}
+}
+
+func (b *ChallengeBuilder) ID(id uuid.UUID) *ChallengeBuilder {
+	b.challenge.ID = id
+	return b
+}
+
+func (b *ChallengeBuilder) Level(level int) *ChallengeBuilder {
+	b.challenge.LevelID = level
+	return b
+}
+
+func (b *ChallengeBuilder) Lesson(lessonID uuid.UUID) *ChallengeBuilder {
+	b.challenge.LessonID = lessonID
+	return b
+}
+
+func (b *ChallengeBuilder) Difficulty(difficulty string) *ChallengeBuilder {
+	b.challenge.Difficulty = difficulty
+	return b
+}
+
+func (b *ChallengeBuilder) XP(xp int) *ChallengeBuilder {
+	b.challenge.XPReward = xp
+	return b
+}
+
+// TestCases sets the challenge's test cases from JSON
+func (b *ChallengeBuilder) TestCases(testCases string) *ChallengeBuilder {
+	b.challenge.TestCases = json.RawMessage(testCases)
+	return b
+}
+
+func (b *ChallengeBuilder) Inactive() *ChallengeBuilder {
+	b.challenge.IsActive = false
+	return b
+}
+
+func (b *ChallengeBuilder) Build() models.Challenge {
+	return b.challenge
+}
+
+// ProgressBuilder builds a user's progress, defaulting to a new user at level 1
+type ProgressBuilder struct {
+	progress models.UserProgress
+}
+
+func Progress() *ProgressBuilder {
+	return &ProgressBuilder{progress: models.UserProgress{
+		ID:           uuid.New(),
+		UserID:       uuid.New(),
+		CurrentLevel: 1,
+		CreatedAt:    Epoch,
+		UpdatedAt:    Epoch,
+	}}
+}
+
+func (b *ProgressBuilder) User(userID uuid.UUID) *ProgressBuilder {
+	b.progress.UserID = userID
+	return b
+}
+
+// XP sets the total XP and the level it reaches under thresholds
+func (b *ProgressBuilder) XP(totalXP int, thresholds []int) *ProgressBuilder {
+	b.progress.TotalXP = totalXP
+	b.progress.CurrentLevel = xp.LevelForXP(thresholds, totalXP)
+	return b
+}
+
+func (b *ProgressBuilder) AgentUnlocked() *ProgressBuilder {
+	b.progress.AgentCreationUnlocked = true
+	return b
+}
+
+func (b *ProgressBuilder) Build() models.UserProgress {
+	return b.progress
+}
+
+func mustJSON(v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
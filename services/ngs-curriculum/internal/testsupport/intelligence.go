@@ -0,0 +1,111 @@
+package testsupport
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"noble-ngs-curriculum/internal/clients/intelligence"
+	"noble-ngs-curriculum/internal/handlers"
+)
+
+var _ handlers.IntelligenceClient = (*MockIntelligence)(nil)
+
+// MockIntelligence stands in for the Intelligence service in handler tests. It records each
+// request and answers with Lesson (GeneratedLesson by default) or Chat, or fails with Err when
+// set. Streamed chat answers are sent in StreamChunks.
+type MockIntelligence struct {
+	mu sync.Mutex
+
+	Lesson *intelligence.GenerateLessonResponse
+	Chat   *intelligence.EducatorChatResponse
+	Err    error
+
+	StreamChunks []string
+
+	GenerateRequests []intelligence.GenerateLessonRequest
+	ChatRequests     []intelligence.EducatorChatRequest
+}
+
+func (m *MockIntelligence) GenerateLesson(ctx context.Context, req intelligence.GenerateLessonRequest, userID, userEmail, userRole string) (*intelligence.GenerateLessonResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.GenerateRequests = append(m.GenerateRequests, req)
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	if m.Lesson == nil {
+		return GeneratedLesson("# Noticing\n\nWatch your thoughts."), nil
+	}
+	resp := *m.Lesson
+	return &resp, nil
+}
+
+// SendEducatorChatMessage answers with Chat, or an empty reply in the request's lesson by default
+func (m *MockIntelligence) SendEducatorChatMessage(ctx context.Context, req intelligence.EducatorChatRequest, userID, userEmail, userRole string) (*intelligence.EducatorChatResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ChatRequests = append(m.ChatRequests, req)
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	if m.Chat == nil {
+		return &intelligence.EducatorChatResponse{LessonID: req.LessonID}, nil
+	}
+	resp := *m.Chat
+	return &resp, nil
+}
+
+// StreamEducatorChatMessage passes each of StreamChunks to onChunk, stopping at the first error,
+// and answers with them joined in the request's lesson
+func (m *MockIntelligence) StreamEducatorChatMessage(ctx context.Context, req intelligence.EducatorChatRequest, userID, userEmail, userRole string, onChunk func(content string) error) (*intelligence.EducatorChatResponse, error) {
+	m.mu.Lock()
+	m.ChatRequests = append(m.ChatRequests, req)
+	err, chunks := m.Err, m.StreamChunks
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	for _, chunk := range chunks {
+		if err := onChunk(chunk); err != nil {
+			return nil, err
+		}
+	}
+	return &intelligence.EducatorChatResponse{Response: strings.Join(chunks, ""), LessonID: req.LessonID}, nil
+}
+
+// GeneratedLesson returns a generation response with markdown that passes schema validation,
+// with every required section filled in
+func GeneratedLesson(markdown string) *intelligence.GenerateLessonResponse {
+	return &intelligence.GenerateLessonResponse{
+		ContentMarkdown: markdown,
+		StructuredLesson: intelligence.StructuredLesson{
+			Metadata: intelligence.LessonMetadata{
+				Title:            "Noticing",
+				Outcomes:         []string{"Notice a passing thought"},
+				Difficulty:       "beginner",
+				Prerequisites:    []string{},
+				EstimatedMinutes: 10,
+			},
+			Teach: intelligence.TeachSection{
+				Overview: "Thoughts come and go.",
+				Concepts: []intelligence.Concept{{Name: "Noticing", Explanation: "Seeing a thought as a thought.", Example: "I am having the thought that I am late."}},
+				Steps:    []string{"Pause", "Name the thought"},
+			},
+			GuidedPractice: []intelligence.GuidedPracticeTask{{Task: "Name three thoughts", Hint: "Start with the loudest", Solution: "Any three thoughts, each named"}},
+			Assessment: intelligence.Assessment{Checks: []intelligence.AssessmentCheck{{
+				Type:        "mcq",
+				Question:    "What is noticing?",
+				Choices:     []string{"Seeing a thought as a thought", "Stopping thoughts"},
+				Answer:      float64(0),
+				Explanation: "Noticing does not stop thoughts.",
+			}}},
+			Summary: "Watch your thoughts",
+			Accessibility: &intelligence.AccessibilityInfo{
+				PlainLanguageSummary: "Notice your thoughts.",
+			},
+		},
+		Provider: "ollama",
+		Version:  1,
+	}
+}
@@ -0,0 +1,347 @@
+package testsupport
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"noble-ngs-curriculum/internal/api"
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/google/uuid"
+)
+
+// MemoryProgressStore is an in-memory services.ProgressStore. It keeps every XP event and
+// achievement so tests can assert on them.
+type MemoryProgressStore struct {
+	mu           sync.Mutex
+	progress     map[uuid.UUID]models.UserProgress
+	levels       map[int]models.CurriculumLevel
+	events       []models.XPEvent
+	achievements []models.Achievement
+	hidden       map[uuid.UUID]bool
+
+	// Clock stamps created progress; XP events and achievements take the time of the update
+	Clock *FakeClock
+
+	// Err, when set, fails every call, to exercise error handling
+	Err error
+}
+
+var _ services.ProgressStore = (*MemoryProgressStore)(nil)
+
+func NewMemoryProgressStore(levels []models.CurriculumLevel, clock *FakeClock) *MemoryProgressStore {
+	s := &MemoryProgressStore{
+		Clock:    clock,
+		progress: make(map[uuid.UUID]models.UserProgress),
+		levels:   make(map[int]models.CurriculumLevel),
+		hidden:   make(map[uuid.UUID]bool),
+	}
+	for _, level := range levels {
+		s.levels[level.LevelNumber] = level
+	}
+	return s
+}
+
+// NewProgressService returns a ProgressService on an in-memory store seeded with cfg's levels.
+// The service and store share a FakeClock stopped at Epoch, reachable as store.Clock.
+func NewProgressService(cfg *config.Config) (*services.ProgressService, *MemoryProgressStore) {
+	clock := NewFakeClock(Epoch)
+	store := NewMemoryProgressStore(Levels(cfg.LevelUpXPThresholds), clock)
+	return services.NewProgressServiceWithStore(store, cfg, clock), store
+}
+
+// PutProgress stores progress as if the user had earned it earlier
+func (s *MemoryProgressStore) PutProgress(progress models.UserProgress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.progress[progress.UserID] = progress
+}
+
+// PutAchievement stores an achievement as if the user had unlocked it earlier
+func (s *MemoryProgressStore) PutAchievement(achievement models.Achievement) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.achievements = append(s.achievements, achievement)
+}
+
+// HideFromLeaderboard leaves the user off the leaderboard, like an opt-out or minor account
+func (s *MemoryProgressStore) HideFromLeaderboard(userID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hidden[userID] = true
+}
+
+// XPEvents returns the user's recorded XP events, oldest first
+func (s *MemoryProgressStore) XPEvents(userID uuid.UUID) []models.XPEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var events []models.XPEvent
+	for _, event := range s.events {
+		if event.UserID == userID {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func (s *MemoryProgressStore) GetProgress(userID uuid.UUID) (models.UserProgress, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return models.UserProgress{}, s.Err
+	}
+	progress, ok := s.progress[userID]
+	if !ok {
+		return progress, services.ErrProgressNotFound
+	}
+	return progress, nil
+}
+
+func (s *MemoryProgressStore) CreateProgress(userID uuid.UUID) (models.UserProgress, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return models.UserProgress{}, s.Err
+	}
+	return s.createLocked(userID), nil
+}
+
+func (s *MemoryProgressStore) createLocked(userID uuid.UUID) models.UserProgress {
+	now := s.Clock.Now()
+	progress := models.UserProgress{
+		ID:           uuid.New(),
+		UserID:       userID,
+		CurrentLevel: 1,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	s.progress[userID] = progress
+	return progress
+}
+
+// AwardXP holds the store lock for the whole update, as the Postgres store holds a row lock
+func (s *MemoryProgressStore) AwardXP(userID uuid.UUID, source string, amount int, metadata []byte, update services.XPUpdate) (models.UserProgress, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return models.UserProgress{}, s.Err
+	}
+
+	progress, ok := s.progress[userID]
+	if !ok {
+		progress = s.createLocked(userID)
+	}
+
+	updated, achievements := update(progress)
+	now := updated.UpdatedAt
+	s.events = append(s.events, models.XPEvent{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Source:    source,
+		XPAwarded: amount,
+		Metadata:  metadata,
+		CreatedAt: now,
+	})
+	s.progress[userID] = updated
+	for _, achievement := range achievements {
+		achievement.ID = uuid.New()
+		achievement.UserID = userID
+		achievement.UnlockedAt = now
+		s.achievements = append(s.achievements, achievement)
+	}
+	return updated, nil
+}
+
+func (s *MemoryProgressStore) CountXPEvents(userID uuid.UUID, source string, since time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return 0, s.Err
+	}
+	count := 0
+	for _, event := range s.events {
+		if event.UserID == userID && event.Source == source && !event.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemoryProgressStore) GetLevel(levelNumber int) (*models.CurriculumLevel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	level, ok := s.levels[levelNumber]
+	if !ok {
+		return nil, services.ErrProgressNotFound
+	}
+	return &level, nil
+}
+
+func (s *MemoryProgressStore) ListLevels() ([]models.CurriculumLevel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	levels := make([]models.CurriculumLevel, 0, len(s.levels))
+	for _, level := range s.levels {
+		levels = append(levels, level)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i].LevelNumber < levels[j].LevelNumber })
+	return levels, nil
+}
+
+// ListAchievements returns a page of the user's achievements, newest first and then by ID
+func (s *MemoryProgressStore) ListAchievements(userID uuid.UUID, page api.Page) ([]models.Achievement, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	achievements := []models.Achievement{}
+	for i := len(s.achievements) - 1; i >= 0; i-- {
+		a := s.achievements[i]
+		if a.UserID == userID && page.Includes(a.UnlockedAt, a.ID) {
+			achievements = append(achievements, a)
+		}
+	}
+	sort.SliceStable(achievements, func(i, j int) bool {
+		if !achievements[i].UnlockedAt.Equal(achievements[j].UnlockedAt) {
+			return achievements[i].UnlockedAt.After(achievements[j].UnlockedAt)
+		}
+		return bytes.Compare(achievements[i].ID[:], achievements[j].ID[:]) < 0
+	})
+	if page.Limit > 0 && len(achievements) > page.Limit {
+		achievements = achievements[:page.Limit]
+	}
+	return achievements, nil
+}
+
+// Leaderboard ranks like SQL RANK(): tied users share a rank, listed by user ID, and the next
+// rank is skipped
+func (s *MemoryProgressStore) Leaderboard(limit int) ([]models.LeaderboardEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return firstEntries(s.board(), limit), nil
+}
+
+// PeriodLeaderboard ranks like Leaderboard by the XP of each user's events from start until end
+func (s *MemoryProgressStore) PeriodLeaderboard(start, end time.Time, limit int) ([]models.LeaderboardEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return firstEntries(s.periodBoard(start, end), limit), nil
+}
+
+// LeaderboardAround returns the user's entry on Leaderboard with its neighbors
+func (s *MemoryProgressStore) LeaderboardAround(userID uuid.UUID, neighbors int) ([]models.LeaderboardEntry, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, 0, s.Err
+	}
+	return entriesAround(s.board(), userID, neighbors)
+}
+
+// PeriodLeaderboardAround returns the user's entry on PeriodLeaderboard with its neighbors
+func (s *MemoryProgressStore) PeriodLeaderboardAround(userID uuid.UUID, start, end time.Time, neighbors int) ([]models.LeaderboardEntry, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, 0, s.Err
+	}
+	return entriesAround(s.periodBoard(start, end), userID, neighbors)
+}
+
+// board is the whole all-time leaderboard. Callers hold s.mu.
+func (s *MemoryProgressStore) board() []models.LeaderboardEntry {
+	entries := []models.LeaderboardEntry{}
+	for userID, progress := range s.progress {
+		if s.hidden[userID] {
+			continue
+		}
+		entries = append(entries, models.LeaderboardEntry{
+			UserID:       userID,
+			CurrentLevel: progress.CurrentLevel,
+			TotalXP:      progress.TotalXP,
+		})
+	}
+	rankEntries(entries, func(e models.LeaderboardEntry) int { return e.TotalXP })
+	return entries
+}
+
+// periodBoard is the whole leaderboard from start until end. Callers hold s.mu.
+func (s *MemoryProgressStore) periodBoard(start, end time.Time) []models.LeaderboardEntry {
+	earned := make(map[uuid.UUID]int)
+	for _, event := range s.events {
+		if !event.CreatedAt.Before(start) && event.CreatedAt.Before(end) {
+			earned[event.UserID] += event.XPAwarded
+		}
+	}
+	entries := []models.LeaderboardEntry{}
+	for userID, xp := range earned {
+		progress, ok := s.progress[userID]
+		if !ok || xp <= 0 || s.hidden[userID] {
+			continue
+		}
+		entries = append(entries, models.LeaderboardEntry{
+			UserID:       userID,
+			CurrentLevel: progress.CurrentLevel,
+			TotalXP:      progress.TotalXP,
+			PeriodXP:     xp,
+		})
+	}
+	rankEntries(entries, func(e models.LeaderboardEntry) int { return e.PeriodXP })
+	return entries
+}
+
+// rankEntries sorts entries by score and ranks them like SQL RANK()
+func rankEntries(entries []models.LeaderboardEntry, score func(models.LeaderboardEntry) int) {
+	sort.Slice(entries, func(i, j int) bool {
+		if score(entries[i]) != score(entries[j]) {
+			return score(entries[i]) > score(entries[j])
+		}
+		return entries[i].UserID.String() < entries[j].UserID.String()
+	})
+	for i := range entries {
+		entries[i].Rank = i + 1
+		if i > 0 && score(entries[i]) == score(entries[i-1]) {
+			entries[i].Rank = entries[i-1].Rank
+		}
+	}
+}
+
+func firstEntries(entries []models.LeaderboardEntry, limit int) []models.LeaderboardEntry {
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+func entriesAround(entries []models.LeaderboardEntry, userID uuid.UUID, neighbors int) ([]models.LeaderboardEntry, int, error) {
+	for i, entry := range entries {
+		if entry.UserID == userID {
+			from, to := i-neighbors, i+neighbors+1
+			if from < 0 {
+				from = 0
+			}
+			if to > len(entries) {
+				to = len(entries)
+			}
+			return entries[from:to], len(entries), nil
+		}
+	}
+	return []models.LeaderboardEntry{}, 0, nil
+}
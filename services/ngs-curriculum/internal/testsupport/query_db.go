@@ -0,0 +1,68 @@
+package testsupport
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+
+	"noble-ngs-curriculum/internal/database"
+)
+
+// Query answers the statements whose SQL contains Match
+type Query struct {
+	Match   string
+	Columns []string
+	Rows    [][]driver.Value
+	// RowsAffected is what a matching Exec reports
+	RowsAffected int64
+	// Args, when set, collects the arguments of each matching statement run
+	Args *[][]driver.Value
+}
+
+// QueryDB returns a database that answers each statement, prepared or not, with the first of
+// queries whose Match its SQL contains, so a service running several queries can be fed each
+// one's rows. Statements nothing matches yield no rows and affect none.
+func QueryDB(queries ...Query) *database.DB {
+	return &database.DB{DB: sql.OpenDB(queryConnector(queries))}
+}
+
+type queryConnector []Query
+
+func (c queryConnector) Connect(context.Context) (driver.Conn, error) { return queryConn(c), nil }
+func (c queryConnector) Driver() driver.Driver                        { return c }
+func (c queryConnector) Open(string) (driver.Conn, error)             { return queryConn(c), nil }
+
+type queryConn []Query
+
+func (c queryConn) Prepare(query string) (driver.Stmt, error) {
+	for _, q := range c {
+		if strings.Contains(query, q.Match) {
+			return queryStmt(q), nil
+		}
+	}
+	return queryStmt{}, nil
+}
+func (c queryConn) Close() error              { return nil }
+func (c queryConn) Begin() (driver.Tx, error) { return rowsTx{}, nil }
+
+type queryStmt Query
+
+func (s queryStmt) Close() error  { return nil }
+func (s queryStmt) NumInput() int { return -1 }
+
+func (s queryStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.record(args)
+	return driver.RowsAffected(s.RowsAffected), nil
+}
+
+func (s queryStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.record(args)
+	return &cannedRows{columns: s.Columns, rows: s.Rows}, nil
+}
+
+func (s queryStmt) record(args []driver.Value) {
+	if s.Args != nil {
+		*s.Args = append(*s.Args, args)
+	}
+}
@@ -0,0 +1,66 @@
+package testsupport
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+
+	"noble-ngs-curriculum/internal/database"
+)
+
+// RowsDB returns a database whose every query, prepared or not, yields rows under columns.
+// Scan code can then be fed values Postgres would return, such as NULLs, without a server.
+// Values are driver values: nil for NULL, string, []byte, int64, bool or time.Time.
+func RowsDB(columns []string, rows ...[]driver.Value) *database.DB {
+	return &database.DB{DB: sql.OpenDB(rowsConnector{columns: columns, rows: rows})}
+}
+
+type rowsConnector struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (c rowsConnector) Connect(context.Context) (driver.Conn, error) { return rowsConn(c), nil }
+func (c rowsConnector) Driver() driver.Driver                        { return c }
+func (c rowsConnector) Open(string) (driver.Conn, error)             { return rowsConn(c), nil }
+
+type rowsConn rowsConnector
+
+func (c rowsConn) Prepare(string) (driver.Stmt, error) { return rowsStmt(c), nil }
+func (c rowsConn) Close() error                        { return nil }
+func (c rowsConn) Begin() (driver.Tx, error)           { return rowsTx{}, nil }
+
+type rowsTx struct{}
+
+func (rowsTx) Commit() error   { return nil }
+func (rowsTx) Rollback() error { return nil }
+
+type rowsStmt rowsConn
+
+func (s rowsStmt) Close() error  { return nil }
+func (s rowsStmt) NumInput() int { return -1 }
+
+func (s rowsStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(0), nil }
+
+func (s rowsStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &cannedRows{columns: s.columns, rows: s.rows}, nil
+}
+
+type cannedRows struct {
+	columns []string
+	rows    [][]driver.Value
+	next    int
+}
+
+func (r *cannedRows) Columns() []string { return r.columns }
+func (r *cannedRows) Close() error      { return nil }
+
+func (r *cannedRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
@@ -1,17 +1,33 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"log"
+	"net"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"noble-ngs-curriculum/internal/cache"
+	"noble-ngs-curriculum/internal/clients/blobstore"
 	"noble-ngs-curriculum/internal/clients/intelligence"
+	"noble-ngs-curriculum/internal/clients/registry"
+	"noble-ngs-curriculum/internal/clients/tts"
+	"noble-ngs-curriculum/internal/clients/users"
 	"noble-ngs-curriculum/internal/config"
 	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/domain/guardrails"
+	"noble-ngs-curriculum/internal/domain/xp"
+	"noble-ngs-curriculum/internal/egress"
+	"noble-ngs-curriculum/internal/faults"
 	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/jobs"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/mtls"
 	"noble-ngs-curriculum/internal/services"
 
 	"github.com/gofiber/fiber/v2"
@@ -19,7 +35,6 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -51,65 +66,384 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
-	// Connect to database
-	db, err := database.Connect(cfg.DatabaseURL)
+	// Dependency faults for exercising retries and degraded modes, never in production
+	var faultInjector *faults.Injector
+	if cfg.FaultInjection != "" {
+		if !faults.Allowed(cfg.Environment) {
+			log.Printf("Ignoring FAULT_INJECTION: only allowed when ENVIRONMENT is development or staging, not %q", cfg.Environment)
+		} else {
+			rules, err := faults.Parse(cfg.FaultInjection)
+			if err != nil {
+				log.Fatalf("Invalid FAULT_INJECTION: %v", err)
+			}
+			faultInjector = faults.New(rules)
+			for _, rule := range rules {
+				log.Printf("Injecting fault %s", rule)
+			}
+		}
+	}
+
+	// Outbound proxy and allowlist, applied before any client is built
+	egressPolicy, err := egress.ParsePolicy(cfg.EgressProxyURL, cfg.EgressNoProxy, cfg.EgressAllowlist)
+	if err != nil {
+		log.Fatalf("Invalid egress configuration: %v", err)
+	}
+	egress.Configure(egressPolicy)
+	if egressPolicy.Proxy != nil {
+		log.Printf("Outbound requests go through proxy %s", egressPolicy.Proxy.Redacted())
+	}
+	if len(egressPolicy.Allowlist) > 0 {
+		log.Printf("Outbound requests limited to %s", strings.Join(egressPolicy.Allowlist, ", "))
+	}
+
+	// XP formula for every award, from XP_FORMULA
+	xpCalculator, err := xp.NewCalculator(cfg.XPFormula, cfg.XPDifficultyMultipliers)
+	if err != nil {
+		log.Fatalf("Invalid XP formula: %v", err)
+	}
+	if xpCalculator.CountsRepeats() && cfg.XPRepeatWindowHours <= 0 {
+		log.Fatalf("XP_REPEAT_WINDOW_HOURS must be positive")
+	}
+	log.Printf("Awarding XP with the %s formula", xpCalculator.Name())
+	xpDecay, err := xp.ParseDecayCurves(cfg.XPDecayCurves)
+	if err != nil {
+		log.Fatalf("Invalid XP_DECAY_CURVES: %v", err)
+	}
+	services.ConfigureXPDecay(xpDecay)
+	levelPolicy, err := xp.NewLevelPolicy(cfg.LevelDownPolicy, cfg.LevelDownGraceXP, cfg.AgentUnlockPolicy)
+	if err != nil {
+		log.Fatalf("Invalid level policy: %v", err)
+	}
+	log.Printf("Levels lost on falling XP: %s (grace %d XP); agent unlock: %s", levelPolicy.LevelDown, levelPolicy.GraceXP, levelPolicy.AgentUnlock)
+	if cfg.PrestigeMilestoneXP < 0 {
+		log.Fatalf("PRESTIGE_MILESTONE_XP must not be negative")
+	}
+	services.ConfigurePrestige(xp.NewPrestige(cfg.LevelUpXPThresholds, cfg.PrestigeMilestoneXP))
+	ethicsSections, err := guardrails.ParseSections(cfg.GuardrailEthicsSections)
+	if err != nil {
+		log.Fatalf("Invalid GUARDRAIL_ETHICS_SECTIONS: %v", err)
+	}
+	if cfg.GuardrailReadingLevelMin < 0 || (cfg.GuardrailReadingLevelMax > 0 && cfg.GuardrailReadingLevelMax < cfg.GuardrailReadingLevelMin) {
+		log.Fatalf("GUARDRAIL_READING_LEVEL_MIN must be from 0 to GUARDRAIL_READING_LEVEL_MAX")
+	}
+	services.ConfigureGuardrails(guardrails.Policy{
+		ReadingLevelMin: cfg.GuardrailReadingLevelMin,
+		ReadingLevelMax: cfg.GuardrailReadingLevelMax,
+		EthicsSections:  ethicsSections,
+	})
+
+	// X-User-* headers must carry the gateway's signature when GATEWAY_SIGNING_SECRET is set
+	gatewayVerifier := services.NewGatewayVerifier(cfg, services.SystemClock{})
+	if gatewayVerifier.Enabled() {
+		if cfg.GatewaySignatureMaxSkewSec <= 0 {
+			log.Fatalf("GATEWAY_SIGNATURE_MAX_SKEW_SECONDS must be positive")
+		}
+		log.Println("Identity headers require a gateway signature")
+	}
+
+	// Callers are identified by bearer JWT, or by X-User-* headers in headers mode
+	if !middleware.ValidMode(cfg.AuthMode) {
+		log.Fatalf("AUTH_MODE must be headers or jwt, not %q", cfg.AuthMode)
+	}
+	var jwtVerifier *middleware.JWTVerifier
+	if cfg.JWTSecret != "" || cfg.JWTPublicKeyFile != "" {
+		var publicKey []byte
+		if cfg.JWTPublicKeyFile != "" {
+			publicKey, err = os.ReadFile(cfg.JWTPublicKeyFile)
+			if err != nil {
+				log.Fatalf("Failed to read JWT_PUBLIC_KEY_FILE: %v", err)
+			}
+		}
+		jwtVerifier, err = middleware.NewJWTVerifier(cfg.JWTSecret, publicKey, cfg.JWTIssuer, cfg.JWTAudience,
+			time.Duration(cfg.JWTLeewaySeconds)*time.Second, time.Now)
+		if err != nil {
+			log.Fatalf("Invalid JWT configuration: %v", err)
+		}
+	}
+	if cfg.AuthMode == middleware.ModeJWT && jwtVerifier == nil {
+		log.Fatalf("AUTH_MODE=jwt requires JWT_SECRET or JWT_PUBLIC_KEY_FILE")
+	}
+	if cfg.AuthMode == middleware.ModeHeaders && !gatewayVerifier.Enabled() && cfg.Environment == "production" {
+		log.Fatalf("Refusing to trust unsigned X-User-* headers in production; set AUTH_MODE=jwt or GATEWAY_SIGNING_SECRET")
+	}
+	log.Printf("Authenticating callers in %s mode", cfg.AuthMode)
+
+	// Admin and internal routes move to a mutual TLS listener when INTERNAL_PORT is set
+	var internalListener net.Listener
+	if cfg.InternalPort != "" {
+		allowedClients := mtls.ParseAllowlist(cfg.InternalTLSAllowedClients)
+		tlsConfig, err := mtls.ServerConfig(cfg.InternalTLSCert, cfg.InternalTLSKey, cfg.InternalTLSClientCA, allowedClients)
+		if err != nil {
+			log.Fatalf("Invalid internal listener TLS: %v", err)
+		}
+		ln, err := net.Listen("tcp", "0.0.0.0:"+cfg.InternalPort)
+		if err != nil {
+			log.Fatalf("Failed to listen on internal port %s: %v", cfg.InternalPort, err)
+		}
+		internalListener = tls.NewListener(ln, tlsConfig)
+	}
+
+	// Wait for the database and its schema before binding the port, so no replica serves
+	// requests it would fail
+	startupCtx := context.Background()
+	startupBackoff := time.Duration(cfg.StartupMaxBackoffSec) * time.Second
+	var db *database.DB
+	err = services.WaitForDependency(startupCtx, "Database", cfg.StartupCheckAttempts, startupBackoff, func(ctx context.Context) error {
+		var err error
+		db, err = database.Connect(cfg.DatabaseURL, time.Duration(cfg.DBSlowQueryMs)*time.Millisecond)
+		return err
+	})
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
-	defer db.Close()
+	if faultInjector != nil {
+		db.SetFaultInjector(faultInjector)
+	}
+	if err := services.WaitForDependency(startupCtx, "Database schema", cfg.StartupCheckAttempts, startupBackoff, db.CheckSchemaVersion); err != nil {
+		log.Fatalf("Database schema check failed: %v", err)
+	}
+
+	// Replicas starting together seed one at a time; the rest then find the content in place
+	seedLock, err := db.AcquireLock(startupCtx, "ngs_seed")
+	if err != nil {
+		log.Fatalf("Failed to take seeding lock: %v", err)
+	}
+
+	// Seed the built-in curriculum pack's missing levels and lessons (idempotent)
+	if err := services.SeedCurriculum(db, cfg); err != nil {
+		log.Fatalf("Failed to seed curriculum: %v", err)
+	}
 
-	// Seed curriculum levels (idempotent)
-	if err := services.SeedCurriculumLevels(db, cfg.LevelUpXPThresholds); err != nil {
-		log.Fatalf("Failed to seed curriculum levels: %v", err)
+	// Lesson prerequisites must stay acyclic for the curriculum graph
+	if err := services.ValidateCurriculumGraph(db); err != nil {
+		log.Fatalf("Invalid curriculum graph: %v", err)
 	}
 
-	// Seed baseline lessons (idempotent)
-	if err := services.SeedLessons(db); err != nil {
-		log.Fatalf("Failed to seed lessons: %v", err)
+	if err := seedLock.Release(); err != nil {
+		log.Printf("Seeding lock: %v", err)
+	}
+
+	// Large challenge submissions are offloaded here when a blob store is configured
+	blobStore, err := blobstore.NewStore(cfg.BlobStore, cfg.BlobStoreURL, cfg.BlobStoreToken)
+	if err != nil {
+		log.Fatalf("Failed to configure blob store: %v", err)
+	}
+
+	// Challenge submissions run on the judge; the placeholder passes every test without running it
+	sandbox, err := services.NewSandbox(cfg.Sandbox, cfg.SandboxURL, cfg.SandboxAPIKey)
+	if err != nil {
+		log.Fatalf("Failed to configure sandbox: %v", err)
+	}
+	if _, ok := sandbox.(services.PlaceholderSandbox); ok && cfg.Environment == "production" {
+		log.Println("WARNING: SANDBOX=placeholder passes every challenge submission without running it")
 	}
 
 	// Initialize services
-	progressService := services.NewProgressService(db, cfg)
+	clock := services.SystemClock{}
+	progressService := services.NewProgressService(db, cfg, clock)
+	progressService.SetXPCalculator(xpCalculator)
 	lessonService := services.NewLessonService(db)
-	challengeService := services.NewChallengeService(db)
+	challengeService := services.NewChallengeService(db, cfg, blobStore)
+	challengeService.SetSandbox(sandbox)
+	duelService := services.NewDuelService(db, cfg, challengeService, clock)
+	duelHub := services.NewDuelHub(cfg.DatabaseURL)
+	collaborationService := services.NewCollaborationService(db, cfg, challengeService, clock)
+	examService := services.NewExamService(db, cfg, challengeService, clock)
+	settingsService := services.NewSettingsService(db)
+	consentService := services.NewConsentService(db, cfg, clock)
+	cohortService := services.NewCohortService(db)
+	graphService := services.NewGraphService(db)
+	lessonAuthoringService := services.NewLessonAuthoringService(db)
+	challengeAuthoringService := services.NewChallengeAuthoringService(db)
+	questionAnalyticsService := services.NewQuestionAnalyticsService(db)
+	analyticsService := services.NewAnalyticsService(db, cohortService, clock)
+	curriculumPackService := services.NewCurriculumPackService(db, cfg)
+	retentionService := services.NewRetentionService(db, cfg, clock)
+	idempotencyService := services.NewIdempotencyService(db, cfg, clock)
+	partitionService := services.NewPartitionService(db, cfg, clock)
+	maintenanceService := services.NewMaintenanceService(db, cfg, clock)
+	experimentService := services.NewExperimentService(db)
+	economyService := services.NewEconomyService(db, cfg)
+	importService := services.NewImportService(db, cfg, clock)
+	contentImportService := services.NewContentImportService(db, cfg)
+	recommendationService := services.NewRecommendationService(db, settingsService)
+	summaryService := services.NewSummaryService(db, clock)
+	usageService := services.NewUsageService(db, cfg, clock)
+	prestigeService := services.NewPrestigeService(db, cfg)
+	alumniService := services.NewAlumniService(db, cfg, clock)
+	weakTopicService := services.NewWeakTopicService(db, cfg, clock)
+	impersonationService := services.NewImpersonationService(db, cfg, clock)
+	accountService := services.NewAccountService(db, cfg, clock)
+	reconciliationService := services.NewReconciliationService(db, cfg, clock)
+	snapshotService := services.NewSnapshotService(db, cfg, clock)
+
+	// Shared cache; replicas fall back to memory while Redis is unreachable
+	appCache, err := cache.New(cfg.RedisURL)
+	if err != nil {
+		log.Fatalf("Failed to configure cache: %v", err)
+	}
+	log.Printf("Caching in %s", appCache.Name())
+	progressService.SetCache(appCache)
+	// Awards from every XP writer drop the cached leaderboards
+	lessonService.SetLeaderboardInvalidator(progressService)
+	challengeService.SetLeaderboardInvalidator(progressService)
+	importService.SetLeaderboardInvalidator(progressService)
+
+	// In projection mode every XP writer applies its events through one projection, so
+	// user_progress can be rebuilt from xp_events with cmd/ngs-progress-replay
+	switch cfg.ProgressMode {
+	case services.ProgressModeDirect:
+	case services.ProgressModeProjection:
+		projection := services.NewProgressProjection(db, cfg, clock)
+		lessonService.SetProgressProjection(projection)
+		challengeService.SetProgressProjection(projection)
+		log.Println("Progress is projected from xp_events")
+	default:
+		log.Fatalf("Invalid PROGRESS_MODE %q: use direct or projection", cfg.ProgressMode)
+	}
 
-	// Initialize Intelligence client
-	intelligenceURL := os.Getenv("INTELLIGENCE_SERVICE_URL")
-	if intelligenceURL == "" {
-		intelligenceURL = "http://localhost:8000"
+	ttsProvider, err := tts.NewProvider(cfg.TTSProvider, cfg.TTSProviderURL, cfg.TTSAPIKey)
+	if err != nil {
+		log.Fatalf("Failed to configure TTS provider: %v", err)
 	}
-	
-	serviceJWTSecret := os.Getenv("SERVICE_JWT_SECRET")
-	if serviceJWTSecret == "" {
+	audioService := services.NewAudioService(db, cfg, ttsProvider, clock)
+
+	// Initialize Intelligence client
+	if cfg.ServiceJWTSecret == "" {
 		log.Fatal("SERVICE_JWT_SECRET environment variable is required")
 	}
-	
-	getServiceToken := func() string {
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"service": "ngs-curriculum",
-			"exp":     time.Now().Add(24 * time.Hour).Unix(),
-		})
-		tokenString, err := token.SignedString([]byte(serviceJWTSecret))
-		if err != nil {
-			log.Printf("Failed to generate service token: %v", err)
-			return ""
+	intelligenceClient := intelligence.NewClient(cfg.IntelligenceServiceURL, intelligence.ServiceTokenProvider(cfg.ServiceJWTSecret))
+	if faultInjector != nil {
+		intelligenceClient.SetFaultInjector(faultInjector)
+	}
+	if cfg.UserServiceURL != "" {
+		progressService.SetUserDirectory(users.NewClient(cfg.UserServiceURL, intelligence.ServiceTokenProvider(cfg.ServiceJWTSecret),
+			appCache, time.Duration(cfg.UserProfileCacheSeconds)*time.Second))
+		log.Printf("Leaderboard users are named by %s", cfg.UserServiceURL)
+	}
+	routingPolicy, err := intelligence.ParseRoutingPolicy(cfg.LLMProviderPolicy)
+	if err != nil {
+		log.Fatalf("Invalid LLM_PROVIDER_POLICY: %v", err)
+	}
+	intelligenceClient.SetRoutingPolicy(routingPolicy)
+	log.Printf("LLM providers by operation: %s", routingPolicy)
+	// Tokens are priced per provider and summed per day and organization, against the daily budgets
+	llmPricing, err := services.ParseLLMPricing(cfg.LLMPricing)
+	if err != nil {
+		log.Fatalf("Invalid LLM_PRICING: %v", err)
+	}
+	llmCostService := services.NewLLMCostService(db, cfg, clock, llmPricing)
+	intelligenceClient.SetUsageRecorder(llmCostService.Record)
+	log.Printf("LLM prices in USD per 1,000 tokens: %s", llmPricing)
+	if cfg.StartupCheckIntelligence {
+		if err := services.WaitForDependency(startupCtx, "Intelligence service", cfg.StartupCheckAttempts, startupBackoff, intelligenceClient.Ping); err != nil {
+			log.Fatalf("Intelligence service check failed: %v", err)
 		}
-		return tokenString
 	}
-	
-	intelligenceClient := intelligence.NewClient(intelligenceURL, getServiceToken)
+
+	// Background jobs share one pool of workers, started in priority order
+	jobSettings, err := services.DefaultJobSettings(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure job queue: %v", err)
+	}
+	jobQueue := jobs.New(jobSettings)
+	jobQueueService := services.NewJobQueueService(db, jobSettings, jobQueue, clock)
+	// Per-organization usage for billing, in multi-tenant deployments
+	meteringService := services.NewMeteringService(db, cfg, clock)
+	orgService := services.NewOrganizationService(db, cfg, clock)
+
+	// Failed jobs are kept as dead letters for an admin to retry or discard
+	jobService := services.NewJobService(db, jobQueue, clock)
+	mediaService := services.NewMediaService(db, intelligenceClient, jobService)
+	// Weak topics that reach REMEDIATION_THRESHOLD get a generated micro-lesson
+	remediationService := services.NewRemediationService(db, cfg, clock, intelligenceClient, jobService)
+	weakTopicService.SetRemediationService(remediationService)
+	// Quiet tutor chat sessions are summarized into a memory that seeds later sessions
+	learnerMemoryService := services.NewLearnerMemoryService(db, cfg, clock, intelligenceClient, jobService)
+
+	// Low-priority routes are turned away while the database pool or a job queue is saturated
+	loadShedder := services.NewLoadShedder(db, cfg, clock)
+	loadShedder.AddQueue("jobs", jobQueue.Depth)
+
+	// Design submissions go to the educator review queue unless AI evaluation is on
+	switch cfg.DesignReview {
+	case "educator":
+	case "ai":
+		challengeService.SetDesignEvaluator(intelligenceClient)
+	default:
+		log.Fatalf("Invalid DESIGN_REVIEW %q: use educator or ai", cfg.DesignReview)
+	}
+
+	serviceRegistry, err := registry.NewRegistry(cfg.ServiceRegistry, cfg.ServiceRegistryURL, cfg.ServiceRegistryToken)
+	if err != nil {
+		log.Fatalf("Failed to configure service registry: %v", err)
+	}
+	registrationService := services.NewRegistrationService(serviceRegistry, cfg)
 
 	// Initialize handlers
 	handler := handlers.NewHandler(progressService)
 	lessonHandler := handlers.NewLessonHandler(lessonService, intelligenceClient)
+	lessonHandler.SetLearnerPreferences(settingsService)
+	lessonHandler.SetWeakTopics(weakTopicService)
+	lessonHandler.SetLessonContext(mediaService)
+	chatFeedbackService := services.NewChatFeedbackService(db)
+	lessonHandler.SetChatRecorder(chatFeedbackService)
+	lessonHandler.SetLearnerMemory(learnerMemoryService)
+	chatFeedbackHandler := handlers.NewChatFeedbackHandler(chatFeedbackService)
 	challengeHandler := handlers.NewChallengeHandler(challengeService)
+	duelHandler := handlers.NewDuelHandler(duelService, duelHub)
+	collaborationHandler := handlers.NewCollaborationHandler(collaborationService)
+	examHandler := handlers.NewExamHandler(examService)
+	recommendationHandler := handlers.NewRecommendationHandler(recommendationService)
+	summaryHandler := handlers.NewSummaryHandler(summaryService)
+	settingsHandler := handlers.NewSettingsHandler(settingsService)
+	usageHandler := handlers.NewUsageHandler(usageService)
+	learnerMemoryHandler := handlers.NewLearnerMemoryHandler(learnerMemoryService)
+	prestigeHandler := handlers.NewPrestigeHandler(prestigeService)
+	alumniHandler := handlers.NewAlumniHandler(alumniService)
+	weakTopicHandler := handlers.NewWeakTopicHandler(weakTopicService)
+	remediationHandler := handlers.NewRemediationHandler(remediationService)
+	consentHandler := handlers.NewConsentHandler(consentService)
+	cohortHandler := handlers.NewCohortHandler(cohortService)
+	experimentHandler := handlers.NewExperimentHandler(experimentService)
+	adminHandler := handlers.NewAdminHandler(economyService, importService, contentImportService)
+	audioHandler := handlers.NewAudioHandler(audioService)
+	mediaHandler := handlers.NewMediaHandler(mediaService)
+	graphHandler := handlers.NewGraphHandler(graphService)
+	authoringHandler := handlers.NewAuthoringHandler(lessonAuthoringService)
+	lessonVersionHandler := handlers.NewLessonVersionHandler(services.NewLessonVersionService(db))
+	challengeAuthoringHandler := handlers.NewChallengeAuthoringHandler(challengeAuthoringService)
+	questionAnalyticsHandler := handlers.NewQuestionAnalyticsHandler(questionAnalyticsService)
+	analyticsHandler := handlers.NewAnalyticsHandler(analyticsService)
+	curriculumPackHandler := handlers.NewCurriculumPackHandler(curriculumPackService)
+	retentionHandler := handlers.NewRetentionHandler(retentionService)
+	maintenanceHandler := handlers.NewMaintenanceHandler(maintenanceService)
+	impersonationHandler := handlers.NewImpersonationHandler(impersonationService)
+	accountHandler := handlers.NewAccountHandler(accountService)
+	reconciliationHandler := handlers.NewReconciliationHandler(reconciliationService)
+	snapshotHandler := handlers.NewSnapshotHandler(snapshotService)
+	loadShedHandler := handlers.NewLoadShedHandler(loadShedder)
+	jobQueueHandler := handlers.NewJobQueueHandler(jobQueueService)
+	deadLetterHandler := handlers.NewDeadLetterHandler(jobService)
+	meteringHandler := handlers.NewMeteringHandler(meteringService, clock)
+	llmCostHandler := handlers.NewLLMCostHandler(llmCostService, clock)
+	orgHandler := handlers.NewOrgHandler(orgService, meteringService, clock)
+	lowPriority := loadShedHandler.LowPriority
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
 		AppName:      "Noble Growth School (NGS) Curriculum v1.0.0",
 		ErrorHandler: customErrorHandler,
+		// The largest route limit; BodyLimit below enforces the per-route ones
+		BodyLimit: max(cfg.MaxBodyBytes, cfg.ImportMaxBodyBytes, cfg.DesignMaxBodyBytes),
 	})
 
+	// Lesson content, transcripts and search results can be hundreds of KB
+	compressContent, err := handlers.CompressContent(cfg.CompressionLevel)
+	if err != nil {
+		log.Fatalf("Invalid COMPRESSION_LEVEL: %v", err)
+	}
+
 	// Prometheus metrics endpoint
 	app.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
 
@@ -143,9 +477,22 @@ func main() {
 	}))
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: cfg.AllowedOrigins,
-		AllowHeaders: "Origin, Content-Type, Accept, X-User-Id, Authorization",
+		AllowHeaders: "Origin, Content-Type, Accept, X-User-Id, X-User-Role, X-Impersonation-Token, Authorization",
 		AllowMethods: "GET, POST, PUT, PATCH, DELETE, OPTIONS",
 	}))
+	if internalListener != nil {
+		app.Use(handlers.InternalOnly("/ngs/admin/", "/ngs/internal/"))
+	}
+	// Identity headers are checked before anything trusts them, impersonation included
+	if gatewayVerifier.Enabled() {
+		app.Use(handlers.GatewaySignature(gatewayVerifier))
+	}
+	// Everything after this reads the caller from middleware.GetIdentity
+	app.Use(middleware.Auth(cfg.AuthMode, jwtVerifier))
+	app.Use(maintenanceHandler.Middleware)
+	app.Use(handlers.BodyLimit(cfg.MaxBodyBytes, "/ngs/admin/import/", "/ngs/challenges/*/design", "/ngs/admin/users/*/restore", "/ngs/admin/curriculum/import"))
+	app.Use(impersonationHandler.Middleware)
+	app.Use(meteringHandler.Middleware)
 
 	// Routes
 	app.Get("/", handler.Info)
@@ -153,40 +500,244 @@ func main() {
 
 	// Progress routes
 	app.Get("/ngs/progress", handler.GetProgress)
-	app.Post("/ngs/award-xp", handler.AwardXP)
-	app.Post("/ngs/complete-lesson", handler.CompleteLesson)
+	idempotent := handlers.Idempotent(idempotencyService)
+	app.Post("/ngs/award-xp", idempotent, handler.AwardXP)
+	app.Post("/ngs/complete-lesson", handlers.BodyLimit(cfg.ReflectionMaxBodyBytes), idempotent, lessonHandler.CompleteLegacyLesson)
 
 	// Achievement routes
 	app.Get("/ngs/achievements", handler.GetAchievements)
 
 	// Leaderboard routes
-	app.Get("/ngs/leaderboard", handler.GetLeaderboard)
+	app.Get("/ngs/leaderboard", lowPriority, handler.GetLeaderboard)
+	app.Get("/ngs/leaderboard/me", lowPriority, handler.GetLeaderboardPosition)
 
 	// Level routes
 	app.Get("/ngs/levels", handler.GetLevels)
 	app.Get("/ngs/levels/:level", handler.GetLevel)
 
+	// Curriculum map routes
+	app.Get("/ngs/curriculum/graph", compressContent, graphHandler.GetCurriculumGraph)
+	app.Get("/ngs/curriculum/map", graphHandler.GetCurriculumMap)
+
 	// Lesson routes
-	app.Get("/ngs/levels/:level/lessons", lessonHandler.GetLessonsByLevel)
-	app.Get("/ngs/lessons/:id", lessonHandler.GetLesson)
-	app.Post("/ngs/lessons/:id/complete", lessonHandler.CompleteLessonHandler)
+	app.Get("/ngs/levels/:level/lessons", compressContent, lessonHandler.GetLessonsByLevel)
+	app.Get("/ngs/lessons/completions", lessonHandler.GetCompletedLessons)
+	app.Get("/ngs/lessons/:id", compressContent, lessonHandler.GetLesson)
+	app.Post("/ngs/lessons/:id/complete", handlers.BodyLimit(cfg.ReflectionMaxBodyBytes), idempotent, lessonHandler.CompleteLessonHandler)
+	app.Post("/ngs/lessons/:id/quiz", lessonHandler.SubmitLessonQuiz)
 	
 	// Intelligent lesson generation routes
 	app.Post("/ngs/lessons/:id/generate", lessonHandler.GenerateLesson)
-	app.Get("/ngs/lessons/:id/content", lessonHandler.GetLessonContent)
-	app.Post("/ngs/lessons/:id/chat/message", lessonHandler.SendEducatorChatMessage)
+	app.Get("/ngs/lessons/:id/content", compressContent, lessonHandler.GetLessonContent)
+	app.Get("/ngs/lessons/:id/export", compressContent, lessonHandler.ExportLesson)
+	app.Get("/ngs/lessons/:id/versions", lessonVersionHandler.ListLessonVersions)
+	app.Get("/ngs/lessons/:id/versions/:a/diff/:b", compressContent, lessonVersionHandler.DiffLessonVersions)
+	app.Post("/ngs/lessons/:id/chat", handlers.BodyLimit(cfg.ChatMaxBodyBytes), lessonHandler.SendEducatorChatMessage)
+	app.Post("/ngs/lessons/:id/chat/message", handlers.BodyLimit(cfg.ChatMaxBodyBytes), lessonHandler.SendEducatorChatMessage)
+	app.Post("/ngs/lessons/:id/chat/stream", handlers.BodyLimit(cfg.ChatMaxBodyBytes), lessonHandler.StreamEducatorChatMessage)
+	app.Put("/ngs/chat/messages/:id/feedback", chatFeedbackHandler.SetFeedback)
+	app.Post("/ngs/chat/messages/:id/escalate", chatFeedbackHandler.Escalate)
+	app.Get("/ngs/chat/escalations", chatFeedbackHandler.ListEscalations)
+
+	// Lesson audio routes
+	app.Get("/ngs/lessons/:id/audio", audioHandler.GetLessonAudio)
+	app.Get("/ngs/audio/:id", audioHandler.StreamAudio)
+
+	// Lesson media and artifact routes
+	app.Post("/ngs/lessons/:id/media", mediaHandler.AttachMedia)
+	app.Get("/ngs/lessons/:id/media", mediaHandler.ListLessonMedia)
+	app.Get("/ngs/lessons/:id/artifacts", compressContent, mediaHandler.GetLessonArtifacts)
+
+	// Search routes
+	app.Get("/ngs/search", compressContent, mediaHandler.Search)
 
 	// Reflection routes
 	app.Get("/ngs/reflections", lessonHandler.GetReflections)
-	app.Post("/ngs/reflections", lessonHandler.SubmitReflection)
+	app.Post("/ngs/reflections", handlers.BodyLimit(cfg.ReflectionMaxBodyBytes), lessonHandler.SubmitReflection)
 
 	// Challenge routes
 	app.Get("/ngs/levels/:level/challenges", challengeHandler.GetChallengesByLevel)
 	app.Get("/ngs/challenges/:id", challengeHandler.GetChallenge)
-	app.Post("/ngs/challenges/:id/submit", challengeHandler.SubmitChallenge)
+	app.Post("/ngs/challenges/:id/submit", handlers.BodyLimit(cfg.SubmissionMaxBodyBytes), challengeHandler.SubmitChallenge)
 	app.Get("/ngs/challenges/submissions", challengeHandler.GetUserSubmissions)
-
-	// Start server in a goroutine
+	app.Put("/ngs/challenges/submissions/:id/share", challengeHandler.ShareSubmission)
+	app.Get("/ngs/challenges/:id/solutions", challengeHandler.GetSolutions)
+	app.Get("/ngs/challenges/:id/leaderboard", lowPriority, challengeHandler.GetRuntimeLeaderboard)
+	app.Post("/ngs/challenges/:id/design", handlers.BodyLimit(cfg.DesignMaxBodyBytes), challengeHandler.SubmitDesign)
+	app.Get("/ngs/challenges/submissions/review-queue", challengeHandler.GetReviewQueue)
+	app.Post("/ngs/challenges/submissions/:id/review", challengeHandler.ReviewSubmission)
+	app.Get("/ngs/challenges/submissions/:id/assets/:asset_id", challengeHandler.GetDesignAsset)
+
+	// Collaboration challenge routes
+	app.Get("/ngs/challenges/:id/collaborations", collaborationHandler.ListCollaborations)
+	app.Post("/ngs/challenges/:id/collaborations", collaborationHandler.CreateCollaboration)
+	app.Get("/ngs/collaborations/:id", collaborationHandler.GetCollaboration)
+	app.Post("/ngs/collaborations/:id/join", collaborationHandler.JoinCollaboration)
+	app.Put("/ngs/collaborations/:id/section", handlers.BodyLimit(cfg.SubmissionMaxBodyBytes), collaborationHandler.UpdateSection)
+	app.Post("/ngs/collaborations/:id/leave", collaborationHandler.LeaveCollaboration)
+	app.Post("/ngs/collaborations/:id/submit", collaborationHandler.SubmitCollaboration)
+
+	// Duel routes
+	app.Post("/ngs/duels/queue", duelHandler.JoinQueue)
+	app.Get("/ngs/duels/history", duelHandler.GetDuelHistory)
+	app.Get("/ngs/duels/rating", duelHandler.GetDuelRating)
+	app.Get("/ngs/duels/:id", duelHandler.GetDuel)
+	app.Get("/ngs/duels/:id/ws", duelHandler.AcceptDuelSocket, duelHandler.DuelSocket())
+	app.Post("/ngs/duels/:id/submit", handlers.BodyLimit(cfg.SubmissionMaxBodyBytes), duelHandler.SubmitDuel)
+	app.Post("/ngs/duels/:id/cancel", duelHandler.CancelDuel)
+
+	// Level exam and certification routes
+	app.Post("/ngs/levels/:level/exam", examHandler.StartExam)
+	app.Get("/ngs/exams/:id", examHandler.GetExam)
+	app.Post("/ngs/exams/:id/submit", examHandler.SubmitExam)
+	app.Get("/ngs/certifications", examHandler.GetCertifications)
+
+	// Continue learning route
+	app.Get("/ngs/continue", recommendationHandler.GetContinue)
+
+	// Summary routes
+	app.Get("/ngs/summary/weekly", lowPriority, summaryHandler.GetWeeklySummary)
+	app.Get("/ngs/activity/heatmap", lowPriority, summaryHandler.GetActivityHeatmap)
+
+	// Settings routes
+	app.Get("/ngs/settings", settingsHandler.GetSettings)
+	app.Patch("/ngs/settings", settingsHandler.UpdateSettings)
+	app.Get("/ngs/settings/learning", settingsHandler.GetLearningPreferences)
+	app.Put("/ngs/settings/learning", settingsHandler.UpdateLearningPreferences)
+
+	// Quota usage route
+	app.Get("/ngs/me/usage", usageHandler.GetUsage)
+	app.Get("/ngs/me/memory", learnerMemoryHandler.GetMemory)
+	app.Delete("/ngs/me/memory", learnerMemoryHandler.ForgetMemory)
+
+	// Prestige route
+	app.Get("/ngs/prestige", prestigeHandler.GetPrestige)
+
+	// Alumni route
+	app.Get("/ngs/alumni/feed", lowPriority, alumniHandler.GetFeed)
+
+	// Weak topic route
+	app.Get("/ngs/weak-topics", lowPriority, weakTopicHandler.GetWeakTopics)
+
+	// Remediation routes
+	app.Get("/ngs/remediation", remediationHandler.ListRemediation)
+	app.Get("/ngs/remediation/:id", remediationHandler.GetRemediation)
+	app.Post("/ngs/remediation/:id/complete", remediationHandler.CompleteRemediation)
+
+	// Guardian consent routes
+	app.Post("/ngs/settings/guardian-consent", consentHandler.RequestConsent)
+	app.Get("/ngs/settings/guardian-consent", consentHandler.GetConsentStatus)
+	app.Get("/ngs/guardian-consent/:token", consentHandler.GetConsentRequest)
+	app.Post("/ngs/guardian-consent/:token", consentHandler.RespondConsent)
+
+	// Cohort routes (educators)
+	app.Post("/ngs/cohorts", cohortHandler.CreateCohort)
+	app.Get("/ngs/cohorts/:id", cohortHandler.GetCohort)
+	app.Post("/ngs/cohorts/:id/members", cohortHandler.AddMember)
+	app.Delete("/ngs/cohorts/:id/members/:userId", cohortHandler.RemoveMember)
+	app.Put("/ngs/cohorts/:id/content-age-band", cohortHandler.SetContentAgeBand)
+	app.Get("/ngs/cohorts/:id/curriculum", cohortHandler.GetCurriculum)
+	app.Put("/ngs/cohorts/:id/curriculum", cohortHandler.SetCurriculum)
+	app.Get("/ngs/analytics/cohorts/:id", lowPriority, analyticsHandler.GetCohortAnalytics)
+
+	// Educator ticket routes (tutor chat escalations)
+	app.Get("/ngs/educator/tickets", chatFeedbackHandler.ListTickets)
+	app.Post("/ngs/educator/tickets/:id/claim", chatFeedbackHandler.ClaimTicket)
+	app.Post("/ngs/educator/tickets/:id/resolve", chatFeedbackHandler.ResolveTicket)
+
+	// Organization routes (the X-Org-Id organization's admins; settings are readable by members)
+	app.Get("/ngs/org/settings", orgHandler.GetSettings)
+	app.Put("/ngs/org/settings", orgHandler.UpdateSettings)
+	app.Get("/ngs/org/educators", orgHandler.ListEducators)
+	app.Post("/ngs/org/educators", orgHandler.InviteEducator)
+	app.Delete("/ngs/org/educators/:userId", orgHandler.RemoveEducator)
+	app.Post("/ngs/org/invitation/accept", orgHandler.AcceptInvitation)
+	app.Get("/ngs/org/usage", orgHandler.GetUsage)
+
+	// Experiment routes
+	app.Get("/ngs/experiments/:key/assignment", experimentHandler.GetAssignment)
+	app.Put("/ngs/experiments/:key", experimentHandler.UpsertExperiment)
+	app.Get("/ngs/experiments/:key/results", lowPriority, experimentHandler.GetResults)
+
+	// Admin routes
+	app.Post("/ngs/admin/simulate/xp-curve", adminHandler.SimulateXPCurve)
+	app.Post("/ngs/admin/import/users", handlers.BodyLimit(cfg.ImportMaxBodyBytes), adminHandler.ImportUsers)
+	app.Put("/ngs/admin/lessons/:id/external-id", adminHandler.SetLessonExternalID)
+	app.Put("/ngs/admin/lessons/:id/prerequisites", graphHandler.SetLessonPrerequisites)
+	app.Post("/ngs/admin/lessons/:id/clone", authoringHandler.CloneLesson)
+	app.Post("/ngs/admin/lessons/from-template", authoringHandler.CreateLessonFromTemplate)
+	app.Get("/ngs/admin/lesson-templates", authoringHandler.GetLessonTemplates)
+	app.Get("/ngs/admin/challenges", challengeAuthoringHandler.ListChallenges)
+	app.Post("/ngs/admin/challenges", challengeAuthoringHandler.CreateChallenge)
+	app.Get("/ngs/admin/challenges/test-case-schema", challengeAuthoringHandler.GetTestCaseSchema)
+	app.Get("/ngs/admin/challenges/:id", challengeAuthoringHandler.GetChallenge)
+	app.Put("/ngs/admin/challenges/:id", challengeAuthoringHandler.UpdateChallenge)
+	app.Post("/ngs/admin/challenges/:id/deactivate", challengeAuthoringHandler.DeactivateChallenge)
+	app.Post("/ngs/admin/challenges/:id/reactivate", challengeAuthoringHandler.ReactivateChallenge)
+	app.Get("/ngs/admin/analytics/questions", lowPriority, questionAnalyticsHandler.GetQuestionStats)
+	app.Post("/ngs/admin/curriculum/import", handlers.BodyLimit(cfg.ImportMaxBodyBytes), curriculumPackHandler.ImportPack)
+	app.Get("/ngs/admin/curriculum/export", curriculumPackHandler.ExportPack)
+	app.Post("/ngs/admin/content/import", adminHandler.ImportContent)
+	app.Get("/ngs/admin/lesson-drafts", adminHandler.GetLessonDrafts)
+	app.Post("/ngs/admin/lesson-drafts/:id/publish", adminHandler.PublishLessonDraft)
+	app.Get("/ngs/admin/retention", retentionHandler.GetRetentionPolicies)
+	app.Post("/ngs/admin/retention/:policy/run", retentionHandler.RunRetentionPolicy)
+	app.Get("/ngs/admin/maintenance", maintenanceHandler.GetMaintenance)
+	app.Put("/ngs/admin/maintenance", maintenanceHandler.SetMaintenance)
+	app.Post("/ngs/admin/impersonations", impersonationHandler.StartImpersonation)
+	app.Get("/ngs/admin/impersonations", impersonationHandler.ListImpersonations)
+	app.Delete("/ngs/admin/impersonations/:id", impersonationHandler.EndImpersonation)
+	app.Get("/ngs/admin/impersonations/:id/audit", impersonationHandler.GetImpersonationAudit)
+	app.Get("/ngs/admin/users/:id/account", accountHandler.GetAccountStatus)
+	app.Post("/ngs/admin/users/:id/deactivate", accountHandler.DeactivateAccount)
+	app.Post("/ngs/admin/users/:id/reactivate", accountHandler.ReactivateAccount)
+	app.Post("/ngs/admin/users/:id/snapshot", snapshotHandler.SnapshotUser)
+	app.Post("/ngs/admin/users/:id/restore", handlers.BodyLimit(cfg.ImportMaxBodyBytes), snapshotHandler.RestoreUser)
+	app.Get("/ngs/admin/xp-reconciliation", reconciliationHandler.GetXPReconciliation)
+	app.Post("/ngs/admin/xp-reconciliation/run", reconciliationHandler.RunXPReconciliation)
+	app.Get("/ngs/admin/xp-reconciliation/repairs", reconciliationHandler.ListXPRepairs)
+	app.Post("/ngs/admin/reconcile-xp/:userId", reconciliationHandler.ReconcileUserXP)
+	app.Get("/ngs/admin/load", loadShedHandler.GetLoad)
+	app.Get("/ngs/admin/jobs", jobQueueHandler.GetJobQueue)
+	app.Put("/ngs/admin/jobs", jobQueueHandler.SetJobQueue)
+	app.Get("/ngs/admin/dlq", deadLetterHandler.ListDeadLetters)
+	app.Post("/ngs/admin/dlq/:id/retry", deadLetterHandler.RetryDeadLetter)
+	app.Post("/ngs/admin/dlq/:id/discard", deadLetterHandler.DiscardDeadLetter)
+	app.Get("/ngs/admin/usage", meteringHandler.ListUsage)
+	app.Get("/ngs/admin/llm-costs", llmCostHandler.ListLLMCosts)
+	app.Get("/ngs/admin/orgs/:id/usage", meteringHandler.GetOrgUsage)
+
+	// Internal routes, called by other services rather than through the gateway
+	app.Post("/ngs/internal/account-events", accountHandler.HandleAccountEvent)
+
+	// Background retention policies, partition maintenance, XP reconciliation, chat session
+	// summaries, load sampling, job settings, usage metering, LLM costs and duel events
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	jobQueueService.Start(backgroundCtx)
+	meteringService.Start(backgroundCtx)
+	llmCostService.Start(backgroundCtx)
+	retentionService.Start(backgroundCtx)
+	partitionService.Start(backgroundCtx)
+	reconciliationService.Start(backgroundCtx)
+	learnerMemoryService.Start(backgroundCtx)
+	loadShedder.Start(backgroundCtx)
+	duelHub.Start(backgroundCtx)
+
+	// Start server in a goroutine; the replica registers once it is listening
+	// The internal listener shares the server and its routes, so it starts once they are built
+	app.Hooks().OnListen(func(fiber.ListenData) error {
+		if internalListener != nil {
+			go func() {
+				log.Printf("🔐 Internal listener on port %s (mutual TLS)", cfg.InternalPort)
+				if err := app.Server().Serve(internalListener); err != nil {
+					log.Fatalf("Failed to serve internal listener: %v", err)
+				}
+			}()
+		}
+		registrationService.Start(backgroundCtx)
+		return nil
+	})
 	go func() {
 		log.Printf("🎓 Noble Growth School (NGS) Curriculum Service")
 		log.Printf("📚 24-level gamified learning curriculum")
@@ -203,9 +754,42 @@ func main() {
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 
+	// Stop accepting requests and let in-flight ones finish, then drain background work, and
+	// only then close the database the work depends on
+	shutdownTimeout := time.Duration(cfg.ShutdownTimeoutSec) * time.Second
+
+	// Leave the registry first so the gateway stops routing here before requests are refused
+	deregisterCtx, cancelDeregister := context.WithTimeout(context.Background(), shutdownTimeout)
+	if err := registrationService.Deregister(deregisterCtx); err != nil {
+		log.Printf("Service registry: %v", err)
+	}
+	cancelDeregister()
+
 	log.Println("Shutting down server...")
-	if err := app.Shutdown(); err != nil {
-		log.Fatalf("Server shutdown failed: %v", err)
+	if err := app.ShutdownWithTimeout(shutdownTimeout); err != nil {
+		log.Printf("Server shutdown incomplete: %v", err)
+	}
+
+	stopBackground()
+	retentionService.Wait()
+	partitionService.Wait()
+	reconciliationService.Wait()
+	learnerMemoryService.Wait()
+	loadShedder.Wait()
+	jobQueueService.Wait()
+	meteringService.Wait()
+	llmCostService.Wait()
+	registrationService.Wait()
+	duelHub.Wait()
+
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), shutdownTimeout)
+	if err := jobQueue.Drain(drainCtx); err != nil {
+		log.Printf("Cancelled running jobs: %v", err)
+	}
+	cancelDrain()
+
+	if err := db.Close(); err != nil {
+		log.Printf("Failed to close database: %v", err)
 	}
 
 	log.Println("Server exited gracefully")
@@ -0,0 +1,29 @@
+package tests
+
+import (
+	"noble-ngs-curriculum/internal/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestEstimateReadingLevel tests the Flesch-Kincaid reading level estimate
+func TestEstimateReadingLevel(t *testing.T) {
+	t.Run("Empty content", func(t *testing.T) {
+		assert.Equal(t, 0.0, services.EstimateReadingLevel(""))
+	})
+
+	t.Run("Simple text reads below technical text", func(t *testing.T) {
+		simple := services.EstimateReadingLevel("The cat sat on the mat. It was a good day. We had fun.")
+		technical := services.EstimateReadingLevel("Probabilistic classification algorithms approximate conditional distributions, " +
+			"optimizing regularized objective functions through iterative gradient computations.")
+		assert.Less(t, simple, technical)
+		assert.LessOrEqual(t, technical, 20.0, "Grade should be clamped")
+	})
+
+	t.Run("Markdown syntax and code are ignored", func(t *testing.T) {
+		plain := services.EstimateReadingLevel("Read the guide. Then try it.")
+		marked := services.EstimateReadingLevel("## Read the [guide](https://example.com).\n\n```go\nfunc veryComplicatedIdentifier() {}\n```\n\nThen *try* it.")
+		assert.InDelta(t, plain, marked, 1.0)
+	})
+}
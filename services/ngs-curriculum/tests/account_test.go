@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCalculateFrozenStreak tests that deactivation periods neither extend nor break streaks
+func TestCalculateFrozenStreak(t *testing.T) {
+	today := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	day := func(offset int) time.Time { return today.AddDate(0, 0, -offset) }
+	freeze := func(from, to int) services.StreakFreeze { return services.StreakFreeze{From: day(from), To: day(to)} }
+
+	t.Run("No freezes", func(t *testing.T) {
+		streak := services.CalculateFrozenStreak([]time.Time{day(1), day(2)}, today, nil)
+		assert.Equal(t, models.StreakStatus{Days: 2, Status: "at_risk"}, streak)
+	})
+
+	t.Run("Deactivated now", func(t *testing.T) {
+		streak := services.CalculateFrozenStreak([]time.Time{day(5), day(6), day(7)}, today, []services.StreakFreeze{freeze(4, 0)})
+		assert.Equal(t, models.StreakStatus{Days: 3, Status: "frozen"}, streak)
+	})
+
+	t.Run("Reactivated yesterday", func(t *testing.T) {
+		streak := services.CalculateFrozenStreak([]time.Time{day(5), day(6)}, today, []services.StreakFreeze{freeze(4, 1)})
+		assert.Equal(t, models.StreakStatus{Days: 2, Status: "at_risk"}, streak, "the streak picks up where it froze")
+
+		streak = services.CalculateFrozenStreak([]time.Time{day(0), day(5), day(6)}, today, []services.StreakFreeze{freeze(4, 1)})
+		assert.Equal(t, models.StreakStatus{Days: 3, Status: "active"}, streak)
+	})
+
+	t.Run("Gap before the freeze still breaks the streak", func(t *testing.T) {
+		streak := services.CalculateFrozenStreak([]time.Time{day(6), day(7)}, today, []services.StreakFreeze{freeze(4, 0)})
+		assert.Equal(t, "none", streak.Status)
+	})
+
+	t.Run("Activity while frozen counts", func(t *testing.T) {
+		streak := services.CalculateFrozenStreak([]time.Time{day(2), day(5)}, today, []services.StreakFreeze{freeze(4, 0)})
+		assert.Equal(t, models.StreakStatus{Days: 2, Status: "frozen"}, streak)
+	})
+}
+
+// TestAccountEventsAuth tests the shared secret on the account events endpoint
+func TestAccountEventsAuth(t *testing.T) {
+	post := func(token, headerToken, body string) int {
+		cfg := progressConfig()
+		cfg.AccountEventsToken = token
+		app := fiber.New()
+		app.Post("/ngs/internal/account-events", handlers.NewAccountHandler(services.NewAccountService(nil, cfg, services.SystemClock{})).HandleAccountEvent)
+
+		req := httptest.NewRequest("POST", "/ngs/internal/account-events", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if headerToken != "" {
+			req.Header.Set("X-Internal-Token", headerToken)
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+	event := `{"type": "account.deactivated", "user_id": "` + uuid.NewString() + `"}`
+
+	assert.Equal(t, fiber.StatusNotFound, post("", "secret", event), "disabled without ACCOUNT_EVENTS_TOKEN")
+	assert.Equal(t, fiber.StatusUnauthorized, post("secret", "", event))
+	assert.Equal(t, fiber.StatusUnauthorized, post("secret", "guess", event))
+	assert.Equal(t, fiber.StatusBadRequest, post("secret", "secret", `{"type": "account.deleted", "user_id": "`+uuid.NewString()+`"}`))
+	assert.Equal(t, fiber.StatusBadRequest, post("secret", "secret", `{"type": "account.deactivated"}`))
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAlumniWeek tests the ISO week the feed rotates on
+func TestAlumniWeek(t *testing.T) {
+	week, rotatesAt := services.AlumniWeek(time.Date(2026, 10, 16, 15, 30, 0, 0, time.UTC))
+	assert.Equal(t, "2026-W42", week)
+	assert.Equal(t, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), rotatesAt)
+
+	week, rotatesAt = services.AlumniWeek(time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, "2026-W43", week, "Monday starts a new week")
+	assert.Equal(t, time.Date(2026, 10, 26, 0, 0, 0, 0, time.UTC), rotatesAt)
+
+	week, _ = services.AlumniWeek(time.Date(2027, 1, 1, 12, 0, 0, 0, time.UTC))
+	assert.Equal(t, "2026-W53", week, "ISO years can end in the next calendar year")
+
+	est := time.FixedZone("EST", -5*3600)
+	week, _ = services.AlumniWeek(time.Date(2026, 10, 18, 22, 0, 0, 0, est))
+	assert.Equal(t, "2026-W43", week, "weeks are in UTC")
+}
+
+// TestAlumniPrompts tests the weekly mentorship prompt rotation
+func TestAlumniPrompts(t *testing.T) {
+	_, next := services.AlumniWeek(time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC))
+	thisWeek := next.AddDate(0, 0, -7)
+
+	prompts := services.AlumniPrompts(thisWeek, 2)
+	require.Len(t, prompts, 2)
+	assert.Equal(t, "mentorship", prompts[0].Type)
+	assert.NotEqual(t, prompts[0].Title, prompts[1].Title)
+	assert.Equal(t, prompts, services.AlumniPrompts(thisWeek, 2), "a week's prompts are stable")
+
+	seen := make(map[string]bool)
+	for week := 0; week < 4; week++ {
+		for _, prompt := range services.AlumniPrompts(thisWeek.AddDate(0, 0, 7*week), 2) {
+			assert.False(t, seen[prompt.Title], "%q repeated before every prompt was shown", prompt.Title)
+			seen[prompt.Title] = true
+		}
+	}
+
+	assert.Empty(t, services.AlumniPrompts(thisWeek, 0))
+	assert.Len(t, services.AlumniPrompts(thisWeek, 100), len(seen), "capped at the number of prompts")
+}
+
+// TestAlumniFeedHandler tests that the feed is for users past the final level
+func TestAlumniFeedHandler(t *testing.T) {
+	cfg := testsupport.Config()
+	db := testsupport.RowsDB([]string{"current_level"}, []driver.Value{int64(len(cfg.LevelUpXPThresholds) - 1)})
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/alumni/feed", handlers.NewAlumniHandler(services.NewAlumniService(db, cfg, testsupport.NewFakeClock(testsupport.Epoch))).GetFeed)
+
+	req := httptest.NewRequest("GET", "/ngs/alumni/feed", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+
+	req = httptest.NewRequest("GET", "/ngs/alumni/feed", nil)
+	req.Header.Set("X-User-Id", uuid.NewString())
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode, "one level short of the end")
+}
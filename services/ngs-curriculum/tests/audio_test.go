@@ -0,0 +1,46 @@
+package tests
+
+import (
+	"noble-ngs-curriculum/internal/services"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAudioURLSignature tests signed audio links
+func TestAudioURLSignature(t *testing.T) {
+	secret := []byte("test-secret")
+	audioID := uuid.New()
+	now := time.Unix(1700000000, 0)
+	expires := now.Add(10 * time.Minute).Unix()
+	signature := services.SignAudioURL(secret, audioID, expires)
+
+	assert.True(t, services.VerifyAudioSignature(secret, audioID, expires, signature, now))
+	assert.False(t, services.VerifyAudioSignature(secret, audioID, expires, signature, now.Add(11*time.Minute)), "Expired link")
+	assert.False(t, services.VerifyAudioSignature(secret, uuid.New(), expires, signature, now), "Signature for another audio")
+	assert.False(t, services.VerifyAudioSignature(secret, audioID, expires+60, signature, now), "Tampered expiry")
+	assert.False(t, services.VerifyAudioSignature([]byte("other"), audioID, expires, signature, now), "Wrong secret")
+}
+
+// TestSpeechChunks tests conversion of lesson markdown into narration chunks
+func TestSpeechChunks(t *testing.T) {
+	t.Run("Markdown is stripped", func(t *testing.T) {
+		chunks := services.SpeechChunks("# Title\n\nSome **bold** text with a [link](http://x).\n\n```go\ncode()\n```", 100)
+		assert.Len(t, chunks, 1)
+		assert.NotContains(t, chunks[0], "#")
+		assert.NotContains(t, chunks[0], "code()")
+		assert.Contains(t, chunks[0], "link")
+	})
+
+	t.Run("Chunks respect the size limit", func(t *testing.T) {
+		paragraph := strings.Repeat("word ", 30)
+		chunks := services.SpeechChunks(paragraph+"\n\n"+paragraph+"\n\n"+strings.Repeat("long ", 100), 200)
+		assert.Greater(t, len(chunks), 2)
+		for _, chunk := range chunks {
+			assert.LessOrEqual(t, len(chunk), 200)
+		}
+	})
+}
@@ -0,0 +1,198 @@
+package tests
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/middleware"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signedJWT builds a token over claims, signed by sign for alg
+func signedJWT(t *testing.T, alg string, claims map[string]interface{}, sign func(signed []byte) []byte) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(signed)))
+}
+
+func hs256(secret string) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(signed)
+		return mac.Sum(nil)
+	}
+}
+
+// TestJWTVerifier tests signatures, algorithms and registered claims
+func TestJWTVerifier(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub": "user-1", "email": "ada@example.com", "role": "educator", "tier": "pro", "org_id": "acme",
+			"iss": "noble-auth", "aud": []string{"ngs", "tutor"}, "exp": now.Add(time.Hour).Unix(),
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+
+	verifier, err := middleware.NewJWTVerifier("old-secret, new-secret", nil, "noble-auth", "ngs", time.Minute, clock)
+	require.NoError(t, err)
+
+	verified, err := verifier.Verify(signedJWT(t, "HS256", claims(nil), hs256("new-secret")))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", verified.Subject)
+	assert.Equal(t, "ada@example.com", verified.Email)
+	assert.Equal(t, "educator", verified.Role)
+	assert.Equal(t, "pro", verified.Tier)
+	assert.Equal(t, "acme", verified.OrgID)
+	_, err = verifier.Verify(signedJWT(t, "HS256", claims(map[string]interface{}{"aud": "ngs"}), hs256("old-secret")))
+	assert.NoError(t, err, "either secret verifies during rotation, and aud may be a string")
+
+	refused := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"wrong secret", signedJWT(t, "HS256", claims(nil), hs256("guess")), middleware.ErrTokenSignature},
+		{"alg none", signedJWT(t, "none", claims(nil), func([]byte) []byte { return nil }), middleware.ErrTokenSignature},
+		{"RS256 without a public key", signedJWT(t, "RS256", claims(nil), hs256("new-secret")), middleware.ErrTokenSignature},
+		{"expired", signedJWT(t, "HS256", claims(map[string]interface{}{"exp": now.Add(-2 * time.Minute).Unix()}), hs256("new-secret")), middleware.ErrTokenExpired},
+		{"not yet valid", signedJWT(t, "HS256", claims(map[string]interface{}{"nbf": now.Add(5 * time.Minute).Unix()}), hs256("new-secret")), middleware.ErrTokenExpired},
+		{"no exp", signedJWT(t, "HS256", claims(map[string]interface{}{"exp": nil}), hs256("new-secret")), middleware.ErrTokenClaims},
+		{"no sub", signedJWT(t, "HS256", claims(map[string]interface{}{"sub": nil}), hs256("new-secret")), middleware.ErrTokenClaims},
+		{"other issuer", signedJWT(t, "HS256", claims(map[string]interface{}{"iss": "elsewhere"}), hs256("new-secret")), middleware.ErrTokenClaims},
+		{"other audience", signedJWT(t, "HS256", claims(map[string]interface{}{"aud": "billing"}), hs256("new-secret")), middleware.ErrTokenClaims},
+		{"not a JWT", "abc.def", middleware.ErrTokenMalformed},
+	}
+	for _, tc := range refused {
+		_, err := verifier.Verify(tc.token)
+		assert.ErrorIs(t, err, tc.want, tc.name)
+	}
+
+	_, err = verifier.Verify(signedJWT(t, "HS256", claims(map[string]interface{}{"exp": now.Add(-30 * time.Second).Unix()}), hs256("new-secret")))
+	assert.NoError(t, err, "expiry allows the leeway")
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	rs256 := func(signed []byte) []byte {
+		digest := sha256.Sum256(signed)
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+		return signature
+	}
+
+	rsaVerifier, err := middleware.NewJWTVerifier("", publicPEM, "", "", 0, clock)
+	require.NoError(t, err)
+	_, err = rsaVerifier.Verify(signedJWT(t, "RS256", claims(nil), rs256))
+	assert.NoError(t, err)
+	_, err = rsaVerifier.Verify(signedJWT(t, "HS256", claims(nil), hs256(string(publicPEM))))
+	assert.ErrorIs(t, err, middleware.ErrTokenSignature, "the public key is never an HMAC secret")
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err = x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	require.NoError(t, err)
+	ecVerifier, err := middleware.NewJWTVerifier("", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), "", "", 0, clock)
+	require.NoError(t, err)
+	es256, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims(claims(nil))).SignedString(ecKey)
+	require.NoError(t, err)
+	verified, err = ecVerifier.Verify(es256)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", verified.Subject)
+	_, err = ecVerifier.Verify(signedJWT(t, "ES384", claims(nil), func([]byte) []byte { return make([]byte, 96) }))
+	assert.ErrorIs(t, err, middleware.ErrTokenSignature, "only HS256, RS256 and ES256 are accepted")
+
+	_, err = middleware.NewJWTVerifier("", nil, "", "", 0, clock)
+	assert.Error(t, err, "a secret or key is required")
+	_, err = middleware.NewJWTVerifier("", []byte("not pem"), "", "", 0, clock)
+	assert.Error(t, err)
+}
+
+// TestAuthMiddleware tests where each mode takes the caller's identity from
+func TestAuthMiddleware(t *testing.T) {
+	now := time.Now()
+	verifier, err := middleware.NewJWTVerifier("secret", nil, "", "", 0, time.Now)
+	require.NoError(t, err)
+	token := signedJWT(t, "HS256", map[string]interface{}{
+		"sub": "token-user", "role": "admin", "org_id": "acme", "exp": now.Add(time.Hour).Unix(),
+	}, hs256("secret"))
+
+	request := func(mode string, verifier *middleware.JWTVerifier, headers map[string]string) (int, string) {
+		app := fiber.New()
+		app.Use(middleware.Auth(mode, verifier))
+		app.Get("/whoami", func(c *fiber.Ctx) error {
+			identity := middleware.GetIdentity(c)
+			return c.SendString(identity.UserID + "|" + identity.Role + "|" + identity.OrgID)
+		})
+		req := httptest.NewRequest("GET", "/whoami", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+	identityHeaders := map[string]string{"X-User-Id": "header-user", "X-User-Role": "student", "X-Org-Id": "globex"}
+	withToken := func(token string) map[string]string {
+		headers := map[string]string{"Authorization": "Bearer " + token}
+		for k, v := range identityHeaders {
+			headers[k] = v
+		}
+		return headers
+	}
+
+	status, body := request(middleware.ModeHeaders, nil, identityHeaders)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "header-user|student|globex", body)
+
+	status, body = request(middleware.ModeHeaders, verifier, withToken(token))
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "token-user|admin|acme", body, "a verified token wins over headers")
+
+	status, _ = request(middleware.ModeHeaders, nil, withToken(token))
+	assert.Equal(t, fiber.StatusUnauthorized, status, "tokens are refused without a verifier")
+
+	status, body = request(middleware.ModeJWT, verifier, identityHeaders)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "||", body, "jwt mode ignores identity headers")
+
+	status, body = request(middleware.ModeJWT, verifier, withToken(token))
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "token-user|admin|acme", body)
+
+	status, _ = request(middleware.ModeJWT, verifier, withToken(token[:len(token)-2]))
+	assert.Equal(t, fiber.StatusUnauthorized, status)
+}
@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"errors"
+	"noble-ngs-curriculum/internal/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLessonTemplates tests the built-in lesson templates
+func TestLessonTemplates(t *testing.T) {
+	templates := services.LessonTemplates()
+
+	t.Run("One template per lesson type", func(t *testing.T) {
+		var types []string
+		for _, tmpl := range templates {
+			types = append(types, tmpl.Type)
+		}
+		assert.ElementsMatch(t, []string{"tutorial", "exercise", "quiz", "challenge", "reflection"}, types)
+	})
+
+	t.Run("Templates are complete", func(t *testing.T) {
+		for _, tmpl := range templates {
+			assert.NotEmpty(t, tmpl.Name, tmpl.Type)
+			assert.Contains(t, tmpl.ContentMarkdown, "{{title}}", tmpl.Type)
+			assert.NotEmpty(t, tmpl.ReflectionPrompt, tmpl.Type)
+			assert.Positive(t, tmpl.XPReward, tmpl.Type)
+			assert.Positive(t, tmpl.EstimatedMinutes, tmpl.Type)
+		}
+	})
+
+	t.Run("Returned slice is a copy", func(t *testing.T) {
+		templates[0].Name = "changed"
+		assert.NotEqual(t, "changed", services.LessonTemplates()[0].Name)
+	})
+}
+
+// TestGetLessonTemplate tests looking up a template by lesson type
+func TestGetLessonTemplate(t *testing.T) {
+	tmpl, err := services.GetLessonTemplate(" Quiz ")
+	require.NoError(t, err)
+	assert.Equal(t, "quiz", tmpl.Type)
+	assert.Equal(t, 60, tmpl.CompletionCriteria.MinQuizScore)
+
+	_, err = services.GetLessonTemplate("lecture")
+	assert.True(t, errors.Is(err, services.ErrTemplateNotFound))
+}
@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"net/http/httptest"
+	"noble-ngs-curriculum/internal/handlers"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBodyLimit tests 413 for oversize bodies and route limits under exempt prefixes
+func TestBodyLimit(t *testing.T) {
+	app := fiber.New()
+	app.Use(handlers.BodyLimit(10, "/import/", "/challenges/*/design"))
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) }
+	app.Post("/reflections", handlers.BodyLimit(5), ok)
+	app.Post("/other", ok)
+	app.Post("/import/users", handlers.BodyLimit(20), ok)
+	app.Post("/challenges/:id/design", handlers.BodyLimit(20), ok)
+	app.Post("/challenges/:id/submit", ok)
+
+	tests := []struct {
+		path string
+		body string
+		want int
+	}{
+		{"/other", "0123456789", fiber.StatusNoContent},
+		{"/other", "0123456789x", fiber.StatusRequestEntityTooLarge},
+		{"/reflections", "01234", fiber.StatusNoContent},
+		{"/reflections", "012345", fiber.StatusRequestEntityTooLarge},
+		{"/import/users", "0123456789abcdef", fiber.StatusNoContent},
+		{"/import/users", strings.Repeat("x", 21), fiber.StatusRequestEntityTooLarge},
+		{"/challenges/42/design", "0123456789abcdef", fiber.StatusNoContent},
+		{"/challenges/42/design", strings.Repeat("x", 21), fiber.StatusRequestEntityTooLarge},
+		{"/challenges/42/submit", "0123456789x", fiber.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		resp, err := app.Test(httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body)))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, resp.StatusCode, "%s with %d bytes", tt.path, len(tt.body))
+	}
+}
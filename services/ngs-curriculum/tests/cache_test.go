@@ -0,0 +1,316 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"noble-ngs-curriculum/internal/cache"
+	"noble-ngs-curriculum/internal/testsupport"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMemoryCache tests values and counters expiring on the cache's clock
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	clock := testsupport.NewFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := cache.NewMemoryWithClock(clock.Now)
+
+	require.NoError(t, c.Set(ctx, "lesson:1", []byte("content"), time.Minute))
+	value, ok, err := c.Get(ctx, "lesson:1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "content", string(value))
+
+	for want := int64(1); want <= 3; want++ {
+		count, err := c.Incr(ctx, "rate:user", time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, want, count)
+	}
+
+	clock.Advance(time.Minute)
+	_, ok, err = c.Get(ctx, "lesson:1")
+	require.NoError(t, err)
+	assert.False(t, ok, "value should expire after its ttl")
+	count, err := c.Incr(ctx, "rate:user", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count, "an expired counter should start a new window")
+
+	require.NoError(t, c.Delete(ctx, "rate:user"))
+	count, _ = c.Incr(ctx, "rate:user", time.Minute)
+	assert.Equal(t, int64(1), count)
+}
+
+// TestRedisCache tests the Redis driver against a minimal RESP server
+func TestRedisCache(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeRedis(t)
+	c, err := cache.NewRedis("redis://:secret@" + server.addr + "/2")
+	require.NoError(t, err)
+
+	require.NoError(t, c.Ping(ctx))
+	_, ok, err := c.Get(ctx, "leaderboard:10")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, c.Set(ctx, "leaderboard:10", []byte("[1,2]"), time.Minute))
+	value, ok, err := c.Get(ctx, "leaderboard:10")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "[1,2]", string(value))
+	assert.Contains(t, server.keys(), cache.RedisKeyPrefix+"leaderboard:10", "keys should be namespaced")
+
+	count, err := c.Incr(ctx, "rate:user", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	count, err = c.Incr(ctx, "rate:user", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	require.NoError(t, c.Delete(ctx, "leaderboard:10"))
+	_, ok, _ = c.Get(ctx, "leaderboard:10")
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{"AUTH secret", "SELECT 2"}, server.setup(), "each connection should authenticate and select the database")
+
+	t.Run("Invalid URL", func(t *testing.T) {
+		_, err := cache.NewRedis("http://redis:6379")
+		assert.Error(t, err)
+		_, err = cache.NewRedis("redis://redis:6379/main")
+		assert.Error(t, err)
+	})
+}
+
+// failingCache is a shared cache that is down
+type failingCache struct{}
+
+var errCacheDown = errors.New("connection refused")
+
+func (failingCache) Name() string { return "redis" }
+func (failingCache) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, errCacheDown
+}
+func (failingCache) Set(context.Context, string, []byte, time.Duration) error { return errCacheDown }
+func (failingCache) Delete(context.Context, ...string) error                  { return errCacheDown }
+func (failingCache) Incr(context.Context, string, time.Duration) (int64, error) {
+	return 0, errCacheDown
+}
+
+// TestFallbackCache tests that a failing shared cache degrades to memory instead of failing
+func TestFallbackCache(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewFallback(failingCache{}, cache.NewMemory())
+
+	require.NoError(t, c.Set(ctx, "levels", []byte("[]"), time.Minute))
+	assert.True(t, c.Degraded())
+	value, ok, err := c.Get(ctx, "levels")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "[]", string(value))
+
+	count, err := c.Incr(ctx, "rate:user", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+// TestLeaderboardCache tests that the leaderboard is served from the cache until it expires
+func TestLeaderboardCache(t *testing.T) {
+	cfg := progressConfig()
+	cfg.LeaderboardCacheSeconds = 30
+	progressService, store := testsupport.NewProgressService(cfg)
+	progressService.SetCache(cache.NewMemory())
+
+	store.PutProgress(testsupport.Progress().User(uuid.New()).XP(500, cfg.LevelUpXPThresholds).Build())
+	entries, err := progressService.GetLeaderboard(10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	store.PutProgress(testsupport.Progress().User(uuid.New()).XP(900, cfg.LevelUpXPThresholds).Build())
+	entries, err = progressService.GetLeaderboard(10)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "the cached leaderboard should be served within its ttl")
+
+	entries, err = progressService.GetLeaderboard(5)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "each limit is cached separately")
+
+	level, err := progressService.GetLevel(2)
+	require.NoError(t, err)
+	assert.Equal(t, 2, level.LevelNumber)
+}
+
+// TestLeaderboardInvalidatedOnXPAward tests that awarding XP drops every cached leaderboard,
+// whatever its period or limit
+func TestLeaderboardInvalidatedOnXPAward(t *testing.T) {
+	cfg := progressConfig()
+	cfg.LeaderboardCacheSeconds = 300
+	progressService, store := testsupport.NewProgressService(cfg)
+	progressService.SetCache(cache.NewMemory())
+
+	first, second := uuid.New(), uuid.New()
+	store.PutProgress(testsupport.Progress().User(first).XP(500, cfg.LevelUpXPThresholds).Build())
+	store.PutProgress(testsupport.Progress().User(second).XP(400, cfg.LevelUpXPThresholds).Build())
+	daily, err := progressService.LeaderboardPeriod("daily")
+	require.NoError(t, err)
+
+	entries, err := progressService.GetLeaderboard(10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, first, entries[0].UserID)
+	entries, err = progressService.GetPeriodLeaderboard(daily, 10)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	_, err = progressService.AwardXP(second, "lesson_completion", 200, nil)
+	require.NoError(t, err)
+
+	entries, err = progressService.GetLeaderboard(10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, second, entries[0].UserID, "the award should show up before the board expires")
+	entries, err = progressService.GetPeriodLeaderboard(daily, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, second, entries[0].UserID)
+
+	// Boards are cached again until the next award
+	store.PutProgress(testsupport.Progress().User(uuid.New()).XP(900, cfg.LevelUpXPThresholds).Build())
+	entries, err = progressService.GetLeaderboard(10)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+	progressService.InvalidateLeaderboards()
+	entries, err = progressService.GetLeaderboard(10)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
+
+// fakeRedis answers the commands the cache driver sends, ignoring expiry
+type fakeRedis struct {
+	addr string
+
+	mu       sync.Mutex
+	data     map[string]string
+	commands []string
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	s := &fakeRedis{addr: listener.Addr().String(), data: map[string]string{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		io.WriteString(conn, s.reply(args))
+	}
+}
+
+func (s *fakeRedis) reply(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands = append(s.commands, strings.Join(args, " "))
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "AUTH", "SELECT", "SET":
+		if args[0] == "SET" {
+			s.data[args[1]] = args[2]
+		}
+		return "+OK\r\n"
+	case "GET":
+		value, ok := s.data[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "DEL":
+		for _, key := range args[1:] {
+			delete(s.data, key)
+		}
+		return ":1\r\n"
+	case "INCR":
+		n, _ := strconv.Atoi(s.data[args[1]])
+		n++
+		s.data[args[1]] = strconv.Itoa(n)
+		return fmt.Sprintf(":%d\r\n", n)
+	case "PEXPIRE":
+		return ":1\r\n"
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+func (s *fakeRedis) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := []string{}
+	for key := range s.data {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// setup returns the connection setup commands the server saw
+func (s *fakeRedis) setup() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	setup := []string{}
+	for _, command := range s.commands {
+		if strings.HasPrefix(command, "AUTH") || strings.HasPrefix(command, "SELECT") {
+			setup = append(setup, command)
+		}
+	}
+	return setup
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if _, err := reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var authoredChallengeColumns = append(append([]string{}, challengeColumns...), "created_by", "updated_by", "updated_at")
+
+// TestValidateTestCases tests checking authored test cases against the test case schema
+func TestValidateTestCases(t *testing.T) {
+	tests, err := services.ValidateTestCases(json.RawMessage(`[
+		{"input": "3", "expected": "6"},
+		{"name": "Large", "input": "1000", "expected": 2000, "hidden": true, "points": 5}
+	]`))
+	require.NoError(t, err)
+	require.Len(t, tests, 2)
+	assert.Equal(t, "Test 1", tests[0].Name)
+	assert.Equal(t, 1, tests[0].Points)
+	assert.Equal(t, 5, tests[1].Points)
+
+	tests, err = services.ValidateTestCases(nil)
+	require.NoError(t, err)
+	assert.Empty(t, tests)
+
+	for name, raw := range map[string]string{
+		"Not an array":    `{"input": "1"}`,
+		"Not an object":   `["1"]`,
+		"Unknown field":   `[{"input": "1", "expect": "2"}]`,
+		"Negative points": `[{"points": -1}]`,
+		"Repeated name":   `[{"name": "Edge"}, {"name": "Edge"}]`,
+		"Default clash":   `[{"name": "Test 2"}, {}]`,
+		"Long name":       `[{"name": "` + strings.Repeat("n", 101) + `"}]`,
+	} {
+		_, err := services.ValidateTestCases(json.RawMessage(raw))
+		assert.ErrorIs(t, err, services.ErrInvalidChallenge, name)
+	}
+
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(services.TestCaseSchema(), &schema))
+	assert.Equal(t, "array", schema["type"])
+}
+
+// TestValidateChallenge tests the checks made before a challenge is saved
+func TestValidateChallenge(t *testing.T) {
+	valid := func() models.Challenge {
+		return models.Challenge{
+			LevelID:       2,
+			Title:         "Double it",
+			Description:   "Print twice the number read from stdin",
+			ChallengeType: services.ChallengeTypeCoding,
+			Difficulty:    "easy",
+			MinAgeBand:    services.AgeBandChild,
+			XPReward:      50,
+			TestCases:     json.RawMessage(`[{"input": "3", "expected": "6"}]`),
+		}
+	}
+	c := valid()
+	require.NoError(t, services.ValidateChallenge(&c))
+
+	for name, change := range map[string]func(c *models.Challenge){
+		"Missing title":        func(c *models.Challenge) { c.Title = "" },
+		"Missing description":  func(c *models.Challenge) { c.Description = "" },
+		"Unknown type":         func(c *models.Challenge) { c.ChallengeType = "puzzle" },
+		"Unknown difficulty":   func(c *models.Challenge) { c.Difficulty = "trivial" },
+		"No level":             func(c *models.Challenge) { c.LevelID = 0 },
+		"Negative XP":          func(c *models.Challenge) { c.XPReward = -1 },
+		"Negative limit":       func(c *models.Challenge) { c.Limits.MemoryLimitMB = -1 },
+		"No test cases":        func(c *models.Challenge) { c.TestCases = nil },
+		"Only hidden cases":    func(c *models.Challenge) { c.TestCases = json.RawMessage(`[{"hidden": true}]`) },
+		"Metadata not object":  func(c *models.Challenge) { c.Metadata = json.RawMessage(`["rubric"]`) },
+		"Optimization no base": func(c *models.Challenge) { c.ChallengeType = services.ChallengeTypeOptimization },
+	} {
+		c := valid()
+		change(&c)
+		assert.ErrorIs(t, services.ValidateChallenge(&c), services.ErrInvalidChallenge, name)
+	}
+
+	c = valid()
+	c.MinAgeBand = "toddler"
+	assert.ErrorIs(t, services.ValidateChallenge(&c), services.ErrInvalidAgeBand)
+
+	c = valid()
+	c.ChallengeType = services.ChallengeTypeReflection
+	c.TestCases = nil
+	assert.NoError(t, services.ValidateChallenge(&c), "reflections have no test cases")
+}
+
+// TestApplyChallengeRequest tests that updates only change the fields sent
+func TestApplyChallengeRequest(t *testing.T) {
+	c := models.Challenge{Title: "Double it", Difficulty: "easy", Tags: []string{"math"}, Metadata: json.RawMessage(`{"language": "python"}`)}
+
+	var req models.ChallengeRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"difficulty": " Hard ", "tags": [" loops", "loops", ""], "metadata": null}`), &req))
+	services.ApplyChallengeRequest(&c, req)
+
+	assert.Equal(t, "Double it", c.Title)
+	assert.Equal(t, "hard", c.Difficulty)
+	assert.Equal(t, []string{"loops"}, c.Tags)
+	assert.Nil(t, c.Metadata, "null clears the metadata")
+}
+
+// TestChallengeAuthoringHandlers tests the admin challenge routes' access and request checks
+func TestChallengeAuthoringHandlers(t *testing.T) {
+	service := services.NewChallengeAuthoringService(testsupport.RowsDB(authoredChallengeColumns))
+	handler := handlers.NewChallengeAuthoringHandler(service)
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/admin/challenges/test-case-schema", handler.GetTestCaseSchema)
+	app.Post("/ngs/admin/challenges", handler.CreateChallenge)
+	app.Put("/ngs/admin/challenges/:id", handler.UpdateChallenge)
+
+	send := func(method, path, role, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User-Id", uuid.NewString())
+		req.Header.Set("X-User-Role", role)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, fiber.StatusForbidden, send("GET", "/ngs/admin/challenges/test-case-schema", "student", ""))
+	assert.Equal(t, fiber.StatusOK, send("GET", "/ngs/admin/challenges/test-case-schema", "educator", ""))
+
+	body := `{"level_id": 2, "title": "Double it", "description": "Print twice the number", "test_cases": [{"input": "3", "expectd": "6"}]}`
+	assert.Equal(t, fiber.StatusForbidden, send("POST", "/ngs/admin/challenges", "student", body))
+	assert.Equal(t, fiber.StatusBadRequest, send("POST", "/ngs/admin/challenges", "admin", body), "challenge_type is required")
+
+	path := "/ngs/admin/challenges/" + uuid.NewString()
+	assert.Equal(t, fiber.StatusNotFound, send("PUT", path, "educator", `{"title": "Triple it"}`))
+	assert.Equal(t, fiber.StatusBadRequest, send("PUT", "/ngs/admin/challenges/not-a-uuid", "educator", `{}`))
+}
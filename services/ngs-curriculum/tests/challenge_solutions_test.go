@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// solutionsApp serves the challenge routes of a challenge with a solution template, as seen by a
+// user who has or has not passed it. A share restricted to the user's passing submissions
+// updates sharedRows of them.
+func solutionsApp(challengeID uuid.UUID, passed bool, sharedRows int64) *fiber.App {
+	row := challengeRow(challengeID, nil)
+	row[9] = "for i in range(1, 101): ..."
+	db := testsupport.QueryDB(
+		testsupport.Query{Match: "is_shared = true",
+			Columns: []string{"id", "submission_code", "score", "code_length", "submitted_at", "code_ref"},
+			Rows:    [][]driver.Value{{uuid.NewString(), "print('shared')", int64(100), int64(15), testsupport.Epoch, nil}}},
+		testsupport.Query{Match: "AND user_id = $3 AND passed = true", RowsAffected: sharedRows},
+		testsupport.Query{Match: "passed = true", Columns: []string{"exists"}, Rows: [][]driver.Value{{passed}}},
+		testsupport.Query{Match: "solution_template", Columns: challengeColumns, Rows: [][]driver.Value{row}},
+		testsupport.Query{Match: "FROM challenges", Columns: []string{"allowed"}, Rows: [][]driver.Value{{true}}},
+		testsupport.Query{Match: "user_settings", Columns: []string{"minor"}, Rows: [][]driver.Value{{false}}},
+	)
+	handler := handlers.NewChallengeHandler(services.NewChallengeService(db, progressConfig(), nil))
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/challenges/:id", handler.GetChallenge)
+	app.Get("/ngs/challenges/:id/solutions", handler.GetSolutions)
+	app.Put("/ngs/challenges/submissions/:id/share", handler.ShareSubmission)
+	return app
+}
+
+func challengeRequest(t *testing.T, app *fiber.App, method, path, body string) (int, []byte) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", uuid.NewString())
+	req.Header.Set("X-User-Role", "student")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, data
+}
+
+// TestChallengeSolutionSpoilers tests that the solution is only revealed once the user has passed
+func TestChallengeSolutionSpoilers(t *testing.T) {
+	challengeID := uuid.New()
+
+	t.Run("Hidden before a pass", func(t *testing.T) {
+		app := solutionsApp(challengeID, false, 0)
+		status, body := challengeRequest(t, app, "GET", "/ngs/challenges/"+challengeID.String(), "")
+		require.Equal(t, fiber.StatusOK, status)
+		var challenge models.Challenge
+		require.NoError(t, json.Unmarshal(body, &challenge))
+		assert.Equal(t, "FizzBuzz", challenge.Title)
+		assert.Empty(t, challenge.SolutionTemplate)
+
+		status, body = challengeRequest(t, app, "GET", "/ngs/challenges/"+challengeID.String()+"/solutions", "")
+		assert.Equal(t, fiber.StatusForbidden, status)
+		assert.Contains(t, string(body), services.ErrSolutionLocked.Error())
+	})
+
+	t.Run("Revealed after a pass", func(t *testing.T) {
+		app := solutionsApp(challengeID, true, 0)
+		status, body := challengeRequest(t, app, "GET", "/ngs/challenges/"+challengeID.String(), "")
+		require.Equal(t, fiber.StatusOK, status)
+		var challenge models.Challenge
+		require.NoError(t, json.Unmarshal(body, &challenge))
+		assert.Equal(t, "for i in range(1, 101): ...", challenge.SolutionTemplate)
+
+		status, body = challengeRequest(t, app, "GET", "/ngs/challenges/"+challengeID.String()+"/solutions", "")
+		require.Equal(t, fiber.StatusOK, status)
+		var solutions models.ChallengeSolutions
+		require.NoError(t, json.Unmarshal(body, &solutions))
+		assert.Equal(t, "for i in range(1, 101): ...", solutions.SolutionTemplate)
+		require.Len(t, solutions.CommunitySolutions, 1)
+		assert.Equal(t, "print('shared')", solutions.CommunitySolutions[0].SubmissionCode)
+	})
+}
+
+// TestShareSubmission tests that only the user's own passing submissions can be shared
+func TestShareSubmission(t *testing.T) {
+	path := "/ngs/challenges/submissions/" + uuid.NewString() + "/share"
+
+	// A failing submission or another user's matches no row of the update
+	status, _ := challengeRequest(t, solutionsApp(uuid.New(), false, 0), "PUT", path, `{"shared": true}`)
+	assert.Equal(t, fiber.StatusNotFound, status)
+
+	status, body := challengeRequest(t, solutionsApp(uuid.New(), true, 1), "PUT", path, `{"shared": true}`)
+	require.Equal(t, fiber.StatusOK, status)
+	assert.Contains(t, string(body), `"is_shared":true`)
+}
@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var challengeColumns = []string{
+	"id", "lesson_id", "level_id", "title", "description", "challenge_type",
+	"difficulty", "starter_code", "test_cases", "solution_template",
+	"xp_reward", "time_limit_minutes", "tags", "metadata", "is_active", "min_age_band", "created_at",
+	"run_time_limit_ms", "run_cpu_limit_ms", "run_memory_limit_mb", "baseline_runtime_ms", "baseline_memory_kb",
+}
+
+// challengeRow is a challenges row with tags in Postgres array text form, or nil for NULL
+func challengeRow(id uuid.UUID, tags driver.Value) []driver.Value {
+	return []driver.Value{
+		id.String(), nil, int64(2), "FizzBuzz", "Print numbers", "coding",
+		"easy", nil, nil, nil,
+		int64(100), nil, tags, nil, true, "child", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		nil, nil, nil, nil, nil,
+	}
+}
+
+// TestChallengeTagsScan tests that the tags array is read from Postgres
+func TestChallengeTagsScan(t *testing.T) {
+	t.Run("Array", func(t *testing.T) {
+		id := uuid.New()
+		db := testsupport.RowsDB(challengeColumns, challengeRow(id, []byte(`{loops,"control flow"}`)))
+
+		challenge, err := services.NewChallengeService(db, progressConfig(), nil).GetChallenge(id)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"loops", "control flow"}, challenge.Tags)
+		assert.Nil(t, challenge.TestCases)
+	})
+
+	t.Run("NULL", func(t *testing.T) {
+		id := uuid.New()
+		db := testsupport.RowsDB(challengeColumns, challengeRow(id, nil))
+
+		challenges, err := services.NewChallengeService(db, progressConfig(), nil).GetChallengesByLevel(2, uuid.New(), []string{"loops"})
+		require.NoError(t, err)
+		require.Len(t, challenges, 1)
+		assert.Nil(t, challenges[0].Tags)
+	})
+}
+
+// TestNormalizeChallengeTags tests cleanup of the tag filter
+func TestNormalizeChallengeTags(t *testing.T) {
+	assert.Equal(t, []string{"loops", "Recursion"}, services.NormalizeChallengeTags([]string{" loops", "", "Recursion", "loops "}))
+	assert.Empty(t, services.NormalizeChallengeTags(nil))
+	assert.Empty(t, services.NormalizeChallengeTags([]string{" ", ""}))
+}
@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ticketColumns = []string{
+	"id", "user_id", "lesson_id", "title", "message_id", "session_id", "message", "response", "rating",
+	"note", "status", "assigned_to", "resolution", "created_at", "updated_at", "resolved_at",
+}
+
+// TestValidateChatFeedback tests rating checks and comment clean-up
+func TestValidateChatFeedback(t *testing.T) {
+	blank := "   "
+	req, err := services.ValidateChatFeedback(models.ChatFeedbackRequest{Rating: "down", Comment: &blank})
+	require.NoError(t, err)
+	assert.Nil(t, req.Comment, "a blank comment is no comment")
+
+	comment := "  Skipped the part I asked about "
+	req, err = services.ValidateChatFeedback(models.ChatFeedbackRequest{Rating: "up", Comment: &comment})
+	require.NoError(t, err)
+	assert.Equal(t, "Skipped the part I asked about", *req.Comment)
+
+	_, err = services.ValidateChatFeedback(models.ChatFeedbackRequest{Rating: "meh"})
+	assert.ErrorIs(t, err, services.ErrInvalidChatFeedback)
+
+	long := strings.Repeat("é", 2001)
+	_, err = services.ValidateChatFeedback(models.ChatFeedbackRequest{Rating: "down", Comment: &long})
+	assert.ErrorIs(t, err, services.ErrInvalidChatFeedback)
+}
+
+// fakeChatRecorder records chat exchanges under id, or fails with err
+type fakeChatRecorder struct {
+	id  uuid.UUID
+	err error
+}
+
+func (f fakeChatRecorder) RecordChatMessage(uuid.UUID, uuid.UUID, uuid.UUID, string, string) (uuid.UUID, error) {
+	return f.id, f.err
+}
+
+// TestChatMessageID tests that chat answers come with the ID to rate or escalate them by
+func TestChatMessageID(t *testing.T) {
+	lessonID, messageID := uuid.New(), uuid.New()
+	mock := &testsupport.MockIntelligence{}
+	lessonService := services.NewLessonService(testsupport.RowsDB(lessonWithCompletionColumns, nullLessonRow(lessonID)))
+	lessonHandler := handlers.NewLessonHandler(lessonService, mock)
+	lessonHandler.SetChatRecorder(fakeChatRecorder{id: messageID})
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Post("/ngs/lessons/:id/chat/message", lessonHandler.SendEducatorChatMessage)
+	path := "/ngs/lessons/" + lessonID.String() + "/chat/message"
+
+	status, body := postJSON(t, app, path, `{"message": "What is a signal?"}`)
+	require.Equal(t, fiber.StatusOK, status, body)
+	assert.Equal(t, messageID.String(), body["message_id"])
+
+	lessonHandler.SetChatRecorder(fakeChatRecorder{err: errors.New("database down")})
+	status, body = postJSON(t, app, path, `{"message": "What is a signal?"}`)
+	require.Equal(t, fiber.StatusOK, status, "the answer is returned even if it cannot be kept")
+	assert.NotContains(t, body, "message_id")
+}
+
+// TestChatFeedbackHandlers tests rating answers and the educators' ticket queue
+func TestChatFeedbackHandlers(t *testing.T) {
+	newApp := func(db []string, rows ...[]driver.Value) *fiber.App {
+		handler := handlers.NewChatFeedbackHandler(services.NewChatFeedbackService(testsupport.RowsDB(db, rows...)))
+		app := fiber.New()
+		app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+		app.Put("/ngs/chat/messages/:id/feedback", handler.SetFeedback)
+		app.Get("/ngs/educator/tickets", handler.ListTickets)
+		app.Post("/ngs/educator/tickets/:id/resolve", handler.ResolveTicket)
+		return app
+	}
+	send := func(app *fiber.App, method, path, role, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User-Id", uuid.NewString())
+		req.Header.Set("X-User-Role", role)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		rec.Code = resp.StatusCode
+		_, _ = rec.Body.ReadFrom(resp.Body)
+		return rec
+	}
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	app := newApp([]string{"rating", "comment", "created_at", "updated_at"}, []driver.Value{"down", "Too vague", now, now})
+	messagePath := "/ngs/chat/messages/" + uuid.NewString() + "/feedback"
+	rec := send(app, "PUT", messagePath, "student", `{"rating": "down", "comment": "Too vague"}`)
+	require.Equal(t, fiber.StatusOK, rec.Code, rec.Body.String())
+	var feedback models.ChatFeedback
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &feedback))
+	assert.Equal(t, "down", feedback.Rating)
+	assert.Equal(t, "Too vague", *feedback.Comment)
+
+	rec = send(app, "PUT", messagePath, "student", `{"rating": "sideways"}`)
+	assert.Equal(t, fiber.StatusBadRequest, rec.Code)
+
+	ticketID, learnerID := uuid.New(), uuid.New()
+	app = newApp(ticketColumns, []driver.Value{
+		ticketID.String(), learnerID.String(), uuid.NewString(), "Signals", uuid.NewString(), uuid.NewString(),
+		"What is a signal?", "A signal is...", "down", "Still confused", "open", nil, nil, now, now, nil,
+	})
+	rec = send(app, "GET", "/ngs/educator/tickets", "educator", "")
+	require.Equal(t, fiber.StatusOK, rec.Code, rec.Body.String())
+	var list struct {
+		Tickets []models.EducatorTicket `json:"tickets"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Tickets, 1)
+	ticket := list.Tickets[0]
+	assert.Equal(t, ticketID, ticket.ID)
+	assert.Equal(t, learnerID, ticket.UserID)
+	assert.Equal(t, "Signals", ticket.LessonTitle)
+	assert.Equal(t, "down", *ticket.Rating)
+	assert.Equal(t, "Still confused", *ticket.Note)
+	assert.Nil(t, ticket.AssignedTo)
+
+	rec = send(app, "GET", "/ngs/educator/tickets", "student", "")
+	assert.Equal(t, fiber.StatusForbidden, rec.Code)
+	rec = send(app, "GET", "/ngs/educator/tickets?status=closed", "educator", "")
+	assert.Equal(t, fiber.StatusBadRequest, rec.Code)
+	rec = send(app, "POST", "/ngs/educator/tickets/"+ticketID.String()+"/resolve", "educator", `{"resolution": "  "}`)
+	assert.Equal(t, fiber.StatusBadRequest, rec.Code, "a ticket is resolved with an answer")
+}
@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/clients/intelligence"
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStreamEducatorChatMessage tests reading a streamed tutor chat answer from the Intelligence service
+func TestStreamEducatorChatMessage(t *testing.T) {
+	sessionID := uuid.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/educator/chat/stream", r.URL.Path)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"content\": \"Signals \", \"done\": false}\n\n")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "data: {\"content\": \"carry meaning.\", \"done\": false}\n\n")
+		fmt.Fprintf(w, "data: {\"content\": \"\", \"done\": true, \"session_id\": %q, \"tokens_used\": 12, \"latency_ms\": 40}\n\n", sessionID)
+	}))
+	defer server.Close()
+
+	client := intelligence.NewClient(server.URL, func() string { return "token" })
+	lessonID := uuid.New()
+	var chunks []string
+	resp, err := client.StreamEducatorChatMessage(context.Background(), intelligence.EducatorChatRequest{LessonID: lessonID, Message: "Hi"}, "user", "", "student", func(content string) error {
+		chunks = append(chunks, content)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Signals ", "carry meaning."}, chunks)
+	assert.Equal(t, "Signals carry meaning.", resp.Response)
+	assert.Equal(t, sessionID, resp.SessionID)
+	assert.Equal(t, lessonID, resp.LessonID)
+	assert.Equal(t, 12, resp.TokensUsed)
+
+	t.Run("error event", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "data: {\"error\": \"model unavailable\", \"done\": true}\n\n")
+		}))
+		defer server.Close()
+
+		client := intelligence.NewClient(server.URL, func() string { return "token" })
+		_, err := client.StreamEducatorChatMessage(context.Background(), intelligence.EducatorChatRequest{Message: "Hi"}, "user", "", "student", func(string) error { return nil })
+		assert.ErrorContains(t, err, "model unavailable")
+	})
+
+	t.Run("cancelled by the reader", func(t *testing.T) {
+		upstreamGone := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "data: {\"content\": \"Signals \", \"done\": false}\n\n")
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				close(upstreamGone)
+			case <-time.After(5 * time.Second):
+			}
+		}))
+		defer server.Close()
+
+		gone := errors.New("learner gone")
+		client := intelligence.NewClient(server.URL, func() string { return "token" })
+		_, err := client.StreamEducatorChatMessage(context.Background(), intelligence.EducatorChatRequest{Message: "Hi"}, "user", "", "student", func(string) error { return gone })
+		assert.ErrorIs(t, err, gone)
+		select {
+		case <-upstreamGone:
+		case <-time.After(3 * time.Second):
+			t.Fatal("the Intelligence service request was not cancelled")
+		}
+	})
+}
+
+// TestStreamEducatorChatMessageHandler tests that the chat stream endpoint relays each piece of
+// the answer as a server-sent event and ends with the session
+func TestStreamEducatorChatMessageHandler(t *testing.T) {
+	lessonID := uuid.New()
+	mock := &testsupport.MockIntelligence{StreamChunks: []string{"Signals ", "carry meaning."}}
+	lessonService := services.NewLessonService(testsupport.RowsDB(lessonWithCompletionColumns, nullLessonRow(lessonID)))
+	lessonHandler := handlers.NewLessonHandler(lessonService, mock)
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Post("/ngs/lessons/:id/chat/stream", lessonHandler.StreamEducatorChatMessage)
+	path := "/ngs/lessons/" + lessonID.String() + "/chat/stream"
+
+	req := httptest.NewRequest("POST", path, strings.NewReader(`{"message": "What is a signal?"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", uuid.NewString())
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	var events []map[string]interface{}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			var event map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(data), &event))
+			events = append(events, event)
+		}
+	}
+	require.Len(t, events, 3)
+	assert.Equal(t, "Signals ", events[0]["content"])
+	assert.Equal(t, false, events[0]["done"])
+	assert.Equal(t, "carry meaning.", events[1]["content"])
+	assert.Equal(t, true, events[2]["done"])
+	assert.Equal(t, lessonID.String(), events[2]["lesson_id"])
+	require.Len(t, mock.ChatRequests, 1)
+	assert.Equal(t, "What is a signal?", mock.ChatRequests[0].Message)
+
+	mock.Err = errors.New("intelligence down")
+	req = httptest.NewRequest("POST", path, strings.NewReader(`{"message": "What is a signal?"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", uuid.NewString())
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode, "the stream has started before the answer fails")
+	scanner = bufio.NewScanner(resp.Body)
+	require.True(t, scanner.Scan())
+	assert.Contains(t, scanner.Text(), `"error":"Failed to send chat message"`)
+
+	status, _ := postJSON(t, app, path, `{}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+}
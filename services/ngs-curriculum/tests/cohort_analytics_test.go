@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCompletionRate tests the share of lesson completions a cohort made
+func TestCompletionRate(t *testing.T) {
+	assert.Equal(t, 0.25, services.CompletionRate(5, 4, 5))
+	assert.Equal(t, float64(0), services.CompletionRate(3, 0, 5), "no lessons")
+	assert.Equal(t, float64(0), services.CompletionRate(0, 4, 0), "no members")
+	assert.Equal(t, float64(1), services.CompletionRate(9, 2, 4), "completions of members who left are capped")
+}
+
+// TestXPBuckets tests that every XP bucket is listed, empty ones included
+func TestXPBuckets(t *testing.T) {
+	buckets := services.XPBuckets(map[int]int{0: 3, 2: 1, 7: 2})
+	require.Len(t, buckets, 8)
+
+	assert.Equal(t, 0, buckets[0].MinXP)
+	require.NotNil(t, buckets[0].MaxXP)
+	assert.Equal(t, 100, *buckets[0].MaxXP)
+	assert.Equal(t, 3, buckets[0].Members)
+
+	assert.Equal(t, 0, buckets[1].Members)
+	assert.Equal(t, 250, buckets[2].MinXP)
+	assert.Equal(t, 1, buckets[2].Members)
+
+	last := buckets[len(buckets)-1]
+	assert.Equal(t, 10000, last.MinXP)
+	assert.Nil(t, last.MaxXP, "the last bucket is open ended")
+	assert.Equal(t, 2, last.Members)
+}
+
+// TestReflectionWeeks tests the weekly reflection quality series and its trend
+func TestReflectionWeeks(t *testing.T) {
+	// A Wednesday; its week starts on Monday the 5th
+	now := time.Date(2026, 10, 7, 15, 30, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC), services.WeekStart(now))
+	assert.Equal(t, time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC), services.WeekStart(time.Date(2026, 10, 11, 23, 0, 0, 0, time.UTC)), "Sunday ends the week")
+
+	quality := func(q float64) *float64 { return &q }
+	since := time.Date(2026, 9, 14, 0, 0, 0, 0, time.UTC)
+	weeks := services.ReflectionWeeks(since, 4, []models.ReflectionQualityWeek{
+		{WeekStart: since, Reflections: 4, AverageQuality: quality(0.5), HighQuality: 0},
+		{WeekStart: since.AddDate(0, 0, 14), Reflections: 2, AverageQuality: quality(0.7), HighQuality: 1},
+		{WeekStart: since.AddDate(0, 0, 21), Reflections: 5, AverageQuality: quality(0.85), HighQuality: 4},
+	})
+	require.Len(t, weeks, 4)
+	assert.Equal(t, since.AddDate(0, 0, 7), weeks[1].WeekStart)
+	assert.Equal(t, 0, weeks[1].Reflections)
+	assert.Nil(t, weeks[1].AverageQuality, "a week without reflections has no average")
+	assert.Equal(t, 4, weeks[3].HighQuality)
+	assert.Equal(t, services.ReflectionTrendImproving, services.ReflectionTrend(weeks))
+
+	declining := []models.ReflectionQualityWeek{
+		{Reflections: 3, AverageQuality: quality(0.8)},
+		{Reflections: 3, AverageQuality: quality(0.6)},
+	}
+	assert.Equal(t, services.ReflectionTrendDeclining, services.ReflectionTrend(declining))
+
+	steady := []models.ReflectionQualityWeek{
+		{Reflections: 3, AverageQuality: quality(0.6)},
+		{},
+		{Reflections: 1, AverageQuality: quality(0.61)},
+	}
+	assert.Equal(t, services.ReflectionTrendSteady, services.ReflectionTrend(steady))
+
+	assert.Equal(t, services.ReflectionTrendInsufficient, services.ReflectionTrend(weeks[:2]))
+}
+
+// TestCohortAnalyticsHandler tests who can read a cohort's analytics and query validation
+func TestCohortAnalyticsHandler(t *testing.T) {
+	cohortID, educatorID := uuid.New(), uuid.New()
+	db := testsupport.RowsDB(
+		[]string{"id", "name", "educator_id", "content_age_band", "created_at", "updated_at", "members"},
+		[]driver.Value{cohortID.String(), "Period 3", educatorID.String(), nil, testsupport.Epoch, testsupport.Epoch, int64(12)},
+	)
+	analyticsService := services.NewAnalyticsService(db, services.NewCohortService(db), services.SystemClock{})
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/analytics/cohorts/:id", handlers.NewAnalyticsHandler(analyticsService).GetCohortAnalytics)
+
+	get := func(path string, userID uuid.UUID, role string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-User-Id", userID.String())
+		req.Header.Set("X-User-Role", role)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	path := "/ngs/analytics/cohorts/" + cohortID.String()
+	assert.Equal(t, fiber.StatusForbidden, get(path, uuid.New(), "student"))
+	assert.Equal(t, fiber.StatusForbidden, get(path, uuid.New(), "educator"), "another educator's cohort")
+	assert.Equal(t, fiber.StatusBadRequest, get("/ngs/analytics/cohorts/not-a-uuid", educatorID, "educator"))
+	assert.Equal(t, fiber.StatusBadRequest, get(path+"?weeks=0", educatorID, "educator"))
+	assert.Equal(t, fiber.StatusBadRequest, get(path+"?stuck_days=91", educatorID, "educator"))
+}
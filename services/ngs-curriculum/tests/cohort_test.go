@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"errors"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestValidateCohortCurriculum tests cohort sequencing validation
+func TestValidateCohortCurriculum(t *testing.T) {
+	order := func(n int) *int { return &n }
+	lessonID := uuid.New()
+
+	tests := []struct {
+		name    string
+		req     models.SetCohortCurriculumRequest
+		wantErr bool
+	}{
+		{"Empty clears overrides", models.SetCohortCurriculumRequest{}, false},
+		{"Reorder and hide", models.SetCohortCurriculumRequest{
+			Lessons:      []models.CohortLessonOverride{{LessonID: lessonID, LessonOrder: order(3)}, {LessonID: uuid.New(), Hidden: true}},
+			HiddenTracks: []string{"data_science", "ml_engineering"},
+		}, false},
+		{"Missing lesson ID", models.SetCohortCurriculumRequest{
+			Lessons: []models.CohortLessonOverride{{Hidden: true}},
+		}, true},
+		{"Duplicate lesson", models.SetCohortCurriculumRequest{
+			Lessons: []models.CohortLessonOverride{{LessonID: lessonID, Hidden: true}, {LessonID: lessonID, LessonOrder: order(1)}},
+		}, true},
+		{"Non-positive order", models.SetCohortCurriculumRequest{
+			Lessons: []models.CohortLessonOverride{{LessonID: lessonID, LessonOrder: order(0)}},
+		}, true},
+		{"Override changes nothing", models.SetCohortCurriculumRequest{
+			Lessons: []models.CohortLessonOverride{{LessonID: lessonID}},
+		}, true},
+		{"Unknown track", models.SetCohortCurriculumRequest{HiddenTracks: []string{"robotics"}}, true},
+		{"Duplicate track", models.SetCohortCurriculumRequest{HiddenTracks: []string{"ethical_ai", "ethical_ai"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := services.ValidateCohortCurriculum(tt.req)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, services.ErrInvalidCurriculum), "got %v", err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var teamRoles = []byte(`{"roles": [
+	{"name": "Architect", "weight": 2, "min_chars": 20},
+	{"name": "Builder"},
+	{"name": "builder"},
+	{"name": " "},
+	{"name": "Tester", "weight": -1}
+]}`)
+
+// TestCollaborationRoles tests reading a challenge's roles from its metadata
+func TestCollaborationRoles(t *testing.T) {
+	assert.Equal(t, []models.CollaborationRole{{Name: "Lead", Weight: 1}, {Name: "Contributor", Weight: 1}},
+		services.CollaborationRoles(&models.Challenge{}))
+
+	assert.Equal(t, []models.CollaborationRole{
+		{Name: "Architect", Weight: 2, MinChars: 20},
+		{Name: "Builder", Weight: 1},
+		{Name: "Tester", Weight: 1},
+	}, services.CollaborationRoles(&models.Challenge{Metadata: teamRoles}))
+}
+
+// TestMissingCollaborationRoles tests the completeness rules for submitting a collaboration
+func TestMissingCollaborationRoles(t *testing.T) {
+	roles := services.CollaborationRoles(&models.Challenge{Metadata: teamRoles})
+
+	members := []models.CollaborationMember{
+		{UserID: uuid.New(), Role: "Architect", Section: "Too short"},
+		{UserID: uuid.New(), Role: "Builder", Section: "   "},
+	}
+	assert.Equal(t, []string{"Architect", "Builder", "Tester"}, services.MissingCollaborationRoles(roles, members),
+		"short and blank sections and unfilled roles are missing")
+
+	members[0].Section = "Services, queues and the data model"
+	members[1].Section = "Implemented the queue consumer"
+	members = append(members, models.CollaborationMember{UserID: uuid.New(), Role: "Tester", Section: "Load tests"})
+	assert.Empty(t, services.MissingCollaborationRoles(roles, members))
+}
+
+// TestSplitCollaborationXP tests splitting XP by role weight
+func TestSplitCollaborationXP(t *testing.T) {
+	roles := services.CollaborationRoles(&models.Challenge{Metadata: teamRoles})
+	architect, builder, tester := uuid.New(), uuid.New(), uuid.New()
+	members := []models.CollaborationMember{
+		{UserID: architect, Role: "Architect"},
+		{UserID: builder, Role: "Builder"},
+		{UserID: tester, Role: "Tester"},
+	}
+
+	assert.Equal(t, map[uuid.UUID]int{architect: 50, builder: 25, tester: 25}, services.SplitCollaborationXP(100, roles, members))
+	assert.Equal(t, map[uuid.UUID]int{architect: 37, builder: 18, tester: 18}, services.SplitCollaborationXP(75, roles, members),
+		"shares round down")
+}
+
+// TestCollaborationChallengeRouting tests that collaboration challenges are only answered through a collaboration
+func TestCollaborationChallengeRouting(t *testing.T) {
+	collaborationRow := challengeRow(uuid.New(), nil)
+	collaborationRow[5], collaborationRow[13] = "collaboration", teamRoles
+	db := testsupport.RowsDB(challengeColumns, collaborationRow)
+	challengeService := services.NewChallengeService(db, progressConfig(), nil)
+	collaborationService := services.NewCollaborationService(db, progressConfig(), challengeService, services.SystemClock{})
+
+	_, err := challengeService.SubmitChallenge(uuid.New(), models.SubmitChallengeRequest{ChallengeID: uuid.New(), SubmissionCode: "print(1)"})
+	assert.ErrorIs(t, err, services.ErrCollaborationRequired)
+
+	_, err = collaborationService.CreateCollaboration(uuid.New(), uuid.New(), "Manager")
+	assert.ErrorIs(t, err, services.ErrInvalidCollaborationRole)
+
+	coding := testsupport.RowsDB(challengeColumns, challengeRow(uuid.New(), nil))
+	_, err = services.NewCollaborationService(coding, progressConfig(), challengeService, services.SystemClock{}).
+		CreateCollaboration(uuid.New(), uuid.New(), "Lead")
+	assert.ErrorIs(t, err, services.ErrNotCollaborationChallenge)
+
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Post("/ngs/challenges/:id/collaborations", handlers.NewCollaborationHandler(collaborationService).CreateCollaboration)
+	req := httptest.NewRequest("POST", "/ngs/challenges/"+uuid.NewString()+"/collaborations", strings.NewReader(`{"role": "Manager"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", uuid.NewString())
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetCompletedLessons tests the completion set counted per lesson level
+func TestGetCompletedLessons(t *testing.T) {
+	first, second, third := uuid.New(), uuid.New(), uuid.New()
+	db := testsupport.RowsDB([]string{"lesson_id", "level_id"},
+		[]driver.Value{first.String(), int64(1)},
+		[]driver.Value{second.String(), int64(1)},
+		[]driver.Value{third.String(), int64(4)},
+	)
+	set, err := services.NewLessonService(db).GetCompletedLessons(uuid.New())
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{first, second, third}, set.LessonIDs, "in query order")
+	assert.Equal(t, map[int]int{1: 2, 4: 1}, set.ByLevel)
+	assert.Equal(t, 3, set.Count)
+
+	t.Run("Handler", func(t *testing.T) {
+		handler := handlers.NewLessonHandler(services.NewLessonService(db), &testsupport.MockIntelligence{})
+		app := fiber.New()
+		app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+		app.Get("/ngs/lessons/completions", handler.GetCompletedLessons)
+
+		body := getList(t, app, "/ngs/lessons/completions")
+		assert.JSONEq(t, `{"1": 2, "4": 1}`, string(body["by_level"]), "keyed by level number")
+		assert.JSONEq(t, `3`, string(body["count"]))
+	})
+
+	t.Run("None", func(t *testing.T) {
+		handler := handlers.NewLessonHandler(services.NewLessonService(testsupport.RowsDB([]string{"lesson_id", "level_id"})), &testsupport.MockIntelligence{})
+		app := fiber.New()
+		app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+		app.Get("/ngs/lessons/completions", handler.GetCompletedLessons)
+
+		body := getList(t, app, "/ngs/lessons/completions")
+		var set models.LessonCompletionSet
+		raw, err := json.Marshal(body)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(raw, &set))
+		assert.JSONEq(t, `[]`, string(body["lesson_ids"]), "an empty list, not null")
+		assert.JSONEq(t, `{}`, string(body["by_level"]))
+		assert.Equal(t, 0, set.Count)
+	})
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseCompletionCriteria tests decoding and validating a lesson's completion criteria
+func TestParseCompletionCriteria(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    models.CompletionCriteria
+		wantErr bool
+	}{
+		{"Empty", ``, models.CompletionCriteria{}, false},
+		{"Null", `null`, models.CompletionCriteria{}, false},
+		{"No criteria", `{}`, models.CompletionCriteria{}, false},
+		{"All criteria", `{"min_reflection_words": 50, "min_quiz_score": 60, "required_artifact": "artifact_url"}`,
+			models.CompletionCriteria{MinReflectionWords: 50, MinQuizScore: 60, RequiredArtifact: "artifact_url"}, false},
+		{"Quiz score of 100", `{"min_quiz_score": 100}`, models.CompletionCriteria{MinQuizScore: 100}, false},
+		{"Unknown key", `{"min_words": 50}`, models.CompletionCriteria{}, true},
+		{"Negative word count", `{"min_reflection_words": -1}`, models.CompletionCriteria{}, true},
+		{"Negative quiz score", `{"min_quiz_score": -5}`, models.CompletionCriteria{}, true},
+		{"Quiz score over 100", `{"min_quiz_score": 101}`, models.CompletionCriteria{}, true},
+		{"Not an object", `[]`, models.CompletionCriteria{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			criteria, err := services.ParseCompletionCriteria(json.RawMessage(tt.raw))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, criteria)
+		})
+	}
+}
+
+// TestEvaluateCompletionCriteria tests which criteria a completion request fails
+func TestEvaluateCompletionCriteria(t *testing.T) {
+	tests := []struct {
+		name      string
+		criteria  models.CompletionCriteria
+		req       models.CompleteLessonRequest
+		quizScore int
+		unmet     int
+	}{
+		{"No criteria", models.CompletionCriteria{}, models.CompleteLessonRequest{}, 0, 0},
+		{"Enough words", models.CompletionCriteria{MinReflectionWords: 3},
+			models.CompleteLessonRequest{ReflectionText: "  loops  repeat\nwork "}, 0, 0},
+		{"Too few words", models.CompletionCriteria{MinReflectionWords: 4},
+			models.CompleteLessonRequest{ReflectionText: "loops repeat work"}, 0, 1},
+		{"Whitespace is not words", models.CompletionCriteria{MinReflectionWords: 1},
+			models.CompleteLessonRequest{ReflectionText: " \n\t "}, 0, 1},
+		{"Quiz score met", models.CompletionCriteria{MinQuizScore: 60}, models.CompleteLessonRequest{}, 60, 0},
+		{"Quiz score missed", models.CompletionCriteria{MinQuizScore: 60}, models.CompleteLessonRequest{}, 59, 1},
+		{"No quiz attempt", models.CompletionCriteria{MinQuizScore: 60}, models.CompleteLessonRequest{}, 0, 1},
+		{"Artifact given", models.CompletionCriteria{RequiredArtifact: "artifact_url"},
+			models.CompleteLessonRequest{Metadata: map[string]interface{}{"artifact_url": "https://example.com/app"}}, 0, 0},
+		{"Artifact missing", models.CompletionCriteria{RequiredArtifact: "artifact_url"},
+			models.CompleteLessonRequest{Metadata: map[string]interface{}{"other": "x"}}, 0, 1},
+		{"Artifact without metadata", models.CompletionCriteria{RequiredArtifact: "artifact_url"},
+			models.CompleteLessonRequest{}, 0, 1},
+		{"Artifact blank", models.CompletionCriteria{RequiredArtifact: "artifact_url"},
+			models.CompleteLessonRequest{Metadata: map[string]interface{}{"artifact_url": "   "}}, 0, 1},
+		{"Artifact null", models.CompletionCriteria{RequiredArtifact: "artifact_url"},
+			models.CompleteLessonRequest{Metadata: map[string]interface{}{"artifact_url": nil}}, 0, 1},
+		{"Every criterion missed", models.CompletionCriteria{MinReflectionWords: 10, MinQuizScore: 80, RequiredArtifact: "artifact_url"},
+			models.CompleteLessonRequest{ReflectionText: "short"}, 20, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Len(t, services.EvaluateCompletionCriteria(tt.criteria, tt.req, tt.quizScore), tt.unmet)
+		})
+	}
+
+	unmet := services.EvaluateCompletionCriteria(models.CompletionCriteria{MinReflectionWords: 5},
+		models.CompleteLessonRequest{ReflectionText: "one two"}, 0)
+	assert.Equal(t, []string{"reflection must be at least 5 words (got 2)"}, unmet)
+}
+
+// TestCompletionCriteriaHandler tests that unmet criteria are listed in a 422 response, with the
+// quiz score taken from the learner's best graded attempt rather than the request
+func TestCompletionCriteriaHandler(t *testing.T) {
+	db := testsupport.QueryDB(testsupport.Query{
+		Match:   "completion_criteria",
+		Columns: []string{"id", "level_id", "title", "xp_reward", "completion_criteria", "locked"},
+		Rows: [][]driver.Value{{uuid.NewString(), int64(1), "Hello, world", int64(50),
+			[]byte(`{"min_reflection_words": 5, "min_quiz_score": 60, "required_artifact": "artifact_url"}`), false}},
+	}, testsupport.Query{
+		Match: "FROM lesson_quiz_attempts", Columns: []string{"max"}, Rows: [][]driver.Value{{int64(40)}},
+	})
+	lessonHandler := handlers.NewLessonHandler(services.NewLessonService(db), &testsupport.MockIntelligence{})
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Post("/ngs/lessons/:id/complete", lessonHandler.CompleteLessonHandler)
+
+	status, body := postJSON(t, app, "/ngs/lessons/"+uuid.NewString()+"/complete", `{"reflection_text": "It printed", "score": 100}`)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	assert.Equal(t, "Lesson completion criteria not met", body["error"])
+	assert.Equal(t, []interface{}{
+		"reflection must be at least 5 words (got 2)",
+		"quiz score must be at least 60 (got 40)",
+		`artifact "artifact_url" is required`,
+	}, body["unmet_criteria"])
+}
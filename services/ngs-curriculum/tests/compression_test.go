@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"net/http/httptest"
+	"noble-ngs-curriculum/internal/handlers"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCompressContent tests encoding negotiation on content-heavy routes
+func TestCompressContent(t *testing.T) {
+	compress, err := handlers.CompressContent("default")
+	require.NoError(t, err)
+
+	markdown := strings.Repeat("# Lesson\n\nPractice kindness with your agent.\n", 500)
+	app := fiber.New()
+	app.Get("/content", compress, func(c *fiber.Ctx) error { return c.SendString(markdown) })
+	app.Get("/tiny", compress, func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+	tests := []struct {
+		path           string
+		acceptEncoding string
+		want           string
+	}{
+		{"/content", "gzip, deflate, br", "br"},
+		{"/content", "gzip", "gzip"},
+		{"/content", "", ""},
+		{"/tiny", "gzip, br", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, resp.Header.Get("Content-Encoding"), "%s with %q", tt.path, tt.acceptEncoding)
+		if tt.want != "" {
+			assert.Less(t, resp.ContentLength, int64(len(markdown)))
+		}
+	}
+
+	_, err = handlers.CompressContent("fastest")
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"noble-ngs-curriculum/internal/clients/connectors"
+	"noble-ngs-curriculum/internal/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseFrontMatter tests front-matter extraction from imported markdown
+func TestParseFrontMatter(t *testing.T) {
+	fm, body := connectors.ParseFrontMatter("---\nTitle: \"Neural Nets\"\nLevel: 7\ntags:\n  - ml\n---\n# Body\n")
+	assert.Equal(t, "Neural Nets", fm["title"])
+	assert.Equal(t, "7", fm["level"])
+	assert.Equal(t, "", fm["tags"])
+	assert.Equal(t, "# Body\n", body)
+
+	fm, body = connectors.ParseFrontMatter("# No front matter\n")
+	assert.Empty(t, fm)
+	assert.Equal(t, "# No front matter\n", body)
+}
+
+// TestNotionToFrontMatter tests converting Notion page properties into front matter
+func TestNotionToFrontMatter(t *testing.T) {
+	title, content := connectors.NotionToFrontMatter("# Ethics 101\n\nLevel: 3\nLesson Type: reflection\n\nBody text")
+	assert.Equal(t, "Ethics 101", title)
+
+	fm, body := connectors.ParseFrontMatter(content)
+	assert.Equal(t, "3", fm["level"])
+	assert.Equal(t, "reflection", fm["lesson_type"])
+	assert.Equal(t, "# Ethics 101\n\nBody text", body)
+}
+
+// TestMapLessonFields tests mapping front matter onto lesson fields
+func TestMapLessonFields(t *testing.T) {
+	fields, warnings := services.MapLessonFields(map[string]string{
+		"level":    "4",
+		"order":    "2",
+		"xp":       "lots",
+		"age_band": "teen",
+		"module":   "9",
+		"owner":    "content-team",
+		"requires": "[intro-101, 'ethics-201']",
+	}, map[string]string{"Module": "lesson_order"}, "Fallback Title")
+
+	assert.Equal(t, "Fallback Title", fields.Title)
+	require.NotNil(t, fields.LevelID)
+	assert.Equal(t, 4, *fields.LevelID)
+	require.NotNil(t, fields.LessonOrder)
+	assert.Equal(t, 2, *fields.LessonOrder, "keys apply in sorted order, so order wins over module")
+	assert.Nil(t, fields.XPReward)
+	assert.Equal(t, "teen", fields.MinAgeBand)
+	assert.Equal(t, []string{"intro-101", "ethics-201"}, fields.Prerequisites)
+	assert.Len(t, warnings, 2, "invalid xp and unknown owner key")
+}
@@ -0,0 +1,174 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const samplePackYAML = `
+version: 1
+name: Sample
+levels:
+  - number: 2
+    title: Foundational Awareness
+    lessons:
+      - external_id: sample-loops
+        order: 6
+        title: Loops
+        content_markdown: |
+          # Loops
+
+          Repeat work with for and while.
+        prerequisites: [ngs-l02-core]
+    challenges:
+      - external_id: sample-double
+        lesson: sample-loops
+        title: Double it
+        description: Print twice the number read from stdin
+        challenge_type: coding
+        test_cases:
+          - input: "3"
+            expected: "6"
+`
+
+// TestParseCurriculumPack tests that YAML and JSON packs read the same fields
+func TestParseCurriculumPack(t *testing.T) {
+	pack, err := services.ParseCurriculumPack([]byte(samplePackYAML), services.PackFormatYAML)
+	require.NoError(t, err)
+	require.Len(t, pack.Levels, 1)
+	lesson := pack.Levels[0].Lessons[0]
+	assert.Equal(t, "sample-loops", lesson.ExternalID)
+	assert.Equal(t, "# Loops\n\nRepeat work with for and while.\n", lesson.ContentMarkdown)
+	assert.Equal(t, []string{"ngs-l02-core"}, lesson.Prerequisites)
+	assert.JSONEq(t, `[{"input": "3", "expected": "6"}]`, string(pack.Levels[0].Challenges[0].TestCases))
+	require.NoError(t, services.ValidateCurriculumPack(pack))
+
+	data, err := json.Marshal(pack)
+	require.NoError(t, err)
+	fromJSON, err := services.ParseCurriculumPack(data, services.PackFormatJSON)
+	require.NoError(t, err)
+	assert.Equal(t, pack, fromJSON)
+
+	_, err = services.ParseCurriculumPack([]byte("version: 1\nlevels:\n  - number: 1\n    titel: Typo\n"), services.PackFormatYAML)
+	assert.ErrorIs(t, err, services.ErrInvalidCurriculumPack)
+	assert.Contains(t, err.Error(), `unknown field "titel"`)
+}
+
+// TestMarshalCurriculumPack tests that exported packs read back unchanged in either format
+func TestMarshalCurriculumPack(t *testing.T) {
+	pack, err := services.ParseCurriculumPack([]byte(samplePackYAML), services.PackFormatYAML)
+	require.NoError(t, err)
+
+	for _, format := range []string{services.PackFormatJSON, services.PackFormatYAML} {
+		data, err := services.MarshalCurriculumPack(pack, format)
+		require.NoError(t, err)
+		again, err := services.ParseCurriculumPack(data, format)
+		require.NoError(t, err, format)
+		want, _ := json.Marshal(pack)
+		got, _ := json.Marshal(again)
+		assert.JSONEq(t, string(want), string(got), format)
+	}
+
+	data, err := services.MarshalCurriculumPack(pack, services.PackFormatYAML)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "version: 1\nname: Sample\nlevels:\n"), "fields keep their order")
+	assert.Contains(t, string(data), "content_markdown: |", "markdown is a literal block")
+	assert.Contains(t, string(data), `input: "3"`, "strings that look like numbers stay strings")
+}
+
+// TestDefaultCurriculumPack tests the built-in pack seeded at startup
+func TestDefaultCurriculumPack(t *testing.T) {
+	pack, err := services.DefaultCurriculumPack()
+	require.NoError(t, err)
+	require.NoError(t, services.ValidateCurriculumPack(pack))
+	require.Len(t, pack.Levels, 24)
+
+	lessons := 0
+	for i, level := range pack.Levels {
+		assert.Equal(t, i+1, level.Number)
+		assert.Len(t, level.Lessons, 5, "core lesson and four tracks")
+		lessons += len(level.Lessons)
+	}
+	assert.Equal(t, 120, lessons)
+	assert.Equal(t, "ngs-l01-core", pack.Levels[0].Lessons[0].ExternalID)
+}
+
+// TestValidateCurriculumPack tests that every problem is reported with where it is
+func TestValidateCurriculumPack(t *testing.T) {
+	pack := &models.CurriculumPack{
+		Version: 2,
+		Levels: []models.PackLevel{
+			{Number: 1, Title: "One", Lessons: []models.PackLesson{
+				{ExternalID: "a", Order: 1, Title: "A"},
+				{ExternalID: "a", Order: 0, Title: "B", MinAgeBand: "toddler"},
+			}},
+			{Number: 1, Title: "", Challenges: []models.PackChallenge{
+				{ExternalID: "c", Title: "C", Description: "D", ChallengeType: "coding"},
+			}},
+		},
+	}
+
+	err := services.ValidateCurriculumPack(pack)
+	var packErr *services.CurriculumPackError
+	require.ErrorAs(t, err, &packErr)
+	assert.ErrorIs(t, err, services.ErrInvalidCurriculumPack)
+	assert.Equal(t, []string{
+		"version: must be 1",
+		`levels[0].lessons[1]: external_id "a" is used by another lesson`,
+		"levels[0].lessons[1]: order must be at least 1",
+		"levels[0].lessons[1]: " + services.ErrInvalidAgeBand.Error(),
+		"levels[1]: level 1 appears more than once",
+		"levels[1]: title is required",
+		"levels[1].challenges[0]: coding challenges need at least one test case that is not hidden",
+	}, packErr.Problems)
+}
+
+// TestCurriculumPackHandlers tests the pack routes' access and request checks
+func TestCurriculumPackHandlers(t *testing.T) {
+	handler := handlers.NewCurriculumPackHandler(services.NewCurriculumPackService(testsupport.RowsDB([]string{"exists"}), testsupport.Config()))
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Post("/ngs/admin/curriculum/import", handler.ImportPack)
+	app.Get("/ngs/admin/curriculum/export", handler.ExportPack)
+
+	send := func(method, path, role, contentType, body string) (int, map[string]json.RawMessage) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		req.Header.Set("X-User-Id", uuid.NewString())
+		req.Header.Set("X-User-Role", role)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var decoded map[string]json.RawMessage
+		_ = json.NewDecoder(resp.Body).Decode(&decoded)
+		return resp.StatusCode, decoded
+	}
+
+	status, _ := send("POST", "/ngs/admin/curriculum/import", "educator", "application/yaml", samplePackYAML)
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	status, body := send("POST", "/ngs/admin/curriculum/import", "admin", "application/yaml", "version: 1\nlevels: []\n")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	assert.JSONEq(t, `["levels: a pack needs at least one level"]`, string(body["problems"]))
+
+	status, body = send("POST", "/ngs/admin/curriculum/import", "admin", "", `{"version": 1, "levels": [{"number": "one"}]}`)
+	assert.Equal(t, fiber.StatusBadRequest, status, "a body starting with { is read as JSON")
+	assert.Contains(t, string(body["error"]), "invalid curriculum pack")
+
+	status, _ = send("GET", "/ngs/admin/curriculum/export?format=xml", "admin", "", "")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCallerLabel tests metric labels derived from calling functions
+func TestCallerLabel(t *testing.T) {
+	tests := []struct {
+		function string
+		want     string
+	}{
+		{"noble-ngs-curriculum/internal/services.(*LessonService).GetLessonsByLevel", "LessonService.GetLessonsByLevel"},
+		{"noble-ngs-curriculum/internal/services.(*MediaService).AttachMedia.func1", "MediaService.AttachMedia"},
+		{"noble-ngs-curriculum/internal/services.SeedCurriculum", "SeedCurriculum"},
+		{"noble-ngs-curriculum/internal/services.rollupXPMetadata", "rollupXPMetadata"},
+		{"main.main", "main"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, database.CallerLabel(tt.function), tt.function)
+	}
+}
+
+// TestStartupBackoff tests the doubling, capped delay between startup checks
+func TestStartupBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, services.StartupBackoff(1, time.Second, 30*time.Second))
+	assert.Equal(t, 2*time.Second, services.StartupBackoff(2, time.Second, 30*time.Second))
+	assert.Equal(t, 16*time.Second, services.StartupBackoff(5, time.Second, 30*time.Second))
+	assert.Equal(t, 30*time.Second, services.StartupBackoff(6, time.Second, 30*time.Second))
+	assert.Equal(t, 30*time.Second, services.StartupBackoff(100, time.Second, 30*time.Second))
+}
+
+// TestWaitForDependency tests retrying until a check succeeds or attempts run out
+func TestWaitForDependency(t *testing.T) {
+	ctx := context.Background()
+
+	calls := 0
+	err := services.WaitForDependency(ctx, "flaky", 3, time.Millisecond, func(context.Context) error {
+		calls++
+		if calls < 2 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	calls = 0
+	down := errors.New("down")
+	err = services.WaitForDependency(ctx, "down", 3, time.Millisecond, func(context.Context) error {
+		calls++
+		return down
+	})
+	assert.ErrorIs(t, err, down)
+	assert.Equal(t, 3, calls)
+}
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/jobs"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var deadLetterColumns = []string{
+	"id", "kind", "class", "payload", "error", "attempts", "status", "first_failed_at", "last_failed_at",
+	"retried_by", "retried_at", "resolved_at", "discarded_by", "discarded_at",
+}
+
+// deadLetterRow is a dead letter as Postgres returns it, never retried or discarded
+func deadLetterRow(id int64, kind, status string, payload string) []driver.Value {
+	failed := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)
+	return []driver.Value{id, kind, "generation", []byte(payload), "intelligence service unavailable", int64(1), status, failed, failed, nil, nil, nil, nil, nil}
+}
+
+// TestValidateDeadLetterStatus tests the dead letter status filter
+func TestValidateDeadLetterStatus(t *testing.T) {
+	for _, status := range []string{"", "dead", "retrying", "resolved", "discarded"} {
+		assert.NoError(t, services.ValidateDeadLetterStatus(status), status)
+	}
+	assert.True(t, errors.Is(services.ValidateDeadLetterStatus("failed"), services.ErrInvalidDeadLetter))
+}
+
+// TestListDeadLetters tests scanning dead letters with their optional columns NULL
+func TestListDeadLetters(t *testing.T) {
+	db := testsupport.RowsDB(deadLetterColumns, deadLetterRow(7, "transcription", "dead", `{"media_id":"m1"}`))
+	jobService := services.NewJobService(db, jobs.New(jobs.Settings{Workers: 1, MaxWait: time.Minute}), services.SystemClock{})
+
+	letters, err := jobService.ListDeadLetters("dead", "", 50)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, int64(7), letters[0].ID)
+	assert.Equal(t, "transcription", letters[0].Kind)
+	assert.JSONEq(t, `{"media_id":"m1"}`, string(letters[0].Payload))
+	assert.Nil(t, letters[0].RetriedBy)
+	assert.Nil(t, letters[0].DiscardedAt)
+
+	_, err = jobService.ListDeadLetters("failed", "", 50)
+	assert.True(t, errors.Is(err, services.ErrInvalidDeadLetter))
+}
+
+// TestJobServiceRuns tests that jobs run with their payload, and that retries run the stored one
+func TestJobServiceRuns(t *testing.T) {
+	type payload struct {
+		MediaID string `json:"media_id"`
+	}
+
+	db := testsupport.RowsDB(deadLetterColumns, deadLetterRow(7, "transcription", "retrying", `{"media_id":"m1"}`))
+	queue := jobs.New(jobs.Settings{Workers: 1, MaxWait: time.Minute})
+	jobService := services.NewJobService(db, queue, services.SystemClock{})
+
+	ran := make(chan string, 2)
+	jobService.Register("transcription", jobs.ClassGeneration, func(ctx context.Context, raw json.RawMessage) error {
+		var p payload
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return err
+		}
+		ran <- p.MediaID
+		return errors.New("intelligence service unavailable")
+	})
+	nextRun := func() string {
+		select {
+		case id := <-ran:
+			return id
+		case <-time.After(2 * time.Second):
+			t.Fatal("job did not run")
+			return ""
+		}
+	}
+
+	t.Run("Submit runs the job with its payload", func(t *testing.T) {
+		require.NoError(t, jobService.Submit("transcription", "transcribe m2", payload{MediaID: "m2"}))
+		assert.Equal(t, "m2", nextRun())
+	})
+
+	t.Run("Retry runs the dead letter's payload again", func(t *testing.T) {
+		adminID := uuid.New()
+		letter, err := jobService.Retry(7, adminID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), letter.ID)
+		assert.Equal(t, "m1", nextRun())
+	})
+
+	t.Run("Unknown kinds are refused", func(t *testing.T) {
+		err := jobService.Submit("webhook", "deliver", payload{})
+		assert.True(t, errors.Is(err, services.ErrUnknownJobKind))
+
+		other := services.NewJobService(db, queue, services.SystemClock{})
+		_, err = other.Retry(7, uuid.New())
+		assert.True(t, errors.Is(err, services.ErrUnknownJobKind))
+	})
+
+	require.NoError(t, queue.Drain(context.Background()))
+}
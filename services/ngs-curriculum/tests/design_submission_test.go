@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"noble-ngs-curriculum/internal/clients/blobstore"
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pngHeader is enough of a PNG file for content sniffing
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// designConfig allows two assets of up to 1 KiB and a 1 KiB write-up
+func designConfig() *config.Config {
+	cfg := progressConfig()
+	cfg.SubmissionMaxCodeBytes = 1 << 10
+	cfg.DesignMaxAssets = 2
+	cfg.DesignAssetMaxBytes = 1 << 10
+	return cfg
+}
+
+// designChallengeRow is a challenge row of the given type with the given metadata
+func designChallengeRow(challengeType string, metadata driver.Value) []driver.Value {
+	row := challengeRow(uuid.New(), nil)
+	row[5], row[13] = challengeType, metadata
+	return row
+}
+
+// TestDesignRubric tests reading a challenge's rubric from its metadata
+func TestDesignRubric(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		rubric := services.DesignRubric(&models.Challenge{})
+		require.Len(t, rubric, 4)
+		assert.Equal(t, "Requirements", rubric[0].Name)
+	})
+
+	t.Run("From metadata", func(t *testing.T) {
+		rubric := services.DesignRubric(&models.Challenge{Metadata: []byte(`{"rubric": [
+			{"name": "Scalability", "max_points": 20},
+			{"name": "", "max_points": 5},
+			{"name": "Diagram", "max_points": 0},
+			{"name": "Security", "description": "Threats are addressed", "max_points": 10}
+		]}`)})
+		assert.Equal(t, []models.RubricCriterion{
+			{Name: "Scalability", MaxPoints: 20},
+			{Name: "Security", Description: "Threats are addressed", MaxPoints: 10},
+		}, rubric)
+	})
+}
+
+// TestScoreRubric tests checking rubric scores and turning them into a percentage
+func TestScoreRubric(t *testing.T) {
+	rubric := []models.RubricCriterion{{Name: "Scalability", MaxPoints: 20}, {Name: "Security", MaxPoints: 10}}
+
+	scores, score, err := services.ScoreRubric(rubric, []models.RubricScore{
+		{Criterion: "security", Points: 4, Comment: " Missing threat model "},
+		{Criterion: "Scalability", Points: 17},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 70, score)
+	assert.Equal(t, []models.RubricScore{
+		{Criterion: "Scalability", Points: 17, MaxPoints: 20},
+		{Criterion: "Security", Points: 4, MaxPoints: 10, Comment: "Missing threat model"},
+	}, scores)
+
+	invalid := map[string][]models.RubricScore{
+		"Missing criterion": {{Criterion: "Scalability", Points: 10}},
+		"Over max points":   {{Criterion: "Scalability", Points: 21}, {Criterion: "Security", Points: 0}},
+		"Negative points":   {{Criterion: "Scalability", Points: -1}, {Criterion: "Security", Points: 0}},
+		"Duplicate":         {{Criterion: "Scalability", Points: 1}, {Criterion: "scalability", Points: 1}, {Criterion: "Security", Points: 1}},
+		"Unknown criterion": {{Criterion: "Scalability", Points: 1}, {Criterion: "Security", Points: 1}, {Criterion: "Style", Points: 1}},
+	}
+	for name, scores := range invalid {
+		_, _, err := services.ScoreRubric(rubric, scores)
+		assert.ErrorIs(t, err, services.ErrInvalidReview, name)
+	}
+}
+
+// TestSubmitDesignValidation tests design submissions rejected before anything is stored
+func TestSubmitDesignValidation(t *testing.T) {
+	db := testsupport.RowsDB(challengeColumns, designChallengeRow("design", nil))
+	withoutBlobs := services.NewChallengeService(db, designConfig(), nil)
+	withBlobs := services.NewChallengeService(db, designConfig(), blobstore.NewFileStore(t.TempDir()))
+	submit := func(challengeService *services.ChallengeService, writeup string, uploads ...services.DesignUpload) error {
+		_, err := challengeService.SubmitDesign(uuid.New(), "", "", uuid.New(), writeup, uploads)
+		return err
+	}
+
+	assert.ErrorIs(t, submit(withoutBlobs, "  "), services.ErrInvalidDesignSubmission, "a write-up is required")
+	assert.ErrorIs(t, submit(withoutBlobs, strings.Repeat("x", 2<<10)), services.ErrSubmissionTooLarge)
+
+	png := services.DesignUpload{FileName: "diagram.png", Data: pngHeader}
+	assert.ErrorIs(t, submit(withBlobs, "# Design", png, png, png), services.ErrInvalidDesignSubmission, "too many assets")
+	assert.ErrorIs(t, submit(withoutBlobs, "# Design", png), services.ErrDesignAssetsUnavailable, "assets need a blob store")
+
+	svg := services.DesignUpload{FileName: "diagram.svg", Data: []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`)}
+	assert.ErrorIs(t, submit(withBlobs, "# Design", svg), services.ErrInvalidDesignSubmission, "SVG can carry script")
+
+	huge := services.DesignUpload{FileName: "huge.png", Data: append(append([]byte{}, pngHeader...), make([]byte, 2<<10)...)}
+	assert.ErrorIs(t, submit(withBlobs, "# Design", huge), services.ErrSubmissionTooLarge)
+}
+
+// TestDesignChallengeRouting tests that design challenges only take design submissions and vice versa
+func TestDesignChallengeRouting(t *testing.T) {
+	coding := services.NewChallengeService(testsupport.RowsDB(challengeColumns, designChallengeRow("coding", nil)), designConfig(), nil)
+	_, err := coding.SubmitDesign(uuid.New(), "", "", uuid.New(), "# Design", nil)
+	assert.ErrorIs(t, err, services.ErrNotDesignChallenge)
+
+	design := services.NewChallengeService(testsupport.RowsDB(challengeColumns, designChallengeRow("design", nil)), designConfig(), nil)
+	_, err = design.SubmitChallenge(uuid.New(), models.SubmitChallengeRequest{ChallengeID: uuid.New(), SubmissionCode: "print(1)"})
+	assert.ErrorIs(t, err, services.ErrDesignSubmissionRequired)
+}
+
+// TestDesignHandlers tests the multipart submission and educator-only review routes
+func TestDesignHandlers(t *testing.T) {
+	db := testsupport.RowsDB(challengeColumns, designChallengeRow("coding", nil))
+	challengeHandler := handlers.NewChallengeHandler(services.NewChallengeService(db, designConfig(), nil))
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Post("/ngs/challenges/:id/design", challengeHandler.SubmitDesign)
+	app.Get("/ngs/challenges/submissions/review-queue", challengeHandler.GetReviewQueue)
+	app.Post("/ngs/challenges/submissions/:id/review", challengeHandler.ReviewSubmission)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	require.NoError(t, form.WriteField("writeup", "# Design"))
+	part, err := form.CreateFormFile("assets", "diagram.png")
+	require.NoError(t, err)
+	_, err = part.Write(pngHeader)
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	req := httptest.NewRequest("POST", "/ngs/challenges/"+uuid.NewString()+"/design", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("X-User-Id", uuid.NewString())
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusServiceUnavailable, resp.StatusCode, "assets are refused without a blob store")
+
+	req = httptest.NewRequest("POST", "/ngs/challenges/"+uuid.NewString()+"/design", strings.NewReader(`{"writeup": "# Design"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", uuid.NewString())
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode, "JSON bodies are not multipart")
+
+	req = httptest.NewRequest("GET", "/ngs/challenges/submissions/review-queue", nil)
+	req.Header.Set("X-User-Id", uuid.NewString())
+	req.Header.Set("X-User-Role", "learner")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+
+	req = httptest.NewRequest("POST", "/ngs/challenges/submissions/"+uuid.NewString()+"/review", strings.NewReader(`{"scores": []}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", uuid.NewString())
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+}
@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var duelColumns = []string{
+	"id", "challenge_id", "level_number", "player_one_id", "player_two_id", "status",
+	"winner_id", "time_limit_seconds", "started_at", "ends_at", "completed_at", "created_at",
+}
+
+// TestDuelHub tests that a duel's watchers are signalled without blocking, and only while watching
+func TestDuelHub(t *testing.T) {
+	hub := services.NewDuelHub("")
+	duelID := uuid.New()
+	changes, unsubscribe := hub.Subscribe(duelID)
+	other, _ := hub.Subscribe(uuid.New())
+
+	hub.Publish(duelID)
+	hub.Publish(duelID)
+	assert.Len(t, changes, 1, "signals coalesce")
+	assert.Empty(t, other)
+
+	<-changes
+	unsubscribe()
+	hub.Publish(duelID)
+	assert.Empty(t, changes)
+}
+
+// duelSocketApp serves the duel socket for a single duel stored as row
+func duelSocketApp(hub *services.DuelHub, challengeID uuid.UUID, row []driver.Value) *fiber.App {
+	db := testsupport.QueryDB(
+		testsupport.Query{Match: "status = 'expired'"},
+		testsupport.Query{Match: "solution_template", Columns: challengeColumns, Rows: [][]driver.Value{challengeRow(challengeID, nil)}},
+		testsupport.Query{Match: "WHERE id = $1\n", Columns: duelColumns, Rows: [][]driver.Value{row}},
+	)
+	duelService := services.NewDuelService(db, progressConfig(), services.NewChallengeService(db, progressConfig(), nil), testsupport.NewFakeClock(testsupport.Epoch))
+	handler := handlers.NewDuelHandler(duelService, hub)
+
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/duels/:id/ws", handler.AcceptDuelSocket, handler.DuelSocket())
+	return app
+}
+
+// dialDuel opens a socket on app for userID
+func dialDuel(t *testing.T, app *fiber.App, duelID, userID uuid.UUID) *websocket.Conn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+ln.Addr().String()+"/ngs/duels/"+duelID.String()+"/ws",
+		http.Header{"X-User-Id": {userID.String()}})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+// TestDuelSocket tests that a participant's socket sends the duel state on connect and on each
+// change, and closes once the duel is over
+func TestDuelSocket(t *testing.T) {
+	userID, duelID, challengeID := uuid.New(), uuid.New(), uuid.New()
+	waiting := []driver.Value{duelID.String(), challengeID.String(), int64(3), userID.String(), nil,
+		"waiting", nil, int64(300), nil, nil, nil, testsupport.Epoch}
+
+	t.Run("Only for participants upgrading", func(t *testing.T) {
+		app := duelSocketApp(services.NewDuelHub(""), challengeID, waiting)
+
+		req := httptest.NewRequest("GET", "/ngs/duels/"+duelID.String()+"/ws", nil)
+		req.Header.Set("X-User-Id", uuid.NewString())
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+
+		req = httptest.NewRequest("GET", "/ngs/duels/"+duelID.String()+"/ws", nil)
+		req.Header.Set("X-User-Id", userID.String())
+		resp, err = app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusUpgradeRequired, resp.StatusCode)
+	})
+
+	t.Run("State on connect and on change", func(t *testing.T) {
+		hub := services.NewDuelHub("")
+		conn := dialDuel(t, duelSocketApp(hub, challengeID, waiting), duelID, userID)
+
+		var state models.DuelState
+		require.NoError(t, conn.ReadJSON(&state))
+		assert.Equal(t, duelID, state.Duel.ID)
+		assert.Equal(t, "waiting", state.Duel.Status)
+
+		hub.Publish(duelID)
+		state = models.DuelState{}
+		require.NoError(t, conn.ReadJSON(&state))
+		assert.Equal(t, "waiting", state.Duel.Status)
+	})
+
+	t.Run("Closed once the duel is over", func(t *testing.T) {
+		opponentID := uuid.New()
+		completed := []driver.Value{duelID.String(), challengeID.String(), int64(3), userID.String(), opponentID.String(),
+			"completed", opponentID.String(), int64(300), testsupport.Epoch, testsupport.Epoch.Add(5 * time.Minute),
+			testsupport.Epoch.Add(time.Minute), testsupport.Epoch}
+		conn := dialDuel(t, duelSocketApp(services.NewDuelHub(""), challengeID, completed), duelID, userID)
+
+		var state models.DuelState
+		require.NoError(t, conn.ReadJSON(&state))
+		assert.Equal(t, "completed", state.Duel.Status)
+		require.NotNil(t, state.Duel.WinnerID)
+		assert.Equal(t, opponentID, *state.Duel.WinnerID)
+		require.NotNil(t, state.Challenge)
+		assert.Empty(t, state.Challenge.SolutionTemplate)
+
+		_, _, err := conn.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), "got %v", err)
+	})
+}
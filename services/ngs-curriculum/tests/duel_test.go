@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDuelRating tests the Elo-style duel rating update
+func TestDuelRating(t *testing.T) {
+	t.Run("Evenly matched winner gains half of K", func(t *testing.T) {
+		winner, loser := services.CalculateElo(1200, 1200, 1.0)
+		assert.Equal(t, 1216, winner, "Winner should gain 16 points")
+		assert.Equal(t, 1184, loser, "Loser should lose 16 points")
+	})
+
+	t.Run("Draw between equal ratings changes nothing", func(t *testing.T) {
+		a, b := services.CalculateElo(1200, 1200, 0.5)
+		assert.Equal(t, 1200, a)
+		assert.Equal(t, 1200, b)
+	})
+
+	t.Run("Upset win moves ratings further than expected win", func(t *testing.T) {
+		upsetWinner, _ := services.CalculateElo(1000, 1400, 1.0)
+		expectedWinner, _ := services.CalculateElo(1400, 1000, 1.0)
+		assert.Greater(t, upsetWinner-1000, expectedWinner-1400, "Underdog should gain more for a win")
+	})
+
+	t.Run("Rating points are conserved", func(t *testing.T) {
+		a, b := services.CalculateElo(1350, 1180, 0.0)
+		assert.Equal(t, 1350+1180, a+b, "Total rating should be conserved")
+	})
+}
+
+// duelQueueService serves userID, at level 3, joining the duel queue with no open duel of their own.
+// The first statement containing opponentMatch finds the waiting duel; a new duel opened
+// instead uses a challenge picked by a statement containing challengeMatch, if that is set.
+func duelQueueService(userID, waitingID uuid.UUID, opponentMatch, challengeMatch string) *services.DuelService {
+	challengeID := uuid.New()
+	duelRow := []driver.Value{waitingID.String(), challengeID.String(), int64(3), uuid.NewString(), userID.String(),
+		"active", nil, int64(300), testsupport.Epoch, testsupport.Epoch.Add(5 * time.Minute), nil, testsupport.Epoch}
+	queries := []testsupport.Query{
+		{Match: "(player_one_id = $1 OR player_two_id = $1)"},
+		{Match: "status = 'expired'"},
+		{Match: "SELECT current_level FROM user_progress", Columns: []string{"current_level"}, Rows: [][]driver.Value{{int64(3)}}},
+		{Match: opponentMatch, Columns: []string{"id"}, Rows: [][]driver.Value{{waitingID.String()}}},
+	}
+	if challengeMatch != "" {
+		queries = append(queries,
+			testsupport.Query{Match: challengeMatch, Columns: []string{"id"}, Rows: [][]driver.Value{{challengeID.String()}}},
+			testsupport.Query{Match: "INSERT INTO duels", Columns: []string{"id"}, Rows: [][]driver.Value{{waitingID.String()}}})
+	}
+	queries = append(queries,
+		testsupport.Query{Match: "solution_template", Columns: challengeColumns, Rows: [][]driver.Value{challengeRow(challengeID, nil)}},
+		testsupport.Query{Match: "WHERE id = $1\n", Columns: duelColumns, Rows: [][]driver.Value{duelRow}})
+	db := testsupport.QueryDB(queries...)
+	return services.NewDuelService(db, progressConfig(), services.NewChallengeService(db, progressConfig(), nil), testsupport.NewFakeClock(testsupport.Epoch))
+}
+
+// TestJoinDuelQueueAgeBand tests that a user is only matched into a waiting duel whose challenge
+// suits their own age band, or a cohort's override, rather than only the opponent's who opened it
+func TestJoinDuelQueueAgeBand(t *testing.T) {
+	userID, waitingID := uuid.New(), uuid.New()
+
+	t.Run("Age filter on the duel's challenge", func(t *testing.T) {
+		// Only the opponent lookup ranks the band of the duel's challenge, c
+		state, err := duelQueueService(userID, waitingID, "CASE c.min_age_band", "").JoinQueue(userID)
+		require.NoError(t, err)
+		assert.Equal(t, waitingID, state.Duel.ID)
+		assert.Equal(t, "FizzBuzz", state.Challenge.Title)
+	})
+
+	t.Run("Cohort override for the joining user", func(t *testing.T) {
+		// The challenge pick applies the override too; without it in the lookup, the pick
+		// answers and no duel is created
+		state, err := duelQueueService(userID, waitingID, "WHERE cm.user_id = $2 AND co.content_age_band IS NOT NULL", "").JoinQueue(userID)
+		require.NoError(t, err)
+		assert.Equal(t, waitingID, state.Duel.ID)
+	})
+
+	t.Run("No suitable waiting duel", func(t *testing.T) {
+		state, err := duelQueueService(userID, waitingID, "no statement contains this", "challenge_type = 'coding'").JoinQueue(userID)
+		require.NoError(t, err)
+		assert.Equal(t, waitingID, state.Duel.ID, "a new duel is opened")
+
+		_, err = duelQueueService(userID, waitingID, "no statement contains this", "").JoinQueue(userID)
+		assert.ErrorIs(t, err, services.ErrNoDuelChallenge)
+	})
+}
+
+// TestJoinDuelQueueLock tests that joining takes the user's queue lock inside the transaction
+// before looking for their open duel, so concurrent joins cannot both open one
+func TestJoinDuelQueueLock(t *testing.T) {
+	userID := uuid.New()
+	var statements [][]driver.Value
+	db := testsupport.QueryDB(
+		testsupport.Query{Match: "pg_advisory_xact_lock", Args: &statements},
+		testsupport.Query{Match: "(player_one_id = $1 OR player_two_id = $1)", Args: &statements},
+		testsupport.Query{Match: "SELECT current_level FROM user_progress", Columns: []string{"current_level"}, Rows: [][]driver.Value{{int64(3)}}},
+	)
+	service := services.NewDuelService(db, progressConfig(), services.NewChallengeService(db, progressConfig(), nil), testsupport.NewFakeClock(testsupport.Epoch))
+
+	_, err := service.JoinQueue(userID)
+	assert.ErrorIs(t, err, services.ErrNoDuelChallenge)
+	require.GreaterOrEqual(t, len(statements), 2)
+	assert.Equal(t, []driver.Value{"ngs_duel_queue:" + userID.String()}, statements[0], "the lock comes first")
+	assert.Equal(t, []driver.Value{userID.String()}, statements[1])
+}
+
+// TestCancelDuelNotifies tests that cancelling a duel announces it to the duel's sockets
+func TestCancelDuelNotifies(t *testing.T) {
+	userID, duelID := uuid.New(), uuid.New()
+	var notifications [][]driver.Value
+	db := testsupport.QueryDB(
+		testsupport.Query{Match: "SET status = 'cancelled'", RowsAffected: 1},
+		testsupport.Query{Match: "pg_notify", Args: &notifications},
+	)
+	service := services.NewDuelService(db, progressConfig(), services.NewChallengeService(db, progressConfig(), nil), testsupport.NewFakeClock(testsupport.Epoch))
+
+	require.NoError(t, service.CancelDuel(duelID, userID))
+	assert.Equal(t, [][]driver.Value{{"ngs_duel_events", duelID.String()}}, notifications)
+}
@@ -0,0 +1,33 @@
+package tests
+
+import (
+	"errors"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestXPCurveSimulationHelpers tests the pure parts of the XP curve simulation
+func TestXPCurveSimulationHelpers(t *testing.T) {
+	t.Run("Source XP scales with the new default", func(t *testing.T) {
+		assert.Equal(t, 120, services.ProjectSourceXP(100, 2, 50, 60))
+		assert.Equal(t, 0, services.ProjectSourceXP(100, 2, 50, 0))
+		assert.Equal(t, 30, services.ProjectSourceXP(0, 3, 0, 10), "unknown sources are valued per event")
+	})
+
+	t.Run("Curve validation", func(t *testing.T) {
+		assert.NoError(t, services.ValidateXPCurve(models.XPCurveSimulationRequest{}))
+		assert.NoError(t, services.ValidateXPCurve(models.XPCurveSimulationRequest{Thresholds: []int{0, 50, 200}}))
+
+		invalid := []models.XPCurveSimulationRequest{
+			{Thresholds: []int{10, 50}},
+			{Thresholds: []int{0, 50, 50}},
+			{XPSources: map[string]int{"lesson_completion": -1}},
+		}
+		for _, req := range invalid {
+			assert.True(t, errors.Is(services.ValidateXPCurve(req), services.ErrInvalidSimulation))
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/egress"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseEgressPolicy tests reading EGRESS_PROXY_URL, EGRESS_NO_PROXY and EGRESS_ALLOWLIST
+func TestParseEgressPolicy(t *testing.T) {
+	policy, err := egress.ParsePolicy("http://proxy.internal:3128", "intelligence, *.svc.cluster.local", " TTS.example.com,*.storage.example.com,")
+	require.NoError(t, err)
+	assert.Equal(t, "proxy.internal:3128", policy.Proxy.Host)
+	assert.Equal(t, []string{"intelligence", "*.svc.cluster.local"}, policy.NoProxy)
+	assert.Equal(t, []string{"tts.example.com", "*.storage.example.com"}, policy.Allowlist)
+
+	for _, bad := range [][3]string{
+		{"proxy.internal:3128", "", ""},
+		{"ftp://proxy.internal", "", ""},
+		{"", "", "https://tts.example.com"},
+		{"", "", "tts.*.com"},
+		{"", "10.0.0.1:80", ""},
+	} {
+		_, err := egress.ParsePolicy(bad[0], bad[1], bad[2])
+		assert.Error(t, err, bad)
+	}
+}
+
+// TestEgressAllowlist tests matching hosts against the allowlist
+func TestEgressAllowlist(t *testing.T) {
+	assert.True(t, egress.Policy{}.Allowed("anything.example.com"), "an empty allowlist allows any host")
+
+	policy, err := egress.ParsePolicy("", "", "tts.example.com,*.storage.example.com")
+	require.NoError(t, err)
+	assert.True(t, policy.Allowed("tts.example.com"))
+	assert.True(t, policy.Allowed("TTS.example.com."))
+	assert.True(t, policy.Allowed("bucket.storage.example.com"))
+	assert.False(t, policy.Allowed("storage.example.com"), "wildcards match subdomains only")
+	assert.False(t, policy.Allowed("tts.example.com.evil.net"))
+	assert.False(t, policy.Allowed("example.com"))
+}
+
+// TestEgressClient tests that clients refuse hosts outside the allowlist, redirects included,
+// and go through the proxy except for no-proxy hosts
+func TestEgressClient(t *testing.T) {
+	defer egress.Configure(egress.Policy{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://blocked.example.com/", http.StatusFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	client := egress.NewClient(egress.DestinationTTS, 5*time.Second)
+
+	t.Run("Allowlisted hosts are reached and others refused", func(t *testing.T) {
+		policy, err := egress.ParsePolicy("", "", "127.0.0.1")
+		require.NoError(t, err)
+		egress.Configure(policy)
+
+		resp, err := client.Get(server.URL + "/")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		_, err = client.Get("http://blocked.example.com/")
+		assert.True(t, errors.Is(err, egress.ErrBlocked))
+
+		_, err = client.Get(server.URL + "/redirect")
+		assert.True(t, errors.Is(err, egress.ErrBlocked), "redirects are checked too")
+	})
+
+	t.Run("Requests go through the proxy unless the host is no-proxy", func(t *testing.T) {
+		var proxied []string
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxied = append(proxied, r.URL.Host)
+			w.Write([]byte("via proxy"))
+		}))
+		defer proxy.Close()
+
+		policy, err := egress.ParsePolicy(proxy.URL, "127.0.0.1", "")
+		require.NoError(t, err)
+		egress.Configure(policy)
+
+		resp, err := client.Get("http://tts.example.com/speak")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, []string{"tts.example.com"}, proxied)
+
+		resp, err = client.Get(server.URL + "/")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, []string{"tts.example.com"}, proxied, "no-proxy hosts connect directly")
+	})
+}
@@ -0,0 +1,32 @@
+package tests
+
+import (
+	"noble-ngs-curriculum/internal/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestExamAnswerMatching tests exam answer-key comparison
+func TestExamAnswerMatching(t *testing.T) {
+	choices := []string{"Signal", "Noise", "Echo"}
+
+	t.Run("Text answers match case-insensitively", func(t *testing.T) {
+		assert.True(t, services.ExamAnswerMatches("Signal", "  signal ", nil))
+	})
+
+	t.Run("Numeric key matches index or choice text", func(t *testing.T) {
+		assert.True(t, services.ExamAnswerMatches(float64(1), float64(1), choices))
+		assert.True(t, services.ExamAnswerMatches(float64(1), "noise", choices))
+		assert.False(t, services.ExamAnswerMatches(float64(1), "Echo", choices))
+	})
+
+	t.Run("Boolean answers match", func(t *testing.T) {
+		assert.True(t, services.ExamAnswerMatches(true, true, nil))
+		assert.False(t, services.ExamAnswerMatches(true, false, nil))
+	})
+
+	t.Run("Missing answer is incorrect", func(t *testing.T) {
+		assert.False(t, services.ExamAnswerMatches("Signal", nil, choices))
+	})
+}
@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"errors"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAssignVariant tests deterministic weighted bucketing
+func TestAssignVariant(t *testing.T) {
+	variants := []models.ExperimentVariant{{Name: "control", Weight: 50}, {Name: "treatment", Weight: 50}}
+
+	t.Run("Same user and key always get the same variant", func(t *testing.T) {
+		userID := uuid.New()
+		first := services.AssignVariant("xp_curve", userID, variants)
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, first, services.AssignVariant("xp_curve", userID, variants))
+		}
+	})
+
+	t.Run("Split follows weights", func(t *testing.T) {
+		weighted := []models.ExperimentVariant{{Name: "control", Weight: 90}, {Name: "treatment", Weight: 10}}
+		counts := map[string]int{}
+		for i := 0; i < 10000; i++ {
+			counts[services.AssignVariant("xp_curve", uuid.New(), weighted)]++
+		}
+		assert.InDelta(t, 9000, counts["control"], 300)
+		assert.InDelta(t, 1000, counts["treatment"], 300)
+	})
+
+	t.Run("No usable variants", func(t *testing.T) {
+		assert.Equal(t, "", services.AssignVariant("xp_curve", uuid.New(), nil))
+		assert.Equal(t, "", services.AssignVariant("xp_curve", uuid.New(), []models.ExperimentVariant{{Name: "off", Weight: 0}}))
+	})
+}
+
+// TestValidateExperiment tests experiment definition validation
+func TestValidateExperiment(t *testing.T) {
+	valid := models.UpsertExperimentRequest{
+		Key:      "xp_curve.v2",
+		Variants: []models.ExperimentVariant{{Name: "control", Weight: 1}, {Name: "treatment", Weight: 1}},
+	}
+	assert.NoError(t, services.ValidateExperiment(valid))
+
+	cases := map[string]func(r *models.UpsertExperimentRequest){
+		"bad key":         func(r *models.UpsertExperimentRequest) { r.Key = "XP Curve" },
+		"unknown status":  func(r *models.UpsertExperimentRequest) { r.Status = "running" },
+		"single variant":  func(r *models.UpsertExperimentRequest) { r.Variants = r.Variants[:1] },
+		"duplicate names": func(r *models.UpsertExperimentRequest) { r.Variants[1].Name = "control" },
+		"zero weight":     func(r *models.UpsertExperimentRequest) { r.Variants[1].Weight = 0 },
+	}
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := valid
+			req.Variants = append([]models.ExperimentVariant(nil), valid.Variants...)
+			mutate(&req)
+			assert.True(t, errors.Is(services.ValidateExperiment(req), services.ErrInvalidExperiment))
+		})
+	}
+}
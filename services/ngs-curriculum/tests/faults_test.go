@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/clients/intelligence"
+	"noble-ngs-curriculum/internal/faults"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseFaults tests reading FAULT_INJECTION rules
+func TestParseFaults(t *testing.T) {
+	rules, err := faults.Parse("intelligence:timeout,match=/educator/chat; db:error,match=ChallengeService.SubmitChallenge,rate=0.5;db:delay,delay=250ms;")
+	require.NoError(t, err)
+	assert.Equal(t, []faults.Rule{
+		{Target: "intelligence", Fault: "timeout", Match: "/educator/chat", Delay: 30 * time.Second, Rate: 1},
+		{Target: "db", Fault: "error", Match: "ChallengeService.SubmitChallenge", Rate: 0.5},
+		{Target: "db", Fault: "delay", Delay: 250 * time.Millisecond, Rate: 1},
+	}, rules)
+	assert.Equal(t, "db:error,match=ChallengeService.SubmitChallenge,rate=0.5", rules[1].String())
+
+	rules, err = faults.Parse("")
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+
+	for _, spec := range []string{
+		"intelligence",
+		"redis:error",
+		"db:explode",
+		"db:delay",
+		"db:delay,delay=-1s",
+		"db:error,rate=0",
+		"db:error,rate=1.5",
+		"db:error,retries=3",
+		"db:error,match",
+	} {
+		_, err := faults.Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+// TestFaultsAllowed tests that fault injection stays out of production
+func TestFaultsAllowed(t *testing.T) {
+	assert.True(t, faults.Allowed("development"))
+	assert.True(t, faults.Allowed("Staging"))
+	assert.False(t, faults.Allowed("production"))
+	assert.False(t, faults.Allowed(""))
+}
+
+// TestInjectFaults tests each fault against matching and non-matching calls
+func TestInjectFaults(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Nil injector", func(t *testing.T) {
+		var injector *faults.Injector
+		assert.NoError(t, injector.Inject(ctx, faults.TargetDB, "LessonService.GetLesson"))
+	})
+
+	t.Run("Error on matching calls only", func(t *testing.T) {
+		injector := faults.New([]faults.Rule{{Target: faults.TargetDB, Fault: faults.FaultError, Match: "FROM user_progress", Rate: 1}})
+		err := injector.Inject(ctx, faults.TargetDB, "ProgressService.GetProgress", "SELECT total_xp FROM user_progress WHERE user_id = $1")
+		assert.ErrorIs(t, err, faults.ErrInjected)
+		assert.NoError(t, injector.Inject(ctx, faults.TargetDB, "LessonService.GetLesson", "SELECT * FROM lessons"))
+		assert.NoError(t, injector.Inject(ctx, faults.TargetIntelligence, "FROM user_progress"), "rules apply to their own target")
+	})
+
+	t.Run("Timeout ends with the caller's deadline", func(t *testing.T) {
+		injector := faults.New([]faults.Rule{{Target: faults.TargetIntelligence, Fault: faults.FaultTimeout, Delay: time.Minute, Rate: 1}})
+		deadline, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		err := injector.Inject(deadline, faults.TargetIntelligence, "/educator/generate")
+		assert.Less(t, time.Since(start), time.Second)
+		assert.ErrorIs(t, err, faults.ErrInjected)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		var netErr net.Error
+		require.ErrorAs(t, err, &netErr)
+		assert.True(t, netErr.Timeout())
+	})
+
+	t.Run("Delay lets the call through", func(t *testing.T) {
+		injector := faults.New([]faults.Rule{{Target: faults.TargetDB, Fault: faults.FaultDelay, Delay: 20 * time.Millisecond, Rate: 1}})
+		start := time.Now()
+		assert.NoError(t, injector.Inject(ctx, faults.TargetDB, "LessonService.GetLesson"))
+		assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	})
+
+	t.Run("Rate", func(t *testing.T) {
+		injector := faults.New([]faults.Rule{{Target: faults.TargetDB, Fault: faults.FaultError, Rate: 0.5}})
+		failed := 0
+		for i := 0; i < 1000; i++ {
+			if injector.Inject(ctx, faults.TargetDB, "LessonService.GetLesson") != nil {
+				failed++
+			}
+		}
+		assert.InDelta(t, 500, failed, 150)
+	})
+}
+
+// TestIntelligenceFaults tests faults injected into Intelligence service requests
+func TestIntelligenceFaults(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"response": "Hello", "tokens_used": 3}`))
+	}))
+	defer server.Close()
+
+	rules, err := faults.Parse("intelligence:error,match=/educator/chat")
+	require.NoError(t, err)
+	client := intelligence.NewClient(server.URL, func() string { return "token" })
+	client.SetFaultInjector(faults.New(rules))
+
+	_, err = client.SendEducatorChatMessage(context.Background(), intelligence.EducatorChatRequest{Message: "Hi"}, "user", "", "student")
+	assert.True(t, errors.Is(err, faults.ErrInjected), "chat fails: %v", err)
+	assert.Zero(t, requests, "failed requests never reach the service")
+
+	assert.NoError(t, client.Ping(context.Background()), "other paths go through")
+	assert.Equal(t, 1, requests)
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGatewaySignature tests verifying the gateway's signature over identity headers
+func TestGatewaySignature(t *testing.T) {
+	cfg := testsupport.Config()
+	cfg.GatewaySigningSecret = "new-secret, old-secret"
+	cfg.GatewaySignatureMaxSkewSec = 300
+	clock := testsupport.NewFakeClock(time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC))
+	verifier := services.NewGatewayVerifier(cfg, clock)
+	require.True(t, verifier.Enabled())
+
+	userID := "9f0c1f9e-2f55-4a8e-9d0a-1f7c7c1f0a11"
+	identity := []string{userID, "student", "ada@example.com", "", "acme"}
+	now := strconv.FormatInt(clock.Now().Unix(), 10)
+
+	t.Run("Signatures by any configured secret verify", func(t *testing.T) {
+		for _, secret := range []string{"new-secret", "old-secret"} {
+			signature := services.SignGatewayRequest([]byte(secret), now, "GET", "/ngs/progress?x=1", identity)
+			assert.NoError(t, verifier.Verify(now, signature, "GET", "/ngs/progress?x=1", identity), secret)
+		}
+	})
+
+	t.Run("A request signed by the gateway verifies", func(t *testing.T) {
+		// The gateway's src/__tests__/gateway-signature.test.ts signs the same request
+		gateway := services.NewGatewayVerifier(&config.Config{GatewaySigningSecret: "gateway-test-secret", GatewaySignatureMaxSkewSec: 300},
+			testsupport.NewFakeClock(time.Unix(1767225600, 0)))
+		signature := "596b95621ff72878325352e5375169eeb5cc1a4e38129b1c01babd0c9ab22571"
+		assert.Equal(t, signature, services.SignGatewayRequest([]byte("gateway-test-secret"), "1767225600", "GET", "/ngs/progress?limit=5", identity))
+		assert.NoError(t, gateway.Verify("1767225600", signature, "GET", "/ngs/progress?limit=5", identity))
+	})
+
+	t.Run("Changed headers, paths or secrets fail", func(t *testing.T) {
+		signature := services.SignGatewayRequest([]byte("new-secret"), now, "GET", "/ngs/progress", identity)
+		admin := []string{userID, "admin", "ada@example.com", "", "acme"}
+		assert.True(t, errors.Is(verifier.Verify(now, signature, "GET", "/ngs/progress", admin), services.ErrGatewaySignatureInvalid))
+		assert.True(t, errors.Is(verifier.Verify(now, signature, "POST", "/ngs/progress", identity), services.ErrGatewaySignatureInvalid))
+		assert.True(t, errors.Is(verifier.Verify(now, signature, "GET", "/ngs/admin/load", identity), services.ErrGatewaySignatureInvalid))
+
+		forged := services.SignGatewayRequest([]byte("guess"), now, "GET", "/ngs/progress", identity)
+		assert.True(t, errors.Is(verifier.Verify(now, forged, "GET", "/ngs/progress", identity), services.ErrGatewaySignatureInvalid))
+	})
+
+	t.Run("Old, future and missing timestamps fail", func(t *testing.T) {
+		for _, at := range []time.Time{clock.Now().Add(-301 * time.Second), clock.Now().Add(301 * time.Second)} {
+			ts := strconv.FormatInt(at.Unix(), 10)
+			signature := services.SignGatewayRequest([]byte("new-secret"), ts, "GET", "/ngs/progress", identity)
+			assert.True(t, errors.Is(verifier.Verify(ts, signature, "GET", "/ngs/progress", identity), services.ErrGatewaySignatureExpired))
+		}
+		assert.True(t, errors.Is(verifier.Verify("", "", "GET", "/ngs/progress", identity), services.ErrGatewaySignatureMissing))
+	})
+
+	t.Run("The middleware only checks requests carrying identity headers", func(t *testing.T) {
+		app := fiber.New()
+		app.Use(handlers.GatewaySignature(verifier))
+		app.Get("/ngs/progress", func(c *fiber.Ctx) error { return c.SendString("ok") })
+		app.Get("/health", func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+		status := func(path string, headers map[string]string) int {
+			req := httptest.NewRequest("GET", path, nil)
+			for k, v := range headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			return resp.StatusCode
+		}
+
+		assert.Equal(t, fiber.StatusOK, status("/health", nil))
+		assert.Equal(t, fiber.StatusUnauthorized, status("/ngs/progress", map[string]string{"X-User-Id": userID, "X-User-Role": "admin"}))
+		assert.Equal(t, fiber.StatusOK, status("/ngs/progress", map[string]string{
+			"X-User-Id":           userID,
+			"X-User-Role":         "student",
+			"X-User-Email":        "ada@example.com",
+			"X-Org-Id":            "acme",
+			"X-Gateway-Timestamp": now,
+			"X-Gateway-Signature": services.SignGatewayRequest([]byte("old-secret"), now, "GET", "/ngs/progress", identity),
+		}))
+	})
+
+	t.Run("Disabled without a secret", func(t *testing.T) {
+		assert.False(t, services.NewGatewayVerifier(testsupport.Config(), clock).Enabled())
+	})
+}
@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"noble-ngs-curriculum/internal/clients/intelligence"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateGeneratedLesson tests checking generation responses against the lesson schema
+func TestValidateGeneratedLesson(t *testing.T) {
+	constraints := intelligence.GenerationConstraints{IncludeAccessibility: true}
+	require.NoError(t, services.ValidateGeneratedLesson(testsupport.GeneratedLesson("# Noticing"), constraints))
+
+	problemsOf := func(resp *intelligence.GenerateLessonResponse, constraints intelligence.GenerationConstraints) []string {
+		err := services.ValidateGeneratedLesson(resp, constraints)
+		require.ErrorIs(t, err, services.ErrInvalidGeneratedLesson)
+		var lessonErr *services.GeneratedLessonError
+		require.ErrorAs(t, err, &lessonErr)
+		return lessonErr.Problems
+	}
+
+	t.Run("Missing sections", func(t *testing.T) {
+		problems := problemsOf(&intelligence.GenerateLessonResponse{ContentMarkdown: "# Noticing"}, constraints)
+		for _, path := range []string{"metadata.title", "metadata.outcomes", "metadata.difficulty", "teach.overview", "teach.concepts", "guided_practice", "assessment.checks", "summary", "accessibility"} {
+			assert.True(t, hasProblem(problems, path), "%s in %v", path, problems)
+		}
+		assert.False(t, hasProblem(problemsOf(&intelligence.GenerateLessonResponse{}, intelligence.GenerationConstraints{}), "accessibility"),
+			"accessibility is only required when it was asked for")
+	})
+
+	t.Run("Bounds and enums", func(t *testing.T) {
+		resp := testsupport.GeneratedLesson("# Noticing")
+		resp.StructuredLesson.Metadata.Difficulty = "legendary"
+		resp.StructuredLesson.Metadata.EstimatedMinutes = 900
+		resp.StructuredLesson.Metadata.Title = strings.Repeat("a", 256)
+		resp.StructuredLesson.Teach.Steps = make([]string, 31)
+		for i := range resp.StructuredLesson.Teach.Steps {
+			resp.StructuredLesson.Teach.Steps[i] = "Step"
+		}
+		problems := problemsOf(resp, constraints)
+		assert.Contains(t, problems, `metadata.difficulty: must be one of beginner, intermediate, advanced, expert, not "legendary"`)
+		assert.Contains(t, problems, "metadata.estimated_minutes: must be between 1 and 240")
+		assert.Contains(t, problems, "metadata.title: must be at most 255 characters")
+		assert.Contains(t, problems, "teach.steps: must have between 1 and 30 items, not 31")
+		assert.Len(t, problems, 4)
+	})
+
+	t.Run("Assessment answers", func(t *testing.T) {
+		for _, tc := range []struct {
+			check   intelligence.AssessmentCheck
+			problem string
+		}{
+			{intelligence.AssessmentCheck{Type: "essay", Answer: "x"}, `type must be one of mcq, short_answer or true_false, not "essay"`},
+			{intelligence.AssessmentCheck{Type: "mcq", Choices: []string{"a"}, Answer: float64(0)}, "an mcq needs between 2 and 10 choices"},
+			{intelligence.AssessmentCheck{Type: "mcq", Choices: []string{"a", "b"}, Answer: float64(2)}, "answer must be the index of one of the choices"},
+			{intelligence.AssessmentCheck{Type: "true_false", Answer: "maybe"}, "answer must be true or false"},
+			{intelligence.AssessmentCheck{Type: "short_answer"}, "answer is required"},
+		} {
+			resp := testsupport.GeneratedLesson("# Noticing")
+			tc.check.Question, tc.check.Explanation = "Why?", "Because."
+			resp.StructuredLesson.Assessment.Checks = []intelligence.AssessmentCheck{tc.check}
+			assert.Equal(t, []string{"assessment.checks[0]: " + tc.problem}, problemsOf(resp, constraints))
+		}
+
+		resp := testsupport.GeneratedLesson("# Noticing")
+		resp.StructuredLesson.Assessment.Checks = append(resp.StructuredLesson.Assessment.Checks,
+			intelligence.AssessmentCheck{Type: "true_false", Question: "Q", Explanation: "E", Answer: true},
+			intelligence.AssessmentCheck{Type: "mcq", Question: "Q", Explanation: "E", Choices: []string{"a", "b"}, Answer: "b"},
+		)
+		assert.NoError(t, services.ValidateGeneratedLesson(resp, constraints))
+	})
+}
+
+func hasProblem(problems []string, path string) bool {
+	for _, problem := range problems {
+		if strings.HasPrefix(problem, path+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// TestGenerateLessonQuarantined tests that a generated lesson not matching the schema is
+// rejected with its problems instead of being published
+func TestGenerateLessonQuarantined(t *testing.T) {
+	lessonID := uuid.New()
+	lesson := testsupport.GeneratedLesson("# Noticing")
+	lesson.StructuredLesson.Metadata.Difficulty = "Beginner"
+	lesson.StructuredLesson.GuidedPractice = nil
+	mock := &testsupport.MockIntelligence{Lesson: lesson}
+	app := intelligenceApp(mock, lessonID)
+
+	status, body := postJSONAs(t, app, "educator", "/ngs/lessons/"+lessonID.String()+"/generate", `{}`)
+	require.Equal(t, fiber.StatusBadGateway, status, body)
+	assert.Equal(t, "invalid_generation", body["error"])
+	problems, err := json.Marshal(body["problems"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		"metadata.difficulty: must be one of beginner, intermediate, advanced, expert, not \"Beginner\"",
+		"guided_practice: must have between 1 and 30 items, not 0"
+	]`, string(problems))
+}
+
+// TestRemediationQuarantined tests that a remediation lesson not matching the schema fails
+// generation rather than being stored
+func TestRemediationQuarantined(t *testing.T) {
+	columns := []string{"user_id", "topic", "lesson_id", "title", "description", "level"}
+	payload, _ := json.Marshal(map[string]string{"remediation_id": uuid.NewString()})
+	db := testsupport.RowsDB(columns, []driver.Value{uuid.NewString(), "Recursion", nil, nil, nil, int64(4)})
+	lesson := testsupport.GeneratedLesson("# Recursion again")
+	lesson.StructuredLesson.Summary = ""
+
+	err := newRemediationService(db, &testsupport.MockIntelligence{Lesson: lesson}).RunRemediation(context.Background(), payload)
+	assert.ErrorIs(t, err, services.ErrInvalidGeneratedLesson)
+}
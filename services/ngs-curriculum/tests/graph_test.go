@@ -0,0 +1,190 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseLessonPrerequisites tests both stored prerequisite formats
+func TestParseLessonPrerequisites(t *testing.T) {
+	id := uuid.New()
+
+	t.Run("Object with min level and lessons", func(t *testing.T) {
+		prereqs, err := services.ParseLessonPrerequisites(json.RawMessage(`{"min_level": 3, "lessons": ["` + id.String() + `"]}`))
+		require.NoError(t, err)
+		assert.Equal(t, 3, prereqs.MinLevel)
+		assert.Equal(t, []uuid.UUID{id}, prereqs.Lessons)
+	})
+
+	t.Run("Bare array of lesson IDs", func(t *testing.T) {
+		prereqs, err := services.ParseLessonPrerequisites(json.RawMessage(`["` + id.String() + `"]`))
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{id}, prereqs.Lessons)
+	})
+
+	t.Run("Empty and null", func(t *testing.T) {
+		for _, raw := range []string{"", "null", " "} {
+			prereqs, err := services.ParseLessonPrerequisites(json.RawMessage(raw))
+			require.NoError(t, err)
+			assert.Zero(t, prereqs.MinLevel)
+			assert.Empty(t, prereqs.Lessons)
+		}
+	})
+
+	t.Run("Invalid lesson ID", func(t *testing.T) {
+		_, err := services.ParseLessonPrerequisites(json.RawMessage(`["not-a-uuid"]`))
+		assert.Error(t, err)
+	})
+}
+
+func graphLesson(level, order int, unlock string, prereqs string) models.Lesson {
+	lesson := testsupport.Lesson().Level(level, order).AgentUnlock(unlock).Build()
+	lesson.Prerequisites = json.RawMessage(prereqs)
+	return lesson
+}
+
+// TestBuildCurriculumGraph tests node and edge construction
+func TestBuildCurriculumGraph(t *testing.T) {
+	levels := []models.CurriculumLevel{
+		{ID: 2, LevelNumber: 2, Title: "Two", UnlockRequirements: json.RawMessage(`{"phase": "Initiation", "prev_level": 1}`)},
+		{ID: 1, LevelNumber: 1, Title: "One"},
+	}
+	first := graphLesson(1, 1, "Unlock checklist", `{"min_level": 1}`)
+	second := graphLesson(2, 1, "Unlock checklist", `{"min_level": 2, "lessons": ["`+first.ID.String()+`"]}`)
+	orphan := graphLesson(2, 2, "", `["`+uuid.New().String()+`"]`)
+
+	graph, violations := services.BuildCurriculumGraph(levels, []models.Lesson{second, orphan, first})
+
+	types := map[string]int{}
+	for _, node := range graph.Nodes {
+		types[node.Type]++
+	}
+	assert.Equal(t, map[string]int{"level": 2, "lesson": 3, "capability": 1}, types)
+	assert.Equal(t, "level:1", graph.Nodes[0].ID, "levels come first in level order")
+	assert.Equal(t, "Initiation", graph.Nodes[1].Data["phase"])
+
+	edges := map[string]string{}
+	for _, edge := range graph.Edges {
+		edges[edge.ID] = edge.Type
+	}
+	lessonNode := func(l models.Lesson) string { return "lesson:" + l.ID.String() }
+	assert.Equal(t, "prerequisite", edges["level:1->level:2"])
+	assert.Equal(t, "prerequisite", edges["level:1->"+lessonNode(first)])
+	assert.Equal(t, "prerequisite", edges[lessonNode(first)+"->"+lessonNode(second)])
+	assert.Equal(t, "unlock", edges[lessonNode(first)+"->capability:unlock-checklist"])
+	assert.Equal(t, "unlock", edges[lessonNode(second)+"->capability:unlock-checklist"])
+
+	require.Len(t, violations, 1, "unknown prerequisite lessons are reported")
+	assert.Equal(t, services.ViolationMissingLesson, violations[0].Code)
+	assert.Equal(t, orphan.ID, *violations[0].LessonID)
+	assert.Nil(t, services.FindPrerequisiteCycle(graph))
+}
+
+// TestFindPrerequisiteCycle tests cycle detection over prerequisite edges
+func TestFindPrerequisiteCycle(t *testing.T) {
+	levels := []models.CurriculumLevel{{ID: 1, LevelNumber: 1, Title: "One"}}
+
+	t.Run("Two lessons requiring each other", func(t *testing.T) {
+		a := graphLesson(1, 1, "", `{}`)
+		b := graphLesson(1, 2, "", `["`+a.ID.String()+`"]`)
+		a.Prerequisites = json.RawMessage(`["` + b.ID.String() + `"]`)
+
+		graph, _ := services.BuildCurriculumGraph(levels, []models.Lesson{a, b})
+		cycle := services.FindPrerequisiteCycle(graph)
+		require.Len(t, cycle, 3)
+		assert.Equal(t, cycle[0], cycle[2])
+		assert.ElementsMatch(t, []string{"lesson:" + a.ID.String(), "lesson:" + b.ID.String()}, cycle[:2])
+	})
+
+	t.Run("Lesson requiring itself", func(t *testing.T) {
+		a := graphLesson(1, 1, "", `{}`)
+		a.Prerequisites = json.RawMessage(`["` + a.ID.String() + `"]`)
+
+		graph, _ := services.BuildCurriculumGraph(levels, []models.Lesson{a})
+		assert.Equal(t, []string{"lesson:" + a.ID.String(), "lesson:" + a.ID.String()}, services.FindPrerequisiteCycle(graph))
+	})
+
+	t.Run("Unlock edges are not prerequisites", func(t *testing.T) {
+		graph := &models.CurriculumGraph{
+			Nodes: []models.GraphNode{{ID: "a"}, {ID: "b"}},
+			Edges: []models.GraphEdge{
+				{Source: "a", Target: "b", Type: "prerequisite"},
+				{Source: "b", Target: "a", Type: "unlock"},
+			},
+		}
+		assert.Nil(t, services.FindPrerequisiteCycle(graph))
+	})
+}
+
+// TestBuildCurriculumGraphLevelOrder tests prerequisites that point above a lesson's level
+func TestBuildCurriculumGraphLevelOrder(t *testing.T) {
+	levels := []models.CurriculumLevel{{ID: 1, LevelNumber: 1, Title: "One"}, {ID: 2, LevelNumber: 2, Title: "Two"}}
+	advanced := graphLesson(2, 1, "", `{"min_level": 2}`)
+	early := graphLesson(1, 1, "", `{"min_level": 2, "lessons": ["`+advanced.ID.String()+`"]}`)
+	unknownLevel := graphLesson(1, 2, "", `{"min_level": 30}`)
+	malformed := graphLesson(1, 3, "", `{"lessons": "nope"}`)
+
+	_, violations := services.BuildCurriculumGraph(levels, []models.Lesson{advanced, early, unknownLevel, malformed})
+
+	codes := map[uuid.UUID][]string{}
+	for _, v := range violations {
+		require.NotNil(t, v.LessonID)
+		assert.NotEmpty(t, v.Message)
+		codes[*v.LessonID] = append(codes[*v.LessonID], v.Code)
+	}
+	assert.Equal(t, []string{services.ViolationLevelOrder, services.ViolationLevelOrder}, codes[early.ID], "min_level and lesson both above level 1")
+	assert.Equal(t, []string{services.ViolationUnknownLevel}, codes[unknownLevel.ID])
+	assert.Equal(t, []string{services.ViolationInvalidFormat}, codes[malformed.ID])
+	assert.Empty(t, codes[advanced.ID])
+}
+
+// TestPrerequisiteError tests the aggregated prerequisite error
+func TestPrerequisiteError(t *testing.T) {
+	err := error(&services.PrerequisiteError{Violations: []models.PrerequisiteViolation{
+		{Code: services.ViolationMissingLesson, Message: "first"},
+		{Code: services.ViolationCycle, Message: "second"},
+	}})
+	assert.True(t, errors.Is(err, services.ErrInvalidPrerequisites))
+	assert.Contains(t, err.Error(), "first; second")
+}
+
+// TestApplyCurriculumMapStatus tests lock, XP to unlock and completion on the level map
+func TestApplyCurriculumMapStatus(t *testing.T) {
+	level := func(number, xpRequired, required, completedRequired int) models.CurriculumMapLevel {
+		return models.CurriculumMapLevel{
+			CurriculumLevel:        models.CurriculumLevel{LevelNumber: number, XPRequired: xpRequired},
+			RequiredLessonCount:    required,
+			CompletedRequiredCount: completedRequired,
+		}
+	}
+	levels := []models.CurriculumMapLevel{
+		level(1, 0, 3, 3),
+		level(2, 100, 2, 1),
+		level(3, 250, 2, 0),
+		level(4, 100, 0, 0),
+	}
+
+	services.ApplyCurriculumMapStatus(levels, 2, 180)
+
+	assert.False(t, levels[0].Locked)
+	assert.True(t, levels[0].Completed)
+	assert.False(t, levels[1].Locked)
+	assert.False(t, levels[1].Completed)
+	assert.Zero(t, levels[1].XPToUnlock)
+
+	assert.True(t, levels[2].Locked)
+	assert.Equal(t, 70, levels[2].XPToUnlock)
+
+	// Locked by level even when the XP is there; no required lessons never counts as completed
+	assert.True(t, levels[3].Locked)
+	assert.Zero(t, levels[3].XPToUnlock)
+	assert.False(t, levels[3].Completed)
+}
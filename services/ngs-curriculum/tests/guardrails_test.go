@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"noble-ngs-curriculum/internal/clients/intelligence"
+	"noble-ngs-curriculum/internal/domain/guardrails"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseGuardrailSections tests reading GUARDRAIL_ETHICS_SECTIONS
+func TestParseGuardrailSections(t *testing.T) {
+	sections, err := guardrails.ParseSections(" ethics = Ethic, Responsible Use ; safety=risk")
+	require.NoError(t, err)
+	assert.Equal(t, []guardrails.Section{
+		{Name: "ethics", Keywords: []string{"ethic", "responsible use"}},
+		{Name: "safety", Keywords: []string{"risk"}},
+	}, sections)
+
+	sections, err = guardrails.ParseSections("none")
+	require.NoError(t, err)
+	assert.Empty(t, sections)
+
+	for _, spec := range []string{"ethics", "=ethic", "ethics=ethic,,moral", "ethics=ethic;ethics=moral"} {
+		_, err := guardrails.ParseSections(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+// TestGuardrailChecklist tests each check of the checklist
+func TestGuardrailChecklist(t *testing.T) {
+	policy := guardrails.Policy{
+		ReadingLevelMin: 4,
+		ReadingLevelMax: 12,
+		EthicsSections:  []guardrails.Section{{Name: "ethics", Keywords: []string{"ethic", "responsible"}}},
+	}
+	markdown := "# Prompting\n\n## Key Concepts\n\n### Ethics & Responsible Use\n\nWrite to ada@example.com for help."
+	clean := guardrails.Lesson{Markdown: markdown, ReadingLevel: 8, RequireEthics: true}
+
+	report := policy.Check(clean)
+	assert.True(t, report.Passed, report.String())
+	assert.Len(t, report.Results, 3)
+
+	t.Run("Personal data", func(t *testing.T) {
+		for _, text := range []string{
+			"Contact jane.doe@gmail.com",
+			"Call (415) 555-0132 today",
+			"Her SSN is 123-45-6789",
+			"Card 4111 1111 1111 1111",
+		} {
+			lesson := clean
+			lesson.Text = []string{text}
+			report := policy.Check(lesson)
+			assert.False(t, report.Passed, text)
+			assert.Equal(t, []string{guardrails.CheckPII}, report.Failed(), text)
+			assert.NotContains(t, report.String(), strings.Fields(text)[1], "the report does not repeat what it found")
+		}
+
+		lesson := clean
+		lesson.Text = []string{"Order 1234 5678 9012 3456 is not a card", "user@mail.test"}
+		assert.True(t, policy.Check(lesson).Passed)
+	})
+
+	t.Run("Reading level", func(t *testing.T) {
+		for _, level := range []float64{2.5, 14} {
+			lesson := clean
+			lesson.ReadingLevel = level
+			assert.Equal(t, []string{guardrails.CheckReadingLevel}, policy.Check(lesson).Failed(), level)
+		}
+		unbounded := policy
+		unbounded.ReadingLevelMax = 0
+		lesson := clean
+		lesson.ReadingLevel = 18
+		assert.True(t, unbounded.Check(lesson).Passed, "0 is no maximum")
+	})
+
+	t.Run("Ethics sections", func(t *testing.T) {
+		lesson := clean
+		lesson.Markdown = "# Prompting\n\nEthics matter, but there is no heading for them."
+		report := policy.Check(lesson)
+		assert.Equal(t, []string{guardrails.CheckEthics}, report.Failed())
+		assert.Contains(t, report.String(), "no ethics section")
+
+		lesson.RequireEthics = false
+		report = policy.Check(lesson)
+		assert.True(t, report.Passed)
+		assert.Len(t, report.Results, 2, "sections are only checked when guardrails were required")
+	})
+}
+
+// TestGenerateLessonBlockedByGuardrails tests that a generated lesson failing its checks is
+// not published and the report is returned
+func TestGenerateLessonBlockedByGuardrails(t *testing.T) {
+	services.ConfigureGuardrails(guardrails.Policy{
+		EthicsSections: []guardrails.Section{{Name: "ethics", Keywords: []string{"ethic"}}},
+	})
+	t.Cleanup(func() { services.ConfigureGuardrails(guardrails.Policy{}) })
+
+	lessonID := uuid.New()
+	lesson := testsupport.GeneratedLesson("# Noticing\n\nWatch your thoughts.")
+	lesson.StructuredLesson.GuidedPractice = []intelligence.GuidedPracticeTask{{
+		Task: "Email your notes to sam.lee@outlook.com", Hint: "Keep it short", Solution: "A short email",
+	}}
+	mock := &testsupport.MockIntelligence{Lesson: lesson}
+	app := intelligenceApp(mock, lessonID)
+
+	status, body := postJSONAs(t, app, "educator", "/ngs/lessons/"+lessonID.String()+"/generate", `{}`)
+	require.Equal(t, fiber.StatusUnprocessableEntity, status, body)
+	assert.Equal(t, "guardrails_failed", body["error"])
+	report := body["guardrails"].(map[string]interface{})
+	assert.Equal(t, false, report["passed"])
+	var failed []string
+	for _, result := range report["results"].([]interface{}) {
+		if r := result.(map[string]interface{}); r["passed"] == false {
+			failed = append(failed, r["check"].(string))
+		}
+	}
+	assert.ElementsMatch(t, []string{guardrails.CheckPII, guardrails.CheckEthics}, failed)
+
+	mock.Lesson = testsupport.GeneratedLesson("# Noticing\n\n## Ethics\n\nWatch your thoughts kindly.")
+	status, body = postJSONAs(t, app, "educator", "/ngs/lessons/"+lessonID.String()+"/generate", `{}`)
+	require.Equal(t, fiber.StatusOK, status, body)
+	assert.Equal(t, true, body["guardrails"].(map[string]interface{})["passed"])
+}
@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryIdempotencyStore keeps Idempotency-Key records in memory, as idempotency_keys does
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*memoryIdempotencyRecord
+}
+
+type memoryIdempotencyRecord struct {
+	request  string
+	response *services.IdempotentResponse
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: make(map[string]*memoryIdempotencyRecord)}
+}
+
+func (s *memoryIdempotencyStore) Begin(userID uuid.UUID, key, method, path string, body []byte) (*services.IdempotentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	request := method + " " + path + " " + services.RequestHash(body)
+	record, ok := s.records[userID.String()+key]
+	switch {
+	case !ok:
+		s.records[userID.String()+key] = &memoryIdempotencyRecord{request: request}
+		return nil, nil
+	case record.request != request:
+		return nil, services.ErrIdempotencyKeyReused
+	case record.response == nil:
+		return nil, services.ErrIdempotencyKeyInProgress
+	}
+	return record.response, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(userID uuid.UUID, key string, response services.IdempotentResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[userID.String()+key].response = &response
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(userID uuid.UUID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record := s.records[userID.String()+key]; record != nil && record.response == nil {
+		delete(s.records, userID.String()+key)
+	}
+	return nil
+}
+
+// TestIdempotentRoute tests that retried requests get the first response without running again
+func TestIdempotentRoute(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	awards := 0
+	fail := false
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Post("/ngs/award-xp", handlers.Idempotent(store), func(c *fiber.Ctx) error {
+		if fail {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to award XP"})
+		}
+		awards++
+		return c.JSON(fiber.Map{"awards": awards})
+	})
+
+	userID := uuid.NewString()
+	send := func(key, body string) (int, string, string) {
+		req := httptest.NewRequest("POST", "/ngs/award-xp", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User-Id", userID)
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data), resp.Header.Get("Idempotent-Replayed")
+	}
+
+	status, body, replayed := send("award-1", `{"source": "lesson_completion"}`)
+	require.Equal(t, fiber.StatusOK, status)
+	assert.JSONEq(t, `{"awards": 1}`, body)
+	assert.Empty(t, replayed)
+
+	status, body, replayed = send("award-1", `{"source": "lesson_completion"}`)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.JSONEq(t, `{"awards": 1}`, body, "the retry gets the original response")
+	assert.Equal(t, "true", replayed)
+	assert.Equal(t, 1, awards, "XP is awarded once")
+
+	status, _, _ = send("award-1", `{"source": "reflection_quality"}`)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status, "a key is for one request")
+
+	status, body, _ = send("", `{"source": "lesson_completion"}`)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.JSONEq(t, `{"awards": 2}`, body, "requests without a key run every time")
+
+	fail = true
+	status, _, _ = send("award-2", `{}`)
+	assert.Equal(t, fiber.StatusInternalServerError, status)
+	fail = false
+	status, body, replayed = send("award-2", `{}`)
+	assert.Equal(t, fiber.StatusOK, status, "a failed request can be retried with its key")
+	assert.JSONEq(t, `{"awards": 3}`, body)
+	assert.Empty(t, replayed)
+
+	_, err := store.Begin(uuid.MustParse(userID), "award-3", "POST", "/ngs/award-xp", []byte(`{}`))
+	require.NoError(t, err)
+	status, _, _ = send("award-3", `{}`)
+	assert.Equal(t, fiber.StatusConflict, status, "a repeat of a running request waits")
+
+	status, _, _ = send(strings.Repeat("k", 256), `{}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+}
@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var impersonationColumns = []string{"id", "admin_id", "user_id", "reason", "created_at", "expires_at", "ended_at", "ended_by"}
+
+// impersonationRow is a session started by adminID an hour before now, lasting ttl
+func impersonationRow(adminID uuid.UUID, now time.Time, ttl time.Duration) []driver.Value {
+	started := now.Add(-time.Hour)
+	return []driver.Value{uuid.NewString(), adminID.String(), uuid.NewString(), "Debugging streak", started, started.Add(ttl), nil, nil}
+}
+
+// TestImpersonationTTL tests the default and longest session lifetimes
+func TestImpersonationTTL(t *testing.T) {
+	cfg := progressConfig()
+	cfg.ImpersonationTTLMinutes, cfg.ImpersonationMaxTTLMinutes = 30, 240
+	impersonationService := services.NewImpersonationService(nil, cfg, services.SystemClock{})
+
+	ttl, err := impersonationService.ImpersonationTTL(0)
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Minute, ttl)
+
+	ttl, err = impersonationService.ImpersonationTTL(240)
+	require.NoError(t, err)
+	assert.Equal(t, 4*time.Hour, ttl)
+
+	_, err = impersonationService.ImpersonationTTL(241)
+	assert.ErrorIs(t, err, services.ErrInvalidImpersonation)
+	_, err = impersonationService.ImpersonationTTL(-5)
+	assert.ErrorIs(t, err, services.ErrInvalidImpersonation)
+}
+
+// TestStartImpersonationValidation tests that sessions need a learner and a reason
+func TestStartImpersonationValidation(t *testing.T) {
+	cfg := progressConfig()
+	cfg.ImpersonationTTLMinutes, cfg.ImpersonationMaxTTLMinutes = 30, 240
+	impersonationService := services.NewImpersonationService(nil, cfg, services.SystemClock{})
+	adminID := uuid.New()
+
+	invalid := map[string]models.StartImpersonationRequest{
+		"no user":   {Reason: "Debugging streak"},
+		"self":      {UserID: adminID, Reason: "Debugging streak"},
+		"no reason": {UserID: uuid.New(), Reason: "  "},
+		"long TTL":  {UserID: uuid.New(), Reason: "Debugging streak", TTLMinutes: 600},
+	}
+	for name, req := range invalid {
+		_, err := impersonationService.StartImpersonation(adminID, req)
+		assert.ErrorIs(t, err, services.ErrInvalidImpersonation, name)
+	}
+}
+
+// TestImpersonationMiddleware tests who may use an impersonation token
+func TestImpersonationMiddleware(t *testing.T) {
+	adminID := uuid.New()
+	now := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)
+	clock := testsupport.NewFakeClock(now)
+
+	request := func(row []driver.Value, headers map[string]string) *http.Response {
+		db := testsupport.RowsDB(impersonationColumns, row)
+		app := fiber.New()
+		app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+		app.Use(handlers.NewImpersonationHandler(services.NewImpersonationService(db, progressConfig(), clock)).Middleware)
+		app.Get("/ngs/progress", func(c *fiber.Ctx) error {
+			return c.SendString(middleware.GetIdentity(c).UserID)
+		})
+
+		req := httptest.NewRequest("GET", "/ngs/progress", nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+	admin := func(id uuid.UUID) map[string]string {
+		return map[string]string{"X-User-Id": id.String(), "X-User-Role": "admin", "X-Impersonation-Token": "token"}
+	}
+
+	resp := request(impersonationRow(adminID, now, 2*time.Hour), map[string]string{"X-User-Id": adminID.String(), "X-User-Role": "admin"})
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode, "requests without a token pass through")
+	assert.Empty(t, resp.Header.Get("X-Impersonating"))
+
+	learner := admin(adminID)
+	learner["X-User-Role"] = "student"
+	assert.Equal(t, fiber.StatusForbidden, request(impersonationRow(adminID, now, 2*time.Hour), learner).StatusCode)
+
+	assert.Equal(t, fiber.StatusUnauthorized, request(impersonationRow(uuid.New(), now, 2*time.Hour), admin(adminID)).StatusCode,
+		"only the admin who started the session may use it")
+	assert.Equal(t, fiber.StatusUnauthorized, request(impersonationRow(adminID, now, 30*time.Minute), admin(adminID)).StatusCode,
+		"expired sessions are refused")
+
+	ended := impersonationRow(adminID, now, 2*time.Hour)
+	ended[6] = now.Add(-time.Minute)
+	assert.Equal(t, fiber.StatusUnauthorized, request(ended, admin(adminID)).StatusCode, "ended sessions are refused")
+}
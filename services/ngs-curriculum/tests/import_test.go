@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"errors"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseImportCSV tests CSV parsing for the legacy LMS importer
+func TestParseImportCSV(t *testing.T) {
+	userID := uuid.New().String()
+
+	t.Run("Parses rows and reports bad numbers with line numbers", func(t *testing.T) {
+		csv := "User_ID,lesson_external_id,score,xp,completed_at\n" +
+			userID + ",LMS-1,90,,2023-05-01\n" +
+			userID + ",LMS-2,ninety,,\n" +
+			userID + ",LMS-3,,40,\n"
+
+		records, rows, parseErrors, err := services.ParseImportCSV(strings.NewReader(csv))
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, []int{2, 4}, rows)
+		assert.Equal(t, "LMS-1", records[0].LessonExternalID)
+		assert.Equal(t, 90, *records[0].Score)
+		assert.Nil(t, records[0].XP)
+		assert.Equal(t, 40, *records[1].XP)
+		require.Len(t, parseErrors, 1)
+		assert.Equal(t, 3, parseErrors[0].Row)
+	})
+
+	t.Run("Required columns", func(t *testing.T) {
+		_, _, _, err := services.ParseImportCSV(strings.NewReader("user_id,score\n"))
+		assert.True(t, errors.Is(err, services.ErrInvalidImport))
+	})
+}
+
+// TestValidateImportRecord tests record validation
+func TestValidateImportRecord(t *testing.T) {
+	n := func(v int) *int { return &v }
+	valid := models.ImportRecord{UserID: uuid.New().String(), LessonExternalID: "LMS-1"}
+	now := testsupport.Epoch
+
+	_, completedAt, err := services.ValidateImportRecord(valid, now)
+	assert.NoError(t, err)
+	assert.Nil(t, completedAt)
+
+	dated := valid
+	dated.CompletedAt = "2023-05-01T10:00:00Z"
+	_, completedAt, err = services.ValidateImportRecord(dated, now)
+	require.NoError(t, err)
+	assert.Equal(t, 2023, completedAt.Year())
+
+	invalid := []models.ImportRecord{
+		{UserID: "legacy-42", LessonExternalID: "LMS-1"},
+		{UserID: valid.UserID},
+		{UserID: valid.UserID, LessonExternalID: "LMS-1", Score: n(101)},
+		{UserID: valid.UserID, LessonExternalID: "LMS-1", XP: n(-5)},
+		{UserID: valid.UserID, LessonExternalID: "LMS-1", CompletedAt: "05/01/2023"},
+		{UserID: valid.UserID, LessonExternalID: "LMS-1", CompletedAt: "2025-01-02"},
+	}
+	for _, record := range invalid {
+		_, _, err := services.ValidateImportRecord(record, now)
+		assert.Error(t, err, "%+v", record)
+	}
+}
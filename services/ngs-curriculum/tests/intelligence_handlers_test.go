@@ -0,0 +1,238 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"noble-ngs-curriculum/internal/clients/intelligence"
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// intelligenceApp serves the lesson routes that call the Intelligence service, with lesson reads
+// answered by the given lesson row
+func intelligenceApp(mock *testsupport.MockIntelligence, lessonID uuid.UUID) *fiber.App {
+	lessonService := services.NewLessonService(testsupport.RowsDB(lessonWithCompletionColumns, nullLessonRow(lessonID)))
+	lessonHandler := handlers.NewLessonHandler(lessonService, mock)
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Post("/ngs/lessons/:id/generate", lessonHandler.GenerateLesson)
+	app.Post("/ngs/lessons/:id/chat/message", lessonHandler.SendEducatorChatMessage)
+	return app
+}
+
+func postJSON(t *testing.T, app *fiber.App, path, body string) (int, map[string]interface{}) {
+	t.Helper()
+	return postJSONAs(t, app, "", path, body)
+}
+
+// postJSONAs posts body as a new user with role, or with no role when it is empty
+func postJSONAs(t *testing.T, app *fiber.App, role, path, body string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", uuid.New().String())
+	if role != "" {
+		req.Header.Set("X-User-Role", role)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&decoded))
+	return resp.StatusCode, decoded
+}
+
+// TestGenerateLessonHandler tests lesson generation against a mocked Intelligence service
+func TestGenerateLessonHandler(t *testing.T) {
+	lessonID := uuid.New()
+	lesson := testsupport.GeneratedLesson("# Noticing\n\nWatch your thoughts.")
+	lesson.Version = 2
+	mock := &testsupport.MockIntelligence{Lesson: lesson}
+	app := intelligenceApp(mock, lessonID)
+
+	req := httptest.NewRequest("POST", "/ngs/lessons/"+lessonID.String()+"/generate", nil)
+	req.Header.Set("X-User-Id", uuid.NewString())
+	req.Header.Set("X-User-Role", "student")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode, "learners cannot rewrite a shared lesson")
+	assert.Empty(t, mock.GenerateRequests)
+
+	status, body := postJSONAs(t, app, "educator", "/ngs/lessons/"+lessonID.String()+"/generate", `{}`)
+	require.Equal(t, fiber.StatusOK, status, body)
+	assert.Equal(t, "# Noticing\n\nWatch your thoughts.", body["content_markdown"])
+	assert.EqualValues(t, 2, body["version"])
+
+	require.Len(t, mock.GenerateRequests, 1)
+	assert.Equal(t, 1, mock.GenerateRequests[0].LevelNumber)
+	assert.True(t, mock.GenerateRequests[0].Constraints.IncludeAccessibility)
+
+	mock.Err = errors.New("intelligence unavailable")
+	status, _ = postJSONAs(t, app, "admin", "/ngs/lessons/"+lessonID.String()+"/generate", `{}`)
+	assert.Equal(t, fiber.StatusInternalServerError, status)
+}
+
+// TestEducatorChatHandler tests tutor chat against a mocked Intelligence service
+func TestEducatorChatHandler(t *testing.T) {
+	lessonID := uuid.New()
+	mock := &testsupport.MockIntelligence{}
+	app := intelligenceApp(mock, lessonID)
+	path := "/ngs/lessons/" + lessonID.String() + "/chat/message"
+
+	status, body := postJSON(t, app, path, `{"message": "What is a signal?"}`)
+	require.Equal(t, fiber.StatusOK, status, body)
+	assert.Equal(t, lessonID.String(), body["lesson_id"])
+	require.Len(t, mock.ChatRequests, 1)
+	assert.Equal(t, "What is a signal?", mock.ChatRequests[0].Message)
+	assert.Equal(t, lessonID, mock.ChatRequests[0].LessonID)
+
+	status, _ = postJSON(t, app, path, `{"message": ""}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	assert.Len(t, mock.ChatRequests, 1, "invalid messages should not reach the Intelligence service")
+}
+
+// fakeLearnerPreferences answers every user with prefs, or fails with err
+type fakeLearnerPreferences struct {
+	prefs map[string]interface{}
+	err   error
+}
+
+func (f fakeLearnerPreferences) LearnerPreferences(uuid.UUID) (map[string]interface{}, error) {
+	return f.prefs, f.err
+}
+
+// TestLearnerPreferencesSent tests that chat carries the learner's preferences and shared
+// generation does not
+func TestLearnerPreferencesSent(t *testing.T) {
+	lessonID := uuid.New()
+	prefs := map[string]interface{}{"examples": "theory", "code_style": "conceptual", "programming_language": "r"}
+	mock := &testsupport.MockIntelligence{}
+	lessonService := services.NewLessonService(testsupport.RowsDB(lessonWithCompletionColumns, nullLessonRow(lessonID)))
+	lessonHandler := handlers.NewLessonHandler(lessonService, mock)
+	lessonHandler.SetLearnerPreferences(fakeLearnerPreferences{prefs: prefs})
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Post("/ngs/lessons/:id/generate", lessonHandler.GenerateLesson)
+	app.Post("/ngs/lessons/:id/chat/message", lessonHandler.SendEducatorChatMessage)
+
+	status, body := postJSONAs(t, app, "educator", "/ngs/lessons/"+lessonID.String()+"/generate", `{}`)
+	require.Equal(t, fiber.StatusOK, status, body)
+	require.Len(t, mock.GenerateRequests, 1)
+	assert.Empty(t, mock.GenerateRequests[0].LearnerProfile.Preferences)
+
+	status, body = postJSON(t, app, "/ngs/lessons/"+lessonID.String()+"/chat/message", `{"message": "Show me"}`)
+	require.Equal(t, fiber.StatusOK, status, body)
+	require.Len(t, mock.ChatRequests, 1)
+	assert.Equal(t, prefs, mock.ChatRequests[0].Preferences)
+
+	lessonHandler.SetLearnerPreferences(fakeLearnerPreferences{err: errors.New("database down")})
+	status, _ = postJSON(t, app, "/ngs/lessons/"+lessonID.String()+"/chat/message", `{"message": "Show me"}`)
+	assert.Equal(t, fiber.StatusOK, status, "chat goes on without preferences")
+	require.Len(t, mock.ChatRequests, 2)
+	assert.Empty(t, mock.ChatRequests[1].Preferences)
+}
+
+// fakeWeakTopics answers every user with topics, or fails with err
+type fakeWeakTopics struct {
+	topics []string
+	err    error
+}
+
+func (f fakeWeakTopics) WeakTopicNames(uuid.UUID) ([]string, error) {
+	return f.topics, f.err
+}
+
+// TestWeakTopicsSent tests that chat carries the learner's weak topics and shared generation
+// does not
+func TestWeakTopicsSent(t *testing.T) {
+	lessonID := uuid.New()
+	mock := &testsupport.MockIntelligence{}
+	lessonService := services.NewLessonService(testsupport.RowsDB(lessonWithCompletionColumns, nullLessonRow(lessonID)))
+	lessonHandler := handlers.NewLessonHandler(lessonService, mock)
+	lessonHandler.SetWeakTopics(fakeWeakTopics{topics: []string{"Recursion", "loops"}})
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Post("/ngs/lessons/:id/generate", lessonHandler.GenerateLesson)
+	app.Post("/ngs/lessons/:id/chat/message", lessonHandler.SendEducatorChatMessage)
+	chatPath := "/ngs/lessons/" + lessonID.String() + "/chat/message"
+
+	status, body := postJSON(t, app, chatPath, `{"message": "Why recurse?"}`)
+	require.Equal(t, fiber.StatusOK, status, body)
+	require.Len(t, mock.ChatRequests, 1)
+	assert.Equal(t, []string{"Recursion", "loops"}, mock.ChatRequests[0].WeakTopics)
+
+	status, body = postJSONAs(t, app, "educator", "/ngs/lessons/"+lessonID.String()+"/generate", `{}`)
+	require.Equal(t, fiber.StatusOK, status, body)
+	require.Len(t, mock.GenerateRequests, 1)
+	assert.Empty(t, mock.GenerateRequests[0].LearnerProfile.WeakTopics)
+
+	lessonHandler.SetWeakTopics(fakeWeakTopics{err: errors.New("database down")})
+	status, body = postJSON(t, app, chatPath, `{"message": "Why recurse?"}`)
+	require.Equal(t, fiber.StatusOK, status, "chat goes on without weak topics: %s", body)
+	require.Len(t, mock.ChatRequests, 2)
+	assert.Empty(t, mock.ChatRequests[1].WeakTopics)
+}
+
+// fakeLessonContext answers every message with chunks, or fails with err
+type fakeLessonContext struct {
+	chunks []models.LessonContextChunk
+	err    error
+}
+
+func (f fakeLessonContext) LessonContext(uuid.UUID, uuid.UUID, string) ([]models.LessonContextChunk, error) {
+	return f.chunks, f.err
+}
+
+// TestLessonContextSent tests that chat carries the lesson passages matching the message
+func TestLessonContextSent(t *testing.T) {
+	lessonID := uuid.New()
+	mock := &testsupport.MockIntelligence{}
+	lessonService := services.NewLessonService(testsupport.RowsDB(lessonWithCompletionColumns, nullLessonRow(lessonID)))
+	lessonHandler := handlers.NewLessonHandler(lessonService, mock)
+	lessonHandler.SetLessonContext(fakeLessonContext{chunks: []models.LessonContextChunk{
+		{Source: "lesson", Heading: "Signals", Content: "A signal is a change you can notice.", Rank: 0.6},
+	}})
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Post("/ngs/lessons/:id/chat/message", lessonHandler.SendEducatorChatMessage)
+	path := "/ngs/lessons/" + lessonID.String() + "/chat/message"
+
+	status, body := postJSON(t, app, path, `{"message": "What is a signal?"}`)
+	require.Equal(t, fiber.StatusOK, status, body)
+	require.Len(t, mock.ChatRequests, 1)
+	assert.Equal(t, []intelligence.LessonContextChunk{
+		{Source: "lesson", Heading: "Signals", Content: "A signal is a change you can notice.", Rank: 0.6},
+	}, mock.ChatRequests[0].LessonContext)
+
+	lessonHandler.SetLessonContext(fakeLessonContext{err: errors.New("database down")})
+	status, _ = postJSON(t, app, path, `{"message": "What is a signal?"}`)
+	assert.Equal(t, fiber.StatusOK, status, "chat goes on without lesson context")
+	require.Len(t, mock.ChatRequests, 2)
+	assert.Empty(t, mock.ChatRequests[1].LessonContext)
+}
+
+// TestServiceTokenProvider tests the service JWT sent to the Intelligence service
+func TestServiceTokenProvider(t *testing.T) {
+	token := intelligence.ServiceTokenProvider("test-secret")()
+	require.NotEmpty(t, token)
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) { return []byte("test-secret"), nil })
+	require.NoError(t, err)
+	assert.Equal(t, "ngs-curriculum", claims["service"])
+
+	_, err = jwt.ParseWithClaims(token, jwt.MapClaims{}, func(*jwt.Token) (interface{}, error) { return []byte("other"), nil })
+	assert.Error(t, err)
+}
@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/jobs"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingJob returns a job that reports its name on started and runs until release is closed
+func blockingJob(name string, started chan<- string, release <-chan struct{}) func(context.Context) {
+	return func(ctx context.Context) {
+		started <- name
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// receive waits for the next started job
+func receive(t *testing.T, started <-chan string) string {
+	select {
+	case name := <-started:
+		return name
+	case <-time.After(2 * time.Second):
+		t.Fatal("no job started")
+		return ""
+	}
+}
+
+// assertNoneStarted checks that no job starts for a moment
+func assertNoneStarted(t *testing.T, started <-chan string) {
+	select {
+	case name := <-started:
+		t.Fatalf("job %s started", name)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestParseClassLimits tests reading JOB_CLASS_LIMITS
+func TestParseClassLimits(t *testing.T) {
+	limits, err := jobs.ParseClassLimits(" generation=2, digest=0,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"generation": 2, "digest": 0}, limits)
+
+	for _, spec := range []string{"exports=1", "generation", "generation=-1", "digest=many"} {
+		_, err := jobs.ParseClassLimits(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+// TestJobQueuePriority tests that free workers take the highest class first
+func TestJobQueuePriority(t *testing.T) {
+	queue := jobs.New(jobs.Settings{Workers: 1, MaxWait: time.Minute})
+	started, release := make(chan string, 10), make(chan struct{})
+
+	require.NoError(t, queue.Submit(jobs.ClassDigest, "first", blockingJob("first", started, release)))
+	assert.Equal(t, "first", receive(t, started))
+	for _, class := range []string{jobs.ClassDigest, jobs.ClassGeneration, jobs.ClassGrading} {
+		require.NoError(t, queue.Submit(class, class, blockingJob(class, started, release)))
+	}
+	assert.Equal(t, 3, queue.Depth())
+	assert.ErrorIs(t, queue.Submit("exports", "export", func(context.Context) {}), jobs.ErrUnknownClass)
+
+	close(release)
+	assert.Equal(t, []string{"grading", "generation", "digest"}, []string{receive(t, started), receive(t, started), receive(t, started)})
+	require.NoError(t, queue.Drain(context.Background()))
+}
+
+// TestJobQueueClassLimits tests per-class limits, including changing them at runtime
+func TestJobQueueClassLimits(t *testing.T) {
+	queue := jobs.New(jobs.Settings{Workers: 3, MaxWait: time.Minute, ClassLimits: map[string]int{"generation": 1, "digest": 0}})
+	started, release := make(chan string, 10), make(chan struct{})
+	defer close(release)
+
+	require.NoError(t, queue.Submit(jobs.ClassGeneration, "a", blockingJob("a", started, release)))
+	require.NoError(t, queue.Submit(jobs.ClassGeneration, "b", blockingJob("b", started, release)))
+	require.NoError(t, queue.Submit(jobs.ClassDigest, "digest", blockingJob("digest", started, release)))
+	assert.Equal(t, "a", receive(t, started))
+	assertNoneStarted(t, started)
+
+	stats := queue.Stats()
+	require.Len(t, stats, 3)
+	assert.Equal(t, jobs.ClassStats{Class: "grading", MaxConcurrent: 3}, stats[0])
+	assert.Equal(t, "generation", stats[1].Class)
+	assert.Equal(t, 1, stats[1].MaxConcurrent)
+	assert.Equal(t, 1, stats[1].Running)
+	assert.Equal(t, 1, stats[1].Queued)
+	assert.Equal(t, 0, stats[2].MaxConcurrent, "a limit of 0 pauses digests")
+	assert.Equal(t, 1, stats[2].Queued)
+
+	require.NoError(t, queue.Configure(jobs.Settings{Workers: 3, MaxWait: time.Minute, ClassLimits: map[string]int{"generation": 2}}))
+	assert.ElementsMatch(t, []string{"b", "digest"}, []string{receive(t, started), receive(t, started)})
+
+	assert.Error(t, queue.Configure(jobs.Settings{Workers: 0, MaxWait: time.Minute}))
+	assert.Error(t, queue.Configure(jobs.Settings{Workers: 1, MaxWait: time.Minute, ClassLimits: map[string]int{"exports": 1}}))
+}
+
+// TestJobQueueStarvation tests that a job waiting the maximum wait goes ahead of higher classes
+func TestJobQueueStarvation(t *testing.T) {
+	clock := testsupport.NewFakeClock(time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC))
+	queue := jobs.NewWithClock(jobs.Settings{Workers: 1, MaxWait: time.Minute}, clock.Now)
+	started := make(chan string, 10)
+	releases := map[string]chan struct{}{}
+	submit := func(class, name string) {
+		releases[name] = make(chan struct{})
+		require.NoError(t, queue.Submit(class, name, blockingJob(name, started, releases[name])))
+	}
+
+	submit(jobs.ClassGrading, "grading 1")
+	assert.Equal(t, "grading 1", receive(t, started))
+	submit(jobs.ClassDigest, "digest")
+	clock.Advance(30 * time.Second)
+	submit(jobs.ClassGrading, "grading 2")
+
+	// Not waiting long enough yet: grading goes first
+	close(releases["grading 1"])
+	assert.Equal(t, "grading 2", receive(t, started))
+
+	submit(jobs.ClassGrading, "grading 3")
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, time.Minute, queue.Stats()[2].OldestWait)
+	close(releases["grading 2"])
+	assert.Equal(t, "digest", receive(t, started), "the digest has waited a minute")
+
+	close(releases["digest"])
+	assert.Equal(t, "grading 3", receive(t, started))
+	close(releases["grading 3"])
+	require.NoError(t, queue.Drain(context.Background()))
+}
+
+// TestJobQueueDrain tests shutting the queue down
+func TestJobQueueDrain(t *testing.T) {
+	queue := jobs.New(jobs.Settings{Workers: 1, MaxWait: time.Minute})
+	started := make(chan string, 10)
+	cancelled := make(chan struct{})
+	require.NoError(t, queue.Submit(jobs.ClassGeneration, "running", func(ctx context.Context) {
+		started <- "running"
+		<-ctx.Done()
+		close(cancelled)
+	}))
+	require.NoError(t, queue.Submit(jobs.ClassGeneration, "queued", blockingJob("queued", started, nil)))
+	assert.Equal(t, "running", receive(t, started))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, queue.Drain(ctx), context.DeadlineExceeded)
+	<-cancelled
+	assertNoneStarted(t, started)
+	assert.Zero(t, queue.Depth(), "queued jobs are dropped")
+	assert.ErrorIs(t, queue.Submit(jobs.ClassGrading, "late", func(context.Context) {}), jobs.ErrQueueClosed)
+}
+
+// TestJobQueueSettings tests admin overrides of the job pool defaults
+func TestJobQueueSettings(t *testing.T) {
+	cfg := progressConfig()
+	cfg.JobWorkers, cfg.JobMaxWaitSeconds, cfg.JobClassLimits = 4, 60, "generation=2,digest=1"
+	defaults, err := services.DefaultJobSettings(cfg)
+	require.NoError(t, err)
+
+	workers := 8
+	merged := services.MergeJobSettings(defaults, models.SetJobQueueRequest{Workers: &workers, ClassLimits: map[string]int{"digest": 0}})
+	assert.Equal(t, jobs.Settings{Workers: 8, MaxWait: time.Minute, ClassLimits: map[string]int{"generation": 2, "digest": 0}}, merged)
+	assert.Equal(t, map[string]int{"generation": 2, "digest": 1}, defaults.ClassLimits, "defaults are left alone")
+
+	cfg.JobClassLimits = "exports=1"
+	_, err = services.DefaultJobSettings(cfg)
+	assert.Error(t, err)
+
+	intPtr := func(n int) *int { return &n }
+	assert.NoError(t, services.ValidateJobQueueRequest(models.SetJobQueueRequest{Workers: intPtr(4), MaxWaitSeconds: intPtr(120), ClassLimits: map[string]int{"grading": 4}}))
+	for _, req := range []models.SetJobQueueRequest{
+		{Workers: intPtr(0)},
+		{Workers: intPtr(65)},
+		{MaxWaitSeconds: intPtr(0)},
+		{ClassLimits: map[string]int{"exports": 1}},
+		{ClassLimits: map[string]int{"digest": -1}},
+	} {
+		assert.ErrorIs(t, services.ValidateJobQueueRequest(req), services.ErrInvalidJobSettings)
+	}
+}
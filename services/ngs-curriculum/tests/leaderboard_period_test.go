@@ -0,0 +1,238 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLeaderboardPeriodWindows tests that period windows are calendar days, ISO weeks and months in UTC
+func TestLeaderboardPeriodWindows(t *testing.T) {
+	friday := time.Date(2026, 10, 16, 22, 30, 0, 0, time.UTC)
+	day := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		require.NoError(t, err)
+		return d
+	}
+
+	for _, tc := range []struct {
+		name       string
+		now        time.Time
+		start, end string
+	}{
+		{services.LeaderboardDaily, friday, "2026-10-16", "2026-10-17"},
+		{services.LeaderboardWeekly, friday, "2026-10-12", "2026-10-19"},
+		{services.LeaderboardWeekly, time.Date(2026, 10, 18, 23, 59, 0, 0, time.UTC), "2026-10-12", "2026-10-19"},
+		{services.LeaderboardWeekly, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), "2026-10-19", "2026-10-26"},
+		{services.LeaderboardMonthly, friday, "2026-10-01", "2026-11-01"},
+	} {
+		period, err := services.ParseLeaderboardPeriod(tc.name, tc.now)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, day(tc.start), period.Start, "%s at %s", tc.name, tc.now)
+		assert.Equal(t, day(tc.end), period.End, "%s at %s", tc.name, tc.now)
+	}
+
+	for _, name := range []string{"", services.LeaderboardAllTime} {
+		period, err := services.ParseLeaderboardPeriod(name, friday)
+		require.NoError(t, err)
+		assert.True(t, period.AllTime())
+	}
+	_, err := services.ParseLeaderboardPeriod("yearly", friday)
+	assert.ErrorIs(t, err, services.ErrInvalidLeaderboardPeriod)
+}
+
+// TestWeeklyLeaderboard tests that a weekly board ranks XP earned this week, not lifetime XP
+func TestWeeklyLeaderboard(t *testing.T) {
+	cfg := progressConfig()
+	service, store := testsupport.NewProgressService(cfg)
+	veteran, newcomer, hidden := uuid.New(), uuid.New(), uuid.New()
+	store.PutProgress(testsupport.Progress().User(veteran).XP(3000, cfg.LevelUpXPThresholds).Build())
+	store.HideFromLeaderboard(hidden)
+
+	// Last week the veteran earned plenty; this week only a little
+	store.Clock.Set(time.Date(2026, 10, 11, 20, 0, 0, 0, time.UTC))
+	_, err := service.AwardXP(veteran, "challenge_passed", 500, nil)
+	require.NoError(t, err)
+	store.Clock.Set(time.Date(2026, 10, 13, 9, 0, 0, 0, time.UTC))
+	_, err = service.AwardXP(veteran, "reflection", 25, nil)
+	require.NoError(t, err)
+	_, err = service.AwardXP(newcomer, "challenge_passed", 100, nil)
+	require.NoError(t, err)
+	_, err = service.AwardXP(newcomer, "lesson_completion", 50, nil)
+	require.NoError(t, err)
+	_, err = service.AwardXP(hidden, "challenge_passed", 900, nil)
+	require.NoError(t, err)
+	store.Clock.Set(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+
+	weekly, err := service.LeaderboardPeriod(services.LeaderboardWeekly)
+	require.NoError(t, err)
+	entries, err := service.GetPeriodLeaderboard(weekly, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, newcomer, entries[0].UserID)
+	assert.Equal(t, 150, entries[0].PeriodXP)
+	assert.Equal(t, 1, entries[0].Rank)
+	assert.Equal(t, veteran, entries[1].UserID)
+	assert.Equal(t, 25, entries[1].PeriodXP)
+	assert.Equal(t, 3525, entries[1].TotalXP, "entries still carry lifetime XP")
+
+	allTime, err := service.LeaderboardPeriod("")
+	require.NoError(t, err)
+	entries, err = service.GetPeriodLeaderboard(allTime, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, veteran, entries[0].UserID)
+	assert.Zero(t, entries[0].PeriodXP)
+
+	// The next week starts empty
+	store.Clock.Set(time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC))
+	weekly, err = service.LeaderboardPeriod(services.LeaderboardWeekly)
+	require.NoError(t, err)
+	entries, err = service.GetPeriodLeaderboard(weekly, 10)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+// TestLeaderboardPeriodParam tests the period query parameter of GET /ngs/leaderboard
+func TestLeaderboardPeriodParam(t *testing.T) {
+	cfg := progressConfig()
+	service, store := testsupport.NewProgressService(cfg)
+	store.Clock.Set(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	_, err := service.AwardXP(uuid.New(), "challenge_passed", 100, nil)
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/leaderboard", handlers.NewHandler(service).GetLeaderboard)
+
+	body := getList(t, app, "/ngs/leaderboard?period=monthly")
+	assert.JSONEq(t, `"monthly"`, string(body["period"]))
+	assert.JSONEq(t, `"2026-10-01T00:00:00Z"`, string(body["period_start"]))
+	assert.JSONEq(t, `"2026-11-01T00:00:00Z"`, string(body["resets_at"]))
+	var entries []models.LeaderboardEntry
+	require.NoError(t, json.Unmarshal(body["leaderboard"], &entries))
+	require.Len(t, entries, 1)
+	assert.Equal(t, 100, entries[0].PeriodXP)
+
+	body = getList(t, app, "/ngs/leaderboard")
+	assert.JSONEq(t, `"all"`, string(body["period"]))
+	assert.NotContains(t, body, "resets_at")
+
+	req := httptest.NewRequest("GET", "/ngs/leaderboard?period=yearly", nil)
+	req.Header.Set("X-User-Id", uuid.New().String())
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
+
+// TestLeaderboardPosition tests finding a user's rank with the users ranked either side
+func TestLeaderboardPosition(t *testing.T) {
+	cfg := progressConfig()
+	service, store := testsupport.NewProgressService(cfg)
+	store.Clock.Set(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	users := make([]uuid.UUID, 6)
+	for i := range users {
+		users[i] = uuid.New()
+		store.PutProgress(testsupport.Progress().User(users[i]).XP(1000-100*i, cfg.LevelUpXPThresholds).Build())
+	}
+	hidden := uuid.New()
+	store.PutProgress(testsupport.Progress().User(hidden).XP(5000, cfg.LevelUpXPThresholds).Build())
+	store.HideFromLeaderboard(hidden)
+
+	allTime, err := service.LeaderboardPeriod("")
+	require.NoError(t, err)
+	position, err := service.GetLeaderboardPosition(users[3], allTime, 2)
+	require.NoError(t, err)
+	require.True(t, position.Ranked)
+	assert.Equal(t, 4, position.Me.Rank)
+	assert.Equal(t, 700, position.Me.TotalXP)
+	assert.Equal(t, 6, position.TotalRanked)
+	require.Len(t, position.Above, 2)
+	assert.Equal(t, users[2], position.Above[0].UserID, "nearest first")
+	assert.Equal(t, users[1], position.Above[1].UserID)
+	require.Len(t, position.Below, 2)
+	assert.Equal(t, users[4], position.Below[0].UserID)
+	assert.Equal(t, 6, position.Below[1].Rank)
+
+	position, err = service.GetLeaderboardPosition(users[0], allTime, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 1, position.Me.Rank)
+	assert.Empty(t, position.Above)
+	assert.Len(t, position.Below, 2)
+
+	position, err = service.GetLeaderboardPosition(hidden, allTime, 2)
+	require.NoError(t, err)
+	assert.False(t, position.Ranked)
+	assert.Nil(t, position.Me)
+
+	// Only users[5] earned XP this week
+	_, err = service.AwardXP(users[5], "reflection", 25, nil)
+	require.NoError(t, err)
+	weekly, err := service.LeaderboardPeriod(services.LeaderboardWeekly)
+	require.NoError(t, err)
+	position, err = service.GetLeaderboardPosition(users[5], weekly, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 1, position.Me.Rank)
+	assert.Equal(t, 25, position.Me.PeriodXP)
+	assert.Equal(t, 1, position.TotalRanked)
+	position, err = service.GetLeaderboardPosition(users[0], weekly, 2)
+	require.NoError(t, err)
+	assert.False(t, position.Ranked, "users who earned nothing this week are not on the weekly board")
+}
+
+// TestLeaderboardPositionEndpoint tests GET /ngs/leaderboard/me
+func TestLeaderboardPositionEndpoint(t *testing.T) {
+	cfg := progressConfig()
+	service, store := testsupport.NewProgressService(cfg)
+	store.Clock.Set(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	userID := uuid.New()
+	_, err := service.AwardXP(userID, "challenge_passed", 100, nil)
+	require.NoError(t, err)
+	_, err = service.AwardXP(uuid.New(), "challenge_passed", 200, nil)
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/leaderboard/me", handlers.NewHandler(service).GetLeaderboardPosition)
+
+	get := func(path string) (int, map[string]json.RawMessage) {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-User-Id", userID.String())
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var body map[string]json.RawMessage
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp.StatusCode, body
+	}
+
+	status, body := get("/ngs/leaderboard/me?period=daily&neighbors=1")
+	require.Equal(t, fiber.StatusOK, status)
+	assert.JSONEq(t, `true`, string(body["ranked"]))
+	assert.JSONEq(t, `2`, string(body["total_ranked"]))
+	assert.JSONEq(t, `"2026-10-17T00:00:00Z"`, string(body["resets_at"]))
+	var me models.LeaderboardEntry
+	require.NoError(t, json.Unmarshal(body["me"], &me))
+	assert.Equal(t, 2, me.Rank)
+	var above []models.LeaderboardEntry
+	require.NoError(t, json.Unmarshal(body["above"], &above))
+	require.Len(t, above, 1)
+	assert.Equal(t, 200, above[0].PeriodXP)
+	assert.JSONEq(t, `[]`, string(body["below"]))
+
+	for _, path := range []string{"/ngs/leaderboard/me?neighbors=11", "/ngs/leaderboard/me?neighbors=-1", "/ngs/leaderboard/me?period=yearly"} {
+		status, _ := get(path)
+		assert.Equal(t, fiber.StatusBadRequest, status, path)
+	}
+}
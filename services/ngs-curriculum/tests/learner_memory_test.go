@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/jobs"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLearnerMemory answers every user with summary, or fails with err
+type fakeLearnerMemory struct {
+	summary string
+	err     error
+}
+
+func (f fakeLearnerMemory) LearnerMemory(uuid.UUID) (*models.LearnerMemory, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &models.LearnerMemory{Summary: f.summary, SessionsSummarized: 1}, nil
+}
+
+// TestLearnerMemorySent tests that new chat sessions carry the learner's memory and shared
+// generation never does
+func TestLearnerMemorySent(t *testing.T) {
+	lessonID := uuid.New()
+	memory := "Confuses signals with noise; likes worked examples."
+	mock := &testsupport.MockIntelligence{}
+	lessonService := services.NewLessonService(testsupport.RowsDB(lessonWithCompletionColumns, nullLessonRow(lessonID)))
+	lessonHandler := handlers.NewLessonHandler(lessonService, mock)
+	lessonHandler.SetLearnerMemory(fakeLearnerMemory{summary: memory})
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Post("/ngs/lessons/:id/generate", lessonHandler.GenerateLesson)
+	app.Post("/ngs/lessons/:id/chat/message", lessonHandler.SendEducatorChatMessage)
+	chatPath := "/ngs/lessons/" + lessonID.String() + "/chat/message"
+
+	status, body := postJSONAs(t, app, "educator", "/ngs/lessons/"+lessonID.String()+"/generate", `{}`)
+	require.Equal(t, fiber.StatusOK, status, body)
+	require.Len(t, mock.GenerateRequests, 1)
+	request, err := json.Marshal(mock.GenerateRequests[0])
+	require.NoError(t, err)
+	assert.NotContains(t, string(request), memory)
+
+	status, body = postJSON(t, app, chatPath, `{"message": "What is a signal?"}`)
+	require.Equal(t, fiber.StatusOK, status, body)
+	require.Len(t, mock.ChatRequests, 1)
+	assert.Equal(t, memory, mock.ChatRequests[0].LearnerMemory, "a new session is seeded with the memory")
+
+	status, body = postJSON(t, app, chatPath, `{"message": "And noise?", "session_id": "`+uuid.NewString()+`"}`)
+	require.Equal(t, fiber.StatusOK, status, body)
+	require.Len(t, mock.ChatRequests, 2)
+	assert.Empty(t, mock.ChatRequests[1].LearnerMemory, "a running session already has it")
+
+	lessonHandler.SetLearnerMemory(fakeLearnerMemory{err: errors.New("database down")})
+	status, _ = postJSON(t, app, chatPath, `{"message": "What is a signal?"}`)
+	assert.Equal(t, fiber.StatusOK, status, "chat goes on without memory")
+	require.Len(t, mock.ChatRequests, 3)
+	assert.Empty(t, mock.ChatRequests[2].LearnerMemory)
+}
+
+// TestLearnerMemoryHandlers tests reading and forgetting a learner's memory
+func TestLearnerMemoryHandlers(t *testing.T) {
+	updated := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	db := testsupport.RowsDB([]string{"summary", "sessions_summarized", "updated_at"},
+		[]driver.Value{"Prefers short answers.", int64(3), updated})
+	jobService := services.NewJobService(db, jobs.New(jobs.Settings{Workers: 1, MaxWait: time.Minute}), services.SystemClock{})
+	memoryService := services.NewLearnerMemoryService(db, testsupport.Config(), services.SystemClock{}, nil, jobService)
+	handler := handlers.NewLearnerMemoryHandler(memoryService)
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/me/memory", handler.GetMemory)
+	app.Delete("/ngs/me/memory", handler.ForgetMemory)
+
+	req := httptest.NewRequest("GET", "/ngs/me/memory", nil)
+	req.Header.Set("X-User-Id", uuid.NewString())
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var memory models.LearnerMemory
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&memory))
+	assert.Equal(t, "Prefers short answers.", memory.Summary)
+	assert.Equal(t, 3, memory.SessionsSummarized)
+	require.NotNil(t, memory.UpdatedAt)
+	assert.True(t, updated.Equal(*memory.UpdatedAt))
+
+	req = httptest.NewRequest("DELETE", "/ngs/me/memory", nil)
+	req.Header.Set("X-User-Id", uuid.NewString())
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+
+	req = httptest.NewRequest("GET", "/ngs/me/memory", nil)
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+}
@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/jobs"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func artifactItems(t *testing.T, data string) []map[string]interface{} {
+	var items []map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(data), &items))
+	return items
+}
+
+// TestFormatNotesOutline tests turning a generated notes outline into copy-ready markdown
+func TestFormatNotesOutline(t *testing.T) {
+	notes, markdown := services.FormatNotesOutline(artifactItems(t, `[
+		{"heading": "Noticing", "content": "Pause before you react.", "level": 1},
+		{"heading": "Steps", "points": ["Breathe", " ", "Name the feeling"], "level": 2},
+		{"title": "Deep", "content": "x", "level": 9},
+		{"heading": " ", "content": ""},
+		{"content": "A closing thought"}
+	]`))
+
+	require.Len(t, notes, 4, "entries with neither heading nor content are skipped")
+	assert.Equal(t, "# Noticing\n\nPause before you react.", notes[0].Markdown)
+	assert.Equal(t, "- Breathe\n- Name the feeling", notes[1].Content, "listed content becomes bullets")
+	assert.Equal(t, "## Steps\n\n- Breathe\n- Name the feeling", notes[1].Markdown)
+	assert.Equal(t, 6, notes[2].Level, "markdown headings go no deeper than 6")
+	assert.Equal(t, "Deep", notes[2].Heading)
+	assert.Equal(t, 1, notes[3].Level)
+	assert.Equal(t, "A closing thought", notes[3].Markdown)
+	assert.Equal(t, notes[0].Markdown+"\n\n"+notes[1].Markdown+"\n\n"+notes[2].Markdown+"\n\n"+notes[3].Markdown, markdown)
+
+	notes, markdown = services.FormatNotesOutline(nil)
+	assert.NotNil(t, notes)
+	assert.Empty(t, notes)
+	assert.Empty(t, markdown)
+}
+
+// TestFormatCodeSnippets tests fencing generated code snippets for copying
+func TestFormatCodeSnippets(t *testing.T) {
+	snippets := services.FormatCodeSnippets(artifactItems(t, `[
+		{"language": "Python 3", "code": "\r\n\r\ndef greet():\r\n    print('hi')\r\n\r\n", "description": " Greets "},
+		{"lang": "markdown", "code": "Use `+"```go"+` fences", "title": "Fences"},
+		{"language": "go", "code": "  \n"},
+		{"code": "SELECT 1"}
+	]`))
+
+	require.Len(t, snippets, 3, "snippets without code are skipped")
+	assert.Equal(t, "python", snippets[0].Language)
+	assert.Equal(t, "Greets", snippets[0].Description)
+	assert.Equal(t, "def greet():\n    print('hi')", snippets[0].Code, "blank lines around the code are trimmed, indentation kept")
+	assert.Equal(t, "```python\ndef greet():\n    print('hi')\n```", snippets[0].Markdown)
+	assert.Equal(t, "````markdown\nUse ```go fences\n````", snippets[1].Markdown, "the fence outgrows backticks in the code")
+	assert.Equal(t, "Fences", snippets[1].Description)
+	assert.Equal(t, "```\nSELECT 1\n```", snippets[2].Markdown)
+}
+
+// TestLessonArtifactsTypeValidation tests that unknown artifact types are rejected
+func TestLessonArtifactsTypeValidation(t *testing.T) {
+	db := testsupport.RowsDB([]string{"exists"})
+	jobService := services.NewJobService(db, jobs.New(jobs.Settings{Workers: 1, MaxWait: time.Minute}), services.SystemClock{})
+	mediaService := services.NewMediaService(db, nil, jobService)
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/lessons/:id/artifacts", handlers.NewMediaHandler(mediaService).GetLessonArtifacts)
+
+	req := httptest.NewRequest("GET", "/ngs/lessons/"+uuid.NewString()+"/artifacts?type=quiz", nil)
+	req.Header.Set("X-User-Id", uuid.NewString())
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	var body map[string]string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, services.ErrInvalidArtifactType.Error(), body["error"])
+}
+
+// TestLessonArtifactsLevelLocked tests that notes and snippets of a lesson above the user's level
+// are refused, as the lesson itself is
+func TestLessonArtifactsLevelLocked(t *testing.T) {
+	for _, locked := range []bool{true, false} {
+		db := testsupport.QueryDB(
+			testsupport.Query{Match: "user_progress", Columns: []string{"locked"}, Rows: [][]driver.Value{{locked}}},
+			testsupport.Query{Match: "notes_outline", Columns: []string{"artifact_data"},
+				Rows: [][]driver.Value{{[]byte(`[{"heading": "Loops", "content": "Repeat work"}]`)}}},
+		)
+		jobService := services.NewJobService(db, jobs.New(jobs.Settings{Workers: 1, MaxWait: time.Minute}), services.SystemClock{})
+		app := fiber.New()
+		app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+		app.Get("/ngs/lessons/:id/artifacts", handlers.NewMediaHandler(services.NewMediaService(db, nil, jobService)).GetLessonArtifacts)
+
+		for _, artifactType := range []string{services.StudyArtifactNotes, services.StudyArtifactSnippets} {
+			req := httptest.NewRequest("GET", "/ngs/lessons/"+uuid.NewString()+"/artifacts?type="+artifactType, nil)
+			req.Header.Set("X-User-Id", uuid.NewString())
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			if locked {
+				assert.Equal(t, fiber.StatusForbidden, resp.StatusCode, artifactType)
+				assert.Equal(t, "level_locked", body["error"], artifactType)
+			} else {
+				assert.Equal(t, fiber.StatusOK, resp.StatusCode, artifactType)
+			}
+		}
+	}
+}
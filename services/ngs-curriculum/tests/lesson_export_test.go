@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/domain/lessonexport"
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportDocument() lessonexport.Document {
+	return lessonexport.Document{
+		Title:            "Noticing (part 1)",
+		Description:      "Pausing before you react",
+		Level:            3,
+		Order:            2,
+		LessonType:       "tutorial",
+		Difficulty:       "beginner",
+		EstimatedMinutes: 20,
+		Outcomes:         []string{"Name a feeling"},
+		UpdatedAt:        time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC),
+		Body:             "# Noticing (part 1)\n\n## Pause\n\nTake a **breath** — then [read more](https://example.com/pause).\n\n```python\nif calm:\n    act()\n```\n",
+		Glossary: []lessonexport.Term{
+			{Term: "pause", Definition: "A short stop"},
+			{Term: "Breath", Definition: "Air in and out"},
+			{Term: " ", Definition: "dropped"},
+		},
+	}
+}
+
+// TestLessonMarkdownExport tests the markdown export's header, content and glossary appendix
+func TestLessonMarkdownExport(t *testing.T) {
+	markdown := string(lessonexport.Markdown(exportDocument()))
+
+	assert.True(t, strings.HasPrefix(markdown, "# Noticing (part 1)\n\nPausing before you react\n\n"), markdown)
+	assert.Equal(t, 1, strings.Count(markdown, "# Noticing (part 1)\n"), "the content's own title heading is dropped")
+	for _, field := range []string{"- **Level:** 3, lesson 2", "- **Type:** tutorial", "- **Difficulty:** beginner", "- **Estimated time:** 20 minutes", "- **Updated:** 2026-03-04", "- Name a feeling"} {
+		assert.Contains(t, markdown, field+"\n")
+	}
+	assert.Contains(t, markdown, "## Pause\n")
+	assert.True(t, strings.HasSuffix(markdown, "## Glossary\n\n**Breath:** Air in and out\n\n**pause:** A short stop\n"),
+		"the glossary is last, alphabetically, without blank terms: %s", markdown)
+
+	bare := string(lessonexport.Markdown(lessonexport.Document{Title: "Bare", Body: "Text"}))
+	assert.Equal(t, "# Bare\n\n\n---\n\nText\n", bare)
+}
+
+var (
+	pdfStreamPattern = regexp.MustCompile(`(?s)stream\n(.*?)\nendstream`)
+	pdfObjectPattern = regexp.MustCompile(`^(\d+) 0 obj`)
+)
+
+// pdfText returns the decompressed content streams of each page
+func pdfText(t *testing.T, pdf []byte) []string {
+	var pages []string
+	for _, m := range pdfStreamPattern.FindAllSubmatch(pdf, -1) {
+		r, err := zlib.NewReader(bytes.NewReader(m[1]))
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		require.NoError(t, err)
+		pages = append(pages, string(content))
+	}
+	return pages
+}
+
+// TestLessonPDFExport tests that the PDF export is a well-formed PDF with the lesson on its pages
+func TestLessonPDFExport(t *testing.T) {
+	pdf := lessonexport.PDF(exportDocument())
+	require.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")))
+	require.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+
+	// Every cross-reference entry points at its object
+	startxref := bytes.LastIndex(pdf, []byte("startxref\n"))
+	require.Positive(t, startxref)
+	xref, err := strconv.Atoi(strings.Fields(string(pdf[startxref+len("startxref\n"):]))[0])
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(pdf[xref:], []byte("xref\n")))
+	lines := strings.Split(string(pdf[xref:]), "\n")
+	size, err := strconv.Atoi(strings.Fields(lines[1])[1])
+	require.NoError(t, err)
+	for i := 1; i < size; i++ {
+		offset, err := strconv.Atoi(strings.Fields(lines[2+i])[0])
+		require.NoError(t, err)
+		m := pdfObjectPattern.FindSubmatch(pdf[offset:])
+		require.NotNil(t, m, "object %d", i)
+		assert.Equal(t, strconv.Itoa(i), string(m[1]))
+	}
+
+	assert.Contains(t, string(pdf), "/Count 1 ")
+	assert.Contains(t, string(pdf), "/Title (Noticing \\(part 1\\))")
+	pages := pdfText(t, pdf)
+	require.Len(t, pages, 1)
+	assert.Contains(t, pages[0], "/F2 20 Tf", "the title is a heading")
+	assert.Contains(t, pages[0], "(Noticing \\(part 1\\)) Tj")
+	assert.Contains(t, pages[0], "(Take a breath \\227 then read more \\(https://example.com/pause\\).) Tj",
+		"inline markdown is stripped and the dash is in WinAnsi")
+	assert.Contains(t, pages[0], "/F4 9.5 Tf 64 ", "code is set in Courier")
+	assert.Contains(t, pages[0], "(    act\\(\\)) Tj", "code keeps its indentation")
+	assert.Contains(t, pages[0], "(\\225) Tj", "bullets have a marker")
+	assert.Contains(t, pages[0], "(1 / 1) Tj")
+
+	long := exportDocument()
+	long.Body = strings.Repeat(fmt.Sprintf("%s\n\n", strings.Repeat("A sentence that fills the page. ", 20)), 40)
+	pages = pdfText(t, lessonexport.PDF(long))
+	assert.Greater(t, len(pages), 3, "long lessons run onto more pages")
+	assert.Contains(t, pages[len(pages)-1], fmt.Sprintf("(%d / %d) Tj", len(pages), len(pages)))
+}
+
+// TestExportLessonHandler tests GET /ngs/lessons/:id/export
+func TestExportLessonHandler(t *testing.T) {
+	lessonID := uuid.New()
+	row := lockedLessonRow(lessonID)
+	row[14] = []byte(`{"metadata": {"difficulty": "advanced", "outcomes": ["Coordinate"]}, "artifacts": {"glossary": [{"term": "Swarm", "definition": "Many agents"}]}}`)
+	row[17] = testsupport.Epoch
+	row[23] = false
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	lessonHandler := handlers.NewLessonHandler(services.NewLessonService(testsupport.RowsDB(lessonWithCompletionColumns, row)), &testsupport.MockIntelligence{})
+	app.Get("/ngs/lessons/:id/export", lessonHandler.ExportLesson)
+	get := func(query string) (int, http.Header, []byte) {
+		req := httptest.NewRequest("GET", "/ngs/lessons/"+lessonID.String()+"/export"+query, nil)
+		req.Header.Set("X-User-Id", uuid.NewString())
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, resp.Header, body
+	}
+
+	status, header, body := get("?format=md")
+	require.Equal(t, fiber.StatusOK, status, string(body))
+	assert.Equal(t, "text/markdown; charset=utf-8", header.Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="agent-swarms.md"`, header.Get("Content-Disposition"))
+	assert.Contains(t, string(body), "- **Difficulty:** advanced\n")
+	assert.Contains(t, string(body), "# Swarms\n", "the content is exported as published")
+	assert.Contains(t, string(body), "**Swarm:** Many agents")
+
+	status, header, body = get("")
+	require.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "application/pdf", header.Get("Content-Type"), "PDF is the default")
+	assert.Equal(t, `attachment; filename="agent-swarms.pdf"`, header.Get("Content-Disposition"))
+	assert.True(t, bytes.HasPrefix(body, []byte("%PDF-")))
+
+	status, _, _ = get("?format=docx")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+
+	row[23] = true
+	app = fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	lessonHandler = handlers.NewLessonHandler(services.NewLessonService(testsupport.RowsDB(lessonWithCompletionColumns, row)), &testsupport.MockIntelligence{})
+	app.Get("/ngs/lessons/:id/export", lessonHandler.ExportLesson)
+	status, _, body = get("?format=md")
+	assert.Equal(t, fiber.StatusForbidden, status)
+	assert.Contains(t, string(body), "level_locked")
+}
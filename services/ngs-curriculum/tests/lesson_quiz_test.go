@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const lessonQuizItems = `[
+	{"question": "Which keyword loops?", "type": "multiple_choice", "choices": ["if", "for", "def"], "correct_answer": 1},
+	{"question": "Lists are immutable.", "type": "true_false", "correct_answer": false},
+	{"question": "Ungradable, no key", "type": "short_answer"},
+	{"question": "Keyword that defines a function?", "type": "short_answer", "correct_answer": "def", "explanation": "def starts a function"}
+]`
+
+// lessonQuizService serves a lesson whose locked state and generated quiz are given, recording
+// the attempts stored, with bestScore as the learner's best attempt
+func lessonQuizService(locked bool, quizItems string, bestScore int64, attempts *[][]driver.Value) *services.LessonService {
+	queries := []testsupport.Query{
+		{Match: "INSERT INTO lesson_quiz_attempts", Columns: []string{"id", "created_at"},
+			Rows: [][]driver.Value{{uuid.NewString(), testsupport.Epoch}}, Args: attempts},
+		{Match: "FROM lesson_quiz_attempts", Columns: []string{"max"}, Rows: [][]driver.Value{{bestScore}}},
+	}
+	if quizItems != "" {
+		queries = append(queries, testsupport.Query{Match: "artifact_type = 'quiz_items'",
+			Columns: []string{"artifact_data"}, Rows: [][]driver.Value{{[]byte(quizItems)}}})
+	}
+	queries = append(queries, testsupport.Query{Match: "user_progress", Columns: []string{"locked"}, Rows: [][]driver.Value{{locked}}})
+	return services.NewLessonService(testsupport.QueryDB(queries...))
+}
+
+// TestSubmitLessonQuiz tests that lesson quiz answers are graded by the service against the
+// generated quiz's answer keys, and each attempt is stored with its score
+func TestSubmitLessonQuiz(t *testing.T) {
+	userID, lessonID := uuid.New(), uuid.New()
+
+	t.Run("Graded against the answer keys", func(t *testing.T) {
+		var attempts [][]driver.Value
+		answers := map[string]interface{}{"0": "FOR", "1": true, "2": "anything", "3": " def "}
+		attempt, err := lessonQuizService(false, lessonQuizItems, 80, &attempts).SubmitLessonQuiz(lessonID, userID, answers)
+		require.NoError(t, err)
+
+		assert.Equal(t, 66, attempt.Score, "two of the three gradable questions")
+		assert.Equal(t, 80, attempt.BestScore)
+		assert.Equal(t, []models.LessonQuizQuestionResult{
+			{Index: 0, Correct: true}, {Index: 1, Correct: false}, {Index: 3, Correct: true},
+		}, attempt.Results)
+
+		require.Len(t, attempts, 1)
+		assert.Equal(t, userID.String(), attempts[0][0])
+		assert.Equal(t, lessonID.String(), attempts[0][1])
+		assert.Equal(t, int64(66), attempts[0][4])
+	})
+
+	t.Run("Unanswered questions are wrong", func(t *testing.T) {
+		attempt, err := lessonQuizService(false, lessonQuizItems, 0, nil).SubmitLessonQuiz(lessonID, userID, nil)
+		require.NoError(t, err)
+		assert.Zero(t, attempt.Score)
+	})
+
+	t.Run("Lesson above the learner's level", func(t *testing.T) {
+		_, err := lessonQuizService(true, lessonQuizItems, 0, nil).SubmitLessonQuiz(lessonID, userID, nil)
+		assert.ErrorIs(t, err, services.ErrLevelLocked)
+	})
+
+	t.Run("Lesson without a gradable quiz", func(t *testing.T) {
+		_, err := lessonQuizService(false, "", 0, nil).SubmitLessonQuiz(lessonID, userID, nil)
+		assert.ErrorIs(t, err, services.ErrNoLessonQuiz)
+
+		_, err = lessonQuizService(false, `[{"question": "No key"}]`, 0, nil).SubmitLessonQuiz(lessonID, userID, nil)
+		assert.ErrorIs(t, err, services.ErrNoLessonQuiz)
+	})
+}
+
+// TestHideQuizAnswers tests that quiz answer keys are left out of lesson metadata sent to learners
+func TestHideQuizAnswers(t *testing.T) {
+	metadata := json.RawMessage(`{"summary": "Loops", "artifacts": {"quiz_items": ` + lessonQuizItems + `, "glossary": [{"term": "loop"}]}}`)
+
+	var hidden struct {
+		Summary   string `json:"summary"`
+		Artifacts struct {
+			QuizItems []map[string]interface{} `json:"quiz_items"`
+			Glossary  []map[string]interface{} `json:"glossary"`
+		} `json:"artifacts"`
+	}
+	require.NoError(t, json.Unmarshal(services.HideQuizAnswers(metadata), &hidden))
+	assert.Equal(t, "Loops", hidden.Summary)
+	assert.Len(t, hidden.Artifacts.Glossary, 1)
+	require.Len(t, hidden.Artifacts.QuizItems, 4)
+	for _, item := range hidden.Artifacts.QuizItems {
+		assert.NotEmpty(t, item["question"])
+		assert.NotContains(t, item, "correct_answer")
+		assert.NotContains(t, item, "explanation")
+	}
+	assert.Equal(t, []interface{}{"if", "for", "def"}, hidden.Artifacts.QuizItems[0]["choices"])
+
+	assert.JSONEq(t, `{"summary": "No quiz"}`, string(services.HideQuizAnswers(json.RawMessage(`{"summary": "No quiz"}`))))
+	assert.Nil(t, services.HideQuizAnswers(nil))
+}
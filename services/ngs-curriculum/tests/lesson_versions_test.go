@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"noble-ngs-curriculum/internal/domain/lessondiff"
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMarkdownDiff tests line diffs of lesson markdown in unified hunks
+func TestMarkdownDiff(t *testing.T) {
+	lines := func(n int, prefix string) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = prefix + string(rune('a'+i))
+		}
+		return out
+	}
+	from := lines(20, "line ")
+	to := append([]string{}, from...)
+	to[1] = "changed b"
+	to = append(to[:15], append([]string{"inserted"}, to[15:]...)...)
+
+	diff := lessondiff.Markdown(strings.Join(from, "\n")+"\n", strings.Join(to, "\n")+"\n")
+	assert.Equal(t, 2, diff.Added)
+	assert.Equal(t, 1, diff.Removed)
+	require.Len(t, diff.Hunks, 2, "changes far apart get their own hunks")
+
+	first := diff.Hunks[0]
+	assert.Equal(t, 1, first.FromStart)
+	assert.Equal(t, 5, first.FromLines, "the change and 3 lines of context after it")
+	assert.Equal(t, []lessondiff.Line{
+		{Op: lessondiff.OpContext, Text: "line a"},
+		{Op: lessondiff.OpRemove, Text: "line b"},
+		{Op: lessondiff.OpAdd, Text: "changed b"},
+		{Op: lessondiff.OpContext, Text: "line c"},
+		{Op: lessondiff.OpContext, Text: "line d"},
+		{Op: lessondiff.OpContext, Text: "line e"},
+	}, first.Lines)
+
+	second := diff.Hunks[1]
+	assert.Equal(t, 13, second.FromStart)
+	assert.Equal(t, 13, second.ToStart)
+	assert.Equal(t, 6, second.FromLines)
+	assert.Equal(t, 7, second.ToLines)
+	assert.Equal(t, lessondiff.Line{Op: lessondiff.OpAdd, Text: "inserted"}, second.Lines[3])
+
+	// Changes within reach of each other's context share a hunk
+	to = append([]string{}, from...)
+	to[5], to[10] = "five", "ten"
+	diff = lessondiff.Markdown(strings.Join(from, "\n"), strings.Join(to, "\n"))
+	assert.Len(t, diff.Hunks, 1)
+
+	same := lessondiff.Markdown("# Title\n", "# Title\n")
+	assert.Zero(t, same.Added)
+	assert.Empty(t, same.Hunks)
+
+	created := lessondiff.Markdown("", "# Title\nBody")
+	assert.Equal(t, 2, created.Added)
+	require.Len(t, created.Hunks, 1)
+	assert.Equal(t, 0, created.Hunks[0].FromLines)
+}
+
+// TestSectionDiff tests comparing the sections of structured lessons
+func TestSectionDiff(t *testing.T) {
+	from := json.RawMessage(`{"summary": "Old", "teach": {"steps": ["a"], "overview": "o"}, "metadata": {"title": "T"}, "notes": 1}`)
+	to := json.RawMessage(`{"metadata": {"title": "T"}, "teach": {"overview": "o", "steps": ["a"]}, "summary": "New", "accessibility": {"plain_language_summary": "p"}, "artifacts": null}`)
+
+	sections := lessondiff.Sections(from, to)
+	var changes []string
+	for _, s := range sections {
+		changes = append(changes, s.Name+":"+s.Change)
+	}
+	assert.Equal(t, []string{"metadata:unchanged", "teach:unchanged", "summary:changed", "accessibility:added", "notes:removed"}, changes,
+		"sections in lesson order, key order and spacing ignored, null sections absent")
+	assert.JSONEq(t, `"Old"`, string(sections[2].Before))
+	assert.JSONEq(t, `"New"`, string(sections[2].After))
+	assert.Empty(t, sections[0].Before, "unchanged sections are not repeated")
+
+	assert.Empty(t, lessondiff.Sections(nil, json.RawMessage(`"not an object"`)))
+}
+
+var lessonVersionColumns = []string{"lesson_id", "version", "source", "created_by", "created_at", "content_markdown", "metadata"}
+
+// TestDiffLessonVersionsHandler tests GET /ngs/lessons/:id/versions/:a/diff/:b
+func TestDiffLessonVersionsHandler(t *testing.T) {
+	lessonID := uuid.New()
+	db := testsupport.RowsDB(lessonVersionColumns, []driver.Value{
+		lessonID.String(), int64(2), "generated", nil, testsupport.Epoch, "# Noticing", []byte(`{"summary": "s"}`),
+	})
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/lessons/:id/versions/:a/diff/:b", handlers.NewLessonVersionHandler(services.NewLessonVersionService(db)).DiffLessonVersions)
+
+	get := func(path, role string) (int, map[string]json.RawMessage) {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-User-Id", uuid.NewString())
+		req.Header.Set("X-User-Role", role)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var body map[string]json.RawMessage
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+
+	path := "/ngs/lessons/" + lessonID.String() + "/versions/1/diff/2"
+	status, body := get(path, "educator")
+	require.Equal(t, fiber.StatusOK, status)
+	assert.JSONEq(t, `{"added": 0, "removed": 0, "hunks": []}`, string(body["markdown"]))
+	assert.JSONEq(t, `[{"name": "summary", "change": "unchanged"}]`, string(body["sections"]))
+	var from map[string]interface{}
+	require.NoError(t, json.Unmarshal(body["from"], &from))
+	assert.Equal(t, "generated", from["source"])
+	assert.NotContains(t, from, "content_markdown", "content is only in the diff")
+
+	status, _ = get(path, "student")
+	assert.Equal(t, fiber.StatusForbidden, status)
+	status, _ = get("/ngs/lessons/"+lessonID.String()+"/versions/x/diff/2", "admin")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+
+	app = fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/lessons/:id/versions/:a/diff/:b", handlers.NewLessonVersionHandler(services.NewLessonVersionService(testsupport.RowsDB(lessonVersionColumns))).DiffLessonVersions)
+	status, _ = get(path, "admin")
+	assert.Equal(t, fiber.StatusNotFound, status)
+}
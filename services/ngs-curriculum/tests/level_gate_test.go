@@ -0,0 +1,174 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/jobs"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lockedLessonRow is a level 24 lesson with content, above the user's current level
+func lockedLessonRow(id uuid.UUID) []driver.Value {
+	return []driver.Value{
+		id.String(), int64(24), "Agent swarms", "Coordinating many agents", int64(1), "tutorial",
+		"# Swarms", "Core lesson", "Practice",
+		"Reflect", "Swarm agent", int64(50),
+		int64(20), nil, nil, true,
+		nil, nil, nil, "adult",
+		false, nil, nil, true,
+	}
+}
+
+// TestLevelGatedLessons tests that lessons above the user's level are refused, or previewed without content
+func TestLevelGatedLessons(t *testing.T) {
+	lessonID := uuid.New()
+	lessonService := services.NewLessonService(testsupport.RowsDB(lessonWithCompletionColumns, lockedLessonRow(lessonID)))
+	lessonHandler := handlers.NewLessonHandler(lessonService, &testsupport.MockIntelligence{})
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/levels/:level/lessons", lessonHandler.GetLessonsByLevel)
+	app.Get("/ngs/lessons/:id", lessonHandler.GetLesson)
+	app.Get("/ngs/lessons/:id/content", lessonHandler.GetLessonContent)
+	get := func(path string) (int, []byte) {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-User-Id", uuid.NewString())
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var body json.RawMessage
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp.StatusCode, body
+	}
+
+	for _, path := range []string{"/ngs/levels/24/lessons", "/ngs/lessons/" + lessonID.String(), "/ngs/lessons/" + lessonID.String() + "/content"} {
+		status, body := get(path)
+		assert.Equal(t, fiber.StatusForbidden, status, path)
+		assert.Contains(t, string(body), "level_locked", path)
+	}
+
+	status, body := get("/ngs/levels/24/lessons?preview=true")
+	require.Equal(t, fiber.StatusOK, status, string(body))
+	var list struct {
+		Lessons []models.LessonWithCompletion `json:"lessons"`
+	}
+	require.NoError(t, json.Unmarshal(body, &list))
+	require.Len(t, list.Lessons, 1)
+	assert.True(t, list.Lessons[0].Locked)
+	assert.Equal(t, "Agent swarms", list.Lessons[0].Title)
+	assert.Empty(t, list.Lessons[0].ContentMarkdown)
+	assert.Empty(t, list.Lessons[0].CoreLesson)
+
+	status, body = get("/ngs/lessons/" + lessonID.String() + "?preview=true")
+	require.Equal(t, fiber.StatusOK, status, string(body))
+	var lesson models.LessonWithCompletion
+	require.NoError(t, json.Unmarshal(body, &lesson))
+	assert.True(t, lesson.Locked)
+	assert.Equal(t, "Coordinating many agents", lesson.Description)
+	assert.Empty(t, lesson.HumanPractice)
+	assert.Empty(t, lesson.ReflectionPrompt)
+
+	status, _ = get("/ngs/lessons/" + lessonID.String() + "/content?preview=true")
+	assert.Equal(t, fiber.StatusForbidden, status, "content is never previewed")
+}
+
+// TestLevelGatedCompletion tests that a lesson above the user's level cannot be completed
+func TestLevelGatedCompletion(t *testing.T) {
+	db := testsupport.RowsDB([]string{"id", "level_id", "title", "xp_reward", "completion_criteria", "locked"},
+		[]driver.Value{uuid.NewString(), int64(24), "Agent swarms", int64(50), []byte(`{}`), true})
+	lessonHandler := handlers.NewLessonHandler(services.NewLessonService(db), &testsupport.MockIntelligence{})
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Post("/ngs/lessons/:id/complete", lessonHandler.CompleteLessonHandler)
+
+	status, body := postJSON(t, app, "/ngs/lessons/"+uuid.NewString()+"/complete", `{"reflection_text": "Done"}`)
+	assert.Equal(t, fiber.StatusForbidden, status)
+	assert.Equal(t, "level_locked", body["error"])
+}
+
+// TestLevelGatedLegacyCompletion tests that the legacy completion route applies the level gate of
+// the lesson named in its body
+func TestLevelGatedLegacyCompletion(t *testing.T) {
+	db := testsupport.RowsDB([]string{"id", "level_id", "title", "xp_reward", "completion_criteria", "locked"},
+		[]driver.Value{uuid.NewString(), int64(24), "Agent swarms", int64(50), []byte(`{}`), true})
+	lessonHandler := handlers.NewLessonHandler(services.NewLessonService(db), &testsupport.MockIntelligence{})
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Post("/ngs/complete-lesson", lessonHandler.CompleteLegacyLesson)
+
+	status, body := postJSON(t, app, "/ngs/complete-lesson", `{"lesson_id": "`+uuid.NewString()+`", "score": 100}`)
+	assert.Equal(t, fiber.StatusForbidden, status)
+	assert.Equal(t, "level_locked", body["error"])
+
+	status, _ = postJSON(t, app, "/ngs/complete-lesson", `{"score": 100}`)
+	assert.Equal(t, fiber.StatusBadRequest, status, "lesson_id is required")
+}
+
+// TestLevelGatedChat tests that the lesson tutor refuses a lesson above the user's level before
+// asking the Intelligence service
+func TestLevelGatedChat(t *testing.T) {
+	lessonID := uuid.New()
+	mock := &testsupport.MockIntelligence{StreamChunks: []string{"Swarms"}}
+	lessonService := services.NewLessonService(testsupport.RowsDB(lessonWithCompletionColumns, lockedLessonRow(lessonID)))
+	lessonHandler := handlers.NewLessonHandler(lessonService, mock)
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Post("/ngs/lessons/:id/chat", lessonHandler.SendEducatorChatMessage)
+	app.Post("/ngs/lessons/:id/chat/stream", lessonHandler.StreamEducatorChatMessage)
+
+	for _, path := range []string{"/chat", "/chat/stream"} {
+		status, body := postJSON(t, app, "/ngs/lessons/"+lessonID.String()+path, `{"message": "How do swarms vote?"}`)
+		assert.Equal(t, fiber.StatusForbidden, status, path)
+		assert.Equal(t, "level_locked", body["error"], path)
+	}
+	assert.Empty(t, mock.ChatRequests)
+}
+
+// TestLevelGatedMedia tests that media, audio and search keep a lesson above the user's level out
+// of reach
+func TestLevelGatedMedia(t *testing.T) {
+	db := testsupport.QueryDB(
+		testsupport.Query{Match: "NOT l.level_id > COALESCE"},
+		testsupport.Query{Match: "websearch_to_tsquery",
+			Columns: []string{"id", "level_id", "title", "description", "source", "snippet", "rank"},
+			Rows:    [][]driver.Value{{uuid.NewString(), int64(24), "Agent swarms", "", "lesson", "Swarm <b>voting</b>", 0.5}}},
+		testsupport.Query{Match: "content_markdown, COALESCE(content_version", Columns: []string{"content_markdown", "content_version", "locked"},
+			Rows: [][]driver.Value{{"# Swarms", int64(1), true}}},
+		testsupport.Query{Match: "user_progress", Columns: []string{"locked"}, Rows: [][]driver.Value{{true}}},
+	)
+	jobService := services.NewJobService(db, jobs.New(jobs.Settings{Workers: 1, MaxWait: time.Minute}), services.SystemClock{})
+	mediaService := services.NewMediaService(db, nil, jobService)
+
+	results, err := mediaService.Search(uuid.New(), "voting", 10)
+	require.NoError(t, err)
+	assert.Empty(t, results.Results, "locked lessons are left out of search")
+
+	mediaHandler := handlers.NewMediaHandler(mediaService)
+	audioHandler := handlers.NewAudioHandler(services.NewAudioService(db, testsupport.Config(), nil, services.SystemClock{}))
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/lessons/:id/media", mediaHandler.ListLessonMedia)
+	app.Get("/ngs/lessons/:id/audio", audioHandler.GetLessonAudio)
+
+	for _, path := range []string{"/media", "/audio"} {
+		req := httptest.NewRequest("GET", "/ngs/lessons/"+uuid.NewString()+path, nil)
+		req.Header.Set("X-User-Id", uuid.NewString())
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, fiber.StatusForbidden, resp.StatusCode, path)
+		assert.Equal(t, "level_locked", body["error"], path)
+	}
+}
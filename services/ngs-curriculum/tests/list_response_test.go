@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getList requests a list endpoint and decodes its body
+func getList(t *testing.T, app *fiber.App, path string) map[string]json.RawMessage {
+	t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	req.Header.Set("X-User-Id", uuid.New().String())
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var body map[string]json.RawMessage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return body
+}
+
+// TestListResponses tests the list contract: empty arrays instead of null, count and has_more
+func TestListResponses(t *testing.T) {
+	t.Run("Empty list is an array", func(t *testing.T) {
+		challengeHandler := handlers.NewChallengeHandler(services.NewChallengeService(testsupport.RowsDB(challengeColumns), progressConfig(), nil))
+		app := fiber.New()
+		app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+		app.Get("/ngs/levels/:level/challenges", challengeHandler.GetChallengesByLevel)
+
+		body := getList(t, app, "/ngs/levels/3/challenges")
+		assert.JSONEq(t, `[]`, string(body["challenges"]))
+		assert.JSONEq(t, `0`, string(body["count"]))
+		assert.JSONEq(t, `false`, string(body["has_more"]))
+		assert.JSONEq(t, `3`, string(body["level"]))
+	})
+
+	t.Run("Limited list reports has_more", func(t *testing.T) {
+		cfg := progressConfig()
+		progressService, store := testsupport.NewProgressService(cfg)
+		for _, totalXP := range []int{900, 600, 300} {
+			store.PutProgress(testsupport.Progress().User(uuid.New()).XP(totalXP, cfg.LevelUpXPThresholds).Build())
+		}
+		app := fiber.New()
+		app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+		app.Get("/ngs/leaderboard", handlers.NewHandler(progressService).GetLeaderboard)
+
+		body := getList(t, app, "/ngs/leaderboard?limit=2")
+		assert.JSONEq(t, `2`, string(body["count"]))
+		assert.JSONEq(t, `true`, string(body["has_more"]))
+
+		body = getList(t, app, "/ngs/leaderboard?limit=3")
+		assert.JSONEq(t, `3`, string(body["count"]))
+		assert.JSONEq(t, `false`, string(body["has_more"]))
+	})
+}
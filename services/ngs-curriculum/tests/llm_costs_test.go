@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/clients/intelligence"
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseLLMPricing tests reading LLM_PRICING and pricing tokens
+func TestParseLLMPricing(t *testing.T) {
+	pricing, err := services.ParseLLMPricing(" openai = 0.01, gemini=0.0035 ,ollama=0")
+	require.NoError(t, err)
+	assert.InDelta(t, 0.025, pricing.Cost("openai", 2500), 1e-9)
+	assert.InDelta(t, 0.0035, pricing.Cost("gemini", 1000), 1e-9)
+	assert.Zero(t, pricing.Cost("ollama", 5000))
+	assert.Zero(t, pricing.Cost("anthropic", 5000), "providers not priced cost nothing")
+	assert.Equal(t, "gemini=0.0035,ollama=0,openai=0.01", pricing.String())
+
+	pricing, err = services.ParseLLMPricing("none")
+	require.NoError(t, err)
+	assert.Equal(t, "none", pricing.String())
+
+	for _, spec := range []string{"openai", "=0.01", "openai=cheap", "openai=-1", "openai=0.01,openai=0.02"} {
+		_, err := services.ParseLLMPricing(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+// TestParseDayRange tests the day range of the LLM cost report
+func TestParseDayRange(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	start, end, err := services.ParseDayRange("", "", now)
+	require.NoError(t, err)
+	assert.Equal(t, "2026-09-17", start.Format("2006-01-02"))
+	assert.Equal(t, "2026-10-16", end.Format("2006-01-02"))
+
+	start, end, err = services.ParseDayRange("2026-10-01", "2026-10-01", now)
+	require.NoError(t, err)
+	assert.True(t, start.Equal(end), "a range may be one day")
+
+	for _, r := range [][2]string{{"2026-10-02", "2026-10-01"}, {"10/01/2026", ""}, {"2025-01-01", "2026-10-01"}} {
+		_, _, err := services.ParseDayRange(r[0], r[1], now)
+		assert.ErrorIs(t, err, services.ErrInvalidDayRange, r)
+	}
+}
+
+// TestIntelligenceUsageRecorder tests that answered requests report their tokens with the
+// organization, operation and provider
+func TestIntelligenceUsageRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/educator/generate":
+			w.Write([]byte(`{"content_markdown": "# Lesson", "tokens_used": 1200}`))
+		case "/educator/chat/message":
+			w.Write([]byte(`{"response": "Hi", "tokens_used": 80, "provider": "ollama"}`))
+		default:
+			w.Write([]byte(`{"transcript": "...", "tokens_used": 0}`))
+		}
+	}))
+	defer server.Close()
+
+	policy, err := intelligence.ParseRoutingPolicy("lesson=openai")
+	require.NoError(t, err)
+	client := intelligence.NewClient(server.URL, func() string { return "token" })
+	client.SetRoutingPolicy(policy)
+	var usage []intelligence.Usage
+	client.SetUsageRecorder(func(u intelligence.Usage) { usage = append(usage, u) })
+
+	ctx := intelligence.WithOrg(context.Background(), "acme")
+	_, err = client.GenerateLesson(ctx, intelligence.GenerateLessonRequest{}, "u", "e", "student")
+	require.NoError(t, err)
+	_, err = client.SendEducatorChatMessage(context.Background(), intelligence.EducatorChatRequest{}, "u", "e", "student")
+	require.NoError(t, err)
+	_, err = client.TranscribeMedia(ctx, intelligence.TranscribeMediaRequest{}, "u", "e", "student")
+	require.NoError(t, err)
+
+	assert.Equal(t, []intelligence.Usage{
+		{OrgID: "acme", Operation: intelligence.OperationLesson, Provider: "openai", Tokens: 1200},
+		{Operation: intelligence.OperationChat, Provider: "ollama", Tokens: 80},
+	}, usage, "requests that used no tokens are not reported")
+}
+
+// TestListLLMCosts tests the admin LLM cost report folding providers into each day and organization
+func TestListLLMCosts(t *testing.T) {
+	day := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	db := testsupport.RowsDB(
+		[]string{"day", "org_id", "provider", "tokens", "cost_usd"},
+		[]driver.Value{day, "acme", "gemini", int64(2000), 0.007},
+		[]driver.Value{day, "acme", "openai", int64(1000), 0.01},
+		[]driver.Value{day, "", "ollama", int64(5000), 0.0},
+		[]driver.Value{day.AddDate(0, 0, -1), "acme", "openai", int64(500), 0.005},
+	)
+	clock := testsupport.NewFakeClock(day.Add(9 * time.Hour))
+	llmCosts := services.NewLLMCostService(db, testsupport.Config(), clock, services.LLMPricing{})
+
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/admin/llm-costs", handlers.NewLLMCostHandler(llmCosts, clock).ListLLMCosts)
+	get := func(query, role string) *http.Response {
+		req := httptest.NewRequest("GET", "/ngs/admin/llm-costs"+query, nil)
+		req.Header.Set("X-User-Id", uuid.New().String())
+		req.Header.Set("X-User-Role", role)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	assert.Equal(t, fiber.StatusForbidden, get("", "student").StatusCode)
+	assert.Equal(t, fiber.StatusBadRequest, get("?from=2026-10-17&to=2026-10-16", "admin").StatusCode)
+	assert.Equal(t, fiber.StatusBadRequest, get("?org_id=acme%20corp", "admin").StatusCode)
+
+	resp := get("?limit=2", "admin")
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var body struct {
+		Costs        []models.LLMDailyCost `json:"costs"`
+		HasMore      bool                  `json:"has_more"`
+		From         string                `json:"from"`
+		To           string                `json:"to"`
+		TotalCostUSD float64               `json:"total_cost_usd"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "2026-09-17", body.From)
+	assert.Equal(t, "2026-10-16", body.To)
+	require.Len(t, body.Costs, 2)
+	assert.True(t, body.HasMore)
+
+	acme := body.Costs[0]
+	assert.Equal(t, "2026-10-16", acme.Day)
+	assert.Equal(t, "acme", acme.OrgID)
+	assert.Equal(t, int64(3000), acme.Tokens)
+	assert.InDelta(t, 0.017, acme.CostUSD, 1e-9)
+	assert.Equal(t, int64(2000), acme.ByProvider["gemini"].Tokens)
+	assert.Equal(t, "", body.Costs[1].OrgID, "requests made for no organization")
+	assert.InDelta(t, 0.017, body.TotalCostUSD, 1e-9)
+}
+
+// TestLLMBudgetAlertedOnce tests that a budget already alerted today is not posted again
+func TestLLMBudgetAlertedOnce(t *testing.T) {
+	var posts atomic.Int32
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts.Add(1)
+	}))
+	defer webhook.Close()
+
+	cfg := testsupport.Config()
+	cfg.LLMDailyBudgetUSD = 10
+	cfg.LLMOrgDailyBudgetUSD = 5
+	cfg.LLMBudgetWebhookURL = webhook.URL
+	// Today's spend is past both budgets, and the alert rows already exist
+	db := testsupport.RowsDB([]string{"org_id", "sum"}, []driver.Value{"acme", 12.5})
+	clock := testsupport.NewFakeClock(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC))
+	llmCosts := services.NewLLMCostService(db, cfg, clock, services.LLMPricing{"openai": 0.01})
+
+	llmCosts.Record(intelligence.Usage{OrgID: "acme", Operation: intelligence.OperationLesson, Provider: "openai", Tokens: 1000})
+	require.NoError(t, llmCosts.Flush())
+	require.NoError(t, llmCosts.CheckBudgets(context.Background()))
+	assert.Zero(t, posts.Load())
+}
@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"database/sql"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPoolWait tests the average connection wait between two pool samples
+func TestPoolWait(t *testing.T) {
+	previous := sql.DBStats{WaitCount: 10, WaitDuration: time.Second}
+
+	assert.Zero(t, services.PoolWait(previous, previous), "nobody waited")
+	assert.Equal(t, 200*time.Millisecond, services.PoolWait(previous, sql.DBStats{WaitCount: 14, WaitDuration: 1800 * time.Millisecond}))
+	assert.Zero(t, services.PoolWait(previous, sql.DBStats{}), "a reopened pool starts from zero")
+}
+
+// TestLoadShedding tests shedding low-priority requests while a job queue is backed up
+func TestLoadShedding(t *testing.T) {
+	cfg := progressConfig()
+	cfg.LoadShedDBWaitMs = 100
+	cfg.LoadShedQueueDepth = 3
+	clock := testsupport.NewFakeClock(time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC))
+
+	depth := 0
+	shedder := services.NewLoadShedder(testsupport.RowsDB(nil), cfg, clock)
+	shedder.AddQueue("transcription", func() int { return depth })
+
+	app := fiber.New()
+	loadShedHandler := handlers.NewLoadShedHandler(shedder)
+	app.Get("/ngs/leaderboard", loadShedHandler.LowPriority, func(c *fiber.Ctx) error { return c.SendString("board") })
+	app.Post("/ngs/award-xp", func(c *fiber.Ctx) error { return c.SendString("awarded") })
+	status := func(method, path string) int {
+		resp, err := app.Test(httptest.NewRequest(method, path, nil))
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	depth = 2
+	sample := shedder.Sample()
+	assert.False(t, sample.Shedding)
+	assert.Equal(t, map[string]int{"transcription": 2}, sample.QueueDepths)
+	assert.Equal(t, fiber.StatusOK, status("GET", "/ngs/leaderboard"))
+
+	depth = 3
+	sample = shedder.Sample()
+	assert.True(t, sample.Shedding)
+	assert.Equal(t, []string{"queue:transcription"}, sample.Reasons)
+	require.NotNil(t, sample.SheddingSince)
+	assert.Equal(t, clock.Now(), *sample.SheddingSince)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/ngs/leaderboard", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "5", resp.Header.Get(fiber.HeaderRetryAfter))
+	assert.Equal(t, fiber.StatusOK, status("POST", "/ngs/award-xp"), "XP awards are never shed")
+
+	// Shedding holds for a few seconds after the queue drains
+	since := clock.Now()
+	depth = 0
+	clock.Advance(2 * time.Second)
+	sample = shedder.Sample()
+	assert.True(t, sample.Shedding)
+	assert.Empty(t, sample.Reasons)
+	assert.Equal(t, since, *sample.SheddingSince)
+
+	clock.Advance(3 * time.Second)
+	sample = shedder.Sample()
+	assert.False(t, sample.Shedding)
+	assert.Nil(t, sample.SheddingSince)
+	assert.Equal(t, sample, shedder.Status())
+	assert.Equal(t, fiber.StatusOK, status("GET", "/ngs/leaderboard"))
+}
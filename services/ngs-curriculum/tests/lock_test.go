@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"context"
+	"database/sql/driver"
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/testsupport"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTryXactLock tests that a transaction lock reports whether Postgres granted it
+func TestTryXactLock(t *testing.T) {
+	for _, granted := range []bool{true, false} {
+		db := testsupport.RowsDB([]string{"locked"}, []driver.Value{granted})
+		tx, err := db.Begin()
+		require.NoError(t, err)
+
+		locked, err := database.TryXactLock(tx, "ngs_test:table")
+		require.NoError(t, err)
+		assert.Equal(t, granted, locked)
+		tx.Rollback()
+	}
+}
+
+// TestSessionLock tests taking, missing and releasing a session lock
+func TestSessionLock(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Acquired", func(t *testing.T) {
+		db := testsupport.RowsDB([]string{"locked"}, []driver.Value{true})
+		lock, err := db.TryLock(ctx, "ngs_test")
+		require.NoError(t, err)
+		require.NotNil(t, lock)
+		assert.NoError(t, lock.Context().Err())
+
+		require.NoError(t, lock.Release())
+		assert.Error(t, lock.Context().Err(), "releasing should cancel the lock's context")
+		assert.NoError(t, lock.Release(), "a second release should do nothing")
+	})
+
+	t.Run("Busy", func(t *testing.T) {
+		db := testsupport.RowsDB([]string{"locked"}, []driver.Value{false})
+		lock, err := db.TryLock(ctx, "ngs_test")
+		require.NoError(t, err)
+		assert.Nil(t, lock)
+	})
+
+	t.Run("Acquire waits for the lock", func(t *testing.T) {
+		db := testsupport.RowsDB([]string{"locked"}, []driver.Value{true})
+		lock, err := db.AcquireLock(ctx, "ngs_test")
+		require.NoError(t, err)
+		require.NotNil(t, lock)
+		assert.NoError(t, lock.Release())
+	})
+}
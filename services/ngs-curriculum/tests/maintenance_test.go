@@ -0,0 +1,35 @@
+package tests
+
+import (
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMaintenanceExempt tests which paths stay available during maintenance
+func TestMaintenanceExempt(t *testing.T) {
+	for _, path := range []string{"/", "/health", "/metrics", "/ngs/admin/maintenance", "/ngs/admin/retention", "/ngs/internal/account-events"} {
+		assert.True(t, services.MaintenanceExempt(path), path)
+	}
+	for _, path := range []string{"/ngs/progress", "/ngs/lessons/completions", "/ngs/administration", "/healthz"} {
+		assert.False(t, services.MaintenanceExempt(path), path)
+	}
+}
+
+// TestValidateMaintenanceRequest tests limits on the maintenance message and Retry-After
+func TestValidateMaintenanceRequest(t *testing.T) {
+	seconds := func(n int) *int { return &n }
+
+	assert.NoError(t, services.ValidateMaintenanceRequest(models.SetMaintenanceRequest{Enabled: true}))
+	assert.NoError(t, services.ValidateMaintenanceRequest(models.SetMaintenanceRequest{Enabled: true, Message: "Back soon", RetryAfterSeconds: seconds(600)}))
+
+	for _, req := range []models.SetMaintenanceRequest{
+		{Message: string(make([]byte, 501))},
+		{RetryAfterSeconds: seconds(0)},
+		{RetryAfterSeconds: seconds(24*60*60 + 1)},
+	} {
+		assert.ErrorIs(t, services.ValidateMaintenanceRequest(req), services.ErrInvalidMaintenance)
+	}
+}
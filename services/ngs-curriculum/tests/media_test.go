@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"noble-ngs-curriculum/internal/clients/intelligence"
+	"noble-ngs-curriculum/internal/jobs"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateMediaRequest tests lesson media attachment validation
+func TestValidateMediaRequest(t *testing.T) {
+	strPtr := func(s string) *string { return &s }
+
+	t.Run("Accepts an https URL with optional title and language", func(t *testing.T) {
+		assert.NoError(t, services.ValidateMediaRequest(models.AttachMediaRequest{URL: "https://cdn.example.com/lesson-1.mp4"}))
+		assert.NoError(t, services.ValidateMediaRequest(models.AttachMediaRequest{
+			URL:      "https://cdn.example.com/lesson-1.mp4",
+			Title:    strPtr("Part 1"),
+			Language: strPtr("en"),
+		}))
+	})
+
+	t.Run("Rejects non-https URLs", func(t *testing.T) {
+		for _, u := range []string{"", "http://cdn.example.com/a.mp4", "file:///etc/passwd", "https://"} {
+			err := services.ValidateMediaRequest(models.AttachMediaRequest{URL: u})
+			assert.True(t, errors.Is(err, services.ErrInvalidMedia), u)
+		}
+	})
+
+	t.Run("Rejects overlong URLs and bad languages", func(t *testing.T) {
+		long := "https://cdn.example.com/" + strings.Repeat("a", 2048)
+		assert.True(t, errors.Is(services.ValidateMediaRequest(models.AttachMediaRequest{URL: long}), services.ErrInvalidMedia))
+
+		err := services.ValidateMediaRequest(models.AttachMediaRequest{URL: "https://cdn.example.com/a.mp4", Language: strPtr("english")})
+		assert.True(t, errors.Is(err, services.ErrInvalidMedia))
+	})
+}
+
+// TestChunkLessonText tests splitting lesson text into passages for tutor chat
+func TestChunkLessonText(t *testing.T) {
+	markdown := "Intro line.\n\n# Loops\n\nA for loop repeats.\n\n```python\nfor i in range(3):\n\n    print(i)\n```\n\n## While\n\nA while loop checks first.\n"
+	chunks := services.ChunkLessonText("lesson", markdown, 1500)
+	assert.Equal(t, []models.LessonContextChunk{
+		{Source: "lesson", Content: "Intro line."},
+		{Source: "lesson", Heading: "Loops", Content: "A for loop repeats.\n\n```python\nfor i in range(3):\n\n    print(i)\n```"},
+		{Source: "lesson", Heading: "While", Content: "A while loop checks first."},
+	}, chunks, "code blocks stay whole")
+
+	long := strings.Repeat("word ", 100)
+	chunks = services.ChunkLessonText("transcript", "First paragraph.\n\n"+long, 120)
+	assert.Equal(t, "First paragraph.", chunks[0].Content, "paragraphs that do not fit start a new passage")
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, len(chunk.Content), 120)
+		assert.Equal(t, "transcript", chunk.Source)
+	}
+	assert.Len(t, chunks, 6)
+
+	assert.Empty(t, services.ChunkLessonText("lesson", "  \n\n", 1500))
+}
+
+// TestSelectLessonContext tests picking the best matching passages
+func TestSelectLessonContext(t *testing.T) {
+	chunks := []models.LessonContextChunk{{Content: "a"}, {Content: "b"}, {Content: "c"}, {Content: "d"}}
+
+	picked := services.SelectLessonContext(chunks, []float64{0.1, 0, 0.5, 0.3}, 2)
+	assert.Equal(t, []models.LessonContextChunk{{Content: "c", Rank: 0.5}, {Content: "d", Rank: 0.3}}, picked)
+
+	picked = services.SelectLessonContext(chunks, []float64{0, 0, 0, 0}, 2)
+	assert.Equal(t, []models.LessonContextChunk{{Content: "a"}, {Content: "b"}}, picked, "without a match the lesson opening is sent")
+}
+
+// TestMediaTranscriptionDrain tests that a drain leaves queued transcriptions unstarted and
+// cancels a running one when it runs out of time, which fails it so it can be retried
+func TestMediaTranscriptionDrain(t *testing.T) {
+	started := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req intelligence.TranscribeMediaRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		started <- req.MediaURL
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	queue := jobs.New(jobs.Settings{Workers: 1, MaxWait: time.Minute})
+	jobService := services.NewJobService(testsupport.QueryDB(), queue, services.SystemClock{})
+	media := services.NewMediaService(testsupport.QueryDB(), intelligence.NewClient(server.URL, func() string { return "token" }), jobService)
+	results := make(chan error, 10)
+	jobService.Register(services.JobTranscription, jobs.ClassGeneration, func(ctx context.Context, payload json.RawMessage) error {
+		err := media.RunTranscription(ctx, payload)
+		results <- err
+		return err
+	})
+
+	for _, mediaURL := range []string{"https://example.com/running.mp4", "https://example.com/queued.mp4"} {
+		require.NoError(t, jobService.Submit(services.JobTranscription, mediaURL, map[string]interface{}{
+			"media_id": uuid.New(), "media_url": mediaURL, "lesson_title": "Loops",
+		}))
+	}
+	select {
+	case mediaURL := <-started:
+		assert.Equal(t, "https://example.com/running.mp4", mediaURL)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no transcription started")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, queue.Drain(ctx), context.DeadlineExceeded)
+
+	// Drain returns once the running transcription has stopped, so its result is in
+	require.Len(t, results, 1, "the queued transcription never ran")
+	assert.ErrorIs(t, <-results, context.Canceled, "the running transcription is cancelled")
+	assert.Empty(t, started, "the queued transcription never reached the intelligence service")
+	assert.Zero(t, queue.Depth())
+}
@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMeteringInputs tests X-Org-Id and month validation
+func TestMeteringInputs(t *testing.T) {
+	for _, orgID := range []string{"acme", "org_42", "9f0c1f9e-2f55-4a8e-9d0a-1f7c7c1f0a11"} {
+		assert.NoError(t, services.ValidateOrgID(orgID), orgID)
+	}
+	for _, orgID := range []string{"", "acme corp", "acme/../x", string(make([]byte, 65))} {
+		assert.True(t, errors.Is(services.ValidateOrgID(orgID), services.ErrInvalidOrgID), orgID)
+	}
+
+	now := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	month, err := services.ParseMonth("", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), month)
+	month, err = services.ParseMonth("2026-02", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), month)
+	_, err = services.ParseMonth("2026-13", now)
+	assert.True(t, errors.Is(err, services.ErrInvalidMonth))
+}
+
+// TestMonthlyUsage tests the monthly rollup of an organization's meters
+func TestMonthlyUsage(t *testing.T) {
+	cfg := testsupport.Config()
+	cfg.OrgAPICallsPerMonth = 1000
+	db := testsupport.RowsDB(
+		[]string{"org_id", "api_calls", "llm_tokens", "sandbox_ms", "storage_bytes"},
+		[]driver.Value{"acme", int64(420), int64(12000), int64(90000), int64(2048)},
+	)
+	metering := services.NewMeteringService(db, cfg, services.SystemClock{})
+
+	usage, err := metering.OrgMonthlyUsage(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), "acme")
+	require.NoError(t, err)
+	assert.Equal(t, "2026-10", usage.Month)
+	assert.Equal(t, int64(420), usage.APICalls)
+	assert.Equal(t, int64(12000), usage.LLMTokens)
+	assert.Equal(t, 1.5, usage.SandboxMinutes)
+	assert.Equal(t, int64(2048), usage.StorageBytes)
+	require.NotNil(t, usage.APICallsLimit)
+	assert.Equal(t, int64(1000), *usage.APICallsLimit)
+
+	empty := services.NewMeteringService(testsupport.RowsDB(nil), cfg, services.SystemClock{})
+	usage, err = empty.OrgMonthlyUsage(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), "beta")
+	require.NoError(t, err)
+	assert.Equal(t, "beta", usage.OrgID)
+	assert.Zero(t, usage.APICalls)
+}
+
+// TestMeteringMiddleware tests metering and the monthly API call quota
+func TestMeteringMiddleware(t *testing.T) {
+	cfg := testsupport.Config()
+	cfg.OrgAPICallsPerMonth = 3
+	clock := testsupport.NewFakeClock(time.Date(2026, 10, 31, 23, 59, 0, 0, time.UTC))
+	// Two calls are already stored for the month
+	db := testsupport.RowsDB([]string{"sum"}, []driver.Value{int64(2)})
+	metering := services.NewMeteringService(db, cfg, clock)
+	meteringHandler := handlers.NewMeteringHandler(metering, clock)
+
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Use(meteringHandler.Middleware)
+	app.Get("/ngs/progress", func(c *fiber.Ctx) error { return c.SendString("ok") })
+	get := func(orgID string) *fiberResponse {
+		req := httptest.NewRequest("GET", "/ngs/progress", nil)
+		if orgID != "" {
+			req.Header.Set("X-Org-Id", orgID)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return &fiberResponse{status: resp.StatusCode, retryAfter: resp.Header.Get("Retry-After")}
+	}
+
+	assert.Equal(t, fiber.StatusOK, get("").status, "requests without an organization are not metered")
+	assert.Equal(t, fiber.StatusBadRequest, get("acme corp").status)
+
+	assert.Equal(t, fiber.StatusOK, get("acme").status)
+	refused := get("acme")
+	assert.Equal(t, fiber.StatusTooManyRequests, refused.status)
+	assert.Equal(t, "61", refused.retryAfter, "the quota resets at the start of next month")
+
+	// A flush re-reads the stored total, which here is still two calls
+	require.NoError(t, metering.Flush())
+	assert.Equal(t, fiber.StatusOK, get("acme").status)
+
+	clock.Advance(2 * time.Minute)
+	cfg.OrgAPICallsPerMonth = 0
+	assert.Equal(t, fiber.StatusOK, get("acme").status, "0 is unlimited")
+}
+
+type fiberResponse struct {
+	status     int
+	retryAfter string
+}
@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/mtls"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA issues certificates for the internal listener tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ngs test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key for name, usable by a server or client
+func (ca *testCA) issue(t *testing.T, name string, serial int64) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+// TestInternalListener tests that admin routes are only served over mutual TLS to allowed
+// clients, and hidden on the public listener
+func TestInternalListener(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, "ngs-curriculum", 2)
+	tlsConfig, err := mtls.ServerConfig(
+		writeFile(t, dir, "server.pem", serverCert),
+		writeFile(t, dir, "server.key", serverKey),
+		writeFile(t, dir, "ca.pem", ca.pem),
+		mtls.ParseAllowlist(" gateway, billing "),
+	)
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Use(handlers.InternalOnly("/ngs/admin/", "/ngs/internal/"))
+	app.Get("/ngs/admin/load", func(c *fiber.Ctx) error { return c.SendString(c.Locals("internal_client").(string)) })
+	app.Get("/ngs/progress", func(c *fiber.Ctx) error { return c.SendString("progress") })
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go app.Listener(tls.NewListener(ln, tlsConfig))
+	defer app.Shutdown()
+	internalURL := "https://" + ln.Addr().String()
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.pem)
+	clientAs := func(name string) *http.Client {
+		config := &tls.Config{RootCAs: roots}
+		if name != "" {
+			certPEM, keyPEM := ca.issue(t, name, 3)
+			cert, err := tls.X509KeyPair(certPEM, keyPEM)
+			require.NoError(t, err)
+			config.Certificates = []tls.Certificate{cert}
+		}
+		return &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{TLSClientConfig: config}}
+	}
+
+	t.Run("Allowed clients reach admin routes", func(t *testing.T) {
+		resp, err := clientAs("gateway").Get(internalURL + "/ngs/admin/load")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		body := make([]byte, 16)
+		n, _ := resp.Body.Read(body)
+		assert.Equal(t, "gateway", string(body[:n]))
+	})
+
+	t.Run("Clients without a certificate or off the allowlist are refused", func(t *testing.T) {
+		_, err := clientAs("").Get(internalURL + "/ngs/admin/load")
+		assert.Error(t, err)
+		_, err = clientAs("reports").Get(internalURL + "/ngs/admin/load")
+		assert.Error(t, err)
+	})
+
+	t.Run("The public listener hides admin routes", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/ngs/admin/load", nil))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+		resp, err = app.Test(httptest.NewRequest("GET", "/ngs/progress", nil))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("The certificate, key and client CA are required", func(t *testing.T) {
+		_, err := mtls.ServerConfig(filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key"), "", nil)
+		assert.Error(t, err)
+	})
+}
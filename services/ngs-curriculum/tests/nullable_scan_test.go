@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"noble-ngs-curriculum/internal/api"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var lessonWithCompletionColumns = []string{
+	"id", "level_id", "title", "description", "lesson_order", "lesson_type",
+	"content_markdown", "core_lesson", "human_practice",
+	"reflection_prompt", "agent_unlock", "xp_reward",
+	"estimated_minutes", "prerequisites", "metadata", "is_required",
+	"created_at", "updated_at", "completion_criteria", "min_age_band",
+	"completed", "completed_at", "score", "locked",
+}
+
+// nullLessonRow is a lessons row with every nullable column NULL and no completion, as a
+// content import may leave it
+func nullLessonRow(id uuid.UUID) []driver.Value {
+	return []driver.Value{
+		id.String(), int64(1), "Imported lesson", nil, int64(1), "tutorial",
+		nil, nil, nil,
+		nil, nil, nil,
+		nil, nil, nil, nil,
+		nil, nil, nil, "adult",
+		false, nil, nil, false,
+	}
+}
+
+// TestLessonScanWithNullColumns tests that lessons with NULL optional columns read as empty values
+func TestLessonScanWithNullColumns(t *testing.T) {
+	id := uuid.New()
+	db := testsupport.RowsDB(lessonWithCompletionColumns, nullLessonRow(id))
+	lessonService := services.NewLessonService(db)
+
+	t.Run("Lesson list", func(t *testing.T) {
+		lessons, err := lessonService.GetLessonsByLevel(1, uuid.New(), false, "", api.Page{})
+		require.NoError(t, err)
+		require.Len(t, lessons, 1)
+
+		l := lessons[0]
+		assert.Equal(t, id, l.ID)
+		assert.Empty(t, l.Description)
+		assert.Empty(t, l.ContentMarkdown)
+		assert.Empty(t, l.AgentUnlock)
+		assert.Zero(t, l.XPReward)
+		assert.Zero(t, l.EstimatedMinutes)
+		assert.True(t, l.IsRequired, "NULL is_required should default to required")
+		assert.Equal(t, json.RawMessage(`{}`), json.RawMessage(l.CompletionCriteria))
+		assert.False(t, l.Completed)
+		assert.True(t, l.CompletedAt.IsZero())
+	})
+
+	t.Run("Single lesson", func(t *testing.T) {
+		l, err := lessonService.GetLesson(id, uuid.New(), false)
+		require.NoError(t, err)
+		assert.Equal(t, "Imported lesson", l.Title)
+		assert.Empty(t, l.CoreLesson)
+		assert.Empty(t, l.HumanPractice)
+		assert.Empty(t, l.ReflectionPrompt)
+		assert.Empty(t, l.Prerequisites)
+	})
+}
+
+// TestLevelScanWithNullDescription tests that a level without a description reads as empty
+func TestLevelScanWithNullDescription(t *testing.T) {
+	db := testsupport.RowsDB(
+		[]string{"id", "level_number", "title", "description", "unlock_requirements", "xp_required"},
+		[]driver.Value{int64(1), int64(1), "Foundations", nil, []byte(`{}`), int64(0)},
+	)
+	progressService := services.NewProgressService(db, progressConfig(), services.SystemClock{})
+
+	level, err := progressService.GetLevel(1)
+	require.NoError(t, err)
+	assert.Equal(t, "Foundations", level.Title)
+	assert.Empty(t, level.Description)
+
+	levels, err := progressService.GetAllLevels()
+	require.NoError(t, err)
+	require.Len(t, levels, 1)
+	assert.Empty(t, levels[0].Description)
+}
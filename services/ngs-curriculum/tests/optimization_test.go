@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"net/http/httptest"
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// optimizedRun is a correct run of two cases taking runtimeMs in total and peaking at memoryKB
+func optimizedRun(runtimeMs float64, memoryKB int64) *models.TestResults {
+	return &models.TestResults{Tests: []models.TestCaseResult{
+		{Name: "small", Status: models.TestPassed, RuntimeMs: runtimeMs / 2, MemoryKB: memoryKB / 2},
+		{Name: "large", Status: models.TestPassed, RuntimeMs: runtimeMs / 2, MemoryKB: memoryKB},
+	}}
+}
+
+// TestScorePerformance tests scoring runtime and memory against the baselines
+func TestScorePerformance(t *testing.T) {
+	t.Run("Both baselines", func(t *testing.T) {
+		results := optimizedRun(200, 4096)
+		score := services.ScorePerformance(results, 100, 4096)
+		assert.Equal(t, 65, score, "half the baseline speed weighs 70%, matching memory 30%")
+		require.NotNil(t, results.Performance)
+		assert.Equal(t, 200.0, results.Performance.RuntimeMs)
+		assert.Equal(t, int64(4096), results.Performance.MemoryKB)
+		assert.Equal(t, 50, results.Performance.RuntimeScore)
+		assert.Equal(t, 100, results.Performance.MemoryScore)
+	})
+
+	t.Run("Faster than the baseline", func(t *testing.T) {
+		assert.Equal(t, 100, services.ScorePerformance(optimizedRun(50, 1024), 100, 4096))
+	})
+
+	t.Run("Runtime baseline only", func(t *testing.T) {
+		assert.Equal(t, 25, services.ScorePerformance(optimizedRun(400, 1<<20), 100, 0))
+	})
+
+	t.Run("No baselines", func(t *testing.T) {
+		assert.Equal(t, 100, services.ScorePerformance(optimizedRun(400, 1<<20), 0, 0))
+	})
+}
+
+// TestRuntimeLeaderboardRequiresOptimization tests that other challenge types have no runtime leaderboard
+func TestRuntimeLeaderboardRequiresOptimization(t *testing.T) {
+	id := uuid.New()
+	db := testsupport.RowsDB(challengeColumns, challengeRow(id, nil))
+	challengeService := services.NewChallengeService(db, progressConfig(), nil)
+
+	_, err := challengeService.GetRuntimeLeaderboard(id, 10)
+	assert.ErrorIs(t, err, services.ErrNotOptimizationChallenge)
+
+	app := fiber.New()
+	app.Get("/ngs/challenges/:id/leaderboard", handlers.NewChallengeHandler(challengeService).GetRuntimeLeaderboard)
+	resp, err := app.Test(httptest.NewRequest("GET", "/ngs/challenges/"+id.String()+"/leaderboard", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
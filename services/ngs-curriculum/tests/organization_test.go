@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOrgSettingsValidation tests checking an organization's defaults before they are saved
+func TestOrgSettingsValidation(t *testing.T) {
+	level := func(n int) *int { return &n }
+	tracks := func(t ...string) *[]string { return &t }
+	locale := func(l string) *string { return &l }
+
+	valid := []models.UpdateOrgSettingsRequest{
+		{},
+		{AgentUnlockLevel: level(8), EnabledTracks: tracks("core", "ethical_ai"), Locale: locale("pt-BR")},
+		{ClearAgentUnlockLevel: true, ClearEnabledTracks: true, ClearLocale: true},
+	}
+	for _, req := range valid {
+		assert.NoError(t, services.ValidateOrgSettingsUpdate(req, 24))
+	}
+
+	invalid := []models.UpdateOrgSettingsRequest{
+		{AgentUnlockLevel: level(0)},
+		{AgentUnlockLevel: level(25)},
+		{AgentUnlockLevel: level(5), ClearAgentUnlockLevel: true},
+		{EnabledTracks: tracks()},
+		{EnabledTracks: tracks("core", "basket_weaving")},
+		{EnabledTracks: tracks("core", "core")},
+		{Locale: locale("English")},
+	}
+	for _, req := range invalid {
+		assert.True(t, errors.Is(services.ValidateOrgSettingsUpdate(req, 24), services.ErrInvalidOrgSettings), "%+v", req)
+	}
+}
+
+// TestOrgHandlers tests the org-scoped admin endpoints
+func TestOrgHandlers(t *testing.T) {
+	adminID := uuid.New()
+	educatorID := uuid.New()
+	clock := testsupport.NewFakeClock(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC))
+
+	newApp := func(orgService *services.OrganizationService) *fiber.App {
+		cfg := testsupport.Config()
+		metering := services.NewMeteringService(testsupport.RowsDB(nil), cfg, clock)
+		h := handlers.NewOrgHandler(orgService, metering, clock)
+		app := fiber.New()
+		app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+		app.Get("/ngs/org/settings", h.GetSettings)
+		app.Put("/ngs/org/settings", h.UpdateSettings)
+		app.Post("/ngs/org/educators", h.InviteEducator)
+		app.Delete("/ngs/org/educators/:userId", h.RemoveEducator)
+		app.Post("/ngs/org/invitation/accept", h.AcceptInvitation)
+		app.Get("/ngs/org/usage", h.GetUsage)
+		return app
+	}
+	send := func(app *fiber.App, method, path, body string, userID uuid.UUID, role, orgID string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User-Id", userID.String())
+		req.Header.Set("X-User-Role", role)
+		if orgID != "" {
+			req.Header.Set("X-Org-Id", orgID)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	t.Run("Only org admins manage the organization", func(t *testing.T) {
+		app := newApp(services.NewOrganizationService(testsupport.RowsDB(nil), testsupport.Config(), clock))
+		invite := `{"user_id": "` + educatorID.String() + `"}`
+
+		assert.Equal(t, fiber.StatusForbidden, send(app, "POST", "/ngs/org/educators", invite, educatorID, "educator", "acme"))
+		assert.Equal(t, fiber.StatusForbidden, send(app, "PUT", "/ngs/org/settings", `{"locale": "fr"}`, educatorID, "student", "acme"))
+		assert.Equal(t, fiber.StatusForbidden, send(app, "GET", "/ngs/org/usage", "", educatorID, "student", "acme"))
+		assert.Equal(t, fiber.StatusBadRequest, send(app, "POST", "/ngs/org/educators", invite, adminID, "org_admin", ""))
+		assert.Equal(t, fiber.StatusBadRequest, send(app, "PUT", "/ngs/org/settings", `{"agent_unlock_level": 99}`, adminID, "org_admin", "acme"))
+		assert.Equal(t, fiber.StatusOK, send(app, "GET", "/ngs/org/usage?month=2026-09", "", adminID, "org_admin", "acme"))
+		assert.Equal(t, fiber.StatusOK, send(app, "GET", "/ngs/org/settings", "", educatorID, "student", "acme"), "members can read the defaults")
+	})
+
+	t.Run("Existing, missing and uninvited educators", func(t *testing.T) {
+		// Every insert conflicts, every delete misses and every invitation is missing
+		app := newApp(services.NewOrganizationService(testsupport.RowsDB(nil), testsupport.Config(), clock))
+
+		assert.Equal(t, fiber.StatusConflict, send(app, "POST", "/ngs/org/educators", `{"user_id": "`+educatorID.String()+`"}`, adminID, "org_admin", "acme"))
+		assert.Equal(t, fiber.StatusBadRequest, send(app, "POST", "/ngs/org/educators", `{"email": "ada@example.com"}`, adminID, "org_admin", "acme"))
+		assert.Equal(t, fiber.StatusNotFound, send(app, "DELETE", "/ngs/org/educators/"+educatorID.String(), "", adminID, "org_admin", "acme"))
+		assert.Equal(t, fiber.StatusNotFound, send(app, "POST", "/ngs/org/invitation/accept", "", educatorID, "educator", "acme"))
+	})
+
+	t.Run("Settings are read back", func(t *testing.T) {
+		db := testsupport.RowsDB(
+			[]string{"org_id", "agent_unlock_level", "enabled_tracks", "locale", "updated_by", "updated_at"},
+			[]driver.Value{"acme", int64(8), []byte(`["core","ethical_ai"]`), nil, adminID.String(), clock.Now()},
+		)
+		orgService := services.NewOrganizationService(db, testsupport.Config(), clock)
+
+		settings, err := orgService.GetSettings("acme")
+		require.NoError(t, err)
+		require.NotNil(t, settings.AgentUnlockLevel)
+		assert.Equal(t, 8, *settings.AgentUnlockLevel)
+		assert.Equal(t, []string{"core", "ethical_ai"}, settings.EnabledTracks)
+		assert.Nil(t, settings.Locale)
+		assert.Equal(t, adminID, *settings.UpdatedBy)
+
+		empty := services.NewOrganizationService(testsupport.RowsDB(nil), testsupport.Config(), clock)
+		settings, err = empty.GetSettings("beta")
+		require.NoError(t, err)
+		assert.Nil(t, settings.AgentUnlockLevel)
+		assert.Nil(t, settings.EnabledTracks)
+		assert.Nil(t, settings.UpdatedAt)
+	})
+}
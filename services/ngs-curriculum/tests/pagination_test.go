@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/api"
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCursor tests encoding and decoding list cursors
+func TestCursor(t *testing.T) {
+	cursor := api.Cursor{At: time.Date(2026, 10, 1, 12, 30, 0, 123456000, time.UTC), ID: uuid.New()}
+	decoded, err := api.ParseCursor(cursor.String())
+	require.NoError(t, err)
+	assert.True(t, cursor.At.Equal(decoded.At))
+	assert.Equal(t, cursor.ID, decoded.ID)
+
+	positional := api.Cursor{ID: uuid.New()}
+	decoded, err = api.ParseCursor(positional.String())
+	require.NoError(t, err)
+	assert.True(t, decoded.At.IsZero())
+	assert.Equal(t, positional.ID, decoded.ID)
+
+	for _, s := range []string{"not base64!", "bm8tc2VwYXJhdG9y", api.Cursor{}.String()[:4]} {
+		_, err := api.ParseCursor(s)
+		assert.ErrorIs(t, err, api.ErrInvalidPage, s)
+	}
+}
+
+// TestPageIncludes tests the date range and cursor of a newest-first page
+func TestPageIncludes(t *testing.T) {
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	low, high := uuid.MustParse("00000000-0000-0000-0000-000000000001"), uuid.MustParse("ffffffff-0000-0000-0000-000000000000")
+	from, to := day, day.AddDate(0, 0, 1)
+	page := api.Page{From: &from, To: &to, After: &api.Cursor{At: day.Add(12 * time.Hour), ID: low}}
+
+	assert.True(t, page.Includes(day.Add(6*time.Hour), low), "older than the cursor")
+	assert.True(t, page.Includes(day.Add(12*time.Hour), high), "same time, later ID")
+	assert.False(t, page.Includes(day.Add(12*time.Hour), low), "the cursor's own item")
+	assert.False(t, page.Includes(day.Add(18*time.Hour), high), "newer than the cursor")
+	assert.False(t, page.Includes(day.Add(-time.Hour), high), "before from")
+	assert.True(t, page.Includes(day, high), "from is inclusive")
+	assert.True(t, api.Page{}.Includes(day, low), "a zero page includes everything")
+}
+
+// TestAchievementPages tests paging through achievements with cursors and filters
+func TestAchievementPages(t *testing.T) {
+	progressService, store := testsupport.NewProgressService(progressConfig())
+	userID := uuid.New()
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	// Two achievements share a time, so the ID breaks the tie
+	for _, at := range []time.Time{day, day.Add(time.Hour), day.Add(time.Hour), day.AddDate(0, 0, 1), day.AddDate(0, 0, 2)} {
+		store.PutAchievement(models.Achievement{ID: uuid.New(), UserID: userID, AchievementType: "level_up", UnlockedAt: at})
+	}
+	all, err := progressService.GetAchievements(userID, api.Page{})
+	require.NoError(t, err)
+	require.Len(t, all, 5)
+
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/achievements", handlers.NewHandler(progressService).GetAchievements)
+	get := func(query string) (int, map[string]json.RawMessage) {
+		req := httptest.NewRequest("GET", "/ngs/achievements"+query, nil)
+		req.Header.Set("X-User-Id", userID.String())
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var body map[string]json.RawMessage
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp.StatusCode, body
+	}
+
+	var paged []models.Achievement
+	query := "?limit=2"
+	for pages := 0; pages < 5; pages++ {
+		status, body := get(query)
+		require.Equal(t, fiber.StatusOK, status)
+		var achievements []models.Achievement
+		require.NoError(t, json.Unmarshal(body["achievements"], &achievements))
+		paged = append(paged, achievements...)
+		if string(body["has_more"]) == "false" {
+			assert.NotContains(t, body, "next_cursor")
+			break
+		}
+		var next string
+		require.NoError(t, json.Unmarshal(body["next_cursor"], &next))
+		query = "?limit=2&cursor=" + next
+	}
+	require.Len(t, paged, 5, "every achievement once")
+	for i := range all {
+		assert.Equal(t, all[i].ID, paged[i].ID, "pages follow the list's order")
+	}
+
+	status, body := get("?from=2026-10-01&to=2026-10-01")
+	require.Equal(t, fiber.StatusOK, status)
+	assert.JSONEq(t, `3`, string(body["count"]), "a date to includes the day")
+
+	status, _ = get("?cursor=nonsense")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = get("?from=2026-10-02&to=2026-10-01")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+}
+
+// TestLessonPages tests filtering lessons by type and paging them by position
+func TestLessonPages(t *testing.T) {
+	lessonRow := func(lessonType string) (uuid.UUID, []driver.Value) {
+		id := uuid.New()
+		row := nullLessonRow(id)
+		row[5] = lessonType
+		return id, row
+	}
+	first, firstRow := lessonRow("tutorial")
+	_, quizRow := lessonRow("quiz")
+	third, thirdRow := lessonRow("tutorial")
+	lessonService := services.NewLessonService(testsupport.RowsDB(lessonWithCompletionColumns, firstRow, quizRow, thirdRow))
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/levels/:level/lessons", handlers.NewLessonHandler(lessonService, &testsupport.MockIntelligence{}).GetLessonsByLevel)
+
+	body := getList(t, app, "/ngs/levels/1/lessons?lesson_type=tutorial&limit=1")
+	var lessons []models.LessonWithCompletion
+	require.NoError(t, json.Unmarshal(body["lessons"], &lessons))
+	require.Len(t, lessons, 1)
+	assert.Equal(t, first, lessons[0].ID)
+	assert.JSONEq(t, `true`, string(body["has_more"]))
+	assert.JSONEq(t, `1`, string(body["level"]))
+	var next string
+	require.NoError(t, json.Unmarshal(body["next_cursor"], &next))
+
+	body = getList(t, app, "/ngs/levels/1/lessons?lesson_type=tutorial&limit=1&cursor="+next)
+	require.NoError(t, json.Unmarshal(body["lessons"], &lessons))
+	require.Len(t, lessons, 1)
+	assert.Equal(t, third, lessons[0].ID, "the quiz is filtered out")
+	assert.JSONEq(t, `false`, string(body["has_more"]))
+
+	body = getList(t, app, "/ngs/levels/1/lessons")
+	assert.JSONEq(t, `3`, string(body["count"]))
+}
@@ -0,0 +1,46 @@
+package tests
+
+import (
+	"noble-ngs-curriculum/internal/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPartitionName tests monthly partition naming
+func TestPartitionName(t *testing.T) {
+	month := time.Date(2025, time.March, 17, 23, 30, 0, 0, time.UTC)
+	assert.Equal(t, "xp_events_p2025_03", services.PartitionName("xp_events", month))
+	assert.Equal(t, time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), services.MonthStart(month))
+
+	// Months are UTC, matching the partition bounds
+	local := time.Date(2025, time.April, 1, 1, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	assert.Equal(t, "xp_events_p2025_03", services.PartitionName("xp_events", local))
+}
+
+// TestParsePartitionMonth tests reading the month back from a partition name
+func TestParsePartitionMonth(t *testing.T) {
+	month, ok := services.ParsePartitionMonth("challenge_submissions", "challenge_submissions_p2024_12")
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2024, time.December, 1, 0, 0, 0, 0, time.UTC), month)
+
+	for _, name := range []string{"xp_events_default", "xp_events_p2024_13", "xp_events_p24_01", "challenge_submissions_p2024_01"} {
+		_, ok := services.ParsePartitionMonth("xp_events", name)
+		assert.False(t, ok, name)
+	}
+}
+
+// TestPartitionsToArchive tests selecting partitions past their retention
+func TestPartitionsToArchive(t *testing.T) {
+	now := time.Date(2026, time.February, 10, 0, 0, 0, 0, time.UTC)
+	partitions := []string{
+		"xp_events_p2024_03", "xp_events_default", "xp_events_p2024_01",
+		"xp_events_p2024_02", "xp_events_p2026_02", "xp_events_p2026_05",
+	}
+
+	assert.Equal(t, []string{"xp_events_p2024_01"}, services.PartitionsToArchive("xp_events", partitions, now, 24))
+	assert.Equal(t, []string{"xp_events_p2024_01", "xp_events_p2024_02", "xp_events_p2024_03"},
+		services.PartitionsToArchive("xp_events", partitions, now, 12))
+	assert.Empty(t, services.PartitionsToArchive("xp_events", partitions, now, 0))
+}
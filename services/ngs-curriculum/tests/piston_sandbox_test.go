@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePiston runs an echo program: stdout is stdin, except for inputs that act out a runtime
+// error, timeout, memory kill or judge failure. Code "syntax error" does not compile.
+type fakePiston struct {
+	mu       sync.Mutex
+	requests []map[string]interface{}
+	auth     string
+}
+
+func (f *fakePiston) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req map[string]interface{}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.auth = r.Header.Get("Authorization")
+	f.mu.Unlock()
+
+	if r.URL.Path != "/api/v2/execute" {
+		http.NotFound(w, r)
+		return
+	}
+	if req["language"] == "cobol" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"message": "cobol-* runtime is unknown"}`))
+		return
+	}
+
+	code := req["files"].([]interface{})[0].(map[string]interface{})["content"]
+	if code == "syntax error" {
+		_, _ = w.Write([]byte(`{"compile": {"stdout": "", "stderr": "main.c:1: error: expected ';'", "code": 1, "signal": null, "status": "RE"}, "run": {}}`))
+		return
+	}
+
+	run := map[string]interface{}{"stdout": req["stdin"], "stderr": "", "code": 0, "signal": nil, "status": nil, "cpu_time": 12, "wall_time": 30, "memory": 8 << 20}
+	switch req["stdin"] {
+	case "boom":
+		run["stdout"], run["stderr"], run["code"], run["status"] = "", "Traceback: ZeroDivisionError", 1, "RE"
+	case "slow":
+		run["stdout"], run["code"], run["signal"], run["status"], run["wall_time"] = "", nil, "SIGKILL", "TO", 1000
+	case "hog":
+		run["stdout"], run["code"], run["signal"], run["status"], run["memory"] = "", nil, "SIGKILL", "SG", 64<<20
+	case "judge down":
+		run["status"], run["message"] = "XX", "isolate box failed"
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"language": req["language"], "version": "3.10.0", "run": run})
+}
+
+func (f *fakePiston) calls() []map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]map[string]interface{}(nil), f.requests...)
+}
+
+// TestNewSandbox tests sandbox selection
+func TestNewSandbox(t *testing.T) {
+	sandbox, err := services.NewSandbox("", "", "")
+	require.NoError(t, err)
+	assert.IsType(t, services.PlaceholderSandbox{}, sandbox)
+
+	_, err = services.NewSandbox("piston", "", "")
+	assert.Error(t, err)
+
+	_, err = services.NewSandbox("docker", "http://judge", "")
+	assert.Error(t, err)
+
+	sandbox, err = services.NewSandbox("piston", "http://piston:2000", "")
+	require.NoError(t, err)
+	assert.IsType(t, &services.PistonSandbox{}, sandbox)
+}
+
+// TestChallengeLanguage tests that challenges run in their metadata.language or the default
+func TestChallengeLanguage(t *testing.T) {
+	assert.Equal(t, "python", services.ChallengeLanguage(&models.Challenge{}, "python"))
+	assert.Equal(t, "javascript", services.ChallengeLanguage(&models.Challenge{Metadata: json.RawMessage(`{"language": " JavaScript "}`)}, "python"))
+	assert.Equal(t, "python", services.ChallengeLanguage(&models.Challenge{Metadata: json.RawMessage(`{"rubric": []}`)}, "python"))
+	assert.Equal(t, "python", services.ChallengeLanguage(&models.Challenge{Metadata: json.RawMessage(`not json`)}, "python"))
+}
+
+// TestPistonSandbox tests running test cases on a Piston judge
+func TestPistonSandbox(t *testing.T) {
+	piston := &fakePiston{}
+	server := httptest.NewServer(piston)
+	defer server.Close()
+	sandbox := services.NewPistonSandbox(server.URL+"/", "judge-key")
+	limits := models.ResourceLimits{TimeLimitMs: 1000, CPULimitMs: 500, MemoryLimitMB: 64}
+
+	tests := []models.TestCase{
+		{Name: "number", Input: json.RawMessage(`[1, 2]`), Expected: json.RawMessage(`[1,2]`)},
+		{Name: "text", Input: json.RawMessage(`"hello"`), Expected: json.RawMessage(`"hello"`)},
+		{Name: "wrong", Input: json.RawMessage(`"41"`), Expected: json.RawMessage(`42`), Hidden: true},
+		{Name: "crash", Input: json.RawMessage(`"boom"`), Expected: json.RawMessage(`1`)},
+		{Name: "loop", Input: json.RawMessage(`"slow"`), Expected: json.RawMessage(`1`)},
+		{Name: "greedy", Input: json.RawMessage(`"hog"`), Expected: json.RawMessage(`1`)},
+	}
+	results, err := sandbox.Run(context.Background(), "python", "print(input())", tests, limits)
+	require.NoError(t, err)
+	require.Len(t, results.Tests, 6)
+
+	assert.Equal(t, models.TestPassed, results.Tests[0].Status)
+	assert.JSONEq(t, `[1,2]`, string(results.Tests[0].Actual))
+	assert.Equal(t, 30.0, results.Tests[0].RuntimeMs)
+	assert.Equal(t, 12.0, results.Tests[0].CPUMs)
+	assert.Equal(t, int64(8<<10), results.Tests[0].MemoryKB)
+	assert.Equal(t, models.TestPassed, results.Tests[1].Status, "strings are sent and compared as plain text")
+	assert.Equal(t, models.TestFailed, results.Tests[2].Status)
+	assert.JSONEq(t, `41`, string(results.Tests[2].Actual))
+	assert.True(t, results.Tests[2].Hidden)
+	assert.Equal(t, models.TestError, results.Tests[3].Status)
+	assert.Equal(t, "Traceback: ZeroDivisionError", results.Tests[3].Output)
+	assert.Equal(t, models.TestTimeout, results.Tests[4].Status)
+	assert.Equal(t, models.TestMemoryExceeded, results.Tests[5].Status)
+
+	calls := piston.calls()
+	require.Len(t, calls, 6)
+	assert.Equal(t, "python", calls[0]["language"])
+	assert.Equal(t, "*", calls[0]["version"])
+	assert.Equal(t, "[1, 2]", calls[0]["stdin"])
+	assert.Equal(t, "hello", calls[1]["stdin"])
+	assert.Equal(t, float64(1000), calls[0]["run_timeout"])
+	assert.Equal(t, float64(500), calls[0]["run_cpu_time"])
+	assert.Equal(t, float64(64<<20), calls[0]["run_memory_limit"])
+	assert.Equal(t, "judge-key", piston.auth)
+
+	t.Run("Compile error", func(t *testing.T) {
+		piston := &fakePiston{}
+		server := httptest.NewServer(piston)
+		defer server.Close()
+
+		results, err := services.NewPistonSandbox(server.URL, "").Run(context.Background(), "c", "syntax error", tests[:3], limits)
+		require.NoError(t, err)
+		require.Len(t, results.Tests, 3)
+		for _, r := range results.Tests {
+			assert.Equal(t, models.TestError, r.Status)
+			assert.Equal(t, "main.c:1: error: expected ';'", r.Output)
+		}
+		assert.Len(t, piston.calls(), 1, "code that does not compile is not run again")
+	})
+
+	t.Run("Judge failure", func(t *testing.T) {
+		_, err := sandbox.Run(context.Background(), "python", "print(input())", []models.TestCase{{Name: "x", Input: json.RawMessage(`"judge down"`)}}, limits)
+		assert.Error(t, err)
+
+		_, err = sandbox.Run(context.Background(), "cobol", "DISPLAY 'HI'", tests[:1], limits)
+		assert.Error(t, err)
+	})
+
+	t.Run("Graded", func(t *testing.T) {
+		services.EnforceLimits(results, limits)
+		assert.Equal(t, 33, services.GradeTestResults(results))
+		assert.Equal(t, "Failed a hidden test case", results.Tests[2].Feedback)
+		assert.Equal(t, "Your code raised an error: Traceback: ZeroDivisionError", results.Tests[3].Feedback)
+	})
+}
@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var progressColumns = []string{"id", "user_id", "current_level", "total_xp", "agent_creation_unlocked", "created_at", "updated_at"}
+
+// progressRow is a user_progress row as Postgres returns it
+func progressRow(userID uuid.UUID, level, totalXP int64, agentUnlocked bool) []driver.Value {
+	return []driver.Value{uuid.NewString(), userID.String(), level, totalXP, agentUnlocked, testsupport.Epoch, testsupport.Epoch.Add(time.Hour)}
+}
+
+// TestProgressPreparedQueries tests that the prepared progress statements scan each column into
+// its field and are run with their arguments in order
+func TestProgressPreparedQueries(t *testing.T) {
+	userID := uuid.New()
+
+	t.Run("Progress read", func(t *testing.T) {
+		db := testsupport.QueryDB(testsupport.Query{Match: "FROM user_progress WHERE user_id = $1",
+			Columns: progressColumns, Rows: [][]driver.Value{progressRow(userID, 4, 720, true)}})
+		progress, err := services.NewProgressService(db, progressConfig(), services.SystemClock{}).GetProgress(userID)
+		require.NoError(t, err)
+		assert.Equal(t, userID, progress.UserID)
+		assert.Equal(t, 4, progress.CurrentLevel)
+		assert.Equal(t, 720, progress.TotalXP)
+		assert.True(t, progress.AgentCreationUnlocked)
+		assert.True(t, testsupport.Epoch.Equal(progress.CreatedAt))
+		assert.True(t, testsupport.Epoch.Add(time.Hour).Equal(progress.UpdatedAt))
+	})
+
+	t.Run("Initial progress insert", func(t *testing.T) {
+		var inserts [][]driver.Value
+		db := testsupport.QueryDB(testsupport.Query{Match: "INSERT INTO user_progress",
+			Columns: progressColumns, Rows: [][]driver.Value{progressRow(userID, 1, 0, false)}, Args: &inserts})
+		progress, err := services.NewProgressService(db, progressConfig(), services.SystemClock{}).GetProgress(userID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, progress.CurrentLevel)
+		assert.Zero(t, progress.TotalXP)
+		assert.Equal(t, [][]driver.Value{{userID.String()}}, inserts)
+	})
+
+	t.Run("XP award", func(t *testing.T) {
+		var events, updates [][]driver.Value
+		db := testsupport.QueryDB(
+			testsupport.Query{Match: "COUNT(*) FROM xp_events", Columns: []string{"count"}, Rows: [][]driver.Value{{int64(0)}}},
+			testsupport.Query{Match: "FOR UPDATE", Columns: progressColumns, Rows: [][]driver.Value{progressRow(userID, 1, 90, false)}},
+			testsupport.Query{Match: "INSERT INTO xp_events", Args: &events},
+			testsupport.Query{Match: "UPDATE user_progress", Args: &updates},
+		)
+		progress, err := services.NewProgressService(db, progressConfig(), services.SystemClock{}).
+			AwardXP(userID, "reflection", 0, map[string]interface{}{"lesson_id": "intro"})
+		require.NoError(t, err)
+		assert.Equal(t, 115, progress.TotalXP)
+		assert.Equal(t, 2, progress.CurrentLevel)
+
+		require.Len(t, events, 1)
+		assert.Equal(t, []driver.Value{userID.String(), "reflection", int64(25)}, events[0][:3])
+		var metadata map[string]interface{}
+		require.NoError(t, json.Unmarshal(events[0][3].([]byte), &metadata))
+		assert.Equal(t, "intro", metadata["lesson_id"])
+		assert.Equal(t, [][]driver.Value{{int64(115), int64(2), false, userID.String()}}, updates)
+	})
+}
+
+// TestPreparedStatementReuse tests that a query is prepared once and its statement reused
+func TestPreparedStatementReuse(t *testing.T) {
+	db := testsupport.QueryDB()
+	first, err := db.Stmt("SELECT 1")
+	require.NoError(t, err)
+	second, err := db.Stmt("SELECT 1")
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+
+	other, err := db.Stmt("SELECT 2")
+	require.NoError(t, err)
+	assert.NotSame(t, first, other)
+}
@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"noble-ngs-curriculum/internal/api"
+	"noble-ngs-curriculum/internal/domain/xp"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPrestigeMilestones tests milestones past the top of the XP curve
+func TestPrestigeMilestones(t *testing.T) {
+	thresholds := []int{0, 100, 250, 1000}
+
+	off := xp.NewPrestige(thresholds, 0)
+	assert.False(t, off.Enabled())
+	assert.Equal(t, 0, off.Milestones(1_000_000))
+	assert.Equal(t, 0, off.XPToNextMilestone(1_000_000))
+
+	prestige := xp.NewPrestige(thresholds, 500)
+	assert.Equal(t, xp.Prestige{StartXP: 1000, MilestoneXP: 500}, prestige)
+	assert.Equal(t, 0, prestige.Milestones(400), "below the top of the curve")
+	assert.Equal(t, 1100, prestige.XPToNextMilestone(400), "the rest of the curve counts toward the first milestone")
+	assert.Equal(t, 0, prestige.Milestones(1000))
+	assert.Equal(t, 500, prestige.XPToNextMilestone(1000))
+	assert.Equal(t, 1, prestige.Milestones(1500))
+	assert.Equal(t, 2, prestige.Milestones(2499))
+	assert.Equal(t, 1, prestige.XPToNextMilestone(2499))
+	assert.Equal(t, 0, xp.NewPrestige(nil, 500).Milestones(499), "an empty curve starts at 0")
+}
+
+// TestPrestigeAchievements tests milestone achievements and the leaderboard column
+func TestPrestigeAchievements(t *testing.T) {
+	cfg := testsupport.Config()
+	top := cfg.LevelUpXPThresholds[len(cfg.LevelUpXPThresholds)-1]
+	services.ConfigurePrestige(xp.NewPrestige(cfg.LevelUpXPThresholds, 1000))
+	t.Cleanup(func() { services.ConfigurePrestige(xp.Prestige{}) })
+
+	service, store := testsupport.NewProgressService(cfg)
+	userID := uuid.New()
+	store.PutProgress(testsupport.Progress().User(userID).XP(top+900, cfg.LevelUpXPThresholds).Build())
+
+	_, err := service.AwardXP(userID, "creative_solution", 50, nil)
+	require.NoError(t, err)
+	_, err = service.AwardXP(userID, "creative_solution", 2100, nil)
+	require.NoError(t, err)
+
+	achievements, err := service.GetAchievements(userID, api.Page{})
+	require.NoError(t, err)
+	var milestones []int
+	for _, achievement := range achievements {
+		if achievement.AchievementType == "prestige_milestone" {
+			var data struct {
+				Milestone int `json:"milestone"`
+			}
+			require.NoError(t, json.Unmarshal(achievement.AchievementData, &data))
+			milestones = append(milestones, data.Milestone)
+		}
+	}
+	assert.ElementsMatch(t, []int{1, 2, 3}, milestones, "one achievement for each milestone passed")
+
+	entries, err := service.GetLeaderboard(10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, 3, entries[0].Prestige)
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"noble-ngs-curriculum/internal/clients/intelligence"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseRoutingPolicy tests reading LLM_PROVIDER_POLICY
+func TestParseRoutingPolicy(t *testing.T) {
+	policy, err := intelligence.ParseRoutingPolicy(" lesson = openai, gemini ; chat=ollama ")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"openai", "gemini"}, policy[intelligence.OperationLesson])
+	assert.Equal(t, []string{"ollama"}, policy[intelligence.OperationChat])
+	assert.Equal(t, "lesson=openai,gemini; chat=ollama", policy.String())
+
+	policy, err = intelligence.ParseRoutingPolicy("none")
+	require.NoError(t, err)
+	assert.Empty(t, policy)
+	assert.Equal(t, "none", policy.String())
+
+	for _, spec := range []string{"lesson", "hints=ollama", "chat=ollama;chat=gemini", "chat=ollama,,gemini", "chat=ollama,ollama"} {
+		_, err := intelligence.ParseRoutingPolicy(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+// TestProviderFailover tests that routed requests ask for each provider in turn and record the one that served them
+func TestProviderFailover(t *testing.T) {
+	var mu sync.Mutex
+	var asked []string
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		provider := r.Header.Get(intelligence.ProviderHeader)
+		mu.Lock()
+		asked = append(asked, provider)
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+		switch provider {
+		case "openai":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "broken":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"detail": "bad request"}`))
+		case "ollama":
+			w.Write([]byte(`{"response": "Hi", "provider": "ollama:llama3"}`))
+		default:
+			w.Write([]byte(`{"content_markdown": "# Lesson"}`))
+		}
+	}))
+	defer server.Close()
+	reset := func() {
+		mu.Lock()
+		asked, bodies = nil, nil
+		mu.Unlock()
+	}
+
+	policy, err := intelligence.ParseRoutingPolicy("lesson=openai,gemini;remediation=gemini;chat=broken,ollama;summary=openai")
+	require.NoError(t, err)
+	client := intelligence.NewClient(server.URL, func() string { return "token" })
+	client.SetRoutingPolicy(policy)
+	ctx := context.Background()
+
+	lesson, err := client.GenerateLesson(ctx, intelligence.GenerateLessonRequest{LessonSummary: "Signals"}, "user", "", "student")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"openai", "gemini"}, asked, "a 503 fails over to the next provider")
+	require.Len(t, bodies, 2)
+	assert.Equal(t, bodies[0], bodies[1], "the request is sent again whole")
+	assert.Equal(t, "gemini", lesson.Provider, "the serving provider is recorded when the service does not report it")
+
+	reset()
+	_, err = client.GenerateLesson(intelligence.WithOperation(ctx, intelligence.OperationRemediation), intelligence.GenerateLessonRequest{}, "user", "", "student")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gemini"}, asked, "remediation is routed apart from lesson generation")
+
+	reset()
+	_, err = client.SendEducatorChatMessage(ctx, intelligence.EducatorChatRequest{Message: "Hi"}, "user", "", "student")
+	assert.ErrorContains(t, err, "status 400")
+	assert.Equal(t, []string{"broken"}, asked, "client errors do not fail over")
+
+	reset()
+	_, err = client.SummarizeChatSession(ctx, intelligence.SummarizeChatSessionRequest{}, "user", "", "student")
+	assert.ErrorContains(t, err, "status 503", "the last provider's failure is returned")
+
+	reset()
+	_, err = client.TranscribeMedia(ctx, intelligence.TranscribeMediaRequest{}, "user", "", "student")
+	require.NoError(t, err)
+	assert.Equal(t, []string{""}, asked, "unrouted operations are left to the service")
+}
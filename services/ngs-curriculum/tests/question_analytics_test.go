@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var questionStatsColumns = []string{
+	"lesson_id", "title", "level_number", "type", "question", "choices", "answer", "attempts", "correct", "discrimination",
+}
+
+func discrimination(d float64) *float64 {
+	return &d
+}
+
+// TestFlagQuestion tests flagging questions learners miss or that do not track exam performance
+func TestFlagQuestion(t *testing.T) {
+	q := models.QuestionStats{Attempts: 40, CorrectRate: 0.1, Discrimination: discrimination(0.4)}
+	services.FlagQuestion(&q, 20)
+	assert.Equal(t, []string{services.QuestionFlagRarelyCorrect}, q.Flags)
+
+	q = models.QuestionStats{Attempts: 40, CorrectRate: 0.7, Discrimination: discrimination(-0.2)}
+	services.FlagQuestion(&q, 20)
+	assert.Equal(t, []string{services.QuestionFlagLowDiscrimination}, q.Flags)
+
+	q = models.QuestionStats{Attempts: 40, CorrectRate: 0, Discrimination: nil}
+	services.FlagQuestion(&q, 20)
+	assert.Equal(t, []string{services.QuestionFlagRarelyCorrect}, q.Flags, "everyone missed it, so there is no correlation to compute")
+
+	q = models.QuestionStats{Attempts: 5, CorrectRate: 0, Discrimination: discrimination(-0.9)}
+	services.FlagQuestion(&q, 20)
+	assert.Equal(t, []string{}, q.Flags, "too few attempts to judge")
+}
+
+// TestSortQuestionStats tests that flagged and poorly discriminating questions come first
+func TestSortQuestionStats(t *testing.T) {
+	stats := []models.QuestionStats{
+		{Question: "unknown", CorrectRate: 1, Flags: []string{}},
+		{Question: "good", CorrectRate: 0.6, Discrimination: discrimination(0.5), Flags: []string{}},
+		{Question: "weak", CorrectRate: 0.6, Discrimination: discrimination(0.2), Flags: []string{}},
+		{Question: "flagged", CorrectRate: 0.1, Discrimination: discrimination(0.3), Flags: []string{services.QuestionFlagRarelyCorrect}},
+	}
+	services.SortQuestionStats(stats)
+
+	order := []string{}
+	for _, q := range stats {
+		order = append(order, q.Question)
+	}
+	assert.Equal(t, []string{"flagged", "weak", "good", "unknown"}, order)
+}
+
+// TestQuestionAnalyticsHandler tests the question analytics route
+func TestQuestionAnalyticsHandler(t *testing.T) {
+	lessonID := uuid.New()
+	db := testsupport.RowsDB(questionStatsColumns,
+		[]driver.Value{lessonID.String(), "Loops", int64(2), "multiple_choice", "What does break do?", []byte(`["Stops the loop", "Skips an iteration"]`), []byte(`"Stops the loop"`), int64(30), int64(3), 0.05},
+		[]driver.Value{lessonID.String(), "Loops", int64(2), "short_answer", "Name a loop keyword", nil, []byte(`"for"`), int64(30), int64(21), 0.45},
+	)
+	handler := handlers.NewQuestionAnalyticsHandler(services.NewQuestionAnalyticsService(db))
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/admin/analytics/questions", handler.GetQuestionStats)
+
+	req := httptest.NewRequest("GET", "/ngs/admin/analytics/questions?flagged=true", nil)
+	req.Header.Set("X-User-Id", uuid.NewString())
+	req.Header.Set("X-User-Role", "educator")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Questions   []models.QuestionStats `json:"questions"`
+		MinAttempts int                    `json:"min_attempts"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body.Questions, 1)
+	q := body.Questions[0]
+	assert.Equal(t, "What does break do?", q.Question)
+	assert.Equal(t, 0.1, q.CorrectRate)
+	assert.Equal(t, []string{services.QuestionFlagRarelyCorrect, services.QuestionFlagLowDiscrimination}, q.Flags)
+	assert.JSONEq(t, `"Stops the loop"`, string(q.Answer))
+	assert.Equal(t, services.DefaultQuestionMinAttempts, body.MinAttempts)
+
+	req = httptest.NewRequest("GET", "/ngs/admin/analytics/questions", nil)
+	req.Header.Set("X-User-Id", uuid.NewString())
+	req.Header.Set("X-User-Role", "student")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+}
@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nextActionSteps are the next action lookups in priority order: the type each recommends and
+// a fragment of its query
+var nextActionSteps = []struct {
+	actionType string
+	match      string
+}{
+	{"resume_exam", "FROM level_exams"},
+	{"resume_duel", "FROM duels d"},
+	{"resume_lesson", "FROM lesson_metrics"},
+	{"retry_challenge", "HAVING NOT bool_or"},
+	{"remediation_lesson", "FROM remediation_lessons"},
+	{"next_lesson", "array_position"},
+	{"start_challenge", "c.level_id = $2 AND c.is_active"},
+}
+
+// recommendationService recommends to a user at level 3 for whom only the lookups from step on
+// find something, and whose level 3 is certified or not
+func recommendationService(step int, certified bool) *services.RecommendationService {
+	queries := []testsupport.Query{
+		{Match: "SELECT current_level FROM user_progress WHERE user_id = $1",
+			Columns: []string{"current_level"}, Rows: [][]driver.Value{{int64(3)}}},
+		{Match: "level_certifications", Columns: []string{"exists"}, Rows: [][]driver.Value{{certified}}},
+	}
+	for i, s := range nextActionSteps {
+		query := testsupport.Query{Match: s.match}
+		if i >= step {
+			query.Columns = []string{"id", "level", "title"}
+			query.Rows = [][]driver.Value{{uuid.NewString(), int64(3), s.actionType}}
+		}
+		queries = append(queries, query)
+	}
+	db := testsupport.QueryDB(queries...)
+	return services.NewRecommendationService(db, services.NewSettingsService(db))
+}
+
+// TestNextActionPriority tests that the highest-priority action that applies is recommended
+func TestNextActionPriority(t *testing.T) {
+	userID := uuid.New()
+	for step, s := range nextActionSteps {
+		t.Run(s.actionType, func(t *testing.T) {
+			action, err := recommendationService(step, false).NextAction(userID)
+			require.NoError(t, err)
+			assert.Equal(t, s.actionType, action.Type)
+			assert.Equal(t, s.actionType, action.Title)
+			assert.Equal(t, 3, action.LevelNumber)
+			require.NotNil(t, action.ResourceID)
+			assert.Contains(t, action.Endpoint, action.ResourceID.String())
+		})
+	}
+
+	t.Run("take_exam", func(t *testing.T) {
+		action, err := recommendationService(len(nextActionSteps), false).NextAction(userID)
+		require.NoError(t, err)
+		assert.Equal(t, "take_exam", action.Type)
+		assert.Equal(t, "/ngs/levels/3/exam", action.Endpoint)
+	})
+
+	t.Run("caught_up", func(t *testing.T) {
+		action, err := recommendationService(len(nextActionSteps), true).NextAction(userID)
+		require.NoError(t, err)
+		assert.Equal(t, "caught_up", action.Type)
+		assert.Nil(t, action.ResourceID)
+	})
+}
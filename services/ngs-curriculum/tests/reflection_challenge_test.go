@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"testing"
+
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReflectionRubric tests reading a reflection challenge's rubric and pass mark from its metadata
+func TestReflectionRubric(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		challenge := &models.Challenge{}
+		assert.Len(t, services.ReflectionRubric(challenge), 5)
+		assert.Equal(t, 60, services.ReflectionMinQuality(challenge, 60))
+	})
+
+	t.Run("From metadata", func(t *testing.T) {
+		challenge := &models.Challenge{Metadata: []byte(`{"min_quality": 75, "rubric": [
+			{"name": "depth", "max_points": 20},
+			{"name": "Creativity", "max_points": 10},
+			{"name": "Next steps", "description": "Names one thing to practise", "max_points": 5}
+		]}`)}
+		assert.Equal(t, []models.RubricCriterion{
+			{Name: services.ReflectionDepth, Description: "Develops its points beyond a sentence or two", MaxPoints: 20},
+			{Name: services.ReflectionNextSteps, Description: "Names one thing to practise", MaxPoints: 5},
+		}, services.ReflectionRubric(challenge), "criteria the scorer does not know are dropped")
+		assert.Equal(t, 75, services.ReflectionMinQuality(challenge, 60))
+	})
+
+	t.Run("Out of range pass mark", func(t *testing.T) {
+		assert.Equal(t, 60, services.ReflectionMinQuality(&models.Challenge{Metadata: []byte(`{"min_quality": 140}`)}, 60))
+	})
+}
+
+// TestScoreReflection tests that thoughtful reflections outscore thin ones on every criterion
+func TestScoreReflection(t *testing.T) {
+	challenge := &models.Challenge{Title: "Recursion", Description: "Reflect on writing recursive functions and base cases."}
+	rubric := services.ReflectionRubric(challenge)
+
+	thin, thinScore := services.ScoreReflection(challenge, rubric, "It was fine.")
+	rich, richScore := services.ScoreReflection(challenge, rubric,
+		"I learned that recursion needs a base case before anything else. For example, my factorial(n) "+
+			"kept calling itself for n = 0 because I checked n == 1 only, and I realized the stack overflow "+
+			"came from that mistake. Next time I will write the base cases first and practice tracing "+
+			"recursive functions by hand on small inputs.")
+
+	require.Len(t, thin, len(rubric))
+	require.Len(t, rich, len(rubric))
+	for i := range rubric {
+		assert.Equal(t, rubric[i].Name, rich[i].Criterion)
+		assert.LessOrEqual(t, thin[i].Points, rich[i].Points, rubric[i].Name)
+		assert.LessOrEqual(t, rich[i].Points, rich[i].MaxPoints, rubric[i].Name)
+	}
+	assert.Less(t, thinScore, 60)
+	assert.GreaterOrEqual(t, richScore, 80)
+}
+
+// TestReflectionChallengeRouting tests that reflection challenges are not sent to the code validator
+func TestReflectionChallengeRouting(t *testing.T) {
+	reflection := services.NewChallengeService(testsupport.RowsDB(challengeColumns, designChallengeRow("reflection", nil)), progressConfig(), nil)
+	_, err := reflection.SubmitChallenge(uuid.New(), models.SubmitChallengeRequest{ChallengeID: uuid.New(), ReflectionText: "   "})
+	assert.ErrorIs(t, err, services.ErrEmptySubmission)
+
+	coding := services.NewChallengeService(testsupport.RowsDB(challengeColumns, designChallengeRow("coding", nil)), progressConfig(), nil)
+	_, err = coding.SubmitChallenge(uuid.New(), models.SubmitChallengeRequest{ChallengeID: uuid.New(), ReflectionText: "I learned a lot."})
+	assert.ErrorIs(t, err, services.ErrEmptySubmission, "code challenges still need submission_code")
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"noble-ngs-curriculum/internal/clients/registry"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConsul records the agent API calls it receives
+type fakeConsul struct {
+	mu           sync.Mutex
+	paths        []string
+	registration map[string]interface{}
+	token        string
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.paths = append(f.paths, r.Method+" "+r.URL.Path)
+	f.token = r.Header.Get("X-Consul-Token")
+	if r.URL.Path == "/v1/agent/service/register" {
+		_ = json.NewDecoder(r.Body).Decode(&f.registration)
+	}
+}
+
+func (f *fakeConsul) calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.paths...)
+}
+
+// TestNewRegistry tests registry selection
+func TestNewRegistry(t *testing.T) {
+	reg, err := registry.NewRegistry("", "", "")
+	require.NoError(t, err)
+	assert.Nil(t, reg)
+
+	_, err = registry.NewRegistry("consul", "", "")
+	assert.Error(t, err)
+
+	_, err = registry.NewRegistry("zookeeper", "http://zk", "")
+	assert.Error(t, err)
+}
+
+// TestRegistrationService tests registering, heartbeating and deregistering with Consul
+func TestRegistrationService(t *testing.T) {
+	consul := &fakeConsul{}
+	server := httptest.NewServer(consul)
+	defer server.Close()
+
+	reg, err := registry.NewRegistry("consul", server.URL, "acl-token")
+	require.NoError(t, err)
+
+	cfg := testsupport.Config()
+	cfg.ServiceAdvertiseHost = "curriculum-1"
+	cfg.ServiceAdvertisePort = 9000
+	cfg.RegistryHeartbeatSeconds = 10
+	registration := services.NewRegistrationService(reg, cfg)
+	instanceID := registration.Instance().ID
+	assert.Equal(t, "ngs-curriculum-curriculum-1-9000", instanceID)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	registration.Start(ctx)
+	require.Eventually(t, func() bool { return len(consul.calls()) >= 2 }, time.Second, 10*time.Millisecond)
+	cancel()
+	registration.Wait()
+	require.NoError(t, registration.Deregister(context.Background()))
+
+	assert.Equal(t, []string{
+		"PUT /v1/agent/service/register",
+		"PUT /v1/agent/check/pass/service:" + instanceID + ":ttl",
+		"PUT /v1/agent/service/deregister/" + instanceID,
+	}, consul.calls())
+	assert.Equal(t, "acl-token", consul.token)
+
+	assert.Equal(t, "ngs-curriculum", consul.registration["Name"])
+	assert.Equal(t, "curriculum-1", consul.registration["Address"])
+	assert.EqualValues(t, 9000, consul.registration["Port"])
+	checks := consul.registration["Checks"].([]interface{})
+	require.Len(t, checks, 2)
+	assert.Equal(t, "30s", checks[0].(map[string]interface{})["TTL"])
+	assert.Equal(t, "http://curriculum-1:9000/health", checks[1].(map[string]interface{})["HTTP"])
+}
+
+// TestRegistrationDisabled tests that no registry means no registration
+func TestRegistrationDisabled(t *testing.T) {
+	registration := services.NewRegistrationService(nil, testsupport.Config())
+	registration.Start(context.Background())
+	registration.Wait()
+	assert.NoError(t, registration.Deregister(context.Background()))
+}
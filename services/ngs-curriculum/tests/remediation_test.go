@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/jobs"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var remediationColumns = []string{
+	"id", "user_id", "topic", "lesson_id", "source", "status", "title", "content_markdown", "metadata",
+	"error", "weak_score", "created_at", "ready_at", "completed_at",
+	"before_answered", "before_correct", "after_answered", "after_correct",
+}
+
+func newRemediationService(db *database.DB, generator services.LessonGenerator) *services.RemediationService {
+	jobService := services.NewJobService(db, jobs.New(jobs.Settings{Workers: 1, MaxWait: time.Minute}), services.SystemClock{})
+	return services.NewRemediationService(db, testsupport.Config(), testsupport.NewFakeClock(testsupport.Epoch), generator, jobService)
+}
+
+// TestNewRemediationOutcome tests comparing exam answers before and after a remediation
+func TestNewRemediationOutcome(t *testing.T) {
+	outcome := services.NewRemediationOutcome(4, 1, 2, 2)
+	require.NotNil(t, outcome.Before.Accuracy)
+	assert.Equal(t, 0.25, *outcome.Before.Accuracy)
+	assert.Equal(t, 1.0, *outcome.After.Accuracy)
+	require.NotNil(t, outcome.Improved)
+	assert.True(t, *outcome.Improved)
+
+	outcome = services.NewRemediationOutcome(2, 1, 4, 2)
+	require.NotNil(t, outcome.Improved)
+	assert.False(t, *outcome.Improved, "the same accuracy is not an improvement")
+
+	outcome = services.NewRemediationOutcome(3, 0, 0, 0)
+	assert.Nil(t, outcome.After.Accuracy)
+	assert.Nil(t, outcome.Improved, "no answers since completion yet")
+
+	outcome = services.NewRemediationOutcome(0, 0, 5, 4)
+	assert.Nil(t, outcome.Improved, "topics from challenges or reflections may have no exam answers before")
+}
+
+// TestRunRemediation tests generating a remediation lesson and falling back to the topic's lesson
+func TestRunRemediation(t *testing.T) {
+	cfg := testsupport.Config()
+	userID := uuid.New()
+	lessonID := uuid.New()
+	columns := []string{"user_id", "topic", "lesson_id", "title", "description", "level"}
+	payload, _ := json.Marshal(map[string]string{"remediation_id": uuid.NewString()})
+
+	mock := &testsupport.MockIntelligence{Lesson: testsupport.GeneratedLesson("# Recursion again")}
+	db := testsupport.RowsDB(columns, []driver.Value{userID.String(), "Recursion", nil, nil, nil, int64(4)})
+	require.NoError(t, newRemediationService(db, mock).RunRemediation(context.Background(), payload))
+
+	require.Len(t, mock.GenerateRequests, 1)
+	req := mock.GenerateRequests[0]
+	assert.True(t, strings.Contains(req.LessonSummary, `"Recursion"`), req.LessonSummary)
+	assert.Equal(t, 4, req.LevelNumber)
+	assert.Equal(t, []string{"Recursion"}, req.LearnerProfile.WeakTopics)
+	assert.Equal(t, cfg.RemediationTargetMinutes, req.Constraints.TargetMinutes)
+
+	t.Run("Generation fails", func(t *testing.T) {
+		mock := &testsupport.MockIntelligence{Err: errors.New("intelligence service unavailable")}
+		err := newRemediationService(db, mock).RunRemediation(context.Background(), payload)
+		assert.Error(t, err, "kept as a dead letter")
+	})
+
+	t.Run("Generation fails with a lesson to select", func(t *testing.T) {
+		mock := &testsupport.MockIntelligence{Err: errors.New("intelligence service unavailable")}
+		db := testsupport.RowsDB(columns, []driver.Value{userID.String(), "Recursion", lessonID.String(), "Recursion", "Functions that call themselves", int64(4)})
+		require.NoError(t, newRemediationService(db, mock).RunRemediation(context.Background(), payload))
+		require.Len(t, mock.GenerateRequests, 1)
+		assert.Contains(t, mock.GenerateRequests[0].LessonSummary, "Functions that call themselves")
+	})
+
+	t.Run("Already ready", func(t *testing.T) {
+		mock := &testsupport.MockIntelligence{}
+		require.NoError(t, newRemediationService(testsupport.RowsDB(columns), mock).RunRemediation(context.Background(), payload))
+		assert.Empty(t, mock.GenerateRequests)
+	})
+}
+
+// TestGetRemediation tests reading a remediation lesson and its outcome
+func TestGetRemediation(t *testing.T) {
+	id := uuid.New()
+	userID := uuid.New()
+	lessonID := uuid.New()
+	completedAt := testsupport.Epoch.Add(time.Hour)
+
+	db := testsupport.RowsDB(remediationColumns, []driver.Value{
+		id.String(), userID.String(), "Recursion", lessonID.String(), "selected", "completed", "Recursion", nil, nil,
+		"intelligence service unavailable", int64(3), testsupport.Epoch, testsupport.Epoch, completedAt,
+		int64(4), int64(1), int64(2), int64(2),
+	})
+	lesson, err := newRemediationService(db, &testsupport.MockIntelligence{}).GetRemediation(userID, id)
+	require.NoError(t, err)
+	assert.Equal(t, "/ngs/lessons/"+lessonID.String(), lesson.Endpoint, "a selected lesson opens where it lives")
+	require.NotNil(t, lesson.Outcome)
+	assert.Equal(t, 2, lesson.Outcome.After.Answered)
+	require.NotNil(t, lesson.Outcome.Improved)
+	assert.True(t, *lesson.Outcome.Improved)
+
+	db = testsupport.RowsDB(remediationColumns, []driver.Value{
+		id.String(), userID.String(), "loops", nil, "generated", "ready", "Loops, again", "# Loops", []byte(`{"summary": "Loops"}`),
+		nil, int64(3), testsupport.Epoch, testsupport.Epoch, nil,
+		nil, nil, nil, nil,
+	})
+	lesson, err = newRemediationService(db, &testsupport.MockIntelligence{}).GetRemediation(userID, id)
+	require.NoError(t, err)
+	assert.Equal(t, "/ngs/remediation/"+id.String(), lesson.Endpoint)
+	assert.Equal(t, "# Loops", *lesson.ContentMarkdown)
+	assert.Nil(t, lesson.Outcome, "no outcome until completed")
+
+	_, err = newRemediationService(testsupport.RowsDB(remediationColumns), &testsupport.MockIntelligence{}).GetRemediation(userID, id)
+	assert.ErrorIs(t, err, services.ErrRemediationNotFound)
+
+	_, err = newRemediationService(db, &testsupport.MockIntelligence{}).CompleteRemediation(userID, id)
+	assert.ErrorIs(t, err, services.ErrRemediationNotReady, "the row still reads as ready")
+}
+
+// TestRemediationHandlers tests the remediation routes' request checks
+func TestRemediationHandlers(t *testing.T) {
+	service := newRemediationService(testsupport.RowsDB(remediationColumns), &testsupport.MockIntelligence{})
+	handler := handlers.NewRemediationHandler(service)
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/remediation", handler.ListRemediation)
+	app.Get("/ngs/remediation/:id", handler.GetRemediation)
+	app.Post("/ngs/remediation/:id/complete", handler.CompleteRemediation)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/ngs/remediation", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+
+	body := getList(t, app, "/ngs/remediation")
+	assert.JSONEq(t, `[]`, string(body["lessons"]))
+
+	req := httptest.NewRequest("GET", "/ngs/remediation/not-a-uuid", nil)
+	req.Header.Set("X-User-Id", uuid.NewString())
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	req = httptest.NewRequest("POST", "/ngs/remediation/"+uuid.NewString()+"/complete", nil)
+	req.Header.Set("X-User-Id", uuid.NewString())
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"noble-ngs-curriculum/internal/config"
+	"noble-ngs-curriculum/internal/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRetentionPolicies tests policy configuration and manual run validation
+func TestRetentionPolicies(t *testing.T) {
+	cfg := &config.Config{
+		ChatSessionArchiveDays:  180,
+		StaleDraftDays:          0,
+		XPMetadataRetentionDays: 365,
+		IdempotencyKeyDays:      1,
+	}
+	svc := services.NewRetentionService(nil, cfg, services.SystemClock{})
+
+	t.Run("Policies reflect configuration", func(t *testing.T) {
+		policies := svc.Policies()
+		require.Len(t, policies, 4)
+
+		byName := map[string]bool{}
+		for _, p := range policies {
+			byName[p.Name] = p.Enabled
+			assert.Nil(t, p.LastRun, p.Name)
+		}
+		assert.True(t, byName[services.RetentionChatSessions])
+		assert.False(t, byName[services.RetentionStaleDrafts])
+		assert.True(t, byName[services.RetentionXPMetadata])
+		assert.True(t, byName[services.RetentionIdempotency])
+	})
+
+	t.Run("Unknown policy", func(t *testing.T) {
+		_, err := svc.RunPolicy(context.Background(), "audit_log_purge")
+		assert.True(t, errors.Is(err, services.ErrUnknownRetentionPolicy))
+	})
+
+	t.Run("Disabled policy", func(t *testing.T) {
+		_, err := svc.RunPolicy(context.Background(), services.RetentionStaleDrafts)
+		assert.True(t, errors.Is(err, services.ErrRetentionPolicyDisabled))
+	})
+}
@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEnforceLimits tests that usage over a limit fails the case with feedback naming the limit
+func TestEnforceLimits(t *testing.T) {
+	limits := models.ResourceLimits{TimeLimitMs: 1000, CPULimitMs: 500, MemoryLimitMB: 256}
+	results := &models.TestResults{Tests: []models.TestCaseResult{
+		{Name: "fits", Status: models.TestPassed, RuntimeMs: 20, CPUMs: 15, MemoryKB: 64 << 10},
+		{Name: "hungry", Status: models.TestPassed, RuntimeMs: 20, CPUMs: 15, MemoryKB: 300 << 10},
+		{Name: "busy", Status: models.TestPassed, RuntimeMs: 700, CPUMs: 650, MemoryKB: 1024},
+		{Name: "slow", Status: models.TestFailed, RuntimeMs: 1500, CPUMs: 10, MemoryKB: 1024},
+		{Name: "killed", Status: models.TestMemoryExceeded},
+	}}
+
+	services.EnforceLimits(results, limits)
+	score := services.GradeTestResults(results)
+
+	require.NotNil(t, results.Limits)
+	assert.Equal(t, limits, *results.Limits)
+	assert.Equal(t, 20, score)
+
+	assert.Equal(t, models.TestPassed, results.Tests[0].Status)
+	assert.Equal(t, models.TestMemoryExceeded, results.Tests[1].Status)
+	assert.Equal(t, "Used 300.0 MB, over the 256 MB memory limit", results.Tests[1].Feedback)
+	assert.Equal(t, models.TestTimeout, results.Tests[2].Status)
+	assert.Equal(t, "Used 650 ms of CPU, over the 500 ms limit", results.Tests[2].Feedback)
+	assert.Equal(t, models.TestTimeout, results.Tests[3].Status)
+	assert.Equal(t, "Ran past the 1000 ms time limit", results.Tests[3].Feedback)
+	assert.Equal(t, "Went over the 256 MB memory limit", results.Tests[4].Feedback, "a case the sandbox killed keeps its status")
+
+	t.Run("No limits", func(t *testing.T) {
+		results := &models.TestResults{Tests: []models.TestCaseResult{
+			{Name: "anything", Status: models.TestPassed, RuntimeMs: 1e6, CPUMs: 1e6, MemoryKB: 1 << 30},
+		}}
+		services.EnforceLimits(results, models.ResourceLimits{})
+		assert.Equal(t, models.TestPassed, results.Tests[0].Status)
+	})
+}
+
+// TestChallengeLimitDefaults tests that a challenge's unset limits fall back to the configured defaults
+func TestChallengeLimitDefaults(t *testing.T) {
+	cfg := progressConfig()
+	cfg.SandboxTimeLimitMs = 5000
+	cfg.SandboxCPULimitMs = 2000
+	cfg.SandboxMemoryLimitMB = 256
+
+	id := uuid.New()
+	row := challengeRow(id, nil)
+	row[19] = int64(64) // run_memory_limit_mb
+
+	challenge, err := services.NewChallengeService(testsupport.RowsDB(challengeColumns, row), cfg, nil).GetChallenge(id)
+	require.NoError(t, err)
+	assert.Equal(t, models.ResourceLimits{TimeLimitMs: 5000, CPULimitMs: 2000, MemoryLimitMB: 64}, challenge.Limits)
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"errors"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestValidateSettingsUpdate tests settings validation
+func TestValidateSettingsUpdate(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	t.Run("Empty update is valid", func(t *testing.T) {
+		assert.NoError(t, services.ValidateSettingsUpdate(models.UpdateSettingsRequest{}))
+	})
+
+	t.Run("Locale format", func(t *testing.T) {
+		assert.NoError(t, services.ValidateSettingsUpdate(models.UpdateSettingsRequest{Locale: str("en-US")}))
+		err := services.ValidateSettingsUpdate(models.UpdateSettingsRequest{Locale: str("english")})
+		assert.True(t, errors.Is(err, services.ErrInvalidSettings))
+	})
+
+	t.Run("Timezone must be a known IANA zone", func(t *testing.T) {
+		assert.NoError(t, services.ValidateSettingsUpdate(models.UpdateSettingsRequest{Timezone: str("UTC")}))
+		assert.Error(t, services.ValidateSettingsUpdate(models.UpdateSettingsRequest{Timezone: str("Mars/Olympus")}))
+		assert.Error(t, services.ValidateSettingsUpdate(models.UpdateSettingsRequest{Timezone: str("Local")}))
+	})
+
+	t.Run("Age band must be known", func(t *testing.T) {
+		assert.NoError(t, services.ValidateSettingsUpdate(models.UpdateSettingsRequest{AgeBand: str(services.AgeBandTeen)}))
+		assert.Error(t, services.ValidateSettingsUpdate(models.UpdateSettingsRequest{AgeBand: str("toddler")}))
+	})
+
+	t.Run("Track order must use known, unique tracks", func(t *testing.T) {
+		valid := &models.ContentPreferences{PreferredTrackOrder: []string{"ml_engineering", "core"}}
+		assert.NoError(t, services.ValidateSettingsUpdate(models.UpdateSettingsRequest{Content: valid}))
+
+		unknown := &models.ContentPreferences{PreferredTrackOrder: []string{"astrology"}}
+		assert.Error(t, services.ValidateSettingsUpdate(models.UpdateSettingsRequest{Content: unknown}))
+
+		duplicate := &models.ContentPreferences{PreferredTrackOrder: []string{"core", "core"}}
+		assert.Error(t, services.ValidateSettingsUpdate(models.UpdateSettingsRequest{Content: duplicate}))
+	})
+
+	t.Run("Learning preferences must be known options", func(t *testing.T) {
+		valid := &models.LearningPreferencesPatch{Examples: str("theory"), ProgrammingLanguage: str("rust")}
+		assert.NoError(t, services.ValidateSettingsUpdate(models.UpdateSettingsRequest{Learning: valid}))
+
+		for _, invalid := range []*models.LearningPreferencesPatch{
+			{Examples: str("lots")},
+			{CodeStyle: str("")},
+			{ProgrammingLanguage: str("Python")},
+		} {
+			err := services.ValidateSettingsUpdate(models.UpdateSettingsRequest{Learning: invalid})
+			assert.True(t, errors.Is(err, services.ErrInvalidSettings))
+		}
+	})
+}
+
+// TestLearningPreferences tests the learning preference defaults and their learner profile form
+func TestLearningPreferences(t *testing.T) {
+	defaults := services.DefaultUserSettings(uuid.New()).Learning
+	assert.Equal(t, models.LearningPreferences{Examples: "balanced", CodeStyle: "balanced", ProgrammingLanguage: "python"}, defaults)
+
+	options := services.LearningPreferenceOptions()
+	assert.Contains(t, options.Examples, defaults.Examples)
+	assert.Contains(t, options.CodeStyle, defaults.CodeStyle)
+	assert.Contains(t, options.ProgrammingLanguages, defaults.ProgrammingLanguage)
+
+	assert.Equal(t, map[string]interface{}{
+		"examples":             "examples",
+		"code_style":           "code_heavy",
+		"programming_language": "go",
+	}, services.LearnerProfilePreferences(models.LearningPreferences{Examples: "examples", CodeStyle: "code_heavy", ProgrammingLanguage: "go"}))
+}
+
+// TestIsMinorAgeBand tests which age bands get minor-account restrictions
+func TestIsMinorAgeBand(t *testing.T) {
+	assert.True(t, services.IsMinorAgeBand(services.AgeBandChild))
+	assert.True(t, services.IsMinorAgeBand(services.AgeBandTeen))
+	assert.False(t, services.IsMinorAgeBand(services.AgeBandAdult))
+}
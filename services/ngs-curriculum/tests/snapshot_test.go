@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/database"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signedSnapshot is a snapshot of userID signed by snapshotService
+func signedSnapshot(t *testing.T, snapshotService *services.SnapshotService, userID uuid.UUID) models.LearnerSnapshot {
+	snapshot := models.LearnerSnapshot{
+		Version:       services.SnapshotVersion,
+		UserID:        userID,
+		SchemaVersion: database.RequiredSchemaVersion,
+		CreatedAt:     time.Date(2026, 3, 4, 12, 0, 0, 123456789, time.UTC),
+		CreatedBy:     uuid.New(),
+		Note:          "Before fixing the streak",
+		Tables: map[string]json.RawMessage{
+			"user_progress": json.RawMessage(`[{"user_id": "` + userID.String() + `", "total_xp": 450, "current_level": 3}]`),
+			"xp_events":     json.RawMessage(`[]`),
+		},
+	}
+	require.NoError(t, snapshotService.SignSnapshot(&snapshot))
+	return snapshot
+}
+
+// TestSnapshotSignature tests signing and verifying learner snapshot bundles
+func TestSnapshotSignature(t *testing.T) {
+	cfg := progressConfig()
+	cfg.LearnerSnapshotKey = "snapshot-key"
+	snapshotService := services.NewSnapshotService(nil, cfg, services.SystemClock{})
+	userID := uuid.New()
+
+	t.Run("Round trip survives reformatting", func(t *testing.T) {
+		snapshot := signedSnapshot(t, snapshotService, userID)
+		indented, err := json.MarshalIndent(snapshot, "", "    ")
+		require.NoError(t, err)
+
+		var downloaded models.LearnerSnapshot
+		require.NoError(t, json.Unmarshal(indented, &downloaded))
+		assert.NoError(t, snapshotService.VerifySnapshot(downloaded, userID))
+	})
+
+	t.Run("Edited bundles are refused", func(t *testing.T) {
+		snapshot := signedSnapshot(t, snapshotService, userID)
+		snapshot.Tables["user_progress"] = json.RawMessage(`[{"user_id": "` + userID.String() + `", "total_xp": 99999}]`)
+		assert.ErrorIs(t, snapshotService.VerifySnapshot(snapshot, userID), services.ErrSnapshotSignature)
+
+		otherCfg := progressConfig()
+		otherCfg.LearnerSnapshotKey = "another-environment"
+		assert.ErrorIs(t, services.NewSnapshotService(nil, otherCfg, services.SystemClock{}).VerifySnapshot(signedSnapshot(t, snapshotService, userID), userID),
+			services.ErrSnapshotSignature, "environments must share the key")
+	})
+
+	t.Run("Restore target", func(t *testing.T) {
+		assert.ErrorIs(t, snapshotService.VerifySnapshot(signedSnapshot(t, snapshotService, userID), uuid.New()), services.ErrSnapshotRestore)
+
+		newer := signedSnapshot(t, snapshotService, userID)
+		newer.SchemaVersion = database.RequiredSchemaVersion + 1
+		require.NoError(t, snapshotService.SignSnapshot(&newer))
+		assert.ErrorIs(t, snapshotService.VerifySnapshot(newer, userID), services.ErrSnapshotRestore)
+
+		unknown := signedSnapshot(t, snapshotService, userID)
+		unknown.Tables["users"] = json.RawMessage(`[]`)
+		require.NoError(t, snapshotService.SignSnapshot(&unknown))
+		assert.ErrorIs(t, snapshotService.VerifySnapshot(unknown, userID), services.ErrInvalidSnapshot)
+	})
+
+	t.Run("Disabled without a key", func(t *testing.T) {
+		disabled := services.NewSnapshotService(nil, progressConfig(), services.SystemClock{})
+		assert.ErrorIs(t, disabled.VerifySnapshot(signedSnapshot(t, snapshotService, userID), userID), services.ErrSnapshotsDisabled)
+	})
+}
@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"noble-ngs-curriculum/internal/api"
+	"noble-ngs-curriculum/internal/clients/blobstore"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var submissionColumns = []string{
+	"id", "user_id", "challenge_id", "submission_code", "test_results",
+	"passed", "score", "feedback", "time_taken_seconds", "is_shared", "submitted_at",
+	"code_ref", "test_results_ref", "runtime_ms", "memory_kb",
+	"review_status", "writeup", "assets", "evaluation",
+}
+
+// TestBlobStores tests the file and HTTP blob stores round-trip objects
+func TestBlobStores(t *testing.T) {
+	ctx := context.Background()
+
+	objects := map[string][]byte{}
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.Method {
+		case "PUT":
+			objects[r.URL.Path], _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+		case "GET":
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		case "DELETE":
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	stores := map[string]blobstore.Store{
+		"fs":   blobstore.NewFileStore(t.TempDir()),
+		"http": blobstore.NewHTTPStore(server.URL+"/bucket/", "token"),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			key := "submissions/user/submission/code.txt"
+			require.NoError(t, store.Put(ctx, key, []byte("print('hi')"), "text/plain"))
+			data, err := store.Get(ctx, key)
+			require.NoError(t, err)
+			assert.Equal(t, "print('hi')", string(data))
+
+			require.NoError(t, store.Delete(ctx, key))
+			_, err = store.Get(ctx, key)
+			assert.ErrorIs(t, err, blobstore.ErrNotFound)
+			assert.NoError(t, store.Delete(ctx, key), "deleting a missing object should succeed")
+
+			assert.Error(t, store.Put(ctx, "../escape", []byte("x"), "text/plain"))
+		})
+	}
+
+	t.Run("Selection", func(t *testing.T) {
+		store, err := blobstore.NewStore("none", "", "")
+		require.NoError(t, err)
+		assert.Nil(t, store)
+		_, err = blobstore.NewStore("fs", "", "")
+		assert.Error(t, err)
+		_, err = blobstore.NewStore("s4", "http://blobs", "")
+		assert.Error(t, err)
+	})
+}
+
+// TestSubmissionSizeLimit tests that oversize code is rejected before anything is stored
+func TestSubmissionSizeLimit(t *testing.T) {
+	cfg := progressConfig()
+	cfg.SubmissionMaxCodeBytes = 16
+	challengeService := services.NewChallengeService(testsupport.RowsDB(nil), cfg, nil)
+
+	_, err := challengeService.SubmitChallenge(uuid.New(), models.SubmitChallengeRequest{
+		ChallengeID:    uuid.New(),
+		SubmissionCode: strings.Repeat("x", 17),
+	})
+	assert.ErrorIs(t, err, services.ErrSubmissionTooLarge)
+}
+
+// TestOffloadedSubmissionHistory tests that offloaded code and test results are read back from
+// the blob store
+func TestOffloadedSubmissionHistory(t *testing.T) {
+	ctx := context.Background()
+	userID, submissionID := uuid.New(), uuid.New()
+	store := blobstore.NewFileStore(t.TempDir())
+	codeKey := "submissions/" + userID.String() + "/" + submissionID.String() + "/code.txt"
+	resultsKey := "submissions/" + userID.String() + "/" + submissionID.String() + "/test_results.json"
+	require.NoError(t, store.Put(ctx, codeKey, []byte("def solve(): pass"), "text/plain"))
+	require.NoError(t, store.Put(ctx, resultsKey, []byte(`{"total_tests":3}`), "application/json"))
+
+	db := testsupport.RowsDB(submissionColumns,
+		[]driver.Value{
+			submissionID.String(), userID.String(), uuid.New().String(), "", nil,
+			true, int64(100), "Great", nil, false, time.Now(),
+			codeKey, resultsKey, nil, nil,
+			"graded", "", nil, nil,
+		},
+		[]driver.Value{
+			uuid.New().String(), userID.String(), uuid.New().String(), "x = 1", []byte(`{"total_tests":1}`),
+			true, int64(100), "Great", int64(30), false, time.Now(),
+			nil, nil, float64(12.5), int64(2048),
+			"graded", "", nil, nil,
+		},
+	)
+
+	submissions, err := services.NewChallengeService(db, progressConfig(), store).GetUserSubmissions(userID, api.Page{Limit: 20}, nil)
+	require.NoError(t, err)
+	require.Len(t, submissions, 2)
+	assert.Equal(t, "def solve(): pass", submissions[0].SubmissionCode)
+	require.NotNil(t, submissions[0].TestResults)
+	assert.Equal(t, 3, submissions[0].TestResults.TotalTests)
+	assert.Equal(t, "x = 1", submissions[1].SubmissionCode, "inline submissions should be unchanged")
+	assert.Equal(t, 30, submissions[1].TimeTakenSeconds)
+	assert.Equal(t, 12.5, submissions[1].RuntimeMs)
+	assert.Equal(t, int64(2048), submissions[1].MemoryKB)
+
+	t.Run("Missing blob store", func(t *testing.T) {
+		_, err := services.NewChallengeService(db, progressConfig(), nil).GetUserSubmissions(userID, api.Page{Limit: 20}, nil)
+		assert.Error(t, err)
+	})
+}
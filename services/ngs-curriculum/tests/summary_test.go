@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http/httptest"
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCalculateStreak tests daily streak counting
+func TestCalculateStreak(t *testing.T) {
+	today := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	day := func(offset int) time.Time { return today.AddDate(0, 0, -offset) }
+
+	t.Run("No activity", func(t *testing.T) {
+		streak := services.CalculateStreak(nil, today)
+		assert.Equal(t, 0, streak.Days)
+		assert.Equal(t, "none", streak.Status)
+	})
+
+	t.Run("Active today", func(t *testing.T) {
+		streak := services.CalculateStreak([]time.Time{day(0), day(1), day(2), day(4)}, today)
+		assert.Equal(t, 3, streak.Days, "Gap should end the streak")
+		assert.Equal(t, "active", streak.Status)
+	})
+
+	t.Run("Last active yesterday is at risk", func(t *testing.T) {
+		streak := services.CalculateStreak([]time.Time{day(1), day(2)}, today)
+		assert.Equal(t, 2, streak.Days)
+		assert.Equal(t, "at_risk", streak.Status)
+	})
+
+	t.Run("Last active two days ago is broken", func(t *testing.T) {
+		streak := services.CalculateStreak([]time.Time{day(2), day(3)}, today)
+		assert.Equal(t, 0, streak.Days)
+		assert.Equal(t, "none", streak.Status)
+	})
+}
+
+// TestActivityHeatmap tests the year bounds of the heatmap route and the totals over its days
+func TestActivityHeatmap(t *testing.T) {
+	db := testsupport.RowsDB([]string{"day", "count", "xp"},
+		[]driver.Value{"2025-01-01", int64(2), int64(60)},
+		[]driver.Value{"2025-01-02", int64(5), int64(150)},
+		[]driver.Value{"2025-03-10", int64(1), int64(10)},
+	)
+	handler := handlers.NewSummaryHandler(services.NewSummaryService(db, services.SystemClock{}))
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/activity/heatmap", handler.GetActivityHeatmap)
+
+	get := func(query string) (int, models.ActivityHeatmap) {
+		req := httptest.NewRequest("GET", "/ngs/activity/heatmap"+query, nil)
+		req.Header.Set("X-User-Id", uuid.NewString())
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var heatmap models.ActivityHeatmap
+		if resp.StatusCode == fiber.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&heatmap))
+		}
+		return resp.StatusCode, heatmap
+	}
+
+	status, heatmap := get("?year=2025")
+	require.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, 2025, heatmap.Year)
+	require.Len(t, heatmap.Days, 3)
+	assert.Equal(t, models.ActivityDay{Date: "2025-01-02", Count: 5, XP: 150}, heatmap.Days[1])
+	assert.Equal(t, 8, heatmap.TotalCount)
+	assert.Equal(t, 3, heatmap.ActiveDays)
+	assert.Equal(t, 5, heatmap.MaxCount)
+
+	status, heatmap = get("")
+	require.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, time.Now().Year(), heatmap.Year, "defaults to the current year")
+
+	status, _ = get("?year=" + strconv.Itoa(time.Now().Year()+1))
+	assert.Equal(t, fiber.StatusOK, status, "next year is accepted")
+	status, _ = get("?year=" + strconv.Itoa(time.Now().Year()+2))
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = get("?year=1999")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+}
@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"noble-ngs-curriculum/internal/api"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseTestCases tests that unnamed cases are numbered and NULL means no cases
+func TestParseTestCases(t *testing.T) {
+	tests, err := services.ParseTestCases(json.RawMessage(`[{"input": [1, 2], "expected": 3}, {"name": "negatives", "input": [-1, -2], "expected": -3, "hidden": true}]`))
+	require.NoError(t, err)
+	require.Len(t, tests, 2)
+	assert.Equal(t, "Test 1", tests[0].Name)
+	assert.Equal(t, "negatives", tests[1].Name)
+	assert.True(t, tests[1].Hidden)
+	assert.Equal(t, 1, tests[0].Points, "cases are worth a point unless they say otherwise")
+
+	tests, err = services.ParseTestCases(json.RawMessage(`[{"input": 1, "points": 3}]`))
+	require.NoError(t, err)
+	assert.Equal(t, 3, tests[0].Points)
+
+	_, err = services.ParseTestCases(json.RawMessage(`[{"input": 1, "points": -1}]`))
+	assert.Error(t, err)
+
+	tests, err = services.ParseTestCases(nil)
+	require.NoError(t, err)
+	assert.Empty(t, tests)
+
+	_, err = services.ParseTestCases(json.RawMessage(`{"not": "a list"}`))
+	assert.Error(t, err)
+}
+
+// TestGradeTestResults tests counts, score and per-test feedback
+func TestGradeTestResults(t *testing.T) {
+	results := &models.TestResults{Tests: []models.TestCaseResult{
+		{Name: "adds", Status: models.TestPassed, RuntimeMs: 2.5},
+		{Name: "carries", Status: models.TestFailed, Expected: json.RawMessage(`10`), Actual: json.RawMessage(`0`)},
+		{Name: "secret", Status: models.TestFailed, Hidden: true, Input: json.RawMessage(`[9]`), Expected: json.RawMessage(`1`)},
+		{Name: "crashes", Status: models.TestError, Output: "ZeroDivisionError: division by zero\n  at line 3"},
+		{Name: "loops", Status: models.TestTimeout},
+	}}
+
+	score := services.GradeTestResults(results)
+	assert.Equal(t, 20, score)
+	assert.Equal(t, 5, results.TotalTests)
+	assert.Equal(t, 1, results.PassedTests)
+	assert.Equal(t, 4, results.FailedTests)
+
+	assert.Equal(t, "Passed in 2.5 ms", results.Tests[0].Feedback)
+	assert.Equal(t, "Expected 10, got 0", results.Tests[1].Feedback)
+	assert.Equal(t, "Failed a hidden test case", results.Tests[2].Feedback)
+	assert.Equal(t, "Your code raised an error: ZeroDivisionError: division by zero", results.Tests[3].Feedback)
+	assert.Equal(t, "Ran past the time limit", results.Tests[4].Feedback)
+
+	redacted := services.RedactHiddenTests(results)
+	assert.Nil(t, redacted.Tests[2].Input)
+	assert.Nil(t, redacted.Tests[2].Expected)
+	assert.Equal(t, json.RawMessage(`10`), redacted.Tests[1].Expected, "visible cases keep their output")
+	assert.NotNil(t, results.Tests[2].Expected, "redaction should not change the stored results")
+
+	assert.Equal(t, 1, results.EarnedPoints)
+	assert.Equal(t, 5, results.MaxPoints)
+	assert.True(t, results.Tests[0].Passed)
+	assert.Equal(t, 1, results.Tests[0].PointsEarned)
+	assert.False(t, results.Tests[1].Passed)
+	assert.Zero(t, results.Tests[1].PointsEarned)
+
+	t.Run("Partial credit", func(t *testing.T) {
+		results := &models.TestResults{Tests: []models.TestCaseResult{
+			{Name: "basic", Status: models.TestPassed, Points: 1},
+			{Name: "edge cases", Status: models.TestPassed, Points: 3},
+			{Name: "large input", Status: models.TestTimeout, Points: 6},
+		}}
+		assert.Equal(t, 40, services.GradeTestResults(results), "4 of 10 points")
+		assert.Equal(t, 4, results.EarnedPoints)
+		assert.Equal(t, 10, results.MaxPoints)
+		assert.Equal(t, 2, results.PassedTests)
+		assert.Equal(t, 3, results.Tests[1].PointsEarned)
+		assert.Zero(t, results.Tests[2].PointsEarned)
+	})
+
+	t.Run("Grading error", func(t *testing.T) {
+		results := &models.TestResults{Error: "Failed to parse test cases"}
+		assert.Zero(t, services.GradeTestResults(results))
+		assert.NotNil(t, results.Tests)
+	})
+}
+
+// TestLegacyTestResults tests that results stored before the schema still read with their counts
+func TestLegacyTestResults(t *testing.T) {
+	userID := uuid.New()
+	legacy := []byte(`{"total_tests": 2, "passed_tests": 2, "failed_tests": 0, "test_details": [], "note": "Sandbox execution not yet implemented"}`)
+	db := testsupport.RowsDB(submissionColumns, []driver.Value{
+		uuid.New().String(), userID.String(), uuid.New().String(), "x = 1", legacy,
+		true, int64(100), "Great", nil, false, time.Now(), nil, nil, nil, nil,
+		"graded", "", nil, nil,
+	})
+
+	submissions, err := services.NewChallengeService(db, progressConfig(), nil).GetUserSubmissions(userID, api.Page{Limit: 20}, nil)
+	require.NoError(t, err)
+	require.Len(t, submissions, 1)
+	results := submissions[0].TestResults
+	require.NotNil(t, results)
+	assert.Equal(t, 2, results.TotalTests)
+	assert.Equal(t, 2, results.PassedTests)
+	assert.Equal(t, 2, results.EarnedPoints, "a point a case")
+	assert.Equal(t, 2, results.MaxPoints)
+	assert.Empty(t, results.Tests)
+}
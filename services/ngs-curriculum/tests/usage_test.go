@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewQuota tests limits, remaining allowance and unlimited quotas
+func TestNewQuota(t *testing.T) {
+	quota := services.NewQuota(300, 1000, nil)
+	require.NotNil(t, quota.Remaining)
+	assert.Equal(t, 700, *quota.Remaining)
+
+	quota = services.NewQuota(1200, 1000, nil)
+	assert.Equal(t, 0, *quota.Remaining, "overshooting the limit leaves nothing, not a negative allowance")
+
+	quota = services.NewQuota(5000, -1, nil)
+	assert.Nil(t, quota.Limit)
+	assert.Nil(t, quota.Remaining)
+}
+
+// TestTokensPerDay tests the token allowance of each tier
+func TestTokensPerDay(t *testing.T) {
+	cfg := progressConfig()
+	cfg.FreeTierTokensDay, cfg.BasicTierTokensDay, cfg.ProTierTokensDay = 1000, 50000, -1
+	usageService := services.NewUsageService(nil, cfg, services.SystemClock{})
+
+	assert.Equal(t, 1000, usageService.TokensPerDay(services.TierFreeTrial))
+	assert.Equal(t, 50000, usageService.TokensPerDay(services.TierBasic))
+	assert.Equal(t, -1, usageService.TokensPerDay(services.TierPro))
+	assert.Equal(t, 1000, usageService.TokensPerDay("enterprise"), "unknown tiers get the free allowance")
+}
+
+// TestGetUsageHandler tests the usage summary, including the gateway's forwarded rate limit
+func TestGetUsageHandler(t *testing.T) {
+	cfg := progressConfig()
+	cfg.FreeTierTokensDay, cfg.BasicTierTokensDay = 1000, 50000
+	clock := testsupport.NewFakeClock(time.Date(2026, 3, 4, 15, 30, 0, 0, time.UTC))
+	db := testsupport.RowsDB([]string{"tokens", "xp"}, []driver.Value{int64(1200), int64(85)})
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/me/usage", handlers.NewUsageHandler(services.NewUsageService(db, cfg, clock)).GetUsage)
+
+	get := func(headers map[string]string) models.UserUsage {
+		req := httptest.NewRequest("GET", "/ngs/me/usage", nil)
+		req.Header.Set("X-User-Id", uuid.NewString())
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, fiber.StatusOK, resp.StatusCode)
+		var usage models.UserUsage
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&usage))
+		return usage
+	}
+
+	usage := get(map[string]string{
+		"X-User-Tier":           "basic",
+		"X-RateLimit-Limit":     "100",
+		"X-RateLimit-Remaining": "58",
+		"X-RateLimit-Reset":     "1772638200",
+	})
+	assert.Equal(t, services.TierBasic, usage.Tier)
+	assert.Equal(t, 1200, usage.LLMTokens.Used)
+	assert.Equal(t, 48800, *usage.LLMTokens.Remaining)
+	assert.Equal(t, time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC), usage.LLMTokens.ResetsAt.UTC())
+	assert.Equal(t, 85, usage.DailyXP.Used)
+	assert.Nil(t, usage.DailyXP.Limit, "XP is not capped")
+	require.NotNil(t, usage.RateLimit)
+	assert.Equal(t, 42, usage.RateLimit.Used)
+	assert.Equal(t, 58, *usage.RateLimit.Remaining)
+	assert.Equal(t, time.Unix(1772638200, 0).UTC(), usage.RateLimit.ResetsAt.UTC())
+
+	usage = get(nil)
+	assert.Equal(t, services.TierFreeTrial, usage.Tier)
+	assert.Equal(t, 0, *usage.LLMTokens.Remaining, "the free allowance is used up")
+	assert.Nil(t, usage.RateLimit, "no rate limit without the gateway's headers")
+}
@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/cache"
+	"noble-ngs-curriculum/internal/clients/users"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// userServiceStub answers /users/batch with a profile for every ID in known
+type userServiceStub struct {
+	mu      sync.Mutex
+	known   map[uuid.UUID]users.Profile
+	batches [][]uuid.UUID
+	tokens  []string
+}
+
+func (s *userServiceStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		IDs []uuid.UUID `json:"ids"`
+	}
+	if r.URL.Path != "/users/batch" || json.NewDecoder(r.Body).Decode(&req) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, req.IDs)
+	s.tokens = append(s.tokens, r.Header.Get("X-Service-Token"))
+	found := []users.Profile{}
+	for _, id := range req.IDs {
+		if profile, ok := s.known[id]; ok {
+			found = append(found, profile)
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"users": found})
+}
+
+// TestUserProfiles tests batch lookups and their caching
+func TestUserProfiles(t *testing.T) {
+	ada, unknown := uuid.New(), uuid.New()
+	stub := &userServiceStub{known: map[uuid.UUID]users.Profile{
+		ada: {ID: ada, DisplayName: "Ada", AvatarURL: "https://cdn.example.com/ada.png"},
+	}}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+	client := users.NewClient(server.URL+"/", func() string { return "service-token" }, cache.NewMemory(), time.Minute)
+
+	profiles, err := client.Profiles(context.Background(), []uuid.UUID{ada, unknown, ada})
+	require.NoError(t, err)
+	assert.Equal(t, "Ada", profiles[ada].DisplayName)
+	assert.Equal(t, users.Profile{ID: unknown}, profiles[unknown])
+	require.Len(t, stub.batches, 1)
+	assert.ElementsMatch(t, []uuid.UUID{ada, unknown}, stub.batches[0], "each user is asked for once")
+	assert.Equal(t, "service-token", stub.tokens[0])
+
+	profiles, err = client.Profiles(context.Background(), []uuid.UUID{ada, unknown})
+	require.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/ada.png", profiles[ada].AvatarURL)
+	assert.Len(t, stub.batches, 1, "known and unknown users are both cached")
+
+	many := make([]uuid.UUID, 150)
+	for i := range many {
+		many[i] = uuid.New()
+	}
+	_, err = client.Profiles(context.Background(), many)
+	require.NoError(t, err)
+	require.Len(t, stub.batches, 3)
+	assert.Len(t, stub.batches[1], 100, "requests are batched")
+	assert.Len(t, stub.batches[2], 50)
+
+	down := users.NewClient("http://127.0.0.1:1", nil, nil, 0)
+	profiles, err = down.Profiles(context.Background(), []uuid.UUID{ada})
+	assert.Error(t, err)
+	assert.Equal(t, users.Profile{ID: ada}, profiles[ada])
+}
+
+// fakeUserDirectory names users from profiles, or fails with err
+type fakeUserDirectory struct {
+	profiles map[uuid.UUID]users.Profile
+	err      error
+	calls    int
+}
+
+func (f *fakeUserDirectory) Profiles(_ context.Context, ids []uuid.UUID) (map[uuid.UUID]users.Profile, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	found := make(map[uuid.UUID]users.Profile)
+	for _, id := range ids {
+		if profile, ok := f.profiles[id]; ok {
+			found[id] = profile
+		}
+	}
+	return found, nil
+}
+
+// TestLeaderboardUsernames tests that leaderboard entries are named in one directory lookup
+func TestLeaderboardUsernames(t *testing.T) {
+	cfg := progressConfig()
+	service, store := testsupport.NewProgressService(cfg)
+	ada, grace := uuid.New(), uuid.New()
+	store.PutProgress(testsupport.Progress().User(ada).XP(500, cfg.LevelUpXPThresholds).Build())
+	store.PutProgress(testsupport.Progress().User(grace).XP(300, cfg.LevelUpXPThresholds).Build())
+	directory := &fakeUserDirectory{profiles: map[uuid.UUID]users.Profile{
+		ada: {ID: ada, DisplayName: "Ada", AvatarURL: "https://cdn.example.com/ada.png"},
+	}}
+	service.SetUserDirectory(directory)
+
+	entries, err := service.GetLeaderboard(10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "Ada", entries[0].Username)
+	assert.Equal(t, "https://cdn.example.com/ada.png", entries[0].AvatarURL)
+	assert.Empty(t, entries[1].Username, "users the directory does not know stay unnamed")
+	assert.Equal(t, 1, directory.calls)
+
+	allTime, err := service.LeaderboardPeriod("")
+	require.NoError(t, err)
+	position, err := service.GetLeaderboardPosition(grace, allTime, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Ada", position.Above[0].Username)
+
+	directory.err = errors.New("user service down")
+	entries, err = service.GetLeaderboard(10)
+	require.NoError(t, err, "the leaderboard is served without names")
+	assert.Len(t, entries, 2)
+	assert.Empty(t, entries[0].Username)
+}
@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFoldWeakTopicSignals tests how misses and successes move a topic's score
+func TestFoldWeakTopicSignals(t *testing.T) {
+	at := func(minutes int) time.Time { return testsupport.Epoch.Add(time.Duration(minutes) * time.Minute) }
+	earlier := at(-60)
+	topics := map[string]*models.LearnerWeakTopic{
+		"Recursion": {Topic: "Recursion", Score: 1, QuizMisses: 1, LastMissedAt: &earlier},
+	}
+
+	changed := services.FoldWeakTopicSignals(topics, []services.WeakTopicSignal{
+		{Topic: "loops", Source: services.WeakTopicSourceChallenge, Missed: true, At: at(3)},
+		{Topic: "Recursion", Source: services.WeakTopicSourceQuiz, Missed: false, At: at(2)},
+		{Topic: "Recursion", Source: services.WeakTopicSourceQuiz, Missed: false, At: at(4)},
+		{Topic: "loops", Source: services.WeakTopicSourceChallenge, Missed: true, At: at(1)},
+		{Topic: "Level 2 reflection", Source: services.WeakTopicSourceReflection, Missed: true, At: at(5)},
+		{Topic: "Closures", Source: services.WeakTopicSourceQuiz, Missed: false, At: at(6)},
+	})
+
+	require.Len(t, changed, 3)
+	assert.NotContains(t, changed, "Closures", "a success on a topic never missed is not tracked")
+
+	recursion := changed["Recursion"]
+	assert.Equal(t, 0, recursion.Score, "the score does not go below 0")
+	assert.Equal(t, 1, recursion.Recoveries, "only the success that lowered the score counts")
+	assert.Equal(t, 1, recursion.QuizMisses)
+	assert.Equal(t, earlier, *recursion.LastMissedAt)
+
+	loops := changed["loops"]
+	assert.Equal(t, 2, loops.Score)
+	assert.Equal(t, 2, loops.ChallengeFailures)
+	assert.Equal(t, at(3), *loops.LastMissedAt, "signals apply in time order")
+
+	reflection := changed["Level 2 reflection"]
+	assert.Equal(t, 1, reflection.Score)
+	assert.Equal(t, 1, reflection.LowReflections)
+	assert.Same(t, loops, topics["loops"], "new topics are added to the map")
+}
+
+// TestWeakTopicsHandler tests that weak topics need a user
+func TestWeakTopicsHandler(t *testing.T) {
+	service := services.NewWeakTopicService(testsupport.RowsDB([]string{"topic"}), testsupport.Config(), testsupport.NewFakeClock(testsupport.Epoch))
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Get("/ngs/weak-topics", handlers.NewWeakTopicHandler(service).GetWeakTopics)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/ngs/weak-topics", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"noble-ngs-curriculum/internal/handlers"
+	"noble-ngs-curriculum/internal/middleware"
+	"noble-ngs-curriculum/internal/models"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNextXPReconciliation tests scheduling the nightly run
+func TestNextXPReconciliation(t *testing.T) {
+	now := time.Date(2026, 3, 4, 2, 30, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 3, 4, 3, 0, 0, 0, time.UTC), services.NextXPReconciliation(now, 3))
+	assert.Equal(t, time.Date(2026, 3, 5, 2, 0, 0, 0, time.UTC), services.NextXPReconciliation(now, 2))
+
+	atHour := time.Date(2026, 3, 4, 3, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 3, 5, 3, 0, 0, 0, time.UTC), services.NextXPReconciliation(atHour, 3),
+		"a run that is due now is scheduled for tomorrow")
+
+	local := time.Date(2026, 3, 4, 23, 30, 0, 0, time.FixedZone("EST", -5*60*60))
+	assert.Equal(t, time.Date(2026, 3, 6, 3, 0, 0, 0, time.UTC), services.NextXPReconciliation(local, 3),
+		"hours are UTC")
+}
+
+// TestXPDrift tests comparing totals with the ledger
+func TestXPDrift(t *testing.T) {
+	progress := models.UserProgress{UserID: uuid.New(), TotalXP: 450, CurrentLevel: 2}
+
+	assert.Nil(t, services.XPDrift(progress, 450, 2))
+
+	drift := services.XPDrift(progress, 500, 2)
+	require.NotNil(t, drift)
+	assert.Equal(t, progress.UserID, drift.UserID)
+	assert.Equal(t, -50, drift.Drift, "a total behind the ledger drifts negative")
+
+	drift = services.XPDrift(progress, 0, 2)
+	require.NotNil(t, drift)
+	assert.Equal(t, 450, drift.Drift)
+
+	drift = services.XPDrift(progress, 450, 3)
+	require.NotNil(t, drift, "a level behind the ledger drifts even when the total agrees")
+	assert.Equal(t, 0, drift.Drift)
+	assert.Equal(t, 2, drift.CurrentLevel)
+	assert.Equal(t, 3, drift.LedgerLevel)
+}
+
+// TestXPReconciliationStatus tests the reported schedule
+func TestXPReconciliationStatus(t *testing.T) {
+	cfg := progressConfig()
+	cfg.XPReconciliationHour, cfg.XPReconciliationRepair = 3, true
+	clock := testsupport.NewFakeClock(time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC))
+
+	status := services.NewReconciliationService(nil, cfg, clock).Status()
+	assert.True(t, status.Enabled)
+	assert.True(t, status.Repair)
+	require.NotNil(t, status.NextRunAt)
+	assert.Equal(t, time.Date(2026, 3, 5, 3, 0, 0, 0, time.UTC), *status.NextRunAt)
+	assert.Nil(t, status.LastRun)
+
+	cfg.XPReconciliationHour = -1
+	status = services.NewReconciliationService(nil, cfg, clock).Status()
+	assert.False(t, status.Enabled)
+	assert.Nil(t, status.NextRunAt)
+}
+
+// TestProgressReplayMode tests that progress is only rebuilt in projection mode
+func TestProgressReplayMode(t *testing.T) {
+	cfg := progressConfig()
+	cfg.ProgressMode = services.ProgressModeDirect
+
+	_, err := services.NewProgressProjection(nil, cfg, services.SystemClock{}).Replay(context.Background(), nil, false)
+	assert.ErrorIs(t, err, services.ErrProjectionDisabled)
+}
+
+// TestReconcileUserXPHandler tests the per-user reconciliation endpoint's checks
+func TestReconcileUserXPHandler(t *testing.T) {
+	db := testsupport.RowsDB([]string{"user_id", "total_xp", "current_level", "agent_creation_unlocked"})
+	handler := handlers.NewReconciliationHandler(services.NewReconciliationService(db, progressConfig(), services.SystemClock{}))
+	app := fiber.New()
+	app.Use(middleware.Auth(middleware.ModeHeaders, nil))
+	app.Post("/ngs/admin/reconcile-xp/:userId", handler.ReconcileUserXP)
+	send := func(path, role string) int {
+		req := httptest.NewRequest("POST", path, nil)
+		req.Header.Set("X-User-Id", uuid.NewString())
+		req.Header.Set("X-User-Role", role)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, fiber.StatusForbidden, send("/ngs/admin/reconcile-xp/"+uuid.NewString(), "student"))
+	assert.Equal(t, fiber.StatusBadRequest, send("/ngs/admin/reconcile-xp/not-a-uuid", "admin"))
+	assert.Equal(t, fiber.StatusNotFound, send("/ngs/admin/reconcile-xp/"+uuid.NewString()+"?dry_run=true", "admin"),
+		"a user without progress has nothing to reconcile")
+}
@@ -0,0 +1,440 @@
+package tests
+
+import (
+	"math"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"noble-ngs-curriculum/internal/domain/xp"
+	"noble-ngs-curriculum/internal/services"
+	"noble-ngs-curriculum/internal/testsupport"
+
+	"github.com/google/uuid"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLevelForXP tests levels on a threshold curve
+func TestLevelForXP(t *testing.T) {
+	thresholds := []int{0, 100, 250}
+	assert.Equal(t, 1, xp.LevelForXP(thresholds, 0))
+	assert.Equal(t, 1, xp.LevelForXP(thresholds, 99))
+	assert.Equal(t, 2, xp.LevelForXP(thresholds, 100))
+	assert.Equal(t, 3, xp.LevelForXP(thresholds, 10000))
+	assert.Equal(t, 1, xp.LevelForXP(thresholds, -5))
+	assert.Equal(t, 1, xp.LevelForXP(nil, 500), "an empty curve only has level 1")
+
+	t.Run("Levels are never lost", func(t *testing.T) {
+		assert.Equal(t, 3, xp.LevelAfterAward([]int{0, 500, 1000}, 3, 600))
+		assert.Equal(t, 3, xp.LevelAfterAward(thresholds, 2, 300))
+	})
+}
+
+// TestReplay tests rebuilding a total and level from the XP ledger
+func TestReplay(t *testing.T) {
+	thresholds := []int{0, 100, 250}
+
+	total, level := xp.Replay(thresholds, nil)
+	assert.Equal(t, 0, total)
+	assert.Equal(t, 1, level)
+
+	total, level = xp.Replay(thresholds, []int{50, 75, 25})
+	assert.Equal(t, 150, total)
+	assert.Equal(t, 2, level)
+
+	total, level = xp.Replay(thresholds, []int{300, -100})
+	assert.Equal(t, 200, total)
+	assert.Equal(t, 3, level, "a correction after a level-up does not take the level back")
+
+	total, level = xp.Replay(thresholds, []int{-100, 300})
+	assert.Equal(t, 200, total)
+	assert.Equal(t, 2, level, "the order of the ledger decides the level")
+}
+
+// TestAwardAmount tests explicit, configured and fallback awards
+func TestAwardAmount(t *testing.T) {
+	sources := map[string]int{"lesson_completion": 50, "disabled": 0}
+	assert.Equal(t, 75, xp.AwardAmount(sources, "lesson_completion", 75))
+	assert.Equal(t, 50, xp.AwardAmount(sources, "lesson_completion", 0))
+	assert.Equal(t, 50, xp.AwardAmount(sources, "lesson_completion", -3))
+	assert.Equal(t, 0, xp.AwardAmount(sources, "disabled", 0))
+	assert.Equal(t, xp.DefaultAward, xp.AwardAmount(sources, "mystery", 0))
+}
+
+// TestAgentUnlocked tests the agent creation gate
+func TestAgentUnlocked(t *testing.T) {
+	assert.False(t, xp.AgentUnlocked(false, 11, 12))
+	assert.True(t, xp.AgentUnlocked(false, 12, 12))
+	assert.True(t, xp.AgentUnlocked(true, 3, 12), "unlocks are kept")
+}
+
+// TestLevelProgress tests progress through a level, including the max level
+func TestLevelProgress(t *testing.T) {
+	thresholds := []int{0, 100, 250, 450}
+
+	t.Run("Start, middle and end of a level", func(t *testing.T) {
+		p := xp.Progress(thresholds, 2, 100)
+		assert.Equal(t, 0.0, p.Percent)
+		assert.Equal(t, 150, p.XPToNextLevel)
+
+		p = xp.Progress(thresholds, 2, 175)
+		assert.Equal(t, 50.0, p.Percent)
+		assert.Equal(t, 75, p.XPToNextLevel)
+		assert.False(t, p.MaxLevel)
+	})
+
+	t.Run("Max level", func(t *testing.T) {
+		p := xp.Progress(thresholds, 4, 9000)
+		assert.True(t, p.MaxLevel)
+		assert.Equal(t, 100.0, p.Percent)
+		assert.Equal(t, 0, p.XPToNextLevel)
+	})
+
+	t.Run("Levels outside the curve are clamped", func(t *testing.T) {
+		p := xp.Progress(thresholds, 9, 300)
+		assert.Equal(t, 4, p.Level)
+		assert.True(t, p.MaxLevel)
+
+		p = xp.Progress(thresholds, 0, 50)
+		assert.Equal(t, 1, p.Level)
+		assert.Equal(t, 50.0, p.Percent)
+
+		p = xp.Progress(nil, 1, 50)
+		assert.True(t, p.MaxLevel)
+	})
+
+	t.Run("XP outside the level is clamped", func(t *testing.T) {
+		p := xp.Progress(thresholds, 3, 100)
+		assert.Equal(t, 0.0, p.Percent)
+		assert.Equal(t, 350, p.XPToNextLevel)
+
+		p = xp.Progress(thresholds, 2, 300)
+		assert.Equal(t, 100.0, p.Percent)
+		assert.Equal(t, 0, p.XPToNextLevel)
+	})
+}
+
+// xpCurve turns random gaps into a valid curve: starting at 0 and strictly increasing
+func xpCurve(gaps []uint16) []int {
+	thresholds := []int{0}
+	for _, gap := range gaps {
+		thresholds = append(thresholds, thresholds[len(thresholds)-1]+int(gap)+1)
+	}
+	return thresholds
+}
+
+// TestLevelProgressProperties checks the progress math over random curves and XP totals
+func TestLevelProgressProperties(t *testing.T) {
+	config := &quick.Config{MaxCount: 2000}
+
+	t.Run("Progress for the level reached is consistent", func(t *testing.T) {
+		property := func(gaps []uint16, totalXP uint32) bool {
+			thresholds := xpCurve(gaps)
+			total := int(totalXP % 2000000)
+			level := xp.LevelForXP(thresholds, total)
+			p := xp.Progress(thresholds, level, total)
+
+			if p.Level != level || thresholds[level-1] > total || p.Percent < 0 || p.Percent > 100 {
+				return false
+			}
+			if level == len(thresholds) {
+				return p.MaxLevel && p.Percent == 100 && p.XPToNextLevel == 0
+			}
+			return !p.MaxLevel && p.Percent < 100 && p.XPToNextLevel == thresholds[level]-total && p.XPToNextLevel > 0
+		}
+		require.NoError(t, quick.Check(property, config))
+	})
+
+	t.Run("More XP never lowers the level or progress", func(t *testing.T) {
+		property := func(gaps []uint16, totalXP uint16, extra uint16) bool {
+			thresholds := xpCurve(gaps)
+			before, after := int(totalXP), int(totalXP)+int(extra)
+			levelBefore, levelAfter := xp.LevelForXP(thresholds, before), xp.LevelForXP(thresholds, after)
+			if levelAfter != levelBefore {
+				return levelAfter > levelBefore
+			}
+			return xp.Progress(thresholds, levelAfter, after).Percent >= xp.Progress(thresholds, levelBefore, before).Percent
+		}
+		require.NoError(t, quick.Check(property, config))
+	})
+
+	t.Run("Any curve, level and XP stays in range", func(t *testing.T) {
+		property := func(thresholds []int, level int, totalXP int) bool {
+			p := xp.Progress(thresholds, level, totalXP)
+			return p.Level >= 1 && p.Level <= xp.MaxLevel(thresholds) &&
+				p.Percent >= 0 && p.Percent <= 100 && !math.IsNaN(p.Percent) &&
+				(p.MaxLevel == (p.Level == xp.MaxLevel(thresholds)))
+		}
+		require.NoError(t, quick.Check(property, config))
+	})
+}
+
+// TestXPCalculators tests each XP formula
+func TestXPCalculators(t *testing.T) {
+	difficulty, err := xp.NewCalculator(xp.FormulaDifficulty, "")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		calculator xp.XPCalculator
+		award      xp.Award
+		want       int
+	}{
+		{"linear gives the base", xp.Linear{}, xp.Award{Base: 50, Repeats: 9, Difficulty: "hard"}, 50},
+		{"linear keeps a zero award", xp.Linear{}, xp.Award{Base: 0}, 0},
+		{"logarithmic first award in full", xp.Logarithmic{}, xp.Award{Base: 100}, 100},
+		{"logarithmic second award", xp.Logarithmic{}, xp.Award{Base: 100, Repeats: 1}, 59},
+		{"logarithmic fifth award", xp.Logarithmic{}, xp.Award{Base: 100, Repeats: 4}, 38},
+		{"logarithmic never below 1", xp.Logarithmic{}, xp.Award{Base: 1, Repeats: 1000}, 1},
+		{"logarithmic keeps a negative correction", xp.Logarithmic{}, xp.Award{Base: -20, Repeats: 3}, -20},
+		{"difficulty easy", difficulty, xp.Award{Base: 100, Difficulty: "easy"}, 100},
+		{"difficulty hard", difficulty, xp.Award{Base: 100, Difficulty: "hard"}, 150},
+		{"difficulty expert", difficulty, xp.Award{Base: 75, Difficulty: "expert"}, 150},
+		{"difficulty rounds", difficulty, xp.Award{Base: 10, Difficulty: "medium"}, 13},
+		{"difficulty unknown is unscaled", difficulty, xp.Award{Base: 100, Difficulty: "legendary"}, 100},
+		{"difficulty missing is unscaled", difficulty, xp.Award{Base: 100}, 100},
+		{"custom multiplier", xp.DifficultyScaled{Multipliers: map[string]float64{"hard": 3}}, xp.Award{Base: 20, Difficulty: "hard"}, 60},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.calculator.Amount(tc.award))
+		})
+	}
+}
+
+// TestNewXPCalculator tests selecting a formula from configuration
+func TestNewXPCalculator(t *testing.T) {
+	tests := []struct {
+		formula     string
+		multipliers string
+		want        string
+		wantErr     bool
+	}{
+		{"", "", xp.FormulaLinear, false},
+		{"linear", "", xp.FormulaLinear, false},
+		{"logarithmic", "", xp.FormulaLogarithmic, false},
+		{"difficulty", "easy=0.5,hard=2", xp.FormulaDifficulty, false},
+		{"quadratic", "", "", true},
+		{"difficulty", "hard", "", true},
+		{"difficulty", "hard=0", "", true},
+		{"difficulty", "hard=lots", "", true},
+	}
+	for _, tc := range tests {
+		calculator, err := xp.NewCalculator(tc.formula, tc.multipliers)
+		if tc.wantErr {
+			assert.Error(t, err, "%s %s", tc.formula, tc.multipliers)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, calculator.Name())
+	}
+}
+
+// TestAwardXPWithFormula tests AwardXP applying the configured formula
+func TestAwardXPWithFormula(t *testing.T) {
+	cfg := testsupport.Config()
+	cfg.XPRepeatWindowHours = 24
+
+	t.Run("Repeated sources diminish within the window", func(t *testing.T) {
+		service, store := testsupport.NewProgressService(cfg)
+		service.SetXPCalculator(xp.Logarithmic{})
+		userID := uuid.New()
+
+		var awarded []int
+		for i := 0; i < 3; i++ {
+			_, err := service.AwardXP(userID, "daily_streak", 100, nil)
+			require.NoError(t, err)
+		}
+		_, err := service.AwardXP(userID, "helping_others", 100, nil)
+		require.NoError(t, err)
+		store.Clock.Advance(25 * time.Hour)
+		_, err = service.AwardXP(userID, "daily_streak", 100, nil)
+		require.NoError(t, err)
+
+		events := store.XPEvents(userID)
+		for _, event := range events {
+			awarded = append(awarded, event.XPAwarded)
+		}
+		assert.Equal(t, []int{100, 59, 48, 100, 100}, awarded, "other sources and awards after the window are in full")
+		assert.Contains(t, string(events[1].Metadata), `"xp_base":100`)
+		assert.Contains(t, string(events[1].Metadata), `"xp_formula":"logarithmic"`)
+	})
+
+	t.Run("Difficulty comes from the award's metadata", func(t *testing.T) {
+		service, store := testsupport.NewProgressService(cfg)
+		calculator, err := xp.NewCalculator(xp.FormulaDifficulty, "")
+		require.NoError(t, err)
+		service.SetXPCalculator(calculator)
+		userID := uuid.New()
+
+		progress, err := service.AwardXP(userID, "challenge_solved", 0, map[string]interface{}{"difficulty": "hard"})
+		require.NoError(t, err)
+		assert.Equal(t, 150, progress.TotalXP)
+		assert.Equal(t, 150, store.XPEvents(userID)[0].XPAwarded)
+	})
+}
+
+// TestXPDecayCurves tests per-source shares for repeated awards
+func TestXPDecayCurves(t *testing.T) {
+	curves, err := xp.ParseDecayCurves("reflection_quality=1,1,0.5,0.25,0; lesson_completion=1,0.5")
+	require.NoError(t, err)
+
+	tests := []struct {
+		source  string
+		amount  int
+		earlier int
+		want    int
+	}{
+		{"reflection_quality", 25, 0, 25},
+		{"reflection_quality", 25, 1, 25},
+		{"reflection_quality", 25, 2, 13},
+		{"reflection_quality", 25, 3, 6},
+		{"reflection_quality", 25, 4, 0},
+		{"reflection_quality", 25, 40, 0},
+		{"lesson_completion", 50, 1, 25},
+		{"lesson_completion", 50, 9, 25},
+		{"challenge_solved", 100, 9, 100},
+		{"lesson_completion", -20, 5, -20},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, curves.Decayed(tc.source, tc.amount, tc.earlier), "%s after %d", tc.source, tc.earlier)
+	}
+
+	for _, spec := range []string{"reflection_quality", "=1,0.5", "lesson_completion=1,1.5", "lesson_completion=1,-0.5", "a=1;a=0.5"} {
+		_, err := xp.ParseDecayCurves(spec)
+		assert.Error(t, err, spec)
+	}
+	none, err := xp.ParseDecayCurves("none")
+	require.NoError(t, err)
+	assert.False(t, none.Decays("reflection_quality"))
+}
+
+// TestAwardXPDecay tests repeated awards from one source decaying within a day
+func TestAwardXPDecay(t *testing.T) {
+	curves, err := xp.ParseDecayCurves("daily_streak=1,0.5,0")
+	require.NoError(t, err)
+	services.ConfigureXPDecay(curves)
+	t.Cleanup(func() { services.ConfigureXPDecay(nil) })
+
+	service, store := testsupport.NewProgressService(testsupport.Config())
+	userID := uuid.New()
+	for i := 0; i < 3; i++ {
+		_, err := service.AwardXP(userID, "daily_streak", 20, nil)
+		require.NoError(t, err)
+	}
+	_, err = service.AwardXP(userID, "helping_others", 10, nil)
+	require.NoError(t, err)
+	store.Clock.Advance(25 * time.Hour)
+	progress, err := service.AwardXP(userID, "daily_streak", 20, nil)
+	require.NoError(t, err)
+
+	var awarded []int
+	events := store.XPEvents(userID)
+	for _, event := range events {
+		awarded = append(awarded, event.XPAwarded)
+	}
+	assert.Equal(t, []int{20, 10, 0, 10, 20}, awarded, "the curve starts again a day later")
+	assert.Equal(t, 60, progress.TotalXP)
+	assert.Contains(t, string(events[2].Metadata), `"xp_before_decay":20`)
+	assert.Contains(t, string(events[2].Metadata), `"xp_repeat":3`)
+}
+
+// TestLevelPolicy tests what happens to levels and agent unlocks when XP falls
+func TestLevelPolicy(t *testing.T) {
+	thresholds := []int{0, 100, 250, 450}
+
+	t.Run("Defaults never take levels or unlocks back", func(t *testing.T) {
+		policy, err := xp.NewLevelPolicy("", 0, "")
+		require.NoError(t, err)
+		assert.Equal(t, xp.LevelPolicy{LevelDown: xp.LevelDownNever, AgentUnlock: xp.AgentUnlockPermanent}, policy)
+		assert.Equal(t, 4, policy.Level(thresholds, 4, 120))
+		assert.Equal(t, 4, policy.Level(thresholds, 4, -50))
+		assert.Equal(t, 3, policy.Level(thresholds, 2, 300), "levels are still gained")
+		assert.True(t, policy.AgentUnlocked(true, 1, 4))
+		assert.Equal(t, xp.LevelAfterAward(thresholds, 4, 120), policy.Level(thresholds, 4, 120), "LevelAfterAward is the default policy")
+	})
+
+	t.Run("Strict follows XP down within the grace", func(t *testing.T) {
+		strict := xp.LevelPolicy{LevelDown: xp.LevelDownStrict}
+		assert.Equal(t, 2, strict.Level(thresholds, 4, 120))
+		assert.Equal(t, 1, strict.Level(thresholds, 4, -50))
+		assert.Equal(t, 4, strict.Level(thresholds, 4, 450), "the threshold itself keeps the level")
+
+		strict.GraceXP = 50
+		assert.Equal(t, 4, strict.Level(thresholds, 4, 400), "within the grace of level 4")
+		assert.Equal(t, 3, strict.Level(thresholds, 4, 399))
+		assert.Equal(t, 2, strict.Level(thresholds, 4, 150), "only levels within the grace of the total are kept")
+		assert.Equal(t, 2, strict.Level(thresholds, 2, 60), "grace never lifts a level")
+		assert.Equal(t, 4, strict.Level(thresholds, 2, 460), "grace does not stop levels being gained")
+	})
+
+	t.Run("Agent unlock can follow the level", func(t *testing.T) {
+		permanent := xp.LevelPolicy{AgentUnlock: xp.AgentUnlockPermanent}
+		assert.True(t, permanent.AgentUnlocked(true, 2, 4))
+		assert.False(t, permanent.AgentUnlocked(false, 3, 4))
+
+		byLevel := xp.LevelPolicy{AgentUnlock: xp.AgentUnlockLevel}
+		assert.False(t, byLevel.AgentUnlocked(true, 3, 4), "the unlock is lost below the unlock level")
+		assert.True(t, byLevel.AgentUnlocked(false, 4, 4))
+	})
+
+	t.Run("Replay applies the policy in ledger order", func(t *testing.T) {
+		total, level, unlocked := xp.LevelPolicy{}.Replay(thresholds, 4, []int{500, -300})
+		assert.Equal(t, 200, total)
+		assert.Equal(t, 4, level)
+		assert.True(t, unlocked)
+
+		total, level, unlocked = xp.LevelPolicy{LevelDown: xp.LevelDownStrict}.Replay(thresholds, 4, []int{500, -300})
+		assert.Equal(t, 200, total)
+		assert.Equal(t, 2, level)
+		assert.True(t, unlocked, "a permanent unlock survives the level-down")
+
+		_, _, unlocked = xp.LevelPolicy{LevelDown: xp.LevelDownStrict, AgentUnlock: xp.AgentUnlockLevel}.Replay(thresholds, 4, []int{500, -300})
+		assert.False(t, unlocked)
+	})
+
+	t.Run("Invalid settings", func(t *testing.T) {
+		_, err := xp.NewLevelPolicy("sometimes", 0, "")
+		assert.Error(t, err)
+		_, err = xp.NewLevelPolicy(xp.LevelDownStrict, -1, "")
+		assert.Error(t, err)
+		_, err = xp.NewLevelPolicy("", 0, "forever")
+		assert.Error(t, err)
+	})
+}
+
+// TestAwardXPLevelPolicy tests awards to a user whose XP is below their level after thresholds
+// were raised
+func TestAwardXPLevelPolicy(t *testing.T) {
+	award := func(levelDown string, graceXP int, agentUnlock string) (int, bool) {
+		cfg := testsupport.Config()
+		cfg.AgentUnlockLevel = 4
+		cfg.LevelDownPolicy, cfg.LevelDownGraceXP, cfg.AgentUnlockPolicy = levelDown, graceXP, agentUnlock
+		service, store := testsupport.NewProgressService(cfg)
+		userID := uuid.New()
+		progress := testsupport.Progress().User(userID).XP(300, cfg.LevelUpXPThresholds).AgentUnlocked().Build()
+		progress.CurrentLevel = 4
+		store.PutProgress(progress)
+
+		response, err := service.AwardXP(userID, "helping_others", 10, nil)
+		require.NoError(t, err)
+		return response.CurrentLevel, response.AgentCreationUnlocked
+	}
+
+	level, unlocked := award(xp.LevelDownNever, 0, xp.AgentUnlockPermanent)
+	assert.Equal(t, 4, level)
+	assert.True(t, unlocked)
+
+	level, unlocked = award(xp.LevelDownStrict, 0, xp.AgentUnlockPermanent)
+	assert.Equal(t, 3, level)
+	assert.True(t, unlocked)
+
+	level, _ = award(xp.LevelDownStrict, 200, xp.AgentUnlockPermanent)
+	assert.Equal(t, 4, level, "310 XP is within 200 of level 4")
+
+	level, unlocked = award(xp.LevelDownStrict, 0, xp.AgentUnlockLevel)
+	assert.Equal(t, 3, level)
+	assert.False(t, unlocked)
+}
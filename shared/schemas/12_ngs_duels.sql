@@ -0,0 +1,47 @@
+-- NGS Challenge Duels
+-- Head-to-head timed challenges with level-based matchmaking and Elo-style ratings
+
+CREATE TABLE IF NOT EXISTS duels (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  challenge_id UUID NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+  level_number INTEGER NOT NULL, -- Matchmaking bucket
+  player_one_id UUID NOT NULL, -- User who opened the duel
+  player_two_id UUID, -- Filled when an opponent is matched
+  status VARCHAR(20) NOT NULL DEFAULT 'waiting', -- waiting, active, completed, expired, cancelled
+  winner_id UUID, -- First player to submit a passing solution
+  time_limit_seconds INTEGER NOT NULL DEFAULT 600,
+  started_at TIMESTAMP, -- Shared countdown start
+  ends_at TIMESTAMP, -- Shared countdown deadline
+  completed_at TIMESTAMP,
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_duels_matchmaking ON duels(level_number, status, created_at);
+CREATE INDEX IF NOT EXISTS idx_duels_player_one ON duels(player_one_id);
+CREATE INDEX IF NOT EXISTS idx_duels_player_two ON duels(player_two_id);
+
+-- Duel submissions: every attempt made during a duel
+CREATE TABLE IF NOT EXISTS duel_submissions (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  duel_id UUID NOT NULL REFERENCES duels(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL,
+  submission_code TEXT,
+  passed BOOLEAN DEFAULT false,
+  score INTEGER CHECK (score >= 0 AND score <= 100),
+  submitted_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_duel_submissions_duel_id ON duel_submissions(duel_id);
+
+-- Duel ratings: one Elo-style rating per user
+CREATE TABLE IF NOT EXISTS duel_ratings (
+  user_id UUID PRIMARY KEY,
+  rating INTEGER NOT NULL DEFAULT 1200,
+  wins INTEGER DEFAULT 0,
+  losses INTEGER DEFAULT 0,
+  draws INTEGER DEFAULT 0,
+  updated_at TIMESTAMP DEFAULT NOW()
+);
+
+COMMENT ON TABLE duels IS 'One-vs-one timed challenge matches; first passing submission wins';
+COMMENT ON TABLE duel_ratings IS 'Elo-style duel ratings updated when a duel completes or expires';
@@ -0,0 +1,14 @@
+-- NGS Challenge Solution Reveal
+-- Lets learners opt in to sharing passing submissions as community solutions
+
+ALTER TABLE challenge_submissions
+ADD COLUMN IF NOT EXISTS is_shared BOOLEAN DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS idx_challenge_submissions_shared
+  ON challenge_submissions(challenge_id, score DESC)
+  WHERE passed = true AND is_shared = true;
+
+CREATE INDEX IF NOT EXISTS idx_challenge_submissions_user_challenge
+  ON challenge_submissions(user_id, challenge_id, passed);
+
+COMMENT ON COLUMN challenge_submissions.is_shared IS 'Learner opted in to show this passing submission as a community solution';
@@ -0,0 +1,38 @@
+-- NGS Level Exams
+-- Timed exam sessions (quiz questions + one challenge) that certify a level
+
+CREATE TABLE IF NOT EXISTS level_exams (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL,
+  level_number INTEGER NOT NULL,
+  status VARCHAR(20) NOT NULL DEFAULT 'in_progress', -- in_progress, submitted, expired
+  questions JSONB NOT NULL, -- Snapshot of questions including answer keys
+  challenge_id UUID REFERENCES challenges(id) ON DELETE SET NULL,
+  time_limit_seconds INTEGER NOT NULL,
+  started_at TIMESTAMP DEFAULT NOW(),
+  ends_at TIMESTAMP NOT NULL,
+  submitted_at TIMESTAMP,
+  quiz_score INTEGER CHECK (quiz_score >= 0 AND quiz_score <= 100),
+  challenge_score INTEGER CHECK (challenge_score >= 0 AND challenge_score <= 100),
+  total_score INTEGER CHECK (total_score >= 0 AND total_score <= 100),
+  passed BOOLEAN DEFAULT false,
+  results JSONB -- Per-question grading detail
+);
+
+CREATE INDEX IF NOT EXISTS idx_level_exams_user_level ON level_exams(user_id, level_number, status);
+
+-- Level certifications: a level is certified by passing its exam, distinct from reaching it by XP
+CREATE TABLE IF NOT EXISTS level_certifications (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL,
+  level_number INTEGER NOT NULL,
+  exam_id UUID REFERENCES level_exams(id) ON DELETE SET NULL,
+  score INTEGER NOT NULL,
+  certified_at TIMESTAMP DEFAULT NOW(),
+  UNIQUE(user_id, level_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_level_certifications_user_id ON level_certifications(user_id);
+
+COMMENT ON TABLE level_exams IS 'Time-boxed level exam sessions combining quiz questions and one challenge';
+COMMENT ON TABLE level_certifications IS 'Levels certified by exam; source of truth for certificates';
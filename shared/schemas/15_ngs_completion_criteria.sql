@@ -0,0 +1,15 @@
+-- NGS Lesson Completion Criteria
+-- Per-lesson rules evaluated before a lesson completion is accepted
+
+ALTER TABLE lessons
+ADD COLUMN IF NOT EXISTS completion_criteria JSONB DEFAULT '{}'::jsonb;
+
+-- Reflection lessons require a written reflection; quizzes require a passing score.
+-- Tutorials and exercises stay reading-only unless configured otherwise.
+UPDATE lessons SET completion_criteria = '{"min_reflection_words": 30}'::jsonb
+WHERE lesson_type = 'reflection' AND (completion_criteria IS NULL OR completion_criteria = '{}'::jsonb);
+
+UPDATE lessons SET completion_criteria = '{"min_quiz_score": 60}'::jsonb
+WHERE lesson_type = 'quiz' AND (completion_criteria IS NULL OR completion_criteria = '{}'::jsonb);
+
+COMMENT ON COLUMN lessons.completion_criteria IS 'Completion rules: min_reflection_words, min_quiz_score, required_artifact (completion metadata key)';
@@ -0,0 +1,4 @@
+-- NGS Activity Heatmap
+-- Covers the per-user, per-day grouping used by the activity heatmap and streaks
+
+CREATE INDEX IF NOT EXISTS idx_xp_events_user_created ON xp_events(user_id, created_at);
@@ -0,0 +1,19 @@
+-- NGS User Settings
+-- Per-user privacy, locale, notification and content preferences
+
+CREATE TABLE IF NOT EXISTS user_settings (
+  user_id UUID PRIMARY KEY,
+  show_on_leaderboard BOOLEAN NOT NULL DEFAULT true,
+  public_profile BOOLEAN NOT NULL DEFAULT false,
+  locale VARCHAR(16) NOT NULL DEFAULT 'en',
+  timezone VARCHAR(64) NOT NULL DEFAULT 'UTC', -- IANA zone name
+  notification_preferences JSONB NOT NULL DEFAULT '{"weekly_digest": true, "streak_reminders": true, "challenge_updates": true, "push_enabled": false}'::jsonb,
+  content_preferences JSONB NOT NULL DEFAULT '{"preferred_track_order": []}'::jsonb,
+  created_at TIMESTAMP DEFAULT NOW(),
+  updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_settings_hidden ON user_settings(user_id) WHERE show_on_leaderboard = false;
+
+COMMENT ON TABLE user_settings IS 'User preferences; absent rows mean all defaults';
+COMMENT ON COLUMN user_settings.content_preferences IS 'preferred_track_order: track keys (core, computer_science, data_science, ethical_ai, ml_engineering) in the order lessons are suggested';
@@ -0,0 +1,11 @@
+-- NGS Lesson Accessibility
+-- Plain-language summaries, alt-text for visuals and reading level for lesson content
+
+ALTER TABLE lessons
+ADD COLUMN IF NOT EXISTS plain_language_summary TEXT,
+ADD COLUMN IF NOT EXISTS alt_text JSONB DEFAULT '[]'::jsonb,
+ADD COLUMN IF NOT EXISTS reading_level NUMERIC(4,1);
+
+COMMENT ON COLUMN lessons.plain_language_summary IS 'Plain-language alternative to the lesson summary';
+COMMENT ON COLUMN lessons.alt_text IS 'Array of {visual, alt_text} for visual aids in generated content';
+COMMENT ON COLUMN lessons.reading_level IS 'Estimated US grade reading level of content_markdown';
@@ -0,0 +1,19 @@
+-- NGS Lesson Audio
+-- Cached text-to-speech renditions of lesson content, one per content version and voice
+
+CREATE TABLE IF NOT EXISTS lesson_audio (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  lesson_id UUID NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+  content_version INTEGER NOT NULL DEFAULT 0,
+  voice VARCHAR(100) NOT NULL,
+  provider VARCHAR(50) NOT NULL,
+  storage_path TEXT NOT NULL, -- Path relative to AUDIO_STORAGE_DIR
+  content_type VARCHAR(100) NOT NULL DEFAULT 'audio/mpeg',
+  size_bytes BIGINT NOT NULL DEFAULT 0,
+  created_at TIMESTAMP DEFAULT NOW(),
+  UNIQUE(lesson_id, content_version, voice)
+);
+
+CREATE INDEX IF NOT EXISTS idx_lesson_audio_lesson_id ON lesson_audio(lesson_id);
+
+COMMENT ON TABLE lesson_audio IS 'TTS audio cache; a new content_version produces a new rendition';
@@ -0,0 +1,25 @@
+-- NGS Minor Accounts & Guardian Consent
+-- Age bands on user settings and token-based guardian consent records
+
+ALTER TABLE user_settings
+ADD COLUMN IF NOT EXISTS age_band VARCHAR(16) NOT NULL DEFAULT 'adult'; -- child (<13), teen (13-17), adult
+
+CREATE INDEX IF NOT EXISTS idx_user_settings_minors ON user_settings(user_id) WHERE age_band IN ('child', 'teen');
+
+CREATE TABLE IF NOT EXISTS guardian_consents (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL,
+  guardian_email VARCHAR(255) NOT NULL,
+  token_hash VARCHAR(64) NOT NULL UNIQUE, -- SHA-256 of the consent link token; the token itself is never stored
+  status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, granted, denied, superseded
+  age_band VARCHAR(16) NOT NULL, -- Age band at the time of the request
+  requested_at TIMESTAMP DEFAULT NOW(),
+  expires_at TIMESTAMP NOT NULL,
+  responded_at TIMESTAMP,
+  responder_ip VARCHAR(64)
+);
+
+CREATE INDEX IF NOT EXISTS idx_guardian_consents_user ON guardian_consents(user_id, requested_at DESC);
+
+COMMENT ON COLUMN user_settings.age_band IS 'child and teen accounts are minors: hidden from leaderboards, no public reflections or shared solutions';
+COMMENT ON TABLE guardian_consents IS 'Guardian consent requests and decisions for minor accounts; kept as an audit record';
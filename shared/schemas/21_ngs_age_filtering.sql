@@ -0,0 +1,32 @@
+-- NGS Age-Appropriate Content
+-- Minimum age bands on lessons and challenges, with per-cohort educator overrides
+
+ALTER TABLE lessons
+ADD COLUMN IF NOT EXISTS min_age_band VARCHAR(16) NOT NULL DEFAULT 'child'; -- child, teen, adult
+
+ALTER TABLE challenges
+ADD COLUMN IF NOT EXISTS min_age_band VARCHAR(16) NOT NULL DEFAULT 'child'; -- child, teen, adult
+
+CREATE TABLE IF NOT EXISTS cohorts (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  name VARCHAR(255) NOT NULL,
+  educator_id UUID NOT NULL,
+  content_age_band VARCHAR(16), -- NULL keeps each member's own band
+  created_at TIMESTAMP DEFAULT NOW(),
+  updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_cohorts_educator ON cohorts(educator_id);
+
+CREATE TABLE IF NOT EXISTS cohort_members (
+  cohort_id UUID NOT NULL REFERENCES cohorts(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL,
+  joined_at TIMESTAMP DEFAULT NOW(),
+  PRIMARY KEY (cohort_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_cohort_members_user ON cohort_members(user_id);
+
+COMMENT ON COLUMN lessons.min_age_band IS 'Youngest age band the lesson is shown to; learners below it do not see the lesson';
+COMMENT ON COLUMN challenges.min_age_band IS 'Youngest age band the challenge is shown to; learners below it do not see the challenge';
+COMMENT ON COLUMN cohorts.content_age_band IS 'Educator override: members may see content up to this band even when their own band is younger';
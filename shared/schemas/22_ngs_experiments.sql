@@ -0,0 +1,27 @@
+-- NGS Experiments
+-- Deterministic A/B assignment with exposure logging for per-variant rollups
+
+CREATE TABLE IF NOT EXISTS experiments (
+  key VARCHAR(100) PRIMARY KEY,
+  description TEXT,
+  variants JSONB NOT NULL, -- [{"name": "control", "weight": 50}, {"name": "treatment", "weight": 50}]
+  status VARCHAR(20) NOT NULL DEFAULT 'active', -- active, paused, concluded
+  created_at TIMESTAMP DEFAULT NOW(),
+  updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS experiment_exposures (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  experiment_key VARCHAR(100) NOT NULL REFERENCES experiments(key) ON DELETE CASCADE,
+  user_id UUID NOT NULL,
+  variant VARCHAR(100) NOT NULL,
+  first_exposed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  last_exposed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  exposure_count INTEGER NOT NULL DEFAULT 1,
+  UNIQUE(experiment_key, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_experiment_exposures_variant ON experiment_exposures(experiment_key, variant);
+
+COMMENT ON TABLE experiments IS 'A/B experiments; users are assigned by hashing experiment key and user ID over variant weights';
+COMMENT ON TABLE experiment_exposures IS 'First and latest exposure per user; rollups measure outcomes after first exposure';
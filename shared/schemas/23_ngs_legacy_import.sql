@@ -0,0 +1,9 @@
+-- NGS Legacy LMS Import
+-- External lesson IDs so historical completions from a legacy LMS can be mapped onto lessons
+
+ALTER TABLE lessons
+ADD COLUMN IF NOT EXISTS external_id VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_lessons_external_id ON lessons(external_id) WHERE external_id IS NOT NULL;
+
+COMMENT ON COLUMN lessons.external_id IS 'Lesson identifier in a legacy LMS, used by POST /ngs/admin/import/users';
@@ -0,0 +1,23 @@
+-- NGS Lesson Drafts
+-- Lessons imported from external content sources wait here until an admin publishes them
+
+CREATE TABLE IF NOT EXISTS lesson_drafts (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  source VARCHAR(20) NOT NULL, -- git, notion, gdocs
+  source_ref VARCHAR(500) NOT NULL, -- File path or document ID within the source
+  fields JSONB NOT NULL DEFAULT '{}', -- Lesson fields mapped from front matter
+  front_matter JSONB NOT NULL DEFAULT '{}',
+  content_markdown TEXT NOT NULL,
+  content_hash VARCHAR(64) NOT NULL,
+  warnings JSONB NOT NULL DEFAULT '[]',
+  status VARCHAR(20) NOT NULL DEFAULT 'draft', -- draft, published
+  lesson_id UUID REFERENCES lessons(id) ON DELETE SET NULL, -- Set once published
+  imported_at TIMESTAMP DEFAULT NOW(),
+  updated_at TIMESTAMP DEFAULT NOW(),
+  published_at TIMESTAMP,
+  UNIQUE(source, source_ref)
+);
+
+CREATE INDEX IF NOT EXISTS idx_lesson_drafts_status ON lesson_drafts(status, updated_at DESC);
+
+COMMENT ON TABLE lesson_drafts IS 'Externally authored lessons; re-importing changed content returns a published draft to draft status';
@@ -0,0 +1,48 @@
+-- NGS Lesson Media
+-- Video assets attached to lessons, their transcript and summary artifacts, and full-text search
+
+CREATE TABLE IF NOT EXISTS lesson_media (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  lesson_id UUID NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+  media_type VARCHAR(20) NOT NULL DEFAULT 'video',
+  url VARCHAR(2048) NOT NULL,
+  title VARCHAR(255),
+  language VARCHAR(32), -- Hint passed to transcription; replaced by the detected language
+  status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, processing, completed, failed
+  error TEXT,
+  attached_by UUID NOT NULL,
+  created_at TIMESTAMP DEFAULT NOW(),
+  updated_at TIMESTAMP DEFAULT NOW(),
+  processed_at TIMESTAMP,
+  UNIQUE(lesson_id, url)
+);
+
+CREATE INDEX IF NOT EXISTS idx_lesson_media_lesson ON lesson_media(lesson_id);
+CREATE INDEX IF NOT EXISTS idx_lesson_media_status ON lesson_media(status);
+
+CREATE TABLE IF NOT EXISTS lesson_media_artifacts (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  lesson_id UUID NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+  media_id UUID NOT NULL REFERENCES lesson_media(id) ON DELETE CASCADE,
+  artifact_type VARCHAR(20) NOT NULL, -- transcript, summary
+  content TEXT NOT NULL,
+  model VARCHAR(100),
+  search_vector TSVECTOR GENERATED ALWAYS AS (to_tsvector('english', content)) STORED,
+  created_at TIMESTAMP DEFAULT NOW(),
+  UNIQUE(media_id, artifact_type)
+);
+
+CREATE INDEX IF NOT EXISTS idx_lesson_media_artifacts_lesson ON lesson_media_artifacts(lesson_id);
+CREATE INDEX IF NOT EXISTS idx_lesson_media_artifacts_search ON lesson_media_artifacts USING GIN(search_vector);
+
+-- Full-text index over lesson text so artifacts and lessons are searched together
+ALTER TABLE lessons ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+  setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+  setweight(to_tsvector('english', COALESCE(description, '')), 'B') ||
+  setweight(to_tsvector('english', COALESCE(content_markdown, '')), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_lessons_search ON lessons USING GIN(search_vector);
+
+COMMENT ON TABLE lesson_media IS 'Video assets attached to lessons; attaching one starts a transcription job';
+COMMENT ON TABLE lesson_media_artifacts IS 'AI-generated transcript and summary for lesson media, indexed for full-text search';
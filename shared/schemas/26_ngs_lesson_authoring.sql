@@ -0,0 +1,18 @@
+-- NGS Lesson Authoring
+-- Lesson clones, cohort overlays and cohort-only lessons created by educators
+
+ALTER TABLE lessons
+ADD COLUMN IF NOT EXISTS cloned_from UUID REFERENCES lessons(id) ON DELETE SET NULL,
+ADD COLUMN IF NOT EXISTS overlay_of UUID REFERENCES lessons(id) ON DELETE CASCADE,
+ADD COLUMN IF NOT EXISTS overlay_cohort_id UUID REFERENCES cohorts(id) ON DELETE CASCADE,
+ADD COLUMN IF NOT EXISTS created_by UUID;
+
+-- A cohort has at most one overlay per base lesson
+CREATE UNIQUE INDEX IF NOT EXISTS idx_lessons_overlay ON lessons(overlay_of, overlay_cohort_id)
+  WHERE overlay_of IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_lessons_overlay_cohort ON lessons(overlay_cohort_id)
+  WHERE overlay_cohort_id IS NOT NULL;
+
+COMMENT ON COLUMN lessons.cloned_from IS 'Lesson this one was cloned from, if any';
+COMMENT ON COLUMN lessons.overlay_of IS 'Base lesson this overlay replaces for members of overlay_cohort_id';
+COMMENT ON COLUMN lessons.overlay_cohort_id IS 'Only members of this cohort see the lesson, in place of overlay_of when set';
@@ -0,0 +1,24 @@
+-- NGS Cohort Curriculum Sequencing
+-- Per-cohort lesson order and hidden optional tracks, applied when lessons are read
+
+CREATE TABLE IF NOT EXISTS cohort_curriculum_overrides (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  cohort_id UUID NOT NULL REFERENCES cohorts(id) ON DELETE CASCADE,
+  lesson_id UUID REFERENCES lessons(id) ON DELETE CASCADE, -- One lesson, or
+  track VARCHAR(50), -- every lesson in a track
+  lesson_order INTEGER, -- Replaces lessons.lesson_order for members; lesson overrides only
+  hidden BOOLEAN NOT NULL DEFAULT false, -- Hides optional lessons from members
+  updated_by UUID NOT NULL,
+  updated_at TIMESTAMP DEFAULT NOW(),
+  CHECK ((lesson_id IS NULL) <> (track IS NULL)),
+  CHECK (track IS NULL OR lesson_order IS NULL),
+  CHECK (lesson_order IS NULL OR lesson_order > 0)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cohort_curriculum_lesson ON cohort_curriculum_overrides(cohort_id, lesson_id)
+  WHERE lesson_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cohort_curriculum_track ON cohort_curriculum_overrides(cohort_id, track)
+  WHERE track IS NOT NULL;
+
+COMMENT ON TABLE cohort_curriculum_overrides IS 'Educator sequencing for a cohort, applied at read time; lessons rows are never changed';
+COMMENT ON COLUMN cohort_curriculum_overrides.hidden IS 'Required lessons stay visible even when hidden is set';
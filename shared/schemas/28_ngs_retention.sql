@@ -0,0 +1,30 @@
+-- NGS Data Retention
+-- Archive table for old tutor chat sessions and monthly rollups of compacted XP events
+
+CREATE TABLE IF NOT EXISTS educator_chat_sessions_archive (
+  LIKE educator_chat_sessions INCLUDING DEFAULTS,
+  archived_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_educator_chat_sessions_archive_user ON educator_chat_sessions_archive(user_id);
+
+-- Events stay for replay and activity history; only their metadata is dropped once rolled up
+ALTER TABLE xp_events
+ADD COLUMN IF NOT EXISTS compacted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_xp_events_uncompacted ON xp_events(created_at)
+  WHERE compacted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS xp_event_rollups (
+  user_id UUID NOT NULL,
+  month DATE NOT NULL, -- First day of the month (UTC)
+  source VARCHAR(100) NOT NULL,
+  event_count INTEGER NOT NULL DEFAULT 0,
+  xp_total BIGINT NOT NULL DEFAULT 0,
+  updated_at TIMESTAMP DEFAULT NOW(),
+  PRIMARY KEY (user_id, month, source)
+);
+
+COMMENT ON TABLE educator_chat_sessions_archive IS 'Tutor chat sessions moved out of educator_chat_sessions by the retention scheduler';
+COMMENT ON COLUMN xp_events.compacted_at IS 'When the event was counted into xp_event_rollups and its metadata dropped';
+COMMENT ON TABLE xp_event_rollups IS 'Monthly per-user, per-source counts of compacted XP events';
@@ -0,0 +1,106 @@
+-- NGS Table Partitioning
+-- Monthly range partitions for xp_events (created_at) and challenge_submissions (submitted_at).
+-- Existing tables are converted once; the curriculum service's partition maintenance job then
+-- creates upcoming months and moves old xp_events partitions to the ngs_archive schema.
+-- Partitions are named <table>_pYYYY_MM; rows outside every month land in <table>_default.
+
+CREATE SCHEMA IF NOT EXISTS ngs_archive;
+
+DO $$
+DECLARE
+  first_month DATE;
+  m DATE;
+BEGIN
+  IF (SELECT relkind FROM pg_class WHERE oid = 'xp_events'::regclass) = 'r' THEN
+    ALTER TABLE xp_events RENAME TO xp_events_unpartitioned;
+    ALTER INDEX IF EXISTS xp_events_pkey RENAME TO xp_events_unpartitioned_pkey;
+    DROP INDEX IF EXISTS idx_xp_events_user_id;
+    DROP INDEX IF EXISTS idx_xp_events_user_created;
+    DROP INDEX IF EXISTS idx_xp_events_uncompacted;
+
+    CREATE TABLE xp_events (
+      id UUID NOT NULL DEFAULT gen_random_uuid(),
+      user_id UUID NOT NULL,
+      source VARCHAR(100),
+      xp_awarded INTEGER NOT NULL,
+      metadata JSONB,
+      created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+      compacted_at TIMESTAMP,
+      PRIMARY KEY (id, created_at)
+    ) PARTITION BY RANGE (created_at);
+
+    SELECT DATE_TRUNC('month', COALESCE(MIN(created_at), NOW()))::date INTO first_month FROM xp_events_unpartitioned;
+    m := first_month;
+    WHILE m <= DATE_TRUNC('month', NOW() + INTERVAL '3 months')::date LOOP
+      EXECUTE format('CREATE TABLE %I PARTITION OF xp_events FOR VALUES FROM (%L) TO (%L)',
+        'xp_events_p' || TO_CHAR(m, 'YYYY_MM'), m, (m + INTERVAL '1 month')::date);
+      m := (m + INTERVAL '1 month')::date;
+    END LOOP;
+    CREATE TABLE xp_events_default PARTITION OF xp_events DEFAULT;
+
+    INSERT INTO xp_events (id, user_id, source, xp_awarded, metadata, created_at, compacted_at)
+    SELECT id, user_id, source, xp_awarded, metadata, COALESCE(created_at, NOW()), compacted_at
+    FROM xp_events_unpartitioned;
+    DROP TABLE xp_events_unpartitioned;
+  END IF;
+
+  IF (SELECT relkind FROM pg_class WHERE oid = 'challenge_submissions'::regclass) = 'r' THEN
+    ALTER TABLE challenge_submissions RENAME TO challenge_submissions_unpartitioned;
+    ALTER INDEX IF EXISTS challenge_submissions_pkey RENAME TO challenge_submissions_unpartitioned_pkey;
+    DROP INDEX IF EXISTS idx_challenge_submissions_user_id;
+    DROP INDEX IF EXISTS idx_challenge_submissions_challenge_id;
+    DROP INDEX IF EXISTS idx_challenge_submissions_shared;
+    DROP INDEX IF EXISTS idx_challenge_submissions_user_challenge;
+
+    CREATE TABLE challenge_submissions (
+      id UUID NOT NULL DEFAULT gen_random_uuid(),
+      user_id UUID NOT NULL,
+      challenge_id UUID NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+      submission_code TEXT,
+      test_results JSONB,
+      passed BOOLEAN DEFAULT false,
+      score INTEGER CHECK (score >= 0 AND score <= 100),
+      feedback TEXT,
+      time_taken_seconds INTEGER,
+      submitted_at TIMESTAMP NOT NULL DEFAULT NOW(),
+      is_shared BOOLEAN DEFAULT false,
+      PRIMARY KEY (id, submitted_at)
+    ) PARTITION BY RANGE (submitted_at);
+
+    SELECT DATE_TRUNC('month', COALESCE(MIN(submitted_at), NOW()))::date INTO first_month FROM challenge_submissions_unpartitioned;
+    m := first_month;
+    WHILE m <= DATE_TRUNC('month', NOW() + INTERVAL '3 months')::date LOOP
+      EXECUTE format('CREATE TABLE %I PARTITION OF challenge_submissions FOR VALUES FROM (%L) TO (%L)',
+        'challenge_submissions_p' || TO_CHAR(m, 'YYYY_MM'), m, (m + INTERVAL '1 month')::date);
+      m := (m + INTERVAL '1 month')::date;
+    END LOOP;
+    CREATE TABLE challenge_submissions_default PARTITION OF challenge_submissions DEFAULT;
+
+    INSERT INTO challenge_submissions (
+      id, user_id, challenge_id, submission_code, test_results, passed, score, feedback,
+      time_taken_seconds, submitted_at, is_shared
+    )
+    SELECT id, user_id, challenge_id, submission_code, test_results, passed, score, feedback,
+           time_taken_seconds, COALESCE(submitted_at, NOW()), is_shared
+    FROM challenge_submissions_unpartitioned;
+    DROP TABLE challenge_submissions_unpartitioned;
+  END IF;
+END $$;
+
+-- Indexes on the parents cascade to every partition, including ones attached later
+CREATE INDEX IF NOT EXISTS idx_xp_events_user_created ON xp_events(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_xp_events_uncompacted ON xp_events(created_at)
+  WHERE compacted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_challenge_submissions_user_submitted ON challenge_submissions(user_id, submitted_at DESC);
+CREATE INDEX IF NOT EXISTS idx_challenge_submissions_challenge_id ON challenge_submissions(challenge_id);
+CREATE INDEX IF NOT EXISTS idx_challenge_submissions_shared
+  ON challenge_submissions(challenge_id, score DESC)
+  WHERE passed = true AND is_shared = true;
+CREATE INDEX IF NOT EXISTS idx_challenge_submissions_user_challenge
+  ON challenge_submissions(user_id, challenge_id, passed);
+
+COMMENT ON SCHEMA ngs_archive IS 'Detached partitions of old xp_events months; their totals remain in xp_event_rollups';
+COMMENT ON TABLE xp_events IS 'Partitioned by month on created_at; maintained by the curriculum service';
+COMMENT ON TABLE challenge_submissions IS 'Partitioned by month on submitted_at; maintained by the curriculum service';
+COMMENT ON COLUMN xp_events.compacted_at IS 'When the event was counted into xp_event_rollups and its metadata dropped';
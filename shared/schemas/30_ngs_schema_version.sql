@@ -0,0 +1,13 @@
+-- NGS Schema Version
+-- Records which NGS migrations have been applied. The curriculum service refuses to start until
+-- the version it was built for is present. Each later NGS migration inserts its own number.
+
+CREATE TABLE IF NOT EXISTS ngs_schema_version (
+  version INTEGER PRIMARY KEY,
+  applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Migrations run in file order, so this also vouches for 12 through 29
+INSERT INTO ngs_schema_version (version) VALUES (30) ON CONFLICT (version) DO NOTHING;
+
+COMMENT ON TABLE ngs_schema_version IS 'Applied NGS migration numbers; checked by the curriculum service on startup';
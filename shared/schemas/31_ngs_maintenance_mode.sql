@@ -0,0 +1,19 @@
+-- NGS Maintenance Mode
+-- A single-row switch admins flip to answer learner endpoints with 503 during migrations or
+-- incidents. Health, metrics and admin endpoints stay available.
+
+CREATE TABLE IF NOT EXISTS ngs_maintenance (
+  id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id), -- Allows exactly one row
+  enabled BOOLEAN NOT NULL DEFAULT false,
+  message TEXT,
+  retry_after_seconds INTEGER CHECK (retry_after_seconds > 0),
+  updated_by UUID,
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO ngs_maintenance (id) VALUES (true) ON CONFLICT (id) DO NOTHING;
+
+INSERT INTO ngs_schema_version (version) VALUES (31) ON CONFLICT (version) DO NOTHING;
+
+COMMENT ON TABLE ngs_maintenance IS 'Maintenance mode switch for the curriculum service; the MAINTENANCE_MODE env var also forces it on';
+COMMENT ON COLUMN ngs_maintenance.retry_after_seconds IS 'Sent as Retry-After while enabled; unset leaves the header out';
@@ -0,0 +1,7 @@
+-- NGS Challenge Tags
+-- GET /ngs/levels/:level/challenges?tags=a,b keeps challenges whose tags contain every requested
+-- tag (tags @> ARRAY[...]), which a GIN index serves.
+
+CREATE INDEX IF NOT EXISTS idx_challenges_tags ON challenges USING GIN (tags);
+
+INSERT INTO ngs_schema_version (version) VALUES (32) ON CONFLICT (version) DO NOTHING;
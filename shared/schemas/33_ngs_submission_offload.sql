@@ -0,0 +1,15 @@
+-- NGS Submission Offload
+-- Submissions whose code or test results exceed SUBMISSION_OFFLOAD_BYTES are written to the blob
+-- store. The row then keeps the object's key in code_ref or test_results_ref, with
+-- submission_code empty or test_results NULL. code_length keeps the character count that ranks
+-- community solutions; rows from before this migration fall back to LENGTH(submission_code).
+
+ALTER TABLE challenge_submissions
+  ADD COLUMN IF NOT EXISTS code_ref TEXT,
+  ADD COLUMN IF NOT EXISTS test_results_ref TEXT,
+  ADD COLUMN IF NOT EXISTS code_length INTEGER;
+
+COMMENT ON COLUMN challenge_submissions.code_ref IS 'Blob store key of offloaded submission code';
+COMMENT ON COLUMN challenge_submissions.test_results_ref IS 'Blob store key of offloaded test results JSON';
+
+INSERT INTO ngs_schema_version (version) VALUES (33) ON CONFLICT (version) DO NOTHING;
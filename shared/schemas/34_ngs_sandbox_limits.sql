@@ -0,0 +1,11 @@
+-- NGS Sandbox Limits
+-- Per-challenge resource limits for each test case run of a submission. NULL falls back to the
+-- curriculum service's SANDBOX_TIME_LIMIT_MS, SANDBOX_CPU_LIMIT_MS and SANDBOX_MEMORY_LIMIT_MB.
+-- Optimization challenges set these tighter, e.g. "must run under 256MB".
+
+ALTER TABLE challenges
+  ADD COLUMN IF NOT EXISTS run_time_limit_ms INTEGER CHECK (run_time_limit_ms > 0),
+  ADD COLUMN IF NOT EXISTS run_cpu_limit_ms INTEGER CHECK (run_cpu_limit_ms > 0),
+  ADD COLUMN IF NOT EXISTS run_memory_limit_mb INTEGER CHECK (run_memory_limit_mb > 0);
+
+INSERT INTO ngs_schema_version (version) VALUES (34) ON CONFLICT (version) DO NOTHING;
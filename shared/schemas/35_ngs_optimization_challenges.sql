@@ -0,0 +1,18 @@
+-- NGS Optimization Challenges
+-- Challenges of type 'optimization' score correct submissions on runtime and memory against the
+-- reference solution's baseline_runtime_ms (summed over test cases) and baseline_memory_kb (peak).
+-- Submissions keep their measured runtime and memory for the per-challenge runtime leaderboard.
+
+ALTER TABLE challenges
+  ADD COLUMN IF NOT EXISTS baseline_runtime_ms DOUBLE PRECISION CHECK (baseline_runtime_ms > 0),
+  ADD COLUMN IF NOT EXISTS baseline_memory_kb BIGINT CHECK (baseline_memory_kb > 0);
+
+ALTER TABLE challenge_submissions
+  ADD COLUMN IF NOT EXISTS runtime_ms DOUBLE PRECISION,
+  ADD COLUMN IF NOT EXISTS memory_kb BIGINT;
+
+CREATE INDEX IF NOT EXISTS idx_challenge_submissions_runtime
+  ON challenge_submissions(challenge_id, runtime_ms)
+  WHERE passed = true AND runtime_ms IS NOT NULL;
+
+INSERT INTO ngs_schema_version (version) VALUES (35) ON CONFLICT (version) DO NOTHING;
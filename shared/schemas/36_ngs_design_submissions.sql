@@ -0,0 +1,22 @@
+-- NGS Design Submissions
+-- Design challenges take a markdown write-up plus uploaded diagram assets instead of code. Assets
+-- live in the blob store and are listed in the assets JSONB array as
+-- {id, file_name, content_type, size_bytes, key}. Design submissions wait in the review queue
+-- (review_status 'pending_review', score 0) until an educator or the intelligence service scores
+-- them against the challenge rubric; evaluation keeps the per-criterion scores.
+
+ALTER TABLE challenge_submissions
+  ADD COLUMN IF NOT EXISTS review_status VARCHAR(20) NOT NULL DEFAULT 'graded'
+    CHECK (review_status IN ('graded', 'pending_review')),
+  ADD COLUMN IF NOT EXISTS writeup TEXT,
+  ADD COLUMN IF NOT EXISTS assets JSONB,
+  ADD COLUMN IF NOT EXISTS evaluation JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_challenge_submissions_pending_review
+  ON challenge_submissions(submitted_at)
+  WHERE review_status = 'pending_review';
+
+COMMENT ON COLUMN challenge_submissions.review_status IS 'graded, or pending_review while a design submission awaits rubric evaluation';
+COMMENT ON COLUMN challenge_submissions.evaluation IS 'Rubric evaluation of a design submission: method, reviewer and per-criterion scores';
+
+INSERT INTO ngs_schema_version (version) VALUES (36) ON CONFLICT (version) DO NOTHING;
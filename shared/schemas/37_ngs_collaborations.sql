@@ -0,0 +1,41 @@
+-- NGS Collaboration Challenges
+-- Challenges of type 'collaboration' are answered by a group: each member takes one of the
+-- roles in the challenge's metadata.roles and writes that role's section. Any member can submit
+-- once every role has a member with a complete section; each member then gets a passing
+-- challenge_submissions row, linked back through collaboration_id, and a share of the XP.
+
+CREATE TABLE IF NOT EXISTS challenge_collaborations (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  challenge_id UUID NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+  created_by UUID NOT NULL,
+  status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'submitted')),
+  submitted_by UUID,
+  submitted_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_challenge_collaborations_challenge
+  ON challenge_collaborations(challenge_id, status);
+
+CREATE TABLE IF NOT EXISTS challenge_collaboration_members (
+  collaboration_id UUID NOT NULL REFERENCES challenge_collaborations(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL,
+  role VARCHAR(100) NOT NULL,
+  section TEXT NOT NULL DEFAULT '',
+  section_updated_at TIMESTAMP,
+  xp_awarded INTEGER,
+  joined_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (collaboration_id, user_id),
+  UNIQUE (collaboration_id, role)
+);
+
+CREATE INDEX IF NOT EXISTS idx_challenge_collaboration_members_user
+  ON challenge_collaboration_members(user_id);
+
+ALTER TABLE challenge_submissions
+  ADD COLUMN IF NOT EXISTS collaboration_id UUID;
+
+COMMENT ON TABLE challenge_collaborations IS 'Group attempts at collaboration challenges, one role per member';
+COMMENT ON COLUMN challenge_submissions.collaboration_id IS 'Collaboration this submission was made through, for collaboration challenges';
+
+INSERT INTO ngs_schema_version (version) VALUES (37) ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,36 @@
+-- NGS Admin Impersonation
+-- An admin opens a time-limited session to make requests as a learner. The session token is
+-- only returned once; every request made with it is recorded in the audit table.
+
+CREATE TABLE IF NOT EXISTS ngs_impersonation_sessions (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  admin_id UUID NOT NULL,
+  user_id UUID NOT NULL,
+  reason TEXT NOT NULL,
+  token_hash VARCHAR(64) NOT NULL UNIQUE, -- SHA-256 of the session token; the token itself is never stored
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  expires_at TIMESTAMP NOT NULL,
+  ended_at TIMESTAMP,
+  ended_by UUID
+);
+
+CREATE INDEX IF NOT EXISTS idx_ngs_impersonation_sessions_user ON ngs_impersonation_sessions(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_ngs_impersonation_sessions_admin ON ngs_impersonation_sessions(admin_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS ngs_impersonation_audit (
+  id BIGSERIAL PRIMARY KEY,
+  session_id UUID NOT NULL REFERENCES ngs_impersonation_sessions(id) ON DELETE CASCADE,
+  admin_id UUID NOT NULL,
+  user_id UUID NOT NULL,
+  method VARCHAR(10) NOT NULL,
+  path TEXT NOT NULL,
+  status INTEGER, -- NULL while the request is in flight or if it never finished
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ngs_impersonation_audit_session ON ngs_impersonation_audit(session_id, id);
+
+COMMENT ON TABLE ngs_impersonation_sessions IS 'Admin sessions acting as a learner; kept as an audit record';
+COMMENT ON TABLE ngs_impersonation_audit IS 'Every request made under an impersonation session, written before it is handled';
+
+INSERT INTO ngs_schema_version (version) VALUES (38) ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,29 @@
+-- NGS Account Deactivation
+-- Soft deactivation, distinct from deleting an account: the learner's data is kept, but they
+-- are left off leaderboards, their notifications are off and their streak is frozen until
+-- they are reactivated. Each deactivation is kept so streaks can skip the frozen days.
+
+ALTER TABLE user_settings
+ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMP; -- Set while the account is deactivated
+
+CREATE TABLE IF NOT EXISTS ngs_account_deactivations (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL,
+  reason TEXT NOT NULL DEFAULT '',
+  source VARCHAR(20) NOT NULL, -- admin or event
+  deactivated_at TIMESTAMP NOT NULL,
+  reactivated_at TIMESTAMP,
+  reactivated_source VARCHAR(20),
+  grace_days INTEGER NOT NULL DEFAULT 0 -- Days from reactivation the streak stays frozen
+);
+
+-- At most one open deactivation per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ngs_account_deactivations_open
+  ON ngs_account_deactivations(user_id) WHERE reactivated_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_ngs_account_deactivations_user
+  ON ngs_account_deactivations(user_id, deactivated_at DESC);
+
+COMMENT ON COLUMN user_settings.deactivated_at IS 'Deactivated accounts are hidden from leaderboards and get no notifications';
+COMMENT ON TABLE ngs_account_deactivations IS 'Deactivation periods; streaks treat their days, and the reactivation grace days, as frozen';
+
+INSERT INTO ngs_schema_version (version) VALUES (39) ON CONFLICT (version) DO NOTHING;
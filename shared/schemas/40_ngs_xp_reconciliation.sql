@@ -0,0 +1,21 @@
+-- NGS XP Reconciliation
+-- A nightly job compares each user_progress.total_xp with the XP ledger (uncompacted xp_events
+-- plus xp_event_rollups) and resets totals that drifted. Every repair is recorded here.
+
+CREATE TABLE IF NOT EXISTS ngs_xp_reconciliation_repairs (
+  id BIGSERIAL PRIMARY KEY,
+  user_id UUID NOT NULL,
+  run_started_at TIMESTAMP NOT NULL,
+  previous_total_xp INTEGER NOT NULL,
+  ledger_xp INTEGER NOT NULL,
+  previous_level INTEGER NOT NULL,
+  new_level INTEGER NOT NULL,
+  source VARCHAR(20) NOT NULL, -- scheduled or admin
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ngs_xp_reconciliation_repairs_user ON ngs_xp_reconciliation_repairs(user_id, id DESC);
+
+COMMENT ON TABLE ngs_xp_reconciliation_repairs IS 'Audit trail of user_progress.total_xp values reset to the XP ledger';
+
+INSERT INTO ngs_schema_version (version) VALUES (40) ON CONFLICT (version) DO NOTHING;
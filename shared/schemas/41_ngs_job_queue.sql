@@ -0,0 +1,20 @@
+-- NGS Job Queue Settings
+-- Background jobs share one pool of workers per instance, started in priority order (grading,
+-- generation, digest). Admins resize the pool and cap classes at runtime; instances pick up the
+-- change within a minute. NULL columns and classes missing from class_limits use the
+-- JOB_WORKERS, JOB_MAX_WAIT_SECONDS and JOB_CLASS_LIMITS defaults.
+
+CREATE TABLE IF NOT EXISTS ngs_job_queue_settings (
+  id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id), -- Allows exactly one row
+  workers INTEGER CHECK (workers > 0),
+  max_wait_seconds INTEGER CHECK (max_wait_seconds > 0),
+  class_limits JSONB NOT NULL DEFAULT '{}'::jsonb,
+  updated_by UUID,
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE ngs_job_queue_settings IS 'Runtime overrides for the curriculum service background job pool';
+COMMENT ON COLUMN ngs_job_queue_settings.max_wait_seconds IS 'A job queued this long starts ahead of higher classes';
+COMMENT ON COLUMN ngs_job_queue_settings.class_limits IS 'Most jobs of each class running at once, e.g. {"generation": 2}; 0 pauses a class';
+
+INSERT INTO ngs_schema_version (version) VALUES (41) ON CONFLICT (version) DO NOTHING;
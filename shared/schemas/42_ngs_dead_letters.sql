@@ -0,0 +1,28 @@
+-- NGS Dead Letters
+-- Background jobs that fail are kept here with their payload and error, instead of only being
+-- logged. Admins retry them, which runs the same job again, or discard them.
+
+CREATE TABLE IF NOT EXISTS ngs_dead_letters (
+  id BIGSERIAL PRIMARY KEY,
+  kind VARCHAR(50) NOT NULL, -- Job kind, e.g. transcription
+  class VARCHAR(20) NOT NULL, -- Job priority class
+  payload JSONB NOT NULL, -- Everything needed to run the job again
+  error TEXT NOT NULL, -- The latest failure
+  attempts INTEGER NOT NULL DEFAULT 1,
+  status VARCHAR(20) NOT NULL DEFAULT 'dead' CHECK (status IN ('dead', 'retrying', 'resolved', 'discarded')),
+  first_failed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  last_failed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  retried_by UUID,
+  retried_at TIMESTAMP,
+  resolved_at TIMESTAMP,
+  discarded_by UUID,
+  discarded_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_ngs_dead_letters_status ON ngs_dead_letters(status, id DESC);
+CREATE INDEX IF NOT EXISTS idx_ngs_dead_letters_kind ON ngs_dead_letters(kind, id DESC);
+
+COMMENT ON TABLE ngs_dead_letters IS 'Failed background jobs awaiting an admin retry or discard';
+COMMENT ON COLUMN ngs_dead_letters.status IS 'dead until an admin acts; retrying while the retry runs, then resolved or dead again';
+
+INSERT INTO ngs_schema_version (version) VALUES (42) ON CONFLICT (version) DO NOTHING;
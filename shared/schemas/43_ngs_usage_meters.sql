@@ -0,0 +1,21 @@
+-- NGS Usage Meters
+-- Per-organization consumption for usage-based billing in multi-tenant deployments, where the
+-- gateway forwards the caller's organization in X-Org-Id. Each instance buffers its counts and
+-- adds them here every few seconds, one row per organization, meter and UTC day; monthly
+-- rollups sum the days.
+
+CREATE TABLE IF NOT EXISTS usage_meters (
+  org_id VARCHAR(64) NOT NULL,
+  meter VARCHAR(30) NOT NULL CHECK (meter IN ('api_calls', 'llm_tokens', 'sandbox_ms', 'storage_bytes')),
+  day DATE NOT NULL,
+  quantity BIGINT NOT NULL DEFAULT 0,
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (org_id, meter, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_meters_day ON usage_meters(day, org_id);
+
+COMMENT ON TABLE usage_meters IS 'Daily per-organization usage from the curriculum service, for billing';
+COMMENT ON COLUMN usage_meters.meter IS 'api_calls (requests), llm_tokens (generation and chat), sandbox_ms (code run time) or storage_bytes (submission bytes stored)';
+
+INSERT INTO ngs_schema_version (version) VALUES (43) ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,34 @@
+-- NGS Organizations
+-- Org-scoped administration for multi-tenant deployments. Organizations are identified by the
+-- gateway's X-Org-Id; an organization's admins invite and remove its educators and set the
+-- defaults its members start from. A missing organization row means no overrides.
+
+CREATE TABLE IF NOT EXISTS organizations (
+  org_id VARCHAR(64) PRIMARY KEY,
+  agent_unlock_level INTEGER CHECK (agent_unlock_level >= 1),
+  enabled_tracks JSONB,
+  locale VARCHAR(10),
+  updated_by UUID NOT NULL,
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON COLUMN organizations.agent_unlock_level IS 'Level at which members unlock agent creation; NULL uses AGENT_UNLOCK_LEVEL';
+COMMENT ON COLUMN organizations.enabled_tracks IS 'Track keys offered to members; NULL offers every track';
+COMMENT ON COLUMN organizations.locale IS 'Locale for members who have not chosen one; NULL uses en';
+
+CREATE TABLE IF NOT EXISTS org_educators (
+  org_id VARCHAR(64) NOT NULL,
+  user_id UUID NOT NULL,
+  email VARCHAR(320),
+  status VARCHAR(20) NOT NULL DEFAULT 'invited' CHECK (status IN ('invited', 'active')),
+  invited_by UUID NOT NULL,
+  invited_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  accepted_at TIMESTAMP,
+  PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_org_educators_user ON org_educators(user_id);
+
+COMMENT ON TABLE org_educators IS 'Educators of an organization; invited until the educator accepts';
+
+INSERT INTO ngs_schema_version (version) VALUES (44) ON CONFLICT (version) DO NOTHING;
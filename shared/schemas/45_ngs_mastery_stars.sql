@@ -0,0 +1,16 @@
+-- NGS Mastery Stars
+-- Prestige past the top level. A learner earns a star for each curriculum track and stage
+-- (beginner, intermediate, advanced, expert) once they have completed every lesson of the track
+-- in the stage's levels. Post-curve XP milestones are computed from total_xp and not stored.
+
+CREATE TABLE IF NOT EXISTS mastery_stars (
+  user_id UUID NOT NULL,
+  track VARCHAR(32) NOT NULL,
+  stage VARCHAR(16) NOT NULL,
+  earned_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (user_id, track, stage)
+);
+
+COMMENT ON TABLE mastery_stars IS 'One row per track and stage a learner has completed every lesson of';
+
+INSERT INTO ngs_schema_version (version) VALUES (45) ON CONFLICT (version) DO NOTHING;
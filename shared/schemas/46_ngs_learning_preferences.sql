@@ -0,0 +1,10 @@
+-- NGS Learning Preferences
+-- How a learner likes to be taught: worked examples or theory first, code-heavy or conceptual
+-- explanations, and the programming language for examples. Lesson generation and tutor chat
+-- receive them as the learner profile's preferences.
+
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS learning_preferences JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+COMMENT ON COLUMN user_settings.learning_preferences IS 'examples (examples, balanced, theory), code_style (code_heavy, balanced, conceptual), programming_language; missing keys are the defaults';
+
+INSERT INTO ngs_schema_version (version) VALUES (46) ON CONFLICT (version) DO NOTHING;
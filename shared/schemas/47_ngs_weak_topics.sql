@@ -0,0 +1,30 @@
+-- NGS Weak Topics
+-- Topics a learner struggles with, built from failed challenge submissions (by challenge tag),
+-- missed exam questions and low-quality reflections (by lesson). Each user's topics are brought
+-- up to date incrementally: new submissions, exams and reflections after refreshed_through are
+-- folded in, each miss raising the topic's score and each success lowering it.
+
+CREATE TABLE IF NOT EXISTS weak_topics (
+  user_id UUID NOT NULL,
+  topic VARCHAR(255) NOT NULL,
+  score INTEGER NOT NULL DEFAULT 0 CHECK (score >= 0),
+  quiz_misses INTEGER NOT NULL DEFAULT 0,
+  challenge_failures INTEGER NOT NULL DEFAULT 0,
+  low_reflections INTEGER NOT NULL DEFAULT 0,
+  recoveries INTEGER NOT NULL DEFAULT 0,
+  last_missed_at TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (user_id, topic)
+);
+
+CREATE INDEX IF NOT EXISTS idx_weak_topics_score ON weak_topics(user_id, score DESC) WHERE score > 0;
+
+CREATE TABLE IF NOT EXISTS weak_topic_refreshes (
+  user_id UUID PRIMARY KEY,
+  refreshed_through TIMESTAMP NOT NULL
+);
+
+COMMENT ON TABLE weak_topics IS 'Per-user weak topics; score is misses less later successes, never below 0';
+COMMENT ON TABLE weak_topic_refreshes IS 'How far each user''s weak topics have been brought up to date';
+
+INSERT INTO ngs_schema_version (version) VALUES (47) ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,32 @@
+-- NGS Remediation Lessons
+-- When a weak topic's score reaches REMEDIATION_THRESHOLD, the learner is given a short optional
+-- lesson on it. The lesson is generated by the intelligence service, or, if generation fails, the
+-- lesson the topic is named after is selected for review. A learner has at most one open
+-- remediation per topic; once it is completed, their exam answers on the topic before and after
+-- completed_at show whether it helped.
+
+CREATE TABLE IF NOT EXISTS remediation_lessons (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL,
+  topic VARCHAR(255) NOT NULL,
+  lesson_id UUID REFERENCES lessons(id) ON DELETE SET NULL, -- The lesson the topic is named after, if any
+  source VARCHAR(20), -- generated or selected, once ready
+  status VARCHAR(20) NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('pending', 'ready', 'failed', 'completed')),
+  title VARCHAR(255),
+  content_markdown TEXT,
+  metadata JSONB, -- Structured lesson from the intelligence service
+  error TEXT,
+  weak_score INTEGER NOT NULL, -- The topic's score when the remediation was created
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  ready_at TIMESTAMP,
+  completed_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_remediation_lessons_open
+  ON remediation_lessons(user_id, topic) WHERE completed_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_remediation_lessons_user ON remediation_lessons(user_id, created_at DESC);
+
+COMMENT ON TABLE remediation_lessons IS 'Optional micro-lessons on a learner''s weak topics, at most one open per topic';
+
+INSERT INTO ngs_schema_version (version) VALUES (48) ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,12 @@
+-- NGS Challenge Authoring
+-- Educators and admins create, update and deactivate challenges through the admin API instead of
+-- SQL. Record who created and last changed each challenge, and when.
+
+ALTER TABLE challenges
+  ADD COLUMN IF NOT EXISTS created_by UUID,
+  ADD COLUMN IF NOT EXISTS updated_by UUID,
+  ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+
+COMMENT ON COLUMN challenges.created_by IS 'Educator or admin who created the challenge through the admin API; NULL for seeded challenges';
+
+INSERT INTO ngs_schema_version (version) VALUES (49) ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,14 @@
+-- NGS Curriculum Packs
+-- Levels, lessons and challenges are imported from and exported to curriculum packs, matched by
+-- stable external IDs so a pack can be imported again without duplicating content. Lessons
+-- already have external_id from the legacy LMS import; challenges get one here.
+
+ALTER TABLE challenges
+  ADD COLUMN IF NOT EXISTS external_id VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_challenges_external_id ON challenges(external_id) WHERE external_id IS NOT NULL;
+
+COMMENT ON COLUMN lessons.external_id IS 'Stable lesson identifier from a legacy LMS or a curriculum pack';
+COMMENT ON COLUMN challenges.external_id IS 'Stable challenge identifier from a curriculum pack';
+
+INSERT INTO ngs_schema_version (version) VALUES (50) ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,48 @@
+-- NGS Tutor Chat Feedback and Escalation
+-- Each tutor chat exchange relayed by NGS is kept, so the learner can rate the answer and, when
+-- the tutor could not help, escalate it to a human educator as a ticket. Tickets form one queue
+-- for educators: open, claimed by an educator, then resolved with their answer to the learner.
+
+CREATE TABLE IF NOT EXISTS ngs_chat_messages (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL,
+  lesson_id UUID NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+  session_id UUID NOT NULL, -- The intelligence service's chat session
+  message TEXT NOT NULL,
+  response TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ngs_chat_messages_user ON ngs_chat_messages(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_ngs_chat_messages_session ON ngs_chat_messages(session_id, created_at);
+
+CREATE TABLE IF NOT EXISTS ngs_chat_feedback (
+  message_id UUID PRIMARY KEY REFERENCES ngs_chat_messages(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL,
+  rating VARCHAR(10) NOT NULL CHECK (rating IN ('up', 'down')),
+  comment TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS ngs_educator_tickets (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  message_id UUID NOT NULL UNIQUE REFERENCES ngs_chat_messages(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL, -- The learner who escalated
+  note TEXT, -- What the learner still needs
+  status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'claimed', 'resolved')),
+  assigned_to UUID, -- The educator who claimed or resolved it
+  resolution TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  resolved_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_ngs_educator_tickets_queue ON ngs_educator_tickets(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_ngs_educator_tickets_user ON ngs_educator_tickets(user_id, created_at DESC);
+
+COMMENT ON TABLE ngs_chat_messages IS 'Tutor chat exchanges relayed by NGS, for feedback and escalation';
+COMMENT ON TABLE ngs_chat_feedback IS 'A learner''s thumbs up or down on a tutor chat answer';
+COMMENT ON TABLE ngs_educator_tickets IS 'Tutor chat answers escalated by learners to human educators';
+
+INSERT INTO ngs_schema_version (version) VALUES (51) ON CONFLICT (version) DO NOTHING;
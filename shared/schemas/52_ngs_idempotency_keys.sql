@@ -0,0 +1,25 @@
+-- NGS Idempotency Keys
+-- XP awards and lesson completions accept an Idempotency-Key header. The first request with a
+-- key is recorded here while it runs, then with its successful response, so a client retrying
+-- it gets that response back instead of a second award. Keys are scoped to the user and expire
+-- after IDEMPOTENCY_KEY_DAYS, when the idempotency_key_prune retention policy deletes them.
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  user_id UUID NOT NULL,
+  key VARCHAR(255) NOT NULL,
+  method VARCHAR(10) NOT NULL,
+  path TEXT NOT NULL,
+  request_hash CHAR(64) NOT NULL, -- SHA-256 of the request body, hex
+  status_code INTEGER, -- NULL while the first request is in flight
+  content_type VARCHAR(255),
+  response_body BYTEA,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  completed_at TIMESTAMP,
+  PRIMARY KEY (user_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created ON idempotency_keys(created_at);
+
+COMMENT ON TABLE idempotency_keys IS 'Idempotency-Key requests and their responses, replayed to retries';
+
+INSERT INTO ngs_schema_version (version) VALUES (52) ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,30 @@
+-- NGS Learner Memory
+-- Tutor chat sessions that have gone quiet are summarized by the intelligence service, and each
+-- summary is folded into a rolling per-learner memory. The memory seeds new chat sessions and
+-- lesson generation, so the tutor remembers the learner from one session and level to the next.
+
+CREATE TABLE IF NOT EXISTS ngs_chat_session_summaries (
+  session_id UUID PRIMARY KEY, -- The intelligence service's chat session, as in ngs_chat_messages
+  user_id UUID NOT NULL,
+  lesson_id UUID NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+  summary TEXT NOT NULL,
+  message_count INTEGER NOT NULL, -- Exchanges covered; a session resumed later is summarized again
+  last_message_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ngs_chat_session_summaries_user ON ngs_chat_session_summaries(user_id, updated_at DESC);
+CREATE INDEX IF NOT EXISTS idx_ngs_chat_messages_session_last ON ngs_chat_messages(created_at, session_id);
+
+CREATE TABLE IF NOT EXISTS ngs_learner_memory (
+  user_id UUID PRIMARY KEY,
+  summary TEXT NOT NULL,
+  sessions_summarized INTEGER NOT NULL DEFAULT 0,
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE ngs_chat_session_summaries IS 'Summaries of completed tutor chat sessions';
+COMMENT ON TABLE ngs_learner_memory IS 'Rolling summary of what the tutor knows about a learner across chat sessions';
+
+INSERT INTO ngs_schema_version (version) VALUES (53) ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,29 @@
+-- NGS LLM Costs
+-- Tokens used by intelligence service requests, priced per provider (LLM_PRICING) and summed per
+-- day, organization, provider and operation. A day's spend past the budget is alerted once per
+-- scope: the row in ngs_llm_budget_alerts is written with the alert, so replicas do not repeat it.
+
+CREATE TABLE IF NOT EXISTS ngs_llm_usage (
+  day DATE NOT NULL,
+  org_id TEXT NOT NULL DEFAULT '', -- Empty for requests made for no organization, e.g. background jobs
+  provider TEXT NOT NULL DEFAULT '',
+  operation TEXT NOT NULL DEFAULT '',
+  tokens BIGINT NOT NULL DEFAULT 0,
+  cost_usd NUMERIC(14,6) NOT NULL DEFAULT 0,
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (day, org_id, provider, operation)
+);
+
+CREATE TABLE IF NOT EXISTS ngs_llm_budget_alerts (
+  day DATE NOT NULL,
+  scope TEXT NOT NULL, -- '*' for all spend, else the organization
+  budget_usd NUMERIC(14,6) NOT NULL,
+  spend_usd NUMERIC(14,6) NOT NULL,
+  alerted_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (day, scope)
+);
+
+COMMENT ON TABLE ngs_llm_usage IS 'LLM tokens and their cost per day, organization, provider and operation';
+COMMENT ON TABLE ngs_llm_budget_alerts IS 'Daily LLM budget alerts sent, one per day and scope';
+
+INSERT INTO ngs_schema_version (version) VALUES (54) ON CONFLICT (version) DO NOTHING;
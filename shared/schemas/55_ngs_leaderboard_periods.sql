@@ -0,0 +1,7 @@
+-- NGS Leaderboard Periods
+-- Daily, weekly and monthly leaderboards sum the XP events in their window. Events are partitioned
+-- by month; this index lets a window be read without scanning its whole partition.
+
+CREATE INDEX IF NOT EXISTS idx_xp_events_created_user ON xp_events(created_at, user_id) INCLUDE (xp_awarded);
+
+INSERT INTO ngs_schema_version (version) VALUES (55) ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,23 @@
+-- NGS Generation Quarantine
+-- Generated lessons that failed schema validation (missing sections, oversized fields, unknown
+-- difficulty or question types) are kept here instead of being published, with every problem
+-- found, so the prompt or provider can be fixed from real examples.
+
+CREATE TABLE IF NOT EXISTS ngs_generation_quarantine (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  source VARCHAR(20) NOT NULL CHECK (source IN ('lesson', 'remediation')),
+  lesson_id UUID, -- The lesson generated for, for source 'lesson'
+  remediation_id UUID, -- The remediation lesson generated for, for source 'remediation'
+  user_id UUID,
+  provider TEXT NOT NULL DEFAULT '',
+  problems JSONB NOT NULL,
+  response JSONB NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ngs_generation_quarantine_created
+  ON ngs_generation_quarantine(created_at DESC);
+
+COMMENT ON TABLE ngs_generation_quarantine IS 'Generated lessons rejected by schema validation, kept for review';
+
+INSERT INTO ngs_schema_version (version) VALUES (56) ON CONFLICT (version) DO NOTHING;
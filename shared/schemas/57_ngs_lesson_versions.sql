@@ -0,0 +1,25 @@
+-- NGS Lesson Versions
+-- A snapshot of each lesson's content per content_version, written with every regeneration,
+-- import, curriculum pack update and authored lesson, so educators can diff what a change did.
+-- Lessons' current content is kept as their baseline version.
+
+CREATE TABLE IF NOT EXISTS ngs_lesson_versions (
+  lesson_id UUID NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+  version INTEGER NOT NULL,
+  content_markdown TEXT NOT NULL DEFAULT '',
+  metadata JSONB,
+  source VARCHAR(20) NOT NULL
+    CHECK (source IN ('generated', 'import', 'pack', 'authored', 'baseline')),
+  created_by UUID,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (lesson_id, version)
+);
+
+INSERT INTO ngs_lesson_versions (lesson_id, version, content_markdown, metadata, source, created_at)
+SELECT id, COALESCE(content_version, 0), COALESCE(content_markdown, ''), metadata, 'baseline', COALESCE(updated_at, NOW())
+FROM lessons
+ON CONFLICT (lesson_id, version) DO NOTHING;
+
+COMMENT ON TABLE ngs_lesson_versions IS 'Lesson content per content_version, for diffing changes';
+
+INSERT INTO ngs_schema_version (version) VALUES (57) ON CONFLICT (version) DO NOTHING;
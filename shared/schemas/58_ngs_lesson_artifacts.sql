@@ -0,0 +1,21 @@
+-- NGS Lesson Artifacts
+-- Generated lessons' artifacts (quiz items, notes outline, code snippets, glossary) are kept in
+-- lesson_artifacts, one row per lesson and type, replaced on each regeneration. Artifacts of
+-- lessons generated before are copied from their structured lesson.
+
+DELETE FROM lesson_artifacts a
+USING lesson_artifacts b
+WHERE a.lesson_id = b.lesson_id
+  AND a.artifact_type = b.artifact_type
+  AND (COALESCE(a.updated_at, a.created_at), a.id) < (COALESCE(b.updated_at, b.created_at), b.id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_lesson_artifacts_lesson_type ON lesson_artifacts(lesson_id, artifact_type);
+
+INSERT INTO lesson_artifacts (lesson_id, artifact_type, artifact_data)
+SELECT l.id, t.artifact_type, l.metadata->'artifacts'->t.artifact_type
+FROM lessons l
+CROSS JOIN (VALUES ('quiz_items'), ('notes_outline'), ('code_snippets'), ('glossary')) AS t(artifact_type)
+WHERE jsonb_typeof(l.metadata->'artifacts'->t.artifact_type) = 'array'
+ON CONFLICT (lesson_id, artifact_type) DO NOTHING;
+
+INSERT INTO ngs_schema_version (version) VALUES (58) ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,20 @@
+-- NGS Lesson Quiz Attempts
+-- Lesson quizzes are graded by the service against the lesson's generated quiz_items. Each
+-- attempt is kept, and the best score is what min_quiz_score and the completion XP tiers read.
+
+CREATE TABLE IF NOT EXISTS lesson_quiz_attempts (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL,
+  lesson_id UUID NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+  answers JSONB NOT NULL DEFAULT '{}',
+  results JSONB NOT NULL DEFAULT '[]',
+  score INTEGER NOT NULL CHECK (score BETWEEN 0 AND 100),
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_lesson_quiz_attempts_user_lesson
+  ON lesson_quiz_attempts(user_id, lesson_id, score DESC);
+
+COMMENT ON TABLE lesson_quiz_attempts IS 'Server-graded lesson quiz attempts; the best score gates completion';
+
+INSERT INTO ngs_schema_version (version) VALUES (59) ON CONFLICT (version) DO NOTHING;